@@ -0,0 +1,125 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// EndpointLogRule - logs requests which host and path match given regexp pattern with given level,
+// optionally including request and response bodies
+type EndpointLogRule struct {
+	Pattern             string `json:"pattern"`
+	IncludeRequestBody  bool   `json:"includeRequestBody"`
+	IncludeResponseBody bool   `json:"includeResponseBody"`
+	LogLevel            string `json:"logLevel"`
+
+	// compiled Pattern, set by SetEndpointLogRules
+	re *regexp.Regexp
+}
+
+// Level - returns rule log level, info is used when level is not set
+func (r EndpointLogRule) Level() (log.Level, error) {
+	if r.LogLevel == "" {
+		return log.InfoLevel, nil
+	}
+	return log.ParseLevel(r.LogLevel)
+}
+
+// LoadEndpointLogRules - reads endpoint log rules from JSON file, i.e.:
+//
+//	[{"pattern": "example.com/api/payment", "includeRequestBody": true, "logLevel": "info"}]
+func (c *Configuration) LoadEndpointLogRules(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var rules []EndpointLogRule
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return fmt.Errorf("failed to parse log rules file: %s", err.Error())
+	}
+
+	return c.SetEndpointLogRules(rules)
+}
+
+// SetEndpointLogRules - validates rules and compiles their patterns, requests are matched against compiled patterns
+func (c *Configuration) SetEndpointLogRules(rules []EndpointLogRule) error {
+	compiled := make([]EndpointLogRule, len(rules))
+	for i, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid log rule pattern '%s': %s", rule.Pattern, err.Error())
+		}
+		if _, err := rule.Level(); err != nil {
+			return fmt.Errorf("invalid log rule level '%s': %s", rule.LogLevel, err.Error())
+		}
+		rule.re = re
+		compiled[i] = rule
+	}
+
+	c.EndpointLogRules = compiled
+
+	return nil
+}
+
+// GetEndpointLogRule - returns first log rule that matches given host and path
+func (c *Configuration) GetEndpointLogRule(host, path string) *EndpointLogRule {
+	for i := range c.EndpointLogRules {
+		if re := c.EndpointLogRules[i].re; re != nil && re.MatchString(host+path) {
+			return &c.EndpointLogRules[i]
+		}
+	}
+	return nil
+}
+
+// logEndpoint - writes access log entry for request that matched endpoint log rule
+func logEndpoint(rule *EndpointLogRule, mode, clientIP string, req *http.Request, reqBody []byte, resp *http.Response) {
+	fields := log.Fields{
+		"mode":        mode,
+		"clientIP":    clientIP,
+		"method":      req.Method,
+		"destination": req.Host,
+		"path":        req.URL.Path,
+		"rawQuery":    req.URL.RawQuery,
+		"pattern":     rule.Pattern,
+	}
+
+	if id := req.Header.Get(RequestIDHeader); id != "" {
+		fields["requestID"] = id
+	}
+
+	if rule.IncludeRequestBody {
+		fields["requestBody"] = string(reqBody)
+	}
+
+	if resp != nil {
+		fields["status"] = resp.StatusCode
+		if rule.IncludeResponseBody && resp.Body != nil {
+			respBody, err := extractBody(resp)
+			if err == nil {
+				fields["responseBody"] = string(respBody)
+			}
+		}
+	}
+
+	entry := log.WithFields(fields)
+
+	level, _ := rule.Level()
+	switch level {
+	case log.DebugLevel:
+		entry.Debug("endpoint request")
+	case log.WarnLevel:
+		entry.Warn("endpoint request")
+	case log.ErrorLevel, log.FatalLevel, log.PanicLevel:
+		// log rules should never stop hoverfly
+		entry.Error("endpoint request")
+	default:
+		entry.Info("endpoint request")
+	}
+}
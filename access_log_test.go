@@ -0,0 +1,89 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestGetEndpointLogRule(t *testing.T) {
+	cfg := InitSettings()
+	err := cfg.SetEndpointLogRules([]EndpointLogRule{
+		{Pattern: "example.com/api/payment", IncludeRequestBody: true},
+		{Pattern: "example.com", LogLevel: "debug"},
+	})
+	testutil.Expect(t, err, nil)
+
+	rule := cfg.GetEndpointLogRule("example.com", "/api/payment")
+	testutil.Expect(t, rule.IncludeRequestBody, true)
+
+	rule = cfg.GetEndpointLogRule("example.com", "/api/users")
+	testutil.Expect(t, rule.LogLevel, "debug")
+
+	rule = cfg.GetEndpointLogRule("other.com", "/")
+	testutil.Expect(t, rule == nil, true)
+}
+
+func TestEndpointLogRuleLevel(t *testing.T) {
+	level, err := EndpointLogRule{}.Level()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, level, log.InfoLevel)
+
+	level, err = EndpointLogRule{LogLevel: "warning"}.Level()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, level, log.WarnLevel)
+
+	_, err = EndpointLogRule{LogLevel: "loud"}.Level()
+	testutil.Refute(t, err, nil)
+}
+
+func TestLogEndpointIncludesBodies(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetLevel(log.InfoLevel)
+	defer log.SetOutput(os.Stderr)
+	defer log.SetLevel(log.FatalLevel)
+
+	req, err := http.NewRequest("POST", "http://example.com/api/payment", nil)
+	testutil.Expect(t, err, nil)
+
+	resp := &http.Response{StatusCode: 201, Body: ioutil.NopCloser(bytes.NewBufferString("payment accepted"))}
+
+	rule := &EndpointLogRule{Pattern: "payment", IncludeRequestBody: true, IncludeResponseBody: true}
+	logEndpoint(rule, SimulateMode, "10.0.0.1", req, []byte("amount=10"), resp)
+
+	output := buf.String()
+	testutil.Expect(t, strings.Contains(output, "amount=10"), true)
+	testutil.Expect(t, strings.Contains(output, "payment accepted"), true)
+
+	// body is still available to the client
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "payment accepted")
+}
+
+func TestLogEndpointWithoutBodies(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetLevel(log.InfoLevel)
+	defer log.SetOutput(os.Stderr)
+	defer log.SetLevel(log.FatalLevel)
+
+	req, err := http.NewRequest("POST", "http://example.com/api/users", nil)
+	testutil.Expect(t, err, nil)
+
+	resp := &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString("secret"))}
+
+	logEndpoint(&EndpointLogRule{Pattern: "users"}, SimulateMode, "10.0.0.1", req, []byte("password=x"), resp)
+
+	output := buf.String()
+	testutil.Expect(t, strings.Contains(output, "/api/users"), true)
+	testutil.Expect(t, strings.Contains(output, "password=x"), false)
+	testutil.Expect(t, strings.Contains(output, "secret"), false)
+}
@@ -8,7 +8,9 @@ import (
 	"io/ioutil"
 	"net/http"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	// static assets
@@ -23,6 +25,7 @@ import (
 
 	// auth
 	"github.com/SpectoLabs/hoverfly/authentication"
+	"github.com/SpectoLabs/hoverfly/authentication/backends"
 	"github.com/SpectoLabs/hoverfly/authentication/controllers"
 	"github.com/SpectoLabs/hoverfly/metrics"
 	"github.com/SpectoLabs/hoverfly/models"
@@ -31,6 +34,21 @@ import (
 // recordedRequests struct encapsulates payload data
 type recordedRequests struct {
 	Data []models.PayloadView `json:"data"`
+	// Bodies - response bodies shared by records when bodies are deduplicated, keyed by their SHA-256 hash
+	Bodies map[string]string `json:"bodies,omitempty"`
+}
+
+type recordBody struct {
+	ID          string `json:"id"`
+	Method      string `json:"method"`
+	Destination string `json:"destination"`
+	Path        string `json:"path"`
+	ContentType string `json:"contentType"`
+	Length      int    `json:"length"`
+}
+
+type recordBodies struct {
+	Data []recordBody `json:"data"`
 }
 
 type storedMetadata struct {
@@ -51,9 +69,67 @@ type statsResponse struct {
 	RecordsCount int           `json:"recordsCount"`
 }
 
+// statsResetResponse - counter values before they were reset
+type statsResetResponse struct {
+	Counters map[string]int64 `json:"counters"`
+}
+
+type cacheStatsResponse struct {
+	RecordsCount  int      `json:"recordsCount"`
+	MetadataCount int      `json:"metadataCount"`
+	Keys          []string `json:"keys"`
+}
+
 type stateRequest struct {
-	Mode        string `json:"mode"`
-	Destination string `json:"destination"`
+	Mode         string   `json:"mode"`
+	Destination  string   `json:"destination"`
+	Destinations []string `json:"destinations,omitempty"`
+	RecordOnce   *bool    `json:"recordOnce,omitempty"`
+}
+
+type timelineResponse struct {
+	Data []TimelineEvent `json:"data"`
+}
+
+type importURLRequest struct {
+	URL string `json:"url"`
+}
+
+type importURLResponse struct {
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+type cacheSaveRequest struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+}
+
+type cacheSaveResponse struct {
+	Message string `json:"message"`
+	Path    string `json:"path,omitempty"`
+	Count   int    `json:"count"`
+}
+
+type replayRequest struct {
+	Target        string   `json:"target"`
+	TargetBaseURL string   `json:"targetBaseURL"`
+	Methods       []string `json:"methods"`
+}
+
+type replayResponse struct {
+	Data       []ReplayResult `json:"data"`
+	Matches    int            `json:"matches"`
+	Mismatches int            `json:"mismatches"`
+	Errors     int            `json:"errors"`
+}
+
+type flowsResponse struct {
+	Flows []FlowSimulation `json:"flows"`
+}
+
+type middlewareResponse struct {
+	Middleware string `json:"middleware"`
 }
 
 type messageResponse struct {
@@ -70,10 +146,8 @@ func (m *messageResponse) Encode() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// StartAdminInterface - starts admin interface web server
-func (d *Hoverfly) StartAdminInterface() {
-
-	// starting admin interface
+// adminHandler - admin API together with logging, error code and CORS middleware
+func (d *Hoverfly) adminHandler() *negroni.Negroni {
 	mux := getBoneRouter(*d)
 	n := negroni.Classic()
 
@@ -84,7 +158,36 @@ func (d *Hoverfly) StartAdminInterface() {
 	}
 
 	n.Use(negronilogrus.NewCustomMiddleware(logLevel, &log.JSONFormatter{}, "admin"))
+	n.Use(negroni.HandlerFunc(errorCodeMiddleware))
+	if len(d.Cfg.AdminCORSOrigins) > 0 {
+		n.Use(negroni.HandlerFunc(d.corsMiddleware))
+	}
 	n.UseHandler(mux)
+	return n
+}
+
+// StartAdminInterface - starts admin interface web server
+func (d *Hoverfly) StartAdminInterface() {
+
+	// starting admin interface
+	n := d.adminHandler()
+
+	if d.Cfg.AdminTLS {
+		if err := d.Cfg.EnsureAdminTLSCertificate(); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"cert":  d.Cfg.AdminTLSCertFile,
+				"key":   d.Cfg.AdminTLSKeyFile,
+			}).Fatal("Failed to prepare admin TLS certificate")
+		}
+
+		log.WithFields(log.Fields{
+			"AdminPort": d.Cfg.AdminPort,
+			"cert":      d.Cfg.AdminTLSCertFile,
+		}).Info("Admin interface is starting with TLS...")
+
+		log.Fatal(http.ListenAndServeTLS(fmt.Sprintf(":%s", d.Cfg.AdminPort), d.Cfg.AdminTLSCertFile, d.Cfg.AdminTLSKeyFile, n))
+	}
 
 	// admin interface starting message
 	log.WithFields(log.Fields{
@@ -112,6 +215,15 @@ func getBoneRouter(d Hoverfly) *bone.Mux {
 		d.Cfg.AuthEnabled)
 
 	mux.Post("/api/token-auth", http.HandlerFunc(ac.Login))
+
+	// liveness and readiness probes don't authenticate
+	mux.Get("/api/health", negroni.New(
+		negroni.HandlerFunc(d.HealthHandler),
+	))
+	// checked by Hoverfly instances that use this one as upstream, see UpstreamHoverflyPool
+	mux.Get(UpstreamHealthPath, negroni.New(
+		negroni.HandlerFunc(d.HealthHandler),
+	))
 	mux.Get("/api/refresh-token-auth", negroni.New(
 		negroni.HandlerFunc(am.RequireTokenAuthentication),
 		negroni.HandlerFunc(ac.RefreshToken),
@@ -121,6 +233,11 @@ func getBoneRouter(d Hoverfly) *bone.Mux {
 		negroni.HandlerFunc(ac.Logout),
 	))
 
+	mux.Post("/api/auth/totp/enroll", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.TOTPEnrollHandler),
+	))
+
 	mux.Get("/api/users", negroni.New(
 		negroni.HandlerFunc(am.RequireTokenAuthentication),
 		negroni.HandlerFunc(ac.GetAllUsersHandler),
@@ -131,6 +248,35 @@ func getBoneRouter(d Hoverfly) *bone.Mux {
 		negroni.HandlerFunc(d.AllRecordsHandler),
 	))
 
+	mux.Get("/api/records/search", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.SearchRecordsHandler),
+	))
+	mux.Post("/api/records/search", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.SearchRecordsHandler),
+	))
+
+	mux.Get("/api/records/bodies", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.RecordBodiesHandler),
+	))
+
+	mux.Get("/api/records/recent", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.RecentRecordsHandler),
+	))
+
+	mux.Get("/api/records/:id/body", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.RecordBodyHandler),
+	))
+
+	mux.Post("/api/records/:id/clone", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.CloneRecordHandler),
+	))
+
 	mux.Delete("/api/records", negroni.New(
 		negroni.HandlerFunc(am.RequireTokenAuthentication),
 		negroni.HandlerFunc(d.DeleteAllRecordsHandler),
@@ -140,6 +286,66 @@ func getBoneRouter(d Hoverfly) *bone.Mux {
 		negroni.HandlerFunc(d.ImportRecordsHandler),
 	))
 
+	mux.Post("/api/simulation/import-url", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.ImportURLHandler),
+	))
+
+	mux.Get("/api/simulation/har", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.ExportHARHandler),
+	))
+	mux.Get("/api/simulation/checksum", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.SimulationChecksumHandler),
+	))
+	mux.Get("/api/simulation/openapi", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.ExportOpenAPIHandler),
+	))
+	mux.Post("/api/simulation/har", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.ImportHARHandler),
+	))
+	mux.Post("/api/simulation/pcap", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.ImportPCAPHandler),
+	))
+
+	mux.Post("/api/simulation/diff", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.SimulationDiffHandler),
+	))
+
+	mux.Post("/api/cache/gc", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.CacheGCHandler),
+	))
+
+	mux.Post("/api/cache/save", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.CacheSaveHandler),
+	))
+
+	mux.Post("/api/replay", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.ReplayHandler),
+	))
+
+	mux.Post("/api/middleware/upload", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.MiddlewareUploadHandler),
+	))
+
+	mux.Get("/api/flows", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.AllFlowsHandler),
+	))
+	mux.Post("/api/flows", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.CreateFlowHandler),
+	))
+
 	mux.Get("/api/metadata", negroni.New(
 		negroni.HandlerFunc(am.RequireTokenAuthentication),
 		negroni.HandlerFunc(d.AllMetadataHandler),
@@ -163,9 +369,42 @@ func getBoneRouter(d Hoverfly) *bone.Mux {
 		negroni.HandlerFunc(am.RequireTokenAuthentication),
 		negroni.HandlerFunc(d.StatsHandler),
 	))
+	mux.Post("/api/stats/reset", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.StatsResetHandler),
+	))
+	mux.Get("/api/stats/cache", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.CacheStatsHandler),
+	))
+	mux.Get("/api/stats/tls", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.TLSStatsHandler),
+	))
+	mux.Get("/api/hooks", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.HooksHandler),
+	))
+	mux.Get("/api/metrics/sizes", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.SizeMetricsHandler),
+	))
+	mux.Get("/metrics", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.Wrap(metrics.MetricsHandler()),
+	))
 	// TODO: check auth for websocket connection
 	mux.Get("/api/statsws", http.HandlerFunc(d.StatsWSHandler))
 
+	mux.Get("/api/ws", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.ControlWSHandler),
+	))
+	mux.Get("/api/events", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.EventsHandler),
+	))
+
 	mux.Get("/api/state", negroni.New(
 		negroni.HandlerFunc(am.RequireTokenAuthentication),
 		negroni.HandlerFunc(d.CurrentStateHandler),
@@ -175,6 +414,34 @@ func getBoneRouter(d Hoverfly) *bone.Mux {
 		negroni.HandlerFunc(d.StateHandler),
 	))
 
+	mux.Get("/api/config/diff", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.ConfigDiffHandler),
+	))
+
+	mux.Get("/api/diffs", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.AllDiffsHandler),
+	))
+	mux.Delete("/api/diffs", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.DeleteDiffsHandler),
+	))
+
+	mux.Delete("/api/sequences", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.DeleteSequencesHandler),
+	))
+
+	mux.Get("/api/timeline", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.TimelineHandler),
+	))
+	mux.Get("/api/diagram", negroni.New(
+		negroni.HandlerFunc(am.RequireTokenAuthentication),
+		negroni.HandlerFunc(d.DiagramHandler),
+	))
+
 	mux.Post("/api/add", negroni.New(
 		negroni.HandlerFunc(am.RequireTokenAuthentication),
 		negroni.HandlerFunc(d.ManualAddHandler),
@@ -216,137 +483,364 @@ func getBoneRouter(d Hoverfly) *bone.Mux {
 	return mux
 }
 
-// AllRecordsHandler returns JSON content type http response
+// AllRecordsHandler returns JSON content type http response, with ?normalised=true records are exported with
+// ExportNormalised
 func (d *Hoverfly) AllRecordsHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
-	records, err := d.RequestCache.GetAllValues()
+	var buf bytes.Buffer
+	opts := ExportOptions{IncludeHistory: req.URL.Query().Get("history") == "true"}
 
-	if err == nil {
+	export := func() error { return d.ExportSimulation(&buf, opts) }
+	if req.URL.Query().Get("normalised") == "true" {
+		export = func() error { return d.ExportNormalised(&buf) }
+	}
 
-		var payloads []models.PayloadView
+	if err := export(); err != nil {
+		log.WithFields(log.Fields{
+			"Error": err.Error(),
+		}).Error("Failed to get data from cache!")
 
-		for _, v := range records {
-			if payload, err := models.NewPayloadFromBytes(v); err == nil {
-				payloadView := payload.ConvertToPayloadView()
-				payloads = append(payloads, *payloadView)
-			} else {
-				log.Error(err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-		w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf.Bytes())
+}
 
-		var response models.PayloadViewData
-		response.Data = payloads
-		b, err := json.Marshal(response)
+// RecordBodiesHandler returns a list of stored response bodies with their ids, which can be used to download them
+func (d *Hoverfly) RecordBodiesHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	entries, err := d.RequestCache.GetAllEntries()
 
-		if err != nil {
-			log.Error(err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		} else {
-			w.Write(b)
-			return
-		}
-	} else {
+	if err != nil {
 		log.WithFields(log.Fields{
 			"Error": err.Error(),
 		}).Error("Failed to get data from cache!")
 
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-		w.WriteHeader(500) // can't process this entity
+		w.WriteHeader(500)
 		return
 	}
-}
-
-// RecordsCount returns number of captured requests as a JSON payload
-func (d *Hoverfly) RecordsCount(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
-	count, err := d.RequestCache.RecordsCount()
-
-	if err == nil {
 
-		w.Header().Set("Content-Type", "application/json")
+	ids := make([]string, 0, len(entries))
+	for k := range entries {
+		ids = append(ids, k)
+	}
+	sort.Strings(ids)
 
-		var response recordsCount
-		response.Count = count
-		b, err := json.Marshal(response)
+	var response recordBodies
+	response.Data = []recordBody{}
 
+	for _, id := range ids {
+		payload, err := models.NewPayloadFromBytes(entries[id])
 		if err != nil {
 			log.Error(err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
-		} else {
-			w.Write(b)
 			return
 		}
-	} else {
-		log.WithFields(log.Fields{
-			"Error": err.Error(),
-		}).Error("Failed to get data from cache!")
-
-		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-		w.WriteHeader(500) // can't process this entity
-		return
+		response.Data = append(response.Data, recordBody{
+			ID:          id,
+			Method:      payload.Request.Method,
+			Destination: payload.Request.Destination,
+			Path:        payload.Request.Path,
+			ContentType: responseContentType(payload.Response),
+			Length:      len(payload.Response.Body),
+		})
 	}
-}
-
-// StatsHandler - returns current stats about Hoverfly (request counts, record count)
-func (d *Hoverfly) StatsHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
-	stats := d.Counter.Flush()
 
-	count, err := d.RequestCache.RecordsCount()
+	w.Header().Set("Content-Type", "application/json")
 
+	b, err := json.Marshal(response)
 	if err != nil {
 		log.Error(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	w.Write(b)
+}
 
-	var sr statsResponse
-	sr.Stats = stats
-	sr.RecordsCount = count
+// RecordBodyHandler returns raw response body of a single record with stored content type
+func (d *Hoverfly) RecordBodyHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	id := bone.GetValue(req, "id")
 
-	w.Header().Set("Content-Type", "application/json")
+	payloadBts, err := d.RequestCache.Get([]byte(id))
 
-	b, err := json.Marshal(sr)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Record '%s' not found", id), http.StatusNotFound)
+		return
+	}
 
+	payload, err := models.NewPayloadFromBytes(payloadBts)
 	if err != nil {
-		log.Error(err)
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   id,
+		}).Error("Failed to decode payload")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
-	} else {
-		w.Write(b)
 		return
 	}
 
+	w.Header().Set("Content-Type", responseContentType(payload.Response))
+
+	// body is stored as it was received, letting client know how to decode it
+	if ce := payload.Response.Headers["Content-Encoding"]; len(ce) > 0 {
+		w.Header().Set("Content-Encoding", ce[0])
+	}
+
+	w.Write([]byte(payload.Response.Body))
 }
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+type cloneRecordRequest struct {
+	NewMethod string `json:"newMethod"`
+	NewHost   string `json:"newHost"`
+	NewPath   string `json:"newPath"`
 }
 
-// StatsWSHandler - returns current stats about Hoverfly (request counts, record count) through the websocket
-func (d *Hoverfly) StatsWSHandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+type cloneRecordResponse struct {
+	ID string `json:"id"`
+}
+
+// CloneRecordHandler - stores copy of a record under new method, host and path, fields that are not supplied are
+// copied from the source record. Response stays the same.
+func (d *Hoverfly) CloneRecordHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	id := bone.GetValue(req, "id")
+
+	var cr cloneRecordRequest
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&cr); err != nil {
+		http.Error(w, fmt.Sprintf("Bad request body: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	payloadBts, err := d.RequestCache.Get([]byte(id))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Record '%s' not found", id), http.StatusNotFound)
+		return
+	}
+
+	payload, err := models.NewPayloadFromBytes(payloadBts)
 	if err != nil {
 		log.WithFields(log.Fields{
-			"error": err,
-		}).Error("failed to upgrade websocket")
+			"error": err.Error(),
+			"key":   id,
+		}).Error("Failed to decode payload")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// defining counters for delta check
-	var recordsCount int
-	var statsCounters map[string]int64
+	if cr.NewMethod != "" {
+		payload.Request.Method = cr.NewMethod
+	}
+	if cr.NewHost != "" {
+		payload.Request.Destination = cr.NewHost
+	}
+	if cr.NewPath != "" {
+		payload.Request.Path = cr.NewPath
+	}
 
-	for {
-		messageType, p, err := conn.ReadMessage()
-		if err != nil {
+	newID := payload.Id()
+	if _, err := d.RequestCache.Get([]byte(newID)); err == nil {
+		http.Error(w, fmt.Sprintf("Record '%s' already exists", newID), http.StatusConflict)
+		return
+	}
+
+	payload.CreatedAt = time.Now()
+	payload.LastAccessedAt = time.Time{}
+	payload.UseCount = 0
+
+	// body file is removed together with the record, so the clone gets its own copy
+	if payload.RequestBodyFile != "" {
+		if payload.RequestBodyFile, err = copyRequestBodyFile(payload.RequestBodyFile); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"key":   id,
+			}).Error("Failed to copy request body file of cloned record")
+			setErrorCode(w, ErrorCodeCacheError)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		log.WithFields(log.Fields{
-			"message": string(p),
+	}
+
+	bts, err := payload.Encode()
+	if err == nil {
+		err = d.RequestCache.Set([]byte(newID), bts)
+		d.captureBloom.add(newID)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   id,
+		}).Error("Failed to store cloned record")
+		setErrorCode(w, ErrorCodeCacheError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"sourceID":    id,
+		"id":          newID,
+		"method":      payload.Request.Method,
+		"destination": payload.Request.Destination,
+		"path":        payload.Request.Path,
+	}).Info("Record cloned")
+
+	d.recordAdminEvent(req, ActionTypeRecordCloned)
+
+	b, err := json.Marshal(cloneRecordResponse{ID: newID})
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(b)
+}
+
+// responseContentType - returns stored content type of the response or sniffs it from the body
+func responseContentType(r models.ResponseDetails) string {
+	if ct := r.Headers["Content-Type"]; len(ct) > 0 && ct[0] != "" {
+		return ct[0]
+	}
+	return http.DetectContentType([]byte(r.Body))
+}
+
+// RecordsCount returns number of captured requests as a JSON payload
+func (d *Hoverfly) RecordsCount(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	count, err := d.RequestCache.RecordsCount()
+
+	if err == nil {
+
+		w.Header().Set("Content-Type", "application/json")
+
+		var response recordsCount
+		response.Count = count
+		b, err := json.Marshal(response)
+
+		if err != nil {
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			w.Write(b)
+			return
+		}
+	} else {
+		log.WithFields(log.Fields{
+			"Error": err.Error(),
+		}).Error("Failed to get data from cache!")
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(500) // can't process this entity
+		return
+	}
+}
+
+// StatsHandler - returns current stats about Hoverfly (request counts, record count)
+func (d *Hoverfly) StatsHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	stats := d.Counter.Flush()
+
+	count, err := d.RequestCache.RecordsCount()
+
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+
+	var sr statsResponse
+	sr.Stats = stats
+	sr.RecordsCount = count
+
+	w.Header().Set("Content-Type", "application/json")
+
+	b, err := json.Marshal(sr)
+
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		w.Write(b)
+		return
+	}
+
+}
+
+// StatsResetHandler - zeroes request counters and returns their values before the reset, requests counted during
+// the reset are neither lost nor reported twice
+func (d *Hoverfly) StatsResetHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	sr := statsResetResponse{Counters: d.Counter.Reset()}
+	d.recordAdminEvent(req, ActionTypeStatsReset)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	b, err := json.Marshal(sr)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
+// CacheStatsHandler - returns number of stored records and metadata entries together with record keys
+func (d *Hoverfly) CacheStatsHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	var sr cacheStatsResponse
+	var err error
+
+	if sr.Keys, err = d.RequestCache.Keys(); err == nil {
+		if sr.RecordsCount, err = d.RequestCache.Count(); err == nil {
+			sr.MetadataCount, err = d.MetadataCache.Count()
+		}
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get cache stats")
+		setErrorCode(w, ErrorCodeCacheError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b, err := json.Marshal(sr)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+// StatsWSHandler - returns current stats about Hoverfly (request counts, record count) through the websocket
+func (d *Hoverfly) StatsWSHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("failed to upgrade websocket")
+		return
+	}
+
+	// defining counters for delta check
+	var recordsCount int
+	var statsCounters map[string]int64
+
+	for {
+		messageType, p, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		log.WithFields(log.Fields{
+			"message": string(p),
 		}).Info("Got message...")
 
 		for _ = range time.Tick(1 * time.Second) {
@@ -366,70 +860,504 @@ func (d *Hoverfly) StatsWSHandler(w http.ResponseWriter, r *http.Request) {
 				sr.Stats = stats
 				sr.RecordsCount = count
 
-				b, err := json.Marshal(sr)
+				b, err := json.Marshal(sr)
+
+				if err = conn.WriteMessage(messageType, b); err != nil {
+					log.WithFields(log.Fields{
+						"message": p,
+						"error":   err.Error(),
+					}).Debug("Got error when writing message...")
+					continue
+				}
+				recordsCount = count
+				statsCounters = stats.Counters
+			}
+		}
+	}
+}
+
+// ImportRecordsHandler - accepts JSON payload and saves it to cache
+func (d *Hoverfly) ImportRecordsHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+
+	var requests recordedRequests
+
+	defer req.Body.Close()
+	body, err := ioutil.ReadAll(req.Body)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var response messageResponse
+
+	if err != nil {
+		// failed to read response body
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Could not read request body!")
+		http.Error(w, "Failed to read request body.", 400)
+		return
+	}
+
+	err = json.Unmarshal(body, &requests)
+	if err == nil {
+		err = requests.resolveBodyRefs()
+	}
+
+	if err != nil {
+		w.WriteHeader(422) // can't process this entity
+		return
+	}
+
+	err = d.ImportPayloads(requests.Data)
+
+	if err != nil {
+		response.Message = err.Error()
+		w.WriteHeader(400)
+	} else {
+		response.Message = fmt.Sprintf("%d payloads import complete.", len(requests.Data))
+		d.recordAdminEvent(req, ActionTypeRecordsImported)
+	}
+
+	b, err := response.Encode()
+	if err != nil {
+		// failed to read response body
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Could not encode response body!")
+		http.Error(w, "Failed to encode response", 500)
+		return
+	}
+
+	w.Write(b)
+
+}
+
+// ExportHARHandler - returns all records as HTTP Archive (HAR 1.2)
+func (d *Hoverfly) ExportHARHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	var buf bytes.Buffer
+	if err := d.ExportHAR(&buf); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to export HAR")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf.Bytes())
+}
+
+// ExportOpenAPIHandler - returns OpenAPI 3.0 document in YAML that describes all records
+func (d *Hoverfly) ExportOpenAPIHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	var buf bytes.Buffer
+	if err := d.ExportOpenAPIv3(&buf); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to export OpenAPI document")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(buf.Bytes())
+}
+
+// ImportHARHandler - imports request and response pairs of HTTP Archive (HAR 1.2) supplied in request body
+func (d *Hoverfly) ImportHARHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	defer req.Body.Close()
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var response messageResponse
+
+	if err := d.ImportHAR(req.Body); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to import HAR")
+		response.Message = err.Error()
+		w.WriteHeader(400)
+	} else {
+		response.Message = "HAR import complete."
+		d.recordAdminEvent(req, ActionTypeRecordsImported)
+	}
+
+	b, err := response.Encode()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Could not encode response body!")
+		http.Error(w, "Failed to encode response", 500)
+		return
+	}
+
+	w.Write(b)
+}
+
+// ImportPCAPHandler - imports cleartext HTTP requests and responses of pcap or pcapng capture supplied in request
+// body
+func (d *Hoverfly) ImportPCAPHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	defer req.Body.Close()
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	var response messageResponse
+
+	if imported, err := d.ImportPCAP(req.Body); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to import PCAP")
+		response.Message = err.Error()
+		w.WriteHeader(400)
+	} else {
+		response.Message = fmt.Sprintf("PCAP import complete, %d requests imported.", imported)
+		d.recordAdminEvent(req, ActionTypeRecordsImported)
+	}
+
+	b, err := response.Encode()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Could not encode response body!")
+		http.Error(w, "Failed to encode response", 500)
+		return
+	}
+
+	w.Write(b)
+}
+
+// ImportURLHandler - fetches simulation from URL supplied in request body and imports it
+func (d *Hoverfly) ImportURLHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	var ir importURLRequest
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	defer req.Body.Close()
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Could not read request body!")
+		http.Error(w, "Failed to read request body.", 400)
+		return
+	}
+
+	var response importURLResponse
+
+	err = json.Unmarshal(body, &ir)
+	if err != nil || ir.URL == "" {
+		response.Message = "Bad request body, expected {\"url\": \"<simulation URL>\"}"
+		w.WriteHeader(400)
+	} else {
+		response.Count, err = d.ImportSimulationFromURL(ir.URL)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"url":   ir.URL,
+			}).Error("Failed to import simulation from URL")
+			response.Message = err.Error()
+			w.WriteHeader(400)
+		} else {
+			response.Message = fmt.Sprintf("%d payloads import complete.", response.Count)
+			d.recordAdminEvent(req, ActionTypeRecordsImported)
+		}
+	}
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Could not encode response body!")
+		http.Error(w, "Failed to encode response", 500)
+		return
+	}
+
+	w.Write(b)
+}
+
+// CacheSaveHandler - writes all records to file path supplied in request body, records don't go through the
+// response so that large caches can be saved
+func (d *Hoverfly) CacheSaveHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	var sr cacheSaveRequest
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	defer req.Body.Close()
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Could not read request body!")
+		http.Error(w, "Failed to read request body.", 400)
+		return
+	}
+
+	var response cacheSaveResponse
+
+	err = json.Unmarshal(body, &sr)
+	if err != nil || sr.Path == "" {
+		response.Message = "Bad request body, expected {\"path\": \"<file path>\", \"format\": \"json\"}"
+		w.WriteHeader(400)
+	} else if response.Count, err = d.SaveCache(sr.Path, sr.Format); err != nil {
+		log.WithFields(log.Fields{
+			"error":  err.Error(),
+			"path":   sr.Path,
+			"format": sr.Format,
+		}).Error("Failed to save cache")
+		response.Message = err.Error()
+		if err == errCacheSaveDisabled || err == errCacheSaveOutside {
+			w.WriteHeader(http.StatusForbidden)
+		} else {
+			w.WriteHeader(400)
+		}
+	} else {
+		response.Path = sr.Path
+		response.Message = fmt.Sprintf("%d payloads saved.", response.Count)
+		d.recordAdminEvent(req, ActionTypeCacheSaved)
+	}
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Could not encode response body!")
+		http.Error(w, "Failed to encode response", 500)
+		return
+	}
+
+	w.Write(b)
+}
+
+// ReplayHandler - sends recorded requests to target supplied in request body and returns report of matching and
+// mismatching responses, only GET requests are replayed unless request body lists other methods. Replay stops when
+// client goes away.
+func (d *Hoverfly) ReplayHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	var rr replayRequest
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	defer req.Body.Close()
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Could not read request body!")
+		http.Error(w, "Failed to read request body.", 400)
+		return
+	}
+
+	err = json.Unmarshal(body, &rr)
+	if rr.TargetBaseURL != "" {
+		rr.Target = rr.TargetBaseURL
+	}
+	if err != nil || rr.Target == "" {
+		var response messageResponse
+		response.Message = "Bad request body, expected {\"targetBaseURL\": \"https://newhost\"}"
+		w.WriteHeader(400)
+		b, _ := response.Encode()
+		w.Write(b)
+		return
+	}
+
+	results, err := d.ReplayRequestsWithOptions(req.Context(), rr.Target, ReplayOptions{Methods: rr.Methods})
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":  err.Error(),
+			"target": rr.Target,
+		}).Error("Failed to replay requests")
+		var response messageResponse
+		response.Message = err.Error()
+		w.WriteHeader(400)
+		b, _ := response.Encode()
+		w.Write(b)
+		return
+	}
+	d.recordAdminEvent(req, ActionTypeRequestsReplayed)
+
+	report := replayResponse{Data: results}
+	for _, result := range results {
+		switch {
+		case result.Error != "":
+			report.Errors++
+		case result.Match:
+			report.Matches++
+		default:
+			report.Mismatches++
+		}
+	}
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Could not encode response body!")
+		http.Error(w, "Failed to encode response", 500)
+		return
+	}
+
+	w.Write(b)
+}
+
+// CacheGCHandler - starts cache garbage collection in the background, result is logged
+func (d *Hoverfly) CacheGCHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	go func() {
+		if _, err := d.CollectGarbage(); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Error("Cache garbage collection failed")
+		}
+	}()
+
+	d.recordAdminEvent(req, ActionTypeCacheGC)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusAccepted)
+
+	var response messageResponse
+	response.Message = "Cache garbage collection started"
+	b, err := response.Encode()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Could not encode response body!")
+		return
+	}
+	w.Write(b)
+}
+
+// SimulationDiffHandler - compares simulations uploaded as 'baseline' and 'current' multipart form fields,
+// running cache is not modified
+func (d *Hoverfly) SimulationDiffHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	baseline, _, err := req.FormFile("baseline")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read 'baseline' form field: %s", err.Error()), 400)
+		return
+	}
+	defer baseline.Close()
+
+	current, _, err := req.FormFile("current")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read 'current' form field: %s", err.Error()), 400)
+		return
+	}
+	defer current.Close()
+
+	diff, err := d.Cfg.DiffSimulations(baseline, current)
+	if err != nil {
+		http.Error(w, err.Error(), 422)
+		return
+	}
 
-				if err = conn.WriteMessage(messageType, b); err != nil {
-					log.WithFields(log.Fields{
-						"message": p,
-						"error":   err.Error(),
-					}).Debug("Got error when writing message...")
-					continue
-				}
-				recordsCount = count
-				statsCounters = stats.Counters
-			}
-		}
+	b, err := json.Marshal(diff)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	w.Write(b)
 }
 
-// ImportRecordsHandler - accepts JSON payload and saves it to cache
-func (d *Hoverfly) ImportRecordsHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+// MiddlewareUploadHandler - saves middleware uploaded as 'file' multipart form field and starts using it. Uploaded
+// file is executed for every request, uploads are refused unless authentication is enabled or AllowMiddlewareUpload
+// is set.
+func (d *Hoverfly) MiddlewareUploadHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
-	var requests recordedRequests
+	if !d.Cfg.AuthEnabled && !d.Cfg.AllowMiddlewareUpload {
+		log.Warn("Middleware upload refused, authentication is disabled and uploads are not allowed")
+		http.Error(w, "Middleware upload requires authentication to be enabled or '-allow-middleware-upload'", http.StatusForbidden)
+		return
+	}
 
-	defer req.Body.Close()
-	body, err := ioutil.ReadAll(req.Body)
+	// leaving some space for multipart boundaries and headers
+	req.Body = http.MaxBytesReader(w, req.Body, d.Cfg.MaxMiddlewareSizeBytes+1024*1024)
 
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	var response messageResponse
+	file, _, err := req.FormFile("file")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read 'file' form field: %s", err.Error()), 400)
+		return
+	}
+	defer file.Close()
 
+	path, err := saveMiddleware(file, d.Cfg.MiddlewareUploadDir, d.Cfg.MaxMiddlewareSizeBytes)
+	if err == errMiddlewareTooLarge {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
 	if err != nil {
-		// failed to read response body
 		log.WithFields(log.Fields{
 			"error": err.Error(),
-		}).Error("Could not read request body!")
-		http.Error(w, "Failed to read request body.", 400)
+		}).Error("Failed to save uploaded middleware")
+		http.Error(w, "Failed to save middleware", 500)
 		return
 	}
 
-	err = json.Unmarshal(body, &requests)
+	d.Cfg.SetMiddleware(path)
+
+	log.WithFields(log.Fields{
+		"middleware": path,
+	}).Info("middleware uploaded")
+
+	d.recordAdminEvent(req, ActionTypeMiddlewareUploaded)
 
+	b, err := json.Marshal(middlewareResponse{Middleware: path})
 	if err != nil {
-		w.WriteHeader(422) // can't process this entity
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	w.Write(b)
+}
 
-	err = d.ImportPayloads(requests.Data)
+// AllFlowsHandler - returns stored flow simulations
+func (d *Hoverfly) AllFlowsHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	flows, err := d.GetFlows()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("Failed to get flows")
+		setErrorCode(w, ErrorCodeCacheError)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
 
+	b, err := json.Marshal(flowsResponse{Flows: flows})
 	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// CreateFlowHandler - stores flow simulation, i.e. OAuth2 authorize redirect followed by token exchange and API call
+func (d *Hoverfly) CreateFlowHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	var flow FlowSimulation
+	var response messageResponse
+
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&flow); err != nil {
+		response.Message = fmt.Sprintf("Bad request body: %s", err.Error())
+		w.WriteHeader(400)
+	} else if err := d.SaveFlow(flow); err != nil {
 		response.Message = err.Error()
 		w.WriteHeader(400)
 	} else {
-		response.Message = fmt.Sprintf("%d payloads import complete.", len(requests.Data))
+		response.Message = fmt.Sprintf("flow '%s' with %d steps created", flow.Name, len(flow.Steps))
+		d.recordAdminEvent(req, ActionTypeFlowCreated)
+		w.WriteHeader(201)
 	}
 
 	b, err := response.Encode()
 	if err != nil {
-		// failed to read response body
 		log.WithFields(log.Fields{
 			"error": err.Error(),
 		}).Error("Could not encode response body!")
 		http.Error(w, "Failed to encode response", 500)
 		return
 	}
-
 	w.Write(b)
-
 }
 
 // ManualAddHandler - manually add new request/responses, using a form
@@ -520,7 +1448,7 @@ func (d *Hoverfly) ManualAddHandler(w http.ResponseWriter, req *http.Request, ne
 
 // DeleteAllRecordsHandler - deletes all captured requests
 func (d *Hoverfly) DeleteAllRecordsHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
-	err := d.RequestCache.DeleteData()
+	err := d.deleteAllRecords()
 
 	var en Entry
 	en.ActionType = ActionTypeWipeDB
@@ -535,6 +1463,8 @@ func (d *Hoverfly) DeleteAllRecordsHandler(w http.ResponseWriter, req *http.Requ
 		}).Error("failed to fire hook")
 	}
 
+	d.recordAdminEvent(req, ActionTypeWipeDB)
+
 	w.Header().Set("Content-Type", "application/json")
 
 	var response messageResponse
@@ -544,7 +1474,7 @@ func (d *Hoverfly) DeleteAllRecordsHandler(w http.ResponseWriter, req *http.Requ
 			w.WriteHeader(200)
 		} else {
 			response.Message = fmt.Sprintf("Something went wrong: %s", err.Error())
-			w.WriteHeader(500)
+			w.WriteHeader(http.StatusServiceUnavailable)
 		}
 	} else {
 		response.Message = "Proxy cache deleted successfuly"
@@ -568,13 +1498,261 @@ func (d *Hoverfly) DeleteAllRecordsHandler(w http.ResponseWriter, req *http.Requ
 func (d *Hoverfly) CurrentStateHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
 	var resp stateRequest
 	resp.Mode = d.Cfg.GetMode()
-	resp.Destination = d.Cfg.Destination
+	resp.Destination = strings.Join(d.Cfg.Destinations, "|")
+	resp.Destinations = d.Cfg.Destinations
+	recordOnce := d.Cfg.GetRecordOnce()
+	resp.RecordOnce = &recordOnce
 
 	b, _ := json.Marshal(resp)
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.Write(b)
 }
 
+// healthResponse - returned by 'GET /api/health' when Hoverfly can serve requests
+type healthResponse struct {
+	Status    string `json:"status"`
+	Mode      string `json:"mode"`
+	Version   string `json:"version"`
+	CacheSize int    `json:"cacheSize"`
+}
+
+// unhealthyResponse - returned by 'GET /api/health' when request cache can't be used
+type unhealthyResponse struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// HealthHandler - liveness and readiness probe, responds with 503 when request cache is unreachable
+func (d *Hoverfly) HealthHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	count, err := d.RequestCache.Count()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("health check failed, request cache is unreachable")
+
+		b, _ := json.Marshal(unhealthyResponse{Status: "unhealthy", Reason: fmt.Sprintf("request cache is unreachable: %s", err.Error())})
+		setErrorCode(w, ErrorCodeServiceUnavailable)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write(b)
+		return
+	}
+
+	b, _ := json.Marshal(healthResponse{
+		Status:    "healthy",
+		Mode:      d.Cfg.GetMode(),
+		Version:   Version,
+		CacheSize: count,
+	})
+	w.Write(b)
+}
+
+// configDiffResponse - configuration changes since startup
+type configDiffResponse struct {
+	Changes []ConfigChange `json:"changes"`
+}
+
+// ConfigDiffHandler - returns configuration fields that were changed since startup together with their startup
+// and current values
+func (d *Hoverfly) ConfigDiffHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	if d.startupConfig == nil {
+		http.Error(w, "startup configuration was not recorded", http.StatusInternalServerError)
+		return
+	}
+
+	changes, err := d.ConfigDiff()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get configuration diff")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b, err := json.Marshal(configDiffResponse{Changes: changes})
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// diffRecords - differences found in diff mode
+type diffRecords struct {
+	Data []DiffRecord `json:"data"`
+}
+
+// AllDiffsHandler - returns differences between simulated and live responses found in diff mode
+func (d *Hoverfly) AllDiffsHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	records, err := d.Diffs.GetAll()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get diffs")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b, err := json.Marshal(diffRecords{Data: records})
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+// DeleteSequencesHandler - resets response sequences, next request gets the first response of its sequence and
+// weighted responses are selected from the start of the simulation seed again
+func (d *Hoverfly) DeleteSequencesHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	w.Header().Set("Content-Type", "application/json")
+
+	d.Sequences.Reset()
+	d.Weights.Reset()
+
+	var response messageResponse
+	response.Message = "Sequences reset successfuly"
+	b, err := response.Encode()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	w.Write(b)
+}
+
+// DeleteDiffsHandler - removes all diff records
+func (d *Hoverfly) DeleteDiffsHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var response messageResponse
+	// bolt cache has no bucket until first diff is stored
+	if err := d.Diffs.Delete(); err != nil && err.Error() != "bucket not found" {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to delete diffs")
+		response.Message = fmt.Sprintf("Something went wrong: %s", err.Error())
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		response.Message = "Diffs deleted successfuly"
+	}
+
+	b, err := response.Encode()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	w.Write(b)
+}
+
+// totpEnrollRequest - user to enroll for TOTP authentication
+type totpEnrollRequest struct {
+	Username string `json:"username"`
+}
+
+// totpEnrollResponse - generated secret and otpauth URI for TOTP app configuration (i.e. as QR code)
+type totpEnrollResponse struct {
+	Username string `json:"username"`
+	Secret   string `json:"secret"`
+	URI      string `json:"uri"`
+}
+
+// TOTPEnrollHandler - generates TOTP secret for user, existing secret is replaced. Users can replace their own
+// secret, admins can enroll anyone. Without authentication only the first user can be enrolled.
+func (d *Hoverfly) TOTPEnrollHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	var response messageResponse
+
+	totp, ok := d.Authentication.(*backends.TOTPAuthentication)
+	if !ok {
+		response.Message = "TOTP authentication is not enabled"
+		w.WriteHeader(http.StatusBadRequest)
+		b, _ := response.Encode()
+		w.Write(b)
+		return
+	}
+
+	var er totpEnrollRequest
+	defer req.Body.Close()
+	if err := json.NewDecoder(req.Body).Decode(&er); err != nil || er.Username == "" {
+		response.Message = "username is required"
+		w.WriteHeader(http.StatusBadRequest)
+		b, _ := response.Encode()
+		w.Write(b)
+		return
+	}
+
+	if err := d.canEnrollTOTPUser(req, totp, er.Username); err != nil {
+		log.WithFields(log.Fields{
+			"error":    err.Error(),
+			"username": er.Username,
+		}).Warn("TOTP enrollment refused")
+		response.Message = err.Error()
+		w.WriteHeader(http.StatusForbidden)
+		b, _ := response.Encode()
+		w.Write(b)
+		return
+	}
+
+	secret, uri, err := totp.Enroll(er.Username)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":    err.Error(),
+			"username": er.Username,
+		}).Error("Failed to enroll TOTP user")
+		response.Message = err.Error()
+		w.WriteHeader(http.StatusInternalServerError)
+		b, _ := response.Encode()
+		w.Write(b)
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"username": er.Username,
+	}).Info("User enrolled for TOTP authentication")
+
+	b, err := json.Marshal(totpEnrollResponse{Username: er.Username, Secret: secret, URI: uri})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Write(b)
+}
+
+// canEnrollTOTPUser - checks whether user that made the request can enroll given user
+func (d *Hoverfly) canEnrollTOTPUser(req *http.Request, totp *backends.TOTPAuthentication, username string) error {
+	if !d.Cfg.AuthEnabled {
+		users, err := totp.GetAllUsers()
+		if err != nil {
+			return err
+		}
+		if len(users) > 0 {
+			return fmt.Errorf("authentication is disabled, only the first user can be enrolled")
+		}
+		return nil
+	}
+
+	requester, err := authentication.TokenUsername(req, d.Cfg.SecretKey)
+	if err != nil {
+		return fmt.Errorf("failed to get user from token: %s", err.Error())
+	}
+	if requester == username {
+		return nil
+	}
+	user, err := totp.GetUser(requester)
+	if err != nil || user == nil || !user.IsAdmin {
+		return fmt.Errorf("only admins can enroll other users")
+	}
+	return nil
+}
+
 // StateHandler handles current proxy state
 func (d *Hoverfly) StateHandler(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	var sr stateRequest
@@ -604,19 +1782,12 @@ func (d *Hoverfly) StateHandler(w http.ResponseWriter, r *http.Request, next htt
 		return
 	}
 
-	availableModes := map[string]bool{
-		"simulate": true,
-		"capture":    true,
-		"modify":     true,
-		"synthesize": true,
-	}
-
 	if sr.Mode != "" {
-		if !availableModes[sr.Mode] {
+		if err := d.SetMode(sr.Mode); err != nil {
 			log.WithFields(log.Fields{
 				"suppliedMode": sr.Mode,
 			}).Error("Wrong mode found, can't change state")
-			http.Error(w, "Bad mode supplied, available modes: simulate, capture, modify, synthesize.", 400)
+			http.Error(w, err.Error(), 400)
 			return
 		}
 		log.WithFields(log.Fields{
@@ -624,14 +1795,23 @@ func (d *Hoverfly) StateHandler(w http.ResponseWriter, r *http.Request, next htt
 			"body":        string(body),
 			"destination": sr.Destination,
 		}).Info("Handling state change request!")
+	}
 
-		// setting new state
-		d.Cfg.SetMode(sr.Mode)
-
+	if sr.RecordOnce != nil {
+		d.Cfg.SetRecordOnce(*sr.RecordOnce)
+		log.WithFields(log.Fields{
+			"recordOnce": *sr.RecordOnce,
+		}).Info("Recording of missing requests in simulate mode changed")
 	}
 
 	// checking whether we should update destination
-	if sr.Destination != "" {
+	if len(sr.Destinations) > 0 {
+		err := d.UpdateDestinations(sr.Destinations)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error while updating destinations: %s", err.Error()), 500)
+			return
+		}
+	} else if sr.Destination != "" {
 		err := d.UpdateDestination(sr.Destination)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error while updating destination: %s", err.Error()), 500)
@@ -653,22 +1833,77 @@ func (d *Hoverfly) StateHandler(w http.ResponseWriter, r *http.Request, next htt
 		}).Error("failed to fire hook")
 	}
 
+	d.recordAdminEvent(r, ActionTypeConfigurationChanged)
+
 	var resp stateRequest
 	resp.Mode = d.Cfg.GetMode()
-	resp.Destination = d.Cfg.Destination
+	resp.Destination = strings.Join(d.Cfg.Destinations, "|")
+	resp.Destinations = d.Cfg.Destinations
+	recordOnce := d.Cfg.GetRecordOnce()
+	resp.RecordOnce = &recordOnce
 	b, _ := json.Marshal(resp)
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.Write(b)
 
 }
 
+// TimelineHandler returns admin and proxy events, optionally limited by RFC3339 'start' and 'end' query parameters
+func (d *Hoverfly) TimelineHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	var start, end time.Time
+	var err error
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	if s := req.URL.Query().Get("start"); s != "" {
+		start, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad start time supplied, expected RFC3339 format: %s", err.Error()), 400)
+			return
+		}
+	}
+
+	if e := req.URL.Query().Get("end"); e != "" {
+		end, err = time.Parse(time.RFC3339, e)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Bad end time supplied, expected RFC3339 format: %s", err.Error()), 400)
+			return
+		}
+	}
+
+	events, err := d.GetTimeline(start, end)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Error": err.Error(),
+		}).Warn("Failed to get timeline!")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var response timelineResponse
+	response.Data = events
+	b, err := json.Marshal(response)
+
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(b)
+}
+
 // AllMetadataHandler returns JSON content type http response
 func (d *Hoverfly) AllMetadataHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	// record access times are written in batches, exported metadata includes the latest ones
+	d.recentAccess.flush(d)
 	entries, err := d.MetadataCache.GetAllEntries()
 
 	metaData := make(map[string]string)
 
 	for k, v := range entries {
+		// TOTP secrets and private keys of WebAuthn credentials must not be readable through admin API
+		if strings.HasPrefix(k, backends.TOTPSecretKeyPrefix) || strings.HasPrefix(k, webauthnCredentialKeyPrefix) {
+			continue
+		}
 		metaData[k] = string(v)
 	}
 
@@ -690,10 +1925,10 @@ func (d *Hoverfly) AllMetadataHandler(w http.ResponseWriter, req *http.Request,
 	} else {
 		log.WithFields(log.Fields{
 			"Error": err.Error(),
-		}).Error("Failed to get metadata!")
+		}).Warn("Failed to get metadata!")
 
 		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-		w.WriteHeader(500)
+		w.WriteHeader(http.StatusServiceUnavailable)
 		return
 	}
 }
@@ -746,11 +1981,25 @@ func (d *Hoverfly) SetMetadataHandler(w http.ResponseWriter, req *http.Request,
 		mr.Message = "Key not provided."
 		w.WriteHeader(400)
 
+	} else if strings.HasPrefix(sm.Key, backends.TOTPSecretKeyPrefix) {
+		// TOTP secrets are set by enrolling users
+		mr.Message = "TOTP secrets can't be set through metadata API."
+		w.WriteHeader(http.StatusForbidden)
+
+	} else if strings.HasPrefix(sm.Key, webauthnCredentialKeyPrefix) {
+		// WebAuthn credentials are registered with RegisterWebAuthnCredential
+		mr.Message = "WebAuthn credentials can't be set through metadata API."
+		w.WriteHeader(http.StatusForbidden)
+
 	} else {
 		err = d.MetadataCache.Set([]byte(sm.Key), []byte(sm.Value))
 		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"key":   sm.Key,
+			}).Warn("Failed to set metadata!")
 			mr.Message = fmt.Sprintf("Failed to set metadata. Error: %s", err.Error())
-			w.WriteHeader(500)
+			w.WriteHeader(http.StatusServiceUnavailable)
 		} else {
 			mr.Message = "Metadata set."
 			w.WriteHeader(201)
@@ -770,7 +2019,17 @@ func (d *Hoverfly) SetMetadataHandler(w http.ResponseWriter, req *http.Request,
 
 // DeleteMetadataHandler - deletes all metadata
 func (d *Hoverfly) DeleteMetadataHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
-	err := d.MetadataCache.DeleteData()
+	// deduplicated bodies are still referenced by stored payloads, TOTP secrets are needed to log in and WebAuthn
+	// credentials are configuration rather than metadata
+	keep := []string{webauthnCredentialKeyPrefix}
+	if _, dedup := d.RequestCache.(*DedupCache); dedup {
+		keep = append(keep, bodyKeyPrefix)
+	}
+	if _, totp := d.Authentication.(*backends.TOTPAuthentication); totp {
+		keep = append(keep, backends.TOTPSecretKeyPrefix)
+	}
+
+	err := deleteMetadataExcept(d.MetadataCache, keep...)
 
 	w.Header().Set("Content-Type", "application/json")
 
@@ -781,7 +2040,7 @@ func (d *Hoverfly) DeleteMetadataHandler(w http.ResponseWriter, req *http.Reques
 			w.WriteHeader(200)
 		} else {
 			response.Message = fmt.Sprintf("Something went wrong: %s", err.Error())
-			w.WriteHeader(500)
+			w.WriteHeader(http.StatusServiceUnavailable)
 		}
 	} else {
 		response.Message = "Metadata deleted successfuly"
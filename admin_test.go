@@ -4,11 +4,20 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"github.com/SpectoLabs/hoverfly/authentication/backends"
+	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/metrics"
 	"github.com/SpectoLabs/hoverfly/testutil"
+	jwt "github.com/dgrijalva/jwt-go"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetAllRecords(t *testing.T) {
@@ -264,6 +273,40 @@ func TestSetSimulateState(t *testing.T) {
 	testutil.Expect(t, dbClient.Cfg.GetMode(), SimulateMode)
 }
 
+func TestSetRecordOnceState(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	dbClient.Cfg.SetMode(SimulateMode)
+
+	req, err := http.NewRequest("POST", "/api/state", ioutil.NopCloser(bytes.NewBufferString(`{"recordOnce": true}`)))
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	// mode is kept when only recordOnce is supplied
+	testutil.Expect(t, dbClient.Cfg.GetMode(), SimulateMode)
+	testutil.Expect(t, dbClient.Cfg.GetRecordOnce(), true)
+
+	sr := stateRequest{}
+	err = json.Unmarshal(rec.Body.Bytes(), &sr)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, sr.RecordOnce != nil, true)
+	testutil.Expect(t, *sr.RecordOnce, true)
+
+	req, err = http.NewRequest("POST", "/api/state", ioutil.NopCloser(bytes.NewBufferString(`{"recordOnce": false}`)))
+	testutil.Expect(t, err, nil)
+	rec = httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+	testutil.Expect(t, dbClient.Cfg.GetRecordOnce(), false)
+}
+
 func TestSetCaptureState(t *testing.T) {
 	server, dbClient := testTools(200, `{'message': 'here'}`)
 	defer server.Close()
@@ -419,6 +462,32 @@ func TestStatsHandler(t *testing.T) {
 	testutil.Expect(t, rec.Code, http.StatusOK)
 }
 
+func TestPrometheusMetricsHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	// request is not recorded, cache miss is counted
+	dbClient.Cfg.SetMode(SimulateMode)
+	simReq, err := http.NewRequest("GET", "http://metrics.example.com/", nil)
+	testutil.Expect(t, err, nil)
+	dbClient.processRequest(simReq)
+
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+	testutil.Expect(t, rec.Header().Get("Content-Type"), metrics.PrometheusContentType)
+
+	body, err := ioutil.ReadAll(rec.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, strings.Contains(string(body), `hoverfly_request_duration_seconds_count{mode="simulate"}`), true)
+	testutil.Expect(t, strings.Contains(string(body), "hoverfly_cache_misses_total"), true)
+}
+
 func TestStatsHandlerSimulateMetrics(t *testing.T) {
 	// test metrics, increases simulate count by 1 and then checks through stats
 	// handler whether it is visible through /stats handler
@@ -559,6 +628,35 @@ func TestStatsHandlerRecordCountMetrics(t *testing.T) {
 	testutil.Expect(t, int(sr.RecordsCount), 5)
 }
 
+func TestCacheStatsHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest("GET", fmt.Sprintf("http://example.com/q=%d", i), nil)
+		testutil.Expect(t, err, nil)
+		dbClient.captureRequest(req)
+	}
+	dbClient.MetadataCache.Set([]byte("foo"), []byte("bar"))
+
+	req, err := http.NewRequest("GET", "/api/stats/cache", nil)
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	sr := cacheStatsResponse{}
+	err = json.Unmarshal(rec.Body.Bytes(), &sr)
+	testutil.Expect(t, err, nil)
+
+	testutil.Expect(t, sr.RecordsCount, 3)
+	testutil.Expect(t, sr.MetadataCount, 1)
+	testutil.Expect(t, len(sr.Keys), 3)
+}
+
 func TestSetMetadata(t *testing.T) {
 	server, dbClient := testTools(200, `{'message': 'here'}`)
 	defer server.Close()
@@ -635,6 +733,62 @@ func TestSetMetadataMissingKey(t *testing.T) {
 	testutil.Expect(t, mr.Message, "Key not provided.")
 }
 
+func TestSetMetadataTOTPSecret(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("PUT", "/api/metadata", bytes.NewBufferString(`{"key": "totp_hfadmin", "value": "secret"}`))
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusForbidden)
+
+	value, _ := dbClient.MetadataCache.Get([]byte("totp_hfadmin"))
+	testutil.Expect(t, len(value), 0)
+}
+
+func TestWebAuthnCredentialsAreProtectedInMetadataAPI(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	credential, _ := webauthnTestCredential(t)
+	testutil.Expect(t, dbClient.RegisterWebAuthnCredential(credential), nil)
+	testutil.Expect(t, dbClient.MetadataCache.Set([]byte("key"), []byte("value")), nil)
+	credentialKey := webauthnCredentialKeyPrefix + credential.ID
+
+	req, err := http.NewRequest("GET", "/api/metadata", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+	sm := storedMetadata{}
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &sm), nil)
+	testutil.Expect(t, len(sm.Data), 1)
+	testutil.Expect(t, sm.Data["key"], "value")
+
+	req, err = http.NewRequest("PUT", "/api/metadata", bytes.NewBufferString(`{"key": "`+credentialKey+`", "value": "{}"}`))
+	testutil.Expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusForbidden)
+
+	req, err = http.NewRequest("DELETE", "/api/metadata", nil)
+	testutil.Expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	value, _ := dbClient.MetadataCache.Get([]byte("key"))
+	testutil.Expect(t, len(value), 0)
+	stored, err := dbClient.webauthnCredential(credential.ID)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, stored.PrivateKey, credential.PrivateKey)
+}
+
 func TestGetMetadata(t *testing.T) {
 	server, dbClient := testTools(200, `{'message': 'here'}`)
 	defer server.Close()
@@ -723,3 +877,582 @@ func TestDeleteMetadataEmpty(t *testing.T) {
 	testutil.Expect(t, err, nil)
 	testutil.Expect(t, len(allMeta), 0)
 }
+
+func TestTimelineHandlerAdminEvent(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	// changing state, this should be visible in the timeline
+	var resp stateRequest
+	resp.Mode = "capture"
+	bts, err := json.Marshal(&resp)
+	testutil.Expect(t, err, nil)
+
+	req, err := http.NewRequest("POST", "/api/state", ioutil.NopCloser(bytes.NewBuffer(bts)))
+	testutil.Expect(t, err, nil)
+	m.ServeHTTP(httptest.NewRecorder(), req)
+
+	req, err = http.NewRequest("GET", "/api/timeline", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	body, err := ioutil.ReadAll(rec.Body)
+
+	tr := timelineResponse{}
+	err = json.Unmarshal(body, &tr)
+	testutil.Expect(t, err, nil)
+
+	testutil.Expect(t, len(tr.Data), 1)
+	testutil.Expect(t, tr.Data[0].Type, TimelineEventAdmin)
+	testutil.Expect(t, tr.Data[0].Action, ActionTypeConfigurationChanged)
+}
+
+func TestTimelineHandlerBadStart(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "/api/timeline?start=yesterday", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestRecordBodyHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "http://example.com/image", nil)
+	testutil.Expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	// getting ids of stored bodies
+	listReq, err := http.NewRequest("GET", "/api/records/bodies", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, listReq)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	rb := recordBodies{}
+	err = json.Unmarshal(rec.Body.Bytes(), &rb)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(rb.Data), 1)
+	testutil.Expect(t, rb.Data[0].Path, "/image")
+
+	// downloading body
+	bodyReq, err := http.NewRequest("GET", fmt.Sprintf("/api/records/%s/body", rb.Data[0].ID), nil)
+	testutil.Expect(t, err, nil)
+	bodyRec := httptest.NewRecorder()
+
+	m.ServeHTTP(bodyRec, bodyReq)
+	testutil.Expect(t, bodyRec.Code, http.StatusOK)
+	testutil.Expect(t, bodyRec.Body.String(), "{'message': 'here'}\n")
+	testutil.Expect(t, bodyRec.Header().Get("Content-Type"), rb.Data[0].ContentType)
+}
+
+func TestRecordBodyHandlerNotFound(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "/api/records/doesnotexist/body", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusNotFound)
+}
+
+func TestCloneRecordHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "http://example.com/v1/user/1", nil)
+	testutil.Expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	keys, err := dbClient.RequestCache.Keys()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(keys), 1)
+
+	cloneReq, err := http.NewRequest("POST", fmt.Sprintf("/api/records/%s/clone", keys[0]), bytes.NewBufferString(`{"newPath": "/v2/user/1"}`))
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, cloneReq)
+	testutil.Expect(t, rec.Code, http.StatusCreated)
+
+	var cr cloneRecordResponse
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &cr), nil)
+	testutil.Refute(t, cr.ID, keys[0])
+
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 2)
+
+	// cloned response is served for the new path
+	dbClient.Cfg.SetMode(SimulateMode)
+	simReq, err := http.NewRequest("GET", "http://example.com/v2/user/1", nil)
+	testutil.Expect(t, err, nil)
+
+	resp := dbClient.getResponse(simReq)
+	testutil.Expect(t, resp.StatusCode, 200)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "{'message': 'here'}\n")
+
+	// cloning again conflicts with the existing clone
+	cloneReq, err = http.NewRequest("POST", fmt.Sprintf("/api/records/%s/clone", keys[0]), bytes.NewBufferString(`{"newPath": "/v2/user/1"}`))
+	testutil.Expect(t, err, nil)
+	rec = httptest.NewRecorder()
+
+	m.ServeHTTP(rec, cloneReq)
+	testutil.Expect(t, rec.Code, http.StatusConflict)
+}
+
+func TestCloneRecordHandlerNotFound(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("POST", "/api/records/doesnotexist/clone", bytes.NewBufferString(`{"newPath": "/v2"}`))
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusNotFound)
+}
+
+func TestImportURLHandler(t *testing.T) {
+	payloadsFile, err := os.Open("examples/exports/readthedocs.json")
+	testutil.Expect(t, err, nil)
+	bts, err := ioutil.ReadAll(payloadsFile)
+	testutil.Expect(t, err, nil)
+
+	// requests to any URL are intercepted by test server
+	server, dbClient := testTools(200, string(bts))
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("POST", "/api/simulation/import-url", ioutil.NopCloser(bytes.NewBuffer([]byte(`{"url": "http://example.com/sim.json"}`))))
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var response importURLResponse
+	err = json.Unmarshal(rec.Body.Bytes(), &response)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, response.Count, 5)
+
+	recordsCount, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, recordsCount, 5)
+}
+
+func TestImportURLHandlerWrongScheme(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	for _, body := range []string{`{"url": "file:///etc/passwd"}`, `{"url": "ftp://example.com/sim.json"}`, `{}`, `not json`} {
+		req, err := http.NewRequest("POST", "/api/simulation/import-url", ioutil.NopCloser(bytes.NewBuffer([]byte(body))))
+		testutil.Expect(t, err, nil)
+
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		testutil.Expect(t, rec.Code, http.StatusBadRequest)
+	}
+
+	recordsCount, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, recordsCount, 0)
+}
+
+func middlewareUploadRequest(t *testing.T, content []byte) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	part, err := writer.CreateFormFile("file", "middleware.py")
+	testutil.Expect(t, err, nil)
+	part.Write(content)
+	testutil.Expect(t, writer.Close(), nil)
+
+	req, err := http.NewRequest("POST", "/api/middleware/upload", &body)
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestMiddlewareUploadHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	dir, err := ioutil.TempDir("", "middleware")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	dbClient.Cfg.MiddlewareUploadDir = dir
+	dbClient.Cfg.AllowMiddlewareUpload = true
+
+	content := []byte("#!/usr/bin/env python\nimport sys\nprint(sys.stdin.read())\n")
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, middlewareUploadRequest(t, content))
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var response middlewareResponse
+	err = json.Unmarshal(rec.Body.Bytes(), &response)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, filepath.Dir(response.Middleware), dir)
+	testutil.Expect(t, dbClient.Cfg.GetMiddleware(), response.Middleware)
+
+	info, err := os.Stat(response.Middleware)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, info.Mode().Perm(), os.FileMode(0755))
+
+	stored, err := ioutil.ReadFile(response.Middleware)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(stored), string(content))
+}
+
+func TestMiddlewareUploadHandlerTooLarge(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	dir, err := ioutil.TempDir("", "middleware")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	dbClient.Cfg.MiddlewareUploadDir = dir
+	dbClient.Cfg.AllowMiddlewareUpload = true
+	dbClient.Cfg.MaxMiddlewareSizeBytes = 10
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, middlewareUploadRequest(t, []byte("more than ten bytes")))
+	testutil.Expect(t, rec.Code, http.StatusRequestEntityTooLarge)
+	testutil.Expect(t, dbClient.Cfg.GetMiddleware(), "")
+
+	files, err := ioutil.ReadDir(dir)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(files), 0)
+}
+
+func TestMiddlewareUploadHandlerNoFile(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.AllowMiddlewareUpload = true
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("POST", "/api/middleware/upload", bytes.NewBufferString("not multipart"))
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestMiddlewareUploadHandlerNotAllowed(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	dir, err := ioutil.TempDir("", "middleware")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	dbClient.Cfg.MiddlewareUploadDir = dir
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, middlewareUploadRequest(t, []byte("#!/bin/sh\ncat\n")))
+	testutil.Expect(t, rec.Code, http.StatusForbidden)
+	testutil.Expect(t, dbClient.Cfg.GetMiddleware(), "")
+
+	files, err := ioutil.ReadDir(dir)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(files), 0)
+}
+
+func TestConfigDiffHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	testutil.Expect(t, dbClient.recordStartupConfig(), nil)
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("POST", "/api/state", ioutil.NopCloser(bytes.NewBufferString(`{"mode": "capture"}`)))
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	req, err = http.NewRequest("GET", "/api/config/diff", nil)
+	testutil.Expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var cr configDiffResponse
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &cr), nil)
+	testutil.Expect(t, len(cr.Changes), 1)
+	testutil.Expect(t, cr.Changes[0].Field, "Mode")
+	testutil.Expect(t, cr.Changes[0].Startup, SimulateMode)
+	testutil.Expect(t, cr.Changes[0].Current, CaptureMode)
+}
+
+func TestConfigDiffHandlerWithoutStartupConfig(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "/api/config/diff", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusInternalServerError)
+}
+
+func TestTOTPEnrollHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	totp := backends.NewTOTPAuthentication(dbClient.Authentication, dbClient.MetadataCache)
+	dbClient.Authentication = totp
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("POST", "/api/auth/totp/enroll", bytes.NewBufferString(`{"username": "hfadmin"}`))
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusCreated)
+
+	var er totpEnrollResponse
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &er), nil)
+	testutil.Expect(t, er.Username, "hfadmin")
+	testutil.Expect(t, strings.HasPrefix(er.URI, "otpauth://totp/"), true)
+
+	code, err := backends.GenerateTOTPCode(er.Secret, time.Now())
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, totp.ValidateCode("hfadmin", code), true)
+
+	// secrets are not exposed through metadata API
+	req, err = http.NewRequest("GET", "/api/metadata", nil)
+	testutil.Expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+	testutil.Expect(t, strings.Contains(rec.Body.String(), er.Secret), false)
+}
+
+func TestTOTPEnrollHandlerWithoutAuthenticationOnlyFirstUser(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+	totp := backends.NewTOTPAuthentication(dbClient.Authentication, dbClient.MetadataCache)
+	dbClient.Authentication = totp
+	m := getBoneRouter(*dbClient)
+
+	for _, enroll := range []struct {
+		username string
+		code     int
+	}{{"hfadmin", http.StatusCreated}, {"other", http.StatusForbidden}} {
+		req, err := http.NewRequest("POST", "/api/auth/totp/enroll", bytes.NewBufferString(`{"username": "`+enroll.username+`"}`))
+		testutil.Expect(t, err, nil)
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		testutil.Expect(t, rec.Code, enroll.code)
+	}
+
+	users, err := totp.GetAllUsers()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(users), 1)
+	testutil.Expect(t, users[0].Username, "hfadmin")
+}
+
+func TestCanEnrollTOTPUser(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+	users := backends.NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache())
+	testutil.Expect(t, users.AddUser("hfadmin", "password", true), nil)
+	testutil.Expect(t, users.AddUser("dave", "password", false), nil)
+	totp := backends.NewTOTPAuthentication(users, dbClient.MetadataCache)
+	dbClient.Authentication = totp
+	dbClient.Cfg.AuthEnabled = true
+
+	for _, username := range []string{"hfadmin", "dave", "eve"} {
+		_, _, err := totp.Enroll(username)
+		testutil.Expect(t, err, nil)
+	}
+
+	request := func(username string) *http.Request {
+		req, err := http.NewRequest("POST", "/api/auth/totp/enroll", nil)
+		testutil.Expect(t, err, nil)
+		if username != "" {
+			token := jwt.New(jwt.SigningMethodHS512)
+			token.Claims["username"] = username
+			signed, err := token.SignedString(dbClient.Cfg.SecretKey)
+			testutil.Expect(t, err, nil)
+			req.Header.Set("Authorization", "Bearer "+signed)
+		}
+		return req
+	}
+
+	testutil.Expect(t, dbClient.canEnrollTOTPUser(request("hfadmin"), totp, "bob"), nil)
+	testutil.Expect(t, dbClient.canEnrollTOTPUser(request("bob"), totp, "bob"), nil)
+	testutil.Refute(t, dbClient.canEnrollTOTPUser(request("bob"), totp, "carol"), nil)
+	// enrolled users are only admins when they are admins in wrapped backend
+	testutil.Refute(t, dbClient.canEnrollTOTPUser(request("dave"), totp, "hfadmin"), nil)
+	testutil.Refute(t, dbClient.canEnrollTOTPUser(request("eve"), totp, "hfadmin"), nil)
+	testutil.Refute(t, dbClient.canEnrollTOTPUser(request(""), totp, "bob"), nil)
+}
+
+func TestTOTPEnrollHandlerWithoutTOTPAuthentication(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("POST", "/api/auth/totp/enroll", bytes.NewBufferString(`{"username": "hfadmin"}`))
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestAllDiffsHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	record := DiffRecord{
+		Method:      "GET",
+		Destination: "example.com",
+		Path:        "/items",
+		Time:        time.Now(),
+		Differences: []Difference{{Field: "status", Simulated: "200", Live: "500"}},
+	}
+	testutil.Expect(t, dbClient.Diffs.Add(record), nil)
+
+	req, err := http.NewRequest("GET", "/api/diffs", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var dr diffRecords
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &dr), nil)
+	testutil.Expect(t, len(dr.Data), 1)
+	testutil.Expect(t, dr.Data[0].Differences[0].Live, "500")
+
+	req, err = http.NewRequest("DELETE", "/api/diffs", nil)
+	testutil.Expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	records, err := dbClient.Diffs.GetAll()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(records), 0)
+}
+
+func TestSimulationDiffHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, content := range map[string]string{"baseline": baselineSimulation, "current": currentSimulation} {
+		part, err := writer.CreateFormFile(name, name+".json")
+		testutil.Expect(t, err, nil)
+		part.Write([]byte(content))
+	}
+	testutil.Expect(t, writer.Close(), nil)
+
+	req, err := http.NewRequest("POST", "/api/simulation/diff", &body)
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var diff SimulationDiff
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &diff), nil)
+	testutil.Expect(t, len(diff.Added), 1)
+	testutil.Expect(t, len(diff.Removed), 1)
+	testutil.Expect(t, len(diff.Modified), 1)
+
+	// nothing was imported
+	recordsCount, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, recordsCount, 0)
+}
+
+func TestSimulationDiffHandlerMissingPart(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("baseline", "baseline.json")
+	testutil.Expect(t, err, nil)
+	part.Write([]byte(baselineSimulation))
+	testutil.Expect(t, writer.Close(), nil)
+
+	req, err := http.NewRequest("POST", "/api/simulation/diff", &body)
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestStatsResetHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	dbClient.Counter.Count(SimulateMode)
+	dbClient.Counter.Count(SimulateMode)
+	dbClient.Counter.Count(CaptureMode)
+
+	req, err := http.NewRequest("POST", "/api/stats/reset", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var reset statsResetResponse
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &reset), nil)
+	testutil.Expect(t, reset.Counters[SimulateMode], int64(2))
+	testutil.Expect(t, reset.Counters[CaptureMode], int64(1))
+
+	req, err = http.NewRequest("GET", "/api/stats", nil)
+	testutil.Expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+
+	var sr statsResponse
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &sr), nil)
+	testutil.Expect(t, sr.Stats.Counters[SimulateMode], int64(0))
+	testutil.Expect(t, sr.Stats.Counters[CaptureMode], int64(0))
+}
@@ -0,0 +1,76 @@
+package hoverfly
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/certs"
+)
+
+// DefaultAdminTLSCertFile - default location of admin server certificate
+const DefaultAdminTLSCertFile = "admin-cert.pem"
+
+// DefaultAdminTLSKeyFile - default location of admin server private key
+const DefaultAdminTLSKeyFile = "admin-key.pem"
+
+// adminCertValidity - how long generated admin certificates are valid
+const adminCertValidity = 365 * 24 * time.Hour
+
+// EnsureAdminTLSCertificate - generates self-signed admin certificate and key when neither of the configured files
+// exists, existing files are never overwritten
+func (c *Configuration) EnsureAdminTLSCertificate() error {
+	certExists, err := fileExists(c.AdminTLSCertFile)
+	if err != nil {
+		return err
+	}
+	keyExists, err := fileExists(c.AdminTLSKeyFile)
+	if err != nil {
+		return err
+	}
+
+	if certExists && keyExists {
+		return nil
+	}
+	if certExists != keyExists {
+		return fmt.Errorf("admin TLS certificate '%s' and key '%s' must either both exist or both be missing", c.AdminTLSCertFile, c.AdminTLSKeyFile)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if hostname != "localhost" {
+		hosts = append(hosts, hostname)
+	}
+
+	cert, priv, err := certs.NewSelfSignedCertificate(hosts, "Hoverfly Admin", adminCertValidity)
+	if err != nil {
+		return err
+	}
+
+	if err := certs.SaveCertificatePair(cert, priv, c.AdminTLSCertFile, c.AdminTLSKeyFile); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"cert":  c.AdminTLSCertFile,
+		"key":   c.AdminTLSKeyFile,
+		"hosts": hosts,
+	}).Warn("Generated self-signed admin certificate")
+
+	return nil
+}
+
+func fileExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
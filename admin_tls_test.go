@@ -0,0 +1,49 @@
+package hoverfly
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestEnsureAdminTLSCertificateGeneratesMissingFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hoverfly_admin_tls")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	cfg := InitSettings()
+	cfg.AdminTLSCertFile = filepath.Join(dir, "cert.pem")
+	cfg.AdminTLSKeyFile = filepath.Join(dir, "key.pem")
+
+	testutil.Expect(t, cfg.EnsureAdminTLSCertificate(), nil)
+
+	pair, err := tls.LoadX509KeyPair(cfg.AdminTLSCertFile, cfg.AdminTLSKeyFile)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(pair.Certificate), 1)
+
+	// existing files are kept
+	before, err := ioutil.ReadFile(cfg.AdminTLSCertFile)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, cfg.EnsureAdminTLSCertificate(), nil)
+	after, err := ioutil.ReadFile(cfg.AdminTLSCertFile)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(after), string(before))
+}
+
+func TestEnsureAdminTLSCertificateMissingKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hoverfly_admin_tls")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	cfg := InitSettings()
+	cfg.AdminTLSCertFile = filepath.Join(dir, "cert.pem")
+	cfg.AdminTLSKeyFile = filepath.Join(dir, "key.pem")
+
+	testutil.Expect(t, ioutil.WriteFile(cfg.AdminTLSCertFile, []byte("cert"), 0644), nil)
+
+	testutil.Refute(t, cfg.EnsureAdminTLSCertificate(), nil)
+}
@@ -2,6 +2,7 @@ package authentication
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/SpectoLabs/hoverfly/authentication/backends"
 	jwt "github.com/dgrijalva/jwt-go"
 	"net/http"
@@ -51,3 +52,21 @@ func Logout(req *http.Request, ab backends.Authentication, secret []byte, exp in
 	tokenString := req.Header.Get("Authorization")
 	return authBackend.Logout(tokenString, tokenRequest)
 }
+
+// TokenUsername - returns name of the user that Hoverfly token in request was issued to
+func TokenUsername(req *http.Request, secret []byte) (string, error) {
+	token, err := jwt.ParseFromRequest(req, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return secret, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	username, ok := token.Claims["username"].(string)
+	if !ok || username == "" {
+		return "", fmt.Errorf("token has no username")
+	}
+	return username, nil
+}
@@ -0,0 +1,79 @@
+package backends
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// AuthTypeJWT - auth type of JWTBackend, tokens are issued by external identity provider
+const AuthTypeJWT = "jwt"
+
+// TokenAuthentication - authentication backend that validates bearer tokens itself instead of Hoverfly issued
+// tokens
+type TokenAuthentication interface {
+	ValidateToken(req *http.Request) bool
+}
+
+// JWTBackend - authentication backend that validates Bearer token in Authorization header against HMAC-SHA256
+// secret or RSA public key. Users and blacklisted tokens are handled by wrapped backend.
+type JWTBackend struct {
+	Authentication
+
+	hmacKey []byte
+	rsaKey  interface{}
+}
+
+// NewJWTBackend - wraps authentication backend, key is either PEM encoded RSA public key or HMAC-SHA256 secret
+func NewJWTBackend(ab Authentication, key []byte) (*JWTBackend, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("JWT verification key is required")
+	}
+
+	if bytes.Contains(key, []byte("-----BEGIN")) {
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSA public key: %s", err.Error())
+		}
+		return &JWTBackend{Authentication: ab, rsaKey: publicKey}, nil
+	}
+	return &JWTBackend{Authentication: ab, hmacKey: key}, nil
+}
+
+// ValidateToken - checks signature and 'exp' claim of Bearer token, blacklisted tokens are rejected
+func (j *JWTBackend) ValidateToken(req *http.Request) bool {
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+
+	token, err := jwt.Parse(strings.TrimPrefix(header, "Bearer "), j.keyFor)
+	if err != nil || !token.Valid {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Warn("JWT validation failed")
+		return false
+	}
+
+	// caches return error for tokens that were never blacklisted
+	blacklisted, _ := j.IsTokenBlacklisted(header)
+	return !blacklisted
+}
+
+// keyFor - returns key that token signature is verified with, signing method must match configured key
+func (j *JWTBackend) keyFor(token *jwt.Token) (interface{}, error) {
+	if j.rsaKey != nil {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return j.rsaKey, nil
+	}
+	if token.Method != jwt.SigningMethodHS256 {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+	return j.hmacKey, nil
+}
@@ -0,0 +1,85 @@
+package backends
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/testutil"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func newTestJWTBackend(t *testing.T, key []byte) *JWTBackend {
+	j, err := NewJWTBackend(NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache()), key)
+	testutil.Expect(t, err, nil)
+	return j
+}
+
+func bearerRequest(token string) *http.Request {
+	req, _ := http.NewRequest("GET", "http://localhost/api/records", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func signedToken(t *testing.T, method jwt.SigningMethod, key interface{}, exp time.Time) string {
+	token := jwt.New(method)
+	token.Claims["sub"] = "hfadmin"
+	token.Claims["exp"] = exp.Unix()
+	tokenString, err := token.SignedString(key)
+	testutil.Expect(t, err, nil)
+	return tokenString
+}
+
+func TestJWTBackendHMAC(t *testing.T) {
+	j := newTestJWTBackend(t, []byte("verysecret"))
+
+	token := signedToken(t, jwt.SigningMethodHS256, []byte("verysecret"), time.Now().Add(time.Hour))
+	testutil.Expect(t, j.ValidateToken(bearerRequest(token)), true)
+
+	other := signedToken(t, jwt.SigningMethodHS256, []byte("othersecret"), time.Now().Add(time.Hour))
+	testutil.Expect(t, j.ValidateToken(bearerRequest(other)), false)
+}
+
+func TestJWTBackendRejectsOtherSigningMethods(t *testing.T) {
+	j := newTestJWTBackend(t, []byte("verysecret"))
+
+	token := signedToken(t, jwt.SigningMethodHS512, []byte("verysecret"), time.Now().Add(time.Hour))
+	testutil.Expect(t, j.ValidateToken(bearerRequest(token)), false)
+}
+
+func TestJWTBackendRSA(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	testutil.Expect(t, err, nil)
+	der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	testutil.Expect(t, err, nil)
+
+	j := newTestJWTBackend(t, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+
+	token := signedToken(t, jwt.SigningMethodRS256, privateKey, time.Now().Add(time.Hour))
+	testutil.Expect(t, j.ValidateToken(bearerRequest(token)), true)
+
+	// HMAC token signed with public key must not pass
+	hmacToken := signedToken(t, jwt.SigningMethodHS256, der, time.Now().Add(time.Hour))
+	testutil.Expect(t, j.ValidateToken(bearerRequest(hmacToken)), false)
+}
+
+func TestJWTBackendBlacklistedToken(t *testing.T) {
+	j := newTestJWTBackend(t, []byte("verysecret"))
+
+	token := signedToken(t, jwt.SigningMethodHS256, []byte("verysecret"), time.Now().Add(time.Hour))
+	testutil.Expect(t, j.InvalidateToken("Bearer "+token), nil)
+	testutil.Expect(t, j.ValidateToken(bearerRequest(token)), false)
+}
+
+func TestNewJWTBackendRequiresKey(t *testing.T) {
+	_, err := NewJWTBackend(NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache()), nil)
+	testutil.Refute(t, err, nil)
+
+	_, err = NewJWTBackend(NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache()), []byte("-----BEGIN PUBLIC KEY-----\nbad\n-----END PUBLIC KEY-----"))
+	testutil.Refute(t, err, nil)
+}
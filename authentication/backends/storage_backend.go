@@ -15,6 +15,8 @@ type User struct {
 	Username string `json:"username" form:"username"`
 	Password string `json:"password" form:"password"`
 	IsAdmin  bool   `json:"is_admin" form:"is_admin"`
+	// Code - one-time code, only sent in login requests when TOTP authentication is used
+	Code string `json:"code,omitempty" form:"code"`
 }
 
 func (u *User) Encode() ([]byte, error) {
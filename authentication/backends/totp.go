@@ -0,0 +1,204 @@
+package backends
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/cache"
+)
+
+// TOTPSecretKeyPrefix - prefix for metadata keys that hold TOTP secrets of enrolled users
+const TOTPSecretKeyPrefix = "totp_"
+
+// TOTPIssuer - issuer shown in TOTP apps
+const TOTPIssuer = "Hoverfly"
+
+const (
+	// totpPeriod - RFC 6238 time step
+	totpPeriod = 30
+	// totpDigits - length of generated codes
+	totpDigits = 6
+	// totpSkewSteps - codes from this many steps before and after current time are accepted
+	totpSkewSteps = 1
+	// totpSecretSize - size of generated secrets in bytes, same as SHA1 output
+	totpSecretSize = 20
+)
+
+// CodeAuthentication - authentication backend that verifies one-time codes instead of passwords
+type CodeAuthentication interface {
+	ValidateCode(username, code string) bool
+}
+
+// totpSecret - enrolled secret together with the last used time step, so that codes can't be reused
+type totpSecret struct {
+	Secret   string `json:"secret"`
+	LastStep int64  `json:"lastStep"`
+}
+
+// TOTPAuthentication - authentication backend that validates username and TOTP code (RFC 6238, SHA1, 30 second
+// step) against secrets stored in secrets cache (metadata cache). Tokens are handled by wrapped backend.
+type TOTPAuthentication struct {
+	Authentication
+
+	secrets cache.Cache
+	now     func() time.Time
+	mu      sync.Mutex
+}
+
+// NewTOTPAuthentication - wraps authentication backend, secrets are stored in given cache
+func NewTOTPAuthentication(ab Authentication, secrets cache.Cache) *TOTPAuthentication {
+	return &TOTPAuthentication{Authentication: ab, secrets: secrets, now: time.Now}
+}
+
+// Enroll - generates new secret for user, existing secret is replaced. Returns base32 encoded secret and
+// otpauth URI that TOTP apps can be configured with, usually by scanning it as QR code.
+func (t *TOTPAuthentication) Enroll(username string) (secret, uri string, err error) {
+	if username == "" {
+		return "", "", fmt.Errorf("username is required")
+	}
+
+	raw := make([]byte, totpSecretSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw)
+
+	// concurrent validation must not store the replaced secret back
+	t.mu.Lock()
+	err = t.storeSecret(username, totpSecret{Secret: secret})
+	t.mu.Unlock()
+	if err != nil {
+		return "", "", err
+	}
+
+	label := url.PathEscape(TOTPIssuer + ":" + username)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", TOTPIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", totpDigits))
+	params.Set("period", fmt.Sprintf("%d", totpPeriod))
+
+	return secret, "otpauth://totp/" + label + "?" + params.Encode(), nil
+}
+
+// ValidateCode - checks TOTP code of enrolled user, every code can be used only once
+func (t *TOTPAuthentication) ValidateCode(username, code string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stored, err := t.getSecret(username)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"username": username,
+		}).Warn("TOTP secret not found")
+		return false
+	}
+
+	current := t.now().Unix() / totpPeriod
+	for step := current - totpSkewSteps; step <= current+totpSkewSteps; step++ {
+		if step <= stored.LastStep {
+			continue
+		}
+		expected, err := totpCode(stored.Secret, step)
+		if err != nil {
+			logUserError(err, username)
+			return false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			stored.LastStep = step
+			if err := t.storeSecret(username, stored); err != nil {
+				logUserError(err, username)
+				return false
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// GetUser - returns enrolled user, only users that are admins in wrapped backend are admins
+func (t *TOTPAuthentication) GetUser(username string) (*User, error) {
+	if _, err := t.getSecret(username); err != nil {
+		return nil, err
+	}
+	return &User{Username: username, IsAdmin: t.isAdmin(username)}, nil
+}
+
+// GetAllUsers - returns enrolled users
+func (t *TOTPAuthentication) GetAllUsers() ([]User, error) {
+	keys, err := t.secrets.Keys()
+	if err != nil {
+		return nil, err
+	}
+	users := []User{}
+	for _, k := range keys {
+		if strings.HasPrefix(k, TOTPSecretKeyPrefix) {
+			username := strings.TrimPrefix(k, TOTPSecretKeyPrefix)
+			users = append(users, User{Username: username, IsAdmin: t.isAdmin(username)})
+		}
+	}
+	return users, nil
+}
+
+// isAdmin - TOTP secrets don't carry roles, they are taken from the user of wrapped backend
+func (t *TOTPAuthentication) isAdmin(username string) bool {
+	if t.Authentication == nil {
+		return false
+	}
+	user, err := t.Authentication.GetUser(username)
+	return err == nil && user != nil && user.IsAdmin
+}
+
+func (t *TOTPAuthentication) getSecret(username string) (totpSecret, error) {
+	var stored totpSecret
+	bts, err := t.secrets.Get([]byte(TOTPSecretKeyPrefix + username))
+	if err != nil {
+		return stored, fmt.Errorf("user '%s' is not enrolled", username)
+	}
+	err = json.Unmarshal(bts, &stored)
+	return stored, err
+}
+
+func (t *TOTPAuthentication) storeSecret(username string, secret totpSecret) error {
+	bts, err := json.Marshal(secret)
+	if err != nil {
+		return err
+	}
+	return t.secrets.Set([]byte(TOTPSecretKeyPrefix+username), bts)
+}
+
+// GenerateTOTPCode - returns code for base32 encoded secret at given time
+func GenerateTOTPCode(secret string, at time.Time) (string, error) {
+	return totpCode(secret, at.Unix()/totpPeriod)
+}
+
+// totpCode - HOTP (RFC 4226) value for given time step
+func totpCode(secret string, step int64) (string, error) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
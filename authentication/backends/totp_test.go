@@ -0,0 +1,124 @@
+package backends
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// rfc6238Secret - base32 encoded SHA1 secret from RFC 6238 test vectors
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTPCodeRFC6238Vectors(t *testing.T) {
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for at, expected := range vectors {
+		code, err := GenerateTOTPCode(rfc6238Secret, time.Unix(at, 0))
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, code, expected)
+	}
+}
+
+func newTestTOTPAuthentication(now time.Time) *TOTPAuthentication {
+	t := NewTOTPAuthentication(NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache()), cache.NewInMemoryCache())
+	t.now = func() time.Time { return now }
+	return t
+}
+
+func TestTOTPEnrollAndValidate(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	totp := newTestTOTPAuthentication(now)
+
+	secret, uri, err := totp.Enroll("hfadmin")
+	testutil.Expect(t, err, nil)
+
+	parsed, err := url.Parse(uri)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, parsed.Scheme, "otpauth")
+	testutil.Expect(t, parsed.Host, "totp")
+	testutil.Expect(t, parsed.Path, "/Hoverfly:hfadmin")
+	testutil.Expect(t, parsed.Query().Get("secret"), secret)
+	testutil.Expect(t, parsed.Query().Get("issuer"), TOTPIssuer)
+
+	code, err := GenerateTOTPCode(secret, now)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, totp.ValidateCode("hfadmin", code), true)
+
+	// codes can't be reused
+	testutil.Expect(t, totp.ValidateCode("hfadmin", code), false)
+
+	// next code is accepted to tolerate clock skew
+	next, err := GenerateTOTPCode(secret, now.Add(30*time.Second))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, totp.ValidateCode("hfadmin", next), true)
+
+	users, err := totp.GetAllUsers()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(users), 1)
+	testutil.Expect(t, users[0].Username, "hfadmin")
+}
+
+func TestTOTPValidateRejectsWrongCodes(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	totp := newTestTOTPAuthentication(now)
+
+	secret, _, err := totp.Enroll("hfadmin")
+	testutil.Expect(t, err, nil)
+
+	old, err := GenerateTOTPCode(secret, now.Add(-2*time.Minute))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, totp.ValidateCode("hfadmin", old), false)
+
+	code, err := GenerateTOTPCode(secret, now)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, totp.ValidateCode("unknown", code), false)
+	testutil.Expect(t, totp.ValidateCode("hfadmin", ""), false)
+
+	_, err = totp.GetUser("unknown")
+	testutil.Refute(t, err, nil)
+}
+
+func TestTOTPEnrollRequiresUsername(t *testing.T) {
+	totp := newTestTOTPAuthentication(time.Now())
+
+	_, _, err := totp.Enroll("")
+	testutil.Refute(t, err, nil)
+
+	secret, _, err := totp.Enroll("user")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, strings.ToUpper(secret), secret)
+}
+
+func TestTOTPUsersAreAdminsOfWrappedBackend(t *testing.T) {
+	users := NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache())
+	testutil.Expect(t, users.AddUser("hfadmin", "password", true), nil)
+	testutil.Expect(t, users.AddUser("bob", "password", false), nil)
+	totp := NewTOTPAuthentication(users, cache.NewInMemoryCache())
+
+	for _, username := range []string{"hfadmin", "bob", "carol"} {
+		_, _, err := totp.Enroll(username)
+		testutil.Expect(t, err, nil)
+	}
+
+	admins := map[string]bool{"hfadmin": true, "bob": false, "carol": false}
+	for username, admin := range admins {
+		user, err := totp.GetUser(username)
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, user.IsAdmin, admin)
+	}
+
+	all, err := totp.GetAllUsers()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(all), 3)
+	for _, user := range all {
+		testutil.Expect(t, user.IsAdmin, admins[user.Username])
+	}
+}
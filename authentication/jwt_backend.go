@@ -80,6 +80,10 @@ func (backend *JWTAuthenticationBackend) GenerateToken(userUUID, username string
 }
 
 func (backend *JWTAuthenticationBackend) Authenticate(user *backends.User) bool {
+	if codeAuth, ok := backend.AuthBackend.(backends.CodeAuthentication); ok {
+		return codeAuth.ValidateCode(user.Username, user.Code)
+	}
+
 	dbUser, err := backend.AuthBackend.GetUser(user.Username)
 	if err != nil {
 		log.WithFields(log.Fields{
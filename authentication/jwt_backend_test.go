@@ -3,6 +3,7 @@ package authentication
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/SpectoLabs/hoverfly/authentication/backends"
 	"github.com/SpectoLabs/hoverfly/cache"
@@ -86,3 +87,19 @@ func TestNotBlacklisted(t *testing.T) {
 	blacklisted := jwtBackend.IsInBlacklist(tokenString)
 	expect(t, blacklisted, false)
 }
+
+func TestAuthenticateWithTOTPCode(t *testing.T) {
+	totp := backends.NewTOTPAuthentication(
+		backends.NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache()),
+		cache.NewInMemoryCache())
+	secret, _, err := totp.Enroll("totpuser")
+	expect(t, err, nil)
+
+	jwtBackend := &JWTAuthenticationBackend{AuthBackend: totp, SecretKey: []byte("verysecret"), JWTExpirationDelta: 100}
+
+	code, err := backends.GenerateTOTPCode(secret, time.Now())
+	expect(t, err, nil)
+
+	expect(t, jwtBackend.Authenticate(&backends.User{Username: "totpuser", Password: code}), false)
+	expect(t, jwtBackend.Authenticate(&backends.User{Username: "totpuser", Code: code}), true)
+}
@@ -26,6 +26,16 @@ func (a *AuthMiddleware) RequireTokenAuthentication(w http.ResponseWriter, req *
 		return
 	}
 
+	// backends that validate externally issued tokens replace Hoverfly token checks
+	if tokenBackend, ok := a.AB.(backends.TokenAuthentication); ok {
+		if tokenBackend.ValidateToken(req) {
+			next(w, req)
+		} else {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+		return
+	}
+
 	authBackend := InitJWTAuthenticationBackend(a.AB, a.SecretKey, a.JWTExpirationDelta)
 
 	token, err := jwt.ParseFromRequest(req, func(token *jwt.Token) (interface{}, error) {
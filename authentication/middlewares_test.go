@@ -0,0 +1,68 @@
+package authentication
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/authentication/backends"
+	"github.com/SpectoLabs/hoverfly/cache"
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+func jwtProtectedRecorder(t *testing.T, header string) *httptest.ResponseRecorder {
+	ab, err := backends.NewJWTBackend(backends.NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache()), []byte("verysecret"))
+	expect(t, err, nil)
+	am := GetNewAuthenticationMiddleware(ab, []byte("hoverflysecret"), 100, true)
+
+	req, _ := http.NewRequest("GET", "http://localhost/api/records", nil)
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	rec := httptest.NewRecorder()
+	am.RequireTokenAuthentication(rec, req, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return rec
+}
+
+func hs256Token(t *testing.T, exp time.Time) string {
+	token := jwt.New(jwt.SigningMethodHS256)
+	token.Claims["sub"] = "hfadmin"
+	token.Claims["exp"] = exp.Unix()
+	tokenString, err := token.SignedString([]byte("verysecret"))
+	expect(t, err, nil)
+	return tokenString
+}
+
+func TestJWTBackendValidToken(t *testing.T) {
+	rec := jwtProtectedRecorder(t, "Bearer "+hs256Token(t, time.Now().Add(time.Hour)))
+	expect(t, rec.Code, http.StatusOK)
+}
+
+func TestJWTBackendExpiredToken(t *testing.T) {
+	rec := jwtProtectedRecorder(t, "Bearer "+hs256Token(t, time.Now().Add(-time.Hour)))
+	expect(t, rec.Code, http.StatusUnauthorized)
+}
+
+func TestJWTBackendTamperedSignature(t *testing.T) {
+	token := hs256Token(t, time.Now().Add(time.Hour))
+	parts := strings.Split(token, ".")
+	signature := []byte(parts[2])
+	if signature[0] == 'A' {
+		signature[0] = 'B'
+	} else {
+		signature[0] = 'A'
+	}
+	parts[2] = string(signature)
+
+	rec := jwtProtectedRecorder(t, "Bearer "+strings.Join(parts, "."))
+	expect(t, rec.Code, http.StatusUnauthorized)
+}
+
+func TestJWTBackendMissingHeader(t *testing.T) {
+	rec := jwtProtectedRecorder(t, "")
+	expect(t, rec.Code, http.StatusUnauthorized)
+}
@@ -0,0 +1,73 @@
+package hoverfly
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// bloomFilter - set membership test without false negatives, false positive rate stays close to the one it was
+// sized for until more than capacity keys are added
+type bloomFilter struct {
+	bits     []uint64
+	size     uint64
+	hashes   uint64
+	count    int
+	capacity int
+}
+
+// newBloomFilter - filter sized for capacity keys at given false positive rate (0-1)
+func newBloomFilter(capacity int, fpRate float64) *bloomFilter {
+	if capacity < 1 {
+		capacity = 1
+	}
+	// optimal number of bits and hash functions
+	size := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if size < 64 {
+		size = 64
+	}
+	hashes := uint64(math.Round(float64(size) / float64(capacity) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &bloomFilter{
+		bits:     make([]uint64, (size+63)/64),
+		size:     size,
+		hashes:   hashes,
+		capacity: capacity,
+	}
+}
+
+// locations - double hashing of 64-bit FNV-1a hash (Kirsch-Mitzenmacher)
+func (f *bloomFilter) locations(key string, fn func(bit uint64) bool) bool {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+
+	for i := uint64(0); i < f.hashes; i++ {
+		if !fn((h1 + i*h2) % f.size) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) add(key string) {
+	f.locations(key, func(bit uint64) bool {
+		f.bits[bit/64] |= 1 << (bit % 64)
+		return true
+	})
+	f.count++
+}
+
+// mayContain - false when key was never added
+func (f *bloomFilter) mayContain(key string) bool {
+	return f.locations(key, func(bit uint64) bool {
+		return f.bits[bit/64]&(1<<(bit%64)) != 0
+	})
+}
+
+// full - more keys were added than filter was sized for
+func (f *bloomFilter) full() bool {
+	return f.count > f.capacity
+}
@@ -0,0 +1,37 @@
+package hoverfly
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestBloomFilterHasNoFalseNegatives(t *testing.T) {
+	f := newBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		f.add(fmt.Sprintf("key-%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		testutil.Expect(t, f.mayContain(fmt.Sprintf("key-%d", i)), true)
+	}
+	testutil.Expect(t, f.full(), false)
+	f.add("one more")
+	testutil.Expect(t, f.full(), true)
+}
+
+func TestBloomFilterFalsePositiveRate(t *testing.T) {
+	f := newBloomFilter(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		f.add(fmt.Sprintf("key-%d", i))
+	}
+
+	positives := 0
+	for i := 0; i < 10000; i++ {
+		if f.mayContain(fmt.Sprintf("other-%d", i)) {
+			positives++
+		}
+	}
+	// 1% expected
+	testutil.Expect(t, positives < 200, true)
+}
@@ -0,0 +1,185 @@
+package hoverfly
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// bodyKeyPrefix - prefix for metadata keys that hold deduplicated response bodies
+const bodyKeyPrefix = "body_sha256_"
+
+// DedupCache - request cache wrapper that stores every distinct response body only once, under its SHA-256 hash
+// in bodies cache, payloads keep a reference to the body. Callers get complete payloads back.
+type DedupCache struct {
+	cache.Cache
+	bodies cache.Cache
+}
+
+// NewDedupCache - wraps request cache, bodies are stored in given cache (metadata cache)
+func NewDedupCache(requestCache, bodies cache.Cache) *DedupCache {
+	return &DedupCache{Cache: requestCache, bodies: bodies}
+}
+
+// Set - stores payload with body replaced by reference, values that are not payloads are stored as they are
+func (c *DedupCache) Set(key, value []byte) error {
+	payload, err := models.NewPayloadFromBytes(value)
+	if err != nil || payload.Response.Body == "" {
+		return c.Cache.Set(key, value)
+	}
+
+	sum := sha256.Sum256([]byte(payload.Response.Body))
+	ref := hex.EncodeToString(sum[:])
+	bodyKey := []byte(bodyKeyPrefix + ref)
+
+	if _, err := c.bodies.Get(bodyKey); err != nil {
+		if err := c.bodies.Set(bodyKey, []byte(payload.Response.Body)); err != nil {
+			return err
+		}
+	}
+
+	payload.Response.Body = ""
+	payload.ResponseBodyRef = ref
+
+	bts, err := payload.Encode()
+	if err != nil {
+		return err
+	}
+	return c.Cache.Set(key, bts)
+}
+
+// Get - returns payload with referenced body restored
+func (c *DedupCache) Get(key []byte) ([]byte, error) {
+	value, err := c.Cache.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.restoreBody(value)
+}
+
+// GetAllValues - returns all payloads with referenced bodies restored
+func (c *DedupCache) GetAllValues() ([][]byte, error) {
+	values, err := c.Cache.GetAllValues()
+	if err != nil {
+		return nil, err
+	}
+	for i := range values {
+		if values[i], err = c.restoreBody(values[i]); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// GetAllEntries - returns all entries with referenced bodies restored
+func (c *DedupCache) GetAllEntries() (map[string][]byte, error) {
+	entries, err := c.Cache.GetAllEntries()
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range entries {
+		if entries[k], err = c.restoreBody(v); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// DeleteData - removes all payloads together with deduplicated bodies, bodies are kept when payloads
+// could not be removed
+func (c *DedupCache) DeleteData() error {
+	if err := c.Cache.DeleteData(); err != nil {
+		return err
+	}
+
+	keys, err := c.bodies.Keys()
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if strings.HasPrefix(k, bodyKeyPrefix) {
+			if err := c.bodies.Delete([]byte(k)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deleteMetadataExcept - removes all metadata except keys with given prefixes
+func deleteMetadataExcept(metadata cache.Cache, prefixes ...string) error {
+	keys, err := metadata.Keys()
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if !hasAnyPrefix(k, prefixes) {
+			if err := metadata.Delete([]byte(k)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// shareResponseBody - moves response body of exported record to bodies, record references it by SHA-256 hash of
+// the body as it is exported
+func shareResponseBody(view *models.PayloadView, bodies map[string]string) {
+	if view.Response.Body == "" {
+		return
+	}
+	sum := sha256.Sum256([]byte(view.Response.Body))
+	ref := hex.EncodeToString(sum[:])
+
+	bodies[ref] = view.Response.Body
+	view.Response.Body = ""
+	view.ResponseBodyRef = ref
+}
+
+// resolveBodyRefs - replaces body references of records with bodies they reference
+func (r *recordedRequests) resolveBodyRefs() error {
+	for i := range r.Data {
+		ref := r.Data[i].ResponseBodyRef
+		if ref == "" {
+			continue
+		}
+		body, ok := r.Bodies[ref]
+		if !ok {
+			return fmt.Errorf("response body %s of data[%d] not found in bodies", ref, i)
+		}
+		r.Data[i].Response.Body = body
+		r.Data[i].ResponseBodyRef = ""
+	}
+	return nil
+}
+
+// restoreBody - replaces body reference with the body itself
+func (c *DedupCache) restoreBody(value []byte) ([]byte, error) {
+	payload, err := models.NewPayloadFromBytes(value)
+	if err != nil || payload.ResponseBodyRef == "" {
+		return value, nil
+	}
+
+	body, err := c.bodies.Get([]byte(bodyKeyPrefix + payload.ResponseBodyRef))
+	if err != nil {
+		return nil, fmt.Errorf("response body %s not found: %s", payload.ResponseBodyRef, err.Error())
+	}
+
+	payload.Response.Body = string(body)
+	payload.ResponseBodyRef = ""
+
+	return payload.Encode()
+}
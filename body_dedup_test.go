@@ -0,0 +1,213 @@
+package hoverfly
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/authentication/backends"
+	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func encodedPayload(t *testing.T, path, body string) []byte {
+	payload := models.Payload{
+		Request:  models.RequestDetails{Path: path, Method: "GET", Destination: "example.com"},
+		Response: models.ResponseDetails{Status: 404, Body: body},
+	}
+	bts, err := payload.Encode()
+	testutil.Expect(t, err, nil)
+	return bts
+}
+
+func TestDedupCacheStoresBodyOnce(t *testing.T) {
+	requests := cache.NewInMemoryCache()
+	bodies := cache.NewInMemoryCache()
+	c := NewDedupCache(requests, bodies)
+
+	testutil.Expect(t, c.Set([]byte("one"), encodedPayload(t, "/one", "not found")), nil)
+	testutil.Expect(t, c.Set([]byte("two"), encodedPayload(t, "/two", "not found")), nil)
+
+	count, err := bodies.Count()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+
+	// underlying cache only holds references
+	raw, err := requests.Get([]byte("one"))
+	testutil.Expect(t, err, nil)
+	stored, err := models.NewPayloadFromBytes(raw)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, stored.Response.Body, "")
+	testutil.Refute(t, stored.ResponseBodyRef, "")
+
+	bts, err := c.Get([]byte("two"))
+	testutil.Expect(t, err, nil)
+	payload, err := models.NewPayloadFromBytes(bts)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, payload.Response.Body, "not found")
+	testutil.Expect(t, payload.ResponseBodyRef, "")
+
+	values, err := c.GetAllValues()
+	testutil.Expect(t, err, nil)
+	for _, v := range values {
+		payload, err := models.NewPayloadFromBytes(v)
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, payload.Response.Body, "not found")
+	}
+}
+
+func TestDedupCachePassesThroughOtherValues(t *testing.T) {
+	c := NewDedupCache(cache.NewInMemoryCache(), cache.NewInMemoryCache())
+
+	testutil.Expect(t, c.Set([]byte("foo"), []byte("bar")), nil)
+
+	value, err := c.Get([]byte("foo"))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(value), "bar")
+}
+
+func TestDedupCacheDeleteDataRemovesBodies(t *testing.T) {
+	bodies := cache.NewInMemoryCache()
+	c := NewDedupCache(cache.NewInMemoryCache(), bodies)
+
+	bodies.Set([]byte("other"), []byte("metadata"))
+	testutil.Expect(t, c.Set([]byte("one"), encodedPayload(t, "/one", "not found")), nil)
+
+	testutil.Expect(t, c.DeleteData(), nil)
+
+	keys, err := bodies.Keys()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(keys), 1)
+	testutil.Expect(t, keys[0], "other")
+}
+
+func TestDeleteMetadataKeepsDeduplicatedBodies(t *testing.T) {
+	bodies := cache.NewInMemoryCache()
+	c := NewDedupCache(cache.NewInMemoryCache(), bodies)
+
+	bodies.Set([]byte("other"), []byte("metadata"))
+	testutil.Expect(t, c.Set([]byte("one"), encodedPayload(t, "/one", "not found")), nil)
+
+	testutil.Expect(t, deleteMetadataExcept(bodies, bodyKeyPrefix), nil)
+
+	bts, err := c.Get([]byte("one"))
+	testutil.Expect(t, err, nil)
+	payload, err := models.NewPayloadFromBytes(bts)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, payload.Response.Body, "not found")
+
+	keys, err := bodies.Keys()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(keys), 1)
+}
+
+func TestDeduplicateBodiesAcrossURLs(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer upstream.Close()
+
+	cfg := InitSettings()
+	cfg.DeduplicateBodies = true
+	authBackend := backends.NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache())
+	h, err := GetNewHoverfly(cfg, cache.NewInMemoryCache(), cache.NewInMemoryCache(), authBackend)
+	testutil.Expect(t, err, nil)
+
+	_, dedup := h.RequestCache.(*DedupCache)
+	testutil.Expect(t, dedup, true)
+
+	cfg.SetMode(CaptureMode)
+	for _, path := range []string{"/health", "/ping"} {
+		req, err := http.NewRequest("GET", upstream.URL+path, nil)
+		testutil.Expect(t, err, nil)
+		_, resp := h.processRequest(req)
+		testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	}
+
+	keys, err := h.MetadataCache.Keys()
+	testutil.Expect(t, err, nil)
+	bodies := 0
+	for _, k := range keys {
+		if strings.HasPrefix(k, bodyKeyPrefix) {
+			bodies++
+		}
+	}
+	testutil.Expect(t, bodies, 1)
+
+	cfg.SetMode(SimulateMode)
+	req, err := http.NewRequest("GET", upstream.URL+"/ping", nil)
+	testutil.Expect(t, err, nil)
+	_, resp := h.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), `{"ok":true}`)
+}
+
+func testExport(t *testing.T, dedupBodies bool) []byte {
+	cfg := InitSettings()
+	cfg.DeduplicateBodies = dedupBodies
+	authBackend := backends.NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache())
+	h, err := GetNewHoverfly(cfg, cache.NewInMemoryCache(), cache.NewInMemoryCache(), authBackend)
+	testutil.Expect(t, err, nil)
+
+	body := strings.Repeat(`{"status":"ok"}`, 100)
+	var payloads []models.PayloadView
+	for i := 0; i < 10; i++ {
+		p := models.Payload{
+			Request:  models.RequestDetails{Path: fmt.Sprintf("/health/%d", i), Method: "GET", Destination: "example.com"},
+			Response: models.ResponseDetails{Status: 200, Body: body},
+		}
+		payloads = append(payloads, *p.ConvertToPayloadView())
+	}
+	testutil.Expect(t, h.ImportPayloads(payloads), nil)
+
+	var buf bytes.Buffer
+	testutil.Expect(t, h.ExportSimulation(&buf, ExportOptions{}), nil)
+	return buf.Bytes()
+}
+
+func TestDeduplicateBodiesReducesExportSize(t *testing.T) {
+	plain := testExport(t, false)
+	deduplicated := testExport(t, true)
+
+	// body is written once instead of ten times
+	body := strings.Repeat(`{"status":"ok"}`, 100)
+	testutil.Expect(t, len(deduplicated) < len(plain)-9*len(body), true)
+
+	// export is a valid simulation that imports with bodies
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	count, err := dbClient.ImportSimulation(bytes.NewReader(deduplicated))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 10)
+
+	values, err := dbClient.RequestCache.GetAllValues()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(values), 10)
+	for _, v := range values {
+		payload, err := models.NewPayloadFromBytes(v)
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, payload.Response.Body, body)
+		testutil.Expect(t, payload.ResponseBodyRef, "")
+	}
+}
+
+func TestImportMissingSharedBody(t *testing.T) {
+	server, dbClient := testTools(200, `{}`)
+	defer server.Close()
+	simulation := `{"data":[{"request":{"method":"GET","destination":"example.com"},"response":{"status":200},"responseBodyRef":"abc"}]}`
+
+	_, err := dbClient.ImportSimulation(strings.NewReader(simulation))
+	testutil.Refute(t, err, nil)
+
+	count, err := dbClient.RequestCache.Count()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 0)
+}
@@ -0,0 +1,162 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// body matcher types, exact matching is the default when request doesn't have body matcher
+const (
+	BodyMatcherExact    = "exact"
+	BodyMatcherJSONPath = "jsonpath"
+	BodyMatcherRegex    = "regex"
+)
+
+// bodyMatchersKeyPrefix - prefix for metadata keys that hold request cache keys of payloads with body matchers,
+// grouped by endpoint
+const bodyMatchersKeyPrefix = "bodymatchers_"
+
+// bodyMatcherSpecificity - matchers are tried from the most specific one, exact matches are found by request key
+var bodyMatcherSpecificity = map[string]int{
+	BodyMatcherExact:    0,
+	BodyMatcherJSONPath: 1,
+	BodyMatcherRegex:    2,
+}
+
+// validateBodyMatcher - checks whether matcher type is known and its expression compiles
+func validateBodyMatcher(m *models.BodyMatcher) error {
+	switch m.Type {
+	case BodyMatcherExact:
+		return nil
+	case BodyMatcherJSONPath:
+		_, err := parseJSONPath(m.Expression)
+		return err
+	case BodyMatcherRegex:
+		if _, err := regexp.Compile(m.Expression); err != nil {
+			return fmt.Errorf("invalid body matcher regex '%s': %s", m.Expression, err.Error())
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown body matcher type '%s'", m.Type)
+}
+
+// bodyMatches - checks request body against matcher, exact matchers are never checked here as identical bodies
+// have the same request key
+func bodyMatches(m *models.BodyMatcher, body []byte) bool {
+	switch m.Type {
+	case BodyMatcherJSONPath:
+		steps, err := parseJSONPath(m.Expression)
+		if err != nil {
+			return false
+		}
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return false
+		}
+		value, found := evalJSONPath(doc, steps)
+		if !found {
+			return false
+		}
+		if m.Value == "" {
+			return true
+		}
+		if s, ok := value.(string); ok {
+			return s == m.Value
+		}
+		// numbers, booleans and null are compared in their JSON form
+		encoded, err := json.Marshal(value)
+		return err == nil && string(encoded) == m.Value
+
+	case BodyMatcherRegex:
+		re, err := regexp.Compile(m.Expression)
+		return err == nil && re.Match(body)
+	}
+	return false
+}
+
+// endpointKey - metadata key of body matchers registered for request method, destination, path and query
+func (d *Hoverfly) endpointKey(method, destination, path, query string) []byte {
+	r := models.RequestDetails{
+		Method:      method,
+		Destination: destination,
+		Path:        path,
+		Query:       d.Cfg.normaliseQuery(query),
+	}
+	return []byte(bodyMatchersKeyPrefix + r.Hash())
+}
+
+// registerBodyMatcher - makes payload stored under given key available to requests with different body, payloads
+// with exact matcher are found by their key and don't need to be registered
+func (d *Hoverfly) registerBodyMatcher(key string, request models.RequestDetails) error {
+	if request.BodyMatcher == nil || request.BodyMatcher.Type == BodyMatcherExact {
+		return nil
+	}
+
+	endpoint := d.endpointKey(request.Method, request.Destination, request.Path, request.Query)
+	keys := d.bodyMatcherKeys(endpoint)
+	for _, k := range keys {
+		if k == key {
+			return nil
+		}
+	}
+
+	bts, err := json.Marshal(append(keys, key))
+	if err != nil {
+		return err
+	}
+	return d.MetadataCache.Set(endpoint, bts)
+}
+
+func (d *Hoverfly) bodyMatcherKeys(endpoint []byte) []string {
+	var keys []string
+	bts, err := d.MetadataCache.Get(endpoint)
+	if err != nil {
+		return keys
+	}
+	if err := json.Unmarshal(bts, &keys); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   string(endpoint),
+		}).Warn("Failed to decode body matchers")
+	}
+	return keys
+}
+
+// matchBody - finds payload which body matcher matches request body, returns its key and encoded payload
+func (d *Hoverfly) matchBody(method, destination, path, query string, body []byte) (string, []byte, bool) {
+	type candidate struct {
+		key     string
+		bts     []byte
+		matcher *models.BodyMatcher
+	}
+
+	var candidates []candidate
+	for _, key := range d.bodyMatcherKeys(d.endpointKey(method, destination, path, query)) {
+		bts, err := d.RequestCache.Get([]byte(key))
+		// payload was deleted
+		if err != nil {
+			continue
+		}
+		payload, err := models.NewPayloadFromBytes(bts)
+		if err != nil || payload.Request.BodyMatcher == nil {
+			continue
+		}
+		candidates = append(candidates, candidate{key: key, bts: bts, matcher: payload.Request.BodyMatcher})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return bodyMatcherSpecificity[candidates[i].matcher.Type] < bodyMatcherSpecificity[candidates[j].matcher.Type]
+	})
+
+	for _, c := range candidates {
+		if bodyMatches(c.matcher, body) {
+			return c.key, c.bts, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,123 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func bodyMatcherPayload(body string, matcher *models.BodyMatcher, status int) models.PayloadView {
+	return models.PayloadView{
+		Request: models.RequestDetailsView{
+			Method:      "POST",
+			Destination: "api.example.com",
+			Path:        "/orders",
+			Body:        body,
+			Headers:     map[string][]string{"Content-Type": {"application/json"}},
+			BodyMatcher: matcher,
+		},
+		Response: models.ResponseDetailsView{Status: status},
+	}
+}
+
+func postOrder(t *testing.T, dbClient *Hoverfly, body string) *http.Response {
+	req, err := http.NewRequest("POST", "http://api.example.com/orders", bytes.NewBufferString(body))
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Content-Type", "application/json")
+
+	_, resp := dbClient.processRequest(req)
+	return resp
+}
+
+func TestBodyMatchers(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	err := dbClient.ImportPayloads([]models.PayloadView{
+		bodyMatcherPayload(`{"type": "any"}`, &models.BodyMatcher{Type: BodyMatcherRegex, Expression: `"type":\s*"`}, 202),
+		bodyMatcherPayload(`{"type": "book"}`, &models.BodyMatcher{Type: BodyMatcherJSONPath, Expression: "$.type", Value: "book"}, 201),
+		bodyMatcherPayload(`{"quantity": 2}`, &models.BodyMatcher{Type: BodyMatcherJSONPath, Expression: "$.quantity", Value: "2"}, 203),
+		bodyMatcherPayload(`{"type": "exact"}`, &models.BodyMatcher{Type: BodyMatcherExact}, 200),
+	})
+	testutil.Expect(t, err, nil)
+	dbClient.Cfg.SetMode(SimulateMode)
+
+	// identical body wins over other matchers
+	testutil.Expect(t, postOrder(t, dbClient, `{"type": "exact"}`).StatusCode, 200)
+
+	// JSONPath is more specific than regex
+	testutil.Expect(t, postOrder(t, dbClient, `{"type": "book", "title": "Go"}`).StatusCode, 201)
+	testutil.Expect(t, postOrder(t, dbClient, `{"quantity": 2, "type": 1}`).StatusCode, 203)
+
+	testutil.Expect(t, postOrder(t, dbClient, `{"type": "film"}`).StatusCode, 202)
+
+	testutil.Expect(t, postOrder(t, dbClient, `{"quantity": 3}`).StatusCode, http.StatusPreconditionFailed)
+}
+
+func TestBodyMatcherDoesNotMatchOtherEndpoints(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	err := dbClient.ImportPayloads([]models.PayloadView{
+		bodyMatcherPayload(`{}`, &models.BodyMatcher{Type: BodyMatcherRegex, Expression: `.*`}, 202),
+	})
+	testutil.Expect(t, err, nil)
+	dbClient.Cfg.SetMode(SimulateMode)
+
+	req, err := http.NewRequest("POST", "http://api.example.com/invoices", bytes.NewBufferString(`{}`))
+	testutil.Expect(t, err, nil)
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+}
+
+func TestGetResponseRewindsRequestBody(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	req, err := http.NewRequest("POST", "http://api.example.com/orders", bytes.NewBufferString(`{"type": "book"}`))
+	testutil.Expect(t, err, nil)
+	dbClient.getResponse(req)
+
+	body, err := ioutil.ReadAll(req.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), `{"type": "book"}`)
+}
+
+func TestInvalidBodyMatcherIsNotImported(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	err := dbClient.ImportPayloads([]models.PayloadView{
+		bodyMatcherPayload(`{}`, &models.BodyMatcher{Type: BodyMatcherRegex, Expression: `(`}, 202),
+		bodyMatcherPayload(`{}`, &models.BodyMatcher{Type: BodyMatcherJSONPath, Expression: "type"}, 202),
+		bodyMatcherPayload(`{}`, &models.BodyMatcher{Type: "xpath", Expression: "/type"}, 202),
+	})
+	testutil.Expect(t, err, nil)
+
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 0)
+}
+
+func TestBodyMatches(t *testing.T) {
+	body := []byte(`{"user": {"id": 42, "name": "jo", "admin": false}, "tags": ["a", "b"]}`)
+
+	testutil.Expect(t, bodyMatches(&models.BodyMatcher{Type: BodyMatcherJSONPath, Expression: "$.user.id", Value: "42"}, body), true)
+	testutil.Expect(t, bodyMatches(&models.BodyMatcher{Type: BodyMatcherJSONPath, Expression: "$.user.name", Value: "jo"}, body), true)
+	testutil.Expect(t, bodyMatches(&models.BodyMatcher{Type: BodyMatcherJSONPath, Expression: "$.user.admin", Value: "false"}, body), true)
+	testutil.Expect(t, bodyMatches(&models.BodyMatcher{Type: BodyMatcherJSONPath, Expression: "$.tags[1]", Value: "b"}, body), true)
+	testutil.Expect(t, bodyMatches(&models.BodyMatcher{Type: BodyMatcherJSONPath, Expression: "$.user.email"}, body), false)
+	testutil.Expect(t, bodyMatches(&models.BodyMatcher{Type: BodyMatcherJSONPath, Expression: "$.user"}, body), true)
+	testutil.Expect(t, bodyMatches(&models.BodyMatcher{Type: BodyMatcherJSONPath, Expression: "$.user.id"}, []byte("not json")), false)
+
+	testutil.Expect(t, bodyMatches(&models.BodyMatcher{Type: BodyMatcherRegex, Expression: `"id":\s*\d+`}, body), true)
+	testutil.Expect(t, bodyMatches(&models.BodyMatcher{Type: BodyMatcherRegex, Expression: `^\[`}, body), false)
+}
@@ -197,3 +197,29 @@ func GetDB(name string) *bolt.DB {
 
 	return db
 }
+
+// Keys - returns all keys in current bucket, bolt keeps them sorted
+func (c *BoltCache) Keys() (keys []string, err error) {
+	err = c.DS.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(c.CurrentBucket)
+
+		keys = []string{}
+
+		if b == nil {
+			// bucket doesn't exist
+			return nil
+		}
+		c := b.Cursor()
+
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			keys = append(keys, string(k))
+		}
+		return nil
+	})
+	return
+}
+
+// Count - returns number of keys in current bucket
+func (c *BoltCache) Count() (int, error) {
+	return c.RecordsCount()
+}
@@ -84,6 +84,27 @@ func TestGetAllKeys(t *testing.T) {
 	expect(t, keys["foo10"], false)
 }
 
+func TestKeysAndCount(t *testing.T) {
+	db := NewBoltDBCache(TestDB, []byte("bucketTestKeysAndCount"))
+
+	keys, err := db.Keys()
+	expect(t, err, nil)
+	expect(t, len(keys), 0)
+
+	expect(t, db.Set([]byte("foo2"), []byte("bar")), nil)
+	expect(t, db.Set([]byte("foo"), []byte("bar")), nil)
+
+	keys, err = db.Keys()
+	expect(t, err, nil)
+	expect(t, len(keys), 2)
+	expect(t, keys[0], "foo")
+	expect(t, keys[1], "foo2")
+
+	count, err := db.Count()
+	expect(t, err, nil)
+	expect(t, count, 2)
+}
+
 func TestDeleteRecords(t *testing.T) {
 	db := NewBoltDBCache(TestDB, []byte("bucketTestDeleteRecords"))
 
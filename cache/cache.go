@@ -10,4 +10,7 @@ type Cache interface {
 	Delete(key []byte) error
 	DeleteData() error
 	GetAllKeys() (map[string]bool, error)
+	// Keys - returns all keys, sorted
+	Keys() ([]string, error)
+	Count() (int, error)
 }
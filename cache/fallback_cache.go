@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// FallbackCache - wraps another cache and keeps values in memory when wrapped cache fails, this way
+// callers can continue working while storage is unavailable
+type FallbackCache struct {
+	Primary  Cache
+	fallback *InMemoryCache
+}
+
+// NewFallbackCache - returns new FallbackCache instance wrapping given cache
+func NewFallbackCache(primary Cache) *FallbackCache {
+	return &FallbackCache{
+		Primary:  primary,
+		fallback: NewInMemoryCache(),
+	}
+}
+
+func logFallback(err error, operation string) {
+	log.WithFields(log.Fields{
+		"error":     err.Error(),
+		"operation": operation,
+	}).Warn("cache operation failed, falling back to in-memory state")
+}
+
+// Set - saves given key and value pair to wrapped cache or to memory if it fails
+func (c *FallbackCache) Set(key, value []byte) error {
+	if err := c.Primary.Set(key, value); err != nil {
+		logFallback(err, "set")
+		return c.fallback.Set(key, value)
+	}
+	// value is stored, making sure stale one is not returned
+	return c.fallback.Delete(key)
+}
+
+// Get - searches for given key in wrapped cache and then in memory
+func (c *FallbackCache) Get(key []byte) ([]byte, error) {
+	value, err := c.Primary.Get(key)
+	if err == nil {
+		return value, nil
+	}
+
+	if fallbackValue, fallbackErr := c.fallback.Get(key); fallbackErr == nil {
+		return fallbackValue, nil
+	}
+
+	return value, err
+}
+
+// GetAllEntries - returns all keys/values from wrapped cache and memory
+func (c *FallbackCache) GetAllEntries() (map[string][]byte, error) {
+	entries := make(map[string][]byte)
+
+	primaryEntries, err := c.Primary.GetAllEntries()
+	if err != nil {
+		logFallback(err, "get all entries")
+	}
+	for k, v := range primaryEntries {
+		entries[k] = v
+	}
+
+	fallbackEntries, _ := c.fallback.GetAllEntries()
+	for k, v := range fallbackEntries {
+		entries[k] = v
+	}
+
+	return entries, nil
+}
+
+// GetAllValues - returns all values from wrapped cache and memory
+func (c *FallbackCache) GetAllValues() ([][]byte, error) {
+	entries, err := c.GetAllEntries()
+
+	values := make([][]byte, 0, len(entries))
+	for _, v := range entries {
+		values = append(values, v)
+	}
+	return values, err
+}
+
+// GetAllKeys - returns all keys from wrapped cache and memory
+func (c *FallbackCache) GetAllKeys() (map[string]bool, error) {
+	entries, err := c.GetAllEntries()
+
+	keys := make(map[string]bool)
+	for k := range entries {
+		keys[k] = true
+	}
+	return keys, err
+}
+
+// RecordsCount - returns records count
+func (c *FallbackCache) RecordsCount() (int, error) {
+	entries, err := c.GetAllEntries()
+	return len(entries), err
+}
+
+// Delete - deletes specified key from wrapped cache and memory
+// Keys - returns sorted keys from both caches
+func (c *FallbackCache) Keys() ([]string, error) {
+	keys, err := c.GetAllKeys()
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	return sorted, err
+}
+
+// Count - returns number of entries in both caches
+func (c *FallbackCache) Count() (int, error) {
+	return c.RecordsCount()
+}
+
+func (c *FallbackCache) Delete(key []byte) error {
+	c.fallback.Delete(key)
+
+	err := c.Primary.Delete(key)
+	if err != nil {
+		logFallback(err, "delete")
+	}
+	return err
+}
+
+// DeleteData - deletes all data from wrapped cache and memory
+func (c *FallbackCache) DeleteData() error {
+	c.fallback.DeleteData()
+
+	err := c.Primary.DeleteData()
+	if err != nil {
+		logFallback(err, "delete data")
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+var errUnavailable = errors.New("backend unavailable")
+
+// failingCache - cache that fails every operation, i.e. remote backend that went away
+type failingCache struct{}
+
+func (c failingCache) Set(key, value []byte) error               { return errUnavailable }
+func (c failingCache) Get(key []byte) ([]byte, error)            { return nil, errUnavailable }
+func (c failingCache) GetAllValues() ([][]byte, error)           { return nil, errUnavailable }
+func (c failingCache) GetAllEntries() (map[string][]byte, error) { return nil, errUnavailable }
+func (c failingCache) RecordsCount() (int, error)                { return 0, errUnavailable }
+func (c failingCache) Delete(key []byte) error                   { return errUnavailable }
+func (c failingCache) DeleteData() error                         { return errUnavailable }
+func (c failingCache) GetAllKeys() (map[string]bool, error)      { return nil, errUnavailable }
+func (c failingCache) Keys() ([]string, error)                   { return nil, errUnavailable }
+func (c failingCache) Count() (int, error)                       { return 0, errUnavailable }
+
+func TestFallbackCacheUsesPrimary(t *testing.T) {
+	primary := NewInMemoryCache()
+	cache := NewFallbackCache(primary)
+
+	err := cache.Set([]byte("foo"), []byte("bar"))
+	testutil.Expect(t, err, nil)
+
+	value, err := primary.Get([]byte("foo"))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(value), "bar")
+
+	value, err = cache.Get([]byte("foo"))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(value), "bar")
+}
+
+func TestFallbackCacheKeepsValuesWhenPrimaryFails(t *testing.T) {
+	cache := NewFallbackCache(failingCache{})
+
+	err := cache.Set([]byte("foo"), []byte("bar"))
+	testutil.Expect(t, err, nil)
+
+	value, err := cache.Get([]byte("foo"))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(value), "bar")
+
+	entries, err := cache.GetAllEntries()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(entries), 1)
+	testutil.Expect(t, string(entries["foo"]), "bar")
+
+	count, err := cache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+}
+
+func TestFallbackCacheGetMissing(t *testing.T) {
+	cache := NewFallbackCache(failingCache{})
+
+	_, err := cache.Get([]byte("foo"))
+	testutil.Expect(t, err, errUnavailable)
+}
+
+func TestFallbackCacheDeleteData(t *testing.T) {
+	cache := NewFallbackCache(failingCache{})
+
+	cache.Set([]byte("foo"), []byte("bar"))
+
+	err := cache.DeleteData()
+	testutil.Expect(t, err, errUnavailable)
+
+	entries, err := cache.GetAllEntries()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(entries), 0)
+}
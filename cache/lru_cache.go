@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// LRUCache - in memory cache that holds at most 'capacity' elements, least recently used element is evicted
+// when new key is added to full cache
+type LRUCache struct {
+	capacity int
+	order    *list.List
+	elements map[string]*list.Element
+	sync.Mutex
+}
+
+// lruEntry - key and value stored in eviction list
+type lruEntry struct {
+	key   string
+	value []byte
+}
+
+// NewLRUCache - returns LRU cache with given capacity, capacity lower than 1 is treated as 1
+func NewLRUCache(capacity int) *LRUCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRUCache{
+		capacity: capacity,
+		order:    list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+// Set - adds or updates element and marks it as most recently used
+func (c *LRUCache) Set(key, value []byte) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if el, ok := c.elements[string(key)]; ok {
+		el.Value.(*lruEntry).value = value
+		c.order.MoveToFront(el)
+		return nil
+	}
+
+	c.elements[string(key)] = c.order.PushFront(&lruEntry{key: string(key), value: value})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.elements, oldest.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+// Get - returns copy of element value and marks it as most recently used, error is returned for missing (or
+// evicted) keys, same as InMemoryCache
+func (c *LRUCache) Get(key []byte) ([]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	el, ok := c.elements[string(key)]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found", key)
+	}
+	c.order.MoveToFront(el)
+
+	bytes := el.Value.(*lruEntry).value
+	value := make([]byte, len(bytes))
+	copy(value, bytes)
+	return value, nil
+}
+
+// GetAllValues - returns all values, most recently used first
+func (c *LRUCache) GetAllValues() ([][]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	values := make([][]byte, 0, c.order.Len())
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		values = append(values, el.Value.(*lruEntry).value)
+	}
+	return values, nil
+}
+
+// GetAllEntries - returns copy of all keys and values
+func (c *LRUCache) GetAllEntries() (map[string][]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	entries := make(map[string][]byte, len(c.elements))
+	for k, el := range c.elements {
+		entries[k] = el.Value.(*lruEntry).value
+	}
+	return entries, nil
+}
+
+// RecordsCount - returns number of stored elements
+func (c *LRUCache) RecordsCount() (int, error) {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.elements), nil
+}
+
+// Delete - removes element
+func (c *LRUCache) Delete(key []byte) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if el, ok := c.elements[string(key)]; ok {
+		c.order.Remove(el)
+		delete(c.elements, string(key))
+	}
+	return nil
+}
+
+// DeleteData - removes all elements
+func (c *LRUCache) DeleteData() error {
+	c.Lock()
+	c.order.Init()
+	c.elements = make(map[string]*list.Element)
+	c.Unlock()
+	return nil
+}
+
+// GetAllKeys - returns all keys
+func (c *LRUCache) GetAllKeys() (map[string]bool, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	keys := make(map[string]bool, len(c.elements))
+	for k := range c.elements {
+		keys[k] = true
+	}
+	return keys, nil
+}
+
+// Keys - returns all keys, sorted
+func (c *LRUCache) Keys() ([]string, error) {
+	c.Lock()
+	keys := make([]string, 0, len(c.elements))
+	for k := range c.elements {
+		keys = append(keys, k)
+	}
+	c.Unlock()
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Count - returns number of stored elements
+func (c *LRUCache) Count() (int, error) {
+	return c.RecordsCount()
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestLRUCacheImplementsCache(t *testing.T) {
+	var _ Cache = NewLRUCache(1)
+}
+
+func TestLRUCacheEvictsOldestEntry(t *testing.T) {
+	c := NewLRUCache(2)
+
+	testutil.Expect(t, c.Set([]byte("a"), []byte("1")), nil)
+	testutil.Expect(t, c.Set([]byte("b"), []byte("2")), nil)
+	testutil.Expect(t, c.Set([]byte("c"), []byte("3")), nil)
+
+	count, err := c.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 2)
+
+	keys, err := c.Keys()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(keys), 2)
+	testutil.Expect(t, keys[0], "b")
+	testutil.Expect(t, keys[1], "c")
+}
+
+func TestLRUCacheGetAfterEviction(t *testing.T) {
+	c := NewLRUCache(1)
+
+	c.Set([]byte("a"), []byte("1"))
+	c.Set([]byte("b"), []byte("2"))
+
+	value, err := c.Get([]byte("a"))
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, len(value), 0)
+
+	value, err = c.Get([]byte("b"))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(value), "2")
+}
+
+func TestLRUCacheSetPromotesExistingKey(t *testing.T) {
+	c := NewLRUCache(2)
+
+	c.Set([]byte("a"), []byte("1"))
+	c.Set([]byte("b"), []byte("2"))
+	// 'a' becomes most recently used, 'b' is evicted next
+	c.Set([]byte("a"), []byte("updated"))
+	c.Set([]byte("c"), []byte("3"))
+
+	value, _ := c.Get([]byte("a"))
+	testutil.Expect(t, string(value), "updated")
+
+	value, _ = c.Get([]byte("b"))
+	testutil.Expect(t, len(value), 0)
+}
+
+func TestLRUCacheGetPromotesKey(t *testing.T) {
+	c := NewLRUCache(2)
+
+	c.Set([]byte("a"), []byte("1"))
+	c.Set([]byte("b"), []byte("2"))
+	c.Get([]byte("a"))
+	c.Set([]byte("c"), []byte("3"))
+
+	value, _ := c.Get([]byte("a"))
+	testutil.Expect(t, string(value), "1")
+
+	value, _ = c.Get([]byte("b"))
+	testutil.Expect(t, len(value), 0)
+}
+
+func TestLRUCacheDelete(t *testing.T) {
+	c := NewLRUCache(2)
+
+	c.Set([]byte("a"), []byte("1"))
+	c.Set([]byte("b"), []byte("2"))
+	testutil.Expect(t, c.Delete([]byte("a")), nil)
+
+	entries, err := c.GetAllEntries()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(entries), 1)
+	testutil.Expect(t, string(entries["b"]), "2")
+
+	testutil.Expect(t, c.DeleteData(), nil)
+	count, _ := c.Count()
+	testutil.Expect(t, count, 0)
+}
@@ -1,6 +1,8 @@
 package cache
 
 import (
+	"fmt"
+	"sort"
 	"sync"
 )
 
@@ -23,12 +25,16 @@ func (c *InMemoryCache) Set(key, value []byte) (err error) {
 	return
 }
 
+// Get - returns copy of element value, error is returned for missing keys same as BoltCache does
 func (c *InMemoryCache) Get(key []byte) (value []byte, err error) {
 	c.RLock()
-	bytes := c.elements[string(key)]
+	defer c.RUnlock()
+	bytes, ok := c.elements[string(key)]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found", key)
+	}
 	value = make([]byte, len(bytes), len(bytes))
 	copy(value, bytes)
-	c.RUnlock()
 	return
 }
 
@@ -78,6 +84,23 @@ func (c *InMemoryCache) GetAllKeys() (keys map[string]bool, err error) {
 	return
 }
 
+// Keys - returns all keys, sorted
+func (c *InMemoryCache) Keys() ([]string, error) {
+	c.RLock()
+	keys := make([]string, 0, len(c.elements))
+	for k := range c.elements {
+		keys = append(keys, k)
+	}
+	c.RUnlock()
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Count - returns number of stored elements
+func (c *InMemoryCache) Count() (int, error) {
+	return c.RecordsCount()
+}
+
 func (c *InMemoryCache) Delete(key []byte) error {
 	c.Lock()
 	delete(c.elements, string(key))
@@ -11,10 +11,8 @@ func TestEmptyByDefault(t *testing.T) {
 
 	expectedKey := []byte{'k'}
 
-	if actualValue, err := cache.Get(expectedKey); err != nil {
-		t.Fatalf("err: %v", err)
-	} else if len(actualValue) != 0 {
-		t.Fatal("Cache should be empty by default")
+	if actualValue, err := cache.Get(expectedKey); err == nil {
+		t.Fatalf("Cache should be empty by default, got %v", actualValue)
 	}
 
 	if actualValue, err := cache.GetAllEntries(); err != nil {
@@ -155,6 +153,22 @@ func TestGetRecordCount(t *testing.T) {
 	}
 }
 
+func TestKeysAndCountMem(t *testing.T) {
+	cache := NewInMemoryCache()
+
+	cache.Set([]byte("b"), []byte("B"))
+	cache.Set([]byte("a"), []byte("A"))
+
+	keys, err := cache.Keys()
+	if err != nil || len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Fatalf("Expected sorted keys [a b] but got %v (error: %v)", keys, err)
+	}
+
+	if count, _ := cache.Count(); count != 2 {
+		t.Fatalf("Expected %v records but got %v", 2, count)
+	}
+}
+
 func TestDeleteData(t *testing.T) {
 	cache := NewInMemoryCache()
 
@@ -186,10 +200,8 @@ func TestDeleteKey(t *testing.T) {
 
 	cache.Delete([]byte(expectedKey1))
 
-	if value, err := cache.Get(expectedKey1); err != nil {
-		t.Fatalf("Error %v", err)
-	} else if len(value) != 0 {
-		t.Fatalf("Expected nil value but got %v", value)
+	if value, err := cache.Get(expectedKey1); err == nil {
+		t.Fatalf("Expected error for deleted key but got value %v", value)
 	} else if value, _ := cache.RecordsCount(); value != 1 {
 		t.Fatalf("Expected %v records but got %v", 1, cache.RecordsCount)
 	}
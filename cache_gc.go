@@ -0,0 +1,104 @@
+package hoverfly
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// trackUsage - updates payload use count and last access time when they are needed by cache garbage collection.
+// Updates of the same payload are serialized and applied to the stored payload, so every concurrent use is counted.
+func (d *Hoverfly) trackUsage(cfg *Configuration, key string, payload *models.Payload) {
+	if payload.MaxUseCount == 0 && cfg.CacheIdleTTL == 0 {
+		return
+	}
+
+	unlock := d.usageLocks.lock(key)
+	defer unlock()
+
+	bts, err := d.RequestCache.Get([]byte(key))
+	if err != nil {
+		// removed since it was served
+		return
+	}
+
+	tracked, err := models.NewPayloadFromBytes(bts)
+	if err == nil {
+		tracked.UseCount++
+		tracked.LastAccessedAt = time.Now()
+		bts, err = tracked.Encode()
+	}
+	if err == nil {
+		err = d.RequestCache.Set([]byte(key), bts)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Warn("Failed to update payload usage")
+	}
+}
+
+// isGarbage - checks whether payload is used up, expired or idle
+func (d *Hoverfly) isGarbage(payload *models.Payload, now time.Time) bool {
+	if payload.MaxUseCount > 0 && payload.UseCount >= payload.MaxUseCount {
+		return true
+	}
+
+	// imported payloads and payloads stored before timestamps were introduced have no creation time,
+	// they can only become idle after being served
+	if d.Cfg.CacheEntryTTL > 0 && !payload.CreatedAt.IsZero() && now.Sub(payload.CreatedAt) > d.Cfg.CacheEntryTTL {
+		return true
+	}
+
+	lastAccess := payload.LastAccessedAt
+	if lastAccess.IsZero() {
+		lastAccess = payload.CreatedAt
+	}
+
+	return d.Cfg.CacheIdleTTL > 0 && !lastAccess.IsZero() && now.Sub(lastAccess) > d.Cfg.CacheIdleTTL
+}
+
+// CollectGarbage - removes used up, expired and idle payloads from request cache, returns how many were removed
+func (d *Hoverfly) CollectGarbage() (int, error) {
+	entries, err := d.RequestCache.GetAllEntries()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	removed := 0
+
+	for key, v := range entries {
+		payload, err := models.NewPayloadFromBytes(v)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"key":   key,
+			}).Warn("Failed to decode payload during cache garbage collection")
+			continue
+		}
+
+		if !d.isGarbage(payload, now) {
+			continue
+		}
+
+		if err := d.RequestCache.Delete([]byte(key)); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"key":   key,
+			}).Warn("Failed to remove payload during cache garbage collection")
+			continue
+		}
+		removeRequestBodyFile(payload.RequestBodyFile)
+		removed++
+	}
+
+	log.WithFields(log.Fields{
+		"total":   len(entries),
+		"removed": removed,
+	}).Info("cache garbage collection complete")
+
+	return removed, nil
+}
@@ -0,0 +1,121 @@
+package hoverfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func storePayload(t *testing.T, d *Hoverfly, path string, payload models.Payload) string {
+	payload.Request = models.RequestDetails{Method: "GET", Destination: "gc.com", Path: path}
+	payload.Response = models.ResponseDetails{Status: 200, Body: path}
+
+	bts, err := payload.Encode()
+	testutil.Expect(t, err, nil)
+
+	key := payload.Id()
+	testutil.Expect(t, d.RequestCache.Set([]byte(key), bts), nil)
+	return key
+}
+
+func TestCollectGarbage(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.CacheEntryTTL = time.Hour
+	dbClient.Cfg.CacheIdleTTL = time.Minute
+
+	now := time.Now()
+
+	storePayload(t, dbClient, "/used", models.Payload{MaxUseCount: 1, UseCount: 1, CreatedAt: now})
+	storePayload(t, dbClient, "/expired", models.Payload{CreatedAt: now.Add(-2 * time.Hour), LastAccessedAt: now})
+	storePayload(t, dbClient, "/idle", models.Payload{CreatedAt: now.Add(-10 * time.Minute)})
+	storePayload(t, dbClient, "/legacy", models.Payload{})
+	keep := storePayload(t, dbClient, "/fresh", models.Payload{MaxUseCount: 2, UseCount: 1, CreatedAt: now.Add(-10 * time.Minute), LastAccessedAt: now})
+
+	removed, err := dbClient.CollectGarbage()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, removed, 3)
+
+	keys, err := dbClient.RequestCache.GetAllKeys()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(keys), 2)
+	testutil.Expect(t, keys[keep], true)
+}
+
+func TestSimulateTracksUsage(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	storePayload(t, dbClient, "/single", models.Payload{MaxUseCount: 1, CreatedAt: time.Now()})
+
+	req, err := http.NewRequest("GET", "http://gc.com/single", nil)
+	testutil.Expect(t, err, nil)
+
+	resp := dbClient.getResponse(req)
+	testutil.Expect(t, resp.StatusCode, 200)
+
+	removed, err := dbClient.CollectGarbage()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, removed, 1)
+}
+
+func TestConcurrentUsesAreAllCounted(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	key := storePayload(t, dbClient, "/shared", models.Payload{MaxUseCount: 100, CreatedAt: time.Now()})
+	bts, err := dbClient.RequestCache.Get([]byte(key))
+	testutil.Expect(t, err, nil)
+	payload, err := models.NewPayloadFromBytes(bts)
+	testutil.Expect(t, err, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dbClient.trackUsage(dbClient.Cfg, key, payload)
+		}()
+	}
+	wg.Wait()
+
+	bts, err = dbClient.RequestCache.Get([]byte(key))
+	testutil.Expect(t, err, nil)
+	tracked, err := models.NewPayloadFromBytes(bts)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, tracked.UseCount, 20)
+}
+
+func TestCacheGCHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	storePayload(t, dbClient, "/used", models.Payload{MaxUseCount: 1, UseCount: 1})
+
+	req, err := http.NewRequest("POST", "/api/cache/gc", nil)
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusAccepted)
+
+	// collection runs in the background
+	count := 1
+	for i := 0; i < 100 && count > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		count, err = dbClient.RequestCache.RecordsCount()
+		testutil.Expect(t, err, nil)
+	}
+	testutil.Expect(t, count, 0)
+}
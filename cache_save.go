@@ -0,0 +1,195 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// cache save formats
+const (
+	// CacheSaveFormatJSON - same format as 'GET /api/records'
+	CacheSaveFormatJSON = "json"
+	// CacheSaveFormatCompressed - same format as ExportCompressed, response bodies are gzip compressed
+	CacheSaveFormatCompressed = "compressed"
+)
+
+var (
+	errCacheSaveDisabled = errors.New("saving cache to a file is disabled, set cache save directory to enable it")
+	errCacheSaveOutside  = errors.New("path is outside of cache save directory")
+)
+
+// SaveCache - writes all records to given file, file is replaced only when all records were written. Relative paths
+// are resolved from CacheSaveDir, files outside of it can't be written. Returns number of written records.
+func (d *Hoverfly) SaveCache(path, format string) (int, error) {
+	path, err := d.Cfg.cacheSavePath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var convert func(*models.Payload) (*models.PayloadView, error)
+	switch format {
+	case "", CacheSaveFormatJSON:
+		convert = plainPayloadView
+	case CacheSaveFormatCompressed:
+		convert = compressedPayloadView
+	default:
+		return 0, fmt.Errorf("unknown format '%s', supported formats are '%s' and '%s'", format, CacheSaveFormatJSON, CacheSaveFormatCompressed)
+	}
+
+	// temporary file is in the same directory so that it can be renamed
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+
+	count, err := d.writeRecords(f, convert)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return 0, err
+	}
+
+	log.WithFields(log.Fields{
+		"path":    path,
+		"format":  format,
+		"records": count,
+	}).Info("cache saved")
+
+	return count, nil
+}
+
+// cacheSavePath - resolves path within CacheSaveDir, paths that leave it with '..' or through symbolic links are
+// refused
+func (c *Configuration) cacheSavePath(path string) (string, error) {
+	if c.CacheSaveDir == "" {
+		return "", errCacheSaveDisabled
+	}
+
+	dir, err := filepath.Abs(c.CacheSaveDir)
+	if err != nil {
+		return "", err
+	}
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path = filepath.Clean(path)
+
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(dir, parent); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errCacheSaveOutside
+	}
+	path = filepath.Join(parent, filepath.Base(path))
+
+	// saved file replaces the link, but links aren't expected in the directory
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return "", errCacheSaveOutside
+	}
+	return path, nil
+}
+
+// writeRecords - writes records one by one in the same format as 'GET /api/records', so that the whole cache doesn't
+// have to be kept in memory
+func (d *Hoverfly) writeRecords(w io.Writer, convert func(*models.Payload) (*models.PayloadView, error)) (int, error) {
+	keys, err := d.RequestCache.Keys()
+	if err != nil {
+		return 0, err
+	}
+
+	// deduplicated response bodies are written once, in 'bodies' after the records
+	_, dedup := d.RequestCache.(*DedupCache)
+	bodies := make(map[string]string)
+
+	count := 0
+	for _, key := range keys {
+		v, err := d.RequestCache.Get([]byte(key))
+		if err != nil {
+			// removed since keys were listed
+			continue
+		}
+
+		payload, err := models.NewPayloadFromBytes(v)
+		if err != nil {
+			return count, fmt.Errorf("failed to decode record '%s': %s", key, err.Error())
+		}
+
+		// records stored before minification was enabled
+		d.Cfg.minifyJSONBodies(payload)
+
+		view, err := convert(payload)
+		if err != nil {
+			return count, fmt.Errorf("failed to convert record '%s': %s", key, err.Error())
+		}
+		if dedup {
+			shareResponseBody(view, bodies)
+		}
+
+		b, err := json.Marshal(view)
+		if err != nil {
+			return count, err
+		}
+
+		separator := ","
+		if count == 0 {
+			separator = `{"data":[`
+		}
+		if _, err := io.WriteString(w, separator); err != nil {
+			return count, err
+		}
+		if _, err := w.Write(b); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	end := "]}"
+	if count == 0 {
+		end = `{"data":null}`
+	} else if len(bodies) > 0 {
+		b, err := json.Marshal(bodies)
+		if err != nil {
+			return count, err
+		}
+		end = `],"bodies":` + string(b) + "}"
+	}
+	_, err = io.WriteString(w, end)
+	return count, err
+}
+
+func plainPayloadView(payload *models.Payload) (*models.PayloadView, error) {
+	return payload.ConvertToPayloadView(), nil
+}
+
+func payloadViewWithHistory(payload *models.Payload) (*models.PayloadView, error) {
+	return payload.ConvertToPayloadViewWithHistory(), nil
+}
+
+func compressedPayloadView(payload *models.Payload) (*models.PayloadView, error) {
+	view := payload.ConvertToPayloadView()
+	if err := compressPayloadView(view); err != nil {
+		return nil, err
+	}
+	return view, nil
+}
@@ -0,0 +1,212 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestSaveCacheJSON(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.RequestCache.Set([]byte("one"), encodedPayload(t, "/one", "first"))
+	dbClient.RequestCache.Set([]byte("two"), encodedPayload(t, "/two", "second"))
+
+	dir, err := ioutil.TempDir("", "cache-save")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	dbClient.Cfg.CacheSaveDir = dir
+	path := filepath.Join(dir, "simulation.json")
+
+	count, err := dbClient.SaveCache(path, CacheSaveFormatJSON)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 2)
+
+	bts, err := ioutil.ReadFile(path)
+	testutil.Expect(t, err, nil)
+	var data models.PayloadViewData
+	testutil.Expect(t, json.Unmarshal(bts, &data), nil)
+	testutil.Expect(t, len(data.Data), 2)
+	testutil.Expect(t, data.Data[0].Response.Body, "first")
+	testutil.Expect(t, data.Data[1].Response.Body, "second")
+
+	// only saved file is left in the directory
+	files, err := ioutil.ReadDir(dir)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(files), 1)
+
+	// saved file can be imported
+	testutil.Expect(t, dbClient.RequestCache.DeleteData(), nil)
+	imported, err := dbClient.ImportSimulation(bytes.NewReader(bts))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, imported, 2)
+}
+
+func TestSaveCacheCompressed(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.RequestCache.Set([]byte("one"), encodedPayload(t, "/one", "first"))
+
+	f, err := ioutil.TempFile("", "cache-save")
+	testutil.Expect(t, err, nil)
+	f.Close()
+	defer os.Remove(f.Name())
+	dbClient.Cfg.CacheSaveDir = os.TempDir()
+
+	count, err := dbClient.SaveCache(f.Name(), CacheSaveFormatCompressed)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+
+	saved, err := os.Open(f.Name())
+	testutil.Expect(t, err, nil)
+	defer saved.Close()
+
+	testutil.Expect(t, dbClient.RequestCache.DeleteData(), nil)
+	testutil.Expect(t, dbClient.ImportCompressed(saved), nil)
+
+	count, err = dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+}
+
+func TestSaveCacheEmpty(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	f, err := ioutil.TempFile("", "cache-save")
+	testutil.Expect(t, err, nil)
+	f.Close()
+	defer os.Remove(f.Name())
+	dbClient.Cfg.CacheSaveDir = os.TempDir()
+
+	count, err := dbClient.SaveCache(f.Name(), "")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 0)
+
+	bts, err := ioutil.ReadFile(f.Name())
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(bts), `{"data":null}`)
+}
+
+func TestSaveCacheInvalidRequests(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	_, err := dbClient.SaveCache(filepath.Join(os.TempDir(), "simulation.json"), CacheSaveFormatJSON)
+	testutil.Expect(t, err, errCacheSaveDisabled)
+
+	dbClient.Cfg.CacheSaveDir = os.TempDir()
+	_, err = dbClient.SaveCache(filepath.Join(os.TempDir(), "simulation.json"), "yaml")
+	testutil.Refute(t, err, nil)
+
+	_, err = dbClient.SaveCache(filepath.Join(os.TempDir(), "does", "not", "exist", "simulation.json"), CacheSaveFormatJSON)
+	testutil.Refute(t, err, nil)
+}
+
+func TestSaveCacheOutsideOfDirectory(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	root, err := ioutil.TempDir("", "cache-save")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(root)
+	dir := filepath.Join(root, "saved")
+	testutil.Expect(t, os.Mkdir(dir, 0755), nil)
+	testutil.Expect(t, os.Symlink(root, filepath.Join(dir, "up")), nil)
+	testutil.Expect(t, os.Symlink(filepath.Join(root, "target.json"), filepath.Join(dir, "link.json")), nil)
+	dbClient.Cfg.CacheSaveDir = dir
+
+	for _, path := range []string{"../simulation.json", filepath.Join(root, "simulation.json"), "up/simulation.json", "link.json", "/etc/passwd"} {
+		_, err := dbClient.SaveCache(path, CacheSaveFormatJSON)
+		testutil.Expect(t, err, errCacheSaveOutside)
+	}
+
+	files, err := ioutil.ReadDir(root)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(files), 1)
+
+	// relative paths are resolved from the directory
+	_, err = dbClient.SaveCache("simulation.json", CacheSaveFormatJSON)
+	testutil.Expect(t, err, nil)
+	_, err = os.Stat(filepath.Join(dir, "simulation.json"))
+	testutil.Expect(t, err, nil)
+}
+
+func TestCacheSaveHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	dbClient.RequestCache.Set([]byte("one"), encodedPayload(t, "/one", "first"))
+
+	f, err := ioutil.TempFile("", "cache-save")
+	testutil.Expect(t, err, nil)
+	f.Close()
+	defer os.Remove(f.Name())
+	dbClient.Cfg.CacheSaveDir = os.TempDir()
+
+	body, _ := json.Marshal(cacheSaveRequest{Path: f.Name(), Format: CacheSaveFormatJSON})
+	req, err := http.NewRequest("POST", "/api/cache/save", bytes.NewReader(body))
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var response cacheSaveResponse
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &response), nil)
+	testutil.Expect(t, response.Count, 1)
+	testutil.Expect(t, response.Path, f.Name())
+
+	// records are in the file, not in the response
+	testutil.Expect(t, bytes.Contains(rec.Body.Bytes(), []byte("first")), false)
+	bts, err := ioutil.ReadFile(f.Name())
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, bytes.Contains(bts, []byte("first")), true)
+}
+
+func TestCacheSaveHandlerBadRequest(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+	dbClient.Cfg.CacheSaveDir = os.TempDir()
+
+	for _, body := range []string{`{}`, `not json`, `{"path": "simulation.json", "format": "yaml"}`} {
+		req, err := http.NewRequest("POST", "/api/cache/save", bytes.NewBufferString(body))
+		testutil.Expect(t, err, nil)
+
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		testutil.Expect(t, rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestCacheSaveHandlerForbidden(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	for _, dir := range []string{"", os.TempDir()} {
+		dbClient.Cfg.CacheSaveDir = dir
+
+		req, err := http.NewRequest("POST", "/api/cache/save", bytes.NewBufferString(`{"path": "../simulation.json"}`))
+		testutil.Expect(t, err, nil)
+
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		testutil.Expect(t, rec.Code, http.StatusForbidden)
+	}
+}
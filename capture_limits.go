@@ -0,0 +1,92 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// TruncatedContentWarning - Content-Warning header of responses served from records with truncated bodies
+const TruncatedContentWarning = "truncated"
+
+// streamedBody - body that was read only up to capture limit, read part is followed by the unread rest so that it
+// can be sent on without buffering it whole
+type streamedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// limitBody - reads body up to limit, stored is what should be captured. When body is longer than limit, stored
+// is its prefix and streamed has to be sent on instead of the original body. Whole body is read when limit is 0.
+func limitBody(body io.ReadCloser, limit int64) (stored []byte, streamed io.ReadCloser, err error) {
+	if limit <= 0 {
+		stored, err = ioutil.ReadAll(body)
+		return stored, nil, err
+	}
+
+	read, err := ioutil.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil || int64(len(read)) <= limit {
+		return read, nil, err
+	}
+	return read[:limit], &streamedBody{Reader: io.MultiReader(bytes.NewReader(read), body), Closer: body}, nil
+}
+
+// truncateBody - returns prefix of body that fits into limit, limit 0 keeps whole body
+func truncateBody(body []byte, limit int64) ([]byte, bool) {
+	if limit <= 0 || int64(len(body)) <= limit {
+		return body, false
+	}
+	return body[:limit], true
+}
+
+// extractLimitedBody - same as extractBody, only the first MaxResponseBodyBytes are kept in memory and response body
+// is streamed to the client when it is longer
+func (d *Hoverfly) extractLimitedBody(resp *http.Response) ([]byte, bool, error) {
+	cfg := d.requestConfig(resp.Request)
+	limit := cfg.MaxResponseBodyBytes
+	if limit <= 0 || resp.Body == nil {
+		body, err := extractBody(resp)
+		return body, false, err
+	}
+
+	stored, streamed, err := limitBody(resp.Body, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	if streamed != nil {
+		resp.Body = streamed
+		return stored, true, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(stored))
+	return stored, false, nil
+}
+
+// bodyTruncation - sizes bodies were truncated at, nil when both were captured fully
+func (c *Configuration) bodyTruncation(req *http.Request, requestTruncated, responseTruncated bool) *models.BodyTruncation {
+	if !requestTruncated && !responseTruncated {
+		return nil
+	}
+
+	truncation := &models.BodyTruncation{}
+	if requestTruncated {
+		truncation.RequestBody = c.MaxRequestBodyBytes
+	}
+	if responseTruncated {
+		truncation.ResponseBody = c.MaxResponseBodyBytes
+	}
+
+	log.WithFields(log.Fields{
+		"destination":  req.Host,
+		"path":         req.URL.Path,
+		"method":       req.Method,
+		"requestBody":  truncation.RequestBody,
+		"responseBody": truncation.ResponseBody,
+	}).Warn("captured body exceeds size limit, only its prefix is stored")
+
+	return truncation
+}
@@ -0,0 +1,115 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// echoUpstream - responds with the request body it received
+func echoUpstream() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+}
+
+func captureWithBody(t *testing.T, d *Hoverfly, target, body string) string {
+	req, err := http.NewRequest("POST", target+"/upload", bytes.NewBufferString(body))
+	testutil.Expect(t, err, nil)
+
+	resp, err := d.captureRequest(req)
+	testutil.Expect(t, err, nil)
+	respBody, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	return string(respBody)
+}
+
+func storedPayload(t *testing.T, d *Hoverfly) *models.Payload {
+	values, err := d.RequestCache.GetAllValues()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(values), 1)
+	payload, err := models.NewPayloadFromBytes(values[0])
+	testutil.Expect(t, err, nil)
+	return payload
+}
+
+func TestCaptureBodyUnderLimitStoredFully(t *testing.T) {
+	upstream := echoUpstream()
+	defer upstream.Close()
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = http.DefaultClient
+	dbClient.Cfg.MaxRequestBodyBytes = 10
+	dbClient.Cfg.MaxResponseBodyBytes = 10
+
+	testutil.Expect(t, captureWithBody(t, dbClient, upstream.URL, "0123456789"), "0123456789")
+
+	payload := storedPayload(t, dbClient)
+	testutil.Expect(t, payload.Request.Body, "0123456789")
+	testutil.Expect(t, payload.Response.Body, "0123456789")
+	testutil.Expect(t, payload.TruncatedAt == nil, true)
+}
+
+func TestCaptureBodyOverLimitStoresPrefix(t *testing.T) {
+	upstream := echoUpstream()
+	defer upstream.Close()
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = http.DefaultClient
+	dbClient.Cfg.MaxRequestBodyBytes = 4
+	dbClient.Cfg.MaxResponseBodyBytes = 6
+
+	// upstream and client get whole bodies
+	body := strings.Repeat("abcdefghij", 100)
+	testutil.Expect(t, captureWithBody(t, dbClient, upstream.URL, body), body)
+
+	payload := storedPayload(t, dbClient)
+	testutil.Expect(t, payload.Request.Body, "abcd")
+	testutil.Expect(t, payload.Response.Body, "abcdef")
+	testutil.Expect(t, payload.TruncatedAt != nil, true)
+	testutil.Expect(t, payload.TruncatedAt.RequestBody, int64(4))
+	testutil.Expect(t, payload.TruncatedAt.ResponseBody, int64(6))
+
+	// stored response is played back as it is, the same request matches the truncated record
+	req, err := http.NewRequest("POST", upstream.URL+"/upload", bytes.NewBufferString(body))
+	testutil.Expect(t, err, nil)
+	resp := dbClient.getResponse(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, resp.Header.Get("Content-Warning"), TruncatedContentWarning)
+	simulated, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(simulated), "abcdef")
+}
+
+func TestCaptureBodyZeroLimitIsUnlimited(t *testing.T) {
+	upstream := echoUpstream()
+	defer upstream.Close()
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = http.DefaultClient
+
+	body := strings.Repeat("abcdefghij", 100)
+	testutil.Expect(t, captureWithBody(t, dbClient, upstream.URL, body), body)
+
+	payload := storedPayload(t, dbClient)
+	testutil.Expect(t, payload.Request.Body, body)
+	testutil.Expect(t, payload.Response.Body, body)
+	testutil.Expect(t, payload.TruncatedAt == nil, true)
+
+	req, err := http.NewRequest("POST", upstream.URL+"/upload", bytes.NewBufferString(body))
+	testutil.Expect(t, err, nil)
+	resp := dbClient.getResponse(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, resp.Header.Get("Content-Warning"), "")
+}
@@ -0,0 +1,70 @@
+package hoverfly
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// doCaptureRequest - forwards captured request, requests that failed to connect to upstream are sent again up to
+// Cfg.CaptureRetries times with exponential backoff. Body is restored before every attempt.
+func (d *Hoverfly) doCaptureRequest(req *http.Request, body []byte) (*http.Request, *http.Response, error) {
+	cfg := d.requestConfig(req)
+	for attempt := 0; ; attempt++ {
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		forwarded, resp, err := d.doRequest(req)
+		if err == nil || !isDialError(err) || attempt >= cfg.CaptureRetries {
+			return forwarded, resp, err
+		}
+
+		wait := cfg.retryBackoff(attempt)
+		log.WithFields(log.Fields{
+			"error":   err.Error(),
+			"host":    req.Host,
+			"attempt": attempt + 1,
+			"wait":    wait.String(),
+		}).Warn("failed to connect to upstream, retrying capture")
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, nil, err
+		}
+	}
+}
+
+// retryBackoff - wait before retry that follows given attempt, RetryBackoffBase is doubled after every attempt and
+// capped at RetryBackoffMax
+func (c *Configuration) retryBackoff(attempt int) time.Duration {
+	wait := c.RetryBackoffBase
+	if wait <= 0 {
+		return 0
+	}
+	for i := 0; i < attempt; i++ {
+		wait *= 2
+		if c.RetryBackoffMax > 0 && wait >= c.RetryBackoffMax {
+			break
+		}
+	}
+	if c.RetryBackoffMax > 0 && wait > c.RetryBackoffMax {
+		wait = c.RetryBackoffMax
+	}
+	return wait
+}
+
+// isDialError - connection to upstream couldn't be opened, i.e. it was refused or host is unreachable. Dial error
+// can be wrapped in another network error, i.e. when connecting to upstream proxy.
+func isDialError(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if opErr, ok := err.(*net.OpError); ok && opErr.Op == "dial" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package hoverfly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// refusingTransport - first failures connections are refused, the rest connect to given address
+func refusingTransport(address string, failures int32) (*http.Transport, *int32) {
+	var dials int32
+	return &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) <= failures {
+				return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+			}
+			return net.Dial(network, address)
+		},
+	}, &dials
+}
+
+func TestCaptureRetriesConnectionRefused(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	transport, dials := refusingTransport(strings.TrimPrefix(server.URL, "http://"), 2)
+	dbClient.HTTP = &http.Client{Transport: transport}
+	dbClient.Cfg.CaptureRetries = 3
+	dbClient.Cfg.RetryBackoffBase = 10 * time.Millisecond
+
+	req, err := http.NewRequest("POST", "http://example.com/retry", strings.NewReader("request body"))
+	testutil.Expect(t, err, nil)
+
+	resp, err := dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, atomic.LoadInt32(dials), int32(3))
+
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+}
+
+func TestCaptureRetriesExhausted(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	transport, dials := refusingTransport(strings.TrimPrefix(server.URL, "http://"), 10)
+	dbClient.HTTP = &http.Client{Transport: transport}
+	dbClient.Cfg.CaptureRetries = 2
+	dbClient.Cfg.RetryBackoffBase = time.Millisecond
+
+	req, err := http.NewRequest("GET", "http://example.com/retry", nil)
+	testutil.Expect(t, err, nil)
+
+	_, err = dbClient.captureRequest(req)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, isDialError(err), true)
+	testutil.Expect(t, atomic.LoadInt32(dials), int32(3))
+}
+
+func TestCaptureDoesNotRetryServerErrors(t *testing.T) {
+	server, dbClient := testTools(503, `unavailable`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	transport, dials := refusingTransport(strings.TrimPrefix(server.URL, "http://"), 0)
+	dbClient.HTTP = &http.Client{Transport: transport}
+	dbClient.Cfg.CaptureRetries = 3
+
+	req, err := http.NewRequest("GET", "http://example.com/retry", nil)
+	testutil.Expect(t, err, nil)
+
+	resp, err := dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, http.StatusServiceUnavailable)
+	testutil.Expect(t, atomic.LoadInt32(dials), int32(1))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	cfg := InitSettings()
+	cfg.RetryBackoffBase = 100 * time.Millisecond
+	cfg.RetryBackoffMax = time.Second
+
+	for attempt, expected := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		testutil.Expect(t, cfg.retryBackoff(attempt), expected*time.Millisecond)
+	}
+	// doubling stops once maximum is reached
+	testutil.Expect(t, cfg.retryBackoff(100), time.Second)
+
+	cfg.RetryBackoffBase = 0
+	testutil.Expect(t, cfg.retryBackoff(3), time.Duration(0))
+}
+
+func TestIsDialError(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	testutil.Expect(t, isDialError(refused), true)
+	testutil.Expect(t, isDialError(withErrorCode(ErrorCodeUpstreamUnreachable, fmt.Errorf("wrapped: %w", refused))), true)
+	testutil.Expect(t, isDialError(&net.OpError{Op: "proxyconnect", Net: "tcp", Err: refused}), true)
+	testutil.Expect(t, isDialError(&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}), false)
+	testutil.Expect(t, isDialError(errors.New("dial tcp: connection refused")), false)
+}
@@ -0,0 +1,88 @@
+package hoverfly
+
+import (
+	"io"
+	"net/http"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// captureBuffer - keeps up to limit bytes written to it, everything when limit is 0. It never fails so that
+// captured body doesn't affect what client receives.
+type captureBuffer struct {
+	limit     int64
+	body      []byte
+	truncated bool
+}
+
+func (b *captureBuffer) Write(p []byte) (int, error) {
+	if b.limit <= 0 {
+		b.body = append(b.body, p...)
+		return len(p), nil
+	}
+	if left := b.limit - int64(len(b.body)); left < int64(len(p)) {
+		b.body = append(b.body, p[:left]...)
+		b.truncated = true
+	} else {
+		b.body = append(b.body, p...)
+	}
+	return len(p), nil
+}
+
+// teeCaptureBody - response body that is delivered to the client as it is read from upstream, read bytes are
+// copied into capture buffer and stored once the whole body was read. Bodies that are closed before they were
+// read to the end aren't stored.
+type teeCaptureBody struct {
+	body     io.ReadCloser
+	reader   io.Reader
+	captured *captureBuffer
+	save     func(body []byte, truncated bool)
+
+	once sync.Once
+}
+
+func newTeeCaptureBody(body io.ReadCloser, limit int64, save func(body []byte, truncated bool)) *teeCaptureBody {
+	captured := &captureBuffer{limit: limit}
+	return &teeCaptureBody{
+		body:     body,
+		reader:   io.TeeReader(body, captured),
+		captured: captured,
+		save:     save,
+	}
+}
+
+func (t *teeCaptureBody) Read(p []byte) (int, error) {
+	n, err := t.reader.Read(p)
+	if err == io.EOF {
+		t.once.Do(func() {
+			t.save(t.captured.body, t.captured.truncated)
+		})
+	}
+	return n, err
+}
+
+func (t *teeCaptureBody) Close() error {
+	t.once.Do(func() {
+		log.WithFields(log.Fields{
+			"read": len(t.captured.body),
+			"mode": CaptureMode,
+		}).Warn("response body was closed before it was read to the end, it is not captured")
+	})
+	return t.body.Close()
+}
+
+// teeCapture - replaces response body with one that stores request and response once the client read it, Trailer
+// of response is known by then
+func (d *Hoverfly) teeCapture(req *http.Request, reqBody []byte, reqTruncated bool, resp *http.Response) {
+	cfg := d.requestConfig(req)
+	resp.Body = newTeeCaptureBody(resp.Body, cfg.MaxResponseBodyBytes, func(body []byte, truncated bool) {
+		storedResp, storedBody := d.decompressedForStorage(resp, body)
+		d.saveTruncated(req, reqBody, storedResp, storedBody, cfg.bodyTruncation(req, reqTruncated, truncated))
+	})
+}
+
+// canTeeResponse - status rewrites need the whole body before response can be returned
+func (c *Configuration) canTeeResponse() bool {
+	return c.StreamResponseBody && len(c.ResponseCodeRewrites) == 0
+}
@@ -0,0 +1,95 @@
+package hoverfly
+
+import (
+	"bytes"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestTeeCaptureStoresDeliveredBody(t *testing.T) {
+	upstream := echoUpstream()
+	defer upstream.Close()
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = http.DefaultClient
+	dbClient.Cfg.StreamResponseBody = true
+
+	// binary body that is bigger than read buffers
+	body := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(body)
+
+	delivered := captureWithBody(t, dbClient, upstream.URL, string(body))
+	testutil.Expect(t, bytes.Equal([]byte(delivered), body), true)
+
+	payload := storedPayload(t, dbClient)
+	testutil.Expect(t, bytes.Equal([]byte(payload.Response.Body), []byte(delivered)), true)
+	testutil.Expect(t, payload.TruncatedAt == nil, true)
+}
+
+func TestTeeCaptureStoresLimitedBody(t *testing.T) {
+	upstream := echoUpstream()
+	defer upstream.Close()
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = http.DefaultClient
+	dbClient.Cfg.StreamResponseBody = true
+	dbClient.Cfg.MaxResponseBodyBytes = 6
+
+	body := strings.Repeat("abcdefghij", 100)
+	testutil.Expect(t, captureWithBody(t, dbClient, upstream.URL, body), body)
+
+	payload := storedPayload(t, dbClient)
+	testutil.Expect(t, payload.Response.Body, "abcdef")
+	testutil.Expect(t, payload.TruncatedAt != nil, true)
+	testutil.Expect(t, payload.TruncatedAt.ResponseBody, int64(6))
+}
+
+func TestTeeCaptureBodyClosedBeforeEndNotStored(t *testing.T) {
+	upstream := echoUpstream()
+	defer upstream.Close()
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = http.DefaultClient
+	dbClient.Cfg.StreamResponseBody = true
+
+	req, err := http.NewRequest("POST", upstream.URL+"/upload", bytes.NewBufferString(strings.Repeat("a", 100000)))
+	testutil.Expect(t, err, nil)
+
+	resp, err := dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+	_, err = resp.Body.Read(make([]byte, 10))
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+
+	values, err := dbClient.RequestCache.GetAllValues()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(values), 0)
+}
+
+func TestTeeCaptureIgnoredWithResponseCodeRewrites(t *testing.T) {
+	cfg := Configuration{StreamResponseBody: true}
+	testutil.Expect(t, cfg.canTeeResponse(), true)
+
+	cfg.ResponseCodeRewrites = []CodeRewriteRule{{}}
+	testutil.Expect(t, cfg.canTeeResponse(), false)
+}
+
+func TestCaptureBufferKeepsLimit(t *testing.T) {
+	buffer := &captureBuffer{limit: 4}
+	n, err := buffer.Write([]byte("abc"))
+	testutil.Expect(t, n, 3)
+	testutil.Expect(t, err, nil)
+	n, err = buffer.Write([]byte("def"))
+	testutil.Expect(t, n, 3)
+	testutil.Expect(t, err, nil)
+
+	testutil.Expect(t, string(buffer.body), "abcd")
+	testutil.Expect(t, buffer.truncated, true)
+}
@@ -0,0 +1,100 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// CBOR major types (RFC 7049)
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMapType  = 5
+)
+
+// cborEntry - key and value of cborMap
+type cborEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// cborMap - CBOR map, entries are encoded in given order so that CTAP2 canonical order can be kept
+type cborMap []cborEntry
+
+// cborEncode - encodes int, uint32, string, []byte, []interface{} and cborMap values
+func cborEncode(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := cborWrite(buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func cborWrite(buf *bytes.Buffer, v interface{}) error {
+	switch value := v.(type) {
+	case int:
+		if value < 0 {
+			cborWriteHeader(buf, cborNegative, uint64(-1-value))
+		} else {
+			cborWriteHeader(buf, cborUnsigned, uint64(value))
+		}
+	case uint32:
+		cborWriteHeader(buf, cborUnsigned, uint64(value))
+	case string:
+		cborWriteHeader(buf, cborText, uint64(len(value)))
+		buf.WriteString(value)
+	case []byte:
+		cborWriteHeader(buf, cborBytes, uint64(len(value)))
+		buf.Write(value)
+	case []interface{}:
+		cborWriteHeader(buf, cborArray, uint64(len(value)))
+		for _, item := range value {
+			if err := cborWrite(buf, item); err != nil {
+				return err
+			}
+		}
+	case cborMap:
+		cborWriteHeader(buf, cborMapType, uint64(len(value)))
+		for _, entry := range value {
+			if err := cborWrite(buf, entry.Key); err != nil {
+				return err
+			}
+			if err := cborWrite(buf, entry.Value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("CBOR encoding of %T is not supported", v)
+	}
+	return nil
+}
+
+// cborWriteHeader - writes major type with argument in the shortest form
+func cborWriteHeader(buf *bytes.Buffer, major byte, n uint64) {
+	switch {
+	case n < 24:
+		buf.WriteByte(major<<5 | byte(n))
+	case n <= 0xff:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(n))
+	case n <= 0xffff:
+		buf.WriteByte(major<<5 | 25)
+		b := make([]byte, 2)
+		binary.BigEndian.PutUint16(b, uint16(n))
+		buf.Write(b)
+	case n <= 0xffffffff:
+		buf.WriteByte(major<<5 | 26)
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, uint32(n))
+		buf.Write(b)
+	default:
+		buf.WriteByte(major<<5 | 27)
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, n)
+		buf.Write(b)
+	}
+}
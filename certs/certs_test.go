@@ -1,8 +1,11 @@
 package certs
 
 import (
+	"crypto/tls"
 	"crypto/x509"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
@@ -107,3 +110,46 @@ func TestGenerateAndSave(t *testing.T) {
 	}
 
 }
+
+func TestSelfSignedCertificate(t *testing.T) {
+	x509c, priv, err := NewSelfSignedCertificate([]string{"localhost", "127.0.0.1"}, "Hoverfly Admin", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("NewSelfSignedCertificate(): got %v, want no error", err)
+	}
+	if priv == nil {
+		t.Errorf("priv: got nil, want *rsa.PrivateKey")
+	}
+	if x509c.IsCA {
+		t.Errorf("x509c.IsCA: got true, want false")
+	}
+	if err := x509c.VerifyHostname("localhost"); err != nil {
+		t.Errorf("x509c.VerifyHostname(%q): got %v, want no error", "localhost", err)
+	}
+	if err := x509c.VerifyHostname("127.0.0.1"); err != nil {
+		t.Errorf("x509c.VerifyHostname(%q): got %v, want no error", "127.0.0.1", err)
+	}
+}
+
+func TestSaveCertificatePair(t *testing.T) {
+	x509c, priv, err := NewSelfSignedCertificate([]string{"localhost"}, "Hoverfly Admin", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("NewSelfSignedCertificate(): got %v, want no error", err)
+	}
+
+	dir, err := ioutil.TempDir("", "hoverfly_certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	if err := SaveCertificatePair(x509c, priv, certFile, keyFile); err != nil {
+		t.Fatalf("SaveCertificatePair(): got %v, want no error", err)
+	}
+
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		t.Errorf("tls.LoadX509KeyPair(): got %v, want no error", err)
+	}
+}
@@ -0,0 +1,144 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// latency distributions of ChaosProfile
+const (
+	LatencyUniform     = "uniform"
+	LatencyNormal      = "normal"
+	LatencyExponential = "exponential"
+)
+
+// errChaosFailure - error of requests that chaos mode failed on purpose
+var errChaosFailure = errors.New("failure injected by chaos mode")
+
+// ChaosProfile - failures and latency injected in chaos mode. ErrorRate (0-1) of requests fail with 503, the
+// rest are delayed by up to MaxLatencyMs milliseconds sampled from LatencyDistribution before they are forwarded.
+type ChaosProfile struct {
+	ErrorRate           float64 `json:"errorRate"`
+	MaxLatencyMs        int     `json:"maxLatencyMs"`
+	LatencyDistribution string  `json:"latencyDistribution"`
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewChaosProfile - returns validated chaos profile, uniform distribution is used when none is given
+func NewChaosProfile(errorRate float64, maxLatencyMs int, distribution string) (*ChaosProfile, error) {
+	p := &ChaosProfile{ErrorRate: errorRate, MaxLatencyMs: maxLatencyMs, LatencyDistribution: distribution}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadChaosProfile - reads chaos profile from JSON file, i.e.:
+//
+//	{"errorRate": 0.1, "maxLatencyMs": 2000, "latencyDistribution": "exponential"}
+func (c *Configuration) LoadChaosProfile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var profile ChaosProfile
+	if err := json.NewDecoder(f).Decode(&profile); err != nil {
+		return fmt.Errorf("failed to parse chaos profile file: %s", err.Error())
+	}
+
+	p, err := NewChaosProfile(profile.ErrorRate, profile.MaxLatencyMs, profile.LatencyDistribution)
+	if err != nil {
+		return err
+	}
+	c.ChaosProfile = p
+	return nil
+}
+
+func (p *ChaosProfile) validate() error {
+	if p.ErrorRate < 0 || p.ErrorRate > 1 {
+		return fmt.Errorf("chaos error rate must be between 0 and 1, got %v", p.ErrorRate)
+	}
+	if p.MaxLatencyMs < 0 {
+		return fmt.Errorf("chaos max latency can't be negative, got %d", p.MaxLatencyMs)
+	}
+	switch p.LatencyDistribution {
+	case "":
+		p.LatencyDistribution = LatencyUniform
+	case LatencyUniform, LatencyNormal, LatencyExponential:
+	default:
+		return fmt.Errorf("unknown chaos latency distribution '%s'", p.LatencyDistribution)
+	}
+	return nil
+}
+
+// roll - decides whether request fails and how long it is delayed otherwise
+func (p *ChaosProfile) roll() (fail bool, delay time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.rand == nil {
+		p.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	if p.rand.Float64() < p.ErrorRate {
+		return true, 0
+	}
+	if p.MaxLatencyMs == 0 {
+		return false, 0
+	}
+
+	max := float64(p.MaxLatencyMs)
+	var ms float64
+	switch p.LatencyDistribution {
+	case LatencyNormal:
+		// centered in the middle of the range, nearly all samples fall within it
+		ms = max/2 + p.rand.NormFloat64()*max/6
+	case LatencyExponential:
+		// most requests are fast, long delays are rare
+		ms = p.rand.ExpFloat64() * max / 4
+	default:
+		ms = p.rand.Float64() * max
+	}
+	ms = math.Max(0, math.Min(ms, max))
+
+	return false, time.Duration(ms * float64(time.Millisecond))
+}
+
+// chaosRequest - fails request or delays it according to chaos profile, requests that don't fail are forwarded to
+// original destination
+func (d *Hoverfly) chaosRequest(req *http.Request) *http.Response {
+	cfg := d.requestConfig(req)
+	profile := cfg.ChaosProfile
+	if profile != nil {
+		fail, delay := profile.roll()
+		if fail {
+			log.WithFields(log.Fields{
+				"mode":        ChaosMode,
+				"path":        req.URL.Path,
+				"method":      req.Method,
+				"destination": req.Host,
+			}).Info("chaos mode failed request")
+			return hoverflyError(req, errChaosFailure, "Request failed", http.StatusServiceUnavailable, ErrorCodeServiceUnavailable, errorFormat(req))
+		}
+		time.Sleep(delay)
+	}
+
+	resp, err := d.bypassRequest(req)
+	if err != nil {
+		return hoverflyError(req, err, "Could not forward request", upstreamErrorStatus(err), errorCodeOf(err, ErrorCodeUpstreamUnreachable), errorFormat(req))
+	}
+	return resp
+}
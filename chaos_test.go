@@ -0,0 +1,150 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// seededChaosProfile - chaos profile with fixed random source
+func seededChaosProfile(t *testing.T, errorRate float64, maxLatencyMs int, distribution string) *ChaosProfile {
+	p, err := NewChaosProfile(errorRate, maxLatencyMs, distribution)
+	testutil.Expect(t, err, nil)
+	p.rand = rand.New(rand.NewSource(42))
+	return p
+}
+
+func TestChaosModeInjectsFailures(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.ChaosProfile = seededChaosProfile(t, 0.5, 0, "")
+	testutil.Expect(t, dbClient.SetMode(ChaosMode), nil)
+
+	// expected outcomes for seed 42
+	expected := make([]int, 20)
+	check := rand.New(rand.NewSource(42))
+	for i := range expected {
+		if check.Float64() < 0.5 {
+			expected[i] = http.StatusServiceUnavailable
+		} else {
+			expected[i] = http.StatusOK
+		}
+	}
+
+	failed := 0
+	for i := range expected {
+		req, err := http.NewRequest("GET", upstream.URL, nil)
+		testutil.Expect(t, err, nil)
+
+		_, resp := dbClient.processRequest(req)
+		testutil.Expect(t, resp.StatusCode, expected[i])
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			testutil.Expect(t, resp.Header.Get(ErrorCodeHeader), string(ErrorCodeServiceUnavailable))
+			failed++
+		}
+	}
+	testutil.Expect(t, failed > 0 && failed < len(expected), true)
+}
+
+func TestChaosModePassesThrough(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("live"))
+	}))
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.ChaosProfile = seededChaosProfile(t, 0, 10, LatencyUniform)
+	testutil.Expect(t, dbClient.SetMode(ChaosMode), nil)
+
+	req, err := http.NewRequest("GET", upstream.URL, nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "live")
+
+	// nothing is captured
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 0)
+
+	// requests proxied in chaos mode are counted
+	_, ok := dbClient.Counter.Counters[ChaosMode]
+	testutil.Expect(t, ok, true)
+}
+
+func TestChaosLatencyDistributions(t *testing.T) {
+	for _, distribution := range []string{LatencyUniform, LatencyNormal, LatencyExponential} {
+		p := seededChaosProfile(t, 0, 100, distribution)
+		again := seededChaosProfile(t, 0, 100, distribution)
+
+		var total time.Duration
+		for i := 0; i < 1000; i++ {
+			fail, delay := p.roll()
+			testutil.Expect(t, fail, false)
+			testutil.Expect(t, delay >= 0 && delay <= 100*time.Millisecond, true)
+
+			// same seed gives same delays
+			_, expected := again.roll()
+			testutil.Expect(t, delay, expected)
+			total += delay
+		}
+
+		mean := total / 1000
+		switch distribution {
+		case LatencyExponential:
+			// skewed towards short delays
+			testutil.Expect(t, mean < 40*time.Millisecond, true)
+		default:
+			testutil.Expect(t, mean > 40*time.Millisecond && mean < 60*time.Millisecond, true)
+		}
+	}
+}
+
+func TestNewChaosProfileValidation(t *testing.T) {
+	_, err := NewChaosProfile(1.5, 0, "")
+	testutil.Refute(t, err, nil)
+
+	_, err = NewChaosProfile(0.1, -1, "")
+	testutil.Refute(t, err, nil)
+
+	_, err = NewChaosProfile(0.1, 100, "poisson")
+	testutil.Refute(t, err, nil)
+
+	p, err := NewChaosProfile(0.1, 100, "")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, p.LatencyDistribution, LatencyUniform)
+}
+
+func TestLoadChaosProfile(t *testing.T) {
+	f, err := ioutil.TempFile("", "chaos")
+	testutil.Expect(t, err, nil)
+	defer os.Remove(f.Name())
+	f.WriteString(`{"errorRate": 0.25, "maxLatencyMs": 500, "latencyDistribution": "normal"}`)
+	f.Close()
+
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.LoadChaosProfile(f.Name()), nil)
+	testutil.Expect(t, cfg.ChaosProfile.ErrorRate, 0.25)
+	testutil.Expect(t, cfg.ChaosProfile.MaxLatencyMs, 500)
+	testutil.Expect(t, cfg.ChaosProfile.LatencyDistribution, LatencyNormal)
+}
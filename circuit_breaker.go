@@ -0,0 +1,138 @@
+package hoverfly
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultCircuitBreakerResetInterval - how long circuit stays open before upstream is tried again
+const DefaultCircuitBreakerResetInterval = 30 * time.Second
+
+// circuit states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// circuit - state of a single upstream host
+type circuit struct {
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+// CircuitBreaker - stops capture mode from forwarding requests to hosts that failed CircuitBreakerThreshold times
+// in a row. Open circuit lets one request through after CircuitBreakerResetInterval (half-open), circuit is closed
+// again when it succeeds and opened again when it fails. Disabled when threshold is not set.
+type CircuitBreaker struct {
+	cfg      *Configuration
+	now      func() time.Time
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// NewCircuitBreaker - returns circuit breaker that takes threshold and reset interval from given configuration
+func NewCircuitBreaker(cfg *Configuration) *CircuitBreaker {
+	return &CircuitBreaker{cfg: cfg, now: time.Now, circuits: make(map[string]*circuit)}
+}
+
+// State - returns state of host circuit
+func (b *CircuitBreaker) State(host string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, ok := b.circuits[host]; ok {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// Allow - returns error when requests to host shouldn't be forwarded
+func (b *CircuitBreaker) Allow(host string) error {
+	if b == nil || b.cfg.CircuitBreakerThreshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[host]
+	if !ok {
+		return nil
+	}
+
+	switch c.state {
+	case CircuitOpen:
+		if b.now().Sub(c.openedAt) < b.resetInterval() {
+			return withErrorCode(ErrorCodeCircuitOpen, fmt.Errorf("circuit breaker for '%s' is open after %d consecutive failures", host, c.failures))
+		}
+		// trial request
+		c.state = CircuitHalfOpen
+		return nil
+	case CircuitHalfOpen:
+		return withErrorCode(ErrorCodeCircuitOpen, fmt.Errorf("circuit breaker for '%s' is half-open, waiting for trial request", host))
+	}
+	return nil
+}
+
+// Record - updates host circuit with result of forwarded request, transport errors and 5xx responses are failures
+func (b *CircuitBreaker) Record(host string, resp *http.Response, err error) {
+	if b == nil || b.cfg.CircuitBreakerThreshold <= 0 {
+		return
+	}
+
+	failed := false
+	if err != nil {
+		// middleware failures say nothing about upstream
+		if errorCodeOf(err, ErrorCodeUpstreamUnreachable) != ErrorCodeUpstreamUnreachable {
+			return
+		}
+		failed = true
+	} else if resp != nil {
+		failed = resp.StatusCode >= 500
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.circuits[host]
+	if !failed {
+		if ok {
+			if c.state != CircuitClosed {
+				log.WithFields(log.Fields{
+					"host": host,
+				}).Info("circuit breaker closed")
+			}
+			delete(b.circuits, host)
+		}
+		return
+	}
+
+	if !ok {
+		c = &circuit{state: CircuitClosed}
+		b.circuits[host] = c
+	}
+	c.failures++
+
+	if c.state == CircuitHalfOpen || (c.state == CircuitClosed && c.failures >= b.cfg.CircuitBreakerThreshold) {
+		c.state = CircuitOpen
+		c.openedAt = b.now()
+		log.WithFields(log.Fields{
+			"host":     host,
+			"failures": c.failures,
+			"retryIn":  b.resetInterval().String(),
+		}).Warn("circuit breaker opened, requests are not forwarded")
+	}
+}
+
+func (b *CircuitBreaker) resetInterval() time.Duration {
+	if b.cfg.CircuitBreakerResetInterval > 0 {
+		return b.cfg.CircuitBreakerResetInterval
+	}
+	return DefaultCircuitBreakerResetInterval
+}
@@ -0,0 +1,118 @@
+package hoverfly
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func circuitBreakerTestServer(status *int32, hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.WriteHeader(int(atomic.LoadInt32(status)))
+	}))
+}
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	status, hits := int32(500), int32(0)
+	server := circuitBreakerTestServer(&status, &hits)
+	defer server.Close()
+
+	_, dbClient := testTools(200, "")
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.CircuitBreakerThreshold = 3
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", server.URL+"/fail", nil)
+		resp, err := dbClient.captureRequest(req)
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, resp.StatusCode, 500)
+	}
+
+	req, _ := http.NewRequest("GET", server.URL+"/fail", nil)
+	_, err := dbClient.captureRequest(req)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, errorCodeOf(err, ""), ErrorCodeCircuitOpen)
+	testutil.Expect(t, upstreamErrorStatus(err), http.StatusServiceUnavailable)
+	testutil.Expect(t, atomic.LoadInt32(&hits), int32(3))
+	testutil.Expect(t, dbClient.Circuits.State(req.Host), CircuitOpen)
+}
+
+func TestCircuitBreakerHalfOpenAfterReset(t *testing.T) {
+	status, hits := int32(500), int32(0)
+	server := circuitBreakerTestServer(&status, &hits)
+	defer server.Close()
+
+	_, dbClient := testTools(200, "")
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.CircuitBreakerThreshold = 1
+	dbClient.Cfg.CircuitBreakerResetInterval = time.Minute
+
+	now := time.Now()
+	dbClient.Circuits.now = func() time.Time { return now }
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	dbClient.captureRequest(req)
+	testutil.Expect(t, dbClient.Circuits.State(req.Host), CircuitOpen)
+
+	// trial request fails, circuit is opened again
+	now = now.Add(time.Minute)
+	req, _ = http.NewRequest("GET", server.URL, nil)
+	resp, err := dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, 500)
+	testutil.Expect(t, dbClient.Circuits.State(req.Host), CircuitOpen)
+
+	req, _ = http.NewRequest("GET", server.URL, nil)
+	_, err = dbClient.captureRequest(req)
+	testutil.Expect(t, errorCodeOf(err, ""), ErrorCodeCircuitOpen)
+	testutil.Expect(t, atomic.LoadInt32(&hits), int32(2))
+
+	// trial request succeeds, circuit is closed
+	atomic.StoreInt32(&status, 200)
+	now = now.Add(time.Minute)
+	req, _ = http.NewRequest("GET", server.URL, nil)
+	resp, err = dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, 200)
+	testutil.Expect(t, dbClient.Circuits.State(req.Host), CircuitClosed)
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	_, dbClient := testTools(200, "")
+	dbClient.Cfg.CircuitBreakerThreshold = 2
+
+	failed := &http.Response{StatusCode: 502}
+	ok := &http.Response{StatusCode: 200}
+
+	dbClient.Circuits.Record("example.com", failed, nil)
+	dbClient.Circuits.Record("example.com", ok, nil)
+	dbClient.Circuits.Record("example.com", failed, nil)
+	testutil.Expect(t, dbClient.Circuits.State("example.com"), CircuitClosed)
+	testutil.Expect(t, dbClient.Circuits.Allow("example.com"), nil)
+
+	// middleware errors aren't upstream failures
+	dbClient.Circuits.Record("example.com", nil, withErrorCode(ErrorCodeMiddlewareFailed, errors.New("middleware failed")))
+	testutil.Expect(t, dbClient.Circuits.State("example.com"), CircuitClosed)
+
+	dbClient.Circuits.Record("example.com", failed, nil)
+	testutil.Expect(t, dbClient.Circuits.State("example.com"), CircuitOpen)
+	testutil.Expect(t, dbClient.Circuits.State("other.com"), CircuitClosed)
+}
+
+func TestCircuitBreakerDisabledByDefault(t *testing.T) {
+	server, dbClient := testTools(500, "")
+	defer server.Close()
+
+	for i := 0; i < 10; i++ {
+		req, _ := http.NewRequest("GET", "http://example.com", nil)
+		_, err := dbClient.captureRequest(req)
+		testutil.Expect(t, err, nil)
+	}
+	testutil.Expect(t, dbClient.Circuits.State("example.com"), CircuitClosed)
+}
@@ -0,0 +1,90 @@
+package hoverfly
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardedForHeader - header that proxies use to pass original client address
+const ForwardedForHeader = "X-Forwarded-For"
+
+// clientIPMetadataKey - cache entry metadata key holding original client IP
+const clientIPMetadataKey = "clientIP"
+
+// SetTrustedProxies - parses CIDR ranges of proxies that are trusted to set X-Forwarded-For, single IP
+// addresses are accepted as well
+func (c *Configuration) SetTrustedProxies(cidrs []string) error {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy '%s'", cidr)
+			}
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy '%s': %s", cidr, err.Error())
+		}
+		nets = append(nets, ipNet)
+	}
+
+	c.TrustedProxies = cidrs
+	c.trustedProxyNets = nets
+	return nil
+}
+
+// isTrustedProxy - whether given IP belongs to one of trusted proxy ranges
+func (c *Configuration) isTrustedProxy(ip net.IP) bool {
+	for _, ipNet := range c.trustedProxyNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// OriginalClientIP - returns IP of the client that sent the request. When connection comes from trusted proxy,
+// rightmost X-Forwarded-For address that is not a trusted proxy is returned, otherwise connection remote address.
+func (c *Configuration) OriginalClientIP(req *http.Request) string {
+	remote := clientHost(req)
+
+	ip := net.ParseIP(remote)
+	if ip == nil || !c.isTrustedProxy(ip) {
+		return remote
+	}
+
+	var forwarded []string
+	for _, value := range req.Header[ForwardedForHeader] {
+		forwarded = append(forwarded, strings.Split(value, ",")...)
+	}
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if hop == nil {
+			// header was not set by trusted proxy past this point
+			break
+		}
+		if !c.isTrustedProxy(hop) {
+			return hop.String()
+		}
+	}
+	return remote
+}
+
+// storeClientIP - keeps original client IP in metadata of captured cache entry, only done when trusted proxies
+// are configured
+func (d *Hoverfly) storeClientIP(key string, req *http.Request) {
+	cfg := d.requestConfig(req)
+	if cfg.DryRun || len(cfg.trustedProxyNets) == 0 {
+		return
+	}
+	d.storeMiddlewareMetadata(key, map[string]string{clientIPMetadataKey: cfg.OriginalClientIP(req)})
+}
@@ -0,0 +1,72 @@
+package hoverfly
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestOriginalClientIPFromTrustedProxy(t *testing.T) {
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.SetTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"}), nil)
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+	req.RemoteAddr = "10.1.1.1:52000"
+	// spoofed address on the left is ignored
+	req.Header.Add(ForwardedForHeader, "1.1.1.1, 203.0.113.7")
+	req.Header.Add(ForwardedForHeader, "192.168.1.1")
+
+	testutil.Expect(t, cfg.OriginalClientIP(req), "203.0.113.7")
+}
+
+func TestOriginalClientIPFromUntrustedConnection(t *testing.T) {
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.SetTrustedProxies([]string{"10.0.0.0/8"}), nil)
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+	req.RemoteAddr = "172.16.0.5:52000"
+	req.Header.Set(ForwardedForHeader, "203.0.113.7")
+
+	testutil.Expect(t, cfg.OriginalClientIP(req), "172.16.0.5")
+}
+
+func TestOriginalClientIPOnlyTrustedHops(t *testing.T) {
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.SetTrustedProxies([]string{"10.0.0.0/8"}), nil)
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+	req.RemoteAddr = "10.1.1.1:52000"
+	req.Header.Set(ForwardedForHeader, "10.2.2.2")
+
+	testutil.Expect(t, cfg.OriginalClientIP(req), "10.1.1.1")
+}
+
+func TestSetTrustedProxiesInvalid(t *testing.T) {
+	cfg := InitSettings()
+	testutil.Refute(t, cfg.SetTrustedProxies([]string{"10.0.0.0/33"}), nil)
+	testutil.Refute(t, cfg.SetTrustedProxies([]string{"proxy.local"}), nil)
+}
+
+func TestCaptureStoresOriginalClientIP(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	testutil.Expect(t, dbClient.Cfg.SetTrustedProxies([]string{"10.0.0.0/8"}), nil)
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	req, err := http.NewRequest("GET", "http://example.com/path", nil)
+	testutil.Expect(t, err, nil)
+	req.RemoteAddr = "10.1.1.1:52000"
+	req.Header.Set(ForwardedForHeader, "203.0.113.7")
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, 200)
+
+	metadata := dbClient.getMiddlewareMetadata(dbClient.getRequestFingerprint(req, nil))
+	testutil.Expect(t, metadata[clientIPMetadataKey], "203.0.113.7")
+}
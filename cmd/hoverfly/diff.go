@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/SpectoLabs/hoverfly/simdiff"
+)
+
+// runDiff - 'hoverfly diff old.json new.json' prints JSON report of records added, removed and modified in the new
+// simulation. Like diff(1) it exits with 0 when simulations are the same, 1 when they differ and 2 on error.
+func runDiff(args []string, stdout, stderr io.Writer) int {
+	if len(args) != 2 {
+		fmt.Fprintln(stderr, "usage: hoverfly diff old.json new.json")
+		return 2
+	}
+
+	old, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 2
+	}
+	defer old.Close()
+
+	current, err := os.Open(args[1])
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 2
+	}
+	defer current.Close()
+
+	diff, err := simdiff.CompareSim(old, current)
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 2
+	}
+
+	b, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 2
+	}
+	fmt.Fprintln(stdout, string(b))
+
+	if diff.Empty() {
+		return 0
+	}
+	return 1
+}
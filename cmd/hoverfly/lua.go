@@ -0,0 +1,7 @@
+//go:build lua
+// +build lua
+
+package main
+
+// Lua middleware interpreter registers itself with Hoverfly
+import _ "github.com/SpectoLabs/hoverfly/luamiddleware"
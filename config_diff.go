@@ -0,0 +1,82 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// configSnapshotExcluded - configuration fields that are not included in snapshots, either secret or not
+// configuration at all
+var configSnapshotExcluded = []string{"SecretKey", "ProxyControlWG"}
+
+// ConfigChange - configuration field that has a different value than it had at startup
+type ConfigChange struct {
+	Field   string      `json:"field"`
+	Startup interface{} `json:"startup"`
+	Current interface{} `json:"current"`
+}
+
+// Snapshot - returns exported configuration fields as they would be encoded to JSON, keyed by field name
+func (c *Configuration) Snapshot() (map[string]interface{}, error) {
+	c.mu.Lock()
+	bts, err := json.Marshal(c)
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[string]interface{})
+	if err := json.Unmarshal(bts, &snapshot); err != nil {
+		return nil, err
+	}
+	for _, f := range configSnapshotExcluded {
+		delete(snapshot, f)
+	}
+	return snapshot, nil
+}
+
+// diffConfigSnapshots - returns changed fields sorted by name, fields missing in one of the snapshots are
+// reported with nil value
+func diffConfigSnapshots(startup, current map[string]interface{}) []ConfigChange {
+	changes := []ConfigChange{}
+
+	for field, value := range current {
+		if before, ok := startup[field]; !ok || !reflect.DeepEqual(before, value) {
+			changes = append(changes, ConfigChange{Field: field, Startup: startup[field], Current: value})
+		}
+	}
+	for field, before := range startup {
+		if _, ok := current[field]; !ok {
+			changes = append(changes, ConfigChange{Field: field, Startup: before})
+		}
+	}
+
+	sort.Sort(configChangesByField(changes))
+	return changes
+}
+
+type configChangesByField []ConfigChange
+
+func (c configChangesByField) Len() int           { return len(c) }
+func (c configChangesByField) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c configChangesByField) Less(i, j int) bool { return c[i].Field < c[j].Field }
+
+// recordStartupConfig - stores configuration snapshot that ConfigDiff compares against
+func (d *Hoverfly) recordStartupConfig() error {
+	snapshot, err := d.Cfg.Snapshot()
+	if err != nil {
+		return err
+	}
+	d.startupConfig = snapshot
+	return nil
+}
+
+// ConfigDiff - returns configuration fields that changed since startup
+func (d *Hoverfly) ConfigDiff() ([]ConfigChange, error) {
+	current, err := d.Cfg.Snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return diffConfigSnapshots(d.startupConfig, current), nil
+}
@@ -0,0 +1,38 @@
+package hoverfly
+
+import (
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestConfigSnapshotExcludesSecretKey(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SecretKey = []byte("secret")
+
+	snapshot, err := cfg.Snapshot()
+	testutil.Expect(t, err, nil)
+
+	_, ok := snapshot["SecretKey"]
+	testutil.Expect(t, ok, false)
+	testutil.Expect(t, snapshot["Mode"], cfg.Mode)
+}
+
+func TestDiffConfigSnapshots(t *testing.T) {
+	startup := map[string]interface{}{"Mode": "simulate", "Middleware": "", "Removed": true}
+	current := map[string]interface{}{"Mode": "simulate", "Middleware": "./mw.py", "Added": 1.0}
+
+	changes := diffConfigSnapshots(startup, current)
+	testutil.Expect(t, len(changes), 3)
+
+	testutil.Expect(t, changes[0].Field, "Added")
+	testutil.Expect(t, changes[0].Startup, nil)
+	testutil.Expect(t, changes[0].Current, 1.0)
+
+	testutil.Expect(t, changes[1].Field, "Middleware")
+	testutil.Expect(t, changes[1].Startup, "")
+	testutil.Expect(t, changes[1].Current, "./mw.py")
+
+	testutil.Expect(t, changes[2].Field, "Removed")
+	testutil.Expect(t, changes[2].Current, nil)
+}
@@ -0,0 +1,109 @@
+package hoverfly
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/pborman/uuid"
+)
+
+// ConnectionIDHeader - header with ID of the client connection response was served on
+const ConnectionIDHeader = "X-Hoverfly-Connection-ID"
+
+// connKey - request context key of client connection
+type connKey struct{}
+
+// connectionIDs - IDs of open proxy connections, keyed on connection
+type connectionIDs struct {
+	ids sync.Map
+}
+
+// connContext - assigns ID to accepted connection, http.Server calls it before connection state hook
+func (c *connectionIDs) connContext(ctx context.Context, conn net.Conn) context.Context {
+	c.ids.Store(conn, uuid.New())
+	return context.WithValue(ctx, connKey{}, conn)
+}
+
+// connState - forgets ID once connection is closed or taken over by a handler
+func (c *connectionIDs) connState(conn net.Conn, state http.ConnState) {
+	if state == http.StateClosed || state == http.StateHijacked {
+		c.ids.Delete(conn)
+	}
+}
+
+// get - returns ID of connection request was received on
+func (c *connectionIDs) get(r *http.Request) (string, bool) {
+	conn, ok := r.Context().Value(connKey{}).(net.Conn)
+	if !ok {
+		return "", false
+	}
+	id, ok := c.ids.Load(conn)
+	if !ok {
+		return "", false
+	}
+	return id.(string), true
+}
+
+// track - registers connection hooks on proxy server
+func (c *connectionIDs) track(server *http.Server) {
+	server.ConnContext = c.connContext
+	server.ConnState = c.connState
+}
+
+// connectionIDHandler - adds connection ID header to every response. Proxy replaces response headers with the ones
+// of upstream or simulated response, so header is added just before they are written.
+type connectionIDHandler struct {
+	handler http.Handler
+	ids     *connectionIDs
+}
+
+func (h *connectionIDHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.ids.get(r)
+	if !ok {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	h.handler.ServeHTTP(&connectionIDWriter{ResponseWriter: w, id: id}, r)
+}
+
+// connectionIDWriter - sets connection ID header when headers are written
+type connectionIDWriter struct {
+	http.ResponseWriter
+	id          string
+	wroteHeader bool
+}
+
+func (w *connectionIDWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.Header().Set(ConnectionIDHeader, w.id)
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *connectionIDWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Hijack - CONNECT and websocket handlers need access to underlying connection
+func (w *connectionIDWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// Flush - keeps streaming responses working
+func (w *connectionIDWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
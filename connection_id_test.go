@@ -0,0 +1,83 @@
+package hoverfly
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// connectionIDOf - sends request on given client and returns connection ID header of the response
+func connectionIDOf(t *testing.T, client *http.Client, url string) string {
+	response, err := client.Get(url)
+	testutil.Expect(t, err, nil)
+	// body has to be read so that connection is reused
+	ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	return response.Header.Get(ConnectionIDHeader)
+}
+
+func TestProxyInjectsConnectionIDHeader(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	proxyPort := "9780"
+
+	dbClient.Cfg.ProxyPort = proxyPort
+	dbClient.Cfg.InjectConnectionIDHeader = true
+	dbClient.UpdateProxy()
+	testutil.Expect(t, dbClient.StartProxy(), nil)
+	defer dbClient.StopProxy()
+
+	url := fmt.Sprintf("http://localhost:%s/", proxyPort)
+
+	client := &http.Client{Transport: &http.Transport{}}
+	first := connectionIDOf(t, client, url)
+	testutil.Refute(t, first, "")
+	testutil.Expect(t, connectionIDOf(t, client, url), first)
+
+	other := &http.Client{Transport: &http.Transport{}}
+	testutil.Refute(t, connectionIDOf(t, other, url), first)
+}
+
+func TestProxyWithoutConnectionIDHeader(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	proxyPort := "9781"
+
+	dbClient.Cfg.ProxyPort = proxyPort
+	dbClient.UpdateProxy()
+	testutil.Expect(t, dbClient.StartProxy(), nil)
+	defer dbClient.StopProxy()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	testutil.Expect(t, connectionIDOf(t, client, fmt.Sprintf("http://localhost:%s/", proxyPort)), "")
+}
+
+func TestConnectionIDsClearedOnClose(t *testing.T) {
+	ids := &connectionIDs{}
+	conn, other := net.Pipe()
+	defer other.Close()
+
+	ctx := ids.connContext(context.Background(), conn)
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	testutil.Expect(t, err, nil)
+	req = req.WithContext(ctx)
+
+	id, ok := ids.get(req)
+	testutil.Expect(t, ok, true)
+	testutil.Refute(t, id, "")
+
+	ids.connState(conn, http.StateActive)
+	_, ok = ids.get(req)
+	testutil.Expect(t, ok, true)
+
+	ids.connState(conn, http.StateClosed)
+	_, ok = ids.get(req)
+	testutil.Expect(t, ok, false)
+}
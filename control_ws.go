@@ -0,0 +1,205 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Control channel events
+const (
+	ControlEventRequestServed = "requestServed"
+	ControlEventModeChanged   = "modeChanged"
+	ControlEventCacheFlushed  = "cacheFlushed"
+	ControlEventError         = "error"
+)
+
+// controlEventBuffer - how many events can wait for a slow client, client that falls further behind is disconnected
+const controlEventBuffer = 100
+
+// ControlEvent - event pushed to control channel clients
+type ControlEvent struct {
+	Event   string    `json:"event"`
+	Time    time.Time `json:"time"`
+	URL     string    `json:"url,omitempty"`
+	Host    string    `json:"host,omitempty"`
+	Method  string    `json:"method,omitempty"`
+	Status  int       `json:"status,omitempty"`
+	Mode    string    `json:"mode,omitempty"`
+	Message string    `json:"message,omitempty"`
+	// LatencyMs - how long it took to serve request, set on requestServed events
+	LatencyMs float64 `json:"latencyMs,omitempty"`
+}
+
+// controlCommand - command sent by control channel clients, i.e. {"cmd":"setMode","mode":"capture"}
+type controlCommand struct {
+	Cmd  string `json:"cmd"`
+	Mode string `json:"mode"`
+}
+
+// eventBroadcaster - fans out control events to every connected client
+type eventBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan ControlEvent]bool
+}
+
+func newEventBroadcaster() *eventBroadcaster {
+	return &eventBroadcaster{subscribers: make(map[chan ControlEvent]bool)}
+}
+
+func (b *eventBroadcaster) subscribe() chan ControlEvent {
+	ch := make(chan ControlEvent, controlEventBuffer)
+	b.mu.Lock()
+	b.subscribers[ch] = true
+	b.mu.Unlock()
+	return ch
+}
+
+// unsubscribe - removes and closes subscriber channel, channels of dropped subscribers are already closed
+func (b *eventBroadcaster) unsubscribe(ch chan ControlEvent) {
+	b.mu.Lock()
+	if b.subscribers[ch] {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+	b.mu.Unlock()
+}
+
+// publish - sends event to all subscribers without blocking the proxy, subscribers that fell controlEventBuffer
+// events behind are dropped and their channels closed. Safe to call on nil broadcaster.
+func (b *eventBroadcaster) publish(event ControlEvent) {
+	if b == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.WithFields(log.Fields{
+				"event": event.Event,
+			}).Warn("event client is too slow, disconnecting it")
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// ControlWSHandler - upgrades connection to websocket, accepts JSON commands and pushes proxy events
+func (d *Hoverfly) ControlWSHandler(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("failed to upgrade websocket")
+		return
+	}
+	defer conn.Close()
+
+	// gorilla connections support only one concurrent writer
+	var writeMu sync.Mutex
+	write := func(event ControlEvent) error {
+		if event.Time.IsZero() {
+			event.Time = time.Now()
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(event)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	var events chan ControlEvent
+	if d.events != nil {
+		events = d.events.subscribe()
+		defer d.events.unsubscribe(events)
+	}
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case event, ok := <-events:
+				if !ok {
+					// client was too slow, read loop ends once connection is closed
+					conn.Close()
+					return
+				}
+				if err := write(event); err != nil {
+					log.WithFields(log.Fields{
+						"error": err.Error(),
+					}).Debug("Got error when writing control event")
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_, p, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var cmd controlCommand
+		if err := json.Unmarshal(p, &cmd); err != nil {
+			write(ControlEvent{Event: ControlEventError, Message: "Failed to parse command: " + err.Error()})
+			continue
+		}
+
+		if err := write(d.executeControlCommand(r, cmd)); err != nil {
+			return
+		}
+	}
+}
+
+// executeControlCommand - applies control channel command, returns event describing the result
+func (d *Hoverfly) executeControlCommand(r *http.Request, cmd controlCommand) ControlEvent {
+	switch cmd.Cmd {
+	case "setMode":
+		if err := d.SetMode(cmd.Mode); err != nil {
+			return ControlEvent{Event: ControlEventError, Message: err.Error()}
+		}
+
+		log.WithFields(log.Fields{
+			"newState": cmd.Mode,
+		}).Info("Handling state change request from control channel!")
+
+		d.recordAdminEvent(r, ActionTypeConfigurationChanged)
+
+		return ControlEvent{Event: ControlEventModeChanged, Mode: d.Cfg.GetMode()}
+
+	case "flushCache":
+		if err := d.deleteAllRecords(); err != nil && err.Error() != "bucket not found" {
+			return ControlEvent{Event: ControlEventError, Message: "Something went wrong: " + err.Error()}
+		}
+
+		var en Entry
+		en.ActionType = ActionTypeWipeDB
+		en.Message = "wipe"
+		en.Time = time.Now()
+
+		if err := d.Hooks.Fire(ActionTypeWipeDB, &en); err != nil {
+			log.WithFields(log.Fields{
+				"error":      err.Error(),
+				"message":    en.Message,
+				"actionType": ActionTypeWipeDB,
+			}).Error("failed to fire hook")
+		}
+
+		d.recordAdminEvent(r, ActionTypeWipeDB)
+
+		return ControlEvent{Event: ControlEventCacheFlushed}
+	}
+
+	return ControlEvent{Event: ControlEventError, Message: "Unknown command '" + cmd.Cmd + "', available commands: setMode, flushCache."}
+}
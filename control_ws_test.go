@@ -0,0 +1,102 @@
+package hoverfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+	"github.com/gorilla/websocket"
+)
+
+func dialControlChannel(t *testing.T, dbClient *Hoverfly) (*httptest.Server, *websocket.Conn) {
+	server := httptest.NewServer(getBoneRouter(*dbClient))
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/api/ws", nil)
+	if err != nil {
+		server.Close()
+		t.Fatalf("failed to connect to control channel: %s", err.Error())
+	}
+	return server, conn
+}
+
+func TestControlChannelSetMode(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.events = newEventBroadcaster()
+
+	admin, conn := dialControlChannel(t, dbClient)
+	defer admin.Close()
+	defer conn.Close()
+
+	testutil.Expect(t, conn.WriteJSON(controlCommand{Cmd: "setMode", Mode: CaptureMode}), nil)
+
+	var event ControlEvent
+	testutil.Expect(t, conn.ReadJSON(&event), nil)
+	testutil.Expect(t, event.Event, ControlEventModeChanged)
+	testutil.Expect(t, event.Mode, CaptureMode)
+	testutil.Expect(t, dbClient.Cfg.GetMode(), CaptureMode)
+
+	testutil.Expect(t, conn.WriteJSON(controlCommand{Cmd: "setMode", Mode: "shouldnotwork"}), nil)
+	testutil.Expect(t, conn.ReadJSON(&event), nil)
+	testutil.Expect(t, event.Event, ControlEventError)
+	testutil.Expect(t, dbClient.Cfg.GetMode(), CaptureMode)
+}
+
+func TestControlChannelFlushCache(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.events = newEventBroadcaster()
+
+	dbClient.RequestCache.Set([]byte("key"), []byte("value"))
+
+	admin, conn := dialControlChannel(t, dbClient)
+	defer admin.Close()
+	defer conn.Close()
+
+	testutil.Expect(t, conn.WriteJSON(controlCommand{Cmd: "flushCache"}), nil)
+
+	var event ControlEvent
+	testutil.Expect(t, conn.ReadJSON(&event), nil)
+	testutil.Expect(t, event.Event, ControlEventCacheFlushed)
+
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 0)
+}
+
+func TestControlChannelPushesEvents(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.events = newEventBroadcaster()
+
+	admin, conn := dialControlChannel(t, dbClient)
+	defer admin.Close()
+	defer conn.Close()
+
+	// round trip makes sure that client has subscribed before event is published
+	testutil.Expect(t, conn.WriteJSON(controlCommand{Cmd: "unknown"}), nil)
+	var event ControlEvent
+	testutil.Expect(t, conn.ReadJSON(&event), nil)
+	testutil.Expect(t, event.Event, ControlEventError)
+
+	dbClient.events.publish(ControlEvent{Event: ControlEventRequestServed, URL: "http://example.com/"})
+
+	testutil.Expect(t, conn.ReadJSON(&event), nil)
+	testutil.Expect(t, event.Event, ControlEventRequestServed)
+	testutil.Expect(t, event.URL, "http://example.com/")
+}
+
+func TestControlChannelRequiresAuthentication(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.AuthEnabled = true
+
+	admin := httptest.NewServer(getBoneRouter(*dbClient))
+	defer admin.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(admin.URL, "http")+"/api/ws", nil)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, http.StatusUnauthorized)
+}
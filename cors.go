@@ -0,0 +1,43 @@
+package hoverfly
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	corsAllowMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	corsAllowHeaders = "Authorization, Content-Type"
+)
+
+// corsAllowedOrigin - checks whether origin is in allowed origins list, "*" allows any origin
+func corsAllowedOrigin(origin string, allowed []string) bool {
+	for _, o := range allowed {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsMiddleware - adds CORS headers to admin API responses for allowed origins and answers preflight
+// requests, requests from other origins are passed through without CORS headers so browsers block them
+func (d *Hoverfly) corsMiddleware(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	origin := req.Header.Get("Origin")
+	if origin == "" || !corsAllowedOrigin(origin, d.Cfg.AdminCORSOrigins) {
+		next(w, req)
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Add("Vary", "Origin")
+
+	if req.Method == "OPTIONS" && req.Header.Get("Access-Control-Request-Method") != "" {
+		w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+		w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	next(w, req)
+}
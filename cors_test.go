@@ -0,0 +1,83 @@
+package hoverfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+	"github.com/codegangsta/negroni"
+)
+
+func corsTestHandler(d *Hoverfly) http.Handler {
+	n := negroni.New(negroni.HandlerFunc(d.corsMiddleware))
+	n.UseHandler(getBoneRouter(*d))
+	return n
+}
+
+func TestCORSPreflightAllowedOrigin(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.AdminCORSOrigins = []string{"http://dashboard.example.com"}
+
+	req, err := http.NewRequest("OPTIONS", "/api/records", nil)
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Origin", "http://dashboard.example.com")
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+
+	rec := httptest.NewRecorder()
+	corsTestHandler(dbClient).ServeHTTP(rec, req)
+
+	testutil.Expect(t, rec.Code, http.StatusNoContent)
+	testutil.Expect(t, rec.Header().Get("Access-Control-Allow-Origin"), "http://dashboard.example.com")
+	testutil.Expect(t, rec.Header().Get("Access-Control-Allow-Methods"), corsAllowMethods)
+	testutil.Expect(t, rec.Header().Get("Access-Control-Allow-Headers"), corsAllowHeaders)
+}
+
+func TestCORSHeadersOnAllowedOriginRequest(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.AdminCORSOrigins = []string{"http://dashboard.example.com"}
+
+	req, err := http.NewRequest("GET", "/api/records", nil)
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Origin", "http://dashboard.example.com")
+
+	rec := httptest.NewRecorder()
+	corsTestHandler(dbClient).ServeHTTP(rec, req)
+
+	testutil.Expect(t, rec.Code, http.StatusOK)
+	testutil.Expect(t, rec.Header().Get("Access-Control-Allow-Origin"), "http://dashboard.example.com")
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.AdminCORSOrigins = []string{"http://dashboard.example.com"}
+
+	req, err := http.NewRequest("OPTIONS", "/api/records", nil)
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Origin", "http://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+
+	rec := httptest.NewRecorder()
+	corsTestHandler(dbClient).ServeHTTP(rec, req)
+
+	testutil.Refute(t, rec.Code, http.StatusNoContent)
+	testutil.Expect(t, rec.Header().Get("Access-Control-Allow-Origin"), "")
+}
+
+func TestCORSDisabledByDefault(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	req, err := http.NewRequest("OPTIONS", "/api/records", nil)
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Origin", "http://dashboard.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+
+	rec := httptest.NewRecorder()
+	corsTestHandler(dbClient).ServeHTTP(rec, req)
+
+	testutil.Expect(t, rec.Header().Get("Access-Control-Allow-Origin"), "")
+}
@@ -0,0 +1,43 @@
+package hoverfly
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// cspHeader - header that holds Content-Security-Policy
+const cspHeader = "Content-Security-Policy"
+
+// CSPHeader - returns policy injected into HTML responses with report-uri directive appended, empty when no
+// policy is configured
+func (c *Configuration) CSPHeader() string {
+	policy := strings.TrimSpace(c.CSPPolicy)
+	if policy == "" {
+		return ""
+	}
+	if c.CSPReportUri != "" {
+		policy = strings.TrimRight(policy, "; ") + "; report-uri " + c.CSPReportUri
+	}
+	return policy
+}
+
+// applyCSPHeader - replaces Content-Security-Policy of HTML responses with configured policy, other responses and
+// responses without configured policy are left unchanged
+func (c *Configuration) applyCSPHeader(resp *http.Response) {
+	if resp == nil {
+		return
+	}
+
+	policy := c.CSPHeader()
+	if policy == "" {
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/html" {
+		return
+	}
+
+	resp.Header.Set(cspHeader, policy)
+}
@@ -0,0 +1,53 @@
+package hoverfly
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestCSPHeaderWithReportUri(t *testing.T) {
+	cfg := Configuration{CSPPolicy: "default-src 'self';", CSPReportUri: "https://csp.example.com/report"}
+	testutil.Expect(t, cfg.CSPHeader(), "default-src 'self'; report-uri https://csp.example.com/report")
+
+	cfg = Configuration{CSPReportUri: "https://csp.example.com/report"}
+	testutil.Expect(t, cfg.CSPHeader(), "")
+}
+
+func TestApplyCSPHeaderReplacesHTMLPolicy(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.CSPPolicy = "default-src 'none'"
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Content-Type", "text/html; charset=utf-8")
+	resp.Header.Set(cspHeader, "default-src *")
+
+	dbClient.Cfg.applyCSPHeader(resp)
+	testutil.Expect(t, resp.Header.Get(cspHeader), "default-src 'none'")
+}
+
+func TestApplyCSPHeaderIgnoresOtherContentTypes(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.CSPPolicy = "default-src 'none'"
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Content-Type", "application/json")
+
+	dbClient.Cfg.applyCSPHeader(resp)
+	testutil.Expect(t, resp.Header.Get(cspHeader), "")
+}
+
+func TestApplyCSPHeaderKeepsStoredPolicyWhenNotConfigured(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Content-Type", "text/html")
+	resp.Header.Set(cspHeader, "default-src *")
+
+	dbClient.Cfg.applyCSPHeader(resp)
+	testutil.Expect(t, resp.Header.Get(cspHeader), "default-src *")
+}
@@ -0,0 +1,41 @@
+package hoverfly
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// refreshDateHeader - replaces Date of simulated response with current time and sets Age to seconds passed since
+// response was captured (added to captured Age). Capture time is taken from the record, or from captured Date
+// header for records that were imported.
+func (c *Configuration) refreshDateHeader(resp *http.Response, payload *models.Payload, now time.Time) {
+	if resp == nil || !c.UpdateDateHeader {
+		return
+	}
+
+	captured := payload.CreatedAt
+	if captured.IsZero() {
+		if date, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+			captured = date
+		}
+	}
+
+	resp.Header.Set("Date", now.UTC().Format(http.TimeFormat))
+
+	if captured.IsZero() {
+		return
+	}
+
+	// captured Age is how old the response already was when it was captured
+	age, err := strconv.ParseInt(resp.Header.Get("Age"), 10, 64)
+	if err != nil || age < 0 {
+		age = 0
+	}
+	if elapsed := int64(now.Sub(captured) / time.Second); elapsed > 0 {
+		age += elapsed
+	}
+	resp.Header.Set("Age", strconv.FormatInt(age, 10))
+}
@@ -0,0 +1,90 @@
+package hoverfly
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestRefreshDateHeader(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.UpdateDateHeader = true
+
+	now := time.Date(2017, 3, 10, 12, 0, 0, 0, time.UTC)
+	resp := &http.Response{Header: http.Header{
+		"Date": {"Fri, 10 Mar 2017 10:00:00 GMT"},
+		"Age":  {"30"},
+	}}
+	payload := &models.Payload{CreatedAt: now.Add(-time.Hour)}
+
+	dbClient.Cfg.refreshDateHeader(resp, payload, now)
+	testutil.Expect(t, resp.Header.Get("Date"), "Fri, 10 Mar 2017 12:00:00 GMT")
+	// capture time of the record is preferred over captured Date
+	testutil.Expect(t, resp.Header.Get("Age"), "3630")
+}
+
+func TestRefreshDateHeaderUsesCapturedDate(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.UpdateDateHeader = true
+
+	now := time.Date(2017, 3, 10, 12, 0, 0, 0, time.UTC)
+	resp := &http.Response{Header: http.Header{"Date": {"Fri, 10 Mar 2017 11:58:00 GMT"}}}
+
+	dbClient.Cfg.refreshDateHeader(resp, &models.Payload{}, now)
+	testutil.Expect(t, resp.Header.Get("Date"), "Fri, 10 Mar 2017 12:00:00 GMT")
+	testutil.Expect(t, resp.Header.Get("Age"), "120")
+
+	// capture time unknown
+	resp = &http.Response{Header: http.Header{}}
+	dbClient.Cfg.refreshDateHeader(resp, &models.Payload{}, now)
+	testutil.Expect(t, resp.Header.Get("Date"), "Fri, 10 Mar 2017 12:00:00 GMT")
+	testutil.Expect(t, resp.Header.Get("Age"), "")
+}
+
+func TestRefreshDateHeaderDisabled(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	resp := &http.Response{Header: http.Header{"Date": {"Fri, 10 Mar 2017 10:00:00 GMT"}}}
+	dbClient.Cfg.refreshDateHeader(resp, &models.Payload{CreatedAt: time.Now().Add(-time.Hour)}, time.Now())
+	testutil.Expect(t, resp.Header.Get("Date"), "Fri, 10 Mar 2017 10:00:00 GMT")
+	testutil.Expect(t, resp.Header.Get("Age"), "")
+}
+
+func TestSimulatedResponseHasCurrentDate(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.Cfg.UpdateDateHeader = true
+
+	view := models.PayloadView{
+		Request: models.RequestDetailsView{Method: "GET", Scheme: "http", Destination: "dated.com", Path: "/"},
+		Response: models.ResponseDetailsView{
+			Status:  200,
+			Body:    "ok",
+			Headers: map[string][]string{"Date": {"Mon, 02 Jan 2006 15:04:05 GMT"}},
+		},
+	}
+	testutil.Expect(t, dbClient.ImportPayloads([]models.PayloadView{view}), nil)
+
+	req, err := http.NewRequest("GET", "http://dated.com/", nil)
+	testutil.Expect(t, err, nil)
+
+	before := time.Now().Add(-time.Second)
+	response := dbClient.getResponse(req)
+	testutil.Expect(t, response.StatusCode, 200)
+
+	date, err := http.ParseTime(response.Header.Get("Date"))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, date.Before(before), false)
+
+	age, err := strconv.Atoi(response.Header.Get("Age"))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, age > 300000000, true)
+}
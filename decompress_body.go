@@ -0,0 +1,113 @@
+package hoverfly
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// decodeContentEncoding - decodes gzip and deflate bodies, ok is false for other content codings. Brotli library
+// isn't vendored, so brotli bodies aren't decoded either.
+func decodeContentEncoding(encoding string, body []byte) (decoded []byte, ok bool, err error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		decoded, err = gunzip(body)
+	case "deflate":
+		var zr io.ReadCloser
+		if zr, err = zlib.NewReader(bytes.NewReader(body)); err == nil {
+			defer zr.Close()
+			decoded, err = ioutil.ReadAll(zr)
+		}
+	default:
+		return nil, false, nil
+	}
+	return decoded, true, err
+}
+
+// decompressedForStorage - with Cfg.DecompressBody, returns copy of response with decoded body, without
+// Content-Encoding and with updated Content-Length. Response sent to the client is not changed.
+func (d *Hoverfly) decompressedForStorage(resp *http.Response, body []byte) (*http.Response, []byte) {
+	cfg := d.requestConfig(resp.Request)
+	encoding := resp.Header.Get("Content-Encoding")
+	if !cfg.DecompressBody || encoding == "" || len(body) == 0 {
+		return resp, body
+	}
+
+	decoded, ok, err := decodeContentEncoding(encoding, body)
+	if !ok || err != nil {
+		fields := log.Fields{
+			"encoding": encoding,
+		}
+		if err != nil {
+			fields["error"] = err.Error()
+		}
+		log.WithFields(fields).Warn("Response body can't be decompressed, storing it as it is")
+		return resp, body
+	}
+
+	stored := *resp
+	stored.Header = cloneHeader(resp.Header)
+	stored.Header.Del("Content-Encoding")
+	stored.Header.Set("Content-Length", strconv.Itoa(len(decoded)))
+	stored.ContentLength = int64(len(decoded))
+	return &stored, decoded
+}
+
+// compressForClient - with Cfg.DecompressBody, gzip compresses simulated response without content coding when
+// client accepts gzip
+func (d *Hoverfly) compressForClient(req *http.Request, resp *http.Response) {
+	cfg := d.requestConfig(req)
+	if !cfg.DecompressBody || resp == nil || resp.Body == nil || req.Method == http.MethodHead {
+		return
+	}
+	if resp.Header.Get("Content-Encoding") != "" || !acceptsGzip(req.Header.Get("Accept-Encoding")) {
+		return
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || len(body) == 0 {
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(body)
+	zw.Close()
+
+	resp.Body = ioutil.NopCloser(&buf)
+	resp.ContentLength = int64(buf.Len())
+	resp.Header.Set("Content-Encoding", "gzip")
+	resp.Header.Set("Content-Length", strconv.Itoa(buf.Len()))
+	resp.Header.Add("Vary", "Accept-Encoding")
+}
+
+// acceptsGzip - Accept-Encoding lists gzip without 'q=0'
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		accepted := true
+		for _, param := range params[1:] {
+			param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); strings.HasPrefix(param, "q=") && err == nil && q == 0 {
+				accepted = false
+			}
+		}
+		if accepted {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package hoverfly
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func gzipped(body string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(body))
+	zw.Close()
+	return buf.Bytes()
+}
+
+// captureEncoded - captures response with given content coding with DecompressBody enabled and simulates it
+// again for client with given Accept-Encoding
+func captureEncoded(t *testing.T, encoding string, body []byte, acceptEncoding string) (captured, simulated *http.Response) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body)
+	}))
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.HTTP = &http.Client{Transport: &http.Transport{
+		// transport mustn't decompress responses itself
+		DisableCompression: true,
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return url.Parse(upstream.URL)
+		},
+	}}
+	dbClient.Cfg.DecompressBody = true
+
+	req, err := http.NewRequest("GET", "http://example.com/compressed", nil)
+	testutil.Expect(t, err, nil)
+	captured, err = dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+
+	req, err = http.NewRequest("GET", "http://example.com/compressed", nil)
+	testutil.Expect(t, err, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	return captured, dbClient.getResponse(req)
+}
+
+func readBody(t *testing.T, resp *http.Response) []byte {
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	return body
+}
+
+func TestDecompressBodyGzipRoundTrip(t *testing.T) {
+	compressed := gzipped("compressed body")
+	captured, simulated := captureEncoded(t, "gzip", compressed, "gzip, deflate")
+
+	// client of capture mode gets upstream response unchanged
+	testutil.Expect(t, captured.Header.Get("Content-Encoding"), "gzip")
+	testutil.Expect(t, bytes.Equal(readBody(t, captured), compressed), true)
+
+	testutil.Expect(t, simulated.Header.Get("Content-Encoding"), "gzip")
+	body, err := gunzip(readBody(t, simulated))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "compressed body")
+}
+
+func TestDecompressBodyStoresDecompressedBody(t *testing.T) {
+	_, simulated := captureEncoded(t, "gzip", gzipped("compressed body"), "")
+
+	testutil.Expect(t, simulated.Header.Get("Content-Encoding"), "")
+	testutil.Expect(t, simulated.Header.Get("Content-Length"), strconv.Itoa(len("compressed body")))
+	testutil.Expect(t, string(readBody(t, simulated)), "compressed body")
+}
+
+func TestDecompressBodyDeflate(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write([]byte("deflated body"))
+	zw.Close()
+
+	_, simulated := captureEncoded(t, "deflate", buf.Bytes(), "identity")
+	testutil.Expect(t, simulated.Header.Get("Content-Encoding"), "")
+	testutil.Expect(t, string(readBody(t, simulated)), "deflated body")
+}
+
+func TestDecompressBodyLeavesOtherBodiesUnchanged(t *testing.T) {
+	_, simulated := captureEncoded(t, "", []byte("plain body"), "")
+	testutil.Expect(t, simulated.Header.Get("Content-Encoding"), "")
+	testutil.Expect(t, string(readBody(t, simulated)), "plain body")
+
+	// brotli can't be decoded, body is stored and simulated as it was received
+	brotli := []byte{0x0b, 0x02, 0x80, 0x68, 0x69, 0x03}
+	_, simulated = captureEncoded(t, "br", brotli, "gzip")
+	testutil.Expect(t, simulated.Header.Get("Content-Encoding"), "br")
+	testutil.Expect(t, bytes.Equal(readBody(t, simulated), brotli), true)
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, expected := range map[string]bool{
+		"":                    false,
+		"gzip":                true,
+		"deflate, gzip;q=1.0": true,
+		"*":                   true,
+		"gzip;q=0":            false,
+		"br, identity":        false,
+	} {
+		testutil.Expect(t, acceptsGzip(header), expected)
+	}
+}
@@ -0,0 +1,122 @@
+package hoverfly
+
+import (
+	"fmt"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultDeduplicateBloomFPRate - false positive rate of captured keys filter when DeduplicateBloomFPRate is not set
+const DefaultDeduplicateBloomFPRate = 0.01
+
+// captureBloomMinCapacity - smallest number of keys captured keys filter is sized for
+const captureBloomMinCapacity = 1024
+
+// isDuplicateCapture - with Cfg.DeduplicateCaptures, request with given fingerprint isn't stored again once it was
+// captured, the first captured response is kept. Keys that the captured keys filter hasn't seen are new without
+// reading the cache, the cache confirms the rest.
+func (d *Hoverfly) isDuplicateCapture(cfg *Configuration, key string) bool {
+	if !cfg.DeduplicateCaptures {
+		return false
+	}
+
+	if !d.captureBloom.mayContain(d, key) {
+		return false
+	}
+
+	_, err := d.RequestCache.Get([]byte(key))
+	if err != nil {
+		return false
+	}
+
+	d.Counter.CountDeduplicated()
+	log.WithFields(log.Fields{
+		"hashKey": key,
+		"mode":    CaptureMode,
+	}).Debug("request already captured, skipping duplicate")
+	return true
+}
+
+// validateDeduplicateBloomFPRate - false positive rate has to be between 0 and 1, 0 is the default rate
+func validateDeduplicateBloomFPRate(rate float64) error {
+	if rate < 0 || rate >= 1 {
+		return fmt.Errorf("deduplicate Bloom filter false positive rate must be between 0 and 1, got %v", rate)
+	}
+	return nil
+}
+
+// captureBloom - Bloom filter of request cache keys used by isDuplicateCapture. It is built from the cache on the
+// first check and rebuilt with twice the capacity when it gets full, keys that are stored afterwards are added to it.
+// Deleted keys stay in the filter and are reported by the cache as not captured.
+type captureBloom struct {
+	mu     sync.Mutex
+	filter *bloomFilter
+}
+
+func newCaptureBloom() *captureBloom {
+	return &captureBloom{}
+}
+
+// mayContain - false when key is certainly not in request cache, true when filter is not used
+func (b *captureBloom) mayContain(d *Hoverfly, key string) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.filter == nil || b.filter.full() {
+		capacity := captureBloomMinCapacity
+		if b.filter != nil {
+			capacity = 2 * b.filter.capacity
+		}
+		if !b.build(d, capacity) {
+			return true
+		}
+	}
+	return b.filter.mayContain(key)
+}
+
+// build - fills new filter with keys of request cache, filter isn't used when keys can't be read
+func (b *captureBloom) build(d *Hoverfly, capacity int) bool {
+	keys, err := d.RequestCache.GetAllKeys()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("Failed to read request cache keys, captures are deduplicated without Bloom filter")
+		b.filter = nil
+		return false
+	}
+	for capacity < 2*len(keys) {
+		capacity *= 2
+	}
+
+	fpRate := d.Cfg.DeduplicateBloomFPRate
+	if fpRate == 0 {
+		fpRate = DefaultDeduplicateBloomFPRate
+	}
+	b.filter = newBloomFilter(capacity, fpRate)
+	for key := range keys {
+		b.filter.add(key)
+	}
+
+	log.WithFields(log.Fields{
+		"keys":     len(keys),
+		"capacity": capacity,
+		"fpRate":   fpRate,
+	}).Debug("Bloom filter of captured requests built")
+	return true
+}
+
+// add - adds key stored in request cache, keys stored before filter is built are read from the cache
+func (b *captureBloom) add(key string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	if b.filter != nil {
+		b.filter.add(key)
+	}
+	b.mu.Unlock()
+}
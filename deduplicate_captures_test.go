@@ -0,0 +1,156 @@
+package hoverfly
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// setCountingCache - request cache that counts writes
+type setCountingCache struct {
+	cache.Cache
+	sets *int
+}
+
+func (c setCountingCache) Set(key, value []byte) error {
+	*c.sets++
+	return c.Cache.Set(key, value)
+}
+
+func captureURL(t *testing.T, dbClient *Hoverfly, url string) {
+	req, err := http.NewRequest("GET", url, nil)
+	testutil.Expect(t, err, nil)
+	_, err = dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+}
+
+func TestDeduplicateCaptures(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	sets := 0
+	dbClient.RequestCache = setCountingCache{Cache: dbClient.RequestCache, sets: &sets}
+	dbClient.Cfg.DeduplicateCaptures = true
+
+	captureURL(t, dbClient, "http://example.com/dedup")
+	testutil.Expect(t, sets, 1)
+
+	captureURL(t, dbClient, "http://example.com/dedup")
+	testutil.Expect(t, sets, 1)
+	testutil.Expect(t, dbClient.Counter.Deduplicated.Count(), int64(1))
+
+	captureURL(t, dbClient, "http://example.com/other")
+	testutil.Expect(t, sets, 2)
+
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 2)
+}
+
+func TestCapturesAreStoredAgainWithoutDeduplication(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	sets := 0
+	dbClient.RequestCache = setCountingCache{Cache: dbClient.RequestCache, sets: &sets}
+
+	captureURL(t, dbClient, "http://example.com/dedup")
+	captureURL(t, dbClient, "http://example.com/dedup")
+	testutil.Expect(t, sets, 2)
+	testutil.Expect(t, dbClient.Counter.Deduplicated.Count(), int64(0))
+}
+
+// getCountingCache - request cache that counts reads of single keys
+type getCountingCache struct {
+	cache.Cache
+	gets *int
+}
+
+func (c getCountingCache) Get(key []byte) ([]byte, error) {
+	*c.gets++
+	return c.Cache.Get(key)
+}
+
+func TestDeduplicateCapturesSkipsCacheReadOfNewRequests(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	gets := 0
+	dbClient.RequestCache = getCountingCache{Cache: dbClient.RequestCache, gets: &gets}
+	dbClient.Cfg.DeduplicateCaptures = true
+
+	isNew := func(url string) bool {
+		req, err := http.NewRequest("GET", url, nil)
+		testutil.Expect(t, err, nil)
+		return !dbClient.isDuplicateCapture(dbClient.Cfg, dbClient.getRequestFingerprint(req, nil))
+	}
+
+	testutil.Expect(t, isNew("http://example.com/first"), true)
+	testutil.Expect(t, gets, 0)
+
+	captureURL(t, dbClient, "http://example.com/first")
+	reads := gets
+	testutil.Expect(t, isNew("http://example.com/first"), false)
+	testutil.Expect(t, gets, reads+1)
+
+	for i := 0; i < 100; i++ {
+		isNew(fmt.Sprintf("http://example.com/new/%d", i))
+	}
+	// default false positive rate is 1%
+	testutil.Expect(t, gets-reads-1 < 5, true)
+}
+
+func TestDeduplicateCapturesSeesImportedRecords(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.DeduplicateCaptures = true
+	captureURL(t, dbClient, "http://example.com/first")
+
+	// filter is built by now, imported record is added to it
+	err := dbClient.ImportPayloads([]models.PayloadView{{
+		Request:  models.RequestDetailsView{Method: "GET", Scheme: "http", Destination: "example.com", Path: "/imported"},
+		Response: models.ResponseDetailsView{Status: 200, Body: "imported"},
+	}})
+	testutil.Expect(t, err, nil)
+
+	req, err := http.NewRequest("GET", "http://example.com/imported", nil)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, dbClient.isDuplicateCapture(dbClient.Cfg, dbClient.getRequestFingerprint(req, nil)), true)
+}
+
+func TestCaptureBloomIsRebuiltWhenFull(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	b := dbClient.captureBloom
+	testutil.Expect(t, b.mayContain(dbClient, "missing"), false)
+	testutil.Expect(t, b.filter.capacity, captureBloomMinCapacity)
+
+	for i := 0; i <= captureBloomMinCapacity; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		dbClient.RequestCache.Set([]byte(key), []byte("value"))
+		b.add(key)
+	}
+	testutil.Expect(t, b.filter.full(), true)
+
+	testutil.Expect(t, b.mayContain(dbClient, "key-0"), true)
+	testutil.Expect(t, b.filter.capacity, 4*captureBloomMinCapacity)
+	testutil.Expect(t, b.filter.full(), false)
+}
+
+func TestValidateDeduplicateBloomFPRate(t *testing.T) {
+	testutil.Expect(t, validateDeduplicateBloomFPRate(0), nil)
+	testutil.Expect(t, validateDeduplicateBloomFPRate(0.001), nil)
+	testutil.Refute(t, validateDeduplicateBloomFPRate(1), nil)
+	testutil.Refute(t, validateDeduplicateBloomFPRate(-0.1), nil)
+}
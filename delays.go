@@ -0,0 +1,247 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DelayDistribution - describes how delay is picked between profile minimum and maximum
+type DelayDistribution string
+
+// Delay distributions
+const (
+	DelayConstant DelayDistribution = "constant"
+	DelayUniform  DelayDistribution = "uniform"
+	DelayNormal   DelayDistribution = "normal"
+)
+
+// DefaultDelayProfile - name of the profile created from '-response-delay' flag
+const DefaultDelayProfile = "default"
+
+// StatusDelayWildcard - ResponseDelayByStatus key that matches every status that isn't listed explicitly
+const StatusDelayWildcard = -1
+
+// responseDelayPatternPrefix - prefix of profile names created from '-response-delay-pattern' flags
+const responseDelayPatternPrefix = "pattern:"
+
+// DelayProfile - named response delay in milliseconds, constant profile always uses MinMs
+type DelayProfile struct {
+	MinMs        uint64            `json:"minMs"`
+	MaxMs        uint64            `json:"maxMs"`
+	Distribution DelayDistribution `json:"distribution"`
+}
+
+// EndpointDelay - assigns delay profile to requests which host and path match given regexp pattern
+type EndpointDelay struct {
+	Pattern string `json:"pattern"`
+	Profile string `json:"profile"`
+
+	// compiled Pattern, set by SetDelays or when delay is added
+	re *regexp.Regexp
+}
+
+type delayConfig struct {
+	Profiles  map[string]DelayProfile `json:"profiles"`
+	Endpoints []EndpointDelay         `json:"endpoints"`
+}
+
+// Validate - checks whether profile can be used
+func (p DelayProfile) Validate() error {
+	switch p.Distribution {
+	case DelayConstant:
+		return nil
+	case DelayUniform, DelayNormal:
+		if p.MaxMs < p.MinMs {
+			return fmt.Errorf("maxMs (%d) is lower than minMs (%d)", p.MaxMs, p.MinMs)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown distribution '%s', expected one of: %s, %s, %s", p.Distribution, DelayConstant, DelayUniform, DelayNormal)
+}
+
+// Duration - picks delay according to profile distribution
+func (p DelayProfile) Duration() time.Duration {
+	ms := p.MinMs
+
+	switch p.Distribution {
+	case DelayUniform:
+		ms = p.MinMs + uint64(rand.Int63n(int64(p.MaxMs-p.MinMs)+1))
+	case DelayNormal:
+		// 99.7% of values fall within min and max, the rest is clamped
+		mean := float64(p.MinMs+p.MaxMs) / 2
+		stdDev := float64(p.MaxMs-p.MinMs) / 6
+		v := rand.NormFloat64()*stdDev + mean
+		if v < float64(p.MinMs) {
+			v = float64(p.MinMs)
+		}
+		if v > float64(p.MaxMs) {
+			v = float64(p.MaxMs)
+		}
+		ms = uint64(v)
+	}
+
+	return time.Duration(ms) * time.Millisecond
+}
+
+// SetResponseDelay - applies constant delay to every endpoint that is not matched by response delay pattern
+func (c *Configuration) SetResponseDelay(ms uint64) {
+	if c.DelayProfiles == nil {
+		c.DelayProfiles = make(map[string]DelayProfile)
+	}
+	c.DelayProfiles[DefaultDelayProfile] = DelayProfile{MinMs: ms, MaxMs: ms, Distribution: DelayConstant}
+
+	endpoints := make([]EndpointDelay, 0, len(c.EndpointDelays)+1)
+	for _, endpoint := range c.EndpointDelays {
+		if endpoint.Profile != DefaultDelayProfile {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	c.EndpointDelays = append(endpoints, EndpointDelay{Pattern: ".", Profile: DefaultDelayProfile, re: regexp.MustCompile(".")})
+}
+
+// SetResponseDelayPattern - applies constant delay to requests which host and path match given regexp pattern,
+// patterns are checked in the order they were added and before delay set with SetResponseDelay
+func (c *Configuration) SetResponseDelayPattern(pattern string, ms uint64) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid response delay pattern '%s': %s", pattern, err.Error())
+	}
+
+	name := responseDelayPatternPrefix + pattern
+	if c.DelayProfiles == nil {
+		c.DelayProfiles = make(map[string]DelayProfile)
+	}
+	c.DelayProfiles[name] = DelayProfile{MinMs: ms, MaxMs: ms, Distribution: DelayConstant}
+
+	endpoint := EndpointDelay{Pattern: pattern, Profile: name, re: re}
+	// global delay matches everything so it has to stay last
+	last := len(c.EndpointDelays) - 1
+	if last >= 0 && c.EndpointDelays[last].Profile == DefaultDelayProfile {
+		c.EndpointDelays = append(c.EndpointDelays[:last], endpoint, c.EndpointDelays[last])
+		return nil
+	}
+	c.EndpointDelays = append(c.EndpointDelays, endpoint)
+	return nil
+}
+
+// ParseResponseDelayPattern - parses 'pattern=milliseconds' value of '-response-delay-pattern' flag
+func ParseResponseDelayPattern(value string) (string, uint64, error) {
+	i := strings.LastIndex(value, "=")
+	if i <= 0 {
+		return "", 0, fmt.Errorf("expected 'pattern=milliseconds', got '%s'", value)
+	}
+	ms, err := strconv.ParseUint(value[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid delay in '%s': %s", value, err.Error())
+	}
+	return value[:i], ms, nil
+}
+
+// LoadDelays - reads delay profiles and endpoint assignments from JSON file, i.e.:
+//
+//	{"profiles": {"slow": {"minMs": 500, "maxMs": 2000, "distribution": "uniform"}},
+//	 "endpoints": [{"pattern": "api.example.com/payments", "profile": "slow"}]}
+func (c *Configuration) LoadDelays(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var dc delayConfig
+	if err := json.NewDecoder(f).Decode(&dc); err != nil {
+		return fmt.Errorf("failed to parse delays file: %s", err.Error())
+	}
+
+	return c.SetDelays(dc.Profiles, dc.Endpoints)
+}
+
+// SetDelays - validates delay profiles and compiles endpoint patterns, endpoints are checked in the given order
+func (c *Configuration) SetDelays(profiles map[string]DelayProfile, endpoints []EndpointDelay) error {
+	for name, profile := range profiles {
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("invalid delay profile '%s': %s", name, err.Error())
+		}
+	}
+
+	compiled := make([]EndpointDelay, len(endpoints))
+	for i, endpoint := range endpoints {
+		re, err := regexp.Compile(endpoint.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid endpoint pattern '%s': %s", endpoint.Pattern, err.Error())
+		}
+		if _, ok := profiles[endpoint.Profile]; !ok {
+			return fmt.Errorf("endpoint pattern '%s' refers to unknown delay profile '%s'", endpoint.Pattern, endpoint.Profile)
+		}
+		endpoint.re = re
+		compiled[i] = endpoint
+	}
+
+	c.DelayProfiles = profiles
+	c.EndpointDelays = compiled
+
+	return nil
+}
+
+// GetDelayProfile - returns name and profile of the first endpoint delay that matches given host and path
+func (c *Configuration) GetDelayProfile(host, path string) (string, *DelayProfile) {
+	for _, endpoint := range c.EndpointDelays {
+		if endpoint.re == nil || !endpoint.re.MatchString(host+path) {
+			continue
+		}
+		if profile, ok := c.DelayProfiles[endpoint.Profile]; ok {
+			return endpoint.Profile, &profile
+		}
+	}
+	return "", nil
+}
+
+// GetStatusDelay - delay in milliseconds for responses with given status, exact status is preferred over
+// StatusDelayWildcard
+func (c *Configuration) GetStatusDelay(status int) (int, bool) {
+	if ms, ok := c.ResponseDelayByStatus[status]; ok {
+		return ms, true
+	}
+	ms, ok := c.ResponseDelayByStatus[StatusDelayWildcard]
+	return ms, ok
+}
+
+// ParseResponseDelayStatus - parses 'status=milliseconds' value of '-response-delay-status' flag, status -1
+// matches every status that isn't listed explicitly
+func ParseResponseDelayStatus(value string) (int, int, error) {
+	i := strings.Index(value, "=")
+	if i <= 0 {
+		return 0, 0, fmt.Errorf("expected 'status=milliseconds', got '%s'", value)
+	}
+	status, err := strconv.Atoi(value[:i])
+	if err != nil || (status != StatusDelayWildcard && (status < 100 || status > 599)) {
+		return 0, 0, fmt.Errorf("invalid status in '%s', expected 100-599 or %d", value, StatusDelayWildcard)
+	}
+	ms, err := strconv.Atoi(value[i+1:])
+	if err != nil || ms < 0 {
+		return 0, 0, fmt.Errorf("invalid delay in '%s'", value)
+	}
+	return status, ms, nil
+}
+
+// responseDelay - delay of simulated response, delay for response status takes priority over delay profiles
+// matched by host and path (including '-response-delay')
+func (d *Hoverfly) responseDelay(req *http.Request, resp *http.Response) (string, time.Duration, bool) {
+	cfg := d.requestConfig(req)
+	if resp != nil {
+		if ms, ok := cfg.GetStatusDelay(resp.StatusCode); ok {
+			return "status:" + strconv.Itoa(resp.StatusCode), time.Duration(ms) * time.Millisecond, true
+		}
+	}
+	if name, profile := cfg.GetDelayProfile(req.Host, req.URL.Path); profile != nil {
+		return name, profile.Duration(), true
+	}
+	return "", 0, false
+}
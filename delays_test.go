@@ -0,0 +1,254 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestDelayProfileConstant(t *testing.T) {
+	profile := DelayProfile{MinMs: 100, MaxMs: 500, Distribution: DelayConstant}
+	testutil.Expect(t, profile.Duration(), 100*time.Millisecond)
+}
+
+func TestDelayProfileWithinRange(t *testing.T) {
+	for _, distribution := range []DelayDistribution{DelayUniform, DelayNormal} {
+		profile := DelayProfile{MinMs: 100, MaxMs: 200, Distribution: distribution}
+		for i := 0; i < 100; i++ {
+			d := profile.Duration()
+			if d < 100*time.Millisecond || d > 200*time.Millisecond {
+				t.Fatalf("%s delay %s out of range", distribution, d)
+			}
+		}
+	}
+}
+
+func TestDelayProfileValidate(t *testing.T) {
+	testutil.Expect(t, DelayProfile{MinMs: 10, Distribution: DelayConstant}.Validate(), nil)
+	testutil.Expect(t, DelayProfile{MinMs: 10, MaxMs: 20, Distribution: DelayUniform}.Validate(), nil)
+	testutil.Refute(t, DelayProfile{MinMs: 20, MaxMs: 10, Distribution: DelayNormal}.Validate(), nil)
+	testutil.Refute(t, DelayProfile{MinMs: 10, MaxMs: 20, Distribution: "poisson"}.Validate(), nil)
+}
+
+func TestGetDelayProfile(t *testing.T) {
+	cfg := InitSettings()
+	profiles := map[string]DelayProfile{
+		"slow": {MinMs: 1000, Distribution: DelayConstant},
+		"fast": {MinMs: 10, Distribution: DelayConstant},
+	}
+	err := cfg.SetDelays(profiles, []EndpointDelay{
+		{Pattern: "example.com/payments", Profile: "slow"},
+		{Pattern: "example.com", Profile: "fast"},
+	})
+	testutil.Expect(t, err, nil)
+
+	name, profile := cfg.GetDelayProfile("example.com", "/payments/1")
+	testutil.Expect(t, name, "slow")
+	testutil.Expect(t, profile.MinMs, uint64(1000))
+
+	name, profile = cfg.GetDelayProfile("example.com", "/users")
+	testutil.Expect(t, name, "fast")
+
+	name, profile = cfg.GetDelayProfile("other.com", "/users")
+	testutil.Expect(t, name, "")
+	testutil.Expect(t, profile == nil, true)
+}
+
+func TestSetResponseDelay(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetResponseDelay(50)
+
+	name, profile := cfg.GetDelayProfile("anything.com", "/")
+	testutil.Expect(t, name, DefaultDelayProfile)
+	testutil.Expect(t, profile.Duration(), 50*time.Millisecond)
+}
+
+func TestLoadDelays(t *testing.T) {
+	f, err := ioutil.TempFile("", "delays")
+	testutil.Expect(t, err, nil)
+	defer os.Remove(f.Name())
+
+	f.WriteString(`{"profiles": {"slow": {"minMs": 500, "maxMs": 2000, "distribution": "uniform"}},
+		"endpoints": [{"pattern": "api.example.com/payments", "profile": "slow"}]}`)
+	f.Close()
+
+	cfg := InitSettings()
+	err = cfg.LoadDelays(f.Name())
+	testutil.Expect(t, err, nil)
+
+	name, profile := cfg.GetDelayProfile("api.example.com", "/payments")
+	testutil.Expect(t, name, "slow")
+	testutil.Expect(t, profile.Distribution, DelayUniform)
+	testutil.Expect(t, profile.MaxMs, uint64(2000))
+}
+
+func TestLoadDelaysUnknownProfile(t *testing.T) {
+	f, err := ioutil.TempFile("", "delays")
+	testutil.Expect(t, err, nil)
+	defer os.Remove(f.Name())
+
+	f.WriteString(`{"profiles": {}, "endpoints": [{"pattern": ".", "profile": "slow"}]}`)
+	f.Close()
+
+	cfg := InitSettings()
+	err = cfg.LoadDelays(f.Name())
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, len(cfg.EndpointDelays), 0)
+}
+
+func TestResponseDelayPatternMatches(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetResponseDelay(50)
+	testutil.Expect(t, cfg.SetResponseDelayPattern("example.com/api/search", 800), nil)
+
+	_, profile := cfg.GetDelayProfile("example.com", "/api/search")
+	testutil.Expect(t, profile.Duration(), 800*time.Millisecond)
+}
+
+func TestResponseDelayPatternFallsBackToResponseDelay(t *testing.T) {
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.SetResponseDelayPattern("example.com/api/search", 800), nil)
+	// pattern still takes precedence when global delay is set afterwards
+	cfg.SetResponseDelay(50)
+
+	name, profile := cfg.GetDelayProfile("example.com", "/api/health")
+	testutil.Expect(t, name, DefaultDelayProfile)
+	testutil.Expect(t, profile.Duration(), 50*time.Millisecond)
+
+	_, profile = cfg.GetDelayProfile("example.com", "/api/search")
+	testutil.Expect(t, profile.Duration(), 800*time.Millisecond)
+}
+
+func TestResponseDelayPatternNoMatch(t *testing.T) {
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.SetResponseDelayPattern("example.com/api/search", 800), nil)
+
+	_, profile := cfg.GetDelayProfile("example.com", "/api/health")
+	testutil.Expect(t, profile == nil, true)
+}
+
+func TestSetResponseDelayPatternInvalid(t *testing.T) {
+	cfg := InitSettings()
+	testutil.Refute(t, cfg.SetResponseDelayPattern("api/(search", 800), nil)
+	testutil.Expect(t, len(cfg.EndpointDelays), 0)
+}
+
+func TestParseResponseDelayPattern(t *testing.T) {
+	pattern, ms, err := ParseResponseDelayPattern("example.com/search?q=a=800")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, pattern, "example.com/search?q=a")
+	testutil.Expect(t, ms, uint64(800))
+
+	_, _, err = ParseResponseDelayPattern("example.com/search")
+	testutil.Refute(t, err, nil)
+
+	_, _, err = ParseResponseDelayPattern("example.com/search=slow")
+	testutil.Refute(t, err, nil)
+}
+
+func delayFor(t *testing.T, d *Hoverfly, status int) (string, time.Duration, bool) {
+	req, err := http.NewRequest("GET", "http://example.com/api/search", nil)
+	testutil.Expect(t, err, nil)
+	return d.responseDelay(req, &http.Response{StatusCode: status})
+}
+
+func TestResponseDelayByStatusTakesPriority(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.SetResponseDelay(50)
+	testutil.Expect(t, dbClient.Cfg.SetResponseDelayPattern("example.com/api/search", 800), nil)
+	dbClient.Cfg.ResponseDelayByStatus = map[int]int{200: 10, 429: 2000}
+
+	name, delay, ok := delayFor(t, dbClient, 429)
+	testutil.Expect(t, ok, true)
+	testutil.Expect(t, name, "status:429")
+	testutil.Expect(t, delay, 2000*time.Millisecond)
+
+	_, delay, _ = delayFor(t, dbClient, 200)
+	testutil.Expect(t, delay, 10*time.Millisecond)
+
+	// status that isn't listed falls back to delay profiles
+	name, delay, ok = delayFor(t, dbClient, 404)
+	testutil.Expect(t, ok, true)
+	testutil.Expect(t, name, responseDelayPatternPrefix+"example.com/api/search")
+	testutil.Expect(t, delay, 800*time.Millisecond)
+}
+
+func TestResponseDelayByStatusWildcard(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.SetResponseDelay(50)
+	dbClient.Cfg.ResponseDelayByStatus = map[int]int{200: 10, StatusDelayWildcard: 30000}
+
+	// exact status is preferred over wildcard
+	_, delay, _ := delayFor(t, dbClient, 200)
+	testutil.Expect(t, delay, 10*time.Millisecond)
+
+	// wildcard is preferred over global delay
+	_, delay, _ = delayFor(t, dbClient, 503)
+	testutil.Expect(t, delay, 30000*time.Millisecond)
+}
+
+func TestResponseDelayByStatusNoMatch(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.ResponseDelayByStatus = map[int]int{429: 2000}
+
+	_, delay, ok := delayFor(t, dbClient, 200)
+	testutil.Expect(t, ok, false)
+	testutil.Expect(t, delay, time.Duration(0))
+
+	// global delay applies when status isn't listed
+	dbClient.Cfg.SetResponseDelay(50)
+	name, delay, ok := delayFor(t, dbClient, 200)
+	testutil.Expect(t, ok, true)
+	testutil.Expect(t, name, DefaultDelayProfile)
+	testutil.Expect(t, delay, 50*time.Millisecond)
+}
+
+func TestResponseDelayByStatusNotAppliedInCapture(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.ResponseDelayByStatus = map[int]int{StatusDelayWildcard: 300}
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	req, err := http.NewRequest("GET", "http://example.com/api/search", nil)
+	testutil.Expect(t, err, nil)
+	start := time.Now()
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, time.Since(start) < 300*time.Millisecond, true)
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	req, err = http.NewRequest("GET", "http://example.com/api/search", nil)
+	testutil.Expect(t, err, nil)
+	start = time.Now()
+	_, resp = dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, time.Since(start) >= 300*time.Millisecond, true)
+}
+
+func TestParseResponseDelayStatus(t *testing.T) {
+	status, ms, err := ParseResponseDelayStatus("429=2000")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, status, 429)
+	testutil.Expect(t, ms, 2000)
+
+	status, _, err = ParseResponseDelayStatus("-1=30000")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, status, StatusDelayWildcard)
+
+	for _, value := range []string{"429", "=10", "abc=10", "99=10", "600=10", "-2=10", "429=-5", "429=slow"} {
+		_, _, err = ParseResponseDelayStatus(value)
+		testutil.Refute(t, err, nil)
+	}
+}
@@ -0,0 +1,39 @@
+package hoverfly
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// validateDestinations - checks that every destination is a valid regular expression
+func validateDestinations(destinations []string) error {
+	for _, destination := range destinations {
+		if _, err := regexp.Compile(destination); err != nil {
+			return fmt.Errorf("destination '%s' is not a valid regular expression: %s", destination, err.Error())
+		}
+	}
+	return nil
+}
+
+// destinationPatterns - compiled Destinations, host of intercepted request has to match at least one of them.
+// Without destinations every host is intercepted, same as with an empty pattern.
+func (c *Configuration) destinationPatterns() []*regexp.Regexp {
+	if len(c.Destinations) == 0 {
+		return []*regexp.Regexp{regexp.MustCompile("")}
+	}
+	patterns := make([]*regexp.Regexp, len(c.Destinations))
+	for i, destination := range c.Destinations {
+		patterns[i] = regexp.MustCompile(destination)
+	}
+	return patterns
+}
+
+// isDestination - checks whether requests to given host are intercepted
+func (c *Configuration) isDestination(host string) bool {
+	for _, pattern := range c.destinationPatterns() {
+		if pattern.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package hoverfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestMultipleDestinations(t *testing.T) {
+	upstream := func() *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	api, auth, other := upstream(), upstream(), upstream()
+	defer api.Close()
+	defer auth.Close()
+	defer other.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	// nothing is recorded, intercepted requests aren't found in cache
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.Destinations = []string{
+		regexp.QuoteMeta(api.Listener.Addr().String()) + "$",
+		regexp.QuoteMeta(auth.Listener.Addr().String()) + "$",
+	}
+	dbClient.UpdateProxy()
+
+	proxy := httptest.NewServer(dbClient.Proxy)
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	testutil.Expect(t, err, nil)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	for _, intercepted := range []string{api.URL, auth.URL} {
+		resp, err := client.Get(intercepted)
+		testutil.Expect(t, err, nil)
+		resp.Body.Close()
+		testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+	}
+
+	resp, err := client.Get(other.URL)
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, resp.Header.Get("Hoverfly"), "")
+}
+
+func TestUpdateDestinationsInvalid(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.Destinations = []string{"."}
+
+	err := dbClient.UpdateDestinations([]string{"api.internal", "e^^**#"})
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, len(dbClient.Cfg.Destinations), 1)
+	testutil.Expect(t, dbClient.Cfg.Destinations[0], ".")
+}
+
+func TestDestinationPatternsDefault(t *testing.T) {
+	cfg := InitSettings()
+
+	patterns := cfg.destinationPatterns()
+	testutil.Expect(t, len(patterns), 1)
+	testutil.Expect(t, patterns[0].MatchString("any.host"), true)
+}
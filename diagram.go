@@ -0,0 +1,98 @@
+package hoverfly
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// diagramEvents - how many of the latest proxy timeline events are drawn
+const diagramEvents = 10
+
+// diagramForwardingModes - modes in which requests reach upstream
+var diagramForwardingModes = map[string]bool{
+	CaptureMode: true,
+	ModifyMode:  true,
+	SpyMode:     true,
+	DiffMode:    true,
+	ChaosMode:   true,
+}
+
+// MermaidDiagram - Mermaid sequence diagram of current mode, middleware and destinations followed by the latest
+// proxy timeline events as messages between Client, Hoverfly and Upstream
+func (d *Hoverfly) MermaidDiagram() (string, error) {
+	events, err := d.GetTimeline(time.Time{}, time.Time{})
+	if err != nil {
+		return "", err
+	}
+
+	var proxied []TimelineEvent
+	for _, event := range events {
+		if event.Type == TimelineEventProxy && event.Payload != nil {
+			proxied = append(proxied, event)
+		}
+	}
+	if len(proxied) > diagramEvents {
+		proxied = proxied[len(proxied)-diagramEvents:]
+	}
+
+	middleware := d.Cfg.GetMiddleware()
+	if middleware == "" {
+		middleware = "none"
+	}
+	destinations := "all hosts"
+	if len(d.Cfg.Destinations) > 0 {
+		destinations = strings.Join(d.Cfg.Destinations, ", ")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("sequenceDiagram\n")
+	buf.WriteString("    participant Client\n")
+	buf.WriteString("    participant Hoverfly\n")
+	buf.WriteString("    participant Upstream\n")
+	fmt.Fprintf(&buf, "    Note over Hoverfly: mode: %s\n", mermaidText(d.Cfg.GetMode()))
+	fmt.Fprintf(&buf, "    Note over Hoverfly: middleware: %s\n", mermaidText(middleware))
+	fmt.Fprintf(&buf, "    Note over Hoverfly: destinations: %s\n", mermaidText(destinations))
+
+	for _, event := range proxied {
+		request := mermaidText(fmt.Sprintf("%s %s%s", event.Payload.Request.Method, event.Payload.Request.Destination, event.Payload.Request.Path))
+		status := event.Payload.Response.Status
+
+		fmt.Fprintf(&buf, "    Client->>Hoverfly: %s\n", request)
+		if diagramForwardingModes[event.Action] {
+			fmt.Fprintf(&buf, "    Hoverfly->>Upstream: %s\n", request)
+			fmt.Fprintf(&buf, "    Upstream-->>Hoverfly: %d\n", status)
+		}
+		fmt.Fprintf(&buf, "    Hoverfly-->>Client: %d (%s)\n", status, mermaidText(event.Action))
+	}
+
+	return buf.String(), nil
+}
+
+var mermaidEscaper = strings.NewReplacer("#", "#35;", ";", "#59;")
+
+// mermaidText - escapes characters that end Mermaid statements or start entity codes
+func mermaidText(text string) string {
+	text = mermaidEscaper.Replace(text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// DiagramHandler - returns Mermaid sequence diagram of proxy state and latest proxied requests, generated on every
+// request
+func (d *Hoverfly) DiagramHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	diagram, err := d.MermaidDiagram()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to generate diagram")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	w.Write([]byte(diagram))
+}
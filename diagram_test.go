@@ -0,0 +1,86 @@
+package hoverfly
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func diagramProxyEvent(at time.Time, mode, path string, status int) TimelineEvent {
+	return TimelineEvent{
+		Time:   at,
+		Type:   TimelineEventProxy,
+		Action: mode,
+		Payload: &models.PayloadView{
+			Request:  models.RequestDetailsView{Method: "GET", Destination: "example.com", Path: path},
+			Response: models.ResponseDetailsView{Status: status},
+		},
+	}
+}
+
+func TestMermaidDiagram(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.SetMiddleware("./middleware.py")
+	dbClient.Cfg.Destinations = []string{"example.com", "api.example.com"}
+
+	now := time.Now()
+	for i := 0; i < 11; i++ {
+		dbClient.recordTimelineEvent(diagramProxyEvent(now.Add(time.Duration(i-20)*time.Second), SimulateMode, fmt.Sprintf("/simulated/%d", i), 200))
+	}
+	dbClient.recordTimelineEvent(diagramProxyEvent(now, CaptureMode, "/captured#1;", 201))
+	dbClient.recordTimelineEvent(TimelineEvent{Time: now, Type: TimelineEventAdmin, Action: ActionTypeWipeDB})
+
+	diagram, err := dbClient.MermaidDiagram()
+	testutil.Expect(t, err, nil)
+
+	lines := strings.Split(strings.TrimSpace(diagram), "\n")
+	testutil.Expect(t, lines[0], "sequenceDiagram")
+	testutil.Expect(t, strings.Contains(diagram, "Note over Hoverfly: mode: simulate\n"), true)
+	testutil.Expect(t, strings.Contains(diagram, "Note over Hoverfly: middleware: ./middleware.py\n"), true)
+	testutil.Expect(t, strings.Contains(diagram, "Note over Hoverfly: destinations: example.com, api.example.com\n"), true)
+
+	// oldest two events are dropped, admin events aren't drawn
+	testutil.Expect(t, strings.Contains(diagram, "/simulated/0\n"), false)
+	testutil.Expect(t, strings.Contains(diagram, "/simulated/1\n"), false)
+	testutil.Expect(t, strings.Contains(diagram, "Client->>Hoverfly: GET example.com/simulated/2\n"), true)
+	testutil.Expect(t, strings.Count(diagram, "Client->>Hoverfly:"), 10)
+	testutil.Expect(t, strings.Contains(diagram, ActionTypeWipeDB), false)
+
+	// only captured request reached upstream
+	testutil.Expect(t, strings.Count(diagram, "Hoverfly->>Upstream:"), 1)
+	testutil.Expect(t, strings.Contains(diagram, "Hoverfly->>Upstream: GET example.com/captured#35;1#59;\n"), true)
+	testutil.Expect(t, strings.Contains(diagram, "Upstream-->>Hoverfly: 201\n"), true)
+	testutil.Expect(t, lines[len(lines)-1], "    Hoverfly-->>Client: 201 (capture)")
+}
+
+func TestDiagramHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "/api/diagram", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+	testutil.Expect(t, rec.Header().Get("Content-Type"), "text/plain; charset=UTF-8")
+	testutil.Expect(t, strings.Contains(rec.Body.String(), "Note over Hoverfly: middleware: none\n"), true)
+	testutil.Expect(t, strings.Contains(rec.Body.String(), "Note over Hoverfly: destinations: all hosts\n"), true)
+
+	// diagram is generated on every request
+	dbClient.recordTimelineEvent(diagramProxyEvent(time.Now(), SimulateMode, "/new", 200))
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, strings.Contains(rec.Body.String(), "Client->>Hoverfly: GET example.com/new\n"), true)
+}
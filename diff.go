@@ -0,0 +1,266 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/cache"
+)
+
+// Difference - single mismatch between simulated and live response, field is 'status', 'body' or
+// 'header:<name>'
+type Difference struct {
+	Field     string `json:"field"`
+	Simulated string `json:"simulated"`
+	Live      string `json:"live"`
+}
+
+// DiffRecord - mismatches found for one request in diff mode
+type DiffRecord struct {
+	Method      string       `json:"method"`
+	Destination string       `json:"destination"`
+	Path        string       `json:"path"`
+	Query       string       `json:"query"`
+	Time        time.Time    `json:"time"`
+	LiveError   string       `json:"liveError,omitempty"`
+	Differences []Difference `json:"differences"`
+}
+
+// DiffStore - keeps diff records in cache, records are returned in the order they were added
+type DiffStore struct {
+	cache cache.Cache
+	seq   uint64
+}
+
+// NewDiffStore - returns diff store backed by given cache
+func NewDiffStore(c cache.Cache) *DiffStore {
+	return &DiffStore{cache: c}
+}
+
+// Add - stores diff record
+func (s *DiffStore) Add(record DiffRecord) error {
+	bts, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	// keys sort in insertion order, sequence keeps records added at the same time apart
+	key := fmt.Sprintf("%020d-%010d", record.Time.UnixNano(), atomic.AddUint64(&s.seq, 1))
+	return s.cache.Set([]byte(key), bts)
+}
+
+// GetAll - returns all stored diff records
+func (s *DiffStore) GetAll() ([]DiffRecord, error) {
+	keys, err := s.cache.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []DiffRecord{}
+	for _, key := range keys {
+		bts, err := s.cache.Get([]byte(key))
+		if err != nil {
+			return nil, err
+		}
+		var record DiffRecord
+		if err := json.Unmarshal(bts, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Delete - removes all diff records
+func (s *DiffStore) Delete() error {
+	return s.cache.DeleteData()
+}
+
+// diffRequest - gets simulated response and response from live backend for the same request, simulated response
+// is returned while mismatches between them are written to diff store
+func (d *Hoverfly) diffRequest(req *http.Request) *http.Response {
+	cfg := d.requestConfig(req)
+	var reqBody []byte
+	if req.Body != nil {
+		reqBody, _ = ioutil.ReadAll(req.Body)
+	}
+
+	live := *req
+	live.Header = cloneHeader(req.Header)
+	live.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	live.RequestURI = ""
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	simulated := d.getResponse(req)
+
+	record := DiffRecord{
+		Method:      req.Method,
+		Destination: req.Host,
+		Path:        req.URL.Path,
+		Query:       req.URL.RawQuery,
+		Time:        time.Now(),
+		Differences: []Difference{},
+	}
+
+	end := startOperation(&live, OperationUpstream)
+	liveResp, err := d.HTTP.Do(&live)
+	end(err)
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err.Error(),
+			"destination": req.Host,
+			"path":        req.URL.Path,
+		}).Warn("Failed to get live response for diff")
+		record.LiveError = err.Error()
+	} else {
+		defer liveResp.Body.Close()
+		record.Differences, err = cfg.diffResponses(simulated, liveResp)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Warn("Failed to compare responses")
+			record.LiveError = err.Error()
+		}
+	}
+
+	if record.LiveError != "" || len(record.Differences) > 0 {
+		if err := d.Diffs.Add(record); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Error("Failed to store diff")
+		}
+	}
+
+	return simulated
+}
+
+// diffResponses - compares status codes, headers and bodies of simulated and live responses
+func (c *Configuration) diffResponses(simulated, live *http.Response) ([]Difference, error) {
+	simulatedBody, err := extractBody(simulated)
+	if err != nil {
+		return nil, err
+	}
+	liveBody, err := ioutil.ReadAll(live.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.compareResponses(
+		simulated.StatusCode, live.StatusCode,
+		simulated.Header, live.Header,
+		simulatedBody, liveBody), nil
+}
+
+// compareResponses - returns differences between two responses, first response is reported as simulated and
+// second as live. Headers listed in DiffIgnoreHeaders are skipped.
+func (c *Configuration) compareResponses(status, otherStatus int, header, otherHeader http.Header, body, otherBody []byte) []Difference {
+	differences := []Difference{}
+
+	if status != otherStatus {
+		differences = append(differences, Difference{
+			Field:     "status",
+			Simulated: fmt.Sprintf("%d", status),
+			Live:      fmt.Sprintf("%d", otherStatus),
+		})
+	}
+
+	// header added by Hoverfly to captured responses
+	ignored := map[string]bool{"Hoverfly": true}
+	for _, h := range c.DiffIgnoreHeaders {
+		ignored[http.CanonicalHeaderKey(h)] = true
+	}
+
+	// headers in simulations don't have to be canonical
+	values := func(h http.Header) map[string]string {
+		joined := make(map[string]string)
+		for name, v := range h {
+			name = http.CanonicalHeaderKey(name)
+			if joined[name] != "" {
+				joined[name] += ", "
+			}
+			joined[name] += strings.Join(v, ", ")
+		}
+		return joined
+	}
+	first, second := values(header), values(otherHeader)
+
+	names := make([]string, 0, len(first)+len(second))
+	for name := range first {
+		names = append(names, name)
+	}
+	for name := range second {
+		if _, ok := first[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !ignored[name] && first[name] != second[name] {
+			differences = append(differences, Difference{Field: "header:" + name, Simulated: first[name], Live: second[name]})
+		}
+	}
+
+	if !c.bodiesEqual(body, otherBody) {
+		differences = append(differences, Difference{Field: "body", Simulated: string(body), Live: string(otherBody)})
+	}
+
+	return differences
+}
+
+// bodiesEqual - JSON bodies are compared by value, array order is ignored when DiffIgnoreJSONOrder is set.
+// Other bodies have to be identical.
+func (c *Configuration) bodiesEqual(simulated, live []byte) bool {
+	if bytes.Equal(simulated, live) {
+		return true
+	}
+
+	var s, l interface{}
+	if json.Unmarshal(simulated, &s) != nil || json.Unmarshal(live, &l) != nil {
+		return false
+	}
+	if c.DiffIgnoreJSONOrder {
+		s, l = sortJSONArrays(s), sortJSONArrays(l)
+	}
+	return reflect.DeepEqual(s, l)
+}
+
+// sortJSONArrays - sorts elements of all arrays in decoded JSON value by their encoding
+func sortJSONArrays(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = sortJSONArrays(item)
+		}
+	case []interface{}:
+		encoded := make([]string, len(value))
+		for i, item := range value {
+			value[i] = sortJSONArrays(item)
+			bts, _ := json.Marshal(value[i])
+			encoded[i] = string(bts)
+		}
+		sort.Sort(byEncoding{items: value, encoded: encoded})
+	}
+	return v
+}
+
+type byEncoding struct {
+	items   []interface{}
+	encoded []string
+}
+
+func (b byEncoding) Len() int           { return len(b.items) }
+func (b byEncoding) Less(i, j int) bool { return b.encoded[i] < b.encoded[j] }
+func (b byEncoding) Swap(i, j int) {
+	b.items[i], b.items[j] = b.items[j], b.items[i]
+	b.encoded[i], b.encoded[j] = b.encoded[j], b.encoded[i]
+}
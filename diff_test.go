@@ -0,0 +1,103 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// diffTools - upstream which responses can be changed after they were captured
+func diffTools(t *testing.T) (*httptest.Server, *Hoverfly, *string) {
+	body := `{"items": [1, 2]}`
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Version", "1")
+		w.Write([]byte(body))
+	}))
+
+	_, dbClient := testTools(200, `{'message': 'here'}`)
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	req, err := http.NewRequest("GET", upstream.URL+"/items", nil)
+	testutil.Expect(t, err, nil)
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, 200)
+
+	dbClient.Cfg.SetMode(DiffMode)
+	return upstream, dbClient, &body
+}
+
+func TestDiffModeReturnsSimulatedResponseAndStoresDiff(t *testing.T) {
+	upstream, dbClient, body := diffTools(t)
+	defer upstream.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	*body = `{"items": [1, 3]}`
+
+	req, err := http.NewRequest("GET", upstream.URL+"/items", nil)
+	testutil.Expect(t, err, nil)
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, 200)
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(respBody), `{"items": [1, 2]}`)
+
+	records, err := dbClient.Diffs.GetAll()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(records), 1)
+	testutil.Expect(t, records[0].Path, "/items")
+
+	var bodyDiff *Difference
+	for i := range records[0].Differences {
+		if records[0].Differences[i].Field == "body" {
+			bodyDiff = &records[0].Differences[i]
+		}
+	}
+	testutil.Refute(t, bodyDiff, nil)
+	testutil.Expect(t, bodyDiff.Live, `{"items": [1, 3]}`)
+}
+
+func TestDiffModeIgnoresHeadersAndJSONOrder(t *testing.T) {
+	upstream, dbClient, body := diffTools(t)
+	defer upstream.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	*body = `{"items": [2, 1]}`
+	dbClient.Cfg.DiffIgnoreHeaders = []string{"date", "content-length"}
+	dbClient.Cfg.DiffIgnoreJSONOrder = true
+
+	req, err := http.NewRequest("GET", upstream.URL+"/items", nil)
+	testutil.Expect(t, err, nil)
+	dbClient.processRequest(req)
+
+	records, err := dbClient.Diffs.GetAll()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(records), 0)
+}
+
+func TestDiffResponsesHeaders(t *testing.T) {
+	cfg := InitSettings()
+	simulated := &http.Response{StatusCode: 200, Header: http.Header{"X-Version": {"1"}}, Body: http.NoBody}
+	live := &http.Response{StatusCode: 201, Header: http.Header{"X-Version": {"2"}}, Body: http.NoBody}
+
+	differences, err := cfg.diffResponses(simulated, live)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(differences), 2)
+	testutil.Expect(t, differences[0].Field, "status")
+	testutil.Expect(t, differences[1].Field, "header:X-Version")
+	testutil.Expect(t, differences[1].Live, "2")
+}
+
+func TestBodiesEqual(t *testing.T) {
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.bodiesEqual([]byte(`{"a": 1, "b": 2}`), []byte(`{"b":2,"a":1}`)), true)
+	testutil.Expect(t, cfg.bodiesEqual([]byte(`[1, 2]`), []byte(`[2, 1]`)), false)
+	testutil.Expect(t, cfg.bodiesEqual([]byte(`plain`), []byte(`other`)), false)
+
+	cfg.DiffIgnoreJSONOrder = true
+	testutil.Expect(t, cfg.bodiesEqual([]byte(`{"a": [{"id": 2}, {"id": 1}]}`), []byte(`{"a": [{"id": 1}, {"id": 2}]}`)), true)
+}
@@ -0,0 +1,312 @@
+package hoverfly
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DigestAuthConfig - credentials Hoverfly answers upstream 'WWW-Authenticate: Digest' challenges with. Only
+// challenges of Hosts are answered, challenges of other hosts are passed to the client. When Realm is set,
+// challenges of other realms are passed to the client too.
+type DigestAuthConfig struct {
+	Username string
+	Password string
+	Realm    string
+	// Hosts - upstream hosts credentials are sent to, i.e. 'api.example.com' matches any port while
+	// 'api.example.com:8443' only matches that one
+	Hosts []string
+}
+
+// errDigestAuthNoHosts - credentials would be answered to challenges of any upstream
+var errDigestAuthNoHosts = errors.New("upstream Digest authentication needs at least one host credentials are sent to")
+
+// Validate - checks that credentials are only sent to configured hosts
+func (c DigestAuthConfig) Validate() error {
+	for _, host := range c.Hosts {
+		if strings.TrimSpace(host) != "" {
+			return nil
+		}
+	}
+	return errDigestAuthNoHosts
+}
+
+// answers - whether challenges of the host are answered with the credentials
+func (c DigestAuthConfig) answers(host string) bool {
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+	for _, allowed := range c.Hosts {
+		allowed = strings.TrimSpace(allowed)
+		if strings.EqualFold(allowed, host) || strings.EqualFold(allowed, hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// digestChallenge - parameters of 'WWW-Authenticate: Digest' challenge together with nonce count of requests that
+// were authorized with it
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	count     int
+}
+
+// digestAuthTransport - answers Digest challenges of upstreams, request that gets 401 with Digest challenge is sent
+// again with Authorization header. Challenges are remembered per host so that following requests are authorized
+// straight away, they are sent again with a new challenge when nonce expires.
+type digestAuthTransport struct {
+	base   http.RoundTripper
+	config DigestAuthConfig
+
+	mu         sync.Mutex
+	challenges map[string]*digestChallenge
+}
+
+func newDigestAuthTransport(base http.RoundTripper, config DigestAuthConfig) *digestAuthTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &digestAuthTransport{base: base, config: config, challenges: make(map[string]*digestChallenge)}
+}
+
+func (t *digestAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// request is sent at most twice, body has to be read again
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+
+	attempt := cloneWithBody(req, body)
+	authorization, usedNonce := t.authorization(req, body)
+	if authorization != "" {
+		attempt.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := t.base.RoundTrip(attempt)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !t.config.answers(req.URL.Host) {
+		return resp, err
+	}
+
+	challenge, ok := parseDigestChallenge(resp.Header[http.CanonicalHeaderKey("WWW-Authenticate")])
+	if !ok || (t.config.Realm != "" && challenge.realm != t.config.Realm) {
+		return resp, nil
+	}
+	// credentials were rejected, only expired nonce is worth another attempt
+	if usedNonce != "" && !challenge.stale && challenge.nonce == usedNonce {
+		return resp, nil
+	}
+
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	t.mu.Lock()
+	t.challenges[req.URL.Host] = &challenge.digestChallenge
+	t.mu.Unlock()
+
+	log.WithFields(log.Fields{
+		"destination": req.URL.Host,
+		"realm":       challenge.realm,
+		"algorithm":   challenge.algorithm,
+	}).Debug("answering upstream Digest authentication challenge")
+
+	attempt = cloneWithBody(req, body)
+	authorization, _ = t.authorization(req, body)
+	attempt.Header.Set("Authorization", authorization)
+	return t.base.RoundTrip(attempt)
+}
+
+// authorization - Authorization header for request answering remembered challenge of its host together with nonce
+// of the challenge, both are empty when host didn't challenge requests yet
+func (t *digestAuthTransport) authorization(req *http.Request, body []byte) (string, string) {
+	t.mu.Lock()
+	challenge, ok := t.challenges[req.URL.Host]
+	if ok {
+		challenge.count++
+	}
+	var c digestChallenge
+	if ok {
+		c = *challenge
+	}
+	t.mu.Unlock()
+
+	if !ok {
+		return "", ""
+	}
+	return c.authorization(t.config, req.Method, req.URL.RequestURI(), body), c.nonce
+}
+
+// authorization - RFC 7616 response to the challenge, 'auth' quality of protection is preferred over 'auth-int'
+func (c digestChallenge) authorization(config DigestAuthConfig, method, uri string, body []byte) string {
+	h := md5.New
+	algorithm := strings.ToUpper(c.algorithm)
+	if strings.HasPrefix(algorithm, "SHA-256") {
+		h = sha256.New
+	}
+
+	cnonce := digestCnonce()
+	nc := fmt.Sprintf("%08x", c.count)
+
+	ha1 := digestHash(h, config.Username+":"+c.realm+":"+config.Password)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = digestHash(h, ha1+":"+c.nonce+":"+cnonce)
+	}
+
+	qop := ""
+	for _, offered := range strings.Split(c.qop, ",") {
+		offered = strings.TrimSpace(offered)
+		if offered == "auth" || (offered == "auth-int" && qop == "") {
+			qop = offered
+		}
+	}
+
+	a2 := method + ":" + uri
+	if qop == "auth-int" {
+		a2 += ":" + digestHash(h, string(body))
+	}
+	ha2 := digestHash(h, a2)
+
+	var response string
+	if qop == "" {
+		response = digestHash(h, ha1+":"+c.nonce+":"+ha2)
+	} else {
+		response = digestHash(h, ha1+":"+c.nonce+":"+nc+":"+cnonce+":"+qop+":"+ha2)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		digestQuote(config.Username), digestQuote(c.realm), digestQuote(c.nonce), digestQuote(uri), response)
+	if c.algorithm != "" {
+		fmt.Fprintf(&buf, ", algorithm=%s", c.algorithm)
+	}
+	if qop != "" {
+		fmt.Fprintf(&buf, `, qop=%s, nc=%s, cnonce="%s"`, qop, nc, cnonce)
+	}
+	if c.opaque != "" {
+		fmt.Fprintf(&buf, `, opaque="%s"`, digestQuote(c.opaque))
+	}
+	return buf.String()
+}
+
+// parsedDigestChallenge - challenge with 'stale' flag, set when previous nonce expired
+type parsedDigestChallenge struct {
+	digestChallenge
+	stale bool
+}
+
+// parseDigestChallenge - first Digest challenge in given 'WWW-Authenticate' headers
+func parseDigestChallenge(headers []string) (parsedDigestChallenge, bool) {
+	for _, header := range headers {
+		if len(header) < 7 || !strings.EqualFold(header[:7], "Digest ") {
+			continue
+		}
+		params := parseDigestParams(header[7:])
+		if params["nonce"] == "" {
+			continue
+		}
+		return parsedDigestChallenge{
+			digestChallenge: digestChallenge{
+				realm:     params["realm"],
+				nonce:     params["nonce"],
+				opaque:    params["opaque"],
+				algorithm: params["algorithm"],
+				qop:       params["qop"],
+			},
+			stale: strings.EqualFold(params["stale"], "true"),
+		}, true
+	}
+	return parsedDigestChallenge{}, false
+}
+
+// parseDigestParams - comma separated 'name=value' and 'name="quoted value"' pairs, names are lower cased
+func parseDigestParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return params
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var value bytes.Buffer
+		if strings.HasPrefix(s, `"`) {
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value.WriteString(strings.TrimSpace(s[:end]))
+			s = s[end:]
+		}
+		params[name] = value.String()
+	}
+}
+
+func digestHash(h func() hash.Hash, s string) string {
+	sum := h()
+	sum.Write([]byte(s))
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// digestCnonce - random client nonce, replaced in tests
+var digestCnonce = func() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+var digestQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func digestQuote(s string) string {
+	return digestQuoter.Replace(s)
+}
+
+// cloneWithBody - copy of request with its own headers and body that can be sent
+func cloneWithBody(req *http.Request, body []byte) *http.Request {
+	clone := new(http.Request)
+	*clone = *req
+	clone.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		clone.Header[k] = append([]string(nil), v...)
+	}
+	if req.Body != nil {
+		clone.Body = ioutil.NopCloser(bytes.NewReader(body))
+		clone.ContentLength = int64(len(body))
+	}
+	return clone
+}
@@ -0,0 +1,216 @@
+package hoverfly
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/authentication/backends"
+	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// digestUpstream - requires SHA-256 Digest authentication of 'Mufasa', nonce is read on every request so that
+// tests can expire it. Counts requests it gets.
+func digestUpstream(nonce *atomic.Value, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		body, _ := ioutil.ReadAll(r.Body)
+		current := nonce.Load().(string)
+
+		params := map[string]string{}
+		if authorization := r.Header.Get("Authorization"); strings.HasPrefix(authorization, "Digest ") {
+			params = parseDigestParams(authorization[len("Digest "):])
+		}
+
+		ha1 := digestHash(sha256.New, "Mufasa:hoverfly@example.com:Circle Of Life")
+		ha2 := digestHash(sha256.New, r.Method+":"+r.URL.RequestURI())
+		expected := digestHash(sha256.New, ha1+":"+current+":"+params["nc"]+":"+params["cnonce"]+":auth:"+ha2)
+
+		if params["response"] != expected || params["nonce"] != current || params["opaque"] != "opaque, value" {
+			stale := ""
+			if params["response"] != "" && params["nonce"] != current {
+				stale = ", stale=true"
+			}
+			w.Header().Add("WWW-Authenticate", `Basic realm="hoverfly@example.com"`)
+			w.Header().Add("WWW-Authenticate", `Digest realm="hoverfly@example.com", qop="auth, auth-int", algorithm=SHA-256, nonce="`+current+`", opaque="opaque, value"`+stale)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Nonce-Count", params["nc"])
+		w.Write([]byte("welcome " + string(body)))
+	}))
+}
+
+func TestDigestAuthTransport(t *testing.T) {
+	var nonce atomic.Value
+	nonce.Store("first")
+	var requests int32
+	upstream := digestUpstream(&nonce, &requests)
+	defer upstream.Close()
+
+	client := &http.Client{Transport: newDigestAuthTransport(nil, DigestAuthConfig{Username: "Mufasa", Password: "Circle Of Life", Hosts: []string{"127.0.0.1"}})}
+
+	resp, err := client.Post(upstream.URL+"/dir/index.html?q=1", "text/plain", strings.NewReader("lion"))
+	testutil.Expect(t, err, nil)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, string(body), "welcome lion")
+	testutil.Expect(t, resp.Header.Get("Nonce-Count"), "00000001")
+	testutil.Expect(t, atomic.LoadInt32(&requests), int32(2))
+
+	// challenge is remembered, nonce count goes up
+	resp, err = client.Get(upstream.URL + "/other")
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, resp.Header.Get("Nonce-Count"), "00000002")
+	testutil.Expect(t, atomic.LoadInt32(&requests), int32(3))
+
+	// expired nonce is replaced
+	nonce.Store("second")
+	resp, err = client.Get(upstream.URL + "/other")
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, resp.Header.Get("Nonce-Count"), "00000001")
+	testutil.Expect(t, atomic.LoadInt32(&requests), int32(5))
+}
+
+func TestDigestAuthTransportWrongCredentials(t *testing.T) {
+	var nonce atomic.Value
+	nonce.Store("first")
+	var requests int32
+	upstream := digestUpstream(&nonce, &requests)
+	defer upstream.Close()
+
+	client := &http.Client{Transport: newDigestAuthTransport(nil, DigestAuthConfig{Username: "Mufasa", Password: "wrong", Hosts: []string{"127.0.0.1"}})}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(upstream.URL)
+		testutil.Expect(t, err, nil)
+		resp.Body.Close()
+		testutil.Expect(t, resp.StatusCode, http.StatusUnauthorized)
+	}
+	// rejected credentials aren't sent again
+	testutil.Expect(t, atomic.LoadInt32(&requests), int32(3))
+
+	// challenges of other realms are passed to the client
+	requests = 0
+	client = &http.Client{Transport: newDigestAuthTransport(nil, DigestAuthConfig{Username: "Mufasa", Password: "Circle Of Life", Realm: "other", Hosts: []string{"127.0.0.1"}})}
+	resp, err := client.Get(upstream.URL)
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusUnauthorized)
+	testutil.Expect(t, atomic.LoadInt32(&requests), int32(1))
+}
+
+func TestDigestAuthTransportOtherHosts(t *testing.T) {
+	var nonce atomic.Value
+	nonce.Store("first")
+	var requests int32
+	upstream := digestUpstream(&nonce, &requests)
+	defer upstream.Close()
+
+	// challenges of hosts credentials aren't configured for are passed to the client
+	client := &http.Client{Transport: newDigestAuthTransport(nil, DigestAuthConfig{Username: "Mufasa", Password: "Circle Of Life", Hosts: []string{"api.example.com"}})}
+	resp, err := client.Get(upstream.URL)
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusUnauthorized)
+	testutil.Expect(t, atomic.LoadInt32(&requests), int32(1))
+
+	// host with port only matches that port
+	requests = 0
+	client = &http.Client{Transport: newDigestAuthTransport(nil, DigestAuthConfig{Username: "Mufasa", Password: "Circle Of Life", Hosts: []string{"127.0.0.1:1"}})}
+	resp, err = client.Get(upstream.URL)
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusUnauthorized)
+
+	client = &http.Client{Transport: newDigestAuthTransport(nil, DigestAuthConfig{Username: "Mufasa", Password: "Circle Of Life", Hosts: []string{upstream.Listener.Addr().String()}})}
+	resp, err = client.Get(upstream.URL)
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+}
+
+func TestDigestAuthConfigRequiresHosts(t *testing.T) {
+	testutil.Expect(t, DigestAuthConfig{Username: "Mufasa", Password: "Circle Of Life"}.Validate(), errDigestAuthNoHosts)
+	testutil.Expect(t, DigestAuthConfig{Username: "Mufasa", Password: "Circle Of Life", Hosts: []string{" "}}.Validate(), errDigestAuthNoHosts)
+	testutil.Expect(t, DigestAuthConfig{Username: "Mufasa", Password: "Circle Of Life", Hosts: []string{"api.example.com"}}.Validate(), nil)
+
+	cfg := InitSettings()
+	cfg.UpstreamDigestAuth = &DigestAuthConfig{Username: "Mufasa", Password: "Circle Of Life"}
+	_, err := NewHoverfly(WithConfiguration(cfg))
+	testutil.Expect(t, err, errDigestAuthNoHosts)
+}
+
+func TestDigestChallengeAuthorization(t *testing.T) {
+	original := digestCnonce
+	digestCnonce = func() string { return "0a4f113b" }
+	defer func() { digestCnonce = original }()
+
+	// RFC 2617 example
+	challenge := digestChallenge{
+		realm:  "testrealm@host.com",
+		nonce:  "dcd98b7102dd2f0e8b11d0f600bfb0c093",
+		opaque: "5ccc069c403ebaf9f0171e9517f40e41",
+		qop:    "auth,auth-int",
+		count:  1,
+	}
+	authorization := challenge.authorization(DigestAuthConfig{Username: "Mufasa", Password: "Circle Of Life"}, "GET", "/dir/index.html", nil)
+	testutil.Expect(t, authorization, `Digest username="Mufasa", realm="testrealm@host.com", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", uri="/dir/index.html", `+
+		`response="6629fae49393a05397450978507c4ef1", qop=auth, nc=00000001, cnonce="0a4f113b", opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+}
+
+func TestParseDigestChallenge(t *testing.T) {
+	_, ok := parseDigestChallenge([]string{`Basic realm="example"`})
+	testutil.Expect(t, ok, false)
+
+	challenge, ok := parseDigestChallenge([]string{`Basic realm="example"`, `digest realm="a \"quoted\", realm",nonce=abc, stale=TRUE, qop="auth"`})
+	testutil.Expect(t, ok, true)
+	testutil.Expect(t, challenge.realm, `a "quoted", realm`)
+	testutil.Expect(t, challenge.nonce, "abc")
+	testutil.Expect(t, challenge.qop, "auth")
+	testutil.Expect(t, challenge.stale, true)
+}
+
+func TestUpstreamDigestAuthInCaptureMode(t *testing.T) {
+	var nonce atomic.Value
+	nonce.Store("first")
+	var requests int32
+	upstream := digestUpstream(&nonce, &requests)
+	defer upstream.Close()
+
+	cfg := InitSettings()
+	cfg.SetMode(CaptureMode)
+	cfg.UpstreamDigestAuth = &DigestAuthConfig{Username: "Mufasa", Password: "Circle Of Life", Realm: "hoverfly@example.com", Hosts: []string{"127.0.0.1"}}
+	authBackend := backends.NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache())
+	h, err := GetNewHoverfly(cfg, cache.NewInMemoryCache(), cache.NewInMemoryCache(), authBackend)
+	testutil.Expect(t, err, nil)
+
+	_, ok := h.httpTransport()
+	testutil.Expect(t, ok, true)
+
+	req, err := http.NewRequest("GET", upstream.URL+"/captured", nil)
+	testutil.Expect(t, err, nil)
+	_, resp := h.processRequest(req)
+	body, _ := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, string(body), "welcome ")
+
+	// captured request doesn't keep credentials
+	values, err := h.RequestCache.GetAllValues()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(values), 1)
+	payload, err := models.NewPayloadFromBytes(values[0])
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, payload.Response.Status, http.StatusOK)
+	testutil.Expect(t, len(payload.Request.Headers["Authorization"]), 0)
+}
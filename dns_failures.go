@@ -0,0 +1,92 @@
+package hoverfly
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/rusenask/goproxy"
+)
+
+// dnsFailure - DNS error that connection to address fails with, nil when its host is in neither DNSFailures nor
+// DNSTimeouts. Hosts are compared case insensitively, without port and trailing dot.
+func (c *Configuration) dnsFailure(address string) error {
+	if c == nil || (len(c.DNSFailures) == 0 && len(c.DNSTimeouts) == 0) {
+		return nil
+	}
+
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(host, ".")
+
+	for _, failing := range c.DNSFailures {
+		if strings.EqualFold(strings.TrimSuffix(failing, "."), host) {
+			return &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+	}
+	for _, timingOut := range c.DNSTimeouts {
+		if strings.EqualFold(strings.TrimSuffix(timingOut, "."), host) {
+			return &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+		}
+	}
+	return nil
+}
+
+// dnsFailureDialer - fails connections to hosts of DNSFailures and DNSTimeouts before anything is looked up, other
+// connections are opened by next dialer. Lists are checked on every dial, so changes apply straight away.
+type dnsFailureDialer struct {
+	cfg  *Configuration
+	next func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+func newDNSFailureDialer(cfg *Configuration, next func(ctx context.Context, network, address string) (net.Conn, error)) *dnsFailureDialer {
+	if next == nil {
+		// what http.Transport does without DialContext
+		next = (&net.Dialer{}).DialContext
+	}
+	return &dnsFailureDialer{cfg: cfg, next: next}
+}
+
+// DialContext - connects to address unless DNS failure is simulated for its host
+func (d *dnsFailureDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if err := d.cfg.dnsFailure(address); err != nil {
+		return nil, err
+	}
+	return d.next(ctx, network, address)
+}
+
+// Dial - connects to address unless DNS failure is simulated for its host
+func (d *dnsFailureDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// applyDNSFailures - routes upstream connections of HTTP client and of CONNECT tunnels that aren't intercepted
+// through dnsFailureDialer. It is applied after the SOCKS5 proxy, which replaces dialer of HTTP client when it
+// changes.
+func (d *Hoverfly) applyDNSFailures(proxy *goproxy.ProxyHttpServer) {
+	connectDial := proxy.ConnectDial
+	if connectDial == nil {
+		// what proxy does without ConnectDial
+		connectDial = func(network, address string) (net.Conn, error) {
+			if proxy.Tr.Dial != nil {
+				return proxy.Tr.Dial(network, address)
+			}
+			return net.Dial(network, address)
+		}
+	}
+	proxy.ConnectDial = newDNSFailureDialer(d.Cfg, func(ctx context.Context, network, address string) (net.Conn, error) {
+		return connectDial(network, address)
+	}).Dial
+
+	if d.dnsDialer != nil {
+		return
+	}
+	transport, ok := d.httpTransport()
+	if !ok {
+		return
+	}
+	d.dnsDialer = newDNSFailureDialer(d.Cfg, transport.DialContext)
+	transport.DialContext = d.dnsDialer.DialContext
+}
@@ -0,0 +1,100 @@
+package hoverfly
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestConfigurationDNSFailure(t *testing.T) {
+	cfg := &Configuration{DNSFailures: []string{"gone.example.com."}, DNSTimeouts: []string{"slow.example.com"}}
+
+	err, ok := cfg.dnsFailure("GONE.example.com:443").(*net.DNSError)
+	testutil.Expect(t, ok, true)
+	testutil.Expect(t, err.Err, "no such host")
+	testutil.Expect(t, err.Name, "GONE.example.com")
+	testutil.Expect(t, err.IsNotFound, true)
+
+	err, ok = cfg.dnsFailure("slow.example.com.").(*net.DNSError)
+	testutil.Expect(t, ok, true)
+	testutil.Expect(t, err.Err, "i/o timeout")
+	testutil.Expect(t, err.IsTimeout, true)
+	testutil.Expect(t, err.Timeout(), true)
+
+	testutil.Expect(t, cfg.dnsFailure("example.com:80"), nil)
+
+	var none *Configuration
+	testutil.Expect(t, none.dnsFailure("gone.example.com:80"), nil)
+}
+
+func TestDNSFailuresOfUpstreamRequests(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+	_, port, _ := net.SplitHostPort(u.Host)
+
+	cfg := InitSettings()
+	cfg.DNSFailures = []string{"localhost"}
+	dbClient, err := NewHoverfly(WithConfiguration(cfg))
+	testutil.Expect(t, err, nil)
+
+	_, err = dbClient.HTTP.Get("http://localhost:" + port)
+	var dnsErr *net.DNSError
+	testutil.Expect(t, errors.As(err, &dnsErr), true)
+	testutil.Expect(t, dnsErr.IsNotFound, true)
+
+	// other hosts are connected to
+	resp, err := dbClient.HTTP.Get(upstream.URL)
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+
+	// lists are checked on every connection
+	dbClient.Cfg.DNSFailures = nil
+	dbClient.Cfg.DNSTimeouts = []string{"localhost"}
+	_, err = dbClient.HTTP.Get("http://localhost:" + port)
+	testutil.Expect(t, errors.As(err, &dnsErr), true)
+	testutil.Expect(t, dnsErr.IsTimeout, true)
+
+	// CONNECT tunnels and upgraded connections fail as well
+	_, err = dbClient.Proxy.ConnectDial("tcp", "localhost:443")
+	testutil.Expect(t, errors.As(err, &dnsErr), true)
+	_, err = dbClient.dialUpstream("tcp", "localhost:"+port)
+	testutil.Expect(t, errors.As(err, &dnsErr), true)
+
+	conn, err := dbClient.Proxy.ConnectDial("tcp", u.Host)
+	testutil.Expect(t, err, nil)
+	conn.Close()
+}
+
+func TestDNSFailuresWithUpstreamSOCKS5Proxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	socks := newSOCKS5Server(t, "", "")
+	defer socks.Close()
+
+	dbClient := socks5Hoverfly(t, socks.Addr())
+	dbClient.Cfg.DNSFailures = []string{"gone.example.com"}
+
+	_, err := dbClient.HTTP.Get("http://gone.example.com")
+	var dnsErr *net.DNSError
+	testutil.Expect(t, errors.As(err, &dnsErr), true)
+	testutil.Expect(t, len(socks.Destinations()), 0)
+
+	resp, err := dbClient.HTTP.Get(upstream.URL)
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, len(socks.Destinations()), 1)
+
+	// rebuilt proxy keeps failing the host once SOCKS5 proxy is removed
+	dbClient.Cfg.UpstreamSOCKS5Proxy = ""
+	dbClient.UpdateProxy()
+	dbClient.UpdateProxy()
+	_, err = dbClient.HTTP.Get("http://gone.example.com")
+	testutil.Expect(t, errors.As(err, &dnsErr), true)
+}
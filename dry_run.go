@@ -0,0 +1,64 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// dryRunEntry - single line written to dry run log for every request that would be captured
+type dryRunEntry struct {
+	Time    time.Time          `json:"time"`
+	Key     string             `json:"key"`
+	Payload models.PayloadView `json:"payload"`
+}
+
+var dryRunMu sync.Mutex
+
+// logDryRun - writes would-be cache entry as JSON line to configured dry run log, entries are logged at info
+// level when no file is configured
+func (d *Hoverfly) logDryRun(cfg *Configuration, key string, payload models.Payload) {
+	// nothing references streamed body file in dry run
+	if payload.RequestBodyFile != "" {
+		os.Remove(payload.RequestBodyFile)
+	}
+
+	entry := dryRunEntry{Time: time.Now(), Key: key, Payload: *payload.ConvertToPayloadView()}
+
+	bts, err := json.Marshal(entry)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Error("Failed to encode dry run entry")
+		return
+	}
+
+	if cfg.DryRunLogFile == "" {
+		log.WithFields(log.Fields{
+			"entry": string(bts),
+		}).Info("dry run, request would be captured")
+		return
+	}
+
+	dryRunMu.Lock()
+	defer dryRunMu.Unlock()
+
+	f, err := os.OpenFile(cfg.DryRunLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		_, err = f.Write(append(bts, '\n'))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"file":  cfg.DryRunLogFile,
+		}).Error("Failed to write dry run entry")
+	}
+}
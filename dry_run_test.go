@@ -0,0 +1,50 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestDryRunCaptureDoesNotStore(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "hoverfly_dry_run")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	dbClient.Cfg.DryRun = true
+	dbClient.Cfg.DryRunLogFile = filepath.Join(dir, "dry_run.log")
+
+	for _, path := range []string{"/one", "/two"} {
+		req, err := http.NewRequest("GET", "http://example.com"+path, nil)
+		testutil.Expect(t, err, nil)
+
+		resp, err := dbClient.captureRequest(req)
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, resp.StatusCode, 201)
+	}
+
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 0)
+
+	bts, err := ioutil.ReadFile(dbClient.Cfg.DryRunLogFile)
+	testutil.Expect(t, err, nil)
+
+	lines := strings.Split(strings.TrimSpace(string(bts)), "\n")
+	testutil.Expect(t, len(lines), 2)
+
+	var entry dryRunEntry
+	testutil.Expect(t, json.Unmarshal([]byte(lines[0]), &entry), nil)
+	testutil.Refute(t, entry.Key, "")
+	testutil.Expect(t, entry.Payload.Request.Path, "/one")
+	testutil.Expect(t, entry.Payload.Response.Status, 201)
+}
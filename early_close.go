@@ -0,0 +1,121 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// EarlyCloseRule - connections of requests which host and path match given regexp pattern are closed without
+// response after AfterRequestBytesRead bytes of request body were read
+type EarlyCloseRule struct {
+	Pattern               string `json:"pattern"`
+	AfterRequestBytesRead int    `json:"afterRequestBytesRead"`
+
+	// compiled Pattern, set by SetEarlyCloseRules
+	re *regexp.Regexp
+}
+
+// LoadEarlyCloseRules - reads early close rules from JSON file, i.e.:
+//
+//	[{"pattern": "example.com/api/upload", "afterRequestBytesRead": 1024}]
+func (c *Configuration) LoadEarlyCloseRules(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var rules []EarlyCloseRule
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return fmt.Errorf("failed to parse early close file: %s", err.Error())
+	}
+
+	return c.SetEarlyCloseRules(rules)
+}
+
+// SetEarlyCloseRules - validates rules and compiles their patterns, requests are matched against compiled patterns
+func (c *Configuration) SetEarlyCloseRules(rules []EarlyCloseRule) error {
+	compiled := make([]EarlyCloseRule, len(rules))
+	for i, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid early close pattern '%s': %s", rule.Pattern, err.Error())
+		}
+		if rule.AfterRequestBytesRead < 0 {
+			return fmt.Errorf("invalid early close afterRequestBytesRead %d for pattern '%s', must not be negative", rule.AfterRequestBytesRead, rule.Pattern)
+		}
+		rule.re = re
+		compiled[i] = rule
+	}
+
+	c.EarlyClose = compiled
+
+	return nil
+}
+
+// GetEarlyCloseRule - returns first early close rule that matches given host and path
+func (c *Configuration) GetEarlyCloseRule(host, path string) *EarlyCloseRule {
+	for i := range c.EarlyClose {
+		if re := c.EarlyClose[i].re; re != nil && re.MatchString(host+path) {
+			return &c.EarlyClose[i]
+		}
+	}
+	return nil
+}
+
+// earlyCloseHandler - reads only the beginning of request body and resets client connection without sending
+// a response. Server reads from the connection in chunks, so few more bytes than configured might be received
+// from the network before the connection is closed.
+type earlyCloseHandler struct {
+	handler http.Handler
+	cfg     *Configuration
+}
+
+func (h *earlyCloseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok || r.Method == "CONNECT" || requestMode(r, h.cfg) != SimulateMode {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	rule := h.cfg.GetEarlyCloseRule(r.Host, r.URL.Path)
+	if rule == nil {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	var read int64
+	if r.Body != nil {
+		read, _ = io.Copy(ioutil.Discard, io.LimitReader(r.Body, int64(rule.AfterRequestBytesRead)))
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("Failed to hijack connection, request is processed")
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	// zero linger makes close send RST instead of FIN
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+
+	log.WithFields(log.Fields{
+		"pattern":     rule.Pattern,
+		"bytesRead":   read,
+		"destination": r.Host,
+		"path":        r.URL.Path,
+	}).Info("Connection closed before request body was read")
+}
@@ -0,0 +1,85 @@
+package hoverfly
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func earlyCloseServer(cfg *Configuration, called *bool) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*called = true
+		fmt.Fprint(w, "uploaded")
+	})
+	return httptest.NewServer(&earlyCloseHandler{handler: handler, cfg: cfg})
+}
+
+func TestEarlyCloseResetsConnection(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetMode(SimulateMode)
+	testutil.Expect(t, cfg.SetEarlyCloseRules([]EarlyCloseRule{{Pattern: "/upload", AfterRequestBytesRead: 10}}), nil)
+
+	called := false
+	server := earlyCloseServer(cfg, &called)
+	defer server.Close()
+
+	body := bytes.Repeat([]byte("a"), 4*1024*1024)
+	_, err := http.Post(server.URL+"/upload", "application/octet-stream", bytes.NewReader(body))
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, called, false)
+}
+
+func TestEarlyCloseNotMatched(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetMode(SimulateMode)
+	testutil.Expect(t, cfg.SetEarlyCloseRules([]EarlyCloseRule{{Pattern: "/upload", AfterRequestBytesRead: 10}}), nil)
+
+	called := false
+	server := earlyCloseServer(cfg, &called)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/other", "text/plain", bytes.NewBufferString("body"))
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(b), "uploaded")
+	testutil.Expect(t, called, true)
+}
+
+func TestLoadEarlyCloseRules(t *testing.T) {
+	f, err := ioutil.TempFile("", "early_close")
+	testutil.Expect(t, err, nil)
+	defer os.Remove(f.Name())
+
+	fmt.Fprint(f, `[{"pattern": "example.com/upload", "afterRequestBytesRead": 512}]`)
+	f.Close()
+
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.LoadEarlyCloseRules(f.Name()), nil)
+
+	rule := cfg.GetEarlyCloseRule("example.com", "/upload")
+	testutil.Refute(t, rule, nil)
+	testutil.Expect(t, rule.AfterRequestBytesRead, 512)
+	testutil.Expect(t, cfg.GetEarlyCloseRule("example.com", "/download") == nil, true)
+}
+
+func TestLoadEarlyCloseRulesNegativeBytes(t *testing.T) {
+	f, err := ioutil.TempFile("", "early_close")
+	testutil.Expect(t, err, nil)
+	defer os.Remove(f.Name())
+
+	fmt.Fprint(f, `[{"pattern": "example.com/upload", "afterRequestBytesRead": -1}]`)
+	f.Close()
+
+	cfg := InitSettings()
+	testutil.Refute(t, cfg.LoadEarlyCloseRules(f.Name()), nil)
+	testutil.Expect(t, len(cfg.EarlyClose), 0)
+}
@@ -0,0 +1,130 @@
+package hoverfly
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// ErrorCodeHeader - machine readable error code, set on every error response from the proxy and admin API
+const ErrorCodeHeader = "X-Hoverfly-Error-Code"
+
+// ErrorCode - machine readable error code, clients should not rely on error messages
+type ErrorCode string
+
+// Error codes
+const (
+	ErrorCodeCacheMiss           ErrorCode = "CACHE_MISS"
+	ErrorCodeBodyMismatch        ErrorCode = "BODY_MISMATCH"
+	ErrorCodeCacheError          ErrorCode = "CACHE_ERROR"
+	ErrorCodePageNotFound        ErrorCode = "PAGE_NOT_FOUND"
+	ErrorCodeRangeNotSatisfiable ErrorCode = "RANGE_NOT_SATISFIABLE"
+	ErrorCodeMiddlewareFailed    ErrorCode = "MIDDLEWARE_FAILED"
+	ErrorCodeMiddlewareTimeout   ErrorCode = "MIDDLEWARE_TIMEOUT"
+	ErrorCodeUpstreamUnreachable ErrorCode = "UPSTREAM_UNREACHABLE"
+	ErrorCodeTooManyRedirects    ErrorCode = "TOO_MANY_REDIRECTS"
+	ErrorCodeBadRequest          ErrorCode = "BAD_REQUEST"
+	ErrorCodeUnauthorized        ErrorCode = "UNAUTHORIZED"
+	ErrorCodeNotFound            ErrorCode = "NOT_FOUND"
+	ErrorCodeRequestTooLarge     ErrorCode = "REQUEST_TOO_LARGE"
+	ErrorCodeInternal            ErrorCode = "INTERNAL_ERROR"
+	ErrorCodeServiceUnavailable  ErrorCode = "SERVICE_UNAVAILABLE"
+	ErrorCodeCircuitOpen         ErrorCode = "CIRCUIT_OPEN"
+	ErrorCodeUnknown             ErrorCode = "UNKNOWN"
+)
+
+// codedError - error that knows which error code it should be reported with
+type codedError struct {
+	code ErrorCode
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// withErrorCode - attaches error code to given error, nil stays nil
+func withErrorCode(code ErrorCode, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// errorCodeOf - returns error code attached to the error or given fallback
+func errorCodeOf(err error, fallback ErrorCode) ErrorCode {
+	if coded, ok := err.(*codedError); ok {
+		return coded.code
+	}
+	return fallback
+}
+
+// errorCodeForStatus - default error code for error responses that were not given more specific code
+func errorCodeForStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ErrorCodeBadRequest
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrorCodeUnauthorized
+	case http.StatusNotFound:
+		return ErrorCodeNotFound
+	case http.StatusRequestEntityTooLarge:
+		return ErrorCodeRequestTooLarge
+	case http.StatusServiceUnavailable:
+		return ErrorCodeServiceUnavailable
+	}
+	if status >= 500 {
+		return ErrorCodeInternal
+	}
+	return ErrorCodeUnknown
+}
+
+// setErrorCode - sets error code header on admin API response, must be called before writing status
+func setErrorCode(w http.ResponseWriter, code ErrorCode) {
+	w.Header().Set(ErrorCodeHeader, string(code))
+}
+
+// errorCodeMiddleware - makes sure that every admin API error response carries error code header
+func errorCodeMiddleware(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	next(&errorCodeWriter{ResponseWriter: w}, req)
+}
+
+// errorCodeWriter - adds default error code header to error responses that don't have one
+type errorCodeWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *errorCodeWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status >= 400 && w.Header().Get(ErrorCodeHeader) == "" {
+		setErrorCode(w, errorCodeForStatus(status))
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *errorCodeWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Hijack - websocket handlers need access to underlying connection
+func (w *errorCodeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// Flush - keeps streaming responses working
+func (w *errorCodeWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
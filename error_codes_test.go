@@ -0,0 +1,126 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	err := withErrorCode(ErrorCodeMiddlewareFailed, errors.New("boom"))
+
+	testutil.Expect(t, err.Error(), "boom")
+	testutil.Expect(t, errorCodeOf(err, ErrorCodeUnknown), ErrorCodeMiddlewareFailed)
+	testutil.Expect(t, errorCodeOf(errors.New("boom"), ErrorCodeUnknown), ErrorCodeUnknown)
+	testutil.Expect(t, withErrorCode(ErrorCodeMiddlewareFailed, nil), nil)
+}
+
+func TestSimulateCacheMissHasErrorCode(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", "http://example.com/missing", nil)
+	testutil.Expect(t, err, nil)
+
+	resp := dbClient.getResponse(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+	testutil.Expect(t, resp.Header.Get(ErrorCodeHeader), string(ErrorCodeCacheMiss))
+}
+
+func TestCaptureUnreachableUpstreamHasErrorCode(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	// upstream goes away
+	server.Close()
+
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusServiceUnavailable)
+	testutil.Expect(t, resp.Header.Get(ErrorCodeHeader), string(ErrorCodeUpstreamUnreachable))
+}
+
+func TestErrorCodeMiddlewareSetsDefaultCode(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/specific" {
+			setErrorCode(w, ErrorCodeCacheError)
+		}
+		if r.URL.Path == "/ok" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		http.Error(w, "bad", http.StatusBadRequest)
+	}
+
+	for path, code := range map[string]string{
+		"/default":  string(ErrorCodeBadRequest),
+		"/specific": string(ErrorCodeCacheError),
+		"/ok":       "",
+	} {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+
+		errorCodeMiddleware(rec, req, handler)
+
+		testutil.Expect(t, rec.Header().Get(ErrorCodeHeader), code)
+	}
+}
+
+func TestHoverflyErrorFormat(t *testing.T) {
+	tests := []struct {
+		accept      string
+		format      string
+		contentType string
+		body        string
+	}{
+		{"", ErrorFormatText, "text/plain", "Hoverfly Error! Could not find recorded request, please record it first!. Got error: boom \n"},
+		{"text/html", ErrorFormatText, "text/plain", "Hoverfly Error! Could not find recorded request, please record it first!. Got error: boom \n"},
+		{"application/json", ErrorFormatJSON, "application/json", `{"error":"Could not find recorded request, please record it first!","detail":"boom"}`},
+		{"text/html, application/json;q=0.9", ErrorFormatJSON, "application/json", `{"error":"Could not find recorded request, please record it first!","detail":"boom"}`},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", "http://example.com/missing", nil)
+		testutil.Expect(t, err, nil)
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+
+		format := errorFormat(req)
+		testutil.Expect(t, format, test.format)
+
+		resp := hoverflyError(req, errors.New("boom"), "Could not find recorded request, please record it first!", http.StatusPreconditionFailed, ErrorCodeCacheMiss, format)
+		testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+		testutil.Expect(t, resp.Header.Get("Content-Type"), test.contentType)
+		testutil.Expect(t, resp.Header.Get(ErrorCodeHeader), string(ErrorCodeCacheMiss))
+
+		body, err := ioutil.ReadAll(resp.Body)
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, string(body), test.body)
+	}
+}
+
+func TestSimulateCacheMissRespondsWithJSON(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", "http://example.com/missing", nil)
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Accept", "application/json")
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+	testutil.Expect(t, resp.Header.Get("Content-Type"), "application/json")
+
+	var body errorResponse
+	testutil.Expect(t, json.NewDecoder(resp.Body).Decode(&body), nil)
+	testutil.Expect(t, body.Error, "Could not find recorded request, please record it first!")
+	testutil.Refute(t, body.Detail, "")
+}
@@ -0,0 +1,134 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// eventsHeartbeatInterval - how often comment is sent on idle event stream so that intermediaries keep it open
+const eventsHeartbeatInterval = 15 * time.Second
+
+// eventFilter - 'GET /api/events' query, empty fields match every event. Events that don't describe a served
+// request only match empty filter.
+type eventFilter struct {
+	Host      string
+	Method    string
+	MinStatus int
+}
+
+// parseEventFilter - reads 'host', 'method' and 'min_status' query parameters
+func parseEventFilter(r *http.Request) (eventFilter, error) {
+	query := r.URL.Query()
+	filter := eventFilter{
+		Host:   query.Get("host"),
+		Method: query.Get("method"),
+	}
+	if minStatus := query.Get("min_status"); minStatus != "" {
+		status, err := strconv.Atoi(minStatus)
+		if err != nil || status < 0 {
+			return filter, fmt.Errorf("invalid min_status '%s'", minStatus)
+		}
+		filter.MinStatus = status
+	}
+	return filter, nil
+}
+
+func (f eventFilter) empty() bool {
+	return f.Host == "" && f.Method == "" && f.MinStatus == 0
+}
+
+// matches - host matches with or without port, host and method are case insensitive
+func (f eventFilter) matches(event ControlEvent) bool {
+	if f.empty() {
+		return true
+	}
+	if event.Event != ControlEventRequestServed {
+		return false
+	}
+	if f.Method != "" && !strings.EqualFold(f.Method, event.Method) {
+		return false
+	}
+	if f.MinStatus > 0 && event.Status < f.MinStatus {
+		return false
+	}
+	if f.Host != "" && !strings.EqualFold(f.Host, event.Host) {
+		hostname, _, err := net.SplitHostPort(event.Host)
+		if err != nil || !strings.EqualFold(f.Host, hostname) {
+			return false
+		}
+	}
+	return true
+}
+
+// EventsHandler - streams proxy events as server-sent events, events are filtered with 'host', 'method' and
+// 'min_status' query parameters before they are encoded, i.e. '/api/events?host=api.example.com&min_status=400'
+func (d *Hoverfly) EventsHandler(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// subscribed before headers are sent so that client doesn't miss events published once it is connected
+	var events chan ControlEvent
+	if d.events != nil {
+		events = d.events.subscribe()
+		defer d.events.unsubscribe(events)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				// client was too slow
+				return
+			}
+			if !filter.matches(event) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"error": err.Error(),
+				}).Error("Failed to encode event")
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Event, data); err != nil {
+				log.WithFields(log.Fields{
+					"error": err.Error(),
+				}).Debug("Got error when writing event")
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
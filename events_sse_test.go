@@ -0,0 +1,153 @@
+package hoverfly
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// nextEvent - reads data of next event from server-sent events stream
+func nextEvent(t *testing.T, reader *bufio.Reader) ControlEvent {
+	for {
+		line, err := reader.ReadString('\n')
+		testutil.Expect(t, err, nil)
+		if strings.HasPrefix(line, "data: ") {
+			var event ControlEvent
+			testutil.Expect(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event), nil)
+			return event
+		}
+	}
+}
+
+func TestEventsHandlerFiltersEvents(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.events = newEventBroadcaster()
+
+	admin := httptest.NewServer(getBoneRouter(*dbClient))
+	defer admin.Close()
+
+	resp, err := http.Get(admin.URL + "/api/events?host=api.example.com&method=POST&min_status=400")
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, resp.Header.Get("Content-Type"), "text/event-stream")
+
+	for _, event := range []ControlEvent{
+		{Event: ControlEventRequestServed, Host: "other.com", Method: "POST", Status: 500, URL: "/other-host"},
+		{Event: ControlEventRequestServed, Host: "api.example.com", Method: "GET", Status: 500, URL: "/other-method"},
+		{Event: ControlEventRequestServed, Host: "api.example.com", Method: "POST", Status: 201, URL: "/low-status"},
+		{Event: ControlEventModeChanged, Mode: CaptureMode},
+		{Event: ControlEventRequestServed, Host: "api.example.com:443", Method: "post", Status: 404, URL: "/matching"},
+	} {
+		dbClient.events.publish(event)
+	}
+
+	event := nextEvent(t, bufio.NewReader(resp.Body))
+	testutil.Expect(t, event.Event, ControlEventRequestServed)
+	testutil.Expect(t, event.URL, "/matching")
+}
+
+func TestEventsHandlerWithoutFilterStreamsAllEvents(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.events = newEventBroadcaster()
+
+	admin := httptest.NewServer(getBoneRouter(*dbClient))
+	defer admin.Close()
+
+	resp, err := http.Get(admin.URL + "/api/events")
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+
+	dbClient.events.publish(ControlEvent{Event: ControlEventModeChanged, Mode: CaptureMode})
+	dbClient.events.publish(ControlEvent{Event: ControlEventRequestServed, Host: "other.com", Status: 200})
+
+	reader := bufio.NewReader(resp.Body)
+	testutil.Expect(t, nextEvent(t, reader).Event, ControlEventModeChanged)
+	testutil.Expect(t, nextEvent(t, reader).Host, "other.com")
+}
+
+func TestEventsHandlerRejectsInvalidFilter(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	m := getBoneRouter(*dbClient)
+	req, err := http.NewRequest("GET", "/api/events?min_status=high", nil)
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestEventsHandlerStreamsProxiedRequests(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.events = newEventBroadcaster()
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	admin := httptest.NewServer(getBoneRouter(*dbClient))
+	defer admin.Close()
+
+	resp, err := http.Get(admin.URL + "/api/events")
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+
+	dbClient.UpdateProxy()
+	proxy := httptest.NewServer(dbClient.Proxy)
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	testutil.Expect(t, err, nil)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	proxied, err := client.Post("http://api.example.com/items?page=2", "text/plain", strings.NewReader("item"))
+	testutil.Expect(t, err, nil)
+	proxied.Body.Close()
+
+	event := nextEvent(t, bufio.NewReader(resp.Body))
+	testutil.Expect(t, event.Event, ControlEventRequestServed)
+	testutil.Expect(t, event.Method, "POST")
+	testutil.Expect(t, event.URL, "http://api.example.com/items?page=2")
+	testutil.Expect(t, event.Status, 200)
+	testutil.Expect(t, event.Mode, CaptureMode)
+	testutil.Expect(t, event.Time.IsZero(), false)
+	testutil.Expect(t, event.LatencyMs > 0, true)
+}
+
+func TestEventBroadcasterDropsSlowSubscriber(t *testing.T) {
+	b := newEventBroadcaster()
+	slow := b.subscribe()
+
+	for i := 0; i <= controlEventBuffer; i++ {
+		b.publish(ControlEvent{Event: ControlEventRequestServed})
+	}
+	testutil.Expect(t, len(b.subscribers), 0)
+
+	// buffered events are still delivered before channel is closed
+	received := 0
+	for range slow {
+		received++
+	}
+	testutil.Expect(t, received, controlEventBuffer)
+
+	// closed channel isn't closed again once client disconnects
+	b.unsubscribe(slow)
+}
+
+func TestEventBroadcasterUnsubscribeClosesChannel(t *testing.T) {
+	b := newEventBroadcaster()
+	ch := b.subscribe()
+	b.unsubscribe(ch)
+
+	_, ok := <-ch
+	testutil.Expect(t, ok, false)
+	testutil.Expect(t, len(b.subscribers), 0)
+}
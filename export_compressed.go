@@ -0,0 +1,69 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// ExportCompressed - writes all records in the same format as 'GET /api/records', every response body is gzip
+// compressed and base64 encoded on its own so that the file stays a valid simulation
+func (d *Hoverfly) ExportCompressed(w io.Writer) error {
+	_, err := d.writeRecords(w, compressedPayloadView)
+	return err
+}
+
+// ImportCompressed - imports records written by ExportCompressed, uncompressed records are imported as they are
+func (d *Hoverfly) ImportCompressed(r io.Reader) error {
+	var data recordedRequests
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return fmt.Errorf("failed to parse simulation: %s", err.Error())
+	}
+	if err := data.resolveBodyRefs(); err != nil {
+		return err
+	}
+
+	for i := range data.Data {
+		if err := decompressPayloadView(&data.Data[i]); err != nil {
+			log.WithFields(log.Fields{
+				"error":       err.Error(),
+				"destination": data.Data[i].Request.Destination,
+				"path":        data.Data[i].Request.Path,
+			}).Error("Failed to decompress response body")
+			return err
+		}
+	}
+
+	return d.ImportPayloads(data.Data)
+}
+
+func compressPayloadView(view *models.PayloadView) error {
+	if err := view.Response.CompressBody(); err != nil {
+		return err
+	}
+	if view.Paginated != nil {
+		for i := range view.Paginated.Pages {
+			if err := view.Paginated.Pages[i].CompressBody(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func decompressPayloadView(view *models.PayloadView) error {
+	if err := view.Response.DecompressBody(); err != nil {
+		return err
+	}
+	if view.Paginated != nil {
+		for i := range view.Paginated.Pages {
+			if err := view.Paginated.Pages[i].DecompressBody(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// exportPlain - same output as 'GET /api/records'
+func exportPlain(d *Hoverfly) ([]byte, error) {
+	values, err := d.RequestCache.GetAllValues()
+	if err != nil {
+		return nil, err
+	}
+
+	var data models.PayloadViewData
+	for _, v := range values {
+		payload, err := models.NewPayloadFromBytes(v)
+		if err != nil {
+			return nil, err
+		}
+		data.Data = append(data.Data, *payload.ConvertToPayloadView())
+	}
+
+	return json.Marshal(data)
+}
+
+func TestExportCompressedRoundTrip(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	testutil.Expect(t, dbClient.Import("examples/exports/readthedocs.json"), nil)
+
+	var buf bytes.Buffer
+	testutil.Expect(t, dbClient.ExportCompressed(&buf), nil)
+
+	var exported models.PayloadViewData
+	testutil.Expect(t, json.Unmarshal(buf.Bytes(), &exported), nil)
+	compressed := 0
+	for _, view := range exported.Data {
+		if view.Response.BodyEncoding == models.BodyEncodingGzipBase64 {
+			compressed++
+		}
+	}
+	testutil.Refute(t, compressed, 0)
+
+	_, other := testTools(200, `{'message': 'here'}`)
+	defer other.RequestCache.DeleteData()
+	testutil.Expect(t, other.ImportCompressed(bytes.NewReader(buf.Bytes())), nil)
+
+	originalCount, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	importedCount, err := other.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, importedCount, originalCount)
+
+	// bodies are the same as the original ones
+	keys, err := dbClient.RequestCache.Keys()
+	testutil.Expect(t, err, nil)
+	for _, key := range keys {
+		original, err := dbClient.RequestCache.Get([]byte(key))
+		testutil.Expect(t, err, nil)
+		imported, err := other.RequestCache.Get([]byte(key))
+		testutil.Expect(t, err, nil)
+
+		op, err := models.NewPayloadFromBytes(original)
+		testutil.Expect(t, err, nil)
+		ip, err := models.NewPayloadFromBytes(imported)
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, ip.Response.Body, op.Response.Body)
+	}
+}
+
+func TestExportCompressedIsAtLeastHalfTheSize(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	testutil.Expect(t, dbClient.Import("examples/exports/readthedocs.json"), nil)
+
+	plain, err := exportPlain(dbClient)
+	testutil.Expect(t, err, nil)
+
+	var buf bytes.Buffer
+	testutil.Expect(t, dbClient.ExportCompressed(&buf), nil)
+
+	if buf.Len()*2 > len(plain) {
+		t.Errorf("Expected compressed export (%d bytes) to be at most half of plain export (%d bytes)", buf.Len(), len(plain))
+	}
+}
+
+func TestImportCompressedServesDecompressedBody(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	body := bytes.Repeat([]byte(`{"id": 1, "name": "hoverfly"}`), 100)
+	view := models.ResponseDetailsView{Status: 200, Body: string(body)}
+	testutil.Expect(t, view.CompressBody(), nil)
+
+	data := models.PayloadViewData{Data: []models.PayloadView{{
+		Request:  models.RequestDetailsView{Method: "GET", Destination: "example.com", Path: "/users"},
+		Response: view,
+	}}}
+	bts, err := json.Marshal(data)
+	testutil.Expect(t, err, nil)
+
+	testutil.Expect(t, dbClient.ImportCompressed(bytes.NewReader(bts)), nil)
+
+	req, err := http.NewRequest("GET", "http://example.com/users", nil)
+	testutil.Expect(t, err, nil)
+
+	resp := dbClient.getResponse(req)
+	testutil.Expect(t, resp.StatusCode, 200)
+
+	got, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(got), string(body))
+}
+
+func BenchmarkExportCompressed(b *testing.B) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	if err := dbClient.Import("examples/exports/readthedocs.json"); err != nil {
+		b.Fatal(err)
+	}
+	plain, err := exportPlain(dbClient)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+
+	var size int
+	for i := 0; i < b.N; i++ {
+		var buf bytes.Buffer
+		if err := dbClient.ExportCompressed(&buf); err != nil {
+			b.Fatal(err)
+		}
+		size = buf.Len()
+	}
+
+	b.ReportMetric(float64(size)/float64(len(plain))*100, "%size")
+}
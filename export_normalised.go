@@ -0,0 +1,104 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// timestampHeaders - headers that differ between captures of the same response, they are left out of normalised
+// export
+var timestampHeaders = []string{"Date", "Age", "Expires", "Last-Modified"}
+
+// ExportNormalised - writes all records in the same format as 'GET /api/records', but stable between captures of
+// the same API so that exported files can be compared with 'git diff': records are sorted by request, header names
+// are canonicalised, timestamp headers and response history are left out and JSON is indented
+func (d *Hoverfly) ExportNormalised(w io.Writer) error {
+	views, err := d.normalisedPayloadViews()
+	if err != nil {
+		return err
+	}
+	return encodeNormalised(w, views)
+}
+
+// normalisedPayloadViews - all records, normalised and sorted by request
+func (d *Hoverfly) normalisedPayloadViews() ([]models.PayloadView, error) {
+	keys, err := d.RequestCache.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]models.PayloadView, 0, len(keys))
+	for _, key := range keys {
+		v, err := d.RequestCache.Get([]byte(key))
+		// removed since keys were listed
+		if err != nil {
+			continue
+		}
+
+		payload, err := models.NewPayloadFromBytes(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode record '%s': %s", key, err.Error())
+		}
+		views = append(views, normalisePayloadView(payload.ConvertToPayloadView()))
+	}
+
+	sort.SliceStable(views, func(i, j int) bool {
+		return canonicalRequestKey(views[i].Request) < canonicalRequestKey(views[j].Request)
+	})
+	return views, nil
+}
+
+func encodeNormalised(w io.Writer, views []models.PayloadView) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(models.PayloadViewData{Data: views})
+}
+
+// normalisePayloadView - canonicalises headers of request and all responses and drops timestamp headers
+func normalisePayloadView(view *models.PayloadView) models.PayloadView {
+	view.Request.Headers = normaliseHeaders(view.Request.Headers, nil)
+	view.Response.Headers = normaliseHeaders(view.Response.Headers, timestampHeaders)
+	view.Response.Trailers = normaliseHeaders(view.Response.Trailers, timestampHeaders)
+	for i := range view.ResponseSequence {
+		view.ResponseSequence[i].Headers = normaliseHeaders(view.ResponseSequence[i].Headers, timestampHeaders)
+	}
+	return *view
+}
+
+// normaliseHeaders - merges headers that differ only in case of their names and removes given headers. Values keep
+// their order, it is significant for headers such as Set-Cookie. JSON encoder sorts header names.
+func normaliseHeaders(headers map[string][]string, drop []string) map[string][]string {
+	if headers == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	// values of names that differ only in case are merged in the same order every time
+	sort.Strings(names)
+
+	normalised := http.Header{}
+	for _, name := range names {
+		for _, value := range headers[name] {
+			normalised.Add(name, value)
+		}
+	}
+	for _, name := range drop {
+		normalised.Del(name)
+	}
+	return normalised
+}
+
+// canonicalRequestKey - records are ordered by destination, path, query, method and body of the request
+func canonicalRequestKey(r models.RequestDetailsView) string {
+	return strings.Join([]string{r.Destination, r.Path, r.Query, r.Method, r.Scheme, r.Body}, "\x00")
+}
@@ -0,0 +1,88 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func storeNormalisedPayload(t *testing.T, d *Hoverfly, path string, headers map[string][]string) {
+	payload := models.Payload{
+		Request:  models.RequestDetails{Method: "GET", Destination: "normalise.com", Path: path},
+		Response: models.ResponseDetails{Status: 200, Body: path, Headers: headers},
+		History:  []models.ResponseVersion{{Version: 1, CreatedAt: time.Now()}},
+	}
+	bts, err := payload.Encode()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, d.RequestCache.Set([]byte(payload.Id()), bts), nil)
+}
+
+func TestExportNormalisedIsStable(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	var exports []string
+	for i, date := range []string{"Mon, 12 Oct 2026 10:00:00 GMT", "Tue, 13 Oct 2026 11:30:00 GMT"} {
+		dbClient.RequestCache.DeleteData()
+
+		paths := []string{"/b", "/a", "/c"}
+		if i == 1 {
+			paths = []string{"/c", "/b", "/a"}
+		}
+		for _, path := range paths {
+			storeNormalisedPayload(t, dbClient, path, map[string][]string{
+				"content-type": {"application/json"},
+				"Date":         {date},
+				"Age":          {"10"},
+				"Set-Cookie":   {"b=2", "a=1"},
+			})
+		}
+
+		var buf bytes.Buffer
+		testutil.Expect(t, dbClient.ExportNormalised(&buf), nil)
+		exports = append(exports, buf.String())
+	}
+
+	testutil.Expect(t, exports[0], exports[1])
+
+	var data models.PayloadViewData
+	testutil.Expect(t, json.Unmarshal([]byte(exports[0]), &data), nil)
+	testutil.Expect(t, len(data.Data), 3)
+	testutil.Expect(t, data.Data[0].Request.Path, "/a")
+	testutil.Expect(t, data.Data[2].Request.Path, "/c")
+
+	headers := http.Header(data.Data[0].Response.Headers)
+	testutil.Expect(t, headers.Get("Date"), "")
+	testutil.Expect(t, headers.Get("Age"), "")
+	testutil.Expect(t, len(data.Data[0].Response.Headers["Content-Type"]), 1)
+	testutil.Expect(t, strings.Join(headers["Set-Cookie"], ";"), "b=2;a=1")
+	testutil.Expect(t, len(data.Data[0].History), 0)
+}
+
+func TestAllRecordsHandlerNormalised(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	storeNormalisedPayload(t, dbClient, "/a", map[string][]string{"Date": {"Mon, 12 Oct 2026 10:00:00 GMT"}})
+
+	m := getBoneRouter(*dbClient)
+	req, err := http.NewRequest("GET", "/api/records?normalised=true", nil)
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var expected bytes.Buffer
+	testutil.Expect(t, dbClient.ExportNormalised(&expected), nil)
+	testutil.Expect(t, rec.Body.String(), expected.String())
+}
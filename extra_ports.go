@@ -0,0 +1,144 @@
+package hoverfly
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rusenask/goproxy"
+)
+
+// ProxyPortConfig - additional proxy port that processes requests in its own mode, regardless of current mode
+type ProxyPortConfig struct {
+	Port string `json:"port"`
+	Mode string `json:"mode"`
+}
+
+// ParseProxyPortConfig - parses 'port=mode' value of '-extra-proxy-port' flag
+func ParseProxyPortConfig(value string) (ProxyPortConfig, error) {
+	i := strings.Index(value, "=")
+	if i <= 0 {
+		return ProxyPortConfig{}, fmt.Errorf("expected 'port=mode', got '%s'", value)
+	}
+	return ProxyPortConfig{Port: value[:i], Mode: value[i+1:]}, nil
+}
+
+// validModes - every mode Hoverfly can be switched to
+var validModes = []string{SimulateMode, CaptureMode, ModifyMode, SynthesizeMode, SpyMode, DiffMode, ChaosMode}
+
+// isValidMode - checks whether mode exists
+func isValidMode(mode string) bool {
+	for _, m := range validModes {
+		if mode == m {
+			return true
+		}
+	}
+	return false
+}
+
+// validateExtraProxyPorts - every extra port needs a valid mode and can't be used by anything else
+func validateExtraProxyPorts(cfg *Configuration) error {
+	used := map[string]bool{cfg.ProxyPort: true, cfg.AdminPort: true}
+	for _, extra := range cfg.ExtraProxyPorts {
+		if port, err := strconv.Atoi(extra.Port); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid extra proxy port '%s'", extra.Port)
+		}
+		if !isValidMode(extra.Mode) {
+			return fmt.Errorf("invalid mode '%s' for extra proxy port %s", extra.Mode, extra.Port)
+		}
+		if used[extra.Port] {
+			return fmt.Errorf("extra proxy port %s is already in use", extra.Port)
+		}
+		used[extra.Port] = true
+	}
+	return nil
+}
+
+// fixedModeKey - request context key of the mode of extra proxy port request arrived on
+type fixedModeKey struct{}
+
+// withFixedMode - request is processed in given mode instead of current mode
+func withFixedMode(r *http.Request, mode string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), fixedModeKey{}, mode))
+}
+
+// fixedMode - mode of extra proxy port request arrived on
+func fixedMode(r *http.Request) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	mode, ok := r.Context().Value(fixedModeKey{}).(string)
+	return mode, ok
+}
+
+// requestMode - mode request should be processed in, current mode for requests to the main proxy port
+func requestMode(r *http.Request, cfg *Configuration) string {
+	if mode, ok := fixedMode(r); ok {
+		return mode
+	}
+	return cfg.GetMode()
+}
+
+// fixedModeHandler - marks requests that arrive on extra proxy port with its mode, requests from intercepted
+// CONNECT tunnels are marked by the proxy of the port
+type fixedModeHandler struct {
+	handler http.Handler
+	mode    string
+}
+
+func (h *fixedModeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.ServeHTTP(w, withFixedMode(r, h.mode))
+}
+
+// startExtraProxies - starts listeners of Cfg.ExtraProxyPorts, listeners that were already started are stopped
+// when one of them fails
+func (d *Hoverfly) startExtraProxies() error {
+	for _, extra := range d.Cfg.ExtraProxyPorts {
+		// port was added after proxies were created
+		proxy, ok := d.ExtraProxies[extra.Port]
+		if !ok {
+			if d.ExtraProxies == nil {
+				d.ExtraProxies = make(map[string]*goproxy.ProxyHttpServer)
+			}
+			proxy = d.newProxy(extra.Mode)
+			d.ExtraProxies[extra.Port] = proxy
+		}
+
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%s", extra.Port))
+		if err == nil {
+			var sl *StoppableListener
+			if sl, err = NewStoppableListener(listener); err == nil {
+				d.serveExtraProxy(sl, proxy, extra)
+				continue
+			}
+		}
+
+		for _, sl := range d.extraListeners {
+			sl.Stop()
+		}
+		d.extraListeners = nil
+		return fmt.Errorf("failed to start extra proxy port %s: %s", extra.Port, err.Error())
+	}
+	return nil
+}
+
+// serveExtraProxy - serves extra proxy port until its listener is stopped
+func (d *Hoverfly) serveExtraProxy(sl *StoppableListener, proxy *goproxy.ProxyHttpServer, extra ProxyPortConfig) {
+	d.extraListeners = append(d.extraListeners, sl)
+	server := &http.Server{}
+	server.Handler = &fixedModeHandler{handler: d.proxyHandler(proxy, server), mode: extra.Mode}
+
+	d.Cfg.ProxyControlWG.Add(1)
+	go func() {
+		defer d.Cfg.ProxyControlWG.Done()
+		log.WithFields(log.Fields{
+			"port": extra.Port,
+			"mode": extra.Mode,
+		}).Info("serving extra proxy port")
+		log.Warn(server.Serve(sl))
+	}()
+}
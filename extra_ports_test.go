@@ -0,0 +1,100 @@
+package hoverfly
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// proxiedStatus - sends request to target through proxy on given port and returns response status
+func proxiedStatus(t *testing.T, port, target string) int {
+	proxyURL, _ := url.Parse(fmt.Sprintf("http://localhost:%s", port))
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	response, err := client.Get(target)
+	testutil.Expect(t, err, nil)
+	ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	return response.StatusCode
+}
+
+func TestParseProxyPortConfig(t *testing.T) {
+	extra, err := ParseProxyPortConfig("8600=simulate")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, extra.Port, "8600")
+	testutil.Expect(t, extra.Mode, SimulateMode)
+
+	_, err = ParseProxyPortConfig("8600")
+	testutil.Refute(t, err, nil)
+
+	_, err = ParseProxyPortConfig("=simulate")
+	testutil.Refute(t, err, nil)
+}
+
+func TestValidateExtraProxyPorts(t *testing.T) {
+	cfg := &Configuration{ProxyPort: "8500", AdminPort: "8888"}
+
+	cfg.ExtraProxyPorts = []ProxyPortConfig{{Port: "8600", Mode: SimulateMode}, {Port: "8601", Mode: CaptureMode}}
+	testutil.Expect(t, validateExtraProxyPorts(cfg), nil)
+
+	cfg.ExtraProxyPorts = []ProxyPortConfig{{Port: "8600", Mode: "replay"}}
+	testutil.Refute(t, validateExtraProxyPorts(cfg), nil)
+
+	cfg.ExtraProxyPorts = []ProxyPortConfig{{Port: "not-a-port", Mode: SimulateMode}}
+	testutil.Refute(t, validateExtraProxyPorts(cfg), nil)
+
+	cfg.ExtraProxyPorts = []ProxyPortConfig{{Port: "8500", Mode: SimulateMode}}
+	testutil.Refute(t, validateExtraProxyPorts(cfg), nil)
+
+	cfg.ExtraProxyPorts = []ProxyPortConfig{{Port: "8600", Mode: SimulateMode}, {Port: "8600", Mode: CaptureMode}}
+	testutil.Refute(t, validateExtraProxyPorts(cfg), nil)
+}
+
+func TestExtraProxyPortsUseTheirOwnMode(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.ProxyPort = "9783"
+	dbClient.Cfg.ExtraProxyPorts = []ProxyPortConfig{{Port: "9784", Mode: SimulateMode}, {Port: "9785", Mode: CaptureMode}}
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.UpdateProxy()
+	testutil.Expect(t, dbClient.StartProxy(), nil)
+	defer dbClient.StopProxy()
+
+	// captured through capture port while current mode is simulate
+	testutil.Expect(t, proxiedStatus(t, "9785", server.URL+"/captured"), http.StatusOK)
+	count, err := dbClient.RequestCache.Count()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+
+	// main port and simulate port serve captured request
+	testutil.Expect(t, proxiedStatus(t, "9783", server.URL+"/captured"), http.StatusOK)
+	testutil.Expect(t, proxiedStatus(t, "9784", server.URL+"/captured"), http.StatusOK)
+
+	// simulate port stays in simulate mode once current mode is changed
+	dbClient.Cfg.SetMode(CaptureMode)
+	testutil.Refute(t, proxiedStatus(t, "9784", server.URL+"/missing"), http.StatusOK)
+	count, err = dbClient.RequestCache.Count()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+
+	testutil.Expect(t, proxiedStatus(t, "9783", server.URL+"/other"), http.StatusOK)
+	count, err = dbClient.RequestCache.Count()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 2)
+}
+
+func TestStartProxyFailsWhenExtraPortIsTaken(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.ProxyPort = "9786"
+	dbClient.Cfg.ExtraProxyPorts = []ProxyPortConfig{{Port: "9786", Mode: SimulateMode}}
+	dbClient.UpdateProxy()
+	err := dbClient.StartProxy()
+	defer dbClient.StopProxy()
+	testutil.Refute(t, err, nil)
+}
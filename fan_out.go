@@ -0,0 +1,148 @@
+package hoverfly
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultFanOutTimeout - how long fan-out waits for all targets
+const DefaultFanOutTimeout = 10 * time.Second
+
+// fanOutResult - response (or error) from a single fan-out target
+type fanOutResult struct {
+	target string
+	resp   *http.Response
+	err    error
+}
+
+// successful - responses with 5xx status are treated as failed, same as load balancers do
+func (r fanOutResult) successful() bool {
+	return r.err == nil && r.resp.StatusCode < 500
+}
+
+// fanOutRequest - sends request to every fan-out target concurrently and returns the first successful response,
+// first unsuccessful response is returned when no target succeeded. Every response is logged, including the ones
+// that arrive after the client got its response. Timeout applies to the whole fan-out.
+func (d *Hoverfly) fanOutRequest(request *http.Request) (*http.Response, error) {
+	cfg := d.requestConfig(request)
+	var body []byte
+	if request.Body != nil {
+		var err error
+		if body, err = extractRequestBody(request); err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := cfg.FanOutTimeout
+	if timeout <= 0 {
+		timeout = DefaultFanOutTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+
+	targets := cfg.FanOutTargets
+	results := make(chan fanOutResult, len(targets))
+
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target string) {
+			defer wg.Done()
+			results <- d.sendToFanOutTarget(ctx, request, body, target)
+		}(target)
+	}
+	// releasing context only when the slowest target is done so that its response still gets logged
+	go func() {
+		wg.Wait()
+		cancel()
+	}()
+
+	deadline := time.After(timeout)
+
+	var fallback *fanOutResult
+	for range targets {
+		select {
+		case result := <-results:
+			if result.successful() {
+				return result.resp, nil
+			}
+			if fallback == nil || (fallback.err != nil && result.err == nil) {
+				r := result
+				fallback = &r
+			}
+		case <-deadline:
+			return nil, withErrorCode(ErrorCodeUpstreamUnreachable, fmt.Errorf("fan-out timed out after %s", timeout))
+		}
+	}
+
+	if fallback == nil {
+		return nil, withErrorCode(ErrorCodeUpstreamUnreachable, fmt.Errorf("no fan-out targets configured"))
+	}
+	if fallback.err != nil {
+		return nil, withErrorCode(ErrorCodeUpstreamUnreachable, fallback.err)
+	}
+	return fallback.resp, nil
+}
+
+// sendToFanOutTarget - sends copy of the request to given target (i.e. 'http://10.0.0.1:8080'), keeping original
+// path and query. Response body is read so that the response can be logged and dropped if another target wins.
+func (d *Hoverfly) sendToFanOutTarget(ctx context.Context, request *http.Request, body []byte, target string) fanOutResult {
+	started := time.Now()
+	result := fanOutResult{target: target}
+
+	targetURL, err := url.Parse(target)
+	if err != nil || targetURL.Host == "" {
+		result.err = fmt.Errorf("invalid fan-out target '%s'", target)
+	} else {
+		u := *request.URL
+		u.Scheme = targetURL.Scheme
+		u.Host = targetURL.Host
+
+		var req *http.Request
+		req, err = http.NewRequest(request.Method, u.String(), bytes.NewReader(body))
+		if err == nil {
+			req.Header = cloneHeader(request.Header)
+			result.resp, err = d.HTTP.Do(req.WithContext(ctx))
+		}
+		if err == nil {
+			var respBody []byte
+			respBody, err = ioutil.ReadAll(result.resp.Body)
+			result.resp.Body.Close()
+			result.resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+			result.resp.ContentLength = int64(len(respBody))
+		}
+		result.err = err
+	}
+
+	fields := log.Fields{
+		"target":   target,
+		"method":   request.Method,
+		"path":     request.URL.Path,
+		"duration": time.Since(started).String(),
+	}
+	if result.err != nil {
+		fields["error"] = result.err.Error()
+		log.WithFields(fields).Warn("fan-out target failed")
+	} else {
+		fields["status"] = result.resp.StatusCode
+		fields["bodyLength"] = result.resp.ContentLength
+		log.WithFields(fields).Info("fan-out target responded")
+	}
+
+	return result
+}
+
+func cloneHeader(h http.Header) http.Header {
+	c := make(http.Header, len(h))
+	for k, v := range h {
+		c[k] = append([]string(nil), v...)
+	}
+	return c
+}
@@ -0,0 +1,119 @@
+package hoverfly
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func fanOutTarget(code int, body string, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.WriteHeader(code)
+		fmt.Fprint(w, body)
+	}))
+}
+
+func fanOutTools(targets ...*httptest.Server) (*httptest.Server, *Hoverfly) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.SetMode(ModifyMode)
+	for _, target := range targets {
+		dbClient.Cfg.FanOutTargets = append(dbClient.Cfg.FanOutTargets, target.URL)
+	}
+	return server, dbClient
+}
+
+func TestFanOutReturnsFirstResponse(t *testing.T) {
+	slow := fanOutTarget(200, "slow", 200*time.Millisecond)
+	defer slow.Close()
+	fast := fanOutTarget(200, "fast", 0)
+	defer fast.Close()
+
+	server, dbClient := fanOutTools(slow, fast)
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", "http://example.com/path?q=1", nil)
+	testutil.Expect(t, err, nil)
+
+	resp, err := dbClient.fanOutRequest(req)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, 200)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "fast")
+}
+
+func TestFanOutSkipsFailedTargets(t *testing.T) {
+	failing := fanOutTarget(503, "down", 0)
+	defer failing.Close()
+	healthy := fanOutTarget(200, "healthy", 50*time.Millisecond)
+	defer healthy.Close()
+
+	server, dbClient := fanOutTools(failing, healthy)
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+
+	resp, err := dbClient.fanOutRequest(req)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, 200)
+}
+
+func TestFanOutReturnsFailedResponseWhenNoTargetSucceeds(t *testing.T) {
+	failing := fanOutTarget(503, "down", 0)
+	defer failing.Close()
+
+	server, dbClient := fanOutTools(failing)
+	defer server.Close()
+	dbClient.Cfg.FanOutTargets = append(dbClient.Cfg.FanOutTargets, "http://127.0.0.1:1")
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+
+	resp, err := dbClient.fanOutRequest(req)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, 503)
+}
+
+func TestFanOutTimeout(t *testing.T) {
+	slow := fanOutTarget(200, "slow", 500*time.Millisecond)
+	defer slow.Close()
+
+	server, dbClient := fanOutTools(slow)
+	defer server.Close()
+	dbClient.Cfg.FanOutTimeout = 50 * time.Millisecond
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+
+	_, err = dbClient.fanOutRequest(req)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, errorCodeOf(err, ErrorCodeUnknown), ErrorCodeUpstreamUnreachable)
+}
+
+func TestModifyModeUsesFanOut(t *testing.T) {
+	target := fanOutTarget(200, "from target", 0)
+	defer target.Close()
+
+	server, dbClient := fanOutTools(target)
+	defer server.Close()
+	dbClient.Cfg.Middleware = "./examples/middleware/modify_request/modify_request.py"
+
+	req, err := http.NewRequest("POST", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, 202)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "from target")
+}
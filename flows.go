@@ -0,0 +1,373 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// FlowSessionCookie - cookie that correlates requests of the same flow run
+const FlowSessionCookie = "hoverfly_flow_session"
+
+// DefaultFlowTTL - incomplete flow runs are removed after this long without progress
+const DefaultFlowTTL = 10 * time.Minute
+
+const (
+	// flowKeyPrefix - prefix for metadata keys that hold flow definitions
+	flowKeyPrefix = "flow_"
+	// flowStateKeyPrefix - prefix for metadata keys that hold flow progress per session
+	flowStateKeyPrefix = "flowstate_"
+)
+
+// FlowMatcher - matches request of a flow step, empty fields match anything
+type FlowMatcher struct {
+	Method      string `json:"method"`
+	Destination string `json:"destination"`
+	Path        string `json:"path"`
+	Query       string `json:"query"`
+}
+
+// FlowStep - response served when request matches flow step
+type FlowStep struct {
+	Request  FlowMatcher                `json:"request"`
+	Response models.ResponseDetailsView `json:"response"`
+}
+
+// FlowSimulation - named ordered list of steps that have to be requested one after another, i.e. OAuth2
+// authorize redirect, token exchange and API call
+type FlowSimulation struct {
+	Name  string     `json:"name"`
+	Steps []FlowStep `json:"steps"`
+}
+
+// flowState - progress of a flow run
+type flowState struct {
+	Step      int       `json:"step"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Validate - checks whether flow can be stored
+func (f FlowSimulation) Validate() error {
+	if f.Name == "" {
+		return fmt.Errorf("flow name is required")
+	}
+	if strings.Contains(f.Name, "_") {
+		return fmt.Errorf("flow name can't contain '_'")
+	}
+	if len(f.Steps) == 0 {
+		return fmt.Errorf("flow '%s' has no steps", f.Name)
+	}
+	return nil
+}
+
+func (m FlowMatcher) matches(req *http.Request) bool {
+	return (m.Method == "" || strings.EqualFold(m.Method, req.Method)) &&
+		(m.Destination == "" || m.Destination == req.Host) &&
+		(m.Path == "" || m.Path == req.URL.Path) &&
+		(m.Query == "" || m.Query == req.URL.RawQuery)
+}
+
+func flowStateKey(session, name string) []byte {
+	return []byte(flowStateKeyPrefix + session + "_" + name)
+}
+
+// SaveFlow - stores flow definition, existing flow with the same name is replaced
+func (d *Hoverfly) SaveFlow(flow FlowSimulation) error {
+	if err := flow.Validate(); err != nil {
+		return err
+	}
+	bts, err := json.Marshal(flow)
+	if err != nil {
+		return err
+	}
+	if err := d.MetadataCache.Set([]byte(flowKeyPrefix+flow.Name), bts); err != nil {
+		return err
+	}
+	d.flows.addFlow(flow.Name)
+	return nil
+}
+
+// GetFlows - returns stored flow definitions, sorted by name
+func (d *Hoverfly) GetFlows() ([]FlowSimulation, error) {
+	names, err := d.flows.flowNames(d.MetadataCache)
+	if err != nil {
+		return nil, err
+	}
+
+	flows := []FlowSimulation{}
+	for _, name := range names {
+		v, err := d.MetadataCache.Get([]byte(flowKeyPrefix + name))
+		if err != nil {
+			// removed together with other metadata
+			d.flows.removeFlow(name)
+			continue
+		}
+		var flow FlowSimulation
+		if err := json.Unmarshal(v, &flow); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"flow":  name,
+			}).Warn("Failed to decode flow")
+			continue
+		}
+		flows = append(flows, flow)
+	}
+
+	return flows, nil
+}
+
+// getFlowState - returns current step of flow run, zero is returned for new and expired runs
+func (d *Hoverfly) getFlowState(session, name string) int {
+	bts, err := d.MetadataCache.Get(flowStateKey(session, name))
+	if err != nil {
+		return 0
+	}
+
+	var state flowState
+	if err := json.Unmarshal(bts, &state); err != nil || d.flowExpired(state, time.Now()) {
+		return 0
+	}
+	return state.Step
+}
+
+func (d *Hoverfly) flowExpired(state flowState, now time.Time) bool {
+	return d.Cfg.FlowTTL > 0 && now.Sub(state.UpdatedAt) > d.Cfg.FlowTTL
+}
+
+// CleanupFlows - removes flow runs that made no progress within flow TTL, returns how many were removed
+func (d *Hoverfly) CleanupFlows() (int, error) {
+	now := time.Now()
+	keys, err := d.flows.expiredRuns(d.MetadataCache, func(updatedAt time.Time) bool {
+		return d.flowExpired(flowState{UpdatedAt: updatedAt}, now)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, k := range keys {
+		if err := d.MetadataCache.Delete([]byte(k)); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"key":   k,
+			}).Warn("Failed to remove expired flow run")
+			continue
+		}
+		d.flows.removeRun(k)
+		removed++
+	}
+
+	return removed, nil
+}
+
+// getFlowResponse - returns response of the flow step that given request matches, nil when request is not a part
+// of any flow. Request can either continue flow run identified by session cookie or start a new run.
+func (d *Hoverfly) getFlowResponse(req *http.Request) *http.Response {
+	flows, err := d.GetFlows()
+	if err != nil || len(flows) == 0 {
+		return nil
+	}
+
+	if _, err := d.CleanupFlows(); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("Failed to clean up expired flow runs")
+	}
+
+	session := ""
+	if cookie, err := req.Cookie(FlowSessionCookie); err == nil {
+		session = cookie.Value
+	}
+
+	for _, flow := range flows {
+		step := 0
+		if session != "" {
+			step = d.getFlowState(session, flow.Name)
+		}
+		// flow might have been replaced with shorter one
+		if step >= len(flow.Steps) {
+			step = 0
+		}
+
+		// request that starts the flow again restarts the run
+		if !flow.Steps[step].Request.matches(req) {
+			if step == 0 || !flow.Steps[0].Request.matches(req) {
+				continue
+			}
+			step = 0
+		}
+
+		newSession := session == ""
+		if newSession {
+			session = string(GetRandomName(16))
+		}
+
+		d.advanceFlow(session, flow, step)
+
+		c := NewConstructor(req, models.Payload{Response: flow.Steps[step].Response.ConvertToResponseDetails()})
+		resp := c.ReconstructResponse()
+		if newSession {
+			resp.Header.Add("Set-Cookie", (&http.Cookie{Name: FlowSessionCookie, Value: session, Path: "/"}).String())
+		}
+
+		log.WithFields(log.Fields{
+			"flow":        flow.Name,
+			"step":        step + 1,
+			"steps":       len(flow.Steps),
+			"path":        req.URL.Path,
+			"method":      req.Method,
+			"destination": req.Host,
+		}).Info("Flow step matched, returning")
+
+		return resp
+	}
+
+	return nil
+}
+
+// advanceFlow - stores flow progress after given step was served, completed runs are removed
+func (d *Hoverfly) advanceFlow(session string, flow FlowSimulation, step int) {
+	key := flowStateKey(session, flow.Name)
+
+	var err error
+	if step+1 >= len(flow.Steps) {
+		if err = d.MetadataCache.Delete(key); err == nil {
+			d.flows.removeRun(string(key))
+		}
+	} else {
+		state := flowState{Step: step + 1, UpdatedAt: time.Now()}
+		var bts []byte
+		bts, err = json.Marshal(state)
+		if err == nil {
+			err = d.MetadataCache.Set(key, bts)
+		}
+		if err == nil {
+			d.flows.setRun(d.MetadataCache, string(key), state.UpdatedAt)
+		}
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"flow":  flow.Name,
+		}).Warn("Failed to store flow progress")
+	}
+}
+
+// flowIndex - names of stored flows and last progress of flow runs, so that proxied requests don't have to list
+// metadata cache keys. It is loaded from metadata cache on first use, flows and runs stored through Hoverfly are
+// added to it afterwards.
+type flowIndex struct {
+	mu     sync.Mutex
+	loaded bool
+	names  map[string]bool
+	// runs - time of last progress keyed on flow state key, zero when state couldn't be decoded
+	runs map[string]time.Time
+}
+
+func newFlowIndex() *flowIndex {
+	return &flowIndex{}
+}
+
+// load - reads flows and runs from metadata cache unless they were already read, mu must be held
+func (i *flowIndex) load(metadata cache.Cache) error {
+	if i.loaded {
+		return nil
+	}
+	keys, err := metadata.Keys()
+	if err != nil {
+		return err
+	}
+
+	i.names = make(map[string]bool)
+	i.runs = make(map[string]time.Time)
+	for _, k := range keys {
+		switch {
+		case strings.HasPrefix(k, flowKeyPrefix):
+			i.names[strings.TrimPrefix(k, flowKeyPrefix)] = true
+		case strings.HasPrefix(k, flowStateKeyPrefix):
+			var state flowState
+			if v, err := metadata.Get([]byte(k)); err == nil {
+				json.Unmarshal(v, &state)
+			}
+			i.runs[k] = state.UpdatedAt
+		}
+	}
+	i.loaded = true
+	return nil
+}
+
+// flowNames - returns names of stored flows, sorted
+func (i *flowIndex) flowNames(metadata cache.Cache) ([]string, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if err := i.load(metadata); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(i.names))
+	for name := range i.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// addFlow - flows stored before the index is loaded are read with the rest
+func (i *flowIndex) addFlow(name string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.loaded {
+		i.names[name] = true
+	}
+}
+
+func (i *flowIndex) removeFlow(name string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.names, name)
+}
+
+// setRun - loads the index so that runs stored before it are cleaned up as well
+func (i *flowIndex) setRun(metadata cache.Cache, key string, updatedAt time.Time) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if err := i.load(metadata); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("Failed to load flow runs")
+		return
+	}
+	i.runs[key] = updatedAt
+}
+
+func (i *flowIndex) removeRun(key string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.runs, key)
+}
+
+// expiredRuns - returns state keys of runs that expired and of runs whose state couldn't be decoded
+func (i *flowIndex) expiredRuns(metadata cache.Cache, expired func(updatedAt time.Time) bool) ([]string, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if err := i.load(metadata); err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for k, updatedAt := range i.runs {
+		if updatedAt.IsZero() || expired(updatedAt) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,201 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func oauthFlow() FlowSimulation {
+	return FlowSimulation{
+		Name: "oauth",
+		Steps: []FlowStep{
+			{
+				Request:  FlowMatcher{Method: "GET", Destination: "auth.example.com", Path: "/authorize"},
+				Response: models.ResponseDetailsView{Status: 302, Body: "redirect"},
+			},
+			{
+				Request:  FlowMatcher{Method: "POST", Destination: "auth.example.com", Path: "/token"},
+				Response: models.ResponseDetailsView{Status: 200, Body: `{"access_token":"abc"}`},
+			},
+			{
+				Request:  FlowMatcher{Method: "GET", Destination: "api.example.com", Path: "/me"},
+				Response: models.ResponseDetailsView{Status: 200, Body: `{"name":"john"}`},
+			},
+		},
+	}
+}
+
+func flowRequest(t *testing.T, method, url string, cookie *http.Cookie) *http.Request {
+	req, err := http.NewRequest(method, url, nil)
+	testutil.Expect(t, err, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+func flowSessionCookie(resp *http.Response) *http.Cookie {
+	for _, c := range resp.Cookies() {
+		if c.Name == FlowSessionCookie {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestFlowStepsAreServedInOrder(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	testutil.Expect(t, dbClient.SaveFlow(oauthFlow()), nil)
+
+	resp := dbClient.getFlowResponse(flowRequest(t, "GET", "http://auth.example.com/authorize", nil))
+	testutil.Refute(t, resp, nil)
+	testutil.Expect(t, resp.StatusCode, 302)
+
+	cookie := flowSessionCookie(resp)
+	testutil.Refute(t, cookie, nil)
+
+	// out of order request doesn't match the flow
+	resp = dbClient.getFlowResponse(flowRequest(t, "GET", "http://api.example.com/me", cookie))
+	testutil.Expect(t, resp == nil, true)
+
+	resp = dbClient.getFlowResponse(flowRequest(t, "POST", "http://auth.example.com/token", cookie))
+	testutil.Refute(t, resp, nil)
+	testutil.Expect(t, resp.StatusCode, 200)
+	testutil.Expect(t, flowSessionCookie(resp) == nil, true)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), `{"access_token":"abc"}`)
+
+	resp = dbClient.getFlowResponse(flowRequest(t, "GET", "http://api.example.com/me", cookie))
+	testutil.Refute(t, resp, nil)
+
+	body, err = ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), `{"name":"john"}`)
+
+	// completed run is removed
+	testutil.Expect(t, dbClient.getFlowState(cookie.Value, "oauth"), 0)
+}
+
+func TestFlowRequestWithoutSessionStartsNewRun(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	testutil.Expect(t, dbClient.SaveFlow(oauthFlow()), nil)
+
+	resp := dbClient.getFlowResponse(flowRequest(t, "POST", "http://auth.example.com/token", nil))
+	testutil.Expect(t, resp == nil, true)
+}
+
+func TestCleanupFlowsRemovesExpiredRuns(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.FlowTTL = time.Minute
+	flow := oauthFlow()
+	testutil.Expect(t, dbClient.SaveFlow(flow), nil)
+
+	bts, err := json.Marshal(flowState{Step: 1, UpdatedAt: time.Now().Add(-2 * time.Minute)})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, dbClient.MetadataCache.Set(flowStateKey("stale", flow.Name), bts), nil)
+
+	dbClient.advanceFlow("fresh", flow, 0)
+
+	removed, err := dbClient.CleanupFlows()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, removed, 1)
+
+	testutil.Expect(t, dbClient.getFlowState("stale", flow.Name), 0)
+	testutil.Expect(t, dbClient.getFlowState("fresh", flow.Name), 1)
+}
+
+func TestCreateFlowHandler(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	bts, err := json.Marshal(oauthFlow())
+	testutil.Expect(t, err, nil)
+
+	req, err := http.NewRequest("POST", "/api/flows", bytes.NewReader(bts))
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusCreated)
+
+	req, err = http.NewRequest("GET", "/api/flows", nil)
+	testutil.Expect(t, err, nil)
+
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var fr flowsResponse
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &fr), nil)
+	testutil.Expect(t, len(fr.Flows), 1)
+	testutil.Expect(t, fr.Flows[0].Name, "oauth")
+	testutil.Expect(t, len(fr.Flows[0].Steps), 3)
+}
+
+func TestCreateFlowHandlerInvalidFlow(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("POST", "/api/flows", bytes.NewBufferString(`{"name": "empty"}`))
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusBadRequest)
+}
+
+// keysCountingCache - counts how many times keys are listed
+type keysCountingCache struct {
+	cache.Cache
+	listed int
+}
+
+func (c *keysCountingCache) Keys() ([]string, error) {
+	c.listed++
+	return c.Cache.Keys()
+}
+
+func TestFlowRequestsDontListMetadataKeys(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	metadata := &keysCountingCache{Cache: dbClient.MetadataCache}
+	dbClient.MetadataCache = metadata
+	dbClient.Cfg.FlowTTL = time.Minute
+	testutil.Expect(t, dbClient.SaveFlow(oauthFlow()), nil)
+
+	for i := 0; i < 5; i++ {
+		resp := dbClient.getFlowResponse(flowRequest(t, "GET", "http://auth.example.com/authorize", nil))
+		testutil.Refute(t, resp, nil)
+	}
+	testutil.Expect(t, metadata.listed, 1)
+
+	flows, err := dbClient.GetFlows()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(flows), 1)
+
+	// flows removed with the rest of metadata aren't served
+	testutil.Expect(t, dbClient.MetadataCache.DeleteData(), nil)
+	resp := dbClient.getFlowResponse(flowRequest(t, "GET", "http://auth.example.com/authorize", nil))
+	testutil.Expect(t, resp == nil, true)
+	testutil.Expect(t, metadata.listed, 1)
+}
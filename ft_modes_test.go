@@ -87,7 +87,8 @@ var _ = Describe("Running Hoverfly in various modes", func() {
 						    "Go-http-client/1.1"
 						  ]
 						}
-					      }
+					      },
+					      "requestBodySHA256": "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
 					    }
 					  ]
 					}`, expectedDestination)))
@@ -153,7 +154,8 @@ var _ = Describe("Running Hoverfly in various modes", func() {
 						    "Go-http-client/1.1"
 						  ]
 						}
-					      }
+					      },
+					      "requestBodySHA256": "8e362be846ae1ad29655564287e3f464c4c5a894795fb957ab0907d4ec4c6240"
 					    }
 					  ]
 					}`, expectedDestination)))
@@ -65,9 +65,13 @@ var _ = BeforeSuite(func() {
 	tokenCache := cache.NewBoltDBCache(db, []byte(backends.TokenBucketName))
 	userCache := cache.NewBoltDBCache(db, []byte(backends.UserBucketName))
 	authBackend := backends.NewCacheBasedAuthBackend(tokenCache, userCache)
-	hf = hoverfly.GetNewHoverfly(cfg, requestCache, metadataCache, authBackend)
+	var err error
+	hf, err = hoverfly.GetNewHoverfly(cfg, requestCache, metadataCache, authBackend)
+	if err != nil {
+		panic(err)
+	}
 
-	err := hf.StartProxy()
+	err = hf.StartProxy()
 
 	if err != nil {
 		panic(err)
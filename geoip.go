@@ -0,0 +1,91 @@
+package hoverfly
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoLocator - resolves client IP to ISO country code
+type GeoLocator interface {
+	Country(ip net.IP) (string, error)
+}
+
+// GeoIPLocator - GeoLocator backed by MaxMind GeoLite2 City database
+type GeoIPLocator struct {
+	db *geoip2.Reader
+}
+
+// NewGeoIPLocator - opens given MaxMind .mmdb database
+func NewGeoIPLocator(path string) (*GeoIPLocator, error) {
+	db, err := geoip2.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &GeoIPLocator{db: db}, nil
+}
+
+// Country - returns ISO country code for given IP, empty string if database has no record for it
+func (l *GeoIPLocator) Country(ip net.IP) (string, error) {
+	record, err := l.db.City(ip)
+	if err != nil {
+		return "", err
+	}
+	return record.Country.IsoCode, nil
+}
+
+// Close - closes underlying database
+func (l *GeoIPLocator) Close() error {
+	return l.db.Close()
+}
+
+// clientIP - returns IP address of the client that sent the request
+func clientIP(req *http.Request) (net.IP, error) {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("failed to parse client IP: %s", req.RemoteAddr)
+	}
+	return ip, nil
+}
+
+// applyGeoIPHeader - adds country of the client to simulated response, response is left unchanged when
+// client can't be located
+func (d *Hoverfly) applyGeoIPHeader(req *http.Request, resp *http.Response) {
+	cfg := d.requestConfig(req)
+	if d.GeoIP == nil || cfg.GeoIPResponseHeader == "" || resp == nil {
+		return
+	}
+
+	ip, err := clientIP(req)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("Failed to resolve client location")
+		return
+	}
+
+	country, err := d.GeoIP.Country(ip)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"ip":    ip.String(),
+		}).Warn("Failed to resolve client location")
+		return
+	}
+
+	if country == "" {
+		return
+	}
+
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	resp.Header.Set(cfg.GeoIPResponseHeader, country)
+}
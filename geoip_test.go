@@ -0,0 +1,73 @@
+package hoverfly
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+type staticLocator map[string]string
+
+func (l staticLocator) Country(ip net.IP) (string, error) {
+	country, ok := l[ip.String()]
+	if !ok {
+		return "", errors.New("address not found")
+	}
+	return country, nil
+}
+
+func TestClientIP(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+
+	req.RemoteAddr = "81.2.69.160:51234"
+	ip, err := clientIP(req)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, ip.String(), "81.2.69.160")
+
+	req.RemoteAddr = "[2001:db8::1]:51234"
+	ip, err = clientIP(req)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, ip.String(), "2001:db8::1")
+
+	req.RemoteAddr = "not-an-ip"
+	_, err = clientIP(req)
+	testutil.Refute(t, err, nil)
+}
+
+func TestApplyGeoIPHeader(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.GeoIPResponseHeader = "X-Simulated-Country"
+	dbClient.GeoIP = staticLocator{"81.2.69.160": "DE"}
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	req.RemoteAddr = "81.2.69.160:51234"
+
+	resp := &http.Response{StatusCode: 200, Header: make(http.Header)}
+	dbClient.applyGeoIPHeader(req, resp)
+	testutil.Expect(t, resp.Header.Get("X-Simulated-Country"), "DE")
+
+	// unknown client - header is not added
+	req.RemoteAddr = "10.0.0.1:51234"
+	resp = &http.Response{StatusCode: 200, Header: make(http.Header)}
+	dbClient.applyGeoIPHeader(req, resp)
+	testutil.Expect(t, resp.Header.Get("X-Simulated-Country"), "")
+}
+
+func TestApplyGeoIPHeaderDisabled(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.GeoIPResponseHeader = "X-Simulated-Country"
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	req.RemoteAddr = "81.2.69.160:51234"
+
+	resp := &http.Response{StatusCode: 200, Header: make(http.Header)}
+	dbClient.applyGeoIPHeader(req, resp)
+	testutil.Expect(t, len(resp.Header), 0)
+}
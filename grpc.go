@@ -0,0 +1,654 @@
+package hoverfly
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultGRPCPort - default port of gRPC listener
+const DefaultGRPCPort = "8502"
+
+// GRPCDestination - destination of captured gRPC calls, records are keyed on method path and request messages only
+const GRPCDestination = "grpc"
+
+// gRPC status codes returned by Hoverfly itself
+const (
+	grpcStatusNotFound      = 5
+	grpcStatusUnimplemented = 12
+	grpcStatusInternal      = 13
+	grpcStatusUnavailable   = 14
+)
+
+// GRPCMode - gRPC interception. gRPC calls are served on their own port over HTTP/2 without TLS, in capture mode
+// they are forwarded to Upstream ('host:port', or 'https://host:port' for TLS) and recorded, in simulate mode
+// recorded responses are returned. When ProtoDescriptorDir is set, only methods declared in descriptor sets
+// ('protoc --descriptor_set_out') found there are served.
+type GRPCMode struct {
+	Enabled            bool
+	Port               string
+	Upstream           string
+	ProtoDescriptorDir string
+}
+
+// grpcRawCodec - keeps messages as they are (protobuf encoded), so calls of any service are proxied and matched on
+// method and exact request bytes without their types being known
+type grpcRawCodec struct{}
+
+func (grpcRawCodec) Marshal(v interface{}) ([]byte, error) {
+	message, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected gRPC message type %T", v)
+	}
+	return *message, nil
+}
+
+func (grpcRawCodec) Unmarshal(data []byte, v interface{}) error {
+	message, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected gRPC message type %T", v)
+	}
+	*message = append([]byte(nil), data...)
+	return nil
+}
+
+func (grpcRawCodec) Name() string {
+	return "proto"
+}
+
+// grpcHandler - gRPC reverse proxy, every call is handled as bidirectional stream by the unknown service handler
+type grpcHandler struct {
+	hf       *Hoverfly
+	methods  map[string]bool
+	upstream *grpc.ClientConn
+	server   *grpc.Server
+}
+
+// StartGRPCProxy - starts gRPC listener on configured port
+func (d *Hoverfly) StartGRPCProxy() error {
+	handler, err := d.newGRPCHandler()
+	if err != nil {
+		return err
+	}
+
+	port := d.Cfg.GRPC.Port
+	if port == "" {
+		port = DefaultGRPCPort
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+	if err != nil {
+		handler.close()
+		return err
+	}
+	d.grpcProxy = handler
+
+	go func() {
+		if err := handler.server.Serve(listener); err != nil && err != grpc.ErrServerStopped {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"port":  port,
+			}).Error("gRPC listener stopped")
+		}
+	}()
+
+	log.WithFields(log.Fields{
+		"port":     port,
+		"upstream": d.Cfg.GRPC.Upstream,
+		"methods":  len(handler.methods),
+	}).Info("serving gRPC")
+
+	return nil
+}
+
+// StopGRPCProxy - stops gRPC listener
+func (d *Hoverfly) StopGRPCProxy() {
+	if d.grpcProxy != nil {
+		d.grpcProxy.close()
+		d.grpcProxy = nil
+	}
+}
+
+func (d *Hoverfly) newGRPCHandler() (*grpcHandler, error) {
+	h := &grpcHandler{hf: d}
+
+	if d.Cfg.GRPC.ProtoDescriptorDir != "" {
+		methods, err := loadGRPCMethods(d.Cfg.GRPC.ProtoDescriptorDir)
+		if err != nil {
+			return nil, err
+		}
+		h.methods = methods
+	}
+
+	if d.Cfg.GRPC.Upstream != "" {
+		conn, err := d.grpcUpstreamConn(d.Cfg.GRPC.Upstream)
+		if err != nil {
+			return nil, err
+		}
+		h.upstream = conn
+	}
+
+	h.server = grpc.NewServer(grpc.ForceServerCodec(grpcRawCodec{}), grpc.UnknownServiceHandler(h.handleStream))
+	return h, nil
+}
+
+func (h *grpcHandler) close() {
+	h.server.Stop()
+	if h.upstream != nil {
+		h.upstream.Close()
+	}
+}
+
+// grpcUpstreamURL - parses gRPC server address, 'host:port', or 'https://host:port' for TLS
+func grpcUpstreamURL(target string) (*url.URL, error) {
+	upstream := target
+	if !strings.Contains(upstream, "://") {
+		upstream = "http://" + upstream
+	}
+	u, err := url.Parse(upstream)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid gRPC upstream '%s', expected 'host:port' or 'https://host:port'", target)
+	}
+	return u, nil
+}
+
+// grpcTLSConfig - TLS configuration of gRPC upstream connections
+func (d *Hoverfly) grpcTLSConfig() *tls.Config {
+	if d.upstreamTLS != nil {
+		return d.upstreamTLS.Clone()
+	}
+	return &tls.Config{InsecureSkipVerify: d.Cfg.TLSVerification}
+}
+
+// grpcUpstreamConn - returns connection to gRPC server ('host:port', or 'https://host:port' for TLS), it is
+// established on first call
+func (d *Hoverfly) grpcUpstreamConn(target string) (*grpc.ClientConn, error) {
+	u, err := grpcUpstreamURL(target)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := insecure.NewCredentials()
+	if u.Scheme == "https" {
+		creds = credentials.NewTLS(d.grpcTLSConfig())
+	}
+	return grpc.NewClient(u.Host, grpc.WithTransportCredentials(creds), grpc.WithDefaultCallOptions(grpc.ForceCodec(grpcRawCodec{})))
+}
+
+// grpcClient - returns URL of gRPC server ('host:port', or 'https://host:port' for TLS) and HTTP/2 client for it,
+// used to exchange length prefixed messages directly
+func (d *Hoverfly) grpcClient(target string) (*url.URL, *http.Client, error) {
+	u, err := grpcUpstreamURL(target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transport := &http.Transport{TLSClientConfig: d.grpcTLSConfig()}
+	var protocols http.Protocols
+	if u.Scheme == "https" {
+		protocols.SetHTTP2(true)
+	} else {
+		protocols.SetUnencryptedHTTP2(true)
+	}
+	transport.Protocols = &protocols
+	return u, &http.Client{Transport: transport}, nil
+}
+
+// handleStream - serves every gRPC call, streams are forwarded message by message in capture mode
+func (h *grpcHandler) handleStream(srv interface{}, stream grpc.ServerStream) error {
+	method, _ := grpc.MethodFromServerStream(stream)
+	if h.methods != nil && !h.methods[method] {
+		return status.Errorf(codes.Unimplemented, "method %s is not declared in proto descriptors", method)
+	}
+	md, _ := metadata.FromIncomingContext(stream.Context())
+
+	switch h.hf.Cfg.GetMode() {
+	case SimulateMode:
+		if err := h.simulatedGRPCError(method); err != nil {
+			return err
+		}
+		messages, err := grpcReceiveMessages(stream)
+		if err != nil {
+			return err
+		}
+		request := grpcRequestDetails(method, grpcHeader(md), grpcFrames(messages))
+		if ok, err := h.simulate(stream, request.Hash()); ok {
+			return err
+		}
+		return status.Errorf(codes.NotFound, "Hoverfly has no recorded response for %s", method)
+	case SpyMode:
+		messages, err := grpcReceiveMessages(stream)
+		if err != nil {
+			return err
+		}
+		request := grpcRequestDetails(method, grpcHeader(md), grpcFrames(messages))
+		if ok, err := h.simulate(stream, request.Hash()); ok {
+			return err
+		}
+		return h.forward(stream, method, md, grpcMessageSource(messages), nil)
+	case CaptureMode:
+		return h.forward(stream, method, md, grpcStreamSource(stream), func(requests [][]byte, response models.ResponseDetails) {
+			request := grpcRequestDetails(method, grpcHeader(md), grpcFrames(requests))
+			h.hf.storePayload(h.hf.Cfg, request.Hash(), models.Payload{Request: request, Response: response})
+		})
+	default:
+		return h.forward(stream, method, md, grpcStreamSource(stream), nil)
+	}
+}
+
+// simulate - sends recorded response and returns status of the call, ok is false when there is no record
+func (h *grpcHandler) simulate(stream grpc.ServerStream, key string) (ok bool, err error) {
+	bts, err := h.hf.RequestCache.Get([]byte(key))
+	if err != nil {
+		return false, nil
+	}
+	payload, err := models.NewPayloadFromBytes(bts)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Error("Failed to decode recorded gRPC call")
+		return false, nil
+	}
+
+	return true, grpcReplayResponse(stream, payload.Response)
+}
+
+// forward - sends request messages read from next to upstream and relays its response as it arrives, captured is
+// called with request messages and the complete response once upstream finished the call
+func (h *grpcHandler) forward(stream grpc.ServerStream, method string, md metadata.MD, next func() ([]byte, error), captured func([][]byte, models.ResponseDetails)) error {
+	if h.upstream == nil {
+		return status.Error(codes.Unavailable, "gRPC upstream is not configured")
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	upstream, err := h.upstream.NewStream(metadata.NewOutgoingContext(ctx, grpcForwardedMetadata(md)), &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, method)
+	if err != nil {
+		return h.upstreamFailed(method, err)
+	}
+
+	// client messages are relayed until the client or upstream ends the stream
+	var mu sync.Mutex
+	var requests [][]byte
+	go func() {
+		for {
+			message, err := next()
+			if err != nil {
+				if err == io.EOF {
+					upstream.CloseSend()
+				}
+				return
+			}
+			mu.Lock()
+			requests = append(requests, message)
+			mu.Unlock()
+			if err := upstream.SendMsg(&message); err != nil {
+				return
+			}
+		}
+	}()
+
+	header, err := upstream.Header()
+	if err == nil && len(header) > 0 {
+		if err := stream.SendHeader(header); err != nil {
+			return err
+		}
+	}
+
+	var responses [][]byte
+	for {
+		var message []byte
+		if err = upstream.RecvMsg(&message); err != nil {
+			break
+		}
+		responses = append(responses, message)
+		if err := stream.SendMsg(&message); err != nil {
+			return err
+		}
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	trailer := upstream.Trailer()
+	stream.SetTrailer(trailer)
+
+	st := status.Convert(err)
+	if header == nil && st.Code() == codes.Unavailable {
+		return h.upstreamFailed(method, err)
+	}
+
+	if captured != nil {
+		mu.Lock()
+		calls := append([][]byte(nil), requests...)
+		mu.Unlock()
+		captured(calls, grpcResponseDetails(header, responses, trailer, st))
+	}
+	return st.Err()
+}
+
+func (h *grpcHandler) upstreamFailed(method string, err error) error {
+	log.WithFields(log.Fields{
+		"error":  err.Error(),
+		"method": method,
+	}).Warn("gRPC upstream failed")
+	return status.Errorf(codes.Unavailable, "gRPC upstream failed: %s", status.Convert(err).Message())
+}
+
+// grpcStreamSource - reads request messages from client stream
+func grpcStreamSource(stream grpc.ServerStream) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		var message []byte
+		err := stream.RecvMsg(&message)
+		return message, err
+	}
+}
+
+// grpcMessageSource - returns request messages that were already read
+func grpcMessageSource(messages [][]byte) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		if len(messages) == 0 {
+			return nil, io.EOF
+		}
+		message := messages[0]
+		messages = messages[1:]
+		return message, nil
+	}
+}
+
+// grpcReceiveMessages - reads all request messages, calls are matched once client finished sending
+func grpcReceiveMessages(stream grpc.ServerStream) ([][]byte, error) {
+	next := grpcStreamSource(stream)
+	var messages [][]byte
+	for {
+		message, err := next()
+		if err == io.EOF {
+			return messages, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+}
+
+// grpcRequestDetails - request part of gRPC record, body holds length prefixed request messages base64 encoded
+// since request bodies of records are stored as text
+func grpcRequestDetails(method string, header http.Header, body []byte) models.RequestDetails {
+	headers := make(map[string][]string)
+	for name, values := range header {
+		// metadata that differs between calls of the same client
+		if name == "Grpc-Timeout" || name == "User-Agent" {
+			continue
+		}
+		headers[name] = values
+	}
+
+	return models.RequestDetails{
+		Method:      "POST",
+		Scheme:      GRPCDestination,
+		Destination: GRPCDestination,
+		Path:        method,
+		Body:        base64.StdEncoding.EncodeToString(body),
+		Headers:     headers,
+	}
+}
+
+// grpcResponseDetails - response part of gRPC record, body holds length prefixed messages and status is kept in
+// trailers as it is sent over HTTP/2
+func grpcResponseDetails(header metadata.MD, messages [][]byte, trailer metadata.MD, st *status.Status) models.ResponseDetails {
+	headers := grpcHeader(header)
+	headers.Set("Content-Type", "application/grpc")
+
+	trailers := grpcHeader(trailer)
+	trailers.Set("Grpc-Status", strconv.Itoa(int(st.Code())))
+	if st.Message() != "" {
+		trailers.Set("Grpc-Message", url.PathEscape(st.Message()))
+	}
+
+	return models.ResponseDetails{
+		Status:   http.StatusOK,
+		Body:     string(grpcFrames(messages)),
+		Headers:  headers,
+		Trailers: trailers,
+	}
+}
+
+// grpcReplayResponse - sends recorded headers and messages and returns recorded status. Trailers-only responses
+// keep status in headers.
+func grpcReplayResponse(stream grpc.ServerStream, response models.ResponseDetails) error {
+	messages, err := grpcMessages([]byte(response.Body))
+	if err != nil {
+		return status.Errorf(codes.Internal, "recorded gRPC response is malformed: %s", err.Error())
+	}
+
+	statusHeaders := http.Header(response.Trailers)
+	if statusHeaders.Get("Grpc-Status") == "" {
+		statusHeaders = http.Header(response.Headers)
+	}
+	code, err := strconv.Atoi(statusHeaders.Get("Grpc-Status"))
+	if err != nil {
+		code = int(codes.OK)
+	}
+	message, err := url.PathUnescape(statusHeaders.Get("Grpc-Message"))
+	if err != nil {
+		message = statusHeaders.Get("Grpc-Message")
+	}
+
+	if header := grpcMetadata(response.Headers); len(header) > 0 || len(messages) > 0 {
+		if err := stream.SendHeader(header); err != nil {
+			return err
+		}
+	}
+	for _, m := range messages {
+		if err := stream.SendMsg(&m); err != nil {
+			return err
+		}
+	}
+	stream.SetTrailer(grpcMetadata(response.Trailers))
+
+	return status.Error(codes.Code(code), message)
+}
+
+// grpcFrames - messages with uncompressed gRPC length prefix
+func grpcFrames(messages [][]byte) []byte {
+	var body []byte
+	for _, m := range messages {
+		body = append(body, grpcMessageFrame(m)...)
+	}
+	return body
+}
+
+// grpcHeader - metadata as HTTP headers of records
+func grpcHeader(md metadata.MD) http.Header {
+	header := make(http.Header)
+	for name, values := range md {
+		if strings.HasPrefix(name, ":") {
+			continue
+		}
+		header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+	return header
+}
+
+// grpcMetadata - recorded headers that are sent as metadata, headers set by gRPC itself are left out
+func grpcMetadata(headers map[string][]string) metadata.MD {
+	md := metadata.MD{}
+	for name, values := range headers {
+		name = strings.ToLower(name)
+		if grpcReservedHeader(name) {
+			continue
+		}
+		md.Append(name, values...)
+	}
+	return md
+}
+
+// grpcForwardedMetadata - client metadata sent to upstream
+func grpcForwardedMetadata(md metadata.MD) metadata.MD {
+	forwarded := metadata.MD{}
+	for name, values := range md {
+		if grpcReservedHeader(name) || name == "user-agent" {
+			continue
+		}
+		forwarded.Append(name, values...)
+	}
+	return forwarded
+}
+
+func grpcReservedHeader(name string) bool {
+	switch name {
+	case "content-type", "content-length", "te", "trailer":
+		return true
+	}
+	return strings.HasPrefix(name, ":") || strings.HasPrefix(name, "grpc-")
+}
+
+// loadGRPCMethods - returns paths ('/<package>.<Service>/<Method>') of methods declared in descriptor sets
+// ('.pb', '.protoset' or '.desc' files) in given directory
+func loadGRPCMethods(dir string) (map[string]bool, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proto descriptor directory: %s", err.Error())
+	}
+
+	methods := make(map[string]bool)
+	for _, f := range files {
+		switch filepath.Ext(f.Name()) {
+		case ".pb", ".protoset", ".desc":
+		default:
+			continue
+		}
+		bts, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if err := descriptorSetMethods(bts, methods); err != nil {
+			return nil, fmt.Errorf("failed to parse proto descriptor '%s': %s", f.Name(), err.Error())
+		}
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no gRPC methods found in proto descriptors in '%s'", dir)
+	}
+	return methods, nil
+}
+
+// descriptorSetMethods - adds methods of FileDescriptorSet to given set. Only the fields needed for method paths are
+// decoded: FileDescriptorSet.file (1), FileDescriptorProto.package (2) and .service (6),
+// ServiceDescriptorProto.name (1) and .method (2), MethodDescriptorProto.name (1).
+func descriptorSetMethods(set []byte, methods map[string]bool) error {
+	return protoFields(set, func(field int, file []byte) error {
+		if field != 1 {
+			return nil
+		}
+
+		var pkg string
+		var services [][]byte
+		err := protoFields(file, func(field int, value []byte) error {
+			switch field {
+			case 2:
+				pkg = string(value)
+			case 6:
+				services = append(services, value)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, service := range services {
+			var name string
+			var names []string
+			err := protoFields(service, func(field int, value []byte) error {
+				switch field {
+				case 1:
+					name = string(value)
+				case 2:
+					return protoFields(value, func(field int, value []byte) error {
+						if field == 1 {
+							names = append(names, string(value))
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			qualified := name
+			if pkg != "" {
+				qualified = pkg + "." + name
+			}
+			for _, method := range names {
+				methods["/"+qualified+"/"+method] = true
+			}
+		}
+		return nil
+	})
+}
+
+// protoFields - calls fn with every length delimited field of protobuf message, other fields are skipped
+func protoFields(message []byte, fn func(field int, value []byte) error) error {
+	for len(message) > 0 {
+		tag, n := binary.Uvarint(message)
+		if n <= 0 {
+			return errors.New("malformed field tag")
+		}
+		message = message[n:]
+
+		field, wireType := int(tag>>3), tag&7
+		switch wireType {
+		case 0:
+			if _, n = binary.Uvarint(message); n <= 0 {
+				return errors.New("malformed varint")
+			}
+			message = message[n:]
+		case 1:
+			if len(message) < 8 {
+				return errors.New("truncated fixed64")
+			}
+			message = message[8:]
+		case 2:
+			length, n := binary.Uvarint(message)
+			if n <= 0 || uint64(len(message)-n) < length {
+				return errors.New("truncated length delimited field")
+			}
+			value := message[n : n+int(length)]
+			message = message[n+int(length):]
+			if err := fn(field, value); err != nil {
+				return err
+			}
+		case 5:
+			if len(message) < 4 {
+				return errors.New("truncated fixed32")
+			}
+			message = message[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d", wireType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCErrorRule - in simulate mode gRPC calls which method ('<package>.<Service>/<Method>') matches given regexp
+// pattern get trailers-only response with given status code and message instead of recorded response
+type GRPCErrorRule struct {
+	Pattern string `json:"pattern"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+
+	// compiled Pattern, set by SetGRPCErrorSimulations
+	re *regexp.Regexp
+}
+
+// Validate - checks whether rule can be used, status codes are 1 (CANCELLED) to 16 (UNAUTHENTICATED)
+func (r GRPCErrorRule) Validate() error {
+	if _, err := regexp.Compile(r.Pattern); err != nil {
+		return fmt.Errorf("invalid pattern '%s': %s", r.Pattern, err.Error())
+	}
+	if r.Code < 1 || r.Code > 16 {
+		return fmt.Errorf("invalid gRPC error status code %d, expected 1 to 16", r.Code)
+	}
+	return nil
+}
+
+// LoadGRPCErrorSimulations - reads gRPC error simulation rules from JSON file, i.e.:
+//
+//	[{"pattern": "^payments.Gateway/Charge$", "code": 14, "message": "gateway is down"}]
+func (c *Configuration) LoadGRPCErrorSimulations(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var rules []GRPCErrorRule
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return fmt.Errorf("failed to parse gRPC error simulations file: %s", err.Error())
+	}
+
+	return c.SetGRPCErrorSimulations(rules)
+}
+
+// SetGRPCErrorSimulations - validates rules and compiles their patterns, calls are matched against compiled patterns
+func (c *Configuration) SetGRPCErrorSimulations(rules []GRPCErrorRule) error {
+	compiled := make([]GRPCErrorRule, len(rules))
+	for i, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		rule.re = regexp.MustCompile(rule.Pattern)
+		compiled[i] = rule
+	}
+
+	c.GRPCErrorSimulations = compiled
+
+	return nil
+}
+
+// simulatedGRPCError - status of the first rule matching called method, nil when no rule applies
+func (h *grpcHandler) simulatedGRPCError(method string) error {
+	method = strings.TrimPrefix(method, "/")
+
+	for _, rule := range h.hf.Cfg.GRPCErrorSimulations {
+		if rule.re == nil || !rule.re.MatchString(method) {
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"method":  "/" + method,
+			"pattern": rule.Pattern,
+			"code":    rule.Code,
+		}).Info("simulating gRPC error")
+		return status.Error(codes.Code(rule.Code), rule.Message)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+	"google.golang.org/grpc/codes"
+)
+
+func TestGRPCErrorSimulated(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.SetMode(SimulateMode)
+	err := dbClient.Cfg.SetGRPCErrorSimulations([]GRPCErrorRule{
+		{Pattern: "^test.Greeter/SayHello$", Code: 14, Message: "greeter is down"},
+		{Pattern: "Greeter/", Code: 7, Message: "denied"},
+	})
+	testutil.Expect(t, err, nil)
+
+	port := startGRPCTestProxy(t, dbClient)
+
+	responses, _, st := grpcTestCall(t, port, "/test.Greeter/SayHello", "john")
+	testutil.Expect(t, st.Code(), codes.Unavailable)
+	testutil.Expect(t, st.Message(), "greeter is down")
+	testutil.Expect(t, len(responses), 0)
+
+	_, _, st = grpcTestCall(t, port, "/test.Greeter/SayGoodbye", "john")
+	testutil.Expect(t, st.Code(), codes.PermissionDenied)
+
+	// other methods are simulated from records
+	_, _, st = grpcTestCall(t, port, "/test.Weather/Forecast", "john")
+	testutil.Expect(t, st.Code(), codes.NotFound)
+
+	// errors are only simulated in simulate mode
+	dbClient.Cfg.SetMode(CaptureMode)
+	_, _, st = grpcTestCall(t, port, "/test.Greeter/SayHello", "john")
+	testutil.Expect(t, st.Code(), codes.Unavailable)
+	testutil.Expect(t, st.Message(), "gRPC upstream is not configured")
+}
+
+func TestGRPCErrorSimulatedOverHTTP2(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.SetMode(SimulateMode)
+	testutil.Expect(t, dbClient.Cfg.SetGRPCErrorSimulations([]GRPCErrorRule{{Pattern: "Greeter", Code: 16, Message: "no token"}}), nil)
+
+	port := startGRPCTestProxy(t, dbClient)
+
+	resp := grpcCall(t, port, "/test.Greeter/SayHello", "john")
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	testutil.Expect(t, resp.ProtoMajor, 2)
+	testutil.Expect(t, len(body), 0)
+	testutil.Expect(t, resp.Header.Get("Grpc-Status"), "16")
+	testutil.Expect(t, resp.Header.Get("Grpc-Message"), "no token")
+	testutil.Expect(t, len(resp.Trailer), 0)
+}
+
+func TestGRPCErrorRuleValidate(t *testing.T) {
+	testutil.Expect(t, GRPCErrorRule{Pattern: "Greeter/.*", Code: 14}.Validate(), nil)
+
+	invalid := []GRPCErrorRule{
+		{Pattern: "Greeter/(", Code: 14},
+		{Pattern: "Greeter", Code: 0},
+		{Pattern: "Greeter", Code: 17},
+	}
+	for _, rule := range invalid {
+		testutil.Refute(t, rule.Validate(), nil)
+	}
+}
+
+func TestLoadGRPCErrorSimulations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hoverfly_grpc_errors")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "errors.json")
+	testutil.Expect(t, ioutil.WriteFile(path, []byte(`[{"pattern": "Greeter/SayHello", "code": 14, "message": "down"}]`), 0644), nil)
+
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.LoadGRPCErrorSimulations(path), nil)
+	testutil.Expect(t, len(cfg.GRPCErrorSimulations), 1)
+	rule := cfg.GRPCErrorSimulations[0]
+	testutil.Expect(t, rule.Pattern, "Greeter/SayHello")
+	testutil.Expect(t, rule.Code, 14)
+	testutil.Expect(t, rule.Message, "down")
+
+	testutil.Expect(t, ioutil.WriteFile(path, []byte(`[{"pattern": "Greeter", "code": 99}]`), 0644), nil)
+	testutil.Refute(t, cfg.LoadGRPCErrorSimulations(path), nil)
+}
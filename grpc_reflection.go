@@ -0,0 +1,295 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// grpcReflectionServices - server reflection services, v1 is tried before v1alpha
+var grpcReflectionServices = []string{
+	"grpc.reflection.v1.ServerReflection",
+	"grpc.reflection.v1alpha.ServerReflection",
+}
+
+// grpcCallResult - messages, headers and trailers of completed gRPC call
+type grpcCallResult struct {
+	status   int
+	body     []byte
+	header   http.Header
+	trailer  http.Header
+	grpcCode string
+	message  string
+}
+
+// ImportFromGRPCReflection - lists methods of gRPC server ('host:port', or 'https://host:port' for TLS) through its
+// reflection API, calls each of them with zero value request and stores the responses as records of the gRPC listener
+func (d *Hoverfly) ImportFromGRPCReflection(target string) (int, error) {
+	return d.ImportFromGRPCReflectionWithFixtures(target, nil)
+}
+
+// ImportFromGRPCReflectionWithFixtures - same as ImportFromGRPCReflection, methods that have fixture (protobuf encoded
+// request message by method path, i.e. '/helloworld.Greeter/SayHello') are called with it instead of zero value
+func (d *Hoverfly) ImportFromGRPCReflectionWithFixtures(target string, fixtures map[string][]byte) (int, error) {
+	upstream, client, err := d.grpcClient(target)
+	if err != nil {
+		return 0, err
+	}
+
+	methods, err := grpcReflectMethods(client, upstream)
+	if err != nil {
+		return 0, err
+	}
+
+	imported := 0
+	for _, method := range methods {
+		body := grpcMessageFrame(fixtures[method])
+		result, err := grpcInvoke(client, upstream, method, body)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":  err.Error(),
+				"method": method,
+			}).Warn("gRPC method call failed, method is not imported")
+			continue
+		}
+
+		header := result.header
+		header.Del("Content-Length")
+		header.Del("Trailer")
+		response := models.ResponseDetails{
+			Status:  result.status,
+			Body:    string(result.body),
+			Headers: header,
+		}
+		if len(result.trailer) > 0 {
+			response.Trailers = result.trailer
+		}
+
+		request := grpcRequestDetails(method, http.Header{"Content-Type": {"application/grpc"}, "Te": {"trailers"}}, body)
+		d.storePayload(d.Cfg, request.Hash(), models.Payload{Request: request, Response: response})
+		imported++
+
+		log.WithFields(log.Fields{
+			"method":     method,
+			"grpcStatus": result.grpcCode,
+		}).Debug("gRPC method imported")
+	}
+
+	log.WithFields(log.Fields{
+		"target":   target,
+		"methods":  len(methods),
+		"imported": imported,
+	}).Info("gRPC methods imported through server reflection")
+
+	return imported, nil
+}
+
+// grpcReflectMethods - returns sorted paths of methods of all services server lists, reflection service excluded
+func grpcReflectMethods(client *http.Client, upstream *url.URL) ([]string, error) {
+	var lastErr error
+	for _, service := range grpcReflectionServices {
+		method := "/" + service + "/ServerReflectionInfo"
+
+		responses, err := grpcReflect(client, upstream, method, protoString(7, "*"))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var services []string
+		for _, response := range responses {
+			err := protoFields(response, func(field int, value []byte) error {
+				if field != 6 {
+					return nil
+				}
+				// ListServiceResponse.service (1), ServiceResponse.name (1)
+				return protoFields(value, func(field int, value []byte) error {
+					if field != 1 {
+						return nil
+					}
+					return protoFields(value, func(field int, value []byte) error {
+						if field == 1 && !strings.HasPrefix(string(value), "grpc.reflection.") {
+							services = append(services, string(value))
+						}
+						return nil
+					})
+				})
+			})
+			if err != nil {
+				return nil, fmt.Errorf("malformed gRPC reflection response: %s", err.Error())
+			}
+		}
+		if len(services) == 0 {
+			return nil, errors.New("gRPC server reflection lists no services")
+		}
+
+		// every request is a message of the stream
+		var stream bytes.Buffer
+		for _, name := range services {
+			stream.Write(grpcMessageFrame(protoString(4, name)))
+		}
+		responses, err = grpcReflectStream(client, upstream, method, stream.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		found := make(map[string]bool)
+		for _, response := range responses {
+			err := protoFields(response, func(field int, value []byte) error {
+				switch field {
+				case 4:
+					// FileDescriptorResponse has the layout of FileDescriptorSet
+					return descriptorSetMethods(value, found)
+				case 7:
+					return fmt.Errorf("gRPC reflection error: %s", grpcReflectionError(value))
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		listed := make(map[string]bool)
+		for _, name := range services {
+			listed[name] = true
+		}
+		var methods []string
+		for path := range found {
+			if listed[grpcMethodService(path)] {
+				methods = append(methods, path)
+			}
+		}
+		sort.Strings(methods)
+		return methods, nil
+	}
+	return nil, fmt.Errorf("gRPC server reflection failed: %s", lastErr.Error())
+}
+
+// grpcReflect - sends single reflection request
+func grpcReflect(client *http.Client, upstream *url.URL, method string, request []byte) ([][]byte, error) {
+	return grpcReflectStream(client, upstream, method, grpcMessageFrame(request))
+}
+
+// grpcReflectStream - sends reflection requests as one stream and returns response messages
+func grpcReflectStream(client *http.Client, upstream *url.URL, method string, stream []byte) ([][]byte, error) {
+	result, err := grpcInvoke(client, upstream, method, stream)
+	if err != nil {
+		return nil, err
+	}
+	if result.grpcCode != "0" {
+		return nil, fmt.Errorf("%s returned gRPC status %s: %s", method, result.grpcCode, result.message)
+	}
+	return grpcMessages(result.body)
+}
+
+// grpcInvoke - calls method with given length prefixed messages and reads the whole response
+func grpcInvoke(client *http.Client, upstream *url.URL, method string, body []byte) (*grpcCallResult, error) {
+	u := *upstream
+	u.Path = method
+
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// trailers, including grpc-status, are available once the whole body was read
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned HTTP status %d", method, resp.StatusCode)
+	}
+
+	result := &grpcCallResult{
+		status:  resp.StatusCode,
+		body:    respBody,
+		header:  cloneHeader(resp.Header),
+		trailer: cloneHeader(resp.Trailer),
+	}
+	// trailers-only responses carry status in headers
+	result.grpcCode, result.message = resp.Trailer.Get("Grpc-Status"), resp.Trailer.Get("Grpc-Message")
+	if result.grpcCode == "" {
+		result.grpcCode, result.message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	if message, err := url.PathUnescape(result.message); err == nil {
+		result.message = message
+	}
+	return result, nil
+}
+
+// grpcMessageFrame - uncompressed length prefixed gRPC message
+func grpcMessageFrame(message []byte) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+// grpcMessages - splits response body into messages, compressed messages are not supported
+func grpcMessages(body []byte) ([][]byte, error) {
+	var messages [][]byte
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return nil, errors.New("truncated gRPC message prefix")
+		}
+		if body[0] != 0 {
+			return nil, errors.New("compressed gRPC messages are not supported")
+		}
+		length := binary.BigEndian.Uint32(body[1:5])
+		if uint64(len(body)-5) < uint64(length) {
+			return nil, errors.New("truncated gRPC message")
+		}
+		messages = append(messages, body[5:5+length])
+		body = body[5+length:]
+	}
+	return messages, nil
+}
+
+// protoString - length delimited protobuf field
+func protoString(field int, value string) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(field)<<3|2)
+	message := append([]byte(nil), buf[:n]...)
+	n = binary.PutUvarint(buf, uint64(len(value)))
+	message = append(message, buf[:n]...)
+	return append(message, value...)
+}
+
+// grpcReflectionError - ErrorResponse.error_message (2)
+func grpcReflectionError(value []byte) string {
+	message := "unknown error"
+	protoFields(value, func(field int, value []byte) error {
+		if field == 2 {
+			message = string(value)
+		}
+		return nil
+	})
+	return message
+}
+
+// grpcMethodService - service of method path '/<package>.<Service>/<Method>'
+func grpcMethodService(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
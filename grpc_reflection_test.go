@@ -0,0 +1,108 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+	"google.golang.org/grpc/codes"
+)
+
+// grpcReflectionUpstream - serves test.Greeter and v1alpha server reflection, SayBye fails with INVALID_ARGUMENT
+func grpcReflectionUpstream() *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/grpc")
+
+		switch r.URL.Path {
+		case "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo":
+			requests, _ := grpcMessages(body)
+			w.Header().Set("Trailer", "Grpc-Status")
+			for _, request := range requests {
+				protoFields(request, func(field int, value []byte) error {
+					switch field {
+					case 7:
+						services := protoField(1, protoField(1, []byte("test.Greeter")))
+						services = append(services, protoField(1, protoField(1, []byte("grpc.reflection.v1alpha.ServerReflection")))...)
+						w.Write(grpcMessageFrame(protoField(6, services)))
+					case 4:
+						w.Write(grpcMessageFrame(protoField(4, greeterDescriptorSet())))
+					}
+					return nil
+				})
+			}
+			w.Header().Set("Grpc-Status", "0")
+		case "/test.Greeter/SayHello":
+			w.Header().Set("Trailer", "Grpc-Status")
+			w.Write(grpcFrame("hello " + string(body[5:])))
+			w.Header().Set("Grpc-Status", "0")
+		case "/test.Greeter/SayBye":
+			w.Header().Set("Grpc-Status", "3")
+			w.Header().Set("Grpc-Message", "name is required")
+		default:
+			w.Header().Set("Grpc-Status", "12")
+		}
+	}))
+	server.Config.Protocols = h2cProtocols()
+	server.Start()
+	return server
+}
+
+func TestImportFromGRPCReflection(t *testing.T) {
+	upstream := grpcReflectionUpstream()
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	imported, err := dbClient.ImportFromGRPCReflectionWithFixtures(strings.TrimPrefix(upstream.URL, "http://"), map[string][]byte{
+		"/test.Greeter/SayHello": []byte("world"),
+	})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, imported, 2)
+
+	// imported calls are served by gRPC listener in simulate mode
+	dbClient.Cfg.SetMode(SimulateMode)
+	port := startGRPCTestProxy(t, dbClient)
+
+	responses, _, st := grpcTestCall(t, port, "/test.Greeter/SayHello", "world")
+	testutil.Expect(t, st.Code(), codes.OK)
+	testutil.Expect(t, len(responses), 1)
+	testutil.Expect(t, responses[0], "hello world")
+
+	_, _, st = grpcTestCall(t, port, "/test.Greeter/SayBye", "")
+	testutil.Expect(t, st.Code(), codes.InvalidArgument)
+	testutil.Expect(t, st.Message(), "name is required")
+}
+
+func TestImportFromGRPCReflectionWithoutReflection(t *testing.T) {
+	var calls int32
+	upstream := grpcUpstream(t, &calls)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	// plain upstream answers every call, reflection response isn't valid
+	_, err := dbClient.ImportFromGRPCReflection(upstream)
+	testutil.Refute(t, err, nil)
+
+	_, err = dbClient.ImportFromGRPCReflection("ftp://example.com")
+	testutil.Refute(t, err, nil)
+}
+
+func TestGRPCMessages(t *testing.T) {
+	messages, err := grpcMessages(append(grpcMessageFrame([]byte("one")), grpcMessageFrame(nil)...))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(messages), 2)
+	testutil.Expect(t, string(messages[0]), "one")
+	testutil.Expect(t, len(messages[1]), 0)
+
+	_, err = grpcMessages([]byte{0, 0, 0, 0, 9, 'x'})
+	testutil.Refute(t, err, nil)
+	_, err = grpcMessages([]byte{1, 0, 0, 0, 0})
+	testutil.Refute(t, err, nil)
+}
@@ -0,0 +1,336 @@
+package hoverfly
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcFrame - message with gRPC length prefix
+func grpcFrame(message string) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+func h2cProtocols() *http.Protocols {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	return &protocols
+}
+
+// grpcUpstream - gRPC server that replies with 'hello <request message>' to every message of any method, returns its
+// address
+func grpcUpstream(t *testing.T, calls *int32) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	testutil.Expect(t, err, nil)
+
+	server := grpc.NewServer(grpc.ForceServerCodec(grpcRawCodec{}), grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
+		atomic.AddInt32(calls, 1)
+		stream.SetTrailer(metadata.Pairs("x-served-by", "upstream"))
+		for {
+			var message []byte
+			if err := stream.RecvMsg(&message); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			reply := []byte("hello " + string(message))
+			if err := stream.SendMsg(&reply); err != nil {
+				return err
+			}
+		}
+	}))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String()
+}
+
+// startGRPCTestProxy - starts gRPC listener of given Hoverfly on a free port, returns the port
+func startGRPCTestProxy(t *testing.T, dbClient *Hoverfly) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	testutil.Expect(t, err, nil)
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	dbClient.Cfg.GRPC.Enabled = true
+	dbClient.Cfg.GRPC.Port = port
+	testutil.Expect(t, dbClient.StartGRPCProxy(), nil)
+	t.Cleanup(dbClient.StopGRPCProxy)
+	return port
+}
+
+// grpcTestStream - opens stream to method of gRPC listener on given port
+func grpcTestStream(t *testing.T, port, method string) grpc.ClientStream {
+	conn, err := grpc.NewClient("localhost:"+port, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithDefaultCallOptions(grpc.ForceCodec(grpcRawCodec{})))
+	testutil.Expect(t, err, nil)
+	t.Cleanup(func() { conn.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, method)
+	testutil.Expect(t, err, nil)
+	return stream
+}
+
+// grpcTestCall - sends messages to method of gRPC listener on given port, returns response messages, trailer and
+// status of the call
+func grpcTestCall(t *testing.T, port, method string, messages ...string) ([]string, metadata.MD, *status.Status) {
+	stream := grpcTestStream(t, port, method)
+	for _, m := range messages {
+		message := []byte(m)
+		if err := stream.SendMsg(&message); err != nil {
+			break
+		}
+	}
+	stream.CloseSend()
+
+	var responses []string
+	for {
+		var message []byte
+		if err := stream.RecvMsg(&message); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return responses, stream.Trailer(), status.Convert(err)
+		}
+		responses = append(responses, string(message))
+	}
+}
+
+// grpcCall - gRPC call sent as plain HTTP/2 request
+func grpcCall(t *testing.T, port, method, message string) *http.Response {
+	client := &http.Client{Transport: &http.Transport{Protocols: h2cProtocols()}}
+	req, err := http.NewRequest("POST", "http://localhost:"+port+method, bytes.NewReader(grpcFrame(message)))
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+
+	resp, err := client.Do(req)
+	testutil.Expect(t, err, nil)
+	return resp
+}
+
+func TestGRPCCaptureAndSimulate(t *testing.T) {
+	var calls int32
+	upstream := grpcUpstream(t, &calls)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.GRPC = GRPCMode{Upstream: upstream}
+	port := startGRPCTestProxy(t, dbClient)
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	responses, trailer, st := grpcTestCall(t, port, "/test.Greeter/SayHello", "john")
+	testutil.Expect(t, st.Code(), codes.OK)
+	testutil.Expect(t, len(responses), 1)
+	testutil.Expect(t, responses[0], "hello john")
+	testutil.Expect(t, trailer.Get("x-served-by")[0], "upstream")
+	testutil.Expect(t, atomic.LoadInt32(&calls), int32(1))
+
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+
+	// upstream is not needed any more
+	dbClient.StopGRPCProxy()
+	dbClient.Cfg.GRPC.Upstream = ""
+	port = startGRPCTestProxy(t, dbClient)
+	dbClient.Cfg.SetMode(SimulateMode)
+
+	responses, trailer, st = grpcTestCall(t, port, "/test.Greeter/SayHello", "john")
+	testutil.Expect(t, st.Code(), codes.OK)
+	testutil.Expect(t, len(responses), 1)
+	testutil.Expect(t, responses[0], "hello john")
+	testutil.Expect(t, trailer.Get("x-served-by")[0], "upstream")
+
+	_, _, st = grpcTestCall(t, port, "/test.Greeter/SayHello", "jane")
+	testutil.Expect(t, st.Code(), codes.NotFound)
+}
+
+func TestGRPCCaptureForwardsStreamMessageByMessage(t *testing.T) {
+	var calls int32
+	upstream := grpcUpstream(t, &calls)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.GRPC = GRPCMode{Upstream: upstream}
+	port := startGRPCTestProxy(t, dbClient)
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	// every response arrives before the next request is sent
+	stream := grpcTestStream(t, port, "/test.Chat/Talk")
+	for _, name := range []string{"john", "jane"} {
+		message := []byte(name)
+		testutil.Expect(t, stream.SendMsg(&message), nil)
+
+		var response []byte
+		testutil.Expect(t, stream.RecvMsg(&response), nil)
+		testutil.Expect(t, string(response), "hello "+name)
+	}
+	stream.CloseSend()
+	var response []byte
+	testutil.Expect(t, stream.RecvMsg(&response), io.EOF)
+
+	// the whole stream is one record
+	dbClient.Cfg.SetMode(SimulateMode)
+	responses, _, st := grpcTestCall(t, port, "/test.Chat/Talk", "john", "jane")
+	testutil.Expect(t, st.Code(), codes.OK)
+	testutil.Expect(t, len(responses), 2)
+	testutil.Expect(t, responses[1], "hello jane")
+}
+
+func TestGRPCSpyForwardsUnrecordedCalls(t *testing.T) {
+	var calls int32
+	upstream := grpcUpstream(t, &calls)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.SetMode(SpyMode)
+
+	dbClient.Cfg.GRPC = GRPCMode{Upstream: upstream}
+	port := startGRPCTestProxy(t, dbClient)
+
+	responses, _, st := grpcTestCall(t, port, "/test.Greeter/SayHello", "john", "jane")
+	testutil.Expect(t, st.Code(), codes.OK)
+	testutil.Expect(t, len(responses), 2)
+	testutil.Expect(t, responses[0], "hello john")
+	testutil.Expect(t, atomic.LoadInt32(&calls), int32(1))
+}
+
+func TestGRPCRejectsNonGRPCRequests(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	port := startGRPCTestProxy(t, dbClient)
+
+	_, err := http.Get("http://localhost:" + port + "/test.Greeter/SayHello")
+	testutil.Refute(t, err, nil)
+}
+
+func TestGRPCWithoutUpstream(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	port := startGRPCTestProxy(t, dbClient)
+
+	_, _, st := grpcTestCall(t, port, "/test.Greeter/SayHello", "john")
+	testutil.Expect(t, st.Code(), codes.Unavailable)
+}
+
+func TestGRPCUpstreamDown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	testutil.Expect(t, err, nil)
+	upstream := listener.Addr().String()
+	listener.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	dbClient.Cfg.GRPC = GRPCMode{Upstream: upstream}
+	port := startGRPCTestProxy(t, dbClient)
+
+	_, _, st := grpcTestCall(t, port, "/test.Greeter/SayHello", "john")
+	testutil.Expect(t, st.Code(), codes.Unavailable)
+
+	// failed calls are not captured
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 0)
+}
+
+func TestGRPCInvalidUpstream(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.GRPC.Upstream = "ftp://localhost:50051"
+	_, err := dbClient.newGRPCHandler()
+	testutil.Refute(t, err, nil)
+}
+
+// protoField - length delimited protobuf field
+func protoField(field int, value []byte) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	out := append([]byte{}, buf[:binary.PutUvarint(buf[:], uint64(field<<3|2))]...)
+	out = append(out, buf[:binary.PutUvarint(buf[:], uint64(len(value)))]...)
+	return append(out, value...)
+}
+
+// greeterDescriptorSet - FileDescriptorSet of 'package test; service Greeter { rpc SayHello ...; rpc SayBye ...; }'
+func greeterDescriptorSet() []byte {
+	method := func(name string) []byte {
+		return protoField(2, protoField(1, []byte(name)))
+	}
+	service := append(protoField(1, []byte("Greeter")), append(method("SayHello"), method("SayBye")...)...)
+	// varint 'syntax' like field that has to be skipped
+	file := append([]byte{0x50, 0x01}, protoField(1, []byte("greeter.proto"))...)
+	file = append(file, protoField(2, []byte("test"))...)
+	file = append(file, protoField(6, service)...)
+	return protoField(1, file)
+}
+
+func TestLoadGRPCMethods(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proto-descriptors")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	testutil.Expect(t, ioutil.WriteFile(filepath.Join(dir, "greeter.pb"), greeterDescriptorSet(), 0644), nil)
+	testutil.Expect(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("not a descriptor"), 0644), nil)
+
+	methods, err := loadGRPCMethods(dir)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(methods), 2)
+	testutil.Expect(t, methods["/test.Greeter/SayHello"], true)
+	testutil.Expect(t, methods["/test.Greeter/SayBye"], true)
+}
+
+func TestLoadGRPCMethodsMalformedDescriptor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proto-descriptors")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	testutil.Expect(t, ioutil.WriteFile(filepath.Join(dir, "broken.pb"), []byte{0x0a, 0x10, 0x01}, 0644), nil)
+
+	_, err = loadGRPCMethods(dir)
+	testutil.Refute(t, err, nil)
+}
+
+func TestGRPCUndeclaredMethod(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proto-descriptors")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+	testutil.Expect(t, ioutil.WriteFile(filepath.Join(dir, "greeter.pb"), greeterDescriptorSet(), 0644), nil)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.GRPC.ProtoDescriptorDir = dir
+
+	port := startGRPCTestProxy(t, dbClient)
+
+	_, _, st := grpcTestCall(t, port, "/test.Greeter/Unknown", "john")
+	testutil.Expect(t, st.Code(), codes.Unimplemented)
+}
@@ -0,0 +1,312 @@
+package hoverfly
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// HARVersion - version of HTTP Archive format written by ExportHAR
+const HARVersion = "1.2"
+
+// HAR - HTTP Archive, only fields that are needed to simulate requests are read, see
+// http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog - root of HTTP Archive
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator - application that created the archive
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry - request and response pair, response is missing for requests that didn't complete
+type HAREntry struct {
+	StartedDateTime string       `json:"startedDateTime"`
+	Time            float64      `json:"time"`
+	Request         HARRequest   `json:"request"`
+	Response        *HARResponse `json:"response"`
+	Cache           struct{}     `json:"cache"`
+	Timings         HARTimings   `json:"timings"`
+}
+
+// HARRequest - request of HAR entry
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARPostData - request body
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARResponse - response of HAR entry
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARContent - response body, Encoding is "base64" for binary bodies
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARNameValue - header, cookie or query parameter
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARTimings - times spent in request phases, only required fields are written
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// ImportHAR - imports request and response pairs of HTTP Archive entries, entries without response are skipped
+func (d *Hoverfly) ImportHAR(r io.Reader) error {
+	var har HAR
+	if err := json.NewDecoder(r).Decode(&har); err != nil {
+		return fmt.Errorf("failed to parse HAR: %s", err.Error())
+	}
+
+	var payloads []models.PayloadView
+	for i, entry := range har.Log.Entries {
+		if entry.Response == nil || entry.Response.Status == 0 {
+			log.WithFields(log.Fields{
+				"entry":  i,
+				"method": entry.Request.Method,
+				"url":    entry.Request.URL,
+			}).Warn("HAR entry has no response, skipping it")
+			continue
+		}
+
+		payload, err := harEntryToPayloadView(entry)
+		if err != nil {
+			return fmt.Errorf("invalid HAR entry %d: %s", i, err.Error())
+		}
+		payloads = append(payloads, payload)
+	}
+
+	return d.ImportPayloads(payloads)
+}
+
+func harEntryToPayloadView(entry HAREntry) (models.PayloadView, error) {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return models.PayloadView{}, err
+	}
+	if u.Host == "" {
+		return models.PayloadView{}, fmt.Errorf("URL '%s' has no host", entry.Request.URL)
+	}
+
+	request := models.RequestDetailsView{
+		Method:      entry.Request.Method,
+		Scheme:      u.Scheme,
+		Destination: u.Host,
+		Path:        u.Path,
+		Query:       u.RawQuery,
+		Headers:     harHeaders(entry.Request.Headers),
+	}
+	if entry.Request.PostData != nil {
+		request.Body = entry.Request.PostData.Text
+	}
+
+	headers := harHeaders(entry.Response.Headers)
+	// HAR content is always decoded, its length differs from the original one
+	delete(headers, "Content-Encoding")
+	delete(headers, "Content-Length")
+
+	response := models.ResponseDetailsView{
+		Status:  entry.Response.Status,
+		Body:    entry.Response.Content.Text,
+		Headers: headers,
+	}
+	if entry.Response.Content.Encoding == "base64" {
+		response.EncodedBody = true
+	}
+
+	return models.PayloadView{Request: request, Response: response}, nil
+}
+
+// harHeaders - HAR headers to header map, names are canonicalized as browsers write HTTP/2 headers in lowercase
+func harHeaders(pairs []HARNameValue) map[string][]string {
+	headers := make(map[string][]string)
+	for _, h := range pairs {
+		// HTTP/2 pseudo headers, i.e. ':authority'
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		name := http.CanonicalHeaderKey(h.Name)
+		headers[name] = append(headers[name], h.Value)
+	}
+	return headers
+}
+
+// ExportHAR - writes all records as HTTP Archive entries, binary response bodies are base64 encoded
+func (d *Hoverfly) ExportHAR(w io.Writer) error {
+	keys, err := d.RequestCache.Keys()
+	if err != nil {
+		return err
+	}
+
+	har := HAR{Log: HARLog{
+		Version: HARVersion,
+		Creator: HARCreator{Name: "Hoverfly", Version: Version},
+		Entries: []HAREntry{},
+	}}
+
+	for _, key := range keys {
+		v, err := d.RequestCache.Get([]byte(key))
+		if err != nil {
+			// removed since keys were listed
+			continue
+		}
+
+		payload, err := models.NewPayloadFromBytes(v)
+		if err != nil {
+			return fmt.Errorf("failed to decode record '%s': %s", key, err.Error())
+		}
+		har.Log.Entries = append(har.Log.Entries, payloadToHAREntry(payload))
+	}
+
+	return json.NewEncoder(w).Encode(har)
+}
+
+func payloadToHAREntry(payload *models.Payload) HAREntry {
+	started := payload.CreatedAt
+	if started.IsZero() {
+		started = time.Now()
+	}
+
+	scheme := payload.Request.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	u := url.URL{Scheme: scheme, Host: payload.Request.Destination, Path: payload.Request.Path, RawQuery: payload.Request.Query}
+
+	request := HARRequest{
+		Method:      payload.Request.Method,
+		URL:         u.String(),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []HARNameValue{},
+		Headers:     harNameValues(payload.Request.Headers),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    len(payload.Request.Body),
+	}
+	query, _ := url.ParseQuery(payload.Request.Query)
+	request.QueryString = harNameValues(query)
+	if payload.Request.Body != "" {
+		request.PostData = &HARPostData{
+			MimeType: http.Header(payload.Request.Headers).Get("Content-Type"),
+			Text:     payload.Request.Body,
+		}
+	}
+
+	headers := http.Header{}
+	for k, values := range payload.Response.Headers {
+		headers[k] = values
+	}
+	body := []byte(payload.Response.Body)
+	if strings.EqualFold(headers.Get("Content-Encoding"), "gzip") {
+		if decoded, err := gunzip(body); err == nil {
+			body = decoded
+			headers.Del("Content-Encoding")
+			headers.Del("Content-Length")
+		}
+	}
+
+	content := HARContent{Size: len(body), MimeType: headers.Get("Content-Type")}
+	if utf8.Valid(body) {
+		content.Text = string(body)
+	} else {
+		content.Text = base64.StdEncoding.EncodeToString(body)
+		content.Encoding = "base64"
+	}
+
+	response := &HARResponse{
+		Status:      payload.Response.Status,
+		StatusText:  http.StatusText(payload.Response.Status),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []HARNameValue{},
+		Headers:     harNameValues(headers),
+		Content:     content,
+		RedirectURL: headers.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(payload.Response.Body),
+	}
+
+	return HAREntry{
+		StartedDateTime: started.UTC().Format("2006-01-02T15:04:05.000Z"),
+		Request:         request,
+		Response:        response,
+	}
+}
+
+// harNameValues - header map to HAR name/value pairs sorted by name so that exports are stable
+func harNameValues(values map[string][]string) []HARNameValue {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := []HARNameValue{}
+	for _, name := range names {
+		for _, v := range values[name] {
+			pairs = append(pairs, HARNameValue{Name: name, Value: v})
+		}
+	}
+	return pairs
+}
+
+func gunzip(body []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return ioutil.ReadAll(zr)
+}
@@ -0,0 +1,229 @@
+package hoverfly
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// browserHAR - trimmed down HAR as saved by browser DevTools, the last request didn't complete
+const browserHAR = `{
+  "log": {
+    "version": "1.2",
+    "creator": {"name": "WebInspector", "version": "537.36"},
+    "entries": [
+      {
+        "startedDateTime": "2016-06-01T10:00:00.000Z",
+        "time": 12.5,
+        "request": {
+          "method": "GET",
+          "url": "https://api.example.com/users?id=1",
+          "httpVersion": "HTTP/2.0",
+          "headers": [{"name": ":authority", "value": "api.example.com"}, {"name": "accept", "value": "application/json"}],
+          "queryString": [{"name": "id", "value": "1"}],
+          "cookies": [],
+          "headersSize": -1,
+          "bodySize": 0
+        },
+        "response": {
+          "status": 200,
+          "statusText": "OK",
+          "httpVersion": "HTTP/2.0",
+          "headers": [{"name": "content-type", "value": "application/json"}, {"name": "content-encoding", "value": "gzip"}],
+          "cookies": [],
+          "content": {"size": 13, "mimeType": "application/json", "text": "{\"name\":\"jo\"}"},
+          "redirectURL": "",
+          "headersSize": -1,
+          "bodySize": 40
+        },
+        "cache": {},
+        "timings": {"send": 1, "wait": 10, "receive": 1.5}
+      },
+      {
+        "startedDateTime": "2016-06-01T10:00:01.000Z",
+        "time": 5,
+        "request": {
+          "method": "GET",
+          "url": "https://api.example.com/logo.png",
+          "httpVersion": "HTTP/1.1",
+          "headers": [],
+          "queryString": [],
+          "cookies": [],
+          "headersSize": -1,
+          "bodySize": 0
+        },
+        "response": {
+          "status": 200,
+          "statusText": "OK",
+          "httpVersion": "HTTP/1.1",
+          "headers": [{"name": "Content-Type", "value": "image/png"}],
+          "cookies": [],
+          "content": {"size": 4, "mimeType": "image/png", "text": "iVBORw==", "encoding": "base64"},
+          "redirectURL": "",
+          "headersSize": -1,
+          "bodySize": 4
+        },
+        "cache": {},
+        "timings": {"send": 1, "wait": 3, "receive": 1}
+      },
+      {
+        "startedDateTime": "2016-06-01T10:00:02.000Z",
+        "time": 0,
+        "request": {
+          "method": "POST",
+          "url": "https://api.example.com/events",
+          "httpVersion": "HTTP/1.1",
+          "headers": [],
+          "queryString": [],
+          "cookies": [],
+          "postData": {"mimeType": "application/json", "text": "{}"},
+          "headersSize": -1,
+          "bodySize": 2
+        },
+        "cache": {},
+        "timings": {"send": 0, "wait": 0, "receive": 0}
+      }
+    ]
+  }
+}`
+
+func TestImportHAR(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	testutil.Expect(t, dbClient.ImportHAR(bytes.NewBufferString(browserHAR)), nil)
+
+	// entry without response is skipped
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 2)
+
+	dbClient.Cfg.SetMode(SimulateMode)
+
+	req, err := http.NewRequest("GET", "https://api.example.com/users?id=1", nil)
+	testutil.Expect(t, err, nil)
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, resp.Header.Get("Content-Type"), "application/json")
+	// HAR content is decoded
+	testutil.Expect(t, resp.Header.Get("Content-Encoding"), "")
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), `{"name":"jo"}`)
+
+	req, err = http.NewRequest("GET", "https://api.example.com/logo.png", nil)
+	testutil.Expect(t, err, nil)
+	_, resp = dbClient.processRequest(req)
+	body, err = ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "\x89PNG")
+}
+
+func TestImportHARInvalid(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	testutil.Refute(t, dbClient.ImportHAR(bytes.NewBufferString("not json")), nil)
+	testutil.Refute(t, dbClient.ImportHAR(bytes.NewBufferString(`{"log": {"entries": [{"request": {"method": "GET", "url": "/relative"}, "response": {"status": 200}}]}}`)), nil)
+	// nothing to import
+	testutil.Refute(t, dbClient.ImportHAR(bytes.NewBufferString(`{"log": {"entries": []}}`)), nil)
+}
+
+func TestExportHAR(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Write([]byte("compressed body"))
+	zw.Close()
+
+	err := dbClient.ImportPayloads([]models.PayloadView{
+		{
+			Request:  models.RequestDetailsView{Method: "GET", Scheme: "https", Destination: "api.example.com", Path: "/text", Query: "a=1&b=2"},
+			Response: models.ResponseDetailsView{Status: 200, Body: "plain", Headers: map[string][]string{"Content-Type": {"text/plain"}}},
+		},
+		{
+			Request:  models.RequestDetailsView{Method: "GET", Destination: "api.example.com", Path: "/gzip"},
+			Response: models.ResponseDetailsView{Status: 200, Body: gzipped.String(), Headers: map[string][]string{"Content-Encoding": {"gzip"}}},
+		},
+		{
+			Request:  models.RequestDetailsView{Method: "GET", Destination: "api.example.com", Path: "/binary"},
+			Response: models.ResponseDetailsView{Status: 200, Body: "\xff\xfe\x00"},
+		},
+	})
+	testutil.Expect(t, err, nil)
+
+	var buf bytes.Buffer
+	testutil.Expect(t, dbClient.ExportHAR(&buf), nil)
+
+	var har HAR
+	testutil.Expect(t, json.Unmarshal(buf.Bytes(), &har), nil)
+	testutil.Expect(t, har.Log.Version, HARVersion)
+	testutil.Expect(t, len(har.Log.Entries), 3)
+
+	entries := make(map[string]HAREntry)
+	for _, e := range har.Log.Entries {
+		entries[e.Request.URL] = e
+	}
+
+	text := entries["https://api.example.com/text?a=1&b=2"]
+	testutil.Expect(t, text.Response.Content.Text, "plain")
+	testutil.Expect(t, text.Response.Content.Encoding, "")
+	testutil.Expect(t, len(text.Request.QueryString), 2)
+
+	compressed := entries["http://api.example.com/gzip"]
+	testutil.Expect(t, compressed.Response.Content.Text, "compressed body")
+	for _, h := range compressed.Response.Headers {
+		testutil.Refute(t, h.Name, "Content-Encoding")
+	}
+
+	binary := entries["http://api.example.com/binary"]
+	testutil.Expect(t, binary.Response.Content.Encoding, "base64")
+	testutil.Expect(t, binary.Response.Content.Text, "//4A")
+
+	// exported archive can be imported back
+	testutil.Expect(t, dbClient.RequestCache.DeleteData(), nil)
+	testutil.Expect(t, dbClient.ImportHAR(&buf), nil)
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 3)
+}
+
+func TestHARHandlers(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("POST", "/api/simulation/har", bytes.NewBufferString(browserHAR))
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	req, err = http.NewRequest("GET", "/api/simulation/har", nil)
+	testutil.Expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var har HAR
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &har), nil)
+	testutil.Expect(t, len(har.Log.Entries), 2)
+
+	req, err = http.NewRequest("POST", "/api/simulation/har", bytes.NewBufferString("not json"))
+	testutil.Expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusBadRequest)
+}
@@ -0,0 +1,30 @@
+package hoverfly
+
+import (
+	"net/http"
+)
+
+// applyForwardRequestHeaders - removes request headers that are not in ForwardRequestHeaders, all headers are
+// forwarded when it is empty
+func (c *Configuration) applyForwardRequestHeaders(header http.Header) {
+	if len(c.ForwardRequestHeaders) == 0 {
+		return
+	}
+
+	allowed := make(map[string]bool, len(c.ForwardRequestHeaders))
+	for _, h := range c.ForwardRequestHeaders {
+		allowed[http.CanonicalHeaderKey(h)] = true
+	}
+	for k := range header {
+		if !allowed[http.CanonicalHeaderKey(k)] {
+			delete(header, k)
+		}
+	}
+}
+
+// applyStripResponseHeaders - removes StripResponseHeaders from response headers
+func (c *Configuration) applyStripResponseHeaders(header http.Header) {
+	for _, h := range c.StripResponseHeaders {
+		header.Del(h)
+	}
+}
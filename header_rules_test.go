@@ -0,0 +1,57 @@
+package hoverfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestModifyModeAppliesHeaderRules(t *testing.T) {
+	var forwarded http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header
+		w.Header().Set("Set-Cookie", "session=1")
+		w.Header().Set("X-Kept", "yes")
+		w.WriteHeader(200)
+	}))
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	// middleware changes destination, all requests are sent to upstream
+	dbClient.HTTP = &http.Client{Transport: &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return url.Parse(upstream.URL)
+		},
+	}}
+	dbClient.Cfg.SetMode(ModifyMode)
+	dbClient.Cfg.Middleware = "./examples/middleware/modify_request/modify_request.py"
+	dbClient.Cfg.ForwardRequestHeaders = []string{"authorization"}
+	dbClient.Cfg.StripResponseHeaders = []string{"set-cookie"}
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Authorization", "Bearer token")
+	req.Header.Set("X-Internal", "secret")
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, 202)
+
+	testutil.Expect(t, forwarded.Get("Authorization"), "Bearer token")
+	testutil.Expect(t, forwarded.Get("X-Internal"), "")
+
+	testutil.Expect(t, resp.Header.Get("Set-Cookie"), "")
+	testutil.Expect(t, resp.Header.Get("X-Kept"), "yes")
+}
+
+func TestApplyForwardRequestHeadersWithoutRules(t *testing.T) {
+	cfg := InitSettings()
+	header := http.Header{"X-Internal": {"secret"}}
+
+	cfg.applyForwardRequestHeaders(header)
+	testutil.Expect(t, header.Get("X-Internal"), "secret")
+}
@@ -0,0 +1,68 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// unreachableCache - request cache that can't be read, i.e. locked database file
+type unreachableCache struct {
+	cache.Cache
+}
+
+func (c unreachableCache) Count() (int, error) {
+	return 0, errors.New("timeout")
+}
+
+func TestHealthHandlerHealthy(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	m := getBoneRouter(*dbClient)
+	req, err = http.NewRequest("GET", "/api/health", nil)
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var health map[string]interface{}
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &health), nil)
+	testutil.Expect(t, len(health), 4)
+	testutil.Expect(t, health["status"], "healthy")
+	testutil.Expect(t, health["mode"], dbClient.Cfg.GetMode())
+	testutil.Expect(t, health["version"], Version)
+	testutil.Expect(t, health["cacheSize"], float64(1))
+}
+
+func TestHealthHandlerUnreachableCache(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.RequestCache = unreachableCache{}
+
+	m := getBoneRouter(*dbClient)
+	req, err := http.NewRequest("GET", "/api/health", nil)
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusServiceUnavailable)
+	testutil.Expect(t, rec.Header().Get(ErrorCodeHeader), string(ErrorCodeServiceUnavailable))
+
+	var health unhealthyResponse
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &health), nil)
+	testutil.Expect(t, health.Status, "unhealthy")
+	testutil.Expect(t, health.Reason, "request cache is unreachable: timeout")
+}
@@ -0,0 +1,81 @@
+package hoverfly
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rusenask/goproxy"
+)
+
+// hijackConnect - serves CONNECT tunnel to remote host by forwarding plain HTTP requests read from client, errors
+// only end this tunnel. Client gets 500 response when remote can't be reached or exchange fails.
+func (d *Hoverfly) hijackConnect(req *http.Request, client net.Conn, ctx *goproxy.ProxyCtx) {
+	defer func() {
+		// nothing in the tunnel is expected to panic, a bug in it mustn't stop the proxy either
+		if e := recover(); e != nil {
+			log.WithFields(log.Fields{
+				"error": e,
+				"host":  req.URL.Host,
+			}).Error("Tunnel to remote host panicked")
+			client.Write([]byte("HTTP/1.1 500 Cannot reach destination\r\n\r\n"))
+		}
+		client.Close()
+	}()
+
+	served, err := d.tunnelHTTP(req.URL.Host, client)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":  err.Error(),
+			"host":   req.URL.Host,
+			"served": served,
+		}).Warn("Tunnel to remote host failed")
+		client.Write([]byte("HTTP/1.1 500 Cannot reach destination\r\n\r\n"))
+	}
+}
+
+// tunnelHTTP - forwards requests read from client to remote host and responses back until client closes the
+// connection, returns how many requests were served before it stopped
+func (d *Hoverfly) tunnelHTTP(host string, client net.Conn) (served int, err error) {
+	remote, err := d.dialUpstream("tcp", host)
+	if err != nil {
+		return served, err
+	}
+	defer remote.Close()
+
+	clientBuf := bufio.NewReadWriter(bufio.NewReader(client), bufio.NewWriter(client))
+	remoteBuf := bufio.NewReadWriter(bufio.NewReader(remote), bufio.NewWriter(remote))
+	for {
+		var req *http.Request
+		req, err = http.ReadRequest(clientBuf.Reader)
+		if err == io.EOF {
+			return served, nil
+		}
+		if err != nil {
+			return served, err
+		}
+		if err = req.Write(remoteBuf); err != nil {
+			return served, err
+		}
+		if err = remoteBuf.Flush(); err != nil {
+			return served, err
+		}
+
+		var resp *http.Response
+		resp, err = http.ReadResponse(remoteBuf.Reader, req)
+		if err != nil {
+			return served, err
+		}
+		err = resp.Write(clientBuf.Writer)
+		resp.Body.Close()
+		if err != nil {
+			return served, err
+		}
+		if err = clientBuf.Flush(); err != nil {
+			return served, err
+		}
+		served++
+	}
+}
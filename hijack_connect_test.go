@@ -0,0 +1,90 @@
+package hoverfly
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// hijackUpstream - plain HTTP remote, '/break' closes connection without responding
+func hijackUpstream() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/break" {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte("tunnelled " + r.URL.Path))
+	}))
+}
+
+// openTunnel - starts hijacked CONNECT tunnel to given host, returns client side of it
+func openTunnel(d *Hoverfly, host string) (net.Conn, *bufio.Reader, chan struct{}) {
+	client, proxySide := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req, _ := http.NewRequest("CONNECT", "http://"+host, nil)
+		d.hijackConnect(req, proxySide, nil)
+	}()
+	return client, bufio.NewReader(client), done
+}
+
+func tunnelGet(t *testing.T, client net.Conn, reader *bufio.Reader, host, path string) *http.Response {
+	req, err := http.NewRequest("GET", "http://"+host+path, nil)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, req.Write(client), nil)
+	resp, err := http.ReadResponse(reader, req)
+	testutil.Expect(t, err, nil)
+	return resp
+}
+
+func TestHijackConnectSurvivesTunnelErrors(t *testing.T) {
+	upstream := hijackUpstream()
+	defer upstream.Close()
+	host := strings.TrimPrefix(upstream.URL, "http://")
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	client, reader, done := openTunnel(dbClient, host)
+	resp := tunnelGet(t, client, reader, host, "/first")
+	body, _ := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, string(body), "tunnelled /first")
+
+	// remote fails mid-session, tunnel ends with error response
+	resp = tunnelGet(t, client, reader, host, "/break")
+	testutil.Expect(t, resp.StatusCode, http.StatusInternalServerError)
+	<-done
+	client.Close()
+
+	// new tunnels are still served
+	client, reader, done = openTunnel(dbClient, host)
+	resp = tunnelGet(t, client, reader, host, "/second")
+	body, _ = ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, string(body), "tunnelled /second")
+	client.Close()
+	<-done
+}
+
+func TestHijackConnectUnreachableRemote(t *testing.T) {
+	upstream := hijackUpstream()
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	client, reader, done := openTunnel(dbClient, host)
+	resp, err := http.ReadResponse(reader, nil)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, http.StatusInternalServerError)
+	<-done
+	client.Close()
+}
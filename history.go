@@ -0,0 +1,90 @@
+package hoverfly
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// ExportOptions - controls what ExportSimulation writes
+type ExportOptions struct {
+	// IncludeHistory - previously captured responses are exported together with the latest one
+	IncludeHistory bool
+}
+
+// withHistory - when response history is kept, response of already captured payload becomes a historical version
+// of new payload instead of being replaced
+func (d *Hoverfly) withHistory(key string, payload models.Payload) models.Payload {
+	payload.Version = 1
+
+	bts, err := d.RequestCache.Get([]byte(key))
+	if err != nil {
+		return payload
+	}
+
+	previous, err := models.NewPayloadFromBytes(bts)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Warn("Failed to decode previous payload, response history is not kept")
+		return payload
+	}
+
+	// payloads captured before history was kept are the first version
+	version := previous.Version
+	if version == 0 {
+		version = 1
+	}
+
+	payload.History = append(previous.History, models.ResponseVersion{
+		Version:   version,
+		CreatedAt: previous.CreatedAt,
+		Response:  previous.Response,
+	})
+	payload.Version = version + 1
+	return payload
+}
+
+// GetResponseAtVersion - returns response captured for given request key at given version, the latest version is
+// the one served in simulate mode
+func (d *Hoverfly) GetResponseAtVersion(key string, version int) (*http.Response, error) {
+	bts, err := d.RequestCache.Get([]byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("request '%s' not found", key)
+	}
+
+	payload, err := models.NewPayloadFromBytes(bts)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := payload.Version
+	if latest == 0 {
+		latest = 1
+	}
+	if version == latest {
+		return NewConstructor(nil, models.Payload{Response: payload.Response}).ReconstructResponse(), nil
+	}
+
+	for _, v := range payload.History {
+		if v.Version == version {
+			return NewConstructor(nil, models.Payload{Response: v.Response}).ReconstructResponse(), nil
+		}
+	}
+	return nil, fmt.Errorf("version %d of request '%s' not found", version, key)
+}
+
+// ExportSimulation - writes all records in the same format as 'GET /api/records', only the latest response of
+// every request is written unless history is included
+func (d *Hoverfly) ExportSimulation(w io.Writer, opts ExportOptions) error {
+	convert := plainPayloadView
+	if opts.IncludeHistory {
+		convert = payloadViewWithHistory
+	}
+	_, err := d.writeRecords(w, convert)
+	return err
+}
@@ -0,0 +1,164 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// captureVersions - captures the same request once for every body, upstream returns bodies in order
+func captureVersions(t *testing.T, dbClient *Hoverfly, bodies ...string) string {
+	served := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, bodies[served])
+		served++
+	}))
+	defer upstream.Close()
+
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	var key string
+	for range bodies {
+		req, err := http.NewRequest("GET", upstream.URL+"/users", nil)
+		testutil.Expect(t, err, nil)
+		key = dbClient.getRequestFingerprint(req, []byte(""))
+
+		_, resp := dbClient.processRequest(req)
+		testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	}
+	return key
+}
+
+func responseBody(t *testing.T, resp *http.Response) string {
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	return string(body)
+}
+
+func TestResponseHistoryKeepsPreviousResponses(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.ResponseHistory = true
+	key := captureVersions(t, dbClient, "v1", "v2", "v3")
+
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+
+	for version, expected := range map[int]string{1: "v1", 2: "v2", 3: "v3"} {
+		resp, err := dbClient.GetResponseAtVersion(key, version)
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, responseBody(t, resp), expected)
+	}
+
+	_, err = dbClient.GetResponseAtVersion(key, 4)
+	testutil.Refute(t, err, nil)
+}
+
+func TestResponseHistoryDisabledReplacesResponse(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	key := captureVersions(t, dbClient, "v1", "v2")
+
+	resp, err := dbClient.GetResponseAtVersion(key, 1)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, responseBody(t, resp), "v2")
+
+	_, err = dbClient.GetResponseAtVersion(key, 2)
+	testutil.Refute(t, err, nil)
+}
+
+func TestSimulateServesLatestVersion(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.ResponseHistory = true
+	captureVersions(t, dbClient, "v1", "v2")
+
+	// upstream is gone, its host is taken from export
+	var export models.PayloadViewData
+	var buf bytes.Buffer
+	testutil.Expect(t, dbClient.ExportSimulation(&buf, ExportOptions{}), nil)
+	testutil.Expect(t, json.Unmarshal(buf.Bytes(), &export), nil)
+
+	req, err := http.NewRequest("GET", "http://"+export.Data[0].Request.Destination+"/users", nil)
+	testutil.Expect(t, err, nil)
+	dbClient.Cfg.SetMode(SimulateMode)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, responseBody(t, resp), "v2")
+}
+
+func TestExportSimulationIncludeHistory(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.ResponseHistory = true
+	captureVersions(t, dbClient, "v1", "v2")
+
+	var latest models.PayloadViewData
+	var buf bytes.Buffer
+	testutil.Expect(t, dbClient.ExportSimulation(&buf, ExportOptions{}), nil)
+	testutil.Expect(t, json.Unmarshal(buf.Bytes(), &latest), nil)
+	testutil.Expect(t, len(latest.Data), 1)
+	testutil.Expect(t, latest.Data[0].Version, 2)
+	testutil.Expect(t, len(latest.Data[0].History), 0)
+
+	buf.Reset()
+	testutil.Expect(t, dbClient.ExportSimulation(&buf, ExportOptions{IncludeHistory: true}), nil)
+	exported := buf.String()
+
+	var withHistory models.PayloadViewData
+	testutil.Expect(t, json.Unmarshal(buf.Bytes(), &withHistory), nil)
+	testutil.Expect(t, len(withHistory.Data[0].History), 1)
+	testutil.Expect(t, withHistory.Data[0].History[0].Version, 1)
+	testutil.Expect(t, withHistory.Data[0].History[0].Response.Body, "v1")
+
+	// exported history is restored on import
+	testutil.Expect(t, dbClient.RequestCache.DeleteData(), nil)
+	count, err := dbClient.ImportSimulation(bytes.NewBufferString(exported))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+
+	keys, err := dbClient.RequestCache.Keys()
+	testutil.Expect(t, err, nil)
+	resp, err := dbClient.GetResponseAtVersion(keys[0], 1)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, responseBody(t, resp), "v1")
+}
+
+func TestAllRecordsHandlerHistoryQuery(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.ResponseHistory = true
+	captureVersions(t, dbClient, "v1", "v2")
+
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "/api/records?history=true", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var data models.PayloadViewData
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &data), nil)
+	testutil.Expect(t, len(data.Data), 1)
+	testutil.Expect(t, len(data.Data[0].History), 1)
+}
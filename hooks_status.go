@@ -0,0 +1,122 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// statuses of hooks reported by GET /api/hooks
+const (
+	HookStatusNotInvoked = "notInvoked"
+	HookStatusSucceeded  = "succeeded"
+	HookStatusFailed     = "failed"
+)
+
+// HookDescriber - hooks implementing it are listed with their description
+type HookDescriber interface {
+	Description() string
+}
+
+// HookView - registered hook and result of its last invocation
+type HookView struct {
+	Type        string     `json:"type"`
+	Description string     `json:"description,omitempty"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	LastInvoked *time.Time `json:"lastInvoked,omitempty"`
+}
+
+// ActionTypeHooksView - hooks registered for action type in the order they are fired
+type ActionTypeHooksView struct {
+	ActionType ActionType `json:"actionType"`
+	Hooks      []HookView `json:"hooks"`
+}
+
+// HooksView - hooks by action type, sorted by action type
+type HooksView struct {
+	ActionTypes []ActionTypeHooksView `json:"actionTypes"`
+}
+
+// trackedHook - hook added through ActionTypeHooks.Add, remembers result of its last invocation
+type trackedHook struct {
+	Hook
+
+	mu          sync.Mutex
+	lastInvoked time.Time
+	lastError   error
+}
+
+// Fire - fires wrapped hook and records the result
+func (h *trackedHook) Fire(entry *Entry) error {
+	err := h.Hook.Fire(entry)
+
+	h.mu.Lock()
+	h.lastInvoked = time.Now()
+	h.lastError = err
+	h.mu.Unlock()
+
+	return err
+}
+
+func hookView(hook Hook) HookView {
+	view := HookView{Status: HookStatusNotInvoked}
+
+	if tracked, ok := hook.(*trackedHook); ok {
+		tracked.mu.Lock()
+		if !tracked.lastInvoked.IsZero() {
+			invoked := tracked.lastInvoked
+			view.LastInvoked = &invoked
+			view.Status = HookStatusSucceeded
+			if tracked.lastError != nil {
+				view.Status = HookStatusFailed
+				view.Error = tracked.lastError.Error()
+			}
+		}
+		tracked.mu.Unlock()
+		hook = tracked.Hook
+	}
+
+	view.Type = fmt.Sprintf("%T", hook)
+	if describer, ok := hook.(HookDescriber); ok {
+		view.Description = describer.Description()
+	}
+	return view
+}
+
+// View - returns registered hooks, hooks that weren't added through Add are reported as not invoked
+func (hooks ActionTypeHooks) View() HooksView {
+	actionTypes := make([]string, 0, len(hooks))
+	for ac := range hooks {
+		actionTypes = append(actionTypes, string(ac))
+	}
+	sort.Strings(actionTypes)
+
+	view := HooksView{ActionTypes: []ActionTypeHooksView{}}
+	for _, ac := range actionTypes {
+		acView := ActionTypeHooksView{ActionType: ActionType(ac), Hooks: []HookView{}}
+		for _, hook := range hooks[ActionType(ac)] {
+			acView.Hooks = append(acView.Hooks, hookView(hook))
+		}
+		view.ActionTypes = append(view.ActionTypes, acView)
+	}
+	return view
+}
+
+// HooksHandler - lists registered hooks by action type with result of their last invocation
+func (d *Hoverfly) HooksHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	b, err := json.Marshal(d.Hooks.View())
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,106 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+type describedHook struct {
+	err error
+}
+
+func (h *describedHook) ActionTypes() []ActionType {
+	return []ActionType{ActionTypeRequestCaptured, ActionTypeWipeDB}
+}
+
+func (h *describedHook) Fire(*Entry) error {
+	return h.err
+}
+
+func (h *describedHook) Description() string {
+	return "sends captured requests to test"
+}
+
+type plainHook struct{}
+
+func (plainHook) ActionTypes() []ActionType {
+	return []ActionType{ActionTypeRequestCaptured}
+}
+
+func (plainHook) Fire(*Entry) error {
+	return nil
+}
+
+func TestHooksViewReportsLastInvocation(t *testing.T) {
+	hooks := make(ActionTypeHooks)
+	described := &describedHook{}
+	hooks.Add(described)
+	hooks.Add(plainHook{})
+
+	view := hooks.View()
+	testutil.Expect(t, len(view.ActionTypes), 2)
+	testutil.Expect(t, view.ActionTypes[0].ActionType, ActionType(ActionTypeRequestCaptured))
+	testutil.Expect(t, view.ActionTypes[1].ActionType, ActionType(ActionTypeWipeDB))
+
+	captured := view.ActionTypes[0].Hooks
+	testutil.Expect(t, len(captured), 2)
+	testutil.Expect(t, captured[0].Type, "*hoverfly.describedHook")
+	testutil.Expect(t, captured[0].Description, "sends captured requests to test")
+	testutil.Expect(t, captured[0].Status, HookStatusNotInvoked)
+	testutil.Expect(t, captured[0].LastInvoked == nil, true)
+	testutil.Expect(t, captured[1].Type, "hoverfly.plainHook")
+	testutil.Expect(t, captured[1].Description, "")
+
+	testutil.Expect(t, hooks.Fire(ActionTypeRequestCaptured, &Entry{}), nil)
+	captured = hooks.View().ActionTypes[0].Hooks
+	testutil.Expect(t, captured[0].Status, HookStatusSucceeded)
+	testutil.Expect(t, captured[0].LastInvoked != nil, true)
+	testutil.Expect(t, captured[1].Status, HookStatusSucceeded)
+
+	described.err = errors.New("endpoint unreachable")
+	testutil.Refute(t, hooks.Fire(ActionTypeWipeDB, &Entry{}), nil)
+	view = hooks.View()
+	testutil.Expect(t, view.ActionTypes[1].Hooks[0].Status, HookStatusFailed)
+	testutil.Expect(t, view.ActionTypes[1].Hooks[0].Error, "endpoint unreachable")
+	// hook is tracked once for all its action types
+	testutil.Expect(t, view.ActionTypes[0].Hooks[0].Status, HookStatusFailed)
+	testutil.Expect(t, view.ActionTypes[0].Hooks[1].Status, HookStatusSucceeded)
+}
+
+func TestHooksHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Hooks = make(ActionTypeHooks)
+	dbClient.AddHook(&describedHook{})
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "/api/hooks", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var body HooksView
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &body), nil)
+	testutil.Expect(t, len(body.ActionTypes), 2)
+	testutil.Expect(t, body.ActionTypes[0].Hooks[0].Description, "sends captured requests to test")
+	testutil.Expect(t, body.ActionTypes[0].Hooks[0].Status, HookStatusNotInvoked)
+}
+
+func TestHooksHandlerWithoutHooks(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "/api/hooks", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+	testutil.Expect(t, rec.Body.String(), `{"actionTypes":[]}`)
+}
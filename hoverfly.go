@@ -1,12 +1,11 @@
 package hoverfly
 
 import (
-	"bufio"
-	"crypto/tls"
+	"encoding/json"
 	"fmt"
-	"net"
+	"mime"
 	"net/http"
-	"regexp"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -16,6 +15,9 @@ import (
 	"github.com/rusenask/goproxy"
 )
 
+// Version - current Hoverfly version
+const Version = "v0.7.1"
+
 // SimulateMode - default mode when Hoverfly looks for captured requests to respond
 const SimulateMode = "simulate"
 
@@ -28,71 +30,149 @@ const ModifyMode = "modify"
 // CaptureMode - requests are captured and stored in cache
 const CaptureMode = "capture"
 
-// orPanic - wrapper for logging errors
-func orPanic(err error) {
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err.Error(),
-		}).Panic("Got error.")
-	}
+// SpyMode - requests are forwarded and captured like in capture mode, upstream responses are returned unchanged
+// and middleware is not applied
+const SpyMode = "spy"
+
+// DiffMode - requests are simulated and forwarded to live backend at the same time, simulated responses are
+// returned and differences between responses are stored
+const DiffMode = "diff"
+
+// ChaosMode - requests are forwarded to original destination, some of them fail and the rest are delayed at random
+// according to ChaosProfile
+const ChaosMode = "chaos"
+
+// BypassHeader - requests carrying this header set to "true" are forwarded live, regardless of current mode
+const BypassHeader = "X-Hoverfly-Bypass"
+
+// RedactedAuthorization - placeholder stored instead of stripped authorization headers
+const RedactedAuthorization = "Basic REDACTED"
+
+// BodyMismatchHeader - set on simulated responses when request body differs from the captured one
+const BodyMismatchHeader = "X-Hoverfly-Body-Mismatch"
+
+// GetNewHoverfly returns a configured ProxyHttpServer and DBClient, error is returned when destination is not a
+// valid regular expression
+//
+// Deprecated: use NewHoverfly with WithConfiguration, WithRequestCache, WithMetadataCache and WithAuthentication.
+func GetNewHoverfly(cfg *Configuration, requestCache, metadataCache cache.Cache, authentication backends.Authentication) (*Hoverfly, error) {
+	return NewHoverfly(
+		WithConfiguration(cfg),
+		WithRequestCache(requestCache),
+		WithMetadataCache(metadataCache),
+		WithAuthentication(authentication),
+	)
 }
 
-// GetNewHoverfly returns a configured ProxyHttpServer and DBClient
-func GetNewHoverfly(cfg *Configuration, requestCache, metadataCache cache.Cache, authentication backends.Authentication) *Hoverfly {
-	h := &Hoverfly{
-		RequestCache:   requestCache,
-		MetadataCache:  metadataCache,
-		Authentication: authentication,
-		HTTP: &http.Client{Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: cfg.TLSVerification},
-		}},
-		Cfg:     cfg,
-		Counter: metrics.NewModeCounter([]string{SimulateMode, SynthesizeMode, ModifyMode, CaptureMode}),
-		Hooks:   make(ActionTypeHooks),
-	}
-	h.UpdateProxy()
-	return h
+// UpdateProxy - applies hooks, proxies of extra ports are created again as well
+func (d *Hoverfly) UpdateProxy() {
+	d.Proxy = d.newProxy("")
+
+	d.ExtraProxies = make(map[string]*goproxy.ProxyHttpServer, len(d.Cfg.ExtraProxyPorts))
+	for _, extra := range d.Cfg.ExtraProxyPorts {
+		d.ExtraProxies[extra.Port] = d.newProxy(extra.Mode)
+	}
+
+	// proxy starting message
+	log.WithFields(log.Fields{
+		"Destinations":    d.Cfg.Destinations,
+		"ProxyPort":       d.Cfg.ProxyPort,
+		"Mode":            d.Cfg.GetMode(),
+		"ExtraProxyPorts": d.Cfg.ExtraProxyPorts,
+	}).Info("Proxy prepared...")
 }
 
-// UpdateProxy - applies hooks
-func (d *Hoverfly) UpdateProxy() {
+// newProxy - creates proxy with hooks, requests are processed in given mode or in current mode when it is empty
+func (d *Hoverfly) newProxy(mode string) *goproxy.ProxyHttpServer {
+	proxyMode := func() string {
+		if mode != "" {
+			return mode
+		}
+		return d.Cfg.GetMode()
+	}
+
 	// creating proxy
 	proxy := goproxy.NewProxyHttpServer()
+	destinations := goproxy.ReqHostMatches(d.Cfg.destinationPatterns()...)
 
-	proxy.OnRequest(goproxy.ReqHostMatches(regexp.MustCompile(d.Cfg.Destination))).
-		HandleConnect(goproxy.AlwaysMitm)
+	d.applyUpstreamSOCKS5Proxy(proxy)
+	d.applyDNSFailures(proxy)
+
+	// requests that aren't processed by Hoverfly are forwarded by proxy's own transport
+	if d.upstreamTLS != nil {
+		proxy.Tr.TLSClientConfig = d.upstreamTLS
+	}
+
+	// WebSocket tunnels, handshake delay and pinning failure have to be checked before regular MITM
+	if d.Cfg.CaptureWebSocket {
+		proxy.OnRequest(destinations).HandleConnectFunc(d.webSocketConnect)
+	}
+	if d.Cfg.TLSHandshakeDelayMs > 0 {
+		proxy.OnRequest(destinations).
+			HandleConnectFunc(d.handshakeDelayConnect)
+	}
+	if d.Cfg.PinFailMode {
+		proxy.OnRequest(destinations).
+			HandleConnectFunc(d.pinFailConnect)
+	}
+
+	if d.tlsSessions != nil {
+		proxy.OnRequest(destinations).
+			HandleConnectFunc(d.mitmConnect)
+	} else {
+		proxy.OnRequest(destinations).
+			HandleConnect(goproxy.AlwaysMitm)
+	}
 
 	// enable curl -p for all hosts on port 80
-	proxy.OnRequest(goproxy.ReqHostMatches(regexp.MustCompile(d.Cfg.Destination))).
-		HijackConnect(func(req *http.Request, client net.Conn, ctx *goproxy.ProxyCtx) {
-			defer func() {
-				if e := recover(); e != nil {
-					ctx.Logf("error connecting to remote: %v", e)
-					client.Write([]byte("HTTP/1.1 500 Cannot reach destination\r\n\r\n"))
+	proxy.OnRequest(destinations).
+		HijackConnect(d.hijackConnect)
+
+	if d.Cfg.InjectViaHeader {
+		proxy.OnRequest(destinations).DoFunc(
+			func(r *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
+				r.Header.Add("Via", d.Cfg.ViaHeader())
+				return r, nil
+			})
+	}
+
+	// preflight requests of simulated services are answered without looking for them in simulation
+	if d.Cfg.CORS.Enabled {
+		proxy.OnRequest(destinations).DoFunc(
+			func(r *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
+				if proxyMode() == SimulateMode && isCORSPreflight(r) {
+					return r, d.Cfg.CORS.corsPreflightResponse(r)
 				}
-				client.Close()
-			}()
-			clientBuf := bufio.NewReadWriter(bufio.NewReader(client), bufio.NewWriter(client))
-			remote, err := net.Dial("tcp", req.URL.Host)
-			orPanic(err)
-			remoteBuf := bufio.NewReadWriter(bufio.NewReader(remote), bufio.NewWriter(remote))
-			for {
-				req, err := http.ReadRequest(clientBuf.Reader)
-				orPanic(err)
-				orPanic(req.Write(remoteBuf))
-				orPanic(remoteBuf.Flush())
-				resp, err := http.ReadResponse(remoteBuf.Reader, req)
-
-				orPanic(err)
-				orPanic(resp.Write(clientBuf.Writer))
-				orPanic(clientBuf.Flush())
-			}
-		})
+				return r, nil
+			})
+	}
 
 	// processing connections
-	proxy.OnRequest(goproxy.ReqHostMatches(regexp.MustCompile(d.Cfg.Destination))).DoFunc(
+	proxy.OnRequest(destinations).DoFunc(
 		func(r *http.Request, ctx *goproxy.ProxyCtx) (*http.Request, *http.Response) {
-			req, resp := d.processRequest(r)
+			if mode != "" {
+				r = withFixedMode(r, mode)
+			}
+			// collecting middleware call details for the timeline, stdin/stdout only with verbose logging
+			r, exchange := withMiddlewareExchange(r)
+			exchange.skipPayloads = !d.Cfg.Verbose
+			ctx.UserData = exchange
+			requestID := d.Cfg.assignRequestID(r)
+			rule := d.Cfg.GetEndpointLogRule(r.Host, r.URL.Path)
+
+			var reqBody []byte
+			if rule != nil && rule.IncludeRequestBody && r.Body != nil {
+				reqBody, _ = extractRequestBody(r)
+			}
+
+			start := time.Now()
+			req, resp := d.process(r)
+			d.Counter.CountWithSize(proxyMode(), time.Since(start), responseSize(resp))
+			setResponseRequestID(resp, requestID)
+
+			if rule != nil {
+				logEndpoint(rule, proxyMode(), d.Cfg.OriginalClientIP(r), r, reqBody, resp)
+			}
 			return req, resp
 		})
 
@@ -104,52 +184,153 @@ func (d *Hoverfly) UpdateProxy() {
 					"path":        r.URL.Path,
 					"query":       r.URL.RawQuery,
 					"method":      r.Method,
-					"mode":        d.Cfg.GetMode(),
+					"mode":        proxyMode(),
 				}).Debug("got request..")
 				return r, nil
 			})
 	}
 
 	// intercepts response
-	proxy.OnResponse(goproxy.ReqHostMatches(regexp.MustCompile(d.Cfg.Destination))).DoFunc(
+	proxy.OnResponse(destinations).DoFunc(
 		func(resp *http.Response, ctx *goproxy.ProxyCtx) *http.Response {
-			d.Counter.Count(d.Cfg.GetMode())
+			exchange, _ := ctx.UserData.(*middlewareExchange)
+			d.recordProxyEvent(ctx.Req, resp, exchange)
+
+			if resp != nil {
+				event := ControlEvent{
+					Event:  ControlEventRequestServed,
+					Time:   time.Now(),
+					URL:    ctx.Req.URL.String(),
+					Host:   ctx.Req.Host,
+					Method: ctx.Req.Method,
+					Status: resp.StatusCode,
+					Mode:   proxyMode(),
+				}
+				if exchange != nil {
+					event.LatencyMs = float64(event.Time.Sub(exchange.received)) / float64(time.Millisecond)
+				}
+				d.events.publish(event)
+			}
+
+			if d.Cfg.InjectViaHeader && resp != nil {
+				resp.Header.Add("Via", d.Cfg.ViaHeader())
+			}
+			if d.Cfg.CORS.Enabled && resp != nil && proxyMode() == SimulateMode {
+				d.Cfg.CORS.applySimulatedCORSHeaders(resp, isCORSPreflight(ctx.Req))
+			}
+			declareTrailers(ctx.Req, resp)
 			return resp
 		})
 
 	proxy.Verbose = d.Cfg.Verbose
-	// proxy starting message
-	log.WithFields(log.Fields{
-		"Destination": d.Cfg.Destination,
-		"ProxyPort":   d.Cfg.ProxyPort,
-		"Mode":        d.Cfg.GetMode(),
-	}).Info("Proxy prepared...")
+	return proxy
+}
+
+// error response formats
+const (
+	ErrorFormatText = "text"
+	ErrorFormatJSON = "json"
+)
 
-	d.Proxy = proxy
-	return
+// errorResponse - body of JSON error responses
+type errorResponse struct {
+	Error  string `json:"error"`
+	Detail string `json:"detail"`
 }
 
-func hoverflyError(req *http.Request, err error, msg string, statusCode int) *http.Response {
-	return goproxy.NewResponse(req,
-		goproxy.ContentTypeText, statusCode,
-		fmt.Sprintf("Hoverfly Error! %s. Got error: %s \n", msg, err.Error()))
+// errorFormat - JSON when client accepts 'application/json', plain text otherwise
+func errorFormat(req *http.Request) string {
+	if req == nil {
+		return ErrorFormatText
+	}
+	for _, value := range req.Header[http.CanonicalHeaderKey("Accept")] {
+		for _, accepted := range strings.Split(value, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+			if err == nil && mediaType == "application/json" {
+				return ErrorFormatJSON
+			}
+		}
+	}
+	return ErrorFormatText
+}
+
+// hoverflyError - creates plain text or JSON error response with machine readable error code header
+func hoverflyError(req *http.Request, err error, msg string, statusCode int, code ErrorCode, format string) *http.Response {
+	var resp *http.Response
+	if format == ErrorFormatJSON {
+		body, _ := json.Marshal(errorResponse{Error: msg, Detail: err.Error()})
+		resp = goproxy.NewResponse(req, "application/json", statusCode, string(body))
+	} else {
+		resp = goproxy.NewResponse(req,
+			goproxy.ContentTypeText, statusCode,
+			fmt.Sprintf("Hoverfly Error! %s. Got error: %s \n", msg, err.Error()))
+	}
+	resp.Header.Set(ErrorCodeHeader, string(code))
+	return resp
+}
+
+// responseSize - size of response body, 0 when it isn't known up front
+func responseSize(resp *http.Response) int64 {
+	if resp == nil || resp.ContentLength < 0 {
+		return 0
+	}
+	return resp.ContentLength
 }
 
 // processRequest - processes incoming requests and based on proxy state (record/playback)
 // returns HTTP response.
-func (d *Hoverfly) processRequest(req *http.Request) (*http.Request, *http.Response) {
+func (d *Hoverfly) processRequest(req *http.Request) (_ *http.Request, resp *http.Response) {
 
-	mode := d.Cfg.GetMode()
+	// snapshot of configuration, it can be changed through admin API while request is processed
+	cfg := d.Cfg.Clone()
+	req = withConfigSnapshot(req, cfg)
+
+	mode := requestMode(req, cfg)
+	defer metrics.InFlightRequests(mode)()
+	defer func(start time.Time) {
+		metrics.ObserveRequestDuration(mode, time.Since(start))
+	}(time.Now())
+
+	body := countRequestBody(req)
+	defer func() {
+		d.Sizes.RecordRequestSize(mode, body.size())
+		d.Sizes.RecordResponseSize(mode, responseSize(resp))
+	}()
+
+	if req.Header.Get(BypassHeader) == "true" {
+		newResponse, err := d.bypassRequest(req)
+
+		if err != nil {
+			return req, hoverflyError(req, err, "Could not forward bypassed request", http.StatusServiceUnavailable, errorCodeOf(err, ErrorCodeUpstreamUnreachable), errorFormat(req))
+		}
+		log.WithFields(log.Fields{
+			"mode":        mode,
+			"path":        req.URL.Path,
+			"rawQuery":    req.URL.RawQuery,
+			"method":      req.Method,
+			"destination": req.Host,
+		}).Info("bypass header found, request forwarded live")
+
+		return req, newResponse
+
+	}
 
 	if mode == CaptureMode {
+		if err := d.runHooks(HookBeforeCapture, req, nil); err != nil {
+			return req, hookError(req, err)
+		}
+
 		newResponse, err := d.captureRequest(req)
 
 		if err != nil {
-			return req, hoverflyError(req, err, "Could not capture request", http.StatusServiceUnavailable)
+			return req, hoverflyError(req, err, "Could not capture request", upstreamErrorStatus(err), errorCodeOf(err, ErrorCodeUpstreamUnreachable), errorFormat(req))
+		}
+		if err := d.runHooks(HookAfterCapture, req, newResponse); err != nil {
+			return req, hookError(req, err)
 		}
 		log.WithFields(log.Fields{
 			"mode":        mode,
-			"middleware":  d.Cfg.Middleware,
+			"middleware":  cfg.GetMiddleware(),
 			"path":        req.URL.Path,
 			"rawQuery":    req.URL.RawQuery,
 			"method":      req.Method,
@@ -158,16 +339,38 @@ func (d *Hoverfly) processRequest(req *http.Request) (*http.Request, *http.Respo
 
 		return req, newResponse
 
+	} else if mode == SpyMode {
+		newResponse, err := d.spyRequest(req)
+
+		if err != nil {
+			return req, hoverflyError(req, err, "Could not forward request", upstreamErrorStatus(err), errorCodeOf(err, ErrorCodeUpstreamUnreachable), errorFormat(req))
+		}
+		log.WithFields(log.Fields{
+			"mode":        mode,
+			"path":        req.URL.Path,
+			"rawQuery":    req.URL.RawQuery,
+			"method":      req.Method,
+			"destination": req.Host,
+		}).Info("request forwarded and captured")
+
+		return req, newResponse
+
+	} else if mode == DiffMode {
+		return req, d.diffRequest(req)
+
+	} else if mode == ChaosMode {
+		return req, d.chaosRequest(req)
+
 	} else if mode == SynthesizeMode {
-		response, err := SynthesizeResponse(req, d.Cfg.Middleware)
+		response, err := d.synthesizeResponse(req)
 
 		if err != nil {
-			return req, hoverflyError(req, err, "Could not create synthetic response!", http.StatusServiceUnavailable)
+			return req, hoverflyError(req, err, "Could not create synthetic response!", upstreamErrorStatus(err), errorCodeOf(err, ErrorCodeMiddlewareFailed), errorFormat(req))
 		}
 
 		log.WithFields(log.Fields{
 			"mode":        mode,
-			"middleware":  d.Cfg.Middleware,
+			"middleware":  cfg.GetMiddleware(),
 			"path":        req.URL.Path,
 			"rawQuery":    req.URL.RawQuery,
 			"method":      req.Method,
@@ -178,39 +381,90 @@ func (d *Hoverfly) processRequest(req *http.Request) (*http.Request, *http.Respo
 
 	} else if mode == ModifyMode {
 
-		response, err := d.modifyRequestResponse(req, d.Cfg.Middleware)
+		response, err := d.modifyRequestResponse(req, cfg.GetMiddleware())
 
 		if err != nil {
 			log.WithFields(log.Fields{
 				"error":      err.Error(),
-				"middleware": d.Cfg.Middleware,
+				"middleware": cfg.GetMiddleware(),
 			}).Error("Got error when performing request modification")
 			return req, hoverflyError(
 				req,
 				err,
-				fmt.Sprintf("Middleware (%s) failed or something else happened!", d.Cfg.Middleware),
-				http.StatusServiceUnavailable)
+				fmt.Sprintf("Middleware (%s) failed or something else happened!", cfg.GetMiddleware()),
+				upstreamErrorStatus(err),
+				errorCodeOf(err, ErrorCodeMiddlewareFailed),
+				errorFormat(req))
 		}
+		cfg.applyStripResponseHeaders(response.Header)
+		cfg.applyCSPHeader(response)
 		// returning modified response
 		return req, response
 	}
 
-	newResponse := d.getResponse(req)
+	if cfg.SimulatedOAuth2 != nil {
+		if resp := cfg.SimulatedOAuth2.simulateOAuth2(req); resp != nil {
+			return req, resp
+		}
+	}
 
-	// introduce response delay
-	if d.Cfg.ResponseDelay > 0 {
+	if cfg.SimulatedWebAuthn != nil {
+		if resp := d.simulateWebAuthn(req); resp != nil {
+			return req, resp
+		}
+	}
+
+	if cfg.IsLiveEndpoint(req.Host, req.URL.Path) {
+		newResponse, err := d.liveRequest(req)
+
+		if err != nil {
+			return req, hoverflyError(req, err, "Could not forward request to live endpoint", http.StatusServiceUnavailable, errorCodeOf(err, ErrorCodeUpstreamUnreachable), errorFormat(req))
+		}
+		log.WithFields(log.Fields{
+			"mode":        mode,
+			"path":        req.URL.Path,
+			"rawQuery":    req.URL.RawQuery,
+			"method":      req.Method,
+			"destination": req.Host,
+			"cached":      cfg.CacheLiveResponses,
+		}).Info("live endpoint, request forwarded")
 
+		return req, newResponse
+	}
+
+	if err := d.runHooks(HookBeforeSimulate, req, nil); err != nil {
+		return req, hookError(req, err)
+	}
+
+	newResponse := d.getFlowResponse(req)
+	if newResponse == nil && mode == SimulateMode && cfg.GetRecordOnce() {
+		newResponse = d.recordOnceResponse(req)
+	} else if newResponse == nil {
+		newResponse = d.getResponse(req)
+	}
+
+	if err := d.runHooks(HookAfterSimulate, req, newResponse); err != nil {
+		return req, hookError(req, err)
+	}
+	newResponse = applyRange(req, newResponse)
+
+	d.applyGeoIPHeader(req, newResponse)
+	cfg.applyCSPHeader(newResponse)
+
+	// introduce response delay
+	if name, delay, ok := d.responseDelay(req, newResponse); ok {
 		log.WithFields(log.Fields{
 			"mode":          mode,
-			"middleware":    d.Cfg.Middleware,
-			"responseDelay": d.Cfg.ResponseDelay,
+			"middleware":    cfg.GetMiddleware(),
+			"delayProfile":  name,
+			"responseDelay": delay.String(),
 			"path":          req.URL.Path,
 			"rawQuery":      req.URL.RawQuery,
 			"method":        req.Method,
 			"destination":   req.Host,
 		}).Debug("Introducing response delay")
 
-		time.Sleep(time.Duration(d.Cfg.ResponseDelay) * time.Millisecond)
+		time.Sleep(delay)
 	}
 
 	return req, newResponse
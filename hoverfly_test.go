@@ -2,12 +2,17 @@ package hoverfly
 
 import (
 	"bytes"
+	"compress/gzip"
 	"fmt"
 	"github.com/SpectoLabs/hoverfly/authentication/backends"
 	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/models"
 	"github.com/SpectoLabs/hoverfly/testutil"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 )
@@ -23,7 +28,8 @@ func TestGetNewHoverflyCheckConfig(t *testing.T) {
 	userCache := cache.NewBoltDBCache(db, []byte("userBucket"))
 	backend := backends.NewCacheBasedAuthBackend(tokenCache, userCache)
 
-	dbClient := GetNewHoverfly(cfg, requestCache, metaCache, backend)
+	dbClient, err := GetNewHoverfly(cfg, requestCache, metaCache, backend)
+	testutil.Expect(t, err, nil)
 
 	testutil.Expect(t, dbClient.Cfg, cfg)
 
@@ -31,6 +37,40 @@ func TestGetNewHoverflyCheckConfig(t *testing.T) {
 	os.Remove("testing2.db")
 }
 
+func TestGetNewHoverflyInvalidDestination(t *testing.T) {
+	cfg := InitSettings()
+	cfg.Destinations = []string{"api[.example.com"}
+
+	backend := backends.NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache())
+
+	dbClient, err := GetNewHoverfly(cfg, cache.NewInMemoryCache(), cache.NewInMemoryCache(), backend)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, dbClient, (*Hoverfly)(nil))
+}
+
+func TestNewHoverflyDefaults(t *testing.T) {
+	dbClient, err := NewHoverfly()
+	testutil.Expect(t, err, nil)
+
+	testutil.Refute(t, dbClient.Cfg, nil)
+	testutil.Refute(t, dbClient.RequestCache, nil)
+	testutil.Refute(t, dbClient.Authentication, nil)
+	testutil.Refute(t, dbClient.HTTP, nil)
+}
+
+func TestNewHoverflyWithOptions(t *testing.T) {
+	cfg := InitSettings()
+	requestCache := cache.NewInMemoryCache()
+	client := &http.Client{}
+
+	dbClient, err := NewHoverfly(WithConfiguration(cfg), WithRequestCache(requestCache), WithHTTPClient(client))
+	testutil.Expect(t, err, nil)
+
+	testutil.Expect(t, dbClient.Cfg, cfg)
+	testutil.Expect(t, dbClient.RequestCache, requestCache)
+	testutil.Expect(t, dbClient.HTTP, client)
+}
+
 func TestGetNewHoverfly(t *testing.T) {
 	server, dbClient := testTools(201, `{'message': 'here'}`)
 	defer server.Close()
@@ -88,6 +128,50 @@ func TestProcessSimulateRequest(t *testing.T) {
 	testutil.Expect(t, newResp.StatusCode, 201)
 }
 
+func TestProcessRequestUsesConfigurationSnapshot(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	err := dbClient.ImportPayloads([]models.PayloadView{{
+		Request:  models.RequestDetailsView{Method: "GET", Destination: "snapshot.com", Path: "/page"},
+		Response: models.ResponseDetailsView{Status: 200, Body: "hello", Headers: map[string][]string{"Content-Type": {"text/html"}}},
+	}})
+	testutil.Expect(t, err, nil)
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.CSPPolicy = "default-src 'self'"
+
+	// configuration is changed while request is processed
+	changed := false
+	dbClient.RegisterHook(HookBeforeSimulate, func(req *http.Request, resp *http.Response) error {
+		if !changed {
+			changed = true
+			dbClient.Cfg.ResponsePadding = []PaddingRule{{Host: "snapshot.com", TargetBytes: 10, Fill: "."}}
+			dbClient.Cfg.CSPPolicy = "default-src 'none'"
+		}
+		return nil
+	})
+
+	get := func() (*http.Response, string) {
+		r, err := http.NewRequest("GET", "http://snapshot.com/page", nil)
+		testutil.Expect(t, err, nil)
+		_, resp := dbClient.processRequest(r)
+		body, err := ioutil.ReadAll(resp.Body)
+		testutil.Expect(t, err, nil)
+		return resp, string(body)
+	}
+
+	resp, body := get()
+	testutil.Expect(t, changed, true)
+	testutil.Expect(t, body, "hello")
+	testutil.Expect(t, resp.Header.Get(cspHeader), "default-src 'self'")
+
+	// the next request sees the change
+	resp, body = get()
+	testutil.Expect(t, body, "hello.....")
+	testutil.Expect(t, resp.Header.Get(cspHeader), "default-src 'none'")
+}
+
 func TestProcessSynthesizeRequest(t *testing.T) {
 	server, dbClient := testTools(201, `{'message': 'here'}`)
 	defer server.Close()
@@ -130,3 +214,205 @@ func TestProcessModifyRequest(t *testing.T) {
 
 	testutil.Expect(t, newResp.StatusCode, 202)
 }
+
+func TestProcessBypassedRequest(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	r, err := http.NewRequest("GET", "http://somehost.com", nil)
+	testutil.Expect(t, err, nil)
+	r.Header.Set(BypassHeader, "true")
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	newReq, newResp := dbClient.processRequest(r)
+
+	testutil.Refute(t, newReq, nil)
+	testutil.Refute(t, newResp, nil)
+	testutil.Expect(t, newResp.StatusCode, 201)
+	testutil.Expect(t, newReq.Header.Get(BypassHeader), "")
+
+	// bypassed requests should not be stored
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 0)
+}
+
+func TestViaHeaderInjected(t *testing.T) {
+	var via string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		via = r.Header.Get("Via")
+		w.WriteHeader(200)
+	}))
+	defer upstream.Close()
+
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.HTTP = http.DefaultClient
+	dbClient.Cfg.InjectViaHeader = true
+	dbClient.Cfg.Destinations = []string{"."}
+	dbClient.Cfg.SetMode(CaptureMode)
+	dbClient.UpdateProxy()
+
+	proxy := httptest.NewServer(dbClient.Proxy)
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	testutil.Expect(t, err, nil)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get(upstream.URL)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, 200)
+	testutil.Expect(t, resp.Header.Get("Via"), dbClient.Cfg.ViaHeader())
+	testutil.Expect(t, via, dbClient.Cfg.ViaHeader())
+}
+
+func gzipBody(body []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(body)
+	w.Close()
+	return buf.Bytes()
+}
+
+// BenchmarkResponsePipeline - measures capturing and simulating responses with different bodies
+func BenchmarkResponsePipeline(b *testing.B) {
+	jsonBody := []byte(`{"id": 1, "name": "hoverfly", "tags": ["proxy", "simulation"], "nested": {"enabled": true}}`)
+
+	// there is no brotli encoder in dependencies, random bytes are as incompressible as brotli output
+	// and hoverfly never decodes 'br' bodies
+	binaryBody := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(binaryBody)
+
+	cases := []struct {
+		name     string
+		body     []byte
+		encoding string
+	}{
+		{name: "PlainJSON", body: jsonBody},
+		{name: "GzipJSON", body: gzipBody(bytes.Repeat(jsonBody, 100)), encoding: "gzip"},
+		{name: "BrotliBinary", body: binaryBody, encoding: "br"},
+		{name: "LargePlainText", body: bytes.Repeat([]byte("hoverfly "), 1024*1024/9)},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tc.encoding != "" {
+				w.Header().Set("Content-Encoding", tc.encoding)
+			}
+			w.Write(tc.body)
+		}))
+
+		server, dbClient := testTools(200, `{'message': 'here'}`)
+		dbClient.HTTP = &http.Client{Transport: &http.Transport{
+			// keeping body as it is on the wire
+			DisableCompression: true,
+			Proxy: func(req *http.Request) (*url.URL, error) {
+				return url.Parse(upstream.URL)
+			},
+		}}
+
+		b.Run(tc.name+"/Capture", func(b *testing.B) {
+			dbClient.Cfg.SetMode(CaptureMode)
+			b.ReportAllocs()
+			b.SetBytes(int64(len(tc.body)))
+			for i := 0; i < b.N; i++ {
+				r, _ := http.NewRequest("GET", "http://pipeline.com/"+tc.name, nil)
+				_, resp := dbClient.processRequest(r)
+				ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+		})
+
+		b.Run(tc.name+"/Simulate", func(b *testing.B) {
+			dbClient.Cfg.SetMode(SimulateMode)
+			b.ReportAllocs()
+			b.SetBytes(int64(len(tc.body)))
+			for i := 0; i < b.N; i++ {
+				r, _ := http.NewRequest("GET", "http://pipeline.com/"+tc.name, nil)
+				_, resp := dbClient.processRequest(r)
+				ioutil.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+		})
+
+		dbClient.RequestCache.DeleteData()
+		server.Close()
+		upstream.Close()
+	}
+}
+
+func TestProcessLiveEndpointRequest(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	testutil.Expect(t, dbClient.Cfg.SetLiveEndpoints([]string{"live.com/webhook"}), nil)
+
+	// nothing is cached, live endpoint is forwarded
+	r, err := http.NewRequest("GET", "http://live.com/webhook", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(r)
+	testutil.Expect(t, resp.StatusCode, 201)
+
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 0)
+
+	// other endpoints are still simulated
+	r, err = http.NewRequest("GET", "http://live.com/other", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp = dbClient.processRequest(r)
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+
+	// live response is cached
+	dbClient.Cfg.CacheLiveResponses = true
+
+	r, err = http.NewRequest("GET", "http://live.com/webhook", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp = dbClient.processRequest(r)
+	testutil.Expect(t, resp.StatusCode, 201)
+
+	count, err = dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+}
+
+func TestGetNewHoverflyJWTAuthType(t *testing.T) {
+	cfg := InitSettings()
+	cfg.AuthType = backends.AuthTypeJWT
+	cfg.JWTVerificationKey = []byte("verysecret")
+
+	dbClient, err := GetNewHoverfly(cfg, cache.NewInMemoryCache(), cache.NewInMemoryCache(), backends.NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache()))
+	testutil.Expect(t, err, nil)
+	_, ok := dbClient.Authentication.(*backends.JWTBackend)
+	testutil.Expect(t, ok, true)
+
+	cfg.AuthType = "unknown"
+	_, err = GetNewHoverfly(cfg, cache.NewInMemoryCache(), cache.NewInMemoryCache(), backends.NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache()))
+	testutil.Refute(t, err, nil)
+}
+
+func TestGetNewHoverflyCacheSize(t *testing.T) {
+	cfg := InitSettings()
+	cfg.CacheSize = 10
+
+	dbClient, err := GetNewHoverfly(cfg, nil, cache.NewInMemoryCache(), backends.NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache()))
+	testutil.Expect(t, err, nil)
+	_, ok := dbClient.RequestCache.(*cache.LRUCache)
+	testutil.Expect(t, ok, true)
+
+	cfg.CacheSize = 0
+	dbClient, err = GetNewHoverfly(cfg, nil, cache.NewInMemoryCache(), backends.NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache()))
+	testutil.Expect(t, err, nil)
+	_, ok = dbClient.RequestCache.(*cache.InMemoryCache)
+	testutil.Expect(t, ok, true)
+}
@@ -0,0 +1,35 @@
+// Package hoverflytest provides Hoverfly backed test doubles for code that already injects *httptest.Server
+package hoverflytest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// Simulation - payloads served by the test server, same structure as 'data' in exported simulations
+type Simulation []models.PayloadView
+
+// NewHoverflyServer - starts httptest.Server which handler is Hoverfly proxy in simulate mode with given
+// simulation imported. Clients should use server URL as their proxy, caller is responsible for closing the server.
+func NewHoverflyServer(t *testing.T, sim Simulation) *httptest.Server {
+	cfg := hoverfly.InitSettings()
+	cfg.AuthEnabled = false
+	cfg.SetMode(hoverfly.SimulateMode)
+
+	// caches and authentication default to in memory ones
+	h, err := hoverfly.NewHoverfly(hoverfly.WithConfiguration(cfg))
+	if err != nil {
+		t.Fatalf("failed to create hoverfly: %s", err.Error())
+	}
+
+	if len(sim) > 0 {
+		if err := h.ImportPayloads(sim); err != nil {
+			t.Fatalf("failed to import simulation: %s", err.Error())
+		}
+	}
+
+	return httptest.NewServer(h.Proxy)
+}
@@ -0,0 +1,56 @@
+package hoverflytest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestNewHoverflyServerSimulatesResponses(t *testing.T) {
+	server := NewHoverflyServer(t, Simulation{
+		{
+			Request: models.RequestDetailsView{
+				Path:        "/hello",
+				Method:      "GET",
+				Destination: "example.com",
+				Scheme:      "http",
+			},
+			Response: models.ResponseDetailsView{
+				Status: 201,
+				Body:   "hello world",
+			},
+		},
+	})
+	defer server.Close()
+
+	proxyURL, _ := url.Parse(server.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get("http://example.com/hello")
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+
+	testutil.Expect(t, resp.StatusCode, 201)
+	testutil.Expect(t, string(body), "hello world")
+}
+
+func TestNewHoverflyServerWithoutMatchingPayload(t *testing.T) {
+	server := NewHoverflyServer(t, Simulation{})
+	defer server.Close()
+
+	proxyURL, _ := url.Parse(server.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get("http://example.com/missing")
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+
+	testutil.Refute(t, resp.StatusCode, 201)
+}
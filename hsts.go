@@ -0,0 +1,32 @@
+package hoverfly
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// DefaultHSTSMaxAge - max-age of injected Strict-Transport-Security header when HSTSMaxAge isn't set, one year
+const DefaultHSTSMaxAge = 365 * 24 * 60 * 60
+
+// injectHSTSHeader - with Cfg.InjectHSTSHeader, sets Strict-Transport-Security of simulated HTTPS responses,
+// replacing the stored one. Plain HTTP responses are left as they are, browsers ignore HSTS sent over HTTP.
+func (d *Hoverfly) injectHSTSHeader(req *http.Request, resp *http.Response) {
+	cfg := d.requestConfig(req)
+	if resp == nil || !cfg.InjectHSTSHeader || !isHTTPS(req) {
+		return
+	}
+
+	maxAge := cfg.HSTSMaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultHSTSMaxAge
+	}
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	resp.Header.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(maxAge)+"; includeSubDomains")
+}
+
+// isHTTPS - request was made over TLS, either intercepted through CONNECT or received directly
+func isHTTPS(req *http.Request) bool {
+	return req.TLS != nil || req.URL.Scheme == "https"
+}
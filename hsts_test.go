@@ -0,0 +1,83 @@
+package hoverfly
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func storeHSTSPayload(t *testing.T, d *Hoverfly) {
+	payload := models.Payload{
+		Request: models.RequestDetails{Method: "GET", Destination: "secure.com", Path: "/"},
+		Response: models.ResponseDetails{Status: 200, Body: "ok", Headers: map[string][]string{
+			"Strict-Transport-Security": {"max-age=60"},
+		}},
+	}
+	bts, err := payload.Encode()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, d.RequestCache.Set([]byte(payload.Id()), bts), nil)
+}
+
+func simulateHSTS(t *testing.T, d *Hoverfly, url string) *http.Response {
+	req, err := http.NewRequest("GET", url, nil)
+	testutil.Expect(t, err, nil)
+	resp := d.getResponse(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	return resp
+}
+
+func TestInjectHSTSHeaderOverwritesStoredHeader(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	storeHSTSPayload(t, dbClient)
+
+	dbClient.Cfg.InjectHSTSHeader = true
+	dbClient.Cfg.HSTSMaxAge = 3600
+
+	resp := simulateHSTS(t, dbClient, "https://secure.com/")
+	testutil.Expect(t, len(resp.Header["Strict-Transport-Security"]), 1)
+	testutil.Expect(t, resp.Header.Get("Strict-Transport-Security"), "max-age=3600; includeSubDomains")
+}
+
+func TestInjectHSTSHeaderDefaultMaxAge(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	storeHSTSPayload(t, dbClient)
+
+	dbClient.Cfg.InjectHSTSHeader = true
+
+	resp := simulateHSTS(t, dbClient, "https://secure.com/")
+	testutil.Expect(t, resp.Header.Get("Strict-Transport-Security"), "max-age=31536000; includeSubDomains")
+}
+
+func TestInjectHSTSHeaderSkipsHTTP(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.InjectHSTSHeader = true
+
+	resp := &http.Response{Header: http.Header{}}
+	req, _ := http.NewRequest("GET", "http://secure.com/", nil)
+	dbClient.injectHSTSHeader(req, resp)
+	testutil.Expect(t, resp.Header.Get("Strict-Transport-Security"), "")
+
+	// stored header isn't touched either
+	storeHSTSPayload(t, dbClient)
+	resp = simulateHSTS(t, dbClient, "http://secure.com/")
+	testutil.Expect(t, resp.Header.Get("Strict-Transport-Security"), "max-age=60")
+}
+
+func TestInjectHSTSHeaderDisabled(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	storeHSTSPayload(t, dbClient)
+
+	resp := simulateHSTS(t, dbClient, "https://secure.com/")
+	testutil.Expect(t, resp.Header.Get("Strict-Transport-Security"), "max-age=60")
+}
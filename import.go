@@ -1,11 +1,15 @@
 package hoverfly
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
@@ -81,20 +85,32 @@ func exists(path string) (bool, error) {
 }
 
 // ImportFromDisk - takes one string value and tries to open a file, then parse it into recordedRequests structure
-// (which is default format in which Hoverfly exports captured requests) and imports those requests into the database
+// (which is default format in which Hoverfly exports captured requests) and imports those requests into the database.
+// Response headers from files referenced by '$include' are added to responses that don't set them.
 func (d *Hoverfly) ImportFromDisk(path string) error {
 	payloadsFile, err := os.Open(path)
 	if err != nil {
 		return fmt.Errorf("Got error while opening payloads file, error %s", err.Error())
 	}
+	defer payloadsFile.Close()
 
-	var requests recordedRequests
+	var requests includedSimulation
 
 	jsonParser := json.NewDecoder(payloadsFile)
 	if err = jsonParser.Decode(&requests); err != nil {
 		return fmt.Errorf("Got error while parsing payloads file, error %s", err.Error())
 	}
 
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	defaults, err := requests.defaultResponseHeaders(abs, []string{abs})
+	if err != nil {
+		return fmt.Errorf("Failed to resolve includes of payloads file, error %s", err.Error())
+	}
+	applyDefaultResponseHeaders(requests.Data, defaults)
+
 	return d.ImportPayloads(requests.Data)
 }
 
@@ -107,15 +123,73 @@ func (d *Hoverfly) ImportFromURL(url string) error {
 	if err != nil {
 		return fmt.Errorf("Failed to fetch given URL, error %s", err.Error())
 	}
+	defer resp.Body.Close()
 
-	var requests recordedRequests
+	_, err = d.ImportSimulation(resp.Body)
+	return err
+}
 
-	jsonParser := json.NewDecoder(resp.Body)
-	if err = jsonParser.Decode(&requests); err != nil {
-		return fmt.Errorf("Got error while parsing payloads, error %s", err.Error())
+// ImportSimulationTimeout - maximum time allowed for fetching remote simulation through admin API
+const ImportSimulationTimeout = 30 * time.Second
+
+// ImportSimulationFromURL - fetches simulation from given http or https URL (following redirects) and imports it,
+// returns number of imported payloads
+func (d *Hoverfly) ImportSimulationFromURL(uri string) (int, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to parse given URL, error %s", err.Error())
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return 0, fmt.Errorf("Unsupported URL scheme '%s', only http and https are allowed", u.Scheme)
 	}
 
-	return d.ImportPayloads(requests.Data)
+	client := &http.Client{
+		Transport: d.HTTP.Transport,
+		Timeout:   ImportSimulationTimeout,
+	}
+
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return 0, fmt.Errorf("Failed to fetch given URL, error %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("Failed to fetch given URL, got status code %d", resp.StatusCode)
+	}
+
+	return d.ImportSimulation(resp.Body)
+}
+
+// ImportSimulation - reads simulation in the default export format from given reader, validates it against
+// simulation schema and imports its payloads, returns number of imported payloads. Nothing is imported when
+// any entry is invalid.
+func (d *Hoverfly) ImportSimulation(r io.Reader) (int, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, fmt.Errorf("Got error while reading payloads, error %s", err.Error())
+	}
+
+	validationErrors, err := ValidateSimulation(bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	if len(validationErrors) > 0 {
+		return 0, validationFailure(validationErrors)
+	}
+
+	var requests recordedRequests
+	if err := json.Unmarshal(body, &requests); err != nil {
+		return 0, fmt.Errorf("Got error while parsing payloads, error %s", err.Error())
+	}
+	if err := requests.resolveBodyRefs(); err != nil {
+		return 0, err
+	}
+
+	if err := d.ImportPayloads(requests.Data); err != nil {
+		return 0, err
+	}
+	return len(requests.Data), nil
 }
 
 func isJSON(s string) bool {
@@ -127,12 +201,18 @@ func isJSON(s string) bool {
 // ImportPayloads - a function to save given payloads into the database.
 func (d *Hoverfly) ImportPayloads(payloads []models.PayloadView) error {
 	if len(payloads) > 0 {
+		payloads, err := d.Cfg.applyImportBodyEncodingPolicy(payloads)
+		if err != nil {
+			return err
+		}
+
 		success := 0
 		failed := 0
 		for _, payloadView := range payloads {
 
 			// Convert PayloadView back to Payload for internal storage
 			pl := payloadView.ConvertToPayload()
+			d.Cfg.minifyJSONBodies(&pl)
 
 			if len(pl.Request.Headers) == 0 {
 				pl.Request.Headers = make(map[string][]string)
@@ -148,6 +228,18 @@ func (d *Hoverfly) ImportPayloads(payloads []models.PayloadView) error {
 				}
 			}
 
+			if pl.Request.BodyMatcher != nil {
+				if err := validateBodyMatcher(pl.Request.BodyMatcher); err != nil {
+					log.WithFields(log.Fields{
+						"error":       err.Error(),
+						"destination": pl.Request.Destination,
+						"path":        pl.Request.Path,
+					}).Error("Invalid body matcher")
+					failed++
+					continue
+				}
+			}
+
 			bts, err := pl.Encode()
 			if err != nil {
 				log.WithFields(log.Fields{
@@ -170,7 +262,20 @@ func (d *Hoverfly) ImportPayloads(payloads []models.PayloadView) error {
 					}).Error("failed to fire hook")
 				}
 
-				d.RequestCache.Set([]byte(pl.Id()), bts)
+				// aliased query parameters are matched by their canonical names
+				request := pl.Request
+				request.Query = d.Cfg.normaliseQuery(request.Query)
+
+				key := request.Hash()
+				d.removeReplacedBodyFile(key, pl.RequestBodyFile)
+				d.RequestCache.Set([]byte(key), bts)
+				d.captureBloom.add(key)
+				if err := d.registerBodyMatcher(key, pl.Request); err != nil {
+					log.WithFields(log.Fields{
+						"error": err.Error(),
+						"key":   key,
+					}).Error("Failed to register body matcher")
+				}
 				if err == nil {
 					success++
 				} else {
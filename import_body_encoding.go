@@ -0,0 +1,118 @@
+package hoverfly
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// Import body encoding policies, see Configuration.ImportBodyEncodingPolicy
+const (
+	// ImportBodyEncodingAuto - bodies without declared encoding are detected, bodies that can't be decoded with
+	// declared encoding fail the import
+	ImportBodyEncodingAuto = "auto"
+	// ImportBodyEncodingStrict - bodies are imported as declared, bodies that can't be decoded fail the import
+	ImportBodyEncodingStrict = "strict"
+	// ImportBodyEncodingLenient - bodies without declared encoding are detected, bodies that can't be decoded with
+	// declared encoding are imported as they are
+	ImportBodyEncodingLenient = "lenient"
+)
+
+// validateImportBodyEncodingPolicy - empty policy imports bodies as declared and ignores decoding errors
+func validateImportBodyEncodingPolicy(policy string) error {
+	switch policy {
+	case "", ImportBodyEncodingAuto, ImportBodyEncodingStrict, ImportBodyEncodingLenient:
+		return nil
+	}
+	return fmt.Errorf("invalid import body encoding policy '%s', expected '%s', '%s' or '%s'",
+		policy, ImportBodyEncodingAuto, ImportBodyEncodingStrict, ImportBodyEncodingLenient)
+}
+
+// applyImportBodyEncodingPolicy - returns copy of payloads with response body encodings detected and checked
+// according to ImportBodyEncodingPolicy
+func (c *Configuration) applyImportBodyEncodingPolicy(payloads []models.PayloadView) ([]models.PayloadView, error) {
+	if c == nil || c.ImportBodyEncodingPolicy == "" {
+		return payloads, nil
+	}
+	policy := c.ImportBodyEncodingPolicy
+
+	payloads = append([]models.PayloadView(nil), payloads...)
+	for i := range payloads {
+		response := &payloads[i].Response
+
+		if !response.EncodedBody && response.BodyEncoding == "" {
+			if policy != ImportBodyEncodingStrict {
+				detectBodyEncoding(response)
+			}
+			continue
+		}
+
+		err := checkDeclaredBodyEncoding(*response)
+		if err == nil {
+			continue
+		}
+		if policy != ImportBodyEncodingLenient {
+			return nil, fmt.Errorf("data[%d].response: %s", i, err.Error())
+		}
+
+		log.WithFields(log.Fields{
+			"error":       err.Error(),
+			"destination": payloads[i].Request.Destination,
+			"path":        payloads[i].Request.Path,
+		}).Warn("Response body can't be decoded, importing it as it is")
+		response.EncodedBody = false
+		response.BodyEncoding = ""
+	}
+	return payloads, nil
+}
+
+// checkDeclaredBodyEncoding - body can be decoded with encoding set by 'encodedBody' and 'bodyEncoding'
+func checkDeclaredBodyEncoding(response models.ResponseDetailsView) error {
+	if response.BodyEncoding != "" {
+		return response.DecompressBody()
+	}
+	if _, err := base64.StdEncoding.DecodeString(response.Body); err != nil {
+		return fmt.Errorf("body is not valid base64: %s", err.Error())
+	}
+	return nil
+}
+
+// minEncodedBodyLength - shorter bodies are always text, most short words are valid base64 or hex
+const minEncodedBodyLength = 8
+
+// detectBodyEncoding - body is text unless it is base64 or, failing that, hex encoding of data that isn't text.
+// Base64 bodies are marked as encoded and hex bodies are replaced by the raw bytes.
+func detectBodyEncoding(response *models.ResponseDetailsView) {
+	body := response.Body
+	if len(body) < minEncodedBodyLength {
+		return
+	}
+
+	if len(body)%4 == 0 {
+		if decoded, err := base64.StdEncoding.DecodeString(body); err == nil && !isText(decoded) {
+			response.EncodedBody = true
+			return
+		}
+	}
+
+	if decoded, err := hex.DecodeString(body); err == nil && !isText(decoded) {
+		response.Body = string(decoded)
+	}
+}
+
+// isText - valid UTF-8 without control characters other than whitespace
+func isText(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if (r < 0x20 && r != '\t' && r != '\n' && r != '\r') || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package hoverfly
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+var pngHeader = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+
+// importedBody - imports single payload with given response and returns body of the stored response
+func importedBody(t *testing.T, policy string, response models.ResponseDetailsView) (string, error) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.ImportBodyEncodingPolicy = policy
+	response.Status = 200
+	err := dbClient.ImportPayloads([]models.PayloadView{{
+		Request:  models.RequestDetailsView{Method: "GET", Destination: "example.com", Path: "/", Scheme: "http"},
+		Response: response,
+	}})
+	if err != nil {
+		count, countErr := dbClient.RequestCache.RecordsCount()
+		testutil.Expect(t, countErr, nil)
+		testutil.Expect(t, count, 0)
+		return "", err
+	}
+
+	keys, err := dbClient.RequestCache.Keys()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(keys), 1)
+	bts, err := dbClient.RequestCache.Get([]byte(keys[0]))
+	testutil.Expect(t, err, nil)
+	payload, err := models.NewPayloadFromBytes(bts)
+	testutil.Expect(t, err, nil)
+	return payload.Response.Body, nil
+}
+
+func TestImportBodyEncodingAutoDetectsBase64(t *testing.T) {
+	body, err := importedBody(t, ImportBodyEncodingAuto, models.ResponseDetailsView{Body: base64.StdEncoding.EncodeToString([]byte(pngHeader))})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, body, pngHeader)
+}
+
+func TestImportBodyEncodingAutoDetectsHex(t *testing.T) {
+	// 10 characters aren't valid base64
+	body, err := importedBody(t, ImportBodyEncodingAuto, models.ResponseDetailsView{Body: hex.EncodeToString([]byte{0xff, 0x00, 0x01, 0x02, 0x03})})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, body, "\xff\x00\x01\x02\x03")
+}
+
+func TestImportBodyEncodingAutoKeepsText(t *testing.T) {
+	for _, text := range []string{"hello world", `{"id": 1}`, base64.StdEncoding.EncodeToString([]byte("plain text")), "cafe"} {
+		body, err := importedBody(t, ImportBodyEncodingAuto, models.ResponseDetailsView{Body: text})
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, body, text)
+	}
+}
+
+func TestImportBodyEncodingKeepsDeclaredEncoding(t *testing.T) {
+	for _, policy := range []string{"", ImportBodyEncodingAuto, ImportBodyEncodingStrict, ImportBodyEncodingLenient} {
+		body, err := importedBody(t, policy, models.ResponseDetailsView{Body: base64.StdEncoding.EncodeToString([]byte("declared")), EncodedBody: true})
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, body, "declared")
+	}
+}
+
+func TestImportBodyEncodingStrictDoesNotDetect(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(pngHeader))
+	body, err := importedBody(t, ImportBodyEncodingStrict, models.ResponseDetailsView{Body: encoded})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, body, encoded)
+}
+
+func TestImportBodyEncodingInvalidDeclaredBody(t *testing.T) {
+	invalid := models.ResponseDetailsView{Body: "not base64!", EncodedBody: true}
+
+	_, err := importedBody(t, ImportBodyEncodingStrict, invalid)
+	testutil.Refute(t, err, nil)
+
+	_, err = importedBody(t, ImportBodyEncodingAuto, invalid)
+	testutil.Refute(t, err, nil)
+
+	_, err = importedBody(t, ImportBodyEncodingAuto, models.ResponseDetailsView{Body: "abc", BodyEncoding: "brotli"})
+	testutil.Refute(t, err, nil)
+
+	body, err := importedBody(t, ImportBodyEncodingLenient, invalid)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, body, "not base64!")
+}
+
+func TestValidateImportBodyEncodingPolicy(t *testing.T) {
+	for _, policy := range []string{"", ImportBodyEncodingAuto, ImportBodyEncodingStrict, ImportBodyEncodingLenient} {
+		testutil.Expect(t, validateImportBodyEncodingPolicy(policy), nil)
+	}
+	testutil.Refute(t, validateImportBodyEncodingPolicy("guess"), nil)
+}
@@ -274,3 +274,17 @@ func TestImportPayloads_CanImportASingleBase64EncodedPayload(t *testing.T) {
 			Query: "", Body: "",
 			Headers: map[string][]string{"Hoverfly": []string {"testing"}}}}))
 }
+
+func TestImportSimulation(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	payloadsFile, err := os.Open("examples/exports/readthedocs.json")
+	testutil.Expect(t, err, nil)
+	defer payloadsFile.Close()
+
+	count, err := dbClient.ImportSimulation(payloadsFile)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 5)
+}
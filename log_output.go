@@ -0,0 +1,130 @@
+package hoverfly
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// log output destinations that aren't file paths
+const (
+	LogOutputStdout = "stdout"
+	LogOutputStderr = "stderr"
+)
+
+// LogOutputHook - logrus hook writing entries of each level to its own destination, levels without destination are
+// written to stderr. Files are opened for appending and shared by levels that use the same path.
+type LogOutputHook struct {
+	mu       sync.Mutex
+	writers  map[log.Level]io.Writer
+	fallback io.Writer
+	files    []*os.File
+}
+
+// ParseLogOutput - parses 'level=destination' (i.e. 'error=stderr', 'info=/var/log/hoverfly/info.log')
+func ParseLogOutput(value string) (string, string, error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid log output '%s', expected 'level=destination' (i.e. 'error=stderr')", value)
+	}
+	if _, err := log.ParseLevel(parts[0]); err != nil {
+		return "", "", fmt.Errorf("invalid log output '%s': %s", value, err.Error())
+	}
+	return strings.ToLower(parts[0]), parts[1], nil
+}
+
+// NewLogOutputHook - creates hook for outputs mapping level names ('debug', 'info', 'warn', 'error', ...) to
+// 'stdout', 'stderr' or a file path
+func NewLogOutputHook(outputs map[string]string) (*LogOutputHook, error) {
+	h := &LogOutputHook{
+		writers:  make(map[log.Level]io.Writer),
+		fallback: os.Stderr,
+	}
+	opened := make(map[string]*os.File)
+
+	for name, destination := range outputs {
+		level, err := log.ParseLevel(name)
+		if err != nil {
+			h.Close()
+			return nil, err
+		}
+
+		switch destination {
+		case LogOutputStdout:
+			h.writers[level] = os.Stdout
+		case LogOutputStderr:
+			h.writers[level] = os.Stderr
+		default:
+			f, ok := opened[destination]
+			if !ok {
+				f, err = os.OpenFile(destination, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+				if err != nil {
+					h.Close()
+					return nil, fmt.Errorf("failed to open log output for level '%s': %s", name, err.Error())
+				}
+				opened[destination] = f
+				h.files = append(h.files, f)
+			}
+			h.writers[level] = f
+		}
+	}
+	return h, nil
+}
+
+// Levels - hook receives entries of all levels as it replaces logger output
+func (h *LogOutputHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire - writes entry formatted with logger formatter to destination of its level
+func (h *LogOutputHook) Fire(entry *log.Entry) error {
+	line, err := entry.String()
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w, ok := h.writers[entry.Level]
+	if !ok {
+		w = h.fallback
+	}
+	_, err = io.WriteString(w, line)
+	return err
+}
+
+// Close - closes log files
+func (h *LogOutputHook) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var err error
+	for _, f := range h.files {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	h.files = nil
+	return err
+}
+
+// ConfigureLogOutput - routes standard logger output through LogOutputHook, logger itself stops writing to its
+// output. Nothing is changed when outputs are empty.
+func ConfigureLogOutput(outputs map[string]string) (*LogOutputHook, error) {
+	if len(outputs) == 0 {
+		return nil, nil
+	}
+	h, err := NewLogOutputHook(outputs)
+	if err != nil {
+		return nil, err
+	}
+	log.AddHook(h)
+	log.SetOutput(ioutil.Discard)
+	return h, nil
+}
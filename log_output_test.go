@@ -0,0 +1,78 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestLogOutputHookWritesLevelsToTheirFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hflogs")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	infoPath := filepath.Join(dir, "info.log")
+	errorPath := filepath.Join(dir, "error.log")
+
+	hook, err := NewLogOutputHook(map[string]string{
+		"info":  infoPath,
+		"warn":  errorPath,
+		"error": errorPath,
+	})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(hook.files), 2)
+
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	logger.Formatter = &log.JSONFormatter{}
+	logger.Hooks.Add(hook)
+
+	logger.WithFields(log.Fields{"path": "/items"}).Info("request served")
+	logger.Warn("slow upstream")
+	logger.Error("upstream failed")
+	testutil.Expect(t, hook.Close(), nil)
+
+	info, err := ioutil.ReadFile(infoPath)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, strings.Count(string(info), "\n"), 1)
+	testutil.Expect(t, strings.Contains(string(info), `"msg":"request served"`), true)
+	testutil.Expect(t, strings.Contains(string(info), `"path":"/items"`), true)
+
+	errors, err := ioutil.ReadFile(errorPath)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, strings.Count(string(errors), "\n"), 2)
+	testutil.Expect(t, strings.Contains(string(errors), "slow upstream"), true)
+	testutil.Expect(t, strings.Contains(string(errors), "upstream failed"), true)
+}
+
+func TestLogOutputHookStandardStreams(t *testing.T) {
+	hook, err := NewLogOutputHook(map[string]string{"info": "stdout", "error": "stderr"})
+	testutil.Expect(t, err, nil)
+	defer hook.Close()
+
+	testutil.Expect(t, hook.writers[log.InfoLevel] == os.Stdout, true)
+	testutil.Expect(t, hook.writers[log.ErrorLevel] == os.Stderr, true)
+	testutil.Expect(t, len(hook.files), 0)
+}
+
+func TestNewLogOutputHookInvalidLevel(t *testing.T) {
+	_, err := NewLogOutputHook(map[string]string{"verbose": "stdout"})
+	testutil.Refute(t, err, nil)
+}
+
+func TestParseLogOutput(t *testing.T) {
+	level, destination, err := ParseLogOutput("ERROR=/var/log/hoverfly/error.log")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, level, "error")
+	testutil.Expect(t, destination, "/var/log/hoverfly/error.log")
+
+	for _, value := range []string{"error", "=stdout", "info=", "loud=stdout"} {
+		_, _, err := ParseLogOutput(value)
+		testutil.Refute(t, err, nil)
+	}
+}
@@ -0,0 +1,73 @@
+package hoverfly
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// luaMiddlewarePrefix - prefix of middleware that is an inline Lua script
+const luaMiddlewarePrefix = "lua:"
+
+// LuaInterpreter - runs inline Lua middleware script, it modifies request and response in place
+type LuaInterpreter func(script string, pair *models.MiddlewarePair) error
+
+// errNoLuaInterpreter - Hoverfly was built without Lua support
+var errNoLuaInterpreter = errors.New("Lua middleware is not supported by this build, rebuild Hoverfly with '-tags lua'")
+
+// luaInterpreter - registered interpreter, nil when Hoverfly was built without Lua support
+var luaInterpreter = struct {
+	sync.Mutex
+	run LuaInterpreter
+}{}
+
+// RegisterLuaInterpreter - sets interpreter Lua middleware is run with, luamiddleware package registers itself when
+// it is imported
+func RegisterLuaInterpreter(run LuaInterpreter) {
+	luaInterpreter.Lock()
+	luaInterpreter.run = run
+	luaInterpreter.Unlock()
+}
+
+func registeredLuaInterpreter() LuaInterpreter {
+	luaInterpreter.Lock()
+	defer luaInterpreter.Unlock()
+	return luaInterpreter.run
+}
+
+// validateLuaMiddleware - Lua middleware can be used only when interpreter is registered
+func validateLuaMiddleware(script string) error {
+	if script != "" && registeredLuaInterpreter() == nil {
+		return errNoLuaInterpreter
+	}
+	return nil
+}
+
+// executeLuaMiddleware - runs Lua script in-process, interpreter panics are returned as errors
+func executeLuaMiddleware(middleware string, payload models.Payload) (_ models.Payload, err error) {
+	run := registeredLuaInterpreter()
+	if run == nil {
+		return payload, errNoLuaInterpreter
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Lua middleware panicked: %v", r)
+		}
+	}()
+
+	pair := &models.MiddlewarePair{Request: payload.Request, Response: payload.Response}
+	if err := run(strings.TrimPrefix(middleware, luaMiddlewarePrefix), pair); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Debug("Lua middleware failed")
+		return payload, err
+	}
+	payload.Request = pair.Request
+	payload.Response = pair.Response
+	return payload, nil
+}
@@ -0,0 +1,76 @@
+package hoverfly
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func withLuaInterpreter(run LuaInterpreter) func() {
+	previous := registeredLuaInterpreter()
+	RegisterLuaInterpreter(run)
+	return func() { RegisterLuaInterpreter(previous) }
+}
+
+func TestLuaMiddlewareTakesPrecedenceOverMiddleware(t *testing.T) {
+	cfg := Configuration{Middleware: "./examples/middleware/modify_response/modify_response.py"}
+	cfg.SetLuaMiddleware("response.status = 201")
+	testutil.Expect(t, cfg.GetMiddleware(), "lua:response.status = 201")
+
+	cfg.SetMiddlewarePlugin("/plugins/middleware.so")
+	testutil.Expect(t, cfg.GetMiddleware(), "plugin:/plugins/middleware.so")
+
+	cfg.SetMiddlewarePlugin("")
+	cfg.SetLuaMiddleware("")
+	testutil.Expect(t, cfg.GetMiddleware(), "./examples/middleware/modify_response/modify_response.py")
+}
+
+func TestExecuteLuaMiddleware(t *testing.T) {
+	var script string
+	defer withLuaInterpreter(func(s string, pair *models.MiddlewarePair) error {
+		script = s
+		pair.Response.Status = 201
+		pair.Response.Body = pair.Request.Method
+		return nil
+	})()
+
+	payload := models.Payload{Request: models.RequestDetails{Method: "GET"}, Response: models.ResponseDetails{Status: 200}}
+	newPayload, _, err := executeMiddleware("lua:response.status = 201", payload, middlewareLimits{})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, script, "response.status = 201")
+	testutil.Expect(t, newPayload.Response.Status, 201)
+	testutil.Expect(t, newPayload.Response.Body, "GET")
+}
+
+func TestExecuteLuaMiddlewareErrorKeepsPayload(t *testing.T) {
+	defer withLuaInterpreter(func(s string, pair *models.MiddlewarePair) error {
+		pair.Response.Status = 500
+		return errors.New("boom")
+	})()
+
+	payload := models.Payload{Response: models.ResponseDetails{Status: 200}}
+	newPayload, err := executeLuaMiddleware("lua:error()", payload)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, newPayload.Response.Status, 200)
+}
+
+func TestExecuteLuaMiddlewarePanicReturnsError(t *testing.T) {
+	defer withLuaInterpreter(func(s string, pair *models.MiddlewarePair) error {
+		panic("interpreter bug")
+	})()
+
+	_, err := executeLuaMiddleware("lua:", models.Payload{})
+	testutil.Refute(t, err, nil)
+}
+
+func TestLuaMiddlewareNeedsInterpreter(t *testing.T) {
+	defer withLuaInterpreter(nil)()
+
+	testutil.Expect(t, validateLuaMiddleware(""), nil)
+	testutil.Expect(t, validateLuaMiddleware("response.status = 201"), errNoLuaInterpreter)
+
+	_, err := executeLuaMiddleware("lua:response.status = 201", models.Payload{})
+	testutil.Expect(t, err, errNoLuaInterpreter)
+}
@@ -0,0 +1,20 @@
+// Package luamiddleware runs inline Lua middleware scripts (Configuration.LuaMiddleware) in-process with
+// gopher-lua, so simple transformations don't need to start middleware executables.
+//
+// Script sees 'request' and 'response' tables, changes made to them are applied to the request and response:
+//
+//	request.method, request.url, request.headers, request.body
+//	response.status, response.headers, response.body
+//
+// Header tables map header names to values, a header with several values is a list of strings. For example:
+//
+//	if request.method == "POST" then
+//		response.status = 201
+//		response.headers["X-Lua"] = "yes"
+//	end
+//
+// The interpreter needs gopher-lua, which Hoverfly doesn't depend on otherwise, so it is only built with the 'lua'
+// build tag. Importing the package registers the interpreter with Hoverfly:
+//
+//	go build -tags lua ./...
+package luamiddleware
@@ -0,0 +1,192 @@
+//go:build lua
+// +build lua
+
+package luamiddleware
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/SpectoLabs/hoverfly"
+	"github.com/SpectoLabs/hoverfly/models"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+func init() {
+	hoverfly.RegisterLuaInterpreter(Run)
+}
+
+// compiled - compiled scripts keyed on their source, configured script is compiled once
+var compiled = struct {
+	sync.Mutex
+	m map[string]*lua.FunctionProto
+}{m: make(map[string]*lua.FunctionProto)}
+
+// compile - returns compiled script, only the last compiled script is kept as script changes only when
+// configuration does
+func compile(script string) (*lua.FunctionProto, error) {
+	compiled.Lock()
+	defer compiled.Unlock()
+
+	if proto, ok := compiled.m[script]; ok {
+		return proto, nil
+	}
+
+	chunk, err := parse.Parse(strings.NewReader(script), "lua middleware")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Lua middleware: %s", err.Error())
+	}
+	proto, err := lua.Compile(chunk, "lua middleware")
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile Lua middleware: %s", err.Error())
+	}
+	compiled.m = map[string]*lua.FunctionProto{script: proto}
+	return proto, nil
+}
+
+// newState - new interpreter for every request so that scripts don't share globals, only libraries that can't
+// reach file system or start processes are opened
+func newState() *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	return L
+}
+
+// Run - runs script with 'request' and 'response' tables, changes script made to them are applied to pair
+func Run(script string, pair *models.MiddlewarePair) error {
+	proto, err := compile(script)
+	if err != nil {
+		return err
+	}
+
+	L := newState()
+	defer L.Close()
+
+	request := L.NewTable()
+	L.SetField(request, "method", lua.LString(pair.Request.Method))
+	L.SetField(request, "url", lua.LString(requestURL(pair.Request)))
+	L.SetField(request, "headers", headersTable(L, pair.Request.Headers))
+	L.SetField(request, "body", lua.LString(pair.Request.Body))
+	L.SetGlobal("request", request)
+
+	response := L.NewTable()
+	L.SetField(response, "status", lua.LNumber(pair.Response.Status))
+	L.SetField(response, "headers", headersTable(L, pair.Response.Headers))
+	L.SetField(response, "body", lua.LString(pair.Response.Body))
+	L.SetGlobal("response", response)
+
+	L.Push(L.NewFunctionFromProto(proto))
+	if err := L.PCall(0, 0, nil); err != nil {
+		return fmt.Errorf("Lua middleware failed: %s", err.Error())
+	}
+
+	// script might have replaced whole tables
+	request, ok := L.GetGlobal("request").(*lua.LTable)
+	if !ok {
+		return fmt.Errorf("Lua middleware replaced 'request' with %s", L.GetGlobal("request").Type())
+	}
+	response, ok = L.GetGlobal("response").(*lua.LTable)
+	if !ok {
+		return fmt.Errorf("Lua middleware replaced 'response' with %s", L.GetGlobal("response").Type())
+	}
+
+	pair.Request.Method = lua.LVAsString(L.GetField(request, "method"))
+	pair.Request.Body = lua.LVAsString(L.GetField(request, "body"))
+	pair.Request.Headers = tableHeaders(L.GetField(request, "headers"))
+	if err := setRequestURL(&pair.Request, lua.LVAsString(L.GetField(request, "url"))); err != nil {
+		return err
+	}
+
+	status, ok := L.GetField(response, "status").(lua.LNumber)
+	if !ok {
+		return fmt.Errorf("Lua middleware set 'response.status' to %s, expected number", L.GetField(response, "status").Type())
+	}
+	pair.Response.Status = int(status)
+	pair.Response.Body = lua.LVAsString(L.GetField(response, "body"))
+	pair.Response.Headers = tableHeaders(L.GetField(response, "headers"))
+	return nil
+}
+
+// requestURL - full URL of request, i.e. 'http://destination/path?query'
+func requestURL(r models.RequestDetails) string {
+	scheme := r.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	u := url.URL{Scheme: scheme, Host: r.Destination, Path: r.Path, RawQuery: r.Query}
+	return u.String()
+}
+
+// setRequestURL - updates scheme, destination, path and query from URL script set
+func setRequestURL(r *models.RequestDetails, raw string) error {
+	if raw == requestURL(*r) {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("Lua middleware set invalid 'request.url' '%s'", raw)
+	}
+	r.Scheme = u.Scheme
+	r.Destination = u.Host
+	r.Path = u.Path
+	r.Query = u.RawQuery
+	return nil
+}
+
+// headersTable - header with a single value is a string, header with several values a list of strings
+func headersTable(L *lua.LState, headers map[string][]string) *lua.LTable {
+	table := L.NewTable()
+	for name, values := range headers {
+		if len(values) == 1 {
+			L.SetField(table, name, lua.LString(values[0]))
+			continue
+		}
+		list := L.NewTable()
+		for _, value := range values {
+			list.Append(lua.LString(value))
+		}
+		L.SetField(table, name, list)
+	}
+	return table
+}
+
+// tableHeaders - reverse of headersTable, entries that are neither strings nor lists are ignored
+func tableHeaders(value lua.LValue) map[string][]string {
+	headers := make(map[string][]string)
+	table, ok := value.(*lua.LTable)
+	if !ok {
+		return headers
+	}
+	table.ForEach(func(k, v lua.LValue) {
+		name, ok := k.(lua.LString)
+		if !ok {
+			return
+		}
+		switch v := v.(type) {
+		case lua.LString, lua.LNumber:
+			headers[string(name)] = []string{lua.LVAsString(v)}
+		case *lua.LTable:
+			var values []string
+			v.ForEach(func(_, item lua.LValue) {
+				values = append(values, lua.LVAsString(item))
+			})
+			headers[string(name)] = values
+		}
+	})
+	return headers
+}
@@ -0,0 +1,73 @@
+//go:build lua
+// +build lua
+
+package luamiddleware
+
+import (
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func testPair() *models.MiddlewarePair {
+	return &models.MiddlewarePair{
+		Request: models.RequestDetails{
+			Method:      "GET",
+			Scheme:      "http",
+			Destination: "example.com",
+			Path:        "/items",
+			Query:       "page=1",
+			Headers:     map[string][]string{"Accept": {"application/json"}},
+		},
+		Response: models.ResponseDetails{
+			Status:  200,
+			Body:    "hello",
+			Headers: map[string][]string{"Vary": {"Accept", "Origin"}},
+		},
+	}
+}
+
+func TestRunExposesRequestAndResponse(t *testing.T) {
+	pair := testPair()
+	err := Run(`
+		response.body = request.method .. " " .. request.url .. " " .. request.headers["Accept"] .. " " ..
+			response.status .. " " .. response.headers["Vary"][2]
+	`, pair)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, pair.Response.Body, "GET http://example.com/items?page=1 application/json 200 Origin")
+}
+
+func TestRunAppliesChanges(t *testing.T) {
+	pair := testPair()
+	err := Run(`
+		request.url = "https://other.com/changed?page=2"
+		request.headers["X-Request"] = {"a", "b"}
+		response.status = 201
+		response.headers["X-Lua"] = "yes"
+		response.body = string.upper(response.body)
+	`, pair)
+	testutil.Expect(t, err, nil)
+
+	testutil.Expect(t, pair.Request.Scheme, "https")
+	testutil.Expect(t, pair.Request.Destination, "other.com")
+	testutil.Expect(t, pair.Request.Path, "/changed")
+	testutil.Expect(t, pair.Request.Query, "page=2")
+	testutil.Expect(t, len(pair.Request.Headers["X-Request"]), 2)
+	testutil.Expect(t, pair.Request.Headers["X-Request"][1], "b")
+
+	testutil.Expect(t, pair.Response.Status, 201)
+	testutil.Expect(t, pair.Response.Headers["X-Lua"][0], "yes")
+	testutil.Expect(t, pair.Response.Body, "HELLO")
+}
+
+func TestRunReturnsScriptErrors(t *testing.T) {
+	testutil.Refute(t, Run(`error("boom")`, testPair()), nil)
+	testutil.Refute(t, Run(`response.status = "ok"`, testPair()), nil)
+	testutil.Refute(t, Run(`this is not lua`, testPair()), nil)
+}
+
+func TestRunCantReachFileSystem(t *testing.T) {
+	testutil.Refute(t, Run(`io.open("/etc/passwd")`, testPair()), nil)
+	testutil.Refute(t, Run(`os.execute("true")`, testPair()), nil)
+}
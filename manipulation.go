@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/SpectoLabs/hoverfly/models"
@@ -15,6 +16,8 @@ import (
 type Constructor struct {
 	request *http.Request
 	payload models.Payload
+	limits  middlewareLimits
+	tracer  TracerProvider
 }
 
 // NewConstructor - returns constructor instance
@@ -23,11 +26,31 @@ func NewConstructor(req *http.Request, payload models.Payload) *Constructor {
 	return c
 }
 
+// withMiddlewareLimits - sets timeout and retries of middleware processes started by ApplyMiddleware
+func (c *Constructor) withMiddlewareLimits(limits middlewareLimits) *Constructor {
+	c.limits = limits
+	return c
+}
+
+// withTracerProvider - middleware invocations get spans of given tracer provider, nil disables tracing
+func (c *Constructor) withTracerProvider(tp TracerProvider) *Constructor {
+	c.tracer = tp
+	return c
+}
+
 // ApplyMiddleware - activates given middleware, middleware should be passed as string to executable, can be
 // full path.
 func (c *Constructor) ApplyMiddleware(middleware string) error {
 
-	newPayload, err := ExecuteMiddleware(middleware, c.payload)
+	end := startOperation(c.request, OperationMiddleware)
+	span := startMiddlewareSpan(c.tracer, c.request, middleware)
+	start := time.Now()
+	newPayload, run, err := executeMiddleware(middleware, c.payload, c.limits)
+	elapsed := time.Since(start)
+	endMiddlewareSpan(span, run, elapsed, err)
+	end(err)
+
+	recordMiddlewareExchange(c.request, run, elapsed)
 
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -72,6 +95,15 @@ func (c *Constructor) ReconstructResponse() *http.Response {
 	response.Body = ioutil.NopCloser(buf)
 	response.StatusCode = c.payload.Response.Status
 
+	if len(c.payload.Response.Trailers) > 0 {
+		response.Trailer = make(http.Header)
+		for k, values := range c.payload.Response.Trailers {
+			for _, v := range values {
+				response.Trailer.Add(k, v)
+			}
+		}
+	}
+
 	return response
 }
 
@@ -102,6 +134,8 @@ func (c *Constructor) ReconstructRequest() (*http.Request, error) {
 	newRequest.URL.Path = c.payload.Request.Path
 	newRequest.URL.RawQuery = c.payload.Request.Query
 	newRequest.Header = c.payload.Request.Headers
+	// keeping request scoped values, i.e. middleware exchange and operation hook
+	newRequest = newRequest.WithContext(c.request.Context())
 
 	// overriding original request
 	c.request = newRequest
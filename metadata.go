@@ -0,0 +1,55 @@
+package hoverfly
+
+import (
+	"encoding/json"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// middlewareMetadataKeyPrefix - prefix for metadata keys that hold key-value pairs returned by middleware
+const middlewareMetadataKeyPrefix = "middleware_metadata_"
+
+// storeMiddlewareMetadata - merges metadata returned by middleware with metadata already stored for given
+// request key
+func (d *Hoverfly) storeMiddlewareMetadata(key string, metadata map[string]string) {
+	if len(metadata) == 0 {
+		return
+	}
+
+	merged := d.getMiddlewareMetadata(key)
+	if merged == nil {
+		merged = make(map[string]string)
+	}
+	for k, v := range metadata {
+		merged[k] = v
+	}
+
+	bts, err := json.Marshal(merged)
+	if err == nil {
+		err = d.MetadataCache.Set([]byte(middlewareMetadataKeyPrefix+key), bts)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Warn("Failed to store middleware metadata")
+	}
+}
+
+// getMiddlewareMetadata - returns metadata stored by middleware for given request key, nil if there is none
+func (d *Hoverfly) getMiddlewareMetadata(key string) map[string]string {
+	bts, err := d.MetadataCache.Get([]byte(middlewareMetadataKeyPrefix + key))
+	if err != nil {
+		return nil
+	}
+
+	var metadata map[string]string
+	if err := json.Unmarshal(bts, &metadata); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Warn("Failed to decode middleware metadata")
+		return nil
+	}
+	return metadata
+}
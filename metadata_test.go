@@ -0,0 +1,105 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestStoreMiddlewareMetadataMerges(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.storeMiddlewareMetadata("key", map[string]string{"a": "1", "b": "2"})
+	dbClient.storeMiddlewareMetadata("key", map[string]string{"b": "3"})
+
+	metadata := dbClient.getMiddlewareMetadata("key")
+	testutil.Expect(t, metadata["a"], "1")
+	testutil.Expect(t, metadata["b"], "3")
+
+	testutil.Expect(t, len(dbClient.getMiddlewareMetadata("other")), 0)
+}
+
+func TestSimulatedResponseUsesMiddlewareMetadata(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.SetMiddleware("./testdata/metadata_middleware.py")
+
+	err := dbClient.ImportPayloads([]models.PayloadView{
+		{
+			Request: models.RequestDetailsView{
+				Path:        "/user",
+				Method:      "GET",
+				Destination: "example.com",
+				Scheme:      "http",
+			},
+			Response: models.ResponseDetailsView{
+				Status:        200,
+				Body:          `{"id": "{{ .Metadata.userId }}"}`,
+				TemplatedBody: true,
+			},
+		},
+		{
+			Request: models.RequestDetailsView{
+				Path:        "/raw",
+				Method:      "GET",
+				Destination: "example.com",
+				Scheme:      "http",
+			},
+			Response: models.ResponseDetailsView{
+				Status: 200,
+				Body:   `{"id": "{{ .Metadata.userId }}"}`,
+			},
+		},
+	})
+	testutil.Expect(t, err, nil)
+
+	req, err := http.NewRequest("GET", "http://example.com/user", nil)
+	testutil.Expect(t, err, nil)
+
+	resp := dbClient.getResponse(req)
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), `{"id": "42"}`)
+
+	// metadata stays available once middleware is removed
+	dbClient.Cfg.SetMiddleware("")
+
+	req, err = http.NewRequest("GET", "http://example.com/user", nil)
+	testutil.Expect(t, err, nil)
+
+	resp = dbClient.getResponse(req)
+	body, err = ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), `{"id": "42"}`)
+
+	// bodies that aren't marked as templates are returned as recorded
+	req, err = http.NewRequest("GET", "http://example.com/raw", nil)
+	testutil.Expect(t, err, nil)
+
+	resp = dbClient.getResponse(req)
+	body, err = ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), `{"id": "{{ .Metadata.userId }}"}`)
+}
+
+func TestCaptureStoresMiddlewareMetadata(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.SetMiddleware("./testdata/metadata_middleware.py")
+
+	req, err := http.NewRequest("GET", "http://example.com/user", nil)
+	testutil.Expect(t, err, nil)
+
+	_, err = dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+
+	key := dbClient.getRequestFingerprint(req, []byte(""))
+	testutil.Expect(t, dbClient.getMiddlewareMetadata(key)["userId"], "42")
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// atomicCounter - registry counter which value can be read and zeroed in one atomic operation, so that no increment
+// is lost between reading and clearing it
+type atomicCounter struct {
+	count int64
+}
+
+func newAtomicCounter() *atomicCounter {
+	return &atomicCounter{}
+}
+
+func (c *atomicCounter) Clear() {
+	atomic.StoreInt64(&c.count, 0)
+}
+
+func (c *atomicCounter) Count() int64 {
+	return atomic.LoadInt64(&c.count)
+}
+
+func (c *atomicCounter) Dec(i int64) {
+	atomic.AddInt64(&c.count, -i)
+}
+
+func (c *atomicCounter) Inc(i int64) {
+	atomic.AddInt64(&c.count, i)
+}
+
+func (c *atomicCounter) Snapshot() metrics.Counter {
+	return metrics.CounterSnapshot(c.Count())
+}
+
+// swap - zeroes the counter and returns its value before
+func (c *atomicCounter) swap() int64 {
+	return atomic.SwapInt64(&c.count, 0)
+}
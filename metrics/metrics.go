@@ -7,9 +7,13 @@ import (
 	"time"
 )
 
+// DeduplicatedCaptures - name of counter of captured requests that weren't stored since they were already captured
+const DeduplicatedCaptures = "deduplicated_captures"
+
 // CounterByMode - container for mode counters, registry and flush interval
 type CounterByMode struct {
 	Counters      map[string]metrics.Counter
+	Deduplicated  metrics.Counter
 	registry      metrics.Registry
 	flushInterval time.Duration
 }
@@ -21,13 +25,17 @@ func NewModeCounter(modes []string) *CounterByMode {
 	counters := make(map[string]metrics.Counter)
 
 	for _, v := range modes {
-		counter := metrics.NewCounter()
+		counter := newAtomicCounter()
 		counters[v] = counter
 		registry.GetOrRegister(v, counter)
 	}
 
+	deduplicated := newAtomicCounter()
+	registry.GetOrRegister(DeduplicatedCaptures, deduplicated)
+
 	c := &CounterByMode{
 		Counters:      counters,
+		Deduplicated:  deduplicated,
 		registry:      registry,
 		flushInterval: 5 * time.Second,
 	}
@@ -37,9 +45,39 @@ func NewModeCounter(modes []string) *CounterByMode {
 	return c
 }
 
-// Count - counts requests based on mode
+// Count - counts requests based on mode, count is also exposed as Prometheus metric
 func (c *CounterByMode) Count(mode string) {
 	c.Counters[mode].Inc(1)
+	prometheusMetrics.countRequest(mode)
+}
+
+// CountWithSize - counts request based on mode and records its response time in histogram of response body size
+// range the response belongs to
+func (c *CounterByMode) CountWithSize(mode string, latency time.Duration, bytes int64) {
+	c.Count(mode)
+	prometheusMetrics.observeWithSize(mode, bytes, latency.Seconds())
+}
+
+// CountDeduplicated - counts captured request that wasn't stored since it was already captured
+func (c *CounterByMode) CountDeduplicated() {
+	c.Deduplicated.Inc(1)
+	prometheusMetrics.countDeduplicated()
+}
+
+// Reset - zeroes all counters and returns their values before the reset, keyed like Stats counters. Every counter is
+// read and zeroed atomically, requests counted while counters are being reset are counted after the reset.
+func (c *CounterByMode) Reset() map[string]int64 {
+	counts := make(map[string]int64)
+	c.registry.Each(func(name string, i interface{}) {
+		switch metric := i.(type) {
+		case *atomicCounter:
+			counts[name] = metric.swap()
+		case metrics.Counter:
+			counts[name] = metric.Count()
+			metric.Clear()
+		}
+	})
+	return counts
 }
 
 // Init initializes logging
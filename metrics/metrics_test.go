@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"sync"
 	"testing"
 )
 
@@ -29,3 +30,47 @@ func TestFlush(t *testing.T) {
 		t.Fatalf("Expected counter to have size %v but was %v", 1, counter)
 	}
 }
+
+func TestReset(t *testing.T) {
+	counter := NewModeCounter([]string{"simulate", "capture"})
+
+	counter.Count("simulate")
+	counter.Count("simulate")
+	counter.CountDeduplicated()
+
+	counts := counter.Reset()
+	if counts["simulate"] != 2 || counts["capture"] != 0 || counts[DeduplicatedCaptures] != 1 {
+		t.Fatalf("Expected counts before reset to be returned but got %v", counts)
+	}
+
+	fl := counter.Flush()
+	if fl.Counters["simulate"] != 0 || fl.Counters[DeduplicatedCaptures] != 0 {
+		t.Fatalf("Expected counters to be zeroed but got %v", fl.Counters)
+	}
+}
+
+func TestResetConcurrentCounts(t *testing.T) {
+	counter := NewModeCounter([]string{"simulate"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				counter.Count("simulate")
+			}
+		}()
+	}
+
+	var total int64
+	for i := 0; i < 100; i++ {
+		total += counter.Reset()["simulate"]
+	}
+	wg.Wait()
+	total += counter.Reset()["simulate"]
+
+	if total != 10000 {
+		t.Fatalf("Expected every count to be reported by exactly one reset but got %d", total)
+	}
+}
@@ -0,0 +1,262 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PrometheusContentType - content type of Prometheus text exposition format
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// durationBuckets - upper bounds (in seconds) of request duration histogram buckets, same as Prometheus client defaults
+var durationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// responseSizeBuckets - labels of response body size ranges, response times are tracked separately for each range
+var responseSizeBuckets = []struct {
+	label string
+	upper int64
+}{
+	{"<1KB", 1 << 10},
+	{"1KB-10KB", 10 << 10},
+	{"10KB-100KB", 100 << 10},
+	{">100KB", -1},
+}
+
+// responseSizeBucket - label of size range given body size belongs to
+func responseSizeBucket(bytes int64) string {
+	for _, b := range responseSizeBuckets {
+		if b.upper < 0 || bytes < b.upper {
+			return b.label
+		}
+	}
+	return responseSizeBuckets[len(responseSizeBuckets)-1].label
+}
+
+// histogram - cumulative bucket counts, sum and count of observed values
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram() *histogram {
+	return &histogram{buckets: make([]uint64, len(durationBuckets))}
+}
+
+func (h *histogram) observe(seconds float64) {
+	for i, upper := range durationBuckets {
+		if seconds <= upper {
+			h.buckets[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// write - writes histogram samples, labels are already formatted i.e. 'mode="simulate"'
+func (h *histogram) write(buf *bytes.Buffer, name, labels string) {
+	for i, upper := range durationBuckets {
+		fmt.Fprintf(buf, "%s_bucket{%s,le=%q} %d\n", name, labels, strconv.FormatFloat(upper, 'g', -1, 64), h.buckets[i])
+	}
+	fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(buf, "%s_sum{%s} %s\n", name, labels, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(buf, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// sizeKey - mode and response size range of response time histogram
+type sizeKey struct {
+	mode string
+	size string
+}
+
+// promRegistry - request, duration and cache metrics exposed in Prometheus text format
+type promRegistry struct {
+	mu          sync.Mutex
+	requests    map[string]uint64
+	durations   map[string]*histogram
+	bySize      map[sizeKey]*histogram
+	inFlight    map[string]int64
+	cacheHits   uint64
+	cacheMisses uint64
+
+	deduplicatedCaptures uint64
+}
+
+var prometheusMetrics = newPromRegistry()
+
+func newPromRegistry() *promRegistry {
+	return &promRegistry{
+		requests:  make(map[string]uint64),
+		durations: make(map[string]*histogram),
+		bySize:    make(map[sizeKey]*histogram),
+		inFlight:  make(map[string]int64),
+	}
+}
+
+// ObserveRequestDuration - records how long it took to process request in given mode
+func ObserveRequestDuration(mode string, d time.Duration) {
+	prometheusMetrics.observe(mode, d.Seconds())
+}
+
+// InFlightRequests - records request that started being processed in given mode, returned function records that
+// it is done, i.e. 'defer metrics.InFlightRequests(mode)()'
+func InFlightRequests(mode string) func() {
+	prometheusMetrics.addInFlight(mode, 1)
+	return func() {
+		prometheusMetrics.addInFlight(mode, -1)
+	}
+}
+
+// CacheHit - records request that was found in request cache
+func CacheHit() {
+	prometheusMetrics.mu.Lock()
+	prometheusMetrics.cacheHits++
+	prometheusMetrics.mu.Unlock()
+}
+
+// CacheMiss - records request that wasn't found in request cache
+func CacheMiss() {
+	prometheusMetrics.mu.Lock()
+	prometheusMetrics.cacheMisses++
+	prometheusMetrics.mu.Unlock()
+}
+
+// MetricsHandler - serves request counts, in flight requests, request durations and cache hits/misses in Prometheus text exposition format
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", PrometheusContentType)
+		w.Write(prometheusMetrics.expose())
+	})
+}
+
+func (p *promRegistry) countRequest(mode string) {
+	p.mu.Lock()
+	p.requests[mode]++
+	p.mu.Unlock()
+}
+
+func (p *promRegistry) countDeduplicated() {
+	p.mu.Lock()
+	p.deduplicatedCaptures++
+	p.mu.Unlock()
+}
+
+func (p *promRegistry) addInFlight(mode string, delta int64) {
+	p.mu.Lock()
+	p.inFlight[mode] += delta
+	p.mu.Unlock()
+}
+
+func (p *promRegistry) observe(mode string, seconds float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h, ok := p.durations[mode]
+	if !ok {
+		h = newHistogram()
+		p.durations[mode] = h
+	}
+	h.observe(seconds)
+}
+
+func (p *promRegistry) observeWithSize(mode string, bytes int64, seconds float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := sizeKey{mode: mode, size: responseSizeBucket(bytes)}
+	h, ok := p.bySize[key]
+	if !ok {
+		h = newHistogram()
+		p.bySize[key] = h
+	}
+	h.observe(seconds)
+}
+
+func (p *promRegistry) expose() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var buf bytes.Buffer
+
+	buf.WriteString("# HELP hoverfly_requests_total Total number of requests processed by Hoverfly.\n")
+	buf.WriteString("# TYPE hoverfly_requests_total counter\n")
+	for _, mode := range sortedKeys(p.requests) {
+		fmt.Fprintf(&buf, "hoverfly_requests_total{mode=%q} %d\n", mode, p.requests[mode])
+	}
+
+	// modes stay exposed with 0 once their requests are done
+	buf.WriteString("# HELP hoverfly_in_flight_requests Number of requests currently being processed by Hoverfly.\n")
+	buf.WriteString("# TYPE hoverfly_in_flight_requests gauge\n")
+	inFlightModes := make([]string, 0, len(p.inFlight))
+	for mode := range p.inFlight {
+		inFlightModes = append(inFlightModes, mode)
+	}
+	sort.Strings(inFlightModes)
+	for _, mode := range inFlightModes {
+		fmt.Fprintf(&buf, "hoverfly_in_flight_requests{mode=%q} %d\n", mode, p.inFlight[mode])
+	}
+
+	buf.WriteString("# HELP hoverfly_request_duration_seconds Time taken to process requests.\n")
+	buf.WriteString("# TYPE hoverfly_request_duration_seconds histogram\n")
+	modes := make([]string, 0, len(p.durations))
+	for mode := range p.durations {
+		modes = append(modes, mode)
+	}
+	sort.Strings(modes)
+	for _, mode := range modes {
+		p.durations[mode].write(&buf, "hoverfly_request_duration_seconds", fmt.Sprintf("mode=%q", mode))
+	}
+
+	buf.WriteString("# HELP hoverfly_response_duration_by_size_seconds Time taken to serve responses by response body size.\n")
+	buf.WriteString("# TYPE hoverfly_response_duration_by_size_seconds histogram\n")
+	keys := make([]sizeKey, 0, len(p.bySize))
+	for key := range p.bySize {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].mode != keys[j].mode {
+			return keys[i].mode < keys[j].mode
+		}
+		return sizeBucketIndex(keys[i].size) < sizeBucketIndex(keys[j].size)
+	})
+	for _, key := range keys {
+		p.bySize[key].write(&buf, "hoverfly_response_duration_by_size_seconds", fmt.Sprintf("mode=%q,size=%q", key.mode, key.size))
+	}
+
+	buf.WriteString("# HELP hoverfly_cache_hits_total Total number of requests found in request cache.\n")
+	buf.WriteString("# TYPE hoverfly_cache_hits_total counter\n")
+	fmt.Fprintf(&buf, "hoverfly_cache_hits_total %d\n", p.cacheHits)
+
+	buf.WriteString("# HELP hoverfly_cache_misses_total Total number of requests not found in request cache.\n")
+	buf.WriteString("# TYPE hoverfly_cache_misses_total counter\n")
+	fmt.Fprintf(&buf, "hoverfly_cache_misses_total %d\n", p.cacheMisses)
+
+	buf.WriteString("# HELP hoverfly_deduplicated_captures_total Total number of captured requests not stored since they were already captured.\n")
+	buf.WriteString("# TYPE hoverfly_deduplicated_captures_total counter\n")
+	fmt.Fprintf(&buf, "hoverfly_deduplicated_captures_total %d\n", p.deduplicatedCaptures)
+
+	return buf.Bytes()
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sizeBucketIndex(label string) int {
+	for i, b := range responseSizeBuckets {
+		if b.label == label {
+			return i
+		}
+	}
+	return len(responseSizeBuckets)
+}
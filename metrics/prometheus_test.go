@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	prometheusMetrics = newPromRegistry()
+
+	counter := NewModeCounter([]string{"simulate"})
+	counter.Count("simulate")
+	counter.Count("simulate")
+	ObserveRequestDuration("simulate", 20*time.Millisecond)
+	CacheHit()
+	CacheMiss()
+	CacheMiss()
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	MetricsHandler().ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Type") != PrometheusContentType {
+		t.Fatalf("Expected content type %s but was %s", PrometheusContentType, rec.Header().Get("Content-Type"))
+	}
+
+	body, _ := ioutil.ReadAll(rec.Body)
+	for _, line := range []string{
+		`hoverfly_requests_total{mode="simulate"} 2`,
+		`hoverfly_request_duration_seconds_bucket{mode="simulate",le="0.01"} 0`,
+		`hoverfly_request_duration_seconds_bucket{mode="simulate",le="0.025"} 1`,
+		`hoverfly_request_duration_seconds_bucket{mode="simulate",le="+Inf"} 1`,
+		`hoverfly_request_duration_seconds_count{mode="simulate"} 1`,
+		`hoverfly_cache_hits_total 1`,
+		`hoverfly_cache_misses_total 2`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Fatalf("Expected metrics to contain %s but got:\n%s", line, body)
+		}
+	}
+}
+
+func TestCountWithSize(t *testing.T) {
+	prometheusMetrics = newPromRegistry()
+
+	counter := NewModeCounter([]string{"simulate"})
+	counter.CountWithSize("simulate", 2*time.Millisecond, 512)
+	counter.CountWithSize("simulate", 200*time.Millisecond, 50<<10)
+	counter.CountWithSize("simulate", 3*time.Second, 2<<20)
+
+	if count := counter.Counters["simulate"].Count(); count != 3 {
+		t.Fatalf("Expected counter to have size %v but was %v", 3, count)
+	}
+
+	body := string(prometheusMetrics.expose())
+	for _, line := range []string{
+		`hoverfly_response_duration_by_size_seconds_bucket{mode="simulate",size="<1KB",le="0.005"} 1`,
+		`hoverfly_response_duration_by_size_seconds_count{mode="simulate",size="10KB-100KB"} 1`,
+		`hoverfly_response_duration_by_size_seconds_bucket{mode="simulate",size=">100KB",le="2.5"} 0`,
+		`hoverfly_response_duration_by_size_seconds_bucket{mode="simulate",size=">100KB",le="5"} 1`,
+		`hoverfly_requests_total{mode="simulate"} 3`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("Expected metrics to contain %s but got:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, `size="1KB-10KB"`) {
+		t.Fatalf("Expected no responses in 1KB-10KB range but got:\n%s", body)
+	}
+}
+
+func TestResponseSizeBucket(t *testing.T) {
+	for size, expected := range map[int64]string{
+		0:         "<1KB",
+		1023:      "<1KB",
+		1024:      "1KB-10KB",
+		10 << 10:  "10KB-100KB",
+		100 << 10: ">100KB",
+	} {
+		if bucket := responseSizeBucket(size); bucket != expected {
+			t.Fatalf("Expected %d bytes to be in %s bucket but was in %s", size, expected, bucket)
+		}
+	}
+}
+
+func TestInFlightRequests(t *testing.T) {
+	prometheusMetrics = newPromRegistry()
+
+	doneSimulate := InFlightRequests("simulate")
+	InFlightRequests("simulate")
+	doneCapture := InFlightRequests("capture")
+
+	body := string(prometheusMetrics.expose())
+	for _, line := range []string{
+		`# TYPE hoverfly_in_flight_requests gauge`,
+		`hoverfly_in_flight_requests{mode="capture"} 1`,
+		`hoverfly_in_flight_requests{mode="simulate"} 2`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("Expected metrics to contain %s but got:\n%s", line, body)
+		}
+	}
+
+	doneSimulate()
+	doneCapture()
+
+	body = string(prometheusMetrics.expose())
+	for _, line := range []string{
+		`hoverfly_in_flight_requests{mode="capture"} 0`,
+		`hoverfly_in_flight_requests{mode="simulate"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Fatalf("Expected metrics to contain %s but got:\n%s", line, body)
+		}
+	}
+}
+
+func TestCountDeduplicated(t *testing.T) {
+	prometheusMetrics = newPromRegistry()
+
+	counter := NewModeCounter([]string{"capture"})
+	counter.CountDeduplicated()
+	counter.CountDeduplicated()
+
+	if count := counter.Flush().Counters[DeduplicatedCaptures]; count != 2 {
+		t.Fatalf("Expected %d deduplicated captures but was %d", 2, count)
+	}
+
+	body := string(prometheusMetrics.expose())
+	if !strings.Contains(body, "hoverfly_deduplicated_captures_total 2\n") {
+		t.Fatalf("Expected metrics to contain deduplicated captures but got:\n%s", body)
+	}
+}
@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultSizeBuckets - upper bounds in bytes of size histogram buckets, powers of 4 from 256 B to 64 MB
+var DefaultSizeBuckets = func() []int64 {
+	var buckets []int64
+	for exp := 4.0; exp <= 13; exp++ {
+		buckets = append(buckets, int64(math.Pow(4, exp)))
+	}
+	return buckets
+}()
+
+// SizeHistogram - request and response body sizes by mode, counted in buckets with given upper bounds. Sizes
+// larger than the last bound are counted in an overflow bucket.
+type SizeHistogram struct {
+	mu        sync.Mutex
+	bounds    []int64
+	requests  map[string]*sizeBuckets
+	responses map[string]*sizeBuckets
+}
+
+type sizeBuckets struct {
+	counts []int64
+	count  int64
+	min    int64
+	max    int64
+}
+
+// SizeStats - summary of sizes in bytes, percentiles are upper bounds of buckets they fall into (never larger
+// than Max)
+type SizeStats struct {
+	Count int64 `json:"count"`
+	Min   int64 `json:"min"`
+	Max   int64 `json:"max"`
+	P50   int64 `json:"p50"`
+	P95   int64 `json:"p95"`
+	P99   int64 `json:"p99"`
+}
+
+// SizeSummary - size stats of requests and responses by mode
+type SizeSummary struct {
+	Buckets   []int64              `json:"buckets"`
+	Requests  map[string]SizeStats `json:"requests"`
+	Responses map[string]SizeStats `json:"responses"`
+}
+
+// NewSizeHistogram - histogram with given bucket upper bounds, DefaultSizeBuckets are used when none are given
+func NewSizeHistogram(bounds []int64) *SizeHistogram {
+	if len(bounds) == 0 {
+		bounds = DefaultSizeBuckets
+	}
+	sorted := append([]int64(nil), bounds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return &SizeHistogram{
+		bounds:    sorted,
+		requests:  make(map[string]*sizeBuckets),
+		responses: make(map[string]*sizeBuckets),
+	}
+}
+
+// ParseSizeBuckets - parses comma separated list of bucket upper bounds in bytes, i.e. '1024,65536,1048576'
+func ParseSizeBuckets(value string) ([]int64, error) {
+	var bounds []int64
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bound, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || bound <= 0 {
+			return nil, fmt.Errorf("invalid size bucket '%s', expected positive number of bytes", part)
+		}
+		bounds = append(bounds, bound)
+	}
+	return bounds, nil
+}
+
+// RecordRequestSize - records size of request body received in given mode
+func (h *SizeHistogram) RecordRequestSize(mode string, bytes int64) {
+	if h == nil {
+		return
+	}
+	h.record(h.requests, mode, bytes)
+}
+
+// RecordResponseSize - records size of response body returned in given mode
+func (h *SizeHistogram) RecordResponseSize(mode string, bytes int64) {
+	if h == nil {
+		return
+	}
+	h.record(h.responses, mode, bytes)
+}
+
+func (h *SizeHistogram) record(sizes map[string]*sizeBuckets, mode string, bytes int64) {
+	if bytes < 0 {
+		bytes = 0
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	b, ok := sizes[mode]
+	if !ok {
+		b = &sizeBuckets{counts: make([]int64, len(h.bounds)+1), min: bytes, max: bytes}
+		sizes[mode] = b
+	}
+	b.counts[sort.Search(len(h.bounds), func(i int) bool { return h.bounds[i] >= bytes })]++
+	b.count++
+	if bytes < b.min {
+		b.min = bytes
+	}
+	if bytes > b.max {
+		b.max = bytes
+	}
+}
+
+// Summary - current size stats of every mode that recorded sizes
+func (h *SizeHistogram) Summary() SizeSummary {
+	summary := SizeSummary{
+		Requests:  make(map[string]SizeStats),
+		Responses: make(map[string]SizeStats),
+	}
+	if h == nil {
+		return summary
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	summary.Buckets = append([]int64(nil), h.bounds...)
+	for mode, b := range h.requests {
+		summary.Requests[mode] = h.stats(b)
+	}
+	for mode, b := range h.responses {
+		summary.Responses[mode] = h.stats(b)
+	}
+	return summary
+}
+
+func (h *SizeHistogram) stats(b *sizeBuckets) SizeStats {
+	return SizeStats{
+		Count: b.count,
+		Min:   b.min,
+		Max:   b.max,
+		P50:   h.percentile(b, 0.50),
+		P95:   h.percentile(b, 0.95),
+		P99:   h.percentile(b, 0.99),
+	}
+}
+
+// percentile - upper bound of bucket holding given quantile, overflow bucket is bounded by the largest size
+func (h *SizeHistogram) percentile(b *sizeBuckets, quantile float64) int64 {
+	rank := int64(math.Ceil(quantile * float64(b.count)))
+	var seen int64
+	for i, count := range b.counts {
+		seen += count
+		if seen < rank {
+			continue
+		}
+		if i < len(h.bounds) && h.bounds[i] < b.max {
+			return h.bounds[i]
+		}
+		return b.max
+	}
+	return b.max
+}
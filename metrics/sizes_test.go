@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"testing"
+)
+
+func TestSizeHistogramSummary(t *testing.T) {
+	h := NewSizeHistogram([]int64{100, 1000, 10000})
+
+	// 90 small, 9 medium and one large request
+	for i := 0; i < 90; i++ {
+		h.RecordRequestSize("capture", 50)
+	}
+	for i := 0; i < 9; i++ {
+		h.RecordRequestSize("capture", 500)
+	}
+	h.RecordRequestSize("capture", 50000)
+
+	stats, ok := h.Summary().Requests["capture"]
+	if !ok {
+		t.Fatalf("Expected request sizes of capture mode")
+	}
+	if stats.Count != 100 || stats.Min != 50 || stats.Max != 50000 {
+		t.Fatalf("Unexpected count, min or max: %+v", stats)
+	}
+	// percentiles are bucket upper bounds, overflow bucket is bounded by max
+	if stats.P50 != 100 || stats.P95 != 1000 || stats.P99 != 1000 {
+		t.Fatalf("Unexpected percentiles: %+v", stats)
+	}
+
+	h.RecordRequestSize("capture", 50000)
+	if stats = h.Summary().Requests["capture"]; stats.P99 != 50000 {
+		t.Fatalf("Expected p99 to be in overflow bucket: %+v", stats)
+	}
+}
+
+func TestSizeHistogramPercentileNotLargerThanMax(t *testing.T) {
+	h := NewSizeHistogram([]int64{1000, 10})
+	h.RecordResponseSize("simulate", 20)
+	h.RecordResponseSize("simulate", 30)
+
+	stats := h.Summary().Responses["simulate"]
+	if stats.P50 != 30 || stats.P99 != 30 {
+		t.Fatalf("Expected percentiles to be capped by max size: %+v", stats)
+	}
+	if len(h.Summary().Requests) != 0 {
+		t.Fatalf("Expected no request sizes")
+	}
+}
+
+func TestSizeHistogramDefaultBuckets(t *testing.T) {
+	h := NewSizeHistogram(nil)
+	if len(h.Summary().Buckets) != len(DefaultSizeBuckets) {
+		t.Fatalf("Expected default buckets")
+	}
+
+	var nilHistogram *SizeHistogram
+	nilHistogram.RecordRequestSize("simulate", 10)
+	if len(nilHistogram.Summary().Requests) != 0 {
+		t.Fatalf("Expected empty summary of nil histogram")
+	}
+}
+
+func TestParseSizeBuckets(t *testing.T) {
+	bounds, err := ParseSizeBuckets("1024, 65536,1048576")
+	if err != nil || len(bounds) != 3 || bounds[1] != 65536 {
+		t.Fatalf("Unexpected buckets %v, error %v", bounds, err)
+	}
+
+	for _, value := range []string{"1k", "-5", "0"} {
+		if _, err := ParseSizeBuckets(value); err == nil {
+			t.Fatalf("Expected error for '%s'", value)
+		}
+	}
+}
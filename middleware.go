@@ -2,9 +2,17 @@ package hoverfly
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/SpectoLabs/hoverfly/models"
@@ -12,14 +20,21 @@ import (
 
 // Pipeline - to provide input to the pipeline, assign an io.Reader to the first's Stdin.
 func Pipeline(cmds ...*exec.Cmd) (pipeLineOutput, collectedStandardError []byte, pipeLineError error) {
+	return pipelineWithLimit(0, cmds...)
+}
+
+// pipelineWithLimit - Pipeline that reads at most maxOutput bytes of the last command's stdout, commands are killed
+// and error is returned once output is larger. Output isn't limited when maxOutput isn't positive.
+func pipelineWithLimit(maxOutput int64, cmds ...*exec.Cmd) (pipeLineOutput, collectedStandardError []byte, pipeLineError error) {
 	// Require at least one command
 	if len(cmds) < 1 {
 		return nil, nil, nil
 	}
 
-	// Collect the output from the command(s)
+	// Collect the output from the command(s), each command writes its stderr into its own buffer as exec copies
+	// it from a separate goroutine for every command
 	var output bytes.Buffer
-	var stderr bytes.Buffer
+	stderr := make([]bytes.Buffer, len(cmds))
 
 	last := len(cmds) - 1
 	for i, cmd := range cmds[:last] {
@@ -29,40 +44,198 @@ func Pipeline(cmds ...*exec.Cmd) (pipeLineOutput, collectedStandardError []byte,
 			return nil, nil, err
 		}
 		// Connect each command's stderr to a buffer
-		cmd.Stderr = &stderr
+		cmd.Stderr = &stderr[i]
 	}
 
 	// Connect the output and error for the last command
-	cmds[last].Stdout, cmds[last].Stderr = &output, &stderr
+	var stdout io.Reader
+	if maxOutput > 0 {
+		pipe, err := cmds[last].StdoutPipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		stdout = pipe
+	} else {
+		cmds[last].Stdout = &output
+	}
+	cmds[last].Stderr = &stderr[last]
 
 	// Start each command
-	for _, cmd := range cmds {
+	for i, cmd := range cmds {
 		if err := cmd.Start(); err != nil {
-			return output.Bytes(), stderr.Bytes(), err
+			for _, started := range cmds[:i] {
+				started.Process.Kill()
+				started.Wait()
+			}
+			return output.Bytes(), joinStderr(stderr), err
 		}
 	}
 
-	// Wait for each command to complete
+	// stdout has to be read before waiting, pipe is closed once command exits
+	if stdout != nil {
+		n, err := io.Copy(&output, io.LimitReader(stdout, maxOutput+1))
+		if err == nil && n > maxOutput {
+			err = fmt.Errorf("middleware output exceeds limit of %d bytes", maxOutput)
+		}
+		if err != nil {
+			for _, cmd := range cmds {
+				cmd.Process.Kill()
+			}
+			for _, cmd := range cmds {
+				cmd.Wait()
+			}
+			return nil, joinStderr(stderr), err
+		}
+	}
+
+	// Wait for each command to complete, stderr buffers are only read once all of them are done
+	var err error
 	for _, cmd := range cmds {
-		if err := cmd.Wait(); err != nil {
-			return output.Bytes(), stderr.Bytes(), err
+		if waitErr := cmd.Wait(); waitErr != nil && err == nil {
+			err = waitErr
 		}
 	}
 
 	// Return the pipeline output and the collected standard error
-	return output.Bytes(), stderr.Bytes(), nil
+	return output.Bytes(), joinStderr(stderr), err
+}
+
+// joinStderr - standard error of pipeline commands in their order
+func joinStderr(buffers []bytes.Buffer) []byte {
+	var stderr []byte
+	for i := range buffers {
+		stderr = append(stderr, buffers[i].Bytes()...)
+	}
+	return stderr
 }
 
 // ExecuteMiddleware - takes command (middleware string) and payload, which is passed to middleware
 func ExecuteMiddleware(middlewares string, payload models.Payload) (models.Payload, error) {
+	newPayload, _, err := executeMiddleware(middlewares, payload, middlewareLimits{})
+	return newPayload, err
+}
+
+// middlewareRun - middleware stdin and stdout together with stderr and exit code of middleware process, exit code
+// is nil when process didn't exit i.e. it couldn't be started or middleware doesn't run as a process
+type middlewareRun struct {
+	Stdin    []byte
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode *int
+}
+
+// exitCodeOf - exit code of middleware process that finished with given error
+func exitCodeOf(err error) *int {
+	code := 0
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return nil
+		}
+		code = exitErr.ExitCode()
+	}
+	return &code
+}
+
+// middlewareLimits - how long middleware process can run, how many times it is started again after it timed out and
+// how much it can write to stdout. Socket middleware is long running and isn't affected. CompressPayload gzip
+// compresses payload written to middleware process, see compressMiddlewarePayload.
+type middlewareLimits struct {
+	Timeout         time.Duration
+	Retries         int
+	MaxOutputBytes  int64
+	CompressPayload bool
+}
+
+// middlewareLimits - middleware timeout, retries and output limit from configuration
+func (c *Configuration) middlewareLimits() middlewareLimits {
+	return middlewareLimits{
+		Timeout:         c.MiddlewareTimeout,
+		Retries:         c.MiddlewareRetries,
+		MaxOutputBytes:  c.MaxMiddlewareOutputBytes,
+		CompressPayload: c.MiddlewareCompressPayload,
+	}
+}
+
+// middlewareCommand - creates middleware process, replaced in tests
+var middlewareCommand = exec.CommandContext
+
+// executeMiddleware - executes middleware, returns new payload together with what was exchanged with middleware
+func executeMiddleware(middlewares string, payload models.Payload, limits middlewareLimits) (models.Payload, middlewareRun, error) {
+	if strings.HasPrefix(middlewares, middlewarePluginPrefix) {
+		newPayload, err := executePluginMiddleware(middlewares, payload)
+		return newPayload, middlewareRun{}, err
+	}
+	if strings.HasPrefix(middlewares, luaMiddlewarePrefix) {
+		newPayload, err := executeLuaMiddleware(middlewares, payload)
+		return newPayload, middlewareRun{}, err
+	}
+	if strings.HasPrefix(middlewares, middlewareSocketPrefix) {
+		newPayload, sent, received, err := executeSocketMiddleware(middlewares, payload)
+		return newPayload, middlewareRun{Stdin: sent, Stdout: received}, err
+	}
+	if p, ok := pooledMiddleware(middlewares); ok {
+		return p.execute(payload, limits)
+	}
 
-	mws := strings.Split(middlewares, "|")
+	return runMiddlewareChainWithLimits(payload, strings.Split(middlewares, "|"), limits)
+}
+
+// runMiddlewareChainWithLimits - kills middleware chain that runs longer than timeout and runs it again, error with
+// ErrorCodeMiddlewareTimeout is returned once all retries timed out
+func runMiddlewareChainWithLimits(payload models.Payload, mws []string, limits middlewareLimits) (models.Payload, middlewareRun, error) {
+	return retryMiddlewareOnTimeout(payload, mws, limits, func(ctx context.Context) (models.Payload, middlewareRun, error) {
+		return runMiddlewareChainContext(ctx, payload, mws, limits.MaxOutputBytes, limits.CompressPayload)
+	})
+}
+
+// retryMiddlewareOnTimeout - runs middleware with context that is done after limits.Timeout, middleware is run
+// again when it timed out, error with ErrorCodeMiddlewareTimeout is returned once all retries timed out
+func retryMiddlewareOnTimeout(payload models.Payload, mws []string, limits middlewareLimits, attempt func(ctx context.Context) (models.Payload, middlewareRun, error)) (models.Payload, middlewareRun, error) {
+	if limits.Timeout <= 0 {
+		return attempt(context.Background())
+	}
+
+	for attempts := 1; ; attempts++ {
+		ctx, cancel := context.WithTimeout(context.Background(), limits.Timeout)
+		newPayload, run, err := attempt(ctx)
+		timedOut := ctx.Err() == context.DeadlineExceeded
+		cancel()
+
+		if !timedOut {
+			return newPayload, run, err
+		}
+		if attempts > limits.Retries {
+			return payload, run, withErrorCode(ErrorCodeMiddlewareTimeout,
+				fmt.Errorf("middleware didn't finish within %s, attempts: %d", limits.Timeout, attempts))
+		}
+
+		log.WithFields(log.Fields{
+			"middlewares": mws,
+			"timeout":     limits.Timeout.String(),
+			"attempt":     attempts,
+		}).Warn("middleware timed out, retrying")
+	}
+}
+
+// runMiddlewareChain - executes middleware commands as a pipeline, stdout of each command is passed as stdin to
+// the next one. Returns payload produced by the last command together with pipeline stdin and stdout, chain is
+// aborted when any of the commands fails.
+func runMiddlewareChain(payload models.Payload, mws []string) (models.Payload, []byte, []byte, error) {
+	newPayload, run, err := runMiddlewareChainContext(context.Background(), payload, mws, 0, false)
+	return newPayload, run.Stdin, run.Stdout, err
+}
+
+// runMiddlewareChainContext - runMiddlewareChain, middleware processes are killed when context is done or when
+// pipeline writes more than maxOutput bytes. With compress, pipeline gets gzip compressed payload and is expected
+// to write it compressed as well.
+func runMiddlewareChainContext(ctx context.Context, payload models.Payload, mws []string, maxOutput int64, compress bool) (models.Payload, middlewareRun, error) {
 	var cmdList []*exec.Cmd
 
 	for _, v := range mws {
 		commands := strings.Split(strings.TrimSpace(v), " ")
 
-		cmd := exec.Command(commands[0], commands[1:]...)
+		cmd := middlewareCommand(ctx, commands[0], commands[1:]...)
 		cmdList = append(cmdList, cmd)
 	}
 
@@ -81,14 +254,33 @@ func ExecuteMiddleware(middlewares string, payload models.Payload) (models.Paylo
 		log.WithFields(log.Fields{
 			"error": err.Error(),
 		}).Error("Failed to marshal json")
-		return payload, err
+		return payload, middlewareRun{}, err
 	}
 
-	//
-	cmdList[0].Stdin = bytes.NewReader(bts)
+	stdin := bts
+	if compress {
+		if stdin, err = compressMiddlewarePayload(bts); err != nil {
+			return payload, middlewareRun{Stdin: bts}, err
+		}
+	}
+	cmdList[0].Stdin = bytes.NewReader(stdin)
 
 	// Run the pipeline
-	mwOutput, stderr, err := Pipeline(cmdList...)
+	mwOutput, stderr, err := pipelineWithLimit(maxOutput, cmdList...)
+	exitCode := exitCodeOf(err)
+
+	// exchange is recorded uncompressed
+	if err == nil && compress {
+		mwOutput, err = decompressMiddlewareOutput(mwOutput)
+	}
+
+	return middlewareResult(payload, mws, middlewareRun{Stdin: bts, Stdout: mwOutput, Stderr: stderr, ExitCode: exitCode}, err)
+}
+
+// middlewareResult - logs middleware errors and stderr, returns payload produced by middleware or original
+// payload when middleware failed or didn't write anything
+func middlewareResult(payload models.Payload, mws []string, run middlewareRun, err error) (models.Payload, middlewareRun, error) {
+	mwOutput, stderr := run.Stdout, run.Stderr
 
 	// middleware failed to execute
 	if err != nil {
@@ -102,7 +294,7 @@ func ExecuteMiddleware(middlewares string, payload models.Payload) (models.Paylo
 				"error": err.Error(),
 			}).Error("Middleware error")
 		}
-		return payload, err
+		return payload, run, err
 	}
 
 	// log stderr, middleware executed successfully
@@ -125,13 +317,13 @@ func ExecuteMiddleware(middlewares string, payload models.Payload) (models.Paylo
 		} else {
 			if log.GetLevel() == log.DebugLevel {
 				log.WithFields(log.Fields{
-					"middlewares": middlewares,
-					"count":       len(middlewares),
+					"middlewares": mws,
+					"count":       len(mws),
 					"payload":     string(mwOutput),
 				}).Debug("payload after modifications")
 			}
 			// payload unmarshalled into Payload struct, returning it
-			return newPayloadView.ConvertToPayload(), nil
+			return newPayloadView.ConvertToPayload(), run, nil
 		}
 	} else {
 
@@ -140,6 +332,35 @@ func ExecuteMiddleware(middlewares string, payload models.Payload) (models.Paylo
 		}).Warn("No response from middleware.")
 	}
 
-	return payload, nil
+	return payload, run, nil
+
+}
+
+var errMiddlewareTooLarge = errors.New("middleware exceeds maximum allowed size")
+
+// saveMiddleware - stores middleware in given directory as an executable file and returns its absolute path,
+// file is only left in place once it was written completely
+func saveMiddleware(r io.Reader, dir string, maxSize int64) (string, error) {
+	f, err := ioutil.TempFile(dir, "hoverfly_middleware_")
+	if err != nil {
+		return "", err
+	}
+
+	n, err := io.Copy(f, io.LimitReader(r, maxSize+1))
+	if err == nil && n > maxSize {
+		err = errMiddlewareTooLarge
+	}
+	if err == nil {
+		err = f.Chmod(0755)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
 
+	return filepath.Abs(f.Name())
 }
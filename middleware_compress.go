@@ -0,0 +1,53 @@
+package hoverfly
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// MiddlewareContentEncodingGzip - content encoding of compressed middleware payloads
+const MiddlewareContentEncodingGzip = "gzip"
+
+// compressMiddlewarePayload - gzip compresses JSON payload and wraps it in envelope, i.e.
+// {"contentEncoding": "gzip", "payload": "<base64 of gzipped JSON>"}
+func compressMiddlewarePayload(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(models.MiddlewareEnvelope{ContentEncoding: MiddlewareContentEncodingGzip, Payload: buf.Bytes()})
+}
+
+// decompressMiddlewareOutput - returns JSON payload from envelope middleware wrote, output without content
+// encoding is plain payload and is returned as it is so that middleware can answer uncompressed
+func decompressMiddlewareOutput(output []byte) ([]byte, error) {
+	if len(output) == 0 {
+		return output, nil
+	}
+
+	var envelope models.MiddlewareEnvelope
+	if err := json.Unmarshal(output, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse middleware output envelope: %s", err.Error())
+	}
+
+	switch envelope.ContentEncoding {
+	case "":
+		return output, nil
+	case MiddlewareContentEncodingGzip:
+		payload, err := gunzip(envelope.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress middleware output: %s", err.Error())
+		}
+		return payload, nil
+	default:
+		return nil, fmt.Errorf("middleware output has unsupported content encoding '%s'", envelope.ContentEncoding)
+	}
+}
@@ -0,0 +1,124 @@
+package hoverfly
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// writeMiddleware - writes middleware script to temporary directory that is removed once test finishes
+func writeMiddleware(t *testing.T, script string) string {
+	path := filepath.Join(middlewareTestDir(t), "middleware.py")
+	testutil.Expect(t, ioutil.WriteFile(path, []byte(script), 0755), nil)
+	return path
+}
+
+func middlewareTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "middleware-compress")
+	testutil.Expect(t, err, nil)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// compressingMiddleware - python middleware that reads gzip compressed envelope and answers with compressed payload
+// that has status changed to 201
+const compressingMiddleware = `#!/usr/bin/env python
+import base64, gzip, json, sys
+
+envelope = json.load(sys.stdin)
+assert envelope["contentEncoding"] == "gzip"
+payload = json.loads(gzip.decompress(base64.b64decode(envelope["payload"])))
+payload["response"]["status"] = 201
+payload["response"]["body"] = payload["response"]["body"].upper()
+compressed = gzip.compress(json.dumps(payload).encode())
+json.dump({"contentEncoding": "gzip", "payload": base64.b64encode(compressed).decode()}, sys.stdout)
+`
+
+func TestCompressMiddlewarePayloadRoundTrip(t *testing.T) {
+	payload := []byte(`{"response": {"status": 200, "body": "` + strings.Repeat("large body ", 10000) + `"}}`)
+
+	compressed, err := compressMiddlewarePayload(payload)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(compressed) < len(payload)/10, true)
+
+	var envelope models.MiddlewareEnvelope
+	testutil.Expect(t, json.Unmarshal(compressed, &envelope), nil)
+	testutil.Expect(t, envelope.ContentEncoding, "gzip")
+
+	decompressed, err := decompressMiddlewareOutput(compressed)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(decompressed), string(payload))
+}
+
+func TestDecompressMiddlewareOutputAcceptsPlainPayload(t *testing.T) {
+	plain := []byte(`{"response": {"status": 201}}`)
+	output, err := decompressMiddlewareOutput(plain)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(output), string(plain))
+
+	_, err = decompressMiddlewareOutput([]byte(`{"contentEncoding": "br", "payload": ""}`))
+	testutil.Refute(t, err, nil)
+	_, err = decompressMiddlewareOutput([]byte(`{"contentEncoding": "gzip", "payload": "bm90IGd6aXA="}`))
+	testutil.Refute(t, err, nil)
+}
+
+func TestMiddlewareCompressPayload(t *testing.T) {
+	middleware := writeMiddleware(t, compressingMiddleware)
+	payload := models.Payload{Response: models.ResponseDetails{Status: 200, Body: strings.Repeat("body", 1000)}}
+
+	newPayload, run, err := executeMiddleware(middleware, payload, middlewareLimits{CompressPayload: true})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, newPayload.Response.Status, 201)
+	testutil.Expect(t, newPayload.Response.Body, strings.Repeat("BODY", 1000))
+
+	// exchange is recorded uncompressed
+	var sent models.PayloadView
+	testutil.Expect(t, json.Unmarshal(run.Stdin, &sent), nil)
+	testutil.Expect(t, sent.Response.Body, payload.Response.Body)
+	var received models.PayloadView
+	testutil.Expect(t, json.Unmarshal(run.Stdout, &received), nil)
+	testutil.Expect(t, received.Response.Status, 201)
+}
+
+func TestMiddlewareCompressPayloadWritesEnvelope(t *testing.T) {
+	stdinPath := filepath.Join(middlewareTestDir(t), "stdin")
+	original := middlewareCommand
+	middlewareCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "tee", stdinPath)
+	}
+	t.Cleanup(func() { middlewareCommand = original })
+
+	payload := models.Payload{Response: models.ResponseDetails{Status: 200, Body: "echoed"}}
+	newPayload, _, err := executeMiddleware("./middleware.py", payload, middlewareLimits{CompressPayload: true})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, newPayload.Response.Body, "echoed")
+
+	stdin, err := ioutil.ReadFile(stdinPath)
+	testutil.Expect(t, err, nil)
+	var envelope models.MiddlewareEnvelope
+	testutil.Expect(t, json.Unmarshal(stdin, &envelope), nil)
+	testutil.Expect(t, envelope.ContentEncoding, MiddlewareContentEncodingGzip)
+	testutil.Expect(t, envelope.Payload[0], byte(0x1f))
+	testutil.Expect(t, envelope.Payload[1], byte(0x8b))
+}
+
+func TestMiddlewarePoolCompressPayload(t *testing.T) {
+	middleware := writeMiddleware(t, compressingMiddleware)
+	p, err := NewMiddlewarePool(middleware, 1)
+	testutil.Expect(t, err, nil)
+	defer p.Close()
+	p.CompressPayload = true
+
+	newPayload, err := ExecuteMiddleware(middleware, models.Payload{Response: models.ResponseDetails{Status: 200, Body: "pooled"}})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, newPayload.Response.Status, 201)
+	testutil.Expect(t, newPayload.Response.Body, "POOLED")
+}
@@ -0,0 +1,78 @@
+package hoverfly
+
+import (
+	"context"
+	"net/http"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// mockMiddlewareCommand - replaces middleware processes with given command
+func mockMiddlewareCommand(t *testing.T, command string, args ...string) {
+	original := middlewareCommand
+	middlewareCommand = func(ctx context.Context, name string, _ ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, command, args...)
+	}
+	t.Cleanup(func() { middlewareCommand = original })
+}
+
+func TestMiddlewareOutputLimitKillsRunawayMiddleware(t *testing.T) {
+	// writes to stdout until it is killed
+	mockMiddlewareCommand(t, "yes")
+
+	payload := models.Payload{Response: models.ResponseDetails{Status: 201, Body: "original body"}}
+	limits := middlewareLimits{MaxOutputBytes: 1024}
+
+	start := time.Now()
+	newPayload, run, err := executeMiddleware("./middleware.py", payload, limits)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, strings.Contains(err.Error(), "1024 bytes"), true)
+	testutil.Expect(t, time.Since(start) < 10*time.Second, true)
+	testutil.Expect(t, newPayload.Response.Body, "original body")
+	testutil.Expect(t, len(run.Stdout), 0)
+}
+
+func TestMiddlewareOutputWithinLimit(t *testing.T) {
+	mockMiddlewareCommand(t, "cat")
+
+	payload := models.Payload{Response: models.ResponseDetails{Status: 201, Body: "original body"}}
+
+	newPayload, _, err := executeMiddleware("./middleware.py", payload, middlewareLimits{MaxOutputBytes: 10 * 1024})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, newPayload.Response.Body, "original body")
+
+	// output is just as large as the limit
+	newPayload, _, err = executeMiddleware("./middleware.py", payload, middlewareLimits{MaxOutputBytes: int64(len(middlewareStdout(t, payload)))})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, newPayload.Response.Body, "original body")
+}
+
+// middlewareStdout - stdout of mocked middleware when output isn't limited
+func middlewareStdout(t *testing.T, payload models.Payload) []byte {
+	_, run, err := executeMiddleware("./middleware.py", payload, middlewareLimits{})
+	testutil.Expect(t, err, nil)
+	return run.Stdout
+}
+
+func TestMiddlewareOutputLimitFailsModifyMode(t *testing.T) {
+	mockMiddlewareCommand(t, "yes")
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.Middleware = "./middleware.py"
+	dbClient.Cfg.MaxMiddlewareOutputBytes = 1024
+	dbClient.Cfg.SetMode(ModifyMode)
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode >= 500, true)
+	testutil.Expect(t, resp.Header.Get(ErrorCodeHeader), string(ErrorCodeMiddlewareFailed))
+}
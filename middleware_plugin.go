@@ -0,0 +1,78 @@
+package hoverfly
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// MiddlewarePluginSymbol - symbol middleware plugin has to export, a function or a variable of MiddlewarePluginFunc
+// type, i.e.:
+//
+//	func MiddlewareFunc(pair *models.MiddlewarePair) error {
+//		pair.Response.Status = 201
+//		return nil
+//	}
+const MiddlewarePluginSymbol = "MiddlewareFunc"
+
+// middlewarePluginPrefix - prefix of middleware that is served by loaded plugin
+const middlewarePluginPrefix = "plugin:"
+
+// MiddlewarePluginFunc - middleware loaded from Go plugin, it modifies request and response in place
+type MiddlewarePluginFunc func(*models.MiddlewarePair) error
+
+// middlewarePlugins - loaded plugins keyed on path, Go plugins can't be unloaded so they are kept once loaded
+var middlewarePlugins = struct {
+	sync.Mutex
+	m map[string]MiddlewarePluginFunc
+}{m: make(map[string]MiddlewarePluginFunc)}
+
+// LoadMiddlewarePlugin - loads middleware from Go plugin (.so) built with '-buildmode=plugin', plugin is used
+// instead of middleware executables while it is set. Plugins are only supported on Linux and macOS.
+func (d *Hoverfly) LoadMiddlewarePlugin(path string) error {
+	fn, err := openMiddlewarePlugin(path)
+	if err != nil {
+		return fmt.Errorf("failed to load middleware plugin '%s': %s", path, err.Error())
+	}
+
+	middlewarePlugins.Lock()
+	middlewarePlugins.m[path] = fn
+	middlewarePlugins.Unlock()
+
+	d.Cfg.SetMiddlewarePlugin(path)
+
+	log.WithFields(log.Fields{
+		"plugin": path,
+	}).Info("middleware plugin loaded")
+	return nil
+}
+
+// executePluginMiddleware - passes payload to loaded plugin, plugin panics are returned as errors
+func executePluginMiddleware(middleware string, payload models.Payload) (_ models.Payload, err error) {
+	path := strings.TrimPrefix(middleware, middlewarePluginPrefix)
+
+	middlewarePlugins.Lock()
+	fn, ok := middlewarePlugins.m[path]
+	middlewarePlugins.Unlock()
+
+	if !ok {
+		return payload, fmt.Errorf("middleware plugin %s is not loaded", path)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("middleware plugin %s panicked: %v", path, r)
+		}
+	}()
+
+	pair := &models.MiddlewarePair{Request: payload.Request, Response: payload.Response}
+	if err := fn(pair); err != nil {
+		return payload, err
+	}
+	payload.Request = pair.Request
+	payload.Response = pair.Response
+	return payload, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package hoverfly
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// openMiddlewarePlugin - Go plugins aren't supported on this platform
+func openMiddlewarePlugin(path string) (MiddlewarePluginFunc, error) {
+	return nil, fmt.Errorf("middleware plugins are not supported on %s", runtime.GOOS)
+}
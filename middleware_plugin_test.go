@@ -0,0 +1,126 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+var middlewarePluginBuild struct {
+	sync.Once
+	// dir - removed by TestMain, loaded plugin has to stay in place until the tests finish
+	dir     string
+	path    string
+	out     []byte
+	err     error
+	loadErr error
+}
+
+// buildMiddlewarePlugin - builds testdata/middleware_plugin once per test run, plugin with the same package path
+// can't be loaded twice from different files. Tests are skipped when the plugin can't be built or loaded, i.e. when
+// it is built by a different toolchain or with different flags such as -race.
+func buildMiddlewarePlugin(t *testing.T) string {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skipf("middleware plugins are not supported on %s", runtime.GOOS)
+	}
+
+	b := &middlewarePluginBuild
+	b.Do(func() {
+		b.dir, b.err = ioutil.TempDir("", "hoverfly-test-middleware-plugin")
+		if b.err != nil {
+			return
+		}
+		b.path = filepath.Join(b.dir, "middleware_plugin.so")
+		b.out, b.err = exec.Command("go", "build", "-buildmode=plugin", "-o", b.path, "./testdata/middleware_plugin").CombinedOutput()
+		if b.err == nil {
+			_, b.loadErr = openMiddlewarePlugin(b.path)
+		}
+	})
+	if b.err != nil {
+		t.Skipf("failed to build middleware plugin: %s %s", b.err.Error(), b.out)
+	}
+	if b.loadErr != nil {
+		t.Skipf("failed to load middleware plugin: %s", b.loadErr.Error())
+	}
+	return b.path
+}
+
+func TestLoadMiddlewarePluginModify(t *testing.T) {
+	path := buildMiddlewarePlugin(t)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	// plugin takes precedence over executable middleware
+	dbClient.Cfg.Middleware = "./examples/middleware/modify_request/modify_request.py"
+
+	if err := dbClient.LoadMiddlewarePlugin(path); err != nil {
+		t.Fatal(err)
+	}
+	testutil.Expect(t, dbClient.Cfg.GetMiddleware(), middlewarePluginPrefix+path)
+
+	req, err := http.NewRequest("GET", "http://very-interesting-website.com/items", nil)
+	testutil.Expect(t, err, nil)
+
+	response, err := dbClient.modifyRequestResponse(req, dbClient.Cfg.GetMiddleware())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.Expect(t, response.StatusCode, 201)
+
+	body, err := ioutil.ReadAll(response.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "modified by plugin: /items")
+}
+
+func TestLoadMiddlewarePluginSynthesize(t *testing.T) {
+	path := buildMiddlewarePlugin(t)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	if err := dbClient.LoadMiddlewarePlugin(path); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", "http://very-interesting-website.com/synthesized", nil)
+	testutil.Expect(t, err, nil)
+
+	response, err := dbClient.synthesizeResponse(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testutil.Expect(t, response.StatusCode, 201)
+
+	body, err := ioutil.ReadAll(response.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "modified by plugin: /synthesized")
+
+	// plugin errors fail the request
+	req, err = http.NewRequest("GET", "http://very-interesting-website.com/fail", nil)
+	testutil.Expect(t, err, nil)
+
+	_, err = dbClient.synthesizeResponse(req)
+	testutil.Refute(t, err, nil)
+}
+
+func TestLoadMiddlewarePluginMissingFile(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	err := dbClient.LoadMiddlewarePlugin("/does/not/exist.so")
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, dbClient.Cfg.MiddlewarePlugin, "")
+}
+
+func TestExecutePluginMiddlewareNotLoaded(t *testing.T) {
+	_, err := executePluginMiddleware(middlewarePluginPrefix+"/not/loaded.so", models.Payload{})
+	testutil.Refute(t, err, nil)
+}
@@ -0,0 +1,36 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package hoverfly
+
+import (
+	"fmt"
+	"plugin"
+
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// openMiddlewarePlugin - opens plugin and looks up its MiddlewarePluginSymbol
+func openMiddlewarePlugin(path string) (MiddlewarePluginFunc, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	symbol, err := p.Lookup(MiddlewarePluginSymbol)
+	if err != nil {
+		return nil, err
+	}
+
+	switch fn := symbol.(type) {
+	case func(*models.MiddlewarePair) error:
+		return fn, nil
+	case *func(*models.MiddlewarePair) error:
+		if *fn == nil {
+			return nil, fmt.Errorf("%s is nil", MiddlewarePluginSymbol)
+		}
+		return *fn, nil
+	default:
+		return nil, fmt.Errorf("%s should be func(*models.MiddlewarePair) error, got %T", MiddlewarePluginSymbol, symbol)
+	}
+}
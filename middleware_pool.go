@@ -0,0 +1,344 @@
+package hoverfly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// middlewarePoolRestartDelay - how long to wait before starting worker again when it failed to start
+const middlewarePoolRestartDelay = time.Second
+
+// MiddlewarePool - pre-started middleware processes. Middleware still reads one payload from stdin until it is
+// closed, so every request gets its own process which is replaced by a fresh one once the request is done. No more
+// than pool size processes run at once, requests wait when all of them are busy. Processes that exit before they
+// are used are replaced as well.
+type MiddlewarePool struct {
+	Middleware string
+	Size       int
+	// CompressPayload - payloads are exchanged gzip compressed, see Configuration.MiddlewareCompressPayload
+	CompressPayload bool
+
+	command []string
+	ready   chan *middlewareWorker
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// middlewareWorker - started middleware process waiting for payload on stdin. Stdout is read only once worker got
+// payload, worker that writes earlier blocks once pipe buffer is full.
+type middlewareWorker struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *os.File
+	stderr bytes.Buffer
+	done   chan struct{}
+	err    error
+}
+
+var middlewarePools = struct {
+	sync.Mutex
+	m map[string]*MiddlewarePool
+}{m: make(map[string]*MiddlewarePool)}
+
+// NewMiddlewarePool - starts given number of middleware processes, payloads for the same middleware are sent to
+// them instead of starting middleware per request. Middleware chains and socket middleware can't be pooled.
+func NewMiddlewarePool(middleware string, size int) (*MiddlewarePool, error) {
+	if strings.Contains(middleware, "|") || strings.HasPrefix(middleware, middlewareSocketPrefix) {
+		return nil, fmt.Errorf("middleware '%s' can't be pooled, only single middleware command can", middleware)
+	}
+	command := strings.Split(strings.TrimSpace(middleware), " ")
+	if command[0] == "" {
+		return nil, fmt.Errorf("middleware is required")
+	}
+	if size < 1 {
+		return nil, fmt.Errorf("middleware pool size has to be at least 1, got %d", size)
+	}
+
+	p := &MiddlewarePool{
+		Middleware: middleware,
+		Size:       size,
+		command:    command,
+		ready:      make(chan *middlewareWorker, size),
+		closed:     make(chan struct{}),
+	}
+
+	for i := 0; i < size; i++ {
+		w, err := p.startWorker()
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.ready <- w
+	}
+
+	middlewarePools.Lock()
+	middlewarePools.m[middleware] = p
+	middlewarePools.Unlock()
+
+	return p, nil
+}
+
+// Close - stops waiting middleware processes, requests that are in progress are finished
+func (p *MiddlewarePool) Close() {
+	p.once.Do(func() {
+		middlewarePools.Lock()
+		if middlewarePools.m[p.Middleware] == p {
+			delete(middlewarePools.m, p.Middleware)
+		}
+		middlewarePools.Unlock()
+
+		close(p.closed)
+		for {
+			select {
+			case w := <-p.ready:
+				w.stop()
+			default:
+				return
+			}
+		}
+	})
+}
+
+func (p *MiddlewarePool) startWorker() (*middlewareWorker, error) {
+	w := &middlewareWorker{
+		cmd:  exec.Command(p.command[0], p.command[1:]...),
+		done: make(chan struct{}),
+	}
+	w.cmd.Stderr = &w.stderr
+
+	stdin, err := w.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	w.stdin = stdin
+
+	// not a pipe created by cmd, Wait would close it before stdout is read
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	w.cmd.Stdout = stdoutWriter
+	w.stdout = stdout
+
+	err = w.cmd.Start()
+	stdoutWriter.Close()
+	if err != nil {
+		stdout.Close()
+		return nil, err
+	}
+
+	go func() {
+		w.err = w.cmd.Wait()
+		close(w.done)
+	}()
+
+	return w, nil
+}
+
+// replace - starts new worker in place of used or crashed one, starting is retried until it succeeds or pool
+// is closed so that pool doesn't shrink
+func (p *MiddlewarePool) replace() {
+	for {
+		w, err := p.startWorker()
+		if err == nil {
+			select {
+			case p.ready <- w:
+			case <-p.closed:
+				w.stop()
+			}
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"error":      err.Error(),
+			"middleware": p.Middleware,
+		}).Error("Failed to start pooled middleware")
+
+		select {
+		case <-time.After(middlewarePoolRestartDelay):
+		case <-p.closed:
+			return
+		}
+	}
+}
+
+// acquire - waits for worker that is still running
+func (p *MiddlewarePool) acquire() (*middlewareWorker, error) {
+	for {
+		select {
+		case w := <-p.ready:
+			select {
+			case <-w.done:
+				log.WithFields(log.Fields{
+					"middleware": p.Middleware,
+					"stderr":     w.stderr.String(),
+				}).Warn("Pooled middleware exited before it was used, replacing it")
+				w.stdout.Close()
+				go p.replace()
+			default:
+				return w, nil
+			}
+		case <-p.closed:
+			return nil, fmt.Errorf("middleware pool is closed")
+		}
+	}
+}
+
+// execute - sends payload to pooled middleware process and waits until it exits. Worker is killed when it runs
+// longer than limits.Timeout or writes more than limits.MaxOutputBytes, timed out payload is sent to another
+// worker limits.Retries times. Payload compression is set by the pool.
+func (p *MiddlewarePool) execute(payload models.Payload, limits middlewareLimits) (models.Payload, middlewareRun, error) {
+	return retryMiddlewareOnTimeout(payload, []string{p.Middleware}, limits, func(ctx context.Context) (models.Payload, middlewareRun, error) {
+		return p.executeContext(ctx, payload, limits.MaxOutputBytes)
+	})
+}
+
+// executeContext - sends payload to pooled middleware process, worker is killed when context is done or it writes
+// more than maxOutput bytes
+func (p *MiddlewarePool) executeContext(ctx context.Context, payload models.Payload, maxOutput int64) (models.Payload, middlewareRun, error) {
+	bts, err := json.Marshal(payload.ConvertToPayloadView())
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to marshal json")
+		return payload, middlewareRun{}, err
+	}
+	stdin := bts
+	if p.CompressPayload {
+		if stdin, err = compressMiddlewarePayload(bts); err != nil {
+			return payload, middlewareRun{Stdin: bts}, err
+		}
+	}
+
+	w, err := p.acquire()
+	if err != nil {
+		return payload, middlewareRun{Stdin: bts}, err
+	}
+	defer func() {
+		select {
+		case <-p.closed:
+		default:
+			go p.replace()
+		}
+	}()
+
+	// payload is written in the background, worker that doesn't read its stdin would block it
+	written := make(chan error, 1)
+	go func() {
+		_, err := w.stdin.Write(stdin)
+		if closeErr := w.stdin.Close(); err == nil {
+			err = closeErr
+		}
+		written <- err
+	}()
+
+	var output bytes.Buffer
+	read := make(chan error, 1)
+	go func() {
+		reader := io.Reader(w.stdout)
+		if maxOutput > 0 {
+			reader = io.LimitReader(w.stdout, maxOutput+1)
+		}
+		n, err := io.Copy(&output, reader)
+		if err == nil && maxOutput > 0 && n > maxOutput {
+			err = fmt.Errorf("middleware output exceeds limit of %d bytes", maxOutput)
+		}
+		read <- err
+	}()
+
+	select {
+	case err = <-read:
+	case <-ctx.Done():
+		w.cmd.Process.Kill()
+		// closing stdout stops read even when children of killed worker still hold the pipe
+		w.stdout.Close()
+		<-read
+		err = ctx.Err()
+	}
+	if err == nil {
+		select {
+		case <-w.done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	if err != nil {
+		w.cmd.Process.Kill()
+	}
+	<-w.done
+	w.stdout.Close()
+
+	if writeErr := <-written; err == nil {
+		err = writeErr
+	}
+	if err == nil {
+		err = w.err
+	}
+	if err != nil {
+		return middlewareResult(payload, []string{p.Middleware}, middlewareRun{Stdin: bts, Stderr: w.stderr.Bytes(), ExitCode: exitCodeOf(w.err)}, err)
+	}
+
+	stdout := output.Bytes()
+	if p.CompressPayload {
+		stdout, err = decompressMiddlewareOutput(stdout)
+	}
+
+	run := middlewareRun{Stdin: bts, Stdout: stdout, Stderr: w.stderr.Bytes(), ExitCode: exitCodeOf(w.err)}
+	return middlewareResult(payload, []string{p.Middleware}, run, err)
+}
+
+// stop - kills worker that wasn't used
+func (w *middlewareWorker) stop() {
+	w.stdin.Close()
+	w.stdout.Close()
+	select {
+	case <-w.done:
+	case <-time.After(middlewarePoolRestartDelay):
+		w.cmd.Process.Kill()
+		<-w.done
+	}
+}
+
+// pooledMiddleware - returns pool started for given middleware
+func pooledMiddleware(middleware string) (*MiddlewarePool, bool) {
+	middlewarePools.Lock()
+	defer middlewarePools.Unlock()
+	p, ok := middlewarePools.m[middleware]
+	return p, ok
+}
+
+// StartMiddlewarePool - pre-starts MiddlewarePoolSize processes of current middleware
+func (d *Hoverfly) StartMiddlewarePool() error {
+	p, err := NewMiddlewarePool(d.Cfg.GetMiddleware(), d.Cfg.MiddlewarePoolSize)
+	if err != nil {
+		return err
+	}
+	p.CompressPayload = d.Cfg.MiddlewareCompressPayload
+	d.mu.Lock()
+	d.middlewarePool = p
+	d.mu.Unlock()
+	return nil
+}
+
+// StopMiddlewarePool - stops pooled middleware processes if pool was started
+func (d *Hoverfly) StopMiddlewarePool() {
+	d.mu.Lock()
+	p := d.middlewarePool
+	d.middlewarePool = nil
+	d.mu.Unlock()
+
+	if p != nil {
+		p.Close()
+	}
+}
@@ -0,0 +1,223 @@
+package hoverfly
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// poolTestMiddleware - writes shell middleware that logs every start to a file and echoes payload back after
+// optional delay, returns middleware and log paths
+func poolTestMiddleware(t *testing.T, delay string) (string, string, func()) {
+	dir, err := ioutil.TempDir("", "middleware-pool")
+	testutil.Expect(t, err, nil)
+
+	logPath := filepath.Join(dir, "starts.log")
+	script := filepath.Join(dir, "echo.sh")
+	body := fmt.Sprintf("#!/bin/sh\necho started >> %s\nsleep %s\ncat\n", logPath, delay)
+	testutil.Expect(t, ioutil.WriteFile(script, []byte(body), 0755), nil)
+
+	return script, logPath, func() { os.RemoveAll(dir) }
+}
+
+// waitForStarts - waits until middleware was started given number of times
+func waitForStarts(t *testing.T, logPath string, starts int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		bts, _ := ioutil.ReadFile(logPath)
+		if strings.Count(string(bts), "started") >= starts {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("middleware wasn't started %d times", starts)
+}
+
+func TestMiddlewarePoolPreStartsProcesses(t *testing.T) {
+	middleware, logPath, cleanup := poolTestMiddleware(t, "0")
+	defer cleanup()
+
+	p, err := NewMiddlewarePool(middleware, 3)
+	testutil.Expect(t, err, nil)
+	defer p.Close()
+
+	// started before any request
+	waitForStarts(t, logPath, 3)
+
+	payload := models.Payload{Response: models.ResponseDetails{Status: 201, Body: "pooled"}}
+	newPayload, err := ExecuteMiddleware(middleware, payload)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, newPayload.Response.Status, 201)
+	testutil.Expect(t, newPayload.Response.Body, "pooled")
+
+	// used process is replaced
+	waitForStarts(t, logPath, 4)
+}
+
+func TestMiddlewarePoolModifiesPayload(t *testing.T) {
+	command := "./examples/middleware/modify_response/modify_response.py"
+	p, err := NewMiddlewarePool(command, 2)
+	testutil.Expect(t, err, nil)
+	defer p.Close()
+
+	for i := 0; i < 4; i++ {
+		newPayload, err := ExecuteMiddleware(command, models.Payload{Response: models.ResponseDetails{Status: 200, Body: "original"}})
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, newPayload.Response.Status, 201)
+		testutil.Expect(t, newPayload.Response.Body, "body was replaced by middleware\n")
+	}
+}
+
+func TestMiddlewarePoolReplacesCrashedWorkers(t *testing.T) {
+	middleware, _, cleanup := poolTestMiddleware(t, "0")
+	defer cleanup()
+
+	p, err := NewMiddlewarePool(middleware, 1)
+	testutil.Expect(t, err, nil)
+	defer p.Close()
+
+	// worker dies while waiting for a request
+	w := <-p.ready
+	w.cmd.Process.Kill()
+	<-w.done
+	p.ready <- w
+
+	newPayload, _, err := p.execute(models.Payload{Response: models.ResponseDetails{Status: 202}}, middlewareLimits{})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, newPayload.Response.Status, 202)
+}
+
+func TestMiddlewarePoolBlocksWhenExhausted(t *testing.T) {
+	middleware, _, cleanup := poolTestMiddleware(t, "0.2")
+	defer cleanup()
+
+	p, err := NewMiddlewarePool(middleware, 1)
+	testutil.Expect(t, err, nil)
+	defer p.Close()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := p.execute(models.Payload{Response: models.ResponseDetails{Status: 200}}, middlewareLimits{})
+			testutil.Expect(t, err, nil)
+		}()
+	}
+	wg.Wait()
+
+	// the second request waited for the first one and for its replacement process
+	testutil.Expect(t, time.Since(start) >= 400*time.Millisecond, true)
+}
+
+func TestNewMiddlewarePoolRejectsChainsAndSockets(t *testing.T) {
+	_, err := NewMiddlewarePool("./a.sh | ./b.sh", 2)
+	testutil.Refute(t, err, nil)
+
+	_, err = NewMiddlewarePool(middlewareSocketPrefix+"/tmp/mw.sock", 2)
+	testutil.Refute(t, err, nil)
+
+	_, err = NewMiddlewarePool("", 2)
+	testutil.Refute(t, err, nil)
+
+	_, err = NewMiddlewarePool("./does-not-exist.sh", 2)
+	testutil.Refute(t, err, nil)
+}
+
+func TestStopMiddlewarePool(t *testing.T) {
+	middleware, _, cleanup := poolTestMiddleware(t, "0")
+	defer cleanup()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.Middleware = middleware
+	dbClient.Cfg.MiddlewarePoolSize = 2
+	testutil.Expect(t, dbClient.StartMiddlewarePool(), nil)
+
+	_, ok := pooledMiddleware(middleware)
+	testutil.Expect(t, ok, true)
+
+	dbClient.StopMiddlewarePool()
+	_, ok = pooledMiddleware(middleware)
+	testutil.Expect(t, ok, false)
+}
+
+// poolTestScript - writes shell middleware with given body, '$LOG' is a file that can be used to count starts
+func poolTestScript(t *testing.T, body string) (string, func()) {
+	dir, err := ioutil.TempDir("", "middleware-pool")
+	testutil.Expect(t, err, nil)
+
+	script := filepath.Join(dir, "middleware.sh")
+	body = fmt.Sprintf("#!/bin/sh\nLOG=%s\n%s\n", filepath.Join(dir, "starts.log"), body)
+	testutil.Expect(t, ioutil.WriteFile(script, []byte(body), 0755), nil)
+
+	return script, func() { os.RemoveAll(dir) }
+}
+
+func TestMiddlewarePoolTimeoutKillsHangingWorker(t *testing.T) {
+	middleware, cleanup := poolTestScript(t, "exec sleep 60")
+	defer cleanup()
+
+	p, err := NewMiddlewarePool(middleware, 1)
+	testutil.Expect(t, err, nil)
+	defer p.Close()
+
+	payload := models.Payload{Response: models.ResponseDetails{Status: 201, Body: "original body"}}
+	limits := middlewareLimits{Timeout: 200 * time.Millisecond, Retries: 1}
+
+	start := time.Now()
+	newPayload, _, err := p.execute(payload, limits)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, errorCodeOf(err, ErrorCodeUnknown), ErrorCodeMiddlewareTimeout)
+	testutil.Expect(t, time.Since(start) < 10*time.Second, true)
+	testutil.Expect(t, newPayload.Response.Body, "original body")
+}
+
+func TestMiddlewarePoolTimeoutRetries(t *testing.T) {
+	// the first two workers hang
+	middleware, cleanup := poolTestScript(t, `echo started >> $LOG
+if [ $(grep -c started $LOG) -le 2 ]; then exec sleep 60; fi
+exec cat`)
+	defer cleanup()
+
+	p, err := NewMiddlewarePool(middleware, 2)
+	testutil.Expect(t, err, nil)
+	defer p.Close()
+
+	payload := models.Payload{Response: models.ResponseDetails{Status: 201, Body: "original body"}}
+	limits := middlewareLimits{Timeout: 500 * time.Millisecond, Retries: 2}
+
+	newPayload, _, err := p.execute(payload, limits)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, newPayload.Response.Body, "original body")
+}
+
+func TestMiddlewarePoolOutputLimitKillsRunawayWorker(t *testing.T) {
+	// writes to stdout until it is killed
+	middleware, cleanup := poolTestScript(t, "exec yes")
+	defer cleanup()
+
+	p, err := NewMiddlewarePool(middleware, 1)
+	testutil.Expect(t, err, nil)
+	defer p.Close()
+
+	payload := models.Payload{Response: models.ResponseDetails{Status: 201, Body: "original body"}}
+
+	start := time.Now()
+	newPayload, run, err := p.execute(payload, middlewareLimits{MaxOutputBytes: 1024})
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, strings.Contains(err.Error(), "1024 bytes"), true)
+	testutil.Expect(t, time.Since(start) < 10*time.Second, true)
+	testutil.Expect(t, newPayload.Response.Body, "original body")
+	testutil.Expect(t, len(run.Stdout), 0)
+}
@@ -0,0 +1,347 @@
+package hoverfly
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// middlewareSocketPrefix - middleware strings with this prefix are sent to middleware connected to the socket
+// at the path that follows the prefix instead of being executed
+const middlewareSocketPrefix = "unix:"
+
+// DefaultMiddlewareSocketTimeout - how long to wait for middleware response on the socket
+const DefaultMiddlewareSocketTimeout = 30 * time.Second
+
+// maxMiddlewareFrameSize - frames larger than this are rejected
+const maxMiddlewareFrameSize = 64 << 20
+
+// middlewareFrame - JSON message exchanged with socket middleware, every frame is prefixed with its length as
+// 4 byte big endian integer. Hoverfly sends payloads with unique IDs and middleware replies with the same ID,
+// so that many requests can be in flight at once. Frames without ID are notifications from middleware.
+type middlewareFrame struct {
+	ID      uint64              `json:"id,omitempty"`
+	Payload *models.PayloadView `json:"payload,omitempty"`
+	Error   string              `json:"error,omitempty"`
+	Message string              `json:"message,omitempty"`
+}
+
+// middlewareReply - reply frame together with raw bytes for the timeline
+type middlewareReply struct {
+	frame middlewareFrame
+	raw   []byte
+}
+
+// pendingMiddlewareRequest - request waiting for reply on the connection it was sent over
+type pendingMiddlewareRequest struct {
+	conn net.Conn
+	ch   chan middlewareReply
+}
+
+// MiddlewareSocket - Unix domain socket that middleware daemon connects to, payloads are sent to the most
+// recently connected middleware. Previous connection is closed and its pending requests fail.
+type MiddlewareSocket struct {
+	Path    string
+	Timeout time.Duration
+
+	listener net.Listener
+
+	mu      sync.Mutex
+	conn    net.Conn
+	writeMu sync.Mutex
+	nextID  uint64
+	pending map[uint64]pendingMiddlewareRequest
+}
+
+var middlewareSockets = struct {
+	sync.Mutex
+	m map[string]*MiddlewareSocket
+}{m: make(map[string]*MiddlewareSocket)}
+
+// NewMiddlewareSocket - creates socket at given path and starts accepting middleware connections, stale socket
+// file left by previous run is removed. Only the user running Hoverfly can connect to the socket.
+func NewMiddlewareSocket(path string) (*MiddlewareSocket, error) {
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// socket is created with umask permissions, other users could read and modify every payload
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	s := &MiddlewareSocket{
+		Path:     path,
+		Timeout:  DefaultMiddlewareSocketTimeout,
+		listener: listener,
+		pending:  make(map[uint64]pendingMiddlewareRequest),
+	}
+
+	middlewareSockets.Lock()
+	middlewareSockets.m[path] = s
+	middlewareSockets.Unlock()
+
+	go s.accept()
+
+	return s, nil
+}
+
+// Middleware - middleware string that makes payloads go to this socket
+func (s *MiddlewareSocket) Middleware() string {
+	return middlewareSocketPrefix + s.Path
+}
+
+// Close - stops accepting middleware connections and removes the socket, pending requests fail
+func (s *MiddlewareSocket) Close() error {
+	middlewareSockets.Lock()
+	delete(middlewareSockets.m, s.Path)
+	middlewareSockets.Unlock()
+
+	err := s.listener.Close()
+
+	s.mu.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.mu.Unlock()
+
+	return err
+}
+
+func (s *MiddlewareSocket) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"socket": s.Path,
+		}).Info("Middleware connected")
+
+		s.mu.Lock()
+		previous := s.conn
+		s.conn = conn
+		if previous != nil {
+			// replies can't be trusted to arrive over replaced connection
+			s.failPending(previous, "middleware connection was replaced")
+		}
+		s.mu.Unlock()
+
+		if previous != nil {
+			previous.Close()
+		}
+
+		go s.read(conn)
+	}
+}
+
+// read - dispatches replies to waiting requests until connection is closed
+func (s *MiddlewareSocket) read(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		if s.conn == conn {
+			s.conn = nil
+		}
+		// requests sent over this connection won't get replies
+		s.failPending(conn, "middleware disconnected")
+		s.mu.Unlock()
+
+		log.WithFields(log.Fields{
+			"socket": s.Path,
+		}).Info("Middleware disconnected")
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		raw, err := readMiddlewareFrame(r)
+		if err != nil {
+			if err != io.EOF {
+				log.WithFields(log.Fields{
+					"error":  err.Error(),
+					"socket": s.Path,
+				}).Error("Failed to read frame from middleware")
+			}
+			return
+		}
+
+		var frame middlewareFrame
+		if err := json.Unmarshal(raw, &frame); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"frame": string(raw),
+			}).Error("Failed to unmarshal JSON from middleware")
+			continue
+		}
+
+		if frame.ID == 0 {
+			log.WithFields(log.Fields{
+				"socket":  s.Path,
+				"message": frame.Message,
+			}).Info("Notification from middleware")
+			continue
+		}
+
+		s.mu.Lock()
+		request, ok := s.pending[frame.ID]
+		delete(s.pending, frame.ID)
+		s.mu.Unlock()
+
+		if !ok {
+			log.WithFields(log.Fields{
+				"id": frame.ID,
+			}).Warn("Got middleware reply for unknown request")
+			continue
+		}
+		request.ch <- middlewareReply{frame: frame, raw: raw}
+	}
+}
+
+// failPending - replies with error to every request sent over given connection, s.mu has to be held
+func (s *MiddlewareSocket) failPending(conn net.Conn, reason string) {
+	for id, request := range s.pending {
+		if request.conn == conn {
+			request.ch <- middlewareReply{frame: middlewareFrame{ID: id, Error: reason}}
+			delete(s.pending, id)
+		}
+	}
+}
+
+// execute - sends payload to connected middleware and waits for modified payload, returns new payload together
+// with sent and received frames
+func (s *MiddlewareSocket) execute(payload models.Payload) (models.Payload, []byte, []byte, error) {
+	view := payload.ConvertToPayloadView()
+
+	s.mu.Lock()
+	conn := s.conn
+	if conn == nil {
+		s.mu.Unlock()
+		return payload, nil, nil, fmt.Errorf("no middleware connected to %s", s.Path)
+	}
+	s.nextID++
+	id := s.nextID
+	ch := make(chan middlewareReply, 1)
+	s.pending[id] = pendingMiddlewareRequest{conn: conn, ch: ch}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+
+	bts, err := json.Marshal(middlewareFrame{ID: id, Payload: view})
+	if err != nil {
+		return payload, nil, nil, err
+	}
+
+	s.writeMu.Lock()
+	err = writeMiddlewareFrame(conn, bts)
+	s.writeMu.Unlock()
+	if err != nil {
+		return payload, bts, nil, err
+	}
+
+	select {
+	case reply := <-ch:
+		if reply.frame.Error != "" {
+			return payload, bts, reply.raw, fmt.Errorf("middleware error - %s", reply.frame.Error)
+		}
+		if reply.frame.Payload == nil {
+			return payload, bts, reply.raw, fmt.Errorf("middleware replied without payload")
+		}
+		return reply.frame.Payload.ConvertToPayload(), bts, reply.raw, nil
+	case <-time.After(s.Timeout):
+		return payload, bts, nil, fmt.Errorf("middleware didn't reply within %s", s.Timeout)
+	}
+}
+
+// executeSocketMiddleware - sends payload to middleware connected to the socket at given path
+func executeSocketMiddleware(middleware string, payload models.Payload) (models.Payload, []byte, []byte, error) {
+	path := strings.TrimPrefix(middleware, middlewareSocketPrefix)
+
+	middlewareSockets.Lock()
+	s, ok := middlewareSockets.m[path]
+	middlewareSockets.Unlock()
+
+	if !ok {
+		return payload, nil, nil, fmt.Errorf("middleware socket %s is not open", path)
+	}
+
+	newPayload, sent, received, err := s.execute(payload)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":  err.Error(),
+			"socket": path,
+		}).Error("Middleware error")
+	}
+	return newPayload, sent, received, err
+}
+
+func writeMiddlewareFrame(w io.Writer, bts []byte) error {
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(bts)))
+	if _, err := w.Write(append(size[:], bts...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func readMiddlewareFrame(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxMiddlewareFrameSize {
+		return nil, fmt.Errorf("frame of %d bytes is too large", n)
+	}
+
+	bts := make([]byte, n)
+	if _, err := io.ReadFull(r, bts); err != nil {
+		return nil, err
+	}
+	return bts, nil
+}
+
+// StartMiddlewareSocket - creates middleware socket at MiddlewareSocket path, middleware has to connect to it
+func (d *Hoverfly) StartMiddlewareSocket() error {
+	s, err := NewMiddlewareSocket(d.Cfg.MiddlewareSocket)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.middlewareSocket = s
+	d.mu.Unlock()
+	return nil
+}
+
+// StopMiddlewareSocket - closes middleware socket if it was started
+func (d *Hoverfly) StopMiddlewareSocket() error {
+	d.mu.Lock()
+	s := d.middlewareSocket
+	d.middlewareSocket = nil
+	d.mu.Unlock()
+
+	if s == nil {
+		return nil
+	}
+	return s.Close()
+}
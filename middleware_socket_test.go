@@ -0,0 +1,197 @@
+package hoverfly
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func openMiddlewareSocket(t *testing.T) (*MiddlewareSocket, func()) {
+	dir, err := ioutil.TempDir("", "hfmw")
+	testutil.Expect(t, err, nil)
+
+	s, err := NewMiddlewareSocket(filepath.Join(dir, "mw.sock"))
+	testutil.Expect(t, err, nil)
+
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// connectMiddlewareDaemon - connects fake middleware daemon that replies with status 201 and request path as body,
+// replies are sent in reverse order of arrival once 'batch' requests are received
+func connectMiddlewareDaemon(t *testing.T, s *MiddlewareSocket, batch int) net.Conn {
+	conn, err := net.Dial("unix", s.Path)
+	testutil.Expect(t, err, nil)
+
+	go func() {
+		r := bufio.NewReader(conn)
+		var frames []middlewareFrame
+		for {
+			raw, err := readMiddlewareFrame(r)
+			if err != nil {
+				return
+			}
+			var frame middlewareFrame
+			json.Unmarshal(raw, &frame)
+			frames = append(frames, frame)
+			if len(frames) < batch {
+				continue
+			}
+
+			for i := len(frames) - 1; i >= 0; i-- {
+				f := frames[i]
+				f.Payload.Response = models.ResponseDetailsView{Status: 201, Body: f.Payload.Request.Path}
+				bts, _ := json.Marshal(f)
+				writeMiddlewareFrame(conn, bts)
+			}
+			frames = nil
+		}
+	}()
+
+	// waiting for hoverfly to register connection
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		connected := s.conn != nil
+		s.mu.Unlock()
+		if connected {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return conn
+}
+
+func TestMiddlewareSocketMultiplexesConcurrentRequests(t *testing.T) {
+	s, cleanup := openMiddlewareSocket(t)
+	defer cleanup()
+
+	requests := 5
+	conn := connectMiddlewareDaemon(t, s, requests)
+	defer conn.Close()
+
+	var wg sync.WaitGroup
+	bodies := make([]string, requests)
+	errs := make([]error, requests)
+
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			payload := models.Payload{Request: models.RequestDetails{Method: "GET", Path: fmt.Sprintf("/%d", i)}}
+			newPayload, err := ExecuteMiddleware(s.Middleware(), payload)
+			bodies[i], errs[i] = newPayload.Response.Body, err
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < requests; i++ {
+		testutil.Expect(t, errs[i], nil)
+		testutil.Expect(t, bodies[i], fmt.Sprintf("/%d", i))
+	}
+}
+
+func TestMiddlewareSocketWithoutConnectedMiddleware(t *testing.T) {
+	s, cleanup := openMiddlewareSocket(t)
+	defer cleanup()
+
+	_, err := ExecuteMiddleware(s.Middleware(), models.Payload{})
+	testutil.Refute(t, err, nil)
+}
+
+func TestMiddlewareSocketReplyTimeout(t *testing.T) {
+	s, cleanup := openMiddlewareSocket(t)
+	defer cleanup()
+	s.Timeout = 50 * time.Millisecond
+
+	// daemon waits for two requests that never come
+	conn := connectMiddlewareDaemon(t, s, 2)
+	defer conn.Close()
+
+	_, err := ExecuteMiddleware(s.Middleware(), models.Payload{})
+	testutil.Refute(t, err, nil)
+}
+
+func TestMiddlewareSocketIsOnlyAccessibleByOwner(t *testing.T) {
+	s, cleanup := openMiddlewareSocket(t)
+	defer cleanup()
+
+	fi, err := os.Stat(s.Path)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, fi.Mode().Perm(), os.FileMode(0600))
+}
+
+func TestMiddlewareSocketReplacedConnectionFailsPendingRequests(t *testing.T) {
+	s, cleanup := openMiddlewareSocket(t)
+	defer cleanup()
+	s.Timeout = 10 * time.Second
+
+	// daemon waits for two requests, the first one stays pending
+	first := connectMiddlewareDaemon(t, s, 2)
+	defer first.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := ExecuteMiddleware(s.Middleware(), models.Payload{})
+		errs <- err
+	}()
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		pending := len(s.pending)
+		s.mu.Unlock()
+		if pending == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	second, err := net.Dial("unix", s.Path)
+	testutil.Expect(t, err, nil)
+	defer second.Close()
+
+	select {
+	case err := <-errs:
+		testutil.Refute(t, err, nil)
+		testutil.Expect(t, strings.Contains(err.Error(), "replaced"), true)
+	case <-time.After(time.Second):
+		t.Fatal("pending request didn't fail when middleware connection was replaced")
+	}
+}
+
+func TestSynthesizeResponseWithMiddlewareSocket(t *testing.T) {
+	s, cleanup := openMiddlewareSocket(t)
+	defer cleanup()
+
+	conn := connectMiddlewareDaemon(t, s, 1)
+	defer conn.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.MiddlewareSocket = s.Path
+	testutil.Expect(t, dbClient.Cfg.GetMiddleware(), s.Middleware())
+
+	req, err := http.NewRequest("GET", "http://example.com/socket", nil)
+	testutil.Expect(t, err, nil)
+
+	resp, err := dbClient.synthesizeResponse(req)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, 201)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "/socket")
+}
@@ -2,6 +2,8 @@ package hoverfly
 
 import (
 	"github.com/SpectoLabs/hoverfly/testutil"
+	"os/exec"
+	"strings"
 	"testing"
 	"github.com/SpectoLabs/hoverfly/models"
 )
@@ -64,3 +66,82 @@ func TestReflectBody(t *testing.T) {
 	testutil.Expect(t, newPayload.Request.Method, req.Method)
 	testutil.Expect(t, newPayload.Request.Destination, req.Destination)
 }
+
+func TestSingleMiddlewareChain(t *testing.T) {
+	command := "./examples/middleware/modify_response/modify_response.py"
+
+	resp := models.ResponseDetails{Status: 200, Body: "original body"}
+	req := models.RequestDetails{Path: "/", Method: "GET", Destination: "hostname-x", Query: ""}
+
+	payload := models.Payload{Response: resp, Request: req}
+
+	expected, err := ExecuteMiddleware(command, payload)
+	testutil.Expect(t, err, nil)
+
+	newPayload, _, _, err := runMiddlewareChain(payload, []string{command})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, newPayload.Response.Body, expected.Response.Body)
+	testutil.Expect(t, newPayload.Response.Status, expected.Response.Status)
+}
+
+func TestMiddlewareChainPipesOutput(t *testing.T) {
+	chain := []string{
+		"./examples/middleware/modify_response/modify_response.py",
+		"./examples/middleware/modify_status_code/modify_status_code.py",
+	}
+
+	resp := models.ResponseDetails{Status: 200, Body: "original body"}
+	req := models.RequestDetails{Path: "/", Method: "GET", Destination: "hostname-x", Query: ""}
+
+	newPayload, _, _, err := runMiddlewareChain(models.Payload{Response: resp, Request: req}, chain)
+
+	testutil.Expect(t, err, nil)
+	// body comes from the first middleware, status from the second one
+	testutil.Expect(t, newPayload.Response.Body, "body was replaced by middleware\n")
+	testutil.Expect(t, newPayload.Response.Status, 301)
+}
+
+func TestMiddlewareChainAbortsOnError(t *testing.T) {
+	chain := []string{"false", "./examples/middleware/modify_response/modify_response.py"}
+
+	resp := models.ResponseDetails{Status: 200, Body: "original body"}
+	req := models.RequestDetails{Path: "/", Method: "GET", Destination: "hostname-x", Query: ""}
+
+	newPayload, _, _, err := runMiddlewareChain(models.Payload{Response: resp, Request: req}, chain)
+
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, newPayload.Response.Body, "original body")
+}
+
+func TestPipelineCollectsStderrOfEveryCommand(t *testing.T) {
+	first := exec.Command("sh", "-c", "echo first >&2; cat")
+	first.Stdin = strings.NewReader("payload")
+	second := exec.Command("sh", "-c", "echo second >&2; cat")
+
+	output, stderr, err := Pipeline(first, second)
+
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(output), "payload")
+	testutil.Expect(t, string(stderr), "first\nsecond\n")
+}
+
+func TestPipelineWaitsForEveryCommandOnError(t *testing.T) {
+	first := exec.Command("sh", "-c", "echo failed >&2; exit 1")
+	second := exec.Command("sh", "-c", "cat; echo second >&2")
+
+	_, stderr, err := Pipeline(first, second)
+
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, string(stderr), "failed\nsecond\n")
+}
+
+func TestGetMiddlewarePrefersChain(t *testing.T) {
+	cfg := InitSettings()
+	cfg.Middleware = "./legacy.py"
+	cfg.MiddlewareChain = []string{"./auth.py", "./transform.py"}
+
+	testutil.Expect(t, cfg.GetMiddleware(), "./auth.py | ./transform.py")
+
+	cfg.SetMiddleware("./uploaded.py")
+	testutil.Expect(t, cfg.GetMiddleware(), "./uploaded.py")
+}
@@ -0,0 +1,86 @@
+package hoverfly
+
+import (
+	"context"
+	"net/http"
+	"os/exec"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// mockMiddleware - replaces middleware processes with 'cat' that echoes payload back, first hangingAttempts of them
+// hang instead. Returns counter of started processes.
+func mockMiddleware(t *testing.T, hangingAttempts int32) *int32 {
+	var started int32
+	original := middlewareCommand
+	middlewareCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		if atomic.AddInt32(&started, 1) <= hangingAttempts {
+			return exec.CommandContext(ctx, "sleep", "60")
+		}
+		return exec.CommandContext(ctx, "cat")
+	}
+	t.Cleanup(func() { middlewareCommand = original })
+	return &started
+}
+
+func TestMiddlewareTimeoutKillsHangingMiddleware(t *testing.T) {
+	started := mockMiddleware(t, 10)
+
+	payload := models.Payload{Response: models.ResponseDetails{Status: 201, Body: "original body"}}
+	limits := middlewareLimits{Timeout: 200 * time.Millisecond}
+
+	start := time.Now()
+	_, _, err := executeMiddleware("./middleware.py", payload, limits)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, errorCodeOf(err, ErrorCodeUnknown), ErrorCodeMiddlewareTimeout)
+	testutil.Expect(t, time.Since(start) < 10*time.Second, true)
+	testutil.Expect(t, atomic.LoadInt32(started), int32(1))
+}
+
+func TestMiddlewareTimeoutRetries(t *testing.T) {
+	started := mockMiddleware(t, 2)
+
+	payload := models.Payload{Response: models.ResponseDetails{Status: 201, Body: "original body"}}
+	limits := middlewareLimits{Timeout: 200 * time.Millisecond, Retries: 2}
+
+	newPayload, _, err := executeMiddleware("./middleware.py", payload, limits)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, newPayload.Response.Body, "original body")
+	testutil.Expect(t, atomic.LoadInt32(started), int32(3))
+}
+
+func TestMiddlewareTimeoutRetriesExhausted(t *testing.T) {
+	started := mockMiddleware(t, 10)
+
+	payload := models.Payload{Response: models.ResponseDetails{Status: 201, Body: "original body"}}
+	limits := middlewareLimits{Timeout: 100 * time.Millisecond, Retries: 2}
+
+	_, _, err := executeMiddleware("./middleware.py", payload, limits)
+	testutil.Expect(t, errorCodeOf(err, ErrorCodeUnknown), ErrorCodeMiddlewareTimeout)
+	testutil.Expect(t, atomic.LoadInt32(started), int32(3))
+}
+
+func TestMiddlewareTimeoutRespondsWithGatewayTimeout(t *testing.T) {
+	mockMiddleware(t, 10)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.Middleware = "./middleware.py"
+	dbClient.Cfg.MiddlewareTimeout = 100 * time.Millisecond
+
+	for _, mode := range []string{SynthesizeMode, ModifyMode} {
+		dbClient.Cfg.SetMode(mode)
+
+		req, err := http.NewRequest("GET", "http://example.com/", nil)
+		testutil.Expect(t, err, nil)
+
+		_, resp := dbClient.processRequest(req)
+		testutil.Expect(t, resp.StatusCode, http.StatusGatewayTimeout)
+		testutil.Expect(t, resp.Header.Get(ErrorCodeHeader), string(ErrorCodeMiddlewareTimeout))
+	}
+}
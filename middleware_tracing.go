@@ -0,0 +1,63 @@
+package hoverfly
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// SpanNameMiddleware - name of spans created for middleware invocations by Configuration.TracerProvider
+const SpanNameMiddleware = "hoverfly.middleware.invocation"
+
+// attributes of middleware invocation spans
+const (
+	SpanAttributeMiddlewarePath        = "middleware.path"
+	SpanAttributeMiddlewareExitCode    = "middleware.exit_code"
+	SpanAttributeMiddlewareElapsedMs   = "middleware.elapsed_ms"
+	SpanAttributeMiddlewareStdinBytes  = "middleware.stdin_bytes"
+	SpanAttributeMiddlewareStdoutBytes = "middleware.stdout_bytes"
+)
+
+// TracerProvider - starts spans of middleware invocations as children of the span in given context, request
+// context of proxied request is used. otel.NewTracerProvider adapts OpenTelemetry tracer provider.
+type TracerProvider interface {
+	StartSpan(ctx context.Context, name string) Span
+}
+
+// Span - span started by TracerProvider, attribute values are strings, ints or float64s. End is called once with
+// error of the traced operation, if there was one.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	End(err error)
+}
+
+// startMiddlewareSpan - starts span of middleware invocation, nil when tracer provider isn't set
+func startMiddlewareSpan(tp TracerProvider, req *http.Request, middleware string) Span {
+	if tp == nil {
+		return nil
+	}
+	ctx := context.Background()
+	if req != nil {
+		ctx = req.Context()
+	}
+	span := tp.StartSpan(ctx, SpanNameMiddleware)
+	if span != nil {
+		span.SetAttribute(SpanAttributeMiddlewarePath, middleware)
+	}
+	return span
+}
+
+// endMiddlewareSpan - sets what was exchanged with middleware and ends the span, exit code is only known for
+// middleware processes
+func endMiddlewareSpan(span Span, run middlewareRun, elapsed time.Duration, err error) {
+	if span == nil {
+		return
+	}
+	if run.ExitCode != nil {
+		span.SetAttribute(SpanAttributeMiddlewareExitCode, *run.ExitCode)
+	}
+	span.SetAttribute(SpanAttributeMiddlewareElapsedMs, float64(elapsed)/float64(time.Millisecond))
+	span.SetAttribute(SpanAttributeMiddlewareStdinBytes, len(run.Stdin))
+	span.SetAttribute(SpanAttributeMiddlewareStdoutBytes, len(run.Stdout))
+	span.End(err)
+}
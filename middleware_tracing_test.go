@@ -0,0 +1,98 @@
+package hoverfly
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+type testSpanKey struct{}
+
+// recordingSpan - span of recordingTracerProvider, keeps its attributes
+type recordingSpan struct {
+	name       string
+	parent     interface{}
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (s *recordingSpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+func (s *recordingSpan) End(err error) {
+	s.err = err
+	s.ended = true
+}
+
+type recordingTracerProvider struct {
+	mu    sync.Mutex
+	spans []*recordingSpan
+}
+
+func (p *recordingTracerProvider) StartSpan(ctx context.Context, name string) Span {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	span := &recordingSpan{name: name, parent: ctx.Value(testSpanKey{}), attributes: map[string]interface{}{}}
+	p.spans = append(p.spans, span)
+	return span
+}
+
+func TestApplyMiddlewareCreatesSpan(t *testing.T) {
+	mockMiddleware(t, 0)
+	tp := &recordingTracerProvider{}
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	testutil.Expect(t, err, nil)
+	req = req.WithContext(context.WithValue(req.Context(), testSpanKey{}, "proxy span"))
+
+	payload := models.Payload{Response: models.ResponseDetails{Status: 201, Body: "original body"}}
+	c := NewConstructor(req, payload).withTracerProvider(tp)
+	testutil.Expect(t, c.ApplyMiddleware("./middleware.py"), nil)
+
+	testutil.Expect(t, len(tp.spans), 1)
+	span := tp.spans[0]
+	testutil.Expect(t, span.name, SpanNameMiddleware)
+	testutil.Expect(t, span.parent, "proxy span")
+	testutil.Expect(t, span.ended, true)
+	testutil.Expect(t, span.err, nil)
+	testutil.Expect(t, span.attributes[SpanAttributeMiddlewarePath], "./middleware.py")
+	testutil.Expect(t, span.attributes[SpanAttributeMiddlewareExitCode], 0)
+
+	// middleware echoes payload back
+	testutil.Refute(t, span.attributes[SpanAttributeMiddlewareStdinBytes], 0)
+	testutil.Expect(t, span.attributes[SpanAttributeMiddlewareStdinBytes], span.attributes[SpanAttributeMiddlewareStdoutBytes])
+	elapsed, ok := span.attributes[SpanAttributeMiddlewareElapsedMs].(float64)
+	testutil.Expect(t, ok, true)
+	testutil.Expect(t, elapsed > 0, true)
+}
+
+func TestSynthesizeResponseCreatesMiddlewareSpan(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	tp := &recordingTracerProvider{}
+	dbClient.Cfg.TracerProvider = tp
+	dbClient.Cfg.SetMiddleware("./examples/middleware/this_is_not_there.py")
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	testutil.Expect(t, err, nil)
+
+	_, err = dbClient.synthesizeResponse(req)
+	testutil.Refute(t, err, nil)
+
+	testutil.Expect(t, len(tp.spans), 1)
+	testutil.Refute(t, tp.spans[0].err, nil)
+	testutil.Expect(t, tp.spans[0].attributes[SpanAttributeMiddlewareStdoutBytes], 0)
+}
+
+func TestApplyMiddlewareWithoutTracerProvider(t *testing.T) {
+	mockMiddleware(t, 0)
+
+	payload := models.Payload{Response: models.ResponseDetails{Status: 201, Body: "original body"}}
+	testutil.Expect(t, NewConstructor(nil, payload).ApplyMiddleware("./middleware.py"), nil)
+}
@@ -0,0 +1,82 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// minifyJSONBodies - compacts JSON response bodies of payload with MinifyJSONBodies
+func (c *Configuration) minifyJSONBodies(payload *models.Payload) {
+	if c == nil || !c.MinifyJSONBodies {
+		return
+	}
+	minifyPayloadJSONBodies(payload)
+}
+
+// minifyPayloadJSONBodies - compacts JSON bodies of every response of the payload: served response, pages,
+// response sequence and history
+func minifyPayloadJSONBodies(payload *models.Payload) {
+	minifyJSONBody(&payload.Response)
+	if payload.Paginated != nil {
+		for i := range payload.Paginated.Pages {
+			minifyJSONBody(&payload.Paginated.Pages[i])
+		}
+	}
+	for i := range payload.ResponseSequence {
+		minifyJSONBody(&payload.ResponseSequence[i])
+	}
+	for i := range payload.History {
+		minifyJSONBody(&payload.History[i].Response)
+	}
+}
+
+// minifyJSONBody - removes insignificant whitespace from body of response with JSON Content-Type, Content-Length
+// header is updated when it is set. Templated bodies, encoded (i.e. gzipped) bodies and bodies that aren't valid
+// JSON are left as they are.
+func minifyJSONBody(response *models.ResponseDetails) {
+	if response.TemplatedBody || response.Body == "" {
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(responseHeader(response.Headers, "Content-Type"))
+	if err != nil || !isJSONMediaType(mediaType) {
+		return
+	}
+	if encoding := responseHeader(response.Headers, "Content-Encoding"); encoding != "" && encoding != "identity" {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(response.Body)); err != nil {
+		return
+	}
+	if buf.Len() == len(response.Body) {
+		return
+	}
+	response.Body = buf.String()
+
+	// headers can be shared with response that is served to the client
+	headers := make(map[string][]string, len(response.Headers))
+	for name, values := range response.Headers {
+		if strings.EqualFold(name, "Content-Length") {
+			values = []string{strconv.Itoa(buf.Len())}
+		}
+		headers[name] = values
+	}
+	response.Headers = headers
+}
+
+// responseHeader - first value of header, stored headers aren't necessarily canonical
+func responseHeader(headers map[string][]string, name string) string {
+	for k, values := range headers {
+		if strings.EqualFold(k, name) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,135 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+const prettyJSONBody = `{
+    "name": "hoverfly",
+    "tags": [
+        "proxy",
+        "simulation"
+    ],
+    "text": "keeps  spaces  in  strings",
+    "nested": {"count": 2}
+}`
+
+const minifiedJSONBody = `{"name":"hoverfly","tags":["proxy","simulation"],"text":"keeps  spaces  in  strings","nested":{"count":2}}`
+
+func jsonResponse(body string) models.ResponseDetails {
+	return models.ResponseDetails{
+		Status: 200,
+		Body:   body,
+		Headers: map[string][]string{
+			"content-type":   {"application/json; charset=utf-8"},
+			"Content-Length": {"999"},
+		},
+	}
+}
+
+func TestMinifyJSONBody(t *testing.T) {
+	response := jsonResponse(prettyJSONBody)
+	original := response.Headers
+
+	minifyJSONBody(&response)
+	testutil.Expect(t, response.Body, minifiedJSONBody)
+	testutil.Expect(t, response.Headers["Content-Length"][0], strconv.Itoa(len(minifiedJSONBody)))
+	testutil.Expect(t, original["Content-Length"][0], "999")
+
+	// minified body is semantically equivalent to the original
+	var before, after interface{}
+	testutil.Expect(t, json.Unmarshal([]byte(prettyJSONBody), &before), nil)
+	testutil.Expect(t, json.Unmarshal([]byte(response.Body), &after), nil)
+	testutil.Expect(t, reflect.DeepEqual(before, after), true)
+}
+
+func TestMinifyJSONBodyLeavesOtherBodies(t *testing.T) {
+	text := jsonResponse(prettyJSONBody)
+	text.Headers["content-type"] = []string{"text/plain"}
+
+	invalid := jsonResponse("{ not json }")
+
+	templated := jsonResponse(prettyJSONBody)
+	templated.TemplatedBody = true
+
+	gzipped := jsonResponse(prettyJSONBody)
+	gzipped.Headers["Content-Encoding"] = []string{"gzip"}
+
+	for _, response := range []models.ResponseDetails{text, invalid, templated, gzipped} {
+		body := response.Body
+		minifyJSONBody(&response)
+		testutil.Expect(t, response.Body, body)
+		testutil.Expect(t, response.Headers["Content-Length"][0], "999")
+	}
+}
+
+func TestMinifyPayloadJSONBodies(t *testing.T) {
+	payload := models.Payload{
+		Response:         jsonResponse(prettyJSONBody),
+		Paginated:        &models.PaginatedResponse{Pages: []models.ResponseDetails{jsonResponse("[ 1, 2 ]")}},
+		ResponseSequence: []models.ResponseDetails{jsonResponse("[ 3 ]")},
+		History:          []models.ResponseVersion{{Version: 1, Response: jsonResponse(`{ "old": true }`)}},
+	}
+
+	minifyPayloadJSONBodies(&payload)
+	testutil.Expect(t, strings.Contains(payload.Response.Body, " \n"), false)
+	testutil.Expect(t, payload.Paginated.Pages[0].Body, "[1,2]")
+	testutil.Expect(t, payload.ResponseSequence[0].Body, "[3]")
+	testutil.Expect(t, payload.History[0].Response.Body, `{"old":true}`)
+}
+
+func TestExportMinifiesJSONBodies(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	// stored before minification was enabled
+	dbClient.storePayload(dbClient.Cfg, "key", models.Payload{
+		Request:  models.RequestDetails{Method: "GET", Destination: "example.com", Path: "/"},
+		Response: jsonResponse(prettyJSONBody),
+	})
+	dbClient.Cfg.MinifyJSONBodies = true
+
+	var buf bytes.Buffer
+	testutil.Expect(t, dbClient.ExportSimulation(&buf, ExportOptions{}), nil)
+
+	var records recordedRequests
+	testutil.Expect(t, json.Unmarshal(buf.Bytes(), &records), nil)
+	testutil.Expect(t, len(records.Data), 1)
+	testutil.Expect(t, records.Data[0].Response.Body, minifiedJSONBody)
+}
+
+func TestMinifyJSONBodiesShareDeduplicatedBody(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	bodies := cache.NewInMemoryCache()
+	dbClient.RequestCache = NewDedupCache(cache.NewInMemoryCache(), bodies)
+	dbClient.Cfg.MinifyJSONBodies = true
+
+	// bodies that differ only in whitespace are stored once
+	one, two := jsonResponse(prettyJSONBody), jsonResponse(strings.Replace(prettyJSONBody, "    ", "\t", -1))
+	testutil.Expect(t, dbClient.ImportPayloads([]models.PayloadView{
+		{
+			Request:  models.RequestDetailsView{Method: "GET", Destination: "example.com", Path: "/one"},
+			Response: one.ConvertToResponseDetailsView(),
+		},
+		{
+			Request:  models.RequestDetailsView{Method: "GET", Destination: "example.com", Path: "/two"},
+			Response: two.ConvertToResponseDetailsView(),
+		},
+	}), nil)
+
+	count, err := bodies.Count()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+}
@@ -0,0 +1,70 @@
+package hoverfly
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ErrBadMode - returned when switching to mode that doesn't exist
+var ErrBadMode = fmt.Errorf("Bad mode supplied, available modes: %s.", strings.Join(validModes, ", "))
+
+// modeChangeHooks - callbacks registered with OnModeChange, shared by copies of Hoverfly
+type modeChangeHooks struct {
+	mu    sync.Mutex
+	hooks []func(oldMode, newMode string)
+}
+
+func newModeChangeHooks() *modeChangeHooks {
+	return &modeChangeHooks{}
+}
+
+// OnModeChange - registers callback that is invoked in a separate goroutine every time mode is switched with
+// SetMode. Callbacks run concurrently, panicking callback is recovered and logged.
+func (d *Hoverfly) OnModeChange(fn func(oldMode, newMode string)) {
+	if d.modeHooks == nil {
+		d.modeHooks = newModeChangeHooks()
+	}
+	d.modeHooks.mu.Lock()
+	d.modeHooks.hooks = append(d.modeHooks.hooks, fn)
+	d.modeHooks.mu.Unlock()
+}
+
+// SetMode - switches Hoverfly to given mode and fires mode change callbacks
+func (d *Hoverfly) SetMode(mode string) error {
+	if !isValidMode(mode) {
+		return ErrBadMode
+	}
+
+	oldMode := d.Cfg.GetMode()
+	d.Cfg.SetMode(mode)
+
+	if d.modeHooks != nil {
+		d.modeHooks.fire(oldMode, mode)
+	}
+	return nil
+}
+
+func (m *modeChangeHooks) fire(oldMode, newMode string) {
+	m.mu.Lock()
+	hooks := make([]func(oldMode, newMode string), len(m.hooks))
+	copy(hooks, m.hooks)
+	m.mu.Unlock()
+
+	for _, fn := range hooks {
+		go func(fn func(oldMode, newMode string)) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.WithFields(log.Fields{
+						"oldMode": oldMode,
+						"newMode": newMode,
+						"panic":   r,
+					}).Error("Mode change callback panicked")
+				}
+			}()
+			fn(oldMode, newMode)
+		}(fn)
+	}
+}
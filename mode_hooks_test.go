@@ -0,0 +1,63 @@
+package hoverfly
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestOnModeChangeCallbacks(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.SetMode(SimulateMode)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	changes := make(chan [2]string, 2)
+	dbClient.OnModeChange(func(oldMode, newMode string) {
+		defer wg.Done()
+		panic("callback failure")
+	})
+	dbClient.OnModeChange(func(oldMode, newMode string) {
+		defer wg.Done()
+		changes <- [2]string{oldMode, newMode}
+	})
+
+	err := dbClient.SetMode(CaptureMode)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, dbClient.Cfg.GetMode(), CaptureMode)
+
+	wg.Wait()
+	select {
+	case change := <-changes:
+		testutil.Expect(t, change, [2]string{SimulateMode, CaptureMode})
+	case <-time.After(time.Second):
+		t.Fatal("mode change callback wasn't called")
+	}
+}
+
+func TestSetModeBadMode(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.SetMode(SimulateMode)
+
+	called := make(chan struct{}, 1)
+	dbClient.OnModeChange(func(oldMode, newMode string) {
+		called <- struct{}{}
+	})
+
+	err := dbClient.SetMode("unknown")
+	testutil.Expect(t, err, ErrBadMode)
+	testutil.Expect(t, strings.Contains(err.Error(), "spy, diff, chaos."), true)
+	testutil.Expect(t, dbClient.Cfg.GetMode(), SimulateMode)
+
+	select {
+	case <-called:
+		t.Fatal("callback called for failed mode change")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -2,12 +2,14 @@ package hoverfly
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
-	"regexp"
 	"sync"
 	"time"
 
@@ -27,23 +29,101 @@ type Hoverfly struct {
 	HTTP           *http.Client
 	Cfg            *Configuration
 	Counter        *metrics.CounterByMode
+	Sizes          *metrics.SizeHistogram
 	Hooks          ActionTypeHooks
+	GeoIP          GeoLocator
+	Diffs          *DiffStore
+
+	// events - pushed to admin control channel clients
+	events *eventBroadcaster
+
+	// Sequences - positions in response sequences of simulated requests
+	Sequences *SequenceCounter
+	// Weights - selects weighted responses of response sequences
+	Weights *WeightedSelector
+
+	// Circuits - per-host circuit breaker of capture mode
+	Circuits *CircuitBreaker
+
+	requestHooks *requestHooks
+
+	// modeHooks - callbacks registered with OnModeChange
+	modeHooks *modeChangeHooks
+
+	// processors - wrap processing of proxied requests, registered with Use
+	processors []func(next RequestProcessor) RequestProcessor
+
+	// middlewareSocket - open when middleware communicates over Unix domain socket
+	middlewareSocket *MiddlewareSocket
+
+	// middlewarePool - pre-started middleware processes, see StartMiddlewarePool
+	middlewarePool *MiddlewarePool
+
+	// grpcProxy - gRPC listener, see StartGRPCProxy
+	grpcProxy *grpcHandler
+
+	// upstreamTLS - TLS configuration with client certificate, set when Cfg.MutualTLS is configured
+	upstreamTLS *tls.Config
+
+	// tlsSessions - counts resumed and full TLS handshakes, see TLSStatsHandler
+	tlsSessions *tlsSessionTracker
+
+	// captureBloom - filter of request cache keys that saves cache reads of DeduplicateCaptures
+	captureBloom *captureBloom
+
+	// flows - stored flows and their runs, see getFlowResponse
+	flows *flowIndex
+
+	// recentAccess - request cache keys ordered by last access, see RecentRecordsHandler
+	recentAccess *accessIndex
+
+	// socks5Proxy - SOCKS5 proxy that upstream connections currently go through, see applyUpstreamSOCKS5Proxy
+	socks5Proxy string
+
+	// dnsDialer - dialer of HTTP client that simulates DNS failures, see applyDNSFailures
+	dnsDialer *dnsFailureDialer
+
+	// startupConfig - configuration snapshot taken when Hoverfly was created
+	startupConfig map[string]interface{}
+
+	// upstreamPool - upstream Hoverfly instances requests are distributed across, see StartUpstreamHoverflyPool
+	upstreamPool *upstreamPool
+
+	// recordOnceLocks - serializes recording of the same request, see recordOnceResponse
+	recordOnceLocks recordOnceLocks
+	// usageLocks - serializes use count updates of the same payload, see trackUsage
+	usageLocks recordOnceLocks
+	// cacheWrites - background writer of requests recorded with RecordOnceAsync
+	cacheWrites cacheWriter
+	// webauthnCounters - serializes simulated WebAuthn assertions, see webauthnResult
+	webauthnCounters sync.Mutex
 
 	Proxy *goproxy.ProxyHttpServer
 	SL    *StoppableListener
 	mu    sync.Mutex
+
+	// ExtraProxies - proxies of Cfg.ExtraProxyPorts keyed on port, each processes requests in its own mode
+	ExtraProxies   map[string]*goproxy.ProxyHttpServer
+	extraListeners []*StoppableListener
+
+	// adminListener - admin interface listener started by StartWhenReady
+	adminListener *StoppableListener
 }
 
 // UpdateDestination - updates proxy with new destination regexp
 func (d *Hoverfly) UpdateDestination(destination string) (err error) {
-	_, err = regexp.Compile(destination)
-	if err != nil {
-		return fmt.Errorf("destination is not a valid regular expression string")
+	return d.UpdateDestinations([]string{destination})
+}
+
+// UpdateDestinations - updates proxy with new destination regexps, hosts matching any of them are intercepted
+func (d *Hoverfly) UpdateDestinations(destinations []string) (err error) {
+	if err = validateDestinations(destinations); err != nil {
+		return err
 	}
 
 	d.mu.Lock()
 	d.StopProxy()
-	d.Cfg.Destination = destination
+	d.Cfg.Destinations = destinations
 	d.UpdateProxy()
 	err = d.StartProxy()
 	d.mu.Unlock()
@@ -62,9 +142,9 @@ func (d *Hoverfly) StartProxy() error {
 	}
 
 	log.WithFields(log.Fields{
-		"destination": d.Cfg.Destination,
-		"port":        d.Cfg.ProxyPort,
-		"mode":        d.Cfg.GetMode(),
+		"destinations": d.Cfg.Destinations,
+		"port":         d.Cfg.ProxyPort,
+		"mode":         d.Cfg.GetMode(),
 	}).Info("current proxy configuration")
 
 	// creating TCP listener
@@ -88,17 +168,53 @@ func (d *Hoverfly) StartProxy() error {
 			d.Cfg.ProxyControlWG.Done()
 		}()
 		log.Info("serving proxy")
-		server.Handler = d.Proxy
+		server.Handler = d.proxyHandler(d.Proxy, &server)
 		log.Warn(server.Serve(sl))
 	}()
 
-	return nil
+	return d.startExtraProxies()
 }
 
-// StopProxy - stops proxy
+// proxyHandler - wraps proxy with handlers that have to see raw client connection
+func (d *Hoverfly) proxyHandler(proxy *goproxy.ProxyHttpServer, server *http.Server) http.Handler {
+	var handler http.Handler = newProxyAuthHandler(&webSocketHandler{
+		handler: &upgradeHandler{
+			handler: &earlyCloseHandler{
+				handler: &packetLossHandler{
+					handler: &partialResponseHandler{
+						handler: &slowHeadersHandler{handler: &trailersHandler{handler: &streamingSynthesisHandler{handler: proxy, cfg: d.Cfg}}, cfg: d.Cfg},
+						cfg:     d.Cfg,
+					},
+					cfg:  d.Cfg,
+					loss: newPacketLoss(d.Cfg.SimulationSeed),
+				},
+				cfg: d.Cfg,
+			},
+			hf: d,
+		},
+		hf: d,
+	}, d.Cfg)
+	if d.Cfg.InjectConnectionIDHeader {
+		ids := &connectionIDs{}
+		ids.track(server)
+		handler = &connectionIDHandler{handler: handler, ids: ids}
+	}
+	return &pipelineHandler{handler: handler, cfg: d.Cfg}
+}
+
+// StopProxy - stops proxy together with proxies of extra ports and admin interface started by StartWhenReady
 func (d *Hoverfly) StopProxy() {
 	d.SL.Stop()
+	for _, sl := range d.extraListeners {
+		sl.Stop()
+	}
+	d.extraListeners = nil
+	if d.adminListener != nil {
+		d.adminListener.Stop()
+		d.adminListener = nil
+	}
 	d.Cfg.ProxyControlWG.Wait()
+	d.recentAccess.flush(d)
 }
 
 // AddHook - adds a hook to DBClient
@@ -108,15 +224,27 @@ func (d *Hoverfly) AddHook(hook Hook) {
 
 var emptyResp = &http.Response{}
 
-// captureRequest saves request for later playback
-func (d *Hoverfly) captureRequest(req *http.Request) (*http.Response, error) {
+// captureRequest saves request for later playback, requests to hosts with open circuit are not forwarded
+func (d *Hoverfly) captureRequest(req *http.Request) (resp *http.Response, err error) {
+	cfg := d.requestConfig(req)
+	host := req.Host
+	if err := d.Circuits.Allow(host); err != nil {
+		return nil, err
+	}
+	defer func() {
+		d.Circuits.Record(host, resp, err)
+	}()
+
+	if cfg.StreamRequestBody {
+		return d.captureStreamedRequest(req)
+	}
 
 	// this is mainly for testing, since when you create
 	if req.Body == nil {
 		req.Body = ioutil.NopCloser(bytes.NewBuffer([]byte("")))
 	}
 
-	reqBody, err := ioutil.ReadAll(req.Body)
+	reqBody, streamedReq, err := limitBody(req.Body, cfg.MaxRequestBodyBytes)
 
 	if err != nil {
 		log.WithFields(log.Fields{
@@ -131,23 +259,49 @@ func (d *Hoverfly) captureRequest(req *http.Request) (*http.Response, error) {
 		"mode": "capture",
 	}).Debug("got request body")
 
-	// forwarding request
-	req.Body = ioutil.NopCloser(bytes.NewBuffer(reqBody))
+	// removing credentials so they are neither forwarded nor stored
+	stripped := false
+	if cfg.StripAuthHeaders {
+		stripped = stripAuthHeaders(req.Header)
+	}
 
-	req, resp, err := d.doRequest(req)
+	// forwarding request, body that is longer than the limit can't be sent again so the request isn't retried
+	if streamedReq != nil {
+		req.Body = streamedReq
+		req, resp, err = d.doRequest(req)
+	} else {
+		req, resp, err = d.doCaptureRequest(req, reqBody)
+	}
 
 	if err != nil {
 		log.WithFields(log.Fields{
 			"error": err.Error(),
 			"mode":  "capture",
 		}).Error("Got error when reading body after being modified by middleware")
+		return nil, err
 	}
 
-	reqBody, err = ioutil.ReadAll(req.Body)
-	req.Body = ioutil.NopCloser(bytes.NewBuffer(reqBody))
+	// middleware might have replaced body that was streamed
+	if _, streamed := req.Body.(*streamedBody); !streamed {
+		reqBody, err = ioutil.ReadAll(req.Body)
+		req.Body = ioutil.NopCloser(bytes.NewBuffer(reqBody))
+	}
+	reqBody, reqTruncated := truncateBody(reqBody, cfg.MaxRequestBodyBytes)
+	reqTruncated = reqTruncated || streamedReq != nil
+
+	// response is returned straight away and stored once client read its body
+	if err == nil && cfg.canTeeResponse() && resp.Body != nil {
+		if stripped {
+			req.Header.Set("Authorization", RedactedAuthorization)
+		}
+		if !d.isDuplicateCapture(cfg, d.getRequestFingerprint(req, reqBody)) {
+			d.teeCapture(req, reqBody, reqTruncated, resp)
+		}
+		return resp, nil
+	}
 
 	if err == nil {
-		respBody, err := extractBody(resp)
+		respBody, respTruncated, err := d.extractLimitedBody(resp)
 
 		if err != nil {
 
@@ -159,8 +313,21 @@ func (d *Hoverfly) captureRequest(req *http.Request) (*http.Response, error) {
 			return resp, err
 		}
 
+		if status := d.rewriteStatus(req, resp.StatusCode, respBody); status != resp.StatusCode {
+			resp.StatusCode = status
+			resp.Status = fmt.Sprintf("%d %s", status, http.StatusText(status))
+		}
+
+		// leaving a trace that request was authenticated
+		if stripped {
+			req.Header.Set("Authorization", RedactedAuthorization)
+		}
+
 		// saving response body with request/response meta to cache
-		d.save(req, reqBody, resp, respBody)
+		if !d.isDuplicateCapture(cfg, d.getRequestFingerprint(req, reqBody)) {
+			storedResp, storedBody := d.decompressedForStorage(resp, respBody)
+			d.saveTruncated(req, reqBody, storedResp, storedBody, cfg.bodyTruncation(req, reqTruncated, respTruncated))
+		}
 	}
 
 	// return new response or error here
@@ -218,6 +385,18 @@ func extractRequestBody(req *http.Request) (extract []byte, err error) {
 	return extract, nil
 }
 
+// stripAuthHeaders - removes authorization headers, returns true if any of them was present
+func stripAuthHeaders(headers http.Header) bool {
+	stripped := false
+	for _, h := range []string{"Authorization", "Proxy-Authorization"} {
+		if _, ok := headers[h]; ok {
+			headers.Del(h)
+			stripped = true
+		}
+	}
+	return stripped
+}
+
 // getRequestDetails - extracts request details
 func getRequestDetails(req *http.Request) (requestObj models.RequestDetails, err error) {
 	if req.Body == nil {
@@ -248,11 +427,15 @@ func getRequestDetails(req *http.Request) (requestObj models.RequestDetails, err
 
 // doRequest performs original request and returns response that should be returned to client and error (if there is one)
 func (d *Hoverfly) doRequest(request *http.Request) (*http.Request, *http.Response, error) {
+	cfg := d.requestConfig(request)
 
 	// We can't have this set. And it only contains "/pkg/net/http/" anyway
 	request.RequestURI = ""
 
-	if d.Cfg.Middleware != "" {
+	// middleware creates new request, mode has to be taken before
+	mode := requestMode(request, cfg)
+
+	if cfg.GetMiddleware() != "" {
 		// middleware is provided, modifying request
 		var payload models.Payload
 
@@ -262,48 +445,76 @@ func (d *Hoverfly) doRequest(request *http.Request) (*http.Request, *http.Respon
 		}
 		payload.Request = rd
 
-		c := NewConstructor(request, payload)
-		err = c.ApplyMiddleware(d.Cfg.Middleware)
+		c := NewConstructor(request, payload).withMiddlewareLimits(cfg.middlewareLimits()).withTracerProvider(cfg.TracerProvider)
+		err = c.ApplyMiddleware(cfg.GetMiddleware())
 
 		if err != nil {
 			log.WithFields(log.Fields{
-				"mode":   d.Cfg.Mode,
+				"mode":   cfg.Mode,
 				"error":  err.Error(),
 				"host":   request.Host,
 				"method": request.Method,
 				"path":   request.URL.Path,
 			}).Error("could not forward request, middleware failed to modify request.")
-			return nil, nil, err
+			return nil, nil, withErrorCode(errorCodeOf(err, ErrorCodeMiddlewareFailed), err)
 		}
 
 		request, err = c.ReconstructRequest()
 
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, withErrorCode(ErrorCodeMiddlewareFailed, err)
+		}
+
+		if len(c.payload.Metadata) > 0 {
+			if body, err := extractRequestBody(request); err == nil {
+				d.storeMiddlewareMetadata(d.getRequestFingerprint(request, body), c.payload.Metadata)
+			}
 		}
 	}
 
-	requestBody, _ := ioutil.ReadAll(request.Body)
+	// applied after middleware so that it doesn't matter what middleware does with headers
+	if mode == ModifyMode {
+		cfg.applyForwardRequestHeaders(request.Header)
+	}
 
-	request.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+	// body longer than capture limit is sent as it is read
+	_, streamed := request.Body.(*streamedBody)
+	var requestBody []byte
+	if !streamed {
+		requestBody, _ = ioutil.ReadAll(request.Body)
+		request.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+	}
 
-	resp, err := d.HTTP.Do(request)
+	var resp *http.Response
+	var err error
+	end := startOperation(request, OperationUpstream)
+	if mode == ModifyMode && len(cfg.FanOutTargets) > 0 {
+		resp, err = d.fanOutRequest(request)
+	} else {
+		resp, err = d.HTTP.Do(request)
+	}
+	end(err)
 
-	request.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+	if !streamed {
+		request.Body = ioutil.NopCloser(bytes.NewReader(requestBody))
+	}
 
 	if err != nil {
 		log.WithFields(log.Fields{
-			"mode":   d.Cfg.Mode,
+			"mode":   cfg.Mode,
 			"error":  err.Error(),
 			"host":   request.Host,
 			"method": request.Method,
 			"path":   request.URL.Path,
 		}).Error("could not forward request, failed to do an HTTP request.")
-		return nil, nil, err
+		if tooMany, ok := tooManyRedirects(err); ok {
+			return nil, nil, withErrorCode(ErrorCodeTooManyRedirects, tooMany)
+		}
+		return nil, nil, withErrorCode(errorCodeOf(err, ErrorCodeUpstreamUnreachable), err)
 	}
 
 	log.WithFields(log.Fields{
-		"mode":   d.Cfg.Mode,
+		"mode":   cfg.Mode,
 		"host":   request.Host,
 		"method": request.Method,
 		"path":   request.URL.Path,
@@ -315,8 +526,52 @@ func (d *Hoverfly) doRequest(request *http.Request) (*http.Request, *http.Respon
 
 }
 
+// bypassRequest strips bypass header and forwards request to original destination, neither request nor
+// response is saved to cache and middleware is not applied.
+func (d *Hoverfly) bypassRequest(request *http.Request) (*http.Response, error) {
+	cfg := d.requestConfig(request)
+
+	// We can't have this set. And it only contains "/pkg/net/http/" anyway
+	request.RequestURI = ""
+
+	request.Header.Del(BypassHeader)
+
+	end := startOperation(request, OperationUpstream)
+	resp, err := d.HTTP.Do(request)
+	end(err)
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"mode":   cfg.GetMode(),
+			"error":  err.Error(),
+			"host":   request.Host,
+			"method": request.Method,
+			"path":   request.URL.Path,
+		}).Error("could not forward bypassed request, failed to do an HTTP request.")
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// liveRequest - forwards request to live endpoint while simulating, response is captured when live
+// responses should be cached
+func (d *Hoverfly) liveRequest(request *http.Request) (*http.Response, error) {
+	cfg := d.requestConfig(request)
+	if cfg.CacheLiveResponses {
+		return d.captureRequest(request)
+	}
+	return d.bypassRequest(request)
+}
+
 // save gets request fingerprint, extracts request body, status code and headers, then saves it to cache
 func (d *Hoverfly) save(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte) {
+	d.saveTruncated(req, reqBody, resp, respBody, nil)
+}
+
+// saveTruncated - same as save, truncation is recorded on the payload when captured bodies were truncated
+func (d *Hoverfly) saveTruncated(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, truncation *models.BodyTruncation) {
+	cfg := d.requestConfig(req)
 	// record request here
 	key := d.getRequestFingerprint(req, reqBody)
 
@@ -328,6 +583,10 @@ func (d *Hoverfly) save(req *http.Request, reqBody []byte, resp *http.Response,
 			Body:    string(respBody),
 			Headers: resp.Header,
 		}
+		// trailers are known only when body was read
+		if len(resp.Trailer) > 0 {
+			responseObj.Trailers = resp.Trailer
+		}
 
 		log.WithFields(log.Fields{
 			"path":          req.URL.Path,
@@ -349,44 +608,99 @@ func (d *Hoverfly) save(req *http.Request, reqBody []byte, resp *http.Response,
 		}
 
 		payload := models.Payload{
-			Response: responseObj,
-			Request:  requestObj,
+			Response:          responseObj,
+			Request:           requestObj,
+			RequestBodySHA256: requestBodyHash(reqBody),
+			CreatedAt:         time.Now(),
+			TruncatedAt:       truncation,
 		}
+		logSchemaMismatch(cfg, payload)
 
-		bts, err := payload.Encode()
-
-		// hook
-		var en Entry
-		en.ActionType = ActionTypeRequestCaptured
-		en.Message = "captured"
-		en.Time = time.Now()
-		en.Data = bts
-
-		if err := d.Hooks.Fire(ActionTypeRequestCaptured, &en); err != nil {
-			log.WithFields(log.Fields{
-				"error":      err.Error(),
-				"message":    en.Message,
-				"actionType": ActionTypeRequestCaptured,
-			}).Error("failed to fire hook")
+		if !isAsyncCacheWrite(req) {
+			d.storePayload(cfg, key, payload)
+			d.storeClientIP(key, req)
+			return
 		}
 
-		if err != nil {
+		// headers of returned response can change before it is stored
+		payload.Request.Headers = cloneHeader(req.Header)
+		payload.Response.Headers = cloneHeader(resp.Header)
+		if len(resp.Trailer) > 0 {
+			payload.Response.Trailers = cloneHeader(resp.Trailer)
+		}
+		stored := *req
+		stored.Header = payload.Request.Headers
+		write := func() {
+			d.storePayload(cfg, key, payload)
+			d.storeClientIP(key, &stored)
+		}
+		if !d.cacheWrites.enqueue(cfg.RecordOnceQueueSize, key, write) {
+			// writer falls behind, request waits for its record to be stored
 			log.WithFields(log.Fields{
-				"error": err.Error(),
-			}).Error("Failed to serialize payload")
-		} else {
-			d.RequestCache.Set([]byte(key), bts)
+				"key":       key,
+				"queueSize": cfg.RecordOnceQueueSize,
+			}).Warn("Background cache write queue is full, storing record synchronously")
+			write()
 		}
 	}
 }
 
+// storePayload - encodes captured payload, fires capture hooks and stores payload in request cache, in dry run
+// payload is only logged
+func (d *Hoverfly) storePayload(cfg *Configuration, key string, payload models.Payload) {
+	if cfg.DryRun {
+		d.logDryRun(cfg, key, payload)
+		return
+	}
+
+	if cfg.ResponseHistory {
+		payload = d.withHistory(key, payload)
+	}
+	cfg.minifyJSONBodies(&payload)
+
+	bts, err := payload.Encode()
+
+	// hook
+	var en Entry
+	en.ActionType = ActionTypeRequestCaptured
+	en.Message = "captured"
+	en.Time = time.Now()
+	en.Data = bts
+
+	if err := d.Hooks.Fire(ActionTypeRequestCaptured, &en); err != nil {
+		log.WithFields(log.Fields{
+			"error":      err.Error(),
+			"message":    en.Message,
+			"actionType": ActionTypeRequestCaptured,
+		}).Error("failed to fire hook")
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to serialize payload")
+	} else {
+		d.removeReplacedBodyFile(key, payload.RequestBodyFile)
+		d.RequestCache.Set([]byte(key), bts)
+		d.captureBloom.add(key)
+		d.markNegativeResponse(cfg, key, payload.Response.Status, time.Now())
+	}
+}
+
+// requestBodyHash returns hex encoded SHA-256 hash of the request body
+func requestBodyHash(body []byte) string {
+	h := sha256.Sum256(body)
+	return hex.EncodeToString(h[:])
+}
+
 // getRequestFingerprint returns request hash
 func (d *Hoverfly) getRequestFingerprint(req *http.Request, requestBody []byte) string {
+	cfg := d.requestConfig(req)
 	r := models.RequestDetails{
 		Path:        req.URL.Path,
 		Method:      req.Method,
 		Destination: req.Host,
-		Query:       req.URL.RawQuery,
+		Query:       cfg.normaliseQuery(req.URL.RawQuery),
 		Body:        string(requestBody),
 		Headers:     req.Header,
 	}
@@ -396,24 +710,79 @@ func (d *Hoverfly) getRequestFingerprint(req *http.Request, requestBody []byte)
 
 // getResponse returns stored response from cache
 func (d *Hoverfly) getResponse(req *http.Request) *http.Response {
+	cfg := d.requestConfig(req)
+
+	if resp := d.redirectChainResponse(req); resp != nil {
+		return resp
+	}
 
 	if req.Body == nil {
 		req.Body = ioutil.NopCloser(bytes.NewBuffer([]byte("")))
 	}
 
-	reqBody, err := ioutil.ReadAll(req.Body)
+	var reqBody []byte
+	var key, bodyHash string
+	var spooled *spooledBody
+	var err error
 
-	if err != nil {
-		log.WithFields(log.Fields{
-			"error": err.Error(),
-		}).Error("Got error when reading request body")
-	}
+	if cfg.StreamRequestBody {
+		// body is kept on disk, its hash is used for matching
+		spooled, err = spoolRequestBody(req.Body, cfg.RequestBodyDir)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Error("Failed to write request body to file")
+			return hoverflyError(req, err, "Failed to read request body", http.StatusInternalServerError, ErrorCodeInternal, errorFormat(req))
+		}
+		defer spooled.remove()
 
-	key := d.getRequestFingerprint(req, reqBody)
+		key = d.getStreamedRequestFingerprint(req, spooled.hash)
+		bodyHash = spooled.hash
+
+	} else {
+		reqBody, err = ioutil.ReadAll(req.Body)
+
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Error("Got error when reading request body")
+		}
+
+		// body is read again when request is forwarded or templated
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
 
+		// bodies longer than capture limit were captured truncated
+		matchedBody, _ := truncateBody(reqBody, cfg.MaxRequestBodyBytes)
+		key = d.getRequestFingerprint(req, matchedBody)
+		bodyHash = requestBodyHash(matchedBody)
+	}
+
+	end := startOperation(req, OperationCacheLookup)
 	payloadBts, err := d.RequestCache.Get([]byte(key))
 
+	// requests to page URLs are matched with the first page request
+	if err != nil {
+		if pageKey, ok := d.getPageFingerprint(req, reqBody); ok {
+			if pageBts, pageErr := d.RequestCache.Get([]byte(pageKey)); pageErr == nil {
+				key, payloadBts, err = pageKey, pageBts, nil
+			}
+		}
+	}
+
+	// requests with different body are matched by body matchers, streamed bodies are only matched by hash
+	if err != nil && spooled == nil {
+		if matchedKey, matchedBts, ok := d.matchBody(req.Method, req.Host, req.URL.Path, req.URL.RawQuery, reqBody); ok {
+			key, payloadBts, err = matchedKey, matchedBts, nil
+		}
+	}
+	if err == nil && d.negativeResponseExpired(cfg, key, time.Now()) {
+		err = errNegativeResponseExpired
+	}
+	end(err)
+
 	if err == nil {
+		metrics.CacheHit()
+
 		// getting cache response
 		payload, err := models.NewPayloadFromBytes(payloadBts)
 		if err != nil {
@@ -422,21 +791,69 @@ func (d *Hoverfly) getResponse(req *http.Request) *http.Response {
 				"value": string(payloadBts),
 				"key":   key,
 			}).Error("Failed to decode payload")
-			return hoverflyError(req, err, "Failed to simulate", http.StatusInternalServerError)
+			return hoverflyError(req, err, "Failed to simulate", http.StatusInternalServerError, ErrorCodeCacheError, errorFormat(req))
+		}
+
+		if spooled != nil && payload.RequestBodyFile != "" {
+			if err := validateStreamedBody(payload.RequestBodyFile, spooled.path); err != nil {
+				log.WithFields(log.Fields{
+					"key":   key,
+					"file":  payload.RequestBodyFile,
+					"error": err.Error(),
+				}).Warn("Streamed request body validation failed")
+				return hoverflyError(req, err, "Could not validate streamed request body", http.StatusPreconditionFailed, ErrorCodeBodyMismatch, errorFormat(req))
+			}
 		}
 
-		c := NewConstructor(req, *payload)
+		d.trackUsage(cfg, key, payload)
+		d.recentAccess.touch(d, key, time.Now())
 
-		if d.Cfg.Middleware != "" {
-			_ = c.ApplyMiddleware(d.Cfg.Middleware)
+		if payload.Paginated != nil {
+			payload, err = d.paginate(req, key, payload)
+			if err != nil {
+				return hoverflyError(req, err, "Failed to simulate paginated response", http.StatusNotFound, ErrorCodePageNotFound, errorFormat(req))
+			}
 		}
 
+		if len(payload.ResponseSequence) > 0 {
+			if i, ok := d.Weights.Pick(payload.ResponseSequence); ok {
+				payload.Response = payload.ResponseSequence[i]
+			} else {
+				payload.Response = payload.ResponseSequence[d.Sequences.Next(key, len(payload.ResponseSequence))]
+			}
+		}
+
+		c := NewConstructor(req, *payload).withMiddlewareLimits(cfg.middlewareLimits()).withTracerProvider(cfg.TracerProvider)
+
+		if cfg.GetMiddleware() != "" {
+			_ = c.ApplyMiddleware(cfg.GetMiddleware())
+			d.storeMiddlewareMetadata(key, c.payload.Metadata)
+		}
+
+		// only bodies marked as templates are rendered, others may contain '{{' on their own
+		if c.payload.Response.TemplatedBody {
+			c.payload.Response.Body = renderTemplatedBody(c.payload.Response.Body, req, reqBody, d.getMiddlewareMetadata(key))
+		}
+		cfg.wrapInEnvelope(&c.payload.Response, req)
+		cfg.padResponse(&c.payload.Response, req)
+
 		response := c.ReconstructResponse()
 
+		// request matched, although body might differ i.e. in JSON formatting
+		if cfg.WarnBodyMismatch && payload.RequestBodySHA256 != "" && payload.RequestBodySHA256 != bodyHash {
+			response.Header.Set(BodyMismatchHeader, "true")
+		}
+		if payload.TruncatedAt != nil {
+			response.Header.Set("Content-Warning", TruncatedContentWarning)
+		}
+		cfg.refreshDateHeader(response, payload, time.Now())
+		d.injectHSTSHeader(req, response)
+		d.compressForClient(req, response)
+
 		log.WithFields(log.Fields{
 			"key":         key,
 			"mode":        SimulateMode,
-			"middleware":  d.Cfg.Middleware,
+			"middleware":  cfg.GetMiddleware(),
 			"path":        req.URL.Path,
 			"rawQuery":    req.URL.RawQuery,
 			"method":      req.Method,
@@ -457,13 +874,15 @@ func (d *Hoverfly) getResponse(req *http.Request) *http.Response {
 		"destination": req.Host,
 		"method":      req.Method,
 	}).Warn("Failed to retrieve response from cache")
+	metrics.CacheMiss()
 	// return error? if we return nil - proxy forwards request to original destination
-	return hoverflyError(req, err, "Could not find recorded request, please record it first!", http.StatusPreconditionFailed)
+	return hoverflyError(req, err, "Could not find recorded request, please record it first!", http.StatusPreconditionFailed, ErrorCodeCacheMiss, errorFormat(req))
 }
 
 // modifyRequestResponse modifies outgoing request and then modifies incoming response, neither request nor response
 // is saved to cache.
 func (d *Hoverfly) modifyRequestResponse(req *http.Request, middleware string) (*http.Response, error) {
+	cfg := d.requestConfig(req)
 
 	// getting request details
 	rd, err := getRequestDetails(req)
@@ -494,14 +913,14 @@ func (d *Hoverfly) modifyRequestResponse(req *http.Request, middleware string) (
 	}
 
 	r := models.ResponseDetails{
-		Status:  resp.StatusCode,
+		Status:  d.rewriteStatus(req, resp.StatusCode, bodyBytes),
 		Body:    string(bodyBytes),
 		Headers: resp.Header,
 	}
 
 	payload := models.Payload{Response: r, Request: rd}
 
-	c := NewConstructor(req, payload)
+	c := NewConstructor(req, payload).withMiddlewareLimits(cfg.middlewareLimits()).withTracerProvider(cfg.TracerProvider)
 	// applying middleware to modify response
 	err = c.ApplyMiddleware(middleware)
 
@@ -565,10 +984,11 @@ type Hook interface {
 // ActionTypeHooks type for storing the hooks
 type ActionTypeHooks map[ActionType][]Hook
 
-// Add a hook
+// Add a hook, result of its last invocation is reported by GET /api/hooks
 func (hooks ActionTypeHooks) Add(hook Hook) {
+	tracked := &trackedHook{Hook: hook}
 	for _, ac := range hook.ActionTypes() {
-		hooks[ac] = append(hooks[ac], hook)
+		hooks[ac] = append(hooks[ac], tracked)
 	}
 }
 
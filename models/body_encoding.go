@@ -0,0 +1,93 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+)
+
+// BodyEncodingGzipBase64 - body is gzip compressed and base64 encoded
+const BodyEncodingGzipBase64 = "gzip+base64"
+
+// rawBody - returns body bytes as they were received
+func (r *ResponseDetailsView) rawBody() ([]byte, error) {
+	if r.EncodedBody {
+		return base64.StdEncoding.DecodeString(r.Body)
+	}
+	return []byte(r.Body), nil
+}
+
+// CompressBody - gzip compresses and base64 encodes body. Bodies that are already compressed (Content-Encoding
+// header or gzip data) and bodies that would not get smaller are left unchanged.
+func (r *ResponseDetailsView) CompressBody() error {
+	if r.BodyEncoding != "" || len(r.Headers["Content-Encoding"]) > 0 {
+		return nil
+	}
+
+	raw, err := r.rawBody()
+	if err != nil {
+		return err
+	}
+	if len(raw) == 0 || isGzip(raw) {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
+	if len(encoded) >= len(r.Body) {
+		return nil
+	}
+
+	r.Body = encoded
+	r.EncodedBody = false
+	r.BodyEncoding = BodyEncodingGzipBase64
+
+	return nil
+}
+
+// DecompressBody - reverts CompressBody, bodies without body encoding are left unchanged
+func (r *ResponseDetailsView) DecompressBody() error {
+	switch r.BodyEncoding {
+	case "":
+		return nil
+	case BodyEncodingGzipBase64:
+	default:
+		return fmt.Errorf("unknown body encoding '%s'", r.BodyEncoding)
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(r.Body)
+	if err != nil {
+		return err
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	raw, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return err
+	}
+
+	r.Body = string(raw)
+	r.EncodedBody = false
+	r.BodyEncoding = ""
+
+	return nil
+}
+
+func isGzip(b []byte) bool {
+	return len(b) > 1 && b[0] == 0x1f && b[1] == 0x8b
+}
@@ -0,0 +1,67 @@
+package models_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	. "github.com/onsi/gomega"
+)
+
+func TestResponseDetailsView_CompressBody(t *testing.T) {
+	RegisterTestingT(t)
+
+	body := strings.Repeat(`{"name": "hoverfly", "status": "ok"}`, 50)
+	view := models.ResponseDetailsView{Status: 200, Body: body}
+
+	Expect(view.CompressBody()).To(BeNil())
+	Expect(view.BodyEncoding).To(Equal(models.BodyEncodingGzipBase64))
+	Expect(len(view.Body) < len(body)).To(BeTrue())
+
+	Expect(view.DecompressBody()).To(BeNil())
+	Expect(view.BodyEncoding).To(Equal(""))
+	Expect(view.Body).To(Equal(body))
+}
+
+func TestResponseDetailsView_CompressBodySkipsCompressedBodies(t *testing.T) {
+	RegisterTestingT(t)
+
+	view := models.ResponseDetailsView{
+		Status:      200,
+		Body:        "H4sIAAAAAAAA/w==",
+		EncodedBody: true,
+		Headers:     map[string][]string{"Content-Encoding": []string{"gzip"}},
+	}
+
+	Expect(view.CompressBody()).To(BeNil())
+	Expect(view.BodyEncoding).To(Equal(""))
+	Expect(view.Body).To(Equal("H4sIAAAAAAAA/w=="))
+}
+
+func TestResponseDetailsView_CompressBodySkipsShortBodies(t *testing.T) {
+	RegisterTestingT(t)
+
+	view := models.ResponseDetailsView{Status: 200, Body: "ok"}
+
+	Expect(view.CompressBody()).To(BeNil())
+	Expect(view.BodyEncoding).To(Equal(""))
+	Expect(view.Body).To(Equal("ok"))
+}
+
+func TestResponseDetailsView_ConvertCompressedToResponseDetails(t *testing.T) {
+	RegisterTestingT(t)
+
+	body := strings.Repeat("hello world ", 50)
+	view := models.ResponseDetailsView{Status: 200, Body: body}
+	Expect(view.CompressBody()).To(BeNil())
+
+	details := view.ConvertToResponseDetails()
+	Expect(details.Body).To(Equal(body))
+}
+
+func TestResponseDetailsView_DecompressBodyUnknownEncoding(t *testing.T) {
+	RegisterTestingT(t)
+
+	view := models.ResponseDetailsView{Status: 200, Body: "abc", BodyEncoding: "brotli"}
+	Expect(view.DecompressBody()).ToNot(BeNil())
+}
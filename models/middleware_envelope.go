@@ -0,0 +1,8 @@
+package models
+
+// MiddlewareEnvelope - wraps payload exchanged with middleware when it is compressed, ContentEncoding tells
+// middleware how Payload is encoded. Payload is base64 encoded in JSON.
+type MiddlewareEnvelope struct {
+	ContentEncoding string `json:"contentEncoding"`
+	Payload         []byte `json:"payload"`
+}
@@ -0,0 +1,7 @@
+package models
+
+// MiddlewarePair - request and response passed to middleware plugin, plugin modifies them in place
+type MiddlewarePair struct {
+	Request  RequestDetails
+	Response ResponseDetails
+}
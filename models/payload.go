@@ -11,6 +11,7 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"net/http"
 	"strings"
+	"time"
 	"github.com/tdewolff/minify"
 	"github.com/tdewolff/minify/json"
 	"github.com/tdewolff/minify/xml"
@@ -41,6 +42,76 @@ func init() {
 type Payload struct {
 	Response ResponseDetails `json:"response"`
 	Request  RequestDetails  `json:"request"`
+
+	// RequestBodySHA256 - hex encoded SHA-256 hash of the captured request body
+	RequestBodySHA256 string `json:"requestBodySHA256,omitempty"`
+
+	// RequestBodyFile - file with captured request body, set instead of request body when bodies are streamed
+	RequestBodyFile string `json:"requestBodyFile,omitempty"`
+
+	// ResponseBodyRef - SHA-256 hash of deduplicated response body, set when body is stored separately
+	ResponseBodyRef string `json:"responseBodyRef,omitempty"`
+
+	// Paginated - when set, pages are served instead of Response
+	Paginated *PaginatedResponse `json:"paginated,omitempty"`
+
+	// ResponseSequence - when set, responses are served one after another on successive calls, the last one is
+	// repeated once the sequence is exhausted. When some of them have weight, they are selected randomly by weight
+	// instead.
+	ResponseSequence []ResponseDetails `json:"responseSequence,omitempty"`
+
+	// MaxUseCount - how many times response can be served before cache garbage collection removes it, 0 means
+	// no limit
+	MaxUseCount int `json:"maxUseCount,omitempty"`
+	// UseCount - how many times response was served, only tracked when it is needed by cache garbage collection
+	UseCount int `json:"useCount,omitempty"`
+
+	// CreatedAt and LastAccessedAt are used by cache garbage collection to find expired and idle entries
+	CreatedAt      time.Time `json:"createdAt"`
+	LastAccessedAt time.Time `json:"lastAccessedAt"`
+
+	// Metadata - key-value pairs returned by middleware, they are kept in metadata cache under the request key
+	// and are not stored together with the payload
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Version - how many times response was captured for the request, only tracked when response history is kept
+	Version int `json:"version,omitempty"`
+	// History - previously captured responses, oldest first
+	History []ResponseVersion `json:"history,omitempty"`
+
+	// TruncatedAt - set when captured bodies were longer than capture size limits and only their prefix is stored
+	TruncatedAt *BodyTruncation `json:"truncatedAt,omitempty"`
+}
+
+// BodyTruncation - sizes captured bodies were truncated at, 0 when body is stored whole
+type BodyTruncation struct {
+	RequestBody  int64 `json:"requestBody,omitempty"`
+	ResponseBody int64 `json:"responseBody,omitempty"`
+}
+
+// ResponseVersion - response that was captured for the request before it was replaced by a newer one
+type ResponseVersion struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Response  ResponseDetails `json:"response"`
+}
+
+func (v *ResponseVersion) ConvertToResponseVersionView() (ResponseVersionView) {
+	return ResponseVersionView{Version: v.Version, CreatedAt: v.CreatedAt, Response: v.Response.ConvertToResponseDetailsView()}
+}
+
+// PaginatedResponse holds responses that are served one after another for the same request, linked
+// together with 'Link' headers
+type PaginatedResponse struct {
+	Pages []ResponseDetails `json:"pages"`
+}
+
+func (p *PaginatedResponse) ConvertToPaginatedResponseView() (*PaginatedResponseView) {
+	pages := make([]ResponseDetailsView, len(p.Pages))
+	for i := range p.Pages {
+		pages[i] = p.Pages[i].ConvertToResponseDetailsView()
+	}
+	return &PaginatedResponseView{Pages: pages}
 }
 
 func (p Payload) Id() string {
@@ -59,7 +130,23 @@ func (p *Payload) Encode() ([]byte, error) {
 }
 
 func (p *Payload) ConvertToPayloadView() (*PayloadView) {
-	return &PayloadView{Response: p.Response.ConvertToResponseDetailsView(), Request: p.Request.ConvertToRequestDetailsView()}
+	view := &PayloadView{Response: p.Response.ConvertToResponseDetailsView(), Request: p.Request.ConvertToRequestDetailsView(), MaxUseCount: p.MaxUseCount, Metadata: p.Metadata, Version: p.Version, TruncatedAt: p.TruncatedAt, RequestBodySHA256: p.RequestBodySHA256, RequestBodyFile: p.RequestBodyFile, ResponseBodyRef: p.ResponseBodyRef}
+	if p.Paginated != nil {
+		view.Paginated = p.Paginated.ConvertToPaginatedResponseView()
+	}
+	for _, response := range p.ResponseSequence {
+		view.ResponseSequence = append(view.ResponseSequence, response.ConvertToResponseDetailsView())
+	}
+	return view
+}
+
+// ConvertToPayloadViewWithHistory - same as ConvertToPayloadView, previously captured responses are included
+func (p *Payload) ConvertToPayloadViewWithHistory() (*PayloadView) {
+	view := p.ConvertToPayloadView()
+	for _, version := range p.History {
+		view.History = append(view.History, version.ConvertToResponseVersionView())
+	}
+	return view
 }
 
 // NewPayloadFromBytes decodes supplied bytes into Payload structure
@@ -83,6 +170,16 @@ type RequestDetails struct {
 	Query       string              `json:"query"`
 	Body        string              `json:"body"`
 	Headers     map[string][]string `json:"headers"`
+	// BodyMatcher - when set, simulated requests with different body match as long as the matcher does
+	BodyMatcher *BodyMatcher `json:"bodyMatcher,omitempty"`
+}
+
+// BodyMatcher - matches body of simulated request, Type is "exact", "jsonpath" or "regex". JSONPath expression
+// matches when selected value equals Value, or when it exists if Value is empty.
+type BodyMatcher struct {
+	Type       string `json:"type"`
+	Expression string `json:"expression,omitempty"`
+	Value      string `json:"value,omitempty"`
 }
 
 func (r *RequestDetails) ConvertToRequestDetailsView() (RequestDetailsView) {
@@ -94,6 +191,7 @@ func (r *RequestDetails) ConvertToRequestDetailsView() (RequestDetailsView) {
 		Query: r.Query,
 		Body: r.Body,
 		Headers: r.Headers,
+		BodyMatcher: r.BodyMatcher,
 	}
 }
 
@@ -159,9 +257,17 @@ func (r *RequestDetails) getContentType() string {
 // to be bytes, however headers should provide all required information for later decoding
 // by the client.
 type ResponseDetails struct {
-	Status  int                 `json:"status"`
-	Body    string              `json:"body"`
-	Headers map[string][]string `json:"headers"`
+	Status   int                 `json:"status"`
+	Body     string              `json:"body"`
+	Headers  map[string][]string `json:"headers"`
+	// Trailers - headers sent after the body, i.e. checksums
+	Trailers map[string][]string `json:"trailers,omitempty"`
+	// TemplatedBody - body is rendered as text/template with request data and middleware metadata when response
+	// is simulated
+	TemplatedBody bool `json:"templatedBody,omitempty"`
+	// Weight - how often response is selected from response sequence relative to other weighted responses, see
+	// WeightedSelector
+	Weight int `json:"weight,omitempty"`
 }
 
 func (r *ResponseDetails) ConvertToResponseDetailsView() (ResponseDetailsView) {
@@ -188,5 +294,5 @@ func (r *ResponseDetails) ConvertToResponseDetailsView() (ResponseDetailsView) {
 		body = base64.StdEncoding.EncodeToString([]byte(r.Body))
 	}
 
-	return ResponseDetailsView{Status: r.Status, Body: body, Headers: r.Headers, EncodedBody: needsEncoding}
+	return ResponseDetailsView{Status: r.Status, Body: body, Headers: r.Headers, Trailers: r.Trailers, EncodedBody: needsEncoding, TemplatedBody: r.TemplatedBody, Weight: r.Weight}
 }
\ No newline at end of file
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/gob"
 	"encoding/base64"
+	"time"
 )
 
 type PayloadViewData struct {
@@ -12,12 +13,58 @@ type PayloadViewData struct {
 
 // PayloadView is used when marshalling and unmarshalling payloads.
 type PayloadView struct {
-	Response ResponseDetailsView `json:"response"`
-	Request  RequestDetailsView  `json:"request"`
+	Response  ResponseDetailsView    `json:"response"`
+	Request   RequestDetailsView     `json:"request"`
+	Paginated *PaginatedResponseView `json:"paginated,omitempty"`
+	ResponseSequence []ResponseDetailsView `json:"responseSequence,omitempty"`
+	MaxUseCount int                  `json:"maxUseCount,omitempty"`
+	Metadata  map[string]string      `json:"metadata,omitempty"`
+	Version   int                    `json:"version,omitempty"`
+	History   []ResponseVersionView  `json:"history,omitempty"`
+	TruncatedAt *BodyTruncation      `json:"truncatedAt,omitempty"`
+	RequestBodySHA256 string         `json:"requestBodySHA256,omitempty"`
+	RequestBodyFile string           `json:"requestBodyFile,omitempty"`
+	// ResponseBodyRef - key of response body in 'bodies' of export, set instead of response body when bodies
+	// are deduplicated
+	ResponseBodyRef string `json:"responseBodyRef,omitempty"`
+}
+
+// ResponseVersionView is used when marshalling and unmarshalling ResponseVersion
+type ResponseVersionView struct {
+	Version   int                 `json:"version"`
+	CreatedAt time.Time           `json:"createdAt"`
+	Response  ResponseDetailsView `json:"response"`
+}
+
+func (v *ResponseVersionView) ConvertToResponseVersion() (ResponseVersion) {
+	return ResponseVersion{Version: v.Version, CreatedAt: v.CreatedAt, Response: v.Response.ConvertToResponseDetails()}
 }
 
 func (r *PayloadView) ConvertToPayload() (Payload) {
-	return Payload{Response: r.Response.ConvertToResponseDetails(), Request: r.Request.ConvertToRequestDetails()}
+	payload := Payload{Response: r.Response.ConvertToResponseDetails(), Request: r.Request.ConvertToRequestDetails(), MaxUseCount: r.MaxUseCount, Metadata: r.Metadata, Version: r.Version, TruncatedAt: r.TruncatedAt, RequestBodySHA256: r.RequestBodySHA256, RequestBodyFile: r.RequestBodyFile, ResponseBodyRef: r.ResponseBodyRef}
+	if r.Paginated != nil {
+		payload.Paginated = r.Paginated.ConvertToPaginatedResponse()
+	}
+	for _, response := range r.ResponseSequence {
+		payload.ResponseSequence = append(payload.ResponseSequence, response.ConvertToResponseDetails())
+	}
+	for _, version := range r.History {
+		payload.History = append(payload.History, version.ConvertToResponseVersion())
+	}
+	return payload
+}
+
+// PaginatedResponseView is used when marshalling and unmarshalling PaginatedResponse
+type PaginatedResponseView struct {
+	Pages []ResponseDetailsView `json:"pages"`
+}
+
+func (r *PaginatedResponseView) ConvertToPaginatedResponse() (*PaginatedResponse) {
+	pages := make([]ResponseDetails, len(r.Pages))
+	for i := range r.Pages {
+		pages[i] = r.Pages[i].ConvertToResponseDetails()
+	}
+	return &PaginatedResponse{Pages: pages}
 }
 
 // Encode method encodes all exported Payload fields to bytes
@@ -40,6 +87,7 @@ type RequestDetailsView struct {
 	Query       string              `json:"query"`
 	Body        string              `json:"body"`
 	Headers     map[string][]string `json:"headers"`
+	BodyMatcher *BodyMatcher        `json:"bodyMatcher,omitempty"`
 }
 
 func (r *RequestDetailsView) ConvertToRequestDetails() (RequestDetails) {
@@ -51,6 +99,7 @@ func (r *RequestDetailsView) ConvertToRequestDetails() (RequestDetails) {
 		Query: r.Query,
 		Body: r.Body,
 		Headers: r.Headers,
+		BodyMatcher: r.BodyMatcher,
 	}
 }
 
@@ -61,7 +110,15 @@ type ResponseDetailsView struct {
 	Status      int                 `json:"status"`
 	Body        string              `json:"body"`
 	EncodedBody bool                `json:"encodedBody"`
+	// BodyEncoding - set when body is compressed, see CompressBody
+	BodyEncoding string             `json:"bodyEncoding,omitempty"`
 	Headers     map[string][]string `json:"headers"`
+	Trailers    map[string][]string `json:"trailers,omitempty"`
+	// TemplatedBody - body is rendered as text/template with request data and middleware metadata when response
+	// is simulated
+	TemplatedBody bool              `json:"templatedBody,omitempty"`
+	// Weight - selection weight of response in response sequence
+	Weight int                      `json:"weight,omitempty"`
 }
 
 func (r *ResponseDetailsView) ConvertToResponseDetails() (ResponseDetails) {
@@ -72,5 +129,12 @@ func (r *ResponseDetailsView) ConvertToResponseDetails() (ResponseDetails) {
 		body = string(decoded)
 	}
 
-	return ResponseDetails{Status: r.Status, Body: body, Headers: r.Headers}
+	if r.BodyEncoding != "" {
+		decompressed := *r
+		if err := decompressed.DecompressBody(); err == nil {
+			body = decompressed.Body
+		}
+	}
+
+	return ResponseDetails{Status: r.Status, Body: body, Headers: r.Headers, Trailers: r.Trailers, TemplatedBody: r.TemplatedBody, Weight: r.Weight}
 }
@@ -93,4 +93,48 @@ func TestPayloadViewData_ConvertToPayloadDataWithEncoding(t *testing.T) {
 	payload := view.ConvertToPayload()
 
 	Expect(payload.Response.Body).To(Equal("encoded"))
-}
\ No newline at end of file
+}
+func TestPayloadView_ConvertToPayloadWithPages(t *testing.T) {
+	RegisterTestingT(t)
+
+	view := models.PayloadView{
+		Request: models.RequestDetailsView{Path: "/items", Method: "GET", Destination: "example.com"},
+		Paginated: &models.PaginatedResponseView{
+			Pages: []models.ResponseDetailsView{
+				{Status: 200, Body: "page one"},
+				{Status: 200, Body: "cGFnZSB0d28=", EncodedBody: true},
+			},
+		},
+	}
+
+	payload := view.ConvertToPayload()
+
+	Expect(payload.Paginated).ToNot(BeNil())
+	Expect(payload.Paginated.Pages).To(HaveLen(2))
+	Expect(payload.Paginated.Pages[0].Body).To(Equal("page one"))
+	Expect(payload.Paginated.Pages[1].Body).To(Equal("page two"))
+}
+
+func TestPayloadView_KeepsBodyReferences(t *testing.T) {
+	RegisterTestingT(t)
+
+	payload := models.Payload{
+		Request:           models.RequestDetails{Path: "/upload", Method: "POST", Destination: "example.com"},
+		Response:          models.ResponseDetails{Status: 201},
+		RequestBodySHA256: "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+		RequestBodyFile:   "/tmp/hoverfly-body-1",
+		ResponseBodyRef:   "fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9",
+	}
+
+	view := payload.ConvertToPayloadView()
+
+	Expect(view.RequestBodySHA256).To(Equal(payload.RequestBodySHA256))
+	Expect(view.RequestBodyFile).To(Equal(payload.RequestBodyFile))
+	Expect(view.ResponseBodyRef).To(Equal(payload.ResponseBodyRef))
+
+	converted := view.ConvertToPayload()
+
+	Expect(converted.RequestBodySHA256).To(Equal(payload.RequestBodySHA256))
+	Expect(converted.RequestBodyFile).To(Equal(payload.RequestBodyFile))
+	Expect(converted.ResponseBodyRef).To(Equal(payload.ResponseBodyRef))
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/SpectoLabs/hoverfly/testutil"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 	"github.com/SpectoLabs/hoverfly/models"
@@ -20,6 +22,9 @@ func TestMain(m *testing.M) {
 
 	// delete test database
 	teardown()
+	if middlewarePluginBuild.dir != "" {
+		os.RemoveAll(middlewarePluginBuild.dir)
+	}
 
 	// call with result of m.Run()
 	os.Exit(retCode)
@@ -373,7 +378,8 @@ func TestUpdateDestination(t *testing.T) {
 	testutil.Expect(t, err, nil)
 	dbClient.UpdateDestination("newdest")
 
-	testutil.Expect(t, dbClient.Cfg.Destination, "newdest")
+	testutil.Expect(t, len(dbClient.Cfg.Destinations), 1)
+	testutil.Expect(t, dbClient.Cfg.Destinations[0], "newdest")
 }
 
 func TestUpdateDestinationEmpty(t *testing.T) {
@@ -464,3 +470,100 @@ func TestXMLMinifierWOHeader(t *testing.T) {
 
 
 
+
+func TestRequestBodyHashCaptured(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	requestBody := []byte(`{"fizz": "buzz"}`)
+
+	req, err := http.NewRequest("POST", "http://capture_body.com", ioutil.NopCloser(bytes.NewBuffer(requestBody)))
+	testutil.Expect(t, err, nil)
+
+	_, err = dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+
+	payloadBts, err := dbClient.RequestCache.Get([]byte(dbClient.getRequestFingerprint(req, requestBody)))
+	testutil.Expect(t, err, nil)
+
+	payload, err := models.NewPayloadFromBytes(payloadBts)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, payload.RequestBodySHA256, requestBodyHash(requestBody))
+}
+
+func TestBodyMismatchHeader(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.WarnBodyMismatch = true
+
+	captured := []byte(`{"fizz": "buzz"}`)
+	headers := map[string][]string{"Content-Type": []string{"application/json"}}
+
+	req, err := http.NewRequest("POST", "http://capture_body.com", ioutil.NopCloser(bytes.NewBuffer(captured)))
+	testutil.Expect(t, err, nil)
+	req.Header = headers
+
+	_, err = dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+
+	// same body - no warning
+	req, err = http.NewRequest("POST", "http://capture_body.com", ioutil.NopCloser(bytes.NewBuffer(captured)))
+	testutil.Expect(t, err, nil)
+	req.Header = headers
+
+	response := dbClient.getResponse(req)
+	testutil.Expect(t, response.StatusCode, 200)
+	testutil.Expect(t, response.Header.Get(BodyMismatchHeader), "")
+
+	// body matches only after minification - warning expected
+	req, err = http.NewRequest("POST", "http://capture_body.com", ioutil.NopCloser(bytes.NewBuffer([]byte(`{"fizz":"buzz"}`))))
+	testutil.Expect(t, err, nil)
+	req.Header = headers
+
+	response = dbClient.getResponse(req)
+	testutil.Expect(t, response.StatusCode, 200)
+	testutil.Expect(t, response.Header.Get(BodyMismatchHeader), "true")
+}
+
+func TestStripAuthHeaders(t *testing.T) {
+	var forwarded http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header
+		w.WriteHeader(200)
+	}))
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.HTTP = &http.Client{Transport: &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return url.Parse(upstream.URL)
+		},
+	}}
+	dbClient.Cfg.StripAuthHeaders = true
+
+	req, err := http.NewRequest("GET", "http://capture_auth.com", nil)
+	testutil.Expect(t, err, nil)
+	req.SetBasicAuth("user", "secret")
+	req.Header.Set("Proxy-Authorization", "Basic c2VjcmV0")
+
+	_, err = dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+
+	testutil.Expect(t, forwarded.Get("Authorization"), "")
+	testutil.Expect(t, forwarded.Get("Proxy-Authorization"), "")
+
+	payloadBts, err := dbClient.RequestCache.Get([]byte(dbClient.getRequestFingerprint(req, []byte(""))))
+	testutil.Expect(t, err, nil)
+
+	payload, err := models.NewPayloadFromBytes(payloadBts)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, http.Header(payload.Request.Headers).Get("Authorization"), RedactedAuthorization)
+	_, found := payload.Request.Headers["Proxy-Authorization"]
+	testutil.Expect(t, found, false)
+}
@@ -0,0 +1,47 @@
+package hoverfly
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// MutualTLSConfig - client certificate presented to upstreams that require it, CACertFile is used to verify
+// upstream certificates instead of system roots when it is set
+type MutualTLSConfig struct {
+	CertFile   string
+	KeyFile    string
+	CACertFile string
+}
+
+// upstreamTLSConfig - TLS configuration of upstream connections, with client certificate when MutualTLS is set
+func (c *Configuration) upstreamTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: c.TLSVerification}
+	if c.MutualTLS == nil {
+		return config, nil
+	}
+
+	if c.MutualTLS.CertFile == "" || c.MutualTLS.KeyFile == "" {
+		return nil, fmt.Errorf("mutual TLS needs both client certificate and key file")
+	}
+	cert, err := tls.LoadX509KeyPair(c.MutualTLS.CertFile, c.MutualTLS.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mutual TLS client certificate: %s", err.Error())
+	}
+	config.Certificates = []tls.Certificate{cert}
+
+	if c.MutualTLS.CACertFile != "" {
+		pem, err := ioutil.ReadFile(c.MutualTLS.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mutual TLS CA certificate: %s", err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in mutual TLS CA certificate file '%s'", c.MutualTLS.CACertFile)
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
@@ -0,0 +1,107 @@
+package hoverfly
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/certs"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// mutualTLSUpstream - TLS server that requires client certificate, it responds with common name of the presented
+// certificate. Its own certificate is written to 'server.pem' in given directory.
+func mutualTLSUpstream(t *testing.T, dir string) *httptest.Server {
+	cert, key, err := certs.NewSelfSignedCertificate([]string{"127.0.0.1"}, "upstream", time.Hour)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, certs.SaveCertificatePair(cert, key, filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem")), nil)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}},
+		ClientAuth:   tls.RequireAnyClientCert,
+	}
+	server.StartTLS()
+	return server
+}
+
+// mutualTLSClientFiles - writes client certificate and key, returns their paths
+func mutualTLSClientFiles(t *testing.T, dir string) (string, string) {
+	cert, key, err := certs.NewSelfSignedCertificate([]string{"hoverfly-client"}, "client", time.Hour)
+	testutil.Expect(t, err, nil)
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	testutil.Expect(t, certs.SaveCertificatePair(cert, key, certFile, keyFile), nil)
+	return certFile, keyFile
+}
+
+func TestMutualTLSPresentsClientCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hoverfly_mtls")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	upstream := mutualTLSUpstream(t, dir)
+	defer upstream.Close()
+	certFile, keyFile := mutualTLSClientFiles(t, dir)
+
+	cfg := InitSettings()
+	// upstream certificate is verified against given CA
+	cfg.TLSVerification = false
+	cfg.MutualTLS = &MutualTLSConfig{CertFile: certFile, KeyFile: keyFile, CACertFile: filepath.Join(dir, "server.pem")}
+
+	h, err := NewHoverfly(WithConfiguration(cfg))
+	testutil.Expect(t, err, nil)
+
+	resp, err := h.HTTP.Get(upstream.URL)
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "hoverfly-client")
+
+	// requests that Hoverfly doesn't process are forwarded with the same certificate
+	testutil.Expect(t, len(h.Proxy.Tr.TLSClientConfig.Certificates), 1)
+}
+
+func TestMutualTLSRequiredByUpstream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hoverfly_mtls")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	upstream := mutualTLSUpstream(t, dir)
+	defer upstream.Close()
+
+	h, err := NewHoverfly(WithConfiguration(InitSettings()))
+	testutil.Expect(t, err, nil)
+
+	_, err = h.HTTP.Get(upstream.URL)
+	testutil.Refute(t, err, nil)
+}
+
+func TestMutualTLSInvalidConfiguration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hoverfly_mtls")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := mutualTLSClientFiles(t, dir)
+	notPEM := filepath.Join(dir, "ca.txt")
+	testutil.Expect(t, ioutil.WriteFile(notPEM, []byte("not a certificate"), 0644), nil)
+
+	for _, mtls := range []*MutualTLSConfig{
+		{CertFile: certFile},
+		{CertFile: certFile, KeyFile: filepath.Join(dir, "missing.pem")},
+		{CertFile: certFile, KeyFile: keyFile, CACertFile: filepath.Join(dir, "missing.pem")},
+		{CertFile: certFile, KeyFile: keyFile, CACertFile: notPEM},
+	} {
+		cfg := InitSettings()
+		cfg.MutualTLS = mtls
+		_, err := NewHoverfly(WithConfiguration(cfg))
+		testutil.Refute(t, err, nil)
+	}
+}
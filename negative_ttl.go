@@ -0,0 +1,53 @@
+package hoverfly
+
+import (
+	"errors"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// negativeResponseExpiryKeyPrefix - prefix for metadata keys that hold expiry time of captured error responses
+const negativeResponseExpiryKeyPrefix = "negative_response_expiry_"
+
+var errNegativeResponseExpired = errors.New("recorded error response expired")
+
+// markNegativeResponse - stores expiry time of captured response with status 400 or higher when NegativeResponseTTL
+// is set, expiry of previously captured error response is removed when it is replaced by successful one
+func (d *Hoverfly) markNegativeResponse(cfg *Configuration, key string, status int, now time.Time) {
+	if cfg.NegativeResponseTTL <= 0 {
+		return
+	}
+
+	metadataKey := []byte(negativeResponseExpiryKeyPrefix + key)
+	var err error
+	if status >= 400 {
+		err = d.MetadataCache.Set(metadataKey, []byte(now.Add(cfg.NegativeResponseTTL).Format(time.RFC3339Nano)))
+	} else {
+		err = d.MetadataCache.Delete(metadataKey)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Warn("Failed to store negative response expiry")
+	}
+}
+
+// negativeResponseExpired - whether recorded error response expired, such responses are treated as not recorded
+func (d *Hoverfly) negativeResponseExpired(cfg *Configuration, key string, now time.Time) bool {
+	if cfg.NegativeResponseTTL <= 0 {
+		return false
+	}
+
+	bts, err := d.MetadataCache.Get([]byte(negativeResponseExpiryKeyPrefix + key))
+	if err != nil {
+		return false
+	}
+
+	expiry, err := time.Parse(time.RFC3339Nano, string(bts))
+	if err != nil {
+		return false
+	}
+	return !now.Before(expiry)
+}
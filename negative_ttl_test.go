@@ -0,0 +1,66 @@
+package hoverfly
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func captureAndSimulate(t *testing.T, status int, ttl, wait time.Duration) *http.Response {
+	server, dbClient := testTools(status, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	defer dbClient.MetadataCache.DeleteData()
+
+	dbClient.Cfg.NegativeResponseTTL = ttl
+
+	req, err := http.NewRequest("GET", "http://example.com/negative", nil)
+	testutil.Expect(t, err, nil)
+	_, err = dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+
+	time.Sleep(wait)
+
+	req, err = http.NewRequest("GET", "http://example.com/negative", nil)
+	testutil.Expect(t, err, nil)
+	return dbClient.getResponse(req)
+}
+
+func TestNegativeResponseExpires(t *testing.T) {
+	resp := captureAndSimulate(t, http.StatusNotFound, 50*time.Millisecond, 100*time.Millisecond)
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+	testutil.Expect(t, resp.Header.Get(ErrorCodeHeader), string(ErrorCodeCacheMiss))
+}
+
+func TestNegativeResponseSimulatedBeforeExpiry(t *testing.T) {
+	resp := captureAndSimulate(t, http.StatusNotFound, time.Minute, 0)
+	testutil.Expect(t, resp.StatusCode, http.StatusNotFound)
+}
+
+func TestSuccessfulResponseDoesNotExpire(t *testing.T) {
+	resp := captureAndSimulate(t, http.StatusOK, 50*time.Millisecond, 100*time.Millisecond)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+}
+
+func TestNegativeResponseKeptWithoutTTL(t *testing.T) {
+	resp := captureAndSimulate(t, http.StatusInternalServerError, 0, 10*time.Millisecond)
+	testutil.Expect(t, resp.StatusCode, http.StatusInternalServerError)
+}
+
+func TestNegativeResponseExpiryClearedBySuccessfulCapture(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+
+	dbClient.Cfg.NegativeResponseTTL = time.Minute
+	now := time.Now()
+
+	dbClient.markNegativeResponse(dbClient.Cfg, "key", http.StatusNotFound, now)
+	testutil.Expect(t, dbClient.negativeResponseExpired(dbClient.Cfg, "key", now.Add(30*time.Second)), false)
+	testutil.Expect(t, dbClient.negativeResponseExpired(dbClient.Cfg, "key", now.Add(time.Minute)), true)
+
+	dbClient.markNegativeResponse(dbClient.Cfg, "key", http.StatusOK, now)
+	testutil.Expect(t, dbClient.negativeResponseExpired(dbClient.Cfg, "key", now.Add(time.Hour)), false)
+}
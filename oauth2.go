@@ -0,0 +1,200 @@
+package hoverfly
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rusenask/goproxy"
+)
+
+// defaultOAuth2TokenLifetime - lifetime of fake access tokens when it is not configured
+const defaultOAuth2TokenLifetime = 3600
+
+// OAuth2Config - simulated OAuth2 authorization server. In simulate mode requests to TokenURL get fake access
+// token, requests to protected endpoints (host + path patterns, every other request when not set) are
+// rejected with 401 unless they carry valid fake token in 'Authorization: Bearer' header.
+type OAuth2Config struct {
+	// TokenURL - host and path of token endpoint, i.e. 'auth.example.com/oauth/token'
+	TokenURL string `json:"tokenURL"`
+	// LifetimeSeconds - how long issued token is valid, same token is returned until it expires
+	LifetimeSeconds    int      `json:"lifetimeSeconds"`
+	ProtectedEndpoints []string `json:"protectedEndpoints"`
+
+	// compiled ProtectedEndpoints, set by SetSimulatedOAuth2
+	protected []*regexp.Regexp
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+	now     func() time.Time
+}
+
+// oauth2TokenResponse - token endpoint response, RFC 6749 section 5.1
+type oauth2TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// oauth2ErrorResponse - protected endpoint error response, RFC 6750 section 3
+type oauth2ErrorResponse struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+// LoadSimulatedOAuth2 - reads simulated OAuth2 configuration from JSON file, i.e.:
+//
+//	{"tokenURL": "auth.example.com/oauth/token", "lifetimeSeconds": 300, "protectedEndpoints": ["api.example.com"]}
+func (c *Configuration) LoadSimulatedOAuth2(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var oauth2 OAuth2Config
+	if err := json.NewDecoder(f).Decode(&oauth2); err != nil {
+		return fmt.Errorf("failed to parse simulated OAuth2 file: %s", err.Error())
+	}
+
+	return c.SetSimulatedOAuth2(&oauth2)
+}
+
+// SetSimulatedOAuth2 - validates simulated OAuth2 configuration and compiles protected endpoint patterns
+func (c *Configuration) SetSimulatedOAuth2(oauth2 *OAuth2Config) error {
+	if oauth2.TokenURL == "" {
+		return fmt.Errorf("simulated OAuth2 tokenURL is required")
+	}
+	if oauth2.LifetimeSeconds < 0 {
+		return fmt.Errorf("invalid simulated OAuth2 lifetimeSeconds %d, must not be negative", oauth2.LifetimeSeconds)
+	}
+	protected, err := compilePatterns("simulated OAuth2 protected endpoint", oauth2.ProtectedEndpoints)
+	if err != nil {
+		return err
+	}
+	oauth2.protected = protected
+
+	c.SimulatedOAuth2 = oauth2
+
+	return nil
+}
+
+// simulateOAuth2 - returns token response for token requests and error response for protected endpoint requests
+// without valid token, nil means that request should be simulated as usual
+func (o *OAuth2Config) simulateOAuth2(req *http.Request) *http.Response {
+	endpoint := req.Host + req.URL.Path
+
+	if strings.TrimSuffix(endpoint, "/") == o.tokenEndpoint() {
+		return o.tokenResponse(req)
+	}
+
+	if !o.isProtected(endpoint) {
+		return nil
+	}
+
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if err := o.validate(token); err != nil {
+		log.WithFields(log.Fields{
+			"destination": req.Host,
+			"path":        req.URL.Path,
+			"error":       err.Error(),
+		}).Info("Rejected request to OAuth2 protected endpoint")
+		return oauth2Error(req, err.Error())
+	}
+	return nil
+}
+
+// tokenEndpoint - host and path of TokenURL, scheme and trailing slash are not compared
+func (o *OAuth2Config) tokenEndpoint() string {
+	endpoint := strings.TrimPrefix(strings.TrimPrefix(o.TokenURL, "https://"), "http://")
+	return strings.TrimSuffix(endpoint, "/")
+}
+
+func (o *OAuth2Config) isProtected(endpoint string) bool {
+	if len(o.ProtectedEndpoints) == 0 {
+		return true
+	}
+	for _, re := range o.protected {
+		if re.MatchString(endpoint) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *OAuth2Config) clock() time.Time {
+	if o.now != nil {
+		return o.now()
+	}
+	return time.Now()
+}
+
+func (o *OAuth2Config) lifetime() time.Duration {
+	if o.LifetimeSeconds == 0 {
+		return defaultOAuth2TokenLifetime * time.Second
+	}
+	return time.Duration(o.LifetimeSeconds) * time.Second
+}
+
+// issue - returns current token, new one is generated when there is none or it expired
+func (o *OAuth2Config) issue() (string, time.Duration, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := o.clock()
+	if o.token == "" || !now.Before(o.expires) {
+		raw := make([]byte, 16)
+		if _, err := rand.Read(raw); err != nil {
+			return "", 0, err
+		}
+		o.token = "hoverfly-" + hex.EncodeToString(raw)
+		o.expires = now.Add(o.lifetime())
+	}
+	return o.token, o.expires.Sub(now), nil
+}
+
+func (o *OAuth2Config) validate(token string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if token == "" || token != o.token {
+		return fmt.Errorf("invalid access token")
+	}
+	if !o.clock().Before(o.expires) {
+		return fmt.Errorf("access token expired")
+	}
+	return nil
+}
+
+func (o *OAuth2Config) tokenResponse(req *http.Request) *http.Response {
+	token, expiresIn, err := o.issue()
+	if err != nil {
+		return hoverflyError(req, err, "Failed to issue simulated OAuth2 token", http.StatusInternalServerError, ErrorCodeInternal, errorFormat(req))
+	}
+
+	body, _ := json.Marshal(oauth2TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(expiresIn / time.Second),
+	})
+	resp := goproxy.NewResponse(req, "application/json", http.StatusOK, string(body))
+	resp.Header.Set("Cache-Control", "no-store")
+	return resp
+}
+
+func oauth2Error(req *http.Request, description string) *http.Response {
+	body, _ := json.Marshal(oauth2ErrorResponse{Error: "invalid_token", Description: description})
+	resp := goproxy.NewResponse(req, "application/json", http.StatusUnauthorized, string(body))
+	resp.Header.Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description="%s"`, description))
+	resp.Header.Set(ErrorCodeHeader, string(ErrorCodeUnauthorized))
+	return resp
+}
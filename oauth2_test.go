@@ -0,0 +1,138 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func oauth2Hoverfly(t *testing.T) (*Hoverfly, func()) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	dbClient.Cfg.SetMode(SimulateMode)
+	err := dbClient.Cfg.SetSimulatedOAuth2(&OAuth2Config{
+		TokenURL:           "https://auth.example.com/oauth/token",
+		LifetimeSeconds:    60,
+		ProtectedEndpoints: []string{"api.example.com"},
+	})
+	testutil.Expect(t, err, nil)
+
+	payload := models.Payload{
+		Request:  models.RequestDetails{Method: "GET", Destination: "api.example.com", Path: "/orders"},
+		Response: models.ResponseDetails{Status: 200, Body: "orders"},
+	}
+	err = dbClient.ImportPayloads([]models.PayloadView{*payload.ConvertToPayloadView()})
+	testutil.Expect(t, err, nil)
+
+	return dbClient, func() {
+		dbClient.RequestCache.DeleteData()
+		server.Close()
+	}
+}
+
+func requestOAuth2Token(t *testing.T, dbClient *Hoverfly) oauth2TokenResponse {
+	req, err := http.NewRequest("POST", "http://auth.example.com/oauth/token", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+
+	var token oauth2TokenResponse
+	testutil.Expect(t, json.NewDecoder(resp.Body).Decode(&token), nil)
+	return token
+}
+
+func getOrders(t *testing.T, dbClient *Hoverfly, token string) *http.Response {
+	req, err := http.NewRequest("GET", "http://api.example.com/orders", nil)
+	testutil.Expect(t, err, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	_, resp := dbClient.processRequest(req)
+	return resp
+}
+
+func TestSimulatedOAuth2Token(t *testing.T) {
+	dbClient, cleanup := oauth2Hoverfly(t)
+	defer cleanup()
+
+	token := requestOAuth2Token(t, dbClient)
+	testutil.Refute(t, token.AccessToken, "")
+	testutil.Expect(t, token.TokenType, "Bearer")
+	testutil.Expect(t, token.ExpiresIn, 60)
+
+	// token is cached until it expires
+	testutil.Expect(t, requestOAuth2Token(t, dbClient).AccessToken, token.AccessToken)
+
+	resp := getOrders(t, dbClient, token.AccessToken)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "orders")
+}
+
+func TestSimulatedOAuth2RejectsInvalidToken(t *testing.T) {
+	dbClient, cleanup := oauth2Hoverfly(t)
+	defer cleanup()
+
+	requestOAuth2Token(t, dbClient)
+
+	resp := getOrders(t, dbClient, "")
+	testutil.Expect(t, resp.StatusCode, http.StatusUnauthorized)
+	testutil.Expect(t, resp.Header.Get("WWW-Authenticate"), `Bearer error="invalid_token", error_description="invalid access token"`)
+
+	resp = getOrders(t, dbClient, "forged")
+	testutil.Expect(t, resp.StatusCode, http.StatusUnauthorized)
+}
+
+func TestSimulatedOAuth2RejectsExpiredToken(t *testing.T) {
+	dbClient, cleanup := oauth2Hoverfly(t)
+	defer cleanup()
+
+	now := time.Now()
+	dbClient.Cfg.SimulatedOAuth2.now = func() time.Time { return now }
+	token := requestOAuth2Token(t, dbClient)
+
+	now = now.Add(61 * time.Second)
+	resp := getOrders(t, dbClient, token.AccessToken)
+	testutil.Expect(t, resp.StatusCode, http.StatusUnauthorized)
+
+	var oauthErr oauth2ErrorResponse
+	testutil.Expect(t, json.NewDecoder(resp.Body).Decode(&oauthErr), nil)
+	testutil.Expect(t, oauthErr.Description, "access token expired")
+
+	// new token is issued after expiry
+	testutil.Refute(t, requestOAuth2Token(t, dbClient).AccessToken, token.AccessToken)
+}
+
+func TestSimulatedOAuth2UnprotectedEndpoint(t *testing.T) {
+	dbClient, cleanup := oauth2Hoverfly(t)
+	defer cleanup()
+
+	req, err := http.NewRequest("GET", "http://other.example.com/", nil)
+	testutil.Expect(t, err, nil)
+	_, resp := dbClient.processRequest(req)
+	// not recorded, but not rejected by simulated OAuth2 either
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+}
+
+func TestLoadSimulatedOAuth2(t *testing.T) {
+	f, err := ioutil.TempFile("", "oauth2")
+	testutil.Expect(t, err, nil)
+	defer os.Remove(f.Name())
+	f.WriteString(`{"tokenURL": "auth.example.com/token", "protectedEndpoints": ["api[.example.com"]}`)
+	f.Close()
+
+	cfg := InitSettings()
+	testutil.Refute(t, cfg.LoadSimulatedOAuth2(f.Name()), nil)
+
+	ioutil.WriteFile(f.Name(), []byte(`{"tokenURL": "auth.example.com/token"}`), 0644)
+	testutil.Expect(t, cfg.LoadSimulatedOAuth2(f.Name()), nil)
+	testutil.Expect(t, cfg.SimulatedOAuth2.tokenEndpoint(), "auth.example.com/token")
+	testutil.Expect(t, cfg.SimulatedOAuth2.lifetime(), time.Hour)
+}
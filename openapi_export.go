@@ -0,0 +1,432 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+	"gopkg.in/yaml.v2"
+)
+
+// OpenAPIVersion - version of OpenAPI specification ExportOpenAPIv3 writes
+const OpenAPIVersion = "3.0.3"
+
+// openAPIMethods - methods OpenAPI operations can be defined for, records with other methods are left out
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// binarySchema - schema of bodies that aren't JSON
+func binarySchema() yaml.MapSlice {
+	return yaml.MapSlice{{Key: "type", Value: "string"}, {Key: "format", Value: "binary"}}
+}
+
+// openAPISchema - OpenAPI 3.0 schema of values merged into node, binary adds schema of bodies that aren't JSON.
+// OpenAPI 3.0 schemas have a single type, so 'null' becomes 'nullable' and other types are variants of 'oneOf'.
+func openAPISchema(n *schemaNode, binary bool) yaml.MapSlice {
+	var variants []interface{}
+	if binary {
+		variants = append(variants, binarySchema())
+	}
+
+	nullable := false
+	if n != nil {
+		nullable = n.types["null"]
+		for _, t := range schemaNodeTypes(n) {
+			if t != "null" {
+				variants = append(variants, openAPITypeSchema(n, t))
+			}
+		}
+	}
+
+	s := yaml.MapSlice{}
+	switch len(variants) {
+	case 0:
+	case 1:
+		s = variants[0].(yaml.MapSlice)
+	default:
+		s = yaml.MapSlice{{Key: "oneOf", Value: variants}}
+	}
+	if nullable {
+		s = append(s, yaml.MapItem{Key: "nullable", Value: true})
+	}
+	return s
+}
+
+// schemaNodeTypes - sorted types of node, integers are numbers as well
+func schemaNodeTypes(n *schemaNode) []string {
+	types := make([]string, 0, len(n.types))
+	for t := range n.types {
+		if t == "integer" && n.types["number"] {
+			continue
+		}
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// openAPITypeSchema - schema of values of node with given type, fields present in every object are required
+func openAPITypeSchema(n *schemaNode, t string) yaml.MapSlice {
+	s := yaml.MapSlice{{Key: "type", Value: t}}
+
+	switch t {
+	case "object":
+		names := make([]string, 0, len(n.properties))
+		for name := range n.properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		properties := yaml.MapSlice{}
+		var required []string
+		for _, name := range names {
+			properties = append(properties, yaml.MapItem{Key: name, Value: openAPISchema(n.properties[name], false)})
+			if n.seen[name] == n.objects {
+				required = append(required, name)
+			}
+		}
+		s = append(s, yaml.MapItem{Key: "properties", Value: properties})
+		if len(required) > 0 {
+			s = append(s, yaml.MapItem{Key: "required", Value: required})
+		}
+	case "array":
+		// only empty arrays were seen, anything is allowed
+		s = append(s, yaml.MapItem{Key: "items", Value: openAPISchema(n.items, false)})
+	}
+	return s
+}
+
+// openAPIContent - structure and distinct examples of bodies with the same media type, binary is set when some of
+// them weren't JSON
+type openAPIContent struct {
+	node     *schemaNode
+	binary   bool
+	examples []interface{}
+	seen     map[string]bool
+}
+
+// addJSON - merges decoded JSON body into schema, body is kept as example unless the same one was seen already
+func (c *openAPIContent) addJSON(value interface{}, raw string) {
+	if c.node == nil {
+		c.node = newSchemaNode()
+	}
+	c.node.merge(value)
+	if c.seen[raw] {
+		return
+	}
+	if c.seen == nil {
+		c.seen = make(map[string]bool)
+	}
+	c.seen[raw] = true
+	c.examples = append(c.examples, yamlValue(value))
+}
+
+func (c *openAPIContent) yaml() yaml.MapSlice {
+	m := yaml.MapSlice{{Key: "schema", Value: openAPISchema(c.node, c.binary)}}
+	if len(c.examples) > 0 {
+		examples := yaml.MapSlice{}
+		for i, example := range c.examples {
+			examples = append(examples, yaml.MapItem{Key: fmt.Sprintf("example%d", i+1), Value: yaml.MapSlice{{Key: "value", Value: example}}})
+		}
+		m = append(m, yaml.MapItem{Key: "examples", Value: examples})
+	}
+	return m
+}
+
+// yamlValue - JSON value decoded with json.Decoder.UseNumber with numbers YAML writes as numbers
+func yamlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = yamlValue(item)
+		}
+		return items
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[k] = yamlValue(item)
+		}
+		return m
+	}
+	return value
+}
+
+// openAPIBodies - bodies keyed on media type
+type openAPIBodies map[string]*openAPIContent
+
+// add - adds body with given headers, JSON bodies add their schema and example, other bodies are binary strings
+func (b openAPIBodies) add(headers map[string][]string, body []byte) {
+	header := http.Header(headers)
+	if strings.EqualFold(header.Get("Content-Encoding"), "gzip") {
+		if decoded, err := gunzip(body); err == nil {
+			body = decoded
+		}
+	}
+
+	mediaType := ""
+	if t, _, err := mime.ParseMediaType(header.Get("Content-Type")); err == nil {
+		mediaType = t
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	isJSON := len(bytes.TrimSpace(body)) > 0 && decoder.Decode(&value) == nil && !decoder.More()
+
+	if mediaType == "" {
+		mediaType = "application/octet-stream"
+		if isJSON {
+			mediaType = "application/json"
+		}
+	}
+
+	content, ok := b[mediaType]
+	if !ok {
+		content = &openAPIContent{}
+		b[mediaType] = content
+	}
+
+	if !isJSON {
+		content.binary = true
+		return
+	}
+	canonical, _ := json.Marshal(value)
+	content.addJSON(value, string(canonical))
+}
+
+func (b openAPIBodies) yaml() yaml.MapSlice {
+	types := make([]string, 0, len(b))
+	for t := range b {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	m := yaml.MapSlice{}
+	for _, t := range types {
+		m = append(m, yaml.MapItem{Key: t, Value: b[t].yaml()})
+	}
+	return m
+}
+
+// openAPIOperation - records captured for the same path and method
+type openAPIOperation struct {
+	query     map[string]bool
+	request   openAPIBodies
+	responses map[int]openAPIBodies
+}
+
+func (o *openAPIOperation) add(payload *models.Payload) {
+	if values, err := url.ParseQuery(payload.Request.Query); err == nil {
+		for name := range values {
+			o.query[name] = true
+		}
+	}
+
+	if payload.Request.Body != "" {
+		o.request.add(payload.Request.Headers, []byte(payload.Request.Body))
+	}
+
+	bodies, ok := o.responses[payload.Response.Status]
+	if !ok {
+		bodies = openAPIBodies{}
+		o.responses[payload.Response.Status] = bodies
+	}
+	if payload.Response.Body != "" {
+		bodies.add(payload.Response.Headers, []byte(payload.Response.Body))
+	}
+}
+
+func (o *openAPIOperation) yaml() yaml.MapSlice {
+	m := yaml.MapSlice{}
+
+	if len(o.query) > 0 {
+		names := make([]string, 0, len(o.query))
+		for name := range o.query {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		parameters := make([]interface{}, 0, len(names))
+		for _, name := range names {
+			parameters = append(parameters, yaml.MapSlice{
+				{Key: "name", Value: name},
+				{Key: "in", Value: "query"},
+				{Key: "schema", Value: yaml.MapSlice{{Key: "type", Value: "string"}}},
+			})
+		}
+		m = append(m, yaml.MapItem{Key: "parameters", Value: parameters})
+	}
+
+	if len(o.request) > 0 {
+		m = append(m, yaml.MapItem{Key: "requestBody", Value: yaml.MapSlice{{Key: "content", Value: o.request.yaml()}}})
+	}
+
+	statuses := make([]int, 0, len(o.responses))
+	for status := range o.responses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+
+	responses := yaml.MapSlice{}
+	for _, status := range statuses {
+		description := http.StatusText(status)
+		if description == "" {
+			description = "Captured response"
+		}
+		response := yaml.MapSlice{{Key: "description", Value: description}}
+		if bodies := o.responses[status]; len(bodies) > 0 {
+			response = append(response, yaml.MapItem{Key: "content", Value: bodies.yaml()})
+		}
+		responses = append(responses, yaml.MapItem{Key: strconv.Itoa(status), Value: response})
+	}
+	return append(m, yaml.MapItem{Key: "responses", Value: responses})
+}
+
+// openAPIPath - operations captured for the same path, together with scheme and host of the records
+type openAPIPath struct {
+	servers    map[string]bool
+	operations map[string]*openAPIOperation
+}
+
+// ExportOpenAPIv3 - writes OpenAPI 3.0 document in YAML that describes all records: paths are grouped by path and
+// method, request and response schemas are inferred from JSON bodies like InferSchemas does (other bodies are binary
+// strings) and records with the same path, method and status merge their schemas and keep every distinct body as an
+// example. Paths
+// served by several hosts list them in path 'servers'.
+func (d *Hoverfly) ExportOpenAPIv3(w io.Writer) error {
+	keys, err := d.RequestCache.Keys()
+	if err != nil {
+		return err
+	}
+
+	paths := make(map[string]*openAPIPath)
+	servers := make(map[string]bool)
+
+	for _, key := range keys {
+		v, err := d.RequestCache.Get([]byte(key))
+		// removed since keys were listed
+		if err != nil {
+			continue
+		}
+
+		payload, err := models.NewPayloadFromBytes(v)
+		if err != nil {
+			return fmt.Errorf("failed to decode record '%s': %s", key, err.Error())
+		}
+
+		method := strings.ToLower(payload.Request.Method)
+		if !isOpenAPIMethod(method) {
+			log.WithFields(log.Fields{
+				"method": payload.Request.Method,
+				"path":   payload.Request.Path,
+			}).Debug("method can't be described by OpenAPI, record left out of export")
+			continue
+		}
+
+		path := payload.Request.Path
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+
+		item, ok := paths[path]
+		if !ok {
+			item = &openAPIPath{servers: make(map[string]bool), operations: make(map[string]*openAPIOperation)}
+			paths[path] = item
+		}
+
+		scheme := payload.Request.Scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		server := scheme + "://" + payload.Request.Destination
+		item.servers[server] = true
+		servers[server] = true
+
+		operation, ok := item.operations[method]
+		if !ok {
+			operation = &openAPIOperation{query: make(map[string]bool), request: openAPIBodies{}, responses: make(map[int]openAPIBodies)}
+			item.operations[method] = operation
+		}
+		operation.add(payload)
+	}
+
+	doc := yaml.MapSlice{
+		{Key: "openapi", Value: OpenAPIVersion},
+		{Key: "info", Value: yaml.MapSlice{
+			{Key: "title", Value: "Hoverfly captured API"},
+			{Key: "version", Value: Version},
+		}},
+	}
+	if len(servers) > 0 {
+		doc = append(doc, yaml.MapItem{Key: "servers", Value: serverList(servers)})
+	}
+
+	pathNames := make([]string, 0, len(paths))
+	for path := range paths {
+		pathNames = append(pathNames, path)
+	}
+	sort.Strings(pathNames)
+
+	pathItems := yaml.MapSlice{}
+	for _, path := range pathNames {
+		item := paths[path]
+		pathItem := yaml.MapSlice{}
+		if len(item.servers) < len(servers) {
+			pathItem = append(pathItem, yaml.MapItem{Key: "servers", Value: serverList(item.servers)})
+		}
+		for _, method := range openAPIMethods {
+			if operation, ok := item.operations[method]; ok {
+				pathItem = append(pathItem, yaml.MapItem{Key: method, Value: operation.yaml()})
+			}
+		}
+		pathItems = append(pathItems, yaml.MapItem{Key: path, Value: pathItem})
+	}
+	doc = append(doc, yaml.MapItem{Key: "paths", Value: pathItems})
+
+	bts, err := yaml.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bts)
+	return err
+}
+
+func isOpenAPIMethod(method string) bool {
+	for _, m := range openAPIMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func serverList(servers map[string]bool) []interface{} {
+	urls := make([]string, 0, len(servers))
+	for server := range servers {
+		urls = append(urls, server)
+	}
+	sort.Strings(urls)
+
+	list := make([]interface{}, 0, len(urls))
+	for _, u := range urls {
+		list = append(list, yaml.MapSlice{{Key: "url", Value: u}})
+	}
+	return list
+}
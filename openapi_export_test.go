@@ -0,0 +1,171 @@
+package hoverfly
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func storeOpenAPIPayload(t *testing.T, d *Hoverfly, request models.RequestDetails, response models.ResponseDetails) {
+	payload := models.Payload{Request: request, Response: response}
+	bts, err := payload.Encode()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, d.RequestCache.Set([]byte(payload.Id()), bts), nil)
+}
+
+func TestExportOpenAPIv3(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	storeOpenAPIPayload(t, dbClient,
+		models.RequestDetails{Method: "POST", Scheme: "https", Destination: "api.example.com", Path: "/users", Body: `{"name": "John"}`,
+			Headers: map[string][]string{"Content-Type": {"application/json"}}},
+		models.ResponseDetails{Status: 201, Body: `{"id": 1, "tags": ["a"]}`,
+			Headers: map[string][]string{"Content-Type": {"application/json; charset=utf-8"}}})
+
+	var buf bytes.Buffer
+	testutil.Expect(t, dbClient.ExportOpenAPIv3(&buf), nil)
+	testutil.Expect(t, buf.String(), `openapi: 3.0.3
+info:
+  title: Hoverfly captured API
+  version: v0.7.1
+servers:
+- url: https://api.example.com
+paths:
+  /users:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                name:
+                  type: string
+              required:
+              - name
+            examples:
+              example1:
+                value:
+                  name: John
+      responses:
+        "201":
+          description: Created
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  id:
+                    type: integer
+                  tags:
+                    type: array
+                    items:
+                      type: string
+                required:
+                - id
+                - tags
+              examples:
+                example1:
+                  value:
+                    id: 1
+                    tags:
+                    - a
+`)
+}
+
+func TestExportOpenAPIv3MergesConflictingSchemas(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	for query, body := range map[string]string{
+		"id=1": `{"id": 1, "name": "John"}`,
+		"id=2": `{"id": 2.5, "email": null}`,
+		"id=3": `[1, 2]`,
+		"id=4": `{"id": 1, "name": "John"}`,
+	} {
+		storeOpenAPIPayload(t, dbClient,
+			models.RequestDetails{Method: "GET", Scheme: "http", Destination: "example.com", Path: "/users", Query: query},
+			models.ResponseDetails{Status: 200, Body: body})
+	}
+
+	var buf bytes.Buffer
+	testutil.Expect(t, dbClient.ExportOpenAPIv3(&buf), nil)
+	doc := buf.String()
+
+	for _, fragment := range []string{
+		"      parameters:\n      - name: id\n        in: query\n        schema:\n          type: string\n",
+		"              schema:\n                oneOf:\n                - type: array\n                  items:\n                    type: integer\n",
+		"                - type: object\n",
+		"                    email:\n                      nullable: true\n",
+		"                    id:\n                      type: number\n",
+		"                    name:\n                      type: string\n",
+		// only fields of every object are required
+		"                  required:\n                  - id\n",
+		"example3:",
+	} {
+		if !strings.Contains(doc, fragment) {
+			t.Fatalf("Expected OpenAPI document to contain:\n%s\ngot:\n%s", fragment, doc)
+		}
+	}
+	// identical bodies are a single example
+	testutil.Expect(t, strings.Contains(doc, "example4:"), false)
+}
+
+func TestExportOpenAPIv3BinaryBodiesAndServers(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	storeOpenAPIPayload(t, dbClient,
+		models.RequestDetails{Method: "GET", Scheme: "http", Destination: "images.example.com", Path: "/logo.png"},
+		models.ResponseDetails{Status: 200, Body: "\x89PNG\r\n", Headers: map[string][]string{"Content-Type": {"image/png"}}})
+	storeOpenAPIPayload(t, dbClient,
+		models.RequestDetails{Method: "CONNECT", Scheme: "http", Destination: "example.com", Path: "/"},
+		models.ResponseDetails{Status: 200})
+	storeOpenAPIPayload(t, dbClient,
+		models.RequestDetails{Method: "DELETE", Scheme: "http", Destination: "example.com", Path: "/users"},
+		models.ResponseDetails{Status: 204})
+
+	var buf bytes.Buffer
+	testutil.Expect(t, dbClient.ExportOpenAPIv3(&buf), nil)
+	doc := buf.String()
+
+	for _, fragment := range []string{
+		"  /logo.png:\n    servers:\n    - url: http://images.example.com\n    get:\n",
+		"            image/png:\n              schema:\n                type: string\n                format: binary\n",
+		"      responses:\n        \"204\":\n          description: No Content\n",
+	} {
+		if !strings.Contains(doc, fragment) {
+			t.Fatalf("Expected OpenAPI document to contain:\n%s\ngot:\n%s", fragment, doc)
+		}
+	}
+	testutil.Expect(t, strings.Contains(doc, "connect:"), false)
+}
+
+func TestExportOpenAPIHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	storeOpenAPIPayload(t, dbClient,
+		models.RequestDetails{Method: "GET", Scheme: "http", Destination: "example.com", Path: "/"},
+		models.ResponseDetails{Status: 200, Body: "hello"})
+
+	m := getBoneRouter(*dbClient)
+	req, err := http.NewRequest("GET", "/api/simulation/openapi", nil)
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+	testutil.Expect(t, rec.Header().Get("Content-Type"), "application/yaml")
+	testutil.Expect(t, strings.HasPrefix(rec.Body.String(), "openapi: 3.0.3\n"), true)
+}
@@ -0,0 +1,54 @@
+package hoverfly
+
+import (
+	"context"
+	"net/http"
+)
+
+// Operations reported to operation hooks while request is being processed
+const (
+	OperationUpstream    = "upstream"
+	OperationCacheLookup = "cache.lookup"
+	OperationMiddleware  = "middleware"
+)
+
+// RequestProcessor - processes proxied request and returns response for the client, i.e. Hoverfly.processRequest
+type RequestProcessor func(req *http.Request) (*http.Request, *http.Response)
+
+// OperationHook - called when processing of a request starts an operation (upstream call, cache lookup or middleware
+// invocation), returned function is called once the operation is done
+type OperationHook func(operation string) func(err error)
+
+type operationHookKey struct{}
+
+// WithOperationHook - returns context that makes processing of the request report its operations to given hook
+func WithOperationHook(ctx context.Context, hook OperationHook) context.Context {
+	return context.WithValue(ctx, operationHookKey{}, hook)
+}
+
+// startOperation - notifies operation hook of the request, if there is one
+func startOperation(req *http.Request, operation string) func(err error) {
+	if req != nil {
+		if hook, ok := req.Context().Value(operationHookKey{}).(OperationHook); ok && hook != nil {
+			if end := hook(operation); end != nil {
+				return end
+			}
+		}
+	}
+	return func(error) {}
+}
+
+// Use - wraps processing of proxied requests with given middleware, i.e. for tracing. Middleware registered
+// first is the outermost one. Must be called before the proxy is started.
+func (d *Hoverfly) Use(middleware func(next RequestProcessor) RequestProcessor) {
+	d.processors = append(d.processors, middleware)
+}
+
+// process - processes request through registered middleware
+func (d *Hoverfly) process(req *http.Request) (*http.Request, *http.Response) {
+	var processor RequestProcessor = d.processRequest
+	for i := len(d.processors) - 1; i >= 0; i-- {
+		processor = d.processors[i](processor)
+	}
+	return processor(req)
+}
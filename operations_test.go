@@ -0,0 +1,58 @@
+package hoverfly
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestUseWrapsProcessingInRegistrationOrder(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	var calls []string
+	wrap := func(name string) func(RequestProcessor) RequestProcessor {
+		return func(next RequestProcessor) RequestProcessor {
+			return func(req *http.Request) (*http.Request, *http.Response) {
+				calls = append(calls, name)
+				return next(req)
+			}
+		}
+	}
+	dbClient.Use(wrap("outer"))
+	dbClient.Use(wrap("inner"))
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	_, resp := dbClient.process(req)
+	testutil.Expect(t, resp.StatusCode, 201)
+	testutil.Expect(t, strings.Join(calls, ","), "outer,inner")
+}
+
+func TestOperationHookIsNotifiedAboutUpstreamCall(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	var started, ended []string
+	hook := func(operation string) func(error) {
+		started = append(started, operation)
+		return func(err error) {
+			testutil.Expect(t, err, nil)
+			ended = append(ended, operation)
+		}
+	}
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+	req = req.WithContext(WithOperationHook(req.Context(), hook))
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, 201)
+	testutil.Expect(t, strings.Join(started, ","), OperationUpstream)
+	testutil.Expect(t, strings.Join(ended, ","), OperationUpstream)
+}
@@ -0,0 +1,205 @@
+package hoverfly
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/SpectoLabs/hoverfly/authentication/backends"
+	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/metrics"
+)
+
+// Option - configures Hoverfly created by NewHoverfly
+type Option func(*options)
+
+type options struct {
+	cfg            *Configuration
+	requestCache   cache.Cache
+	metadataCache  cache.Cache
+	authentication backends.Authentication
+	httpClient     *http.Client
+	diffCache      cache.Cache
+}
+
+// WithConfiguration - sets configuration, settings from InitSettings are used by default
+func WithConfiguration(cfg *Configuration) Option {
+	return func(o *options) {
+		o.cfg = cfg
+	}
+}
+
+// WithRequestCache - sets cache for captured requests, in memory cache is used by default
+func WithRequestCache(c cache.Cache) Option {
+	return func(o *options) {
+		o.requestCache = c
+	}
+}
+
+// WithMetadataCache - sets cache for metadata, in memory cache is used by default
+func WithMetadataCache(c cache.Cache) Option {
+	return func(o *options) {
+		o.metadataCache = c
+	}
+}
+
+// WithAuthentication - sets authentication backend for admin API, in memory backend is used by default
+func WithAuthentication(ab backends.Authentication) Option {
+	return func(o *options) {
+		o.authentication = ab
+	}
+}
+
+// WithHTTPClient - sets client for upstream requests, by default client is created from configuration
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// WithDiffCache - sets cache for diff mode records, in memory cache is used by default
+func WithDiffCache(c cache.Cache) Option {
+	return func(o *options) {
+		o.diffCache = c
+	}
+}
+
+// NewHoverfly returns a configured ProxyHttpServer and DBClient, error is returned when destination is not a
+// valid regular expression
+func NewHoverfly(opts ...Option) (*Hoverfly, error) {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	cfg := o.cfg
+	if cfg == nil {
+		cfg = InitSettings()
+	}
+	if o.requestCache == nil && cfg.CacheSize > 0 {
+		o.requestCache = cache.NewLRUCache(cfg.CacheSize)
+	}
+	if o.requestCache == nil {
+		o.requestCache = cache.NewInMemoryCache()
+	}
+	if o.metadataCache == nil {
+		o.metadataCache = cache.NewInMemoryCache()
+	}
+	if o.diffCache == nil {
+		o.diffCache = cache.NewInMemoryCache()
+	}
+	if o.authentication == nil {
+		o.authentication = backends.NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache())
+	}
+	tlsConfig, err := cfg.upstreamTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	tlsSessions := newTLSSessionTracker()
+	tlsSessions.trackUpstream(tlsConfig)
+	if o.httpClient == nil {
+		o.httpClient = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: tlsConfig,
+			},
+			CheckRedirect: cfg.CheckRedirect,
+		}
+	}
+
+	if err := validateDestinations(cfg.Destinations); err != nil {
+		return nil, err
+	}
+	if err := validateUpstreamSOCKS5Proxy(cfg.UpstreamSOCKS5Proxy); err != nil {
+		return nil, err
+	}
+	if err := validateUpstreamHoverflyPool(cfg); err != nil {
+		return nil, err
+	}
+	if err := validateExtraProxyPorts(cfg); err != nil {
+		return nil, err
+	}
+	if err := validateImportBodyEncodingPolicy(cfg.ImportBodyEncodingPolicy); err != nil {
+		return nil, err
+	}
+	if err := validatePacketLossRate(cfg.PacketLossRate); err != nil {
+		return nil, err
+	}
+	if err := validateDeduplicateBloomFPRate(cfg.DeduplicateBloomFPRate); err != nil {
+		return nil, err
+	}
+
+	h := &Hoverfly{
+		RequestCache:   o.requestCache,
+		MetadataCache:  cache.NewFallbackCache(o.metadataCache),
+		Authentication: o.authentication,
+		HTTP:           o.httpClient,
+		Diffs:          NewDiffStore(o.diffCache),
+		Sequences:      NewSequenceCounter(),
+		Weights:        NewWeightedSelector(cfg.SimulationSeed),
+		Circuits:       NewCircuitBreaker(cfg),
+		requestHooks:   newRequestHooks(),
+		Cfg:            cfg,
+		Counter:        metrics.NewModeCounter([]string{SimulateMode, SynthesizeMode, ModifyMode, CaptureMode, SpyMode, DiffMode, ChaosMode}),
+		Sizes:          metrics.NewSizeHistogram(cfg.SizeBuckets),
+		Hooks:          make(ActionTypeHooks),
+		events:         newEventBroadcaster(),
+		modeHooks:      newModeChangeHooks(),
+		tlsSessions:    tlsSessions,
+		captureBloom:   newCaptureBloom(),
+		flows:          newFlowIndex(),
+		recentAccess:   newAccessIndex(),
+	}
+	if cfg.MutualTLS != nil {
+		h.upstreamTLS = tlsConfig
+	}
+	if cfg.UpstreamDigestAuth != nil {
+		if err := cfg.UpstreamDigestAuth.Validate(); err != nil {
+			return nil, err
+		}
+		client := *h.HTTP
+		client.Transport = newDigestAuthTransport(client.Transport, *cfg.UpstreamDigestAuth)
+		h.HTTP = &client
+	}
+	if cfg.TOTPAuth {
+		h.Authentication = backends.NewTOTPAuthentication(o.authentication, h.MetadataCache)
+	}
+	if cfg.AuthType == backends.AuthTypeJWT {
+		jwtBackend, err := backends.NewJWTBackend(h.Authentication, cfg.JWTVerificationKey)
+		if err != nil {
+			return nil, err
+		}
+		h.Authentication = jwtBackend
+	} else if cfg.AuthType != "" {
+		return nil, fmt.Errorf("unknown auth type '%s'", cfg.AuthType)
+	}
+	if cfg.DeduplicateBodies {
+		h.RequestCache = NewDedupCache(o.requestCache, h.MetadataCache)
+	}
+	if err := h.recordStartupConfig(); err != nil {
+		return nil, err
+	}
+	if cfg.SimulatedWebAuthn != nil {
+		for _, credential := range cfg.SimulatedWebAuthn.Credentials {
+			if err := h.RegisterWebAuthnCredential(credential); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := validateLuaMiddleware(cfg.LuaMiddleware); err != nil {
+		return nil, err
+	}
+	if cfg.MiddlewarePlugin != "" {
+		if err := h.LoadMiddlewarePlugin(cfg.MiddlewarePlugin); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.SimulationDir != "" {
+		// files that failed to load are logged, the rest of simulation is still served
+		if err := h.LoadSimulationDirectory(cfg.SimulationDir); err != nil {
+			if _, ok := err.(MultiError); !ok {
+				return nil, err
+			}
+		}
+	}
+	h.UpdateProxy()
+	return h, nil
+}
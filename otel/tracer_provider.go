@@ -0,0 +1,55 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SpectoLabs/hoverfly"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewTracerProvider - adapts OpenTelemetry tracer provider for hoverfly.Configuration.TracerProvider, middleware
+// invocation spans are children of the span in request context (the proxy span of NewTracingMiddleware)
+func NewTracerProvider(tp trace.TracerProvider) hoverfly.TracerProvider {
+	return &tracerProvider{tracer: tp.Tracer(TracerName)}
+}
+
+type tracerProvider struct {
+	tracer trace.Tracer
+}
+
+func (p *tracerProvider) StartSpan(ctx context.Context, name string) hoverfly.Span {
+	_, span := p.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindInternal))
+	return &tracedSpan{span: span}
+}
+
+type tracedSpan struct {
+	span trace.Span
+}
+
+func (s *tracedSpan) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, v))
+	case int:
+		s.span.SetAttributes(attribute.Int(key, v))
+	case int64:
+		s.span.SetAttributes(attribute.Int64(key, v))
+	case float64:
+		s.span.SetAttributes(attribute.Float64(key, v))
+	case bool:
+		s.span.SetAttributes(attribute.Bool(key, v))
+	default:
+		s.span.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+func (s *tracedSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,72 @@
+// Package otel provides OpenTelemetry tracing for requests that go through Hoverfly proxy
+package otel
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/SpectoLabs/hoverfly"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName - name of the tracer spans are created with
+const TracerName = "github.com/SpectoLabs/hoverfly"
+
+// SpanNameProxy - name of the span that covers whole processing of proxied request, operation spans are named
+// after the operation with the same prefix, i.e. 'hoverfly.upstream'
+const SpanNameProxy = "hoverfly.proxy"
+
+// Middleware - wraps processing of proxied requests, register it with Hoverfly.Use
+type Middleware func(next hoverfly.RequestProcessor) hoverfly.RequestProcessor
+
+// NewTracingMiddleware - creates span for every proxied request, incoming 'traceparent' header is used as its
+// parent. Upstream calls, cache lookups and middleware invocations get child spans.
+func NewTracingMiddleware(tp trace.TracerProvider) Middleware {
+	tracer := tp.Tracer(TracerName)
+	propagator := propagation.TraceContext{}
+
+	return func(next hoverfly.RequestProcessor) hoverfly.RequestProcessor {
+		return func(req *http.Request) (*http.Request, *http.Response) {
+			parent := propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+			ctx, span := tracer.Start(parent, SpanNameProxy,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.method", req.Method),
+					attribute.String("http.host", req.Host),
+					attribute.String("http.target", req.URL.RequestURI()),
+				))
+			defer span.End()
+
+			hook := func(operation string) func(error) {
+				kind := trace.SpanKindInternal
+				if operation == hoverfly.OperationUpstream {
+					kind = trace.SpanKindClient
+				}
+				_, child := tracer.Start(ctx, "hoverfly."+operation, trace.WithSpanKind(kind))
+
+				return func(err error) {
+					if err != nil {
+						child.RecordError(err)
+						child.SetStatus(codes.Error, err.Error())
+					}
+					child.End()
+				}
+			}
+
+			req, resp := next(req.WithContext(hoverfly.WithOperationHook(ctx, hook)))
+
+			if resp != nil {
+				span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+				if resp.StatusCode >= 500 {
+					span.SetStatus(codes.Error, fmt.Sprintf("response status %d", resp.StatusCode))
+				}
+			}
+
+			return req, resp
+		}
+	}
+}
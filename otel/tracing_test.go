@@ -0,0 +1,205 @@
+package otel
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly"
+	"github.com/SpectoLabs/hoverfly/authentication/backends"
+	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordedSpan - span created by recordingTracer
+type recordedSpan struct {
+	trace.Span
+
+	tracer *recordingTracer
+	name   string
+	kind   trace.SpanKind
+	sc     trace.SpanContext
+	parent trace.SpanContext
+	status codes.Code
+	ended  bool
+	attrs  []attribute.KeyValue
+}
+
+func (s *recordedSpan) SpanContext() trace.SpanContext                      { return s.sc }
+func (s *recordedSpan) IsRecording() bool                                   { return true }
+func (s *recordedSpan) RecordError(err error, options ...trace.EventOption) {}
+
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.tracer.mu.Lock()
+	s.attrs = append(s.attrs, kv...)
+	s.tracer.mu.Unlock()
+}
+
+func (s *recordedSpan) SetStatus(code codes.Code, description string) {
+	s.tracer.mu.Lock()
+	s.status = code
+	s.tracer.mu.Unlock()
+}
+
+func (s *recordedSpan) End(options ...trace.SpanEndOption) {
+	s.tracer.mu.Lock()
+	s.ended = true
+	s.tracer.mu.Unlock()
+}
+
+// recordingTracer - tracer and tracer provider that keeps created spans in memory
+type recordingTracer struct {
+	mu     sync.Mutex
+	spans  []*recordedSpan
+	nextID uint64
+}
+
+func (t *recordingTracer) Tracer(name string, options ...trace.TracerOption) trace.Tracer {
+	return t
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, options ...trace.SpanStartOption) (context.Context, trace.Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.nextID++
+	var spanID trace.SpanID
+	binary.BigEndian.PutUint64(spanID[:], t.nextID)
+
+	parent := trace.SpanContextFromContext(ctx)
+	traceID := parent.TraceID()
+	if !traceID.IsValid() {
+		binary.BigEndian.PutUint64(traceID[8:], t.nextID)
+	}
+
+	cfg := trace.NewSpanStartConfig(options...)
+	span := &recordedSpan{
+		Span:   trace.SpanFromContext(context.Background()),
+		tracer: t,
+		name:   name,
+		kind:   cfg.SpanKind(),
+		sc:     trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled}),
+		parent: parent,
+	}
+	t.spans = append(t.spans, span)
+
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func (t *recordingTracer) span(name string) *recordedSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range t.spans {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func tracedHoverfly(t *testing.T, tracer *recordingTracer, mode string) (*httptest.Server, *http.Client) {
+	cfg := hoverfly.InitSettings()
+	cfg.AuthEnabled = false
+	cfg.SetMode(mode)
+
+	authBackend := backends.NewCacheBasedAuthBackend(cache.NewInMemoryCache(), cache.NewInMemoryCache())
+	h, err := hoverfly.GetNewHoverfly(cfg, cache.NewInMemoryCache(), cache.NewInMemoryCache(), authBackend)
+	testutil.Expect(t, err, nil)
+
+	err = h.ImportPayloads([]models.PayloadView{
+		{
+			Request:  models.RequestDetailsView{Method: "GET", Scheme: "http", Destination: "api.example.com", Path: "/users"},
+			Response: models.ResponseDetailsView{Status: 200, Body: "users"},
+		},
+	})
+	testutil.Expect(t, err, nil)
+
+	h.Use(NewTracingMiddleware(tracer))
+
+	server := httptest.NewServer(h.Proxy)
+	proxyURL, err := url.Parse(server.URL)
+	testutil.Expect(t, err, nil)
+
+	return server, &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+}
+
+func TestTracingMiddlewareContinuesIncomingTrace(t *testing.T) {
+	tracer := &recordingTracer{}
+	server, client := tracedHoverfly(t, tracer, hoverfly.SimulateMode)
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", "http://api.example.com/users", nil)
+	testutil.Expect(t, err, nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	resp, err := client.Do(req)
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, 200)
+
+	proxySpan := tracer.span(SpanNameProxy)
+	testutil.Refute(t, proxySpan, nil)
+	testutil.Expect(t, proxySpan.sc.TraceID().String(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	testutil.Expect(t, proxySpan.parent.SpanID().String(), "00f067aa0ba902b7")
+	testutil.Expect(t, proxySpan.kind, trace.SpanKindServer)
+	testutil.Expect(t, proxySpan.ended, true)
+
+	lookup := tracer.span("hoverfly." + hoverfly.OperationCacheLookup)
+	testutil.Refute(t, lookup, nil)
+	testutil.Expect(t, lookup.parent.SpanID(), proxySpan.sc.SpanID())
+	testutil.Expect(t, lookup.ended, true)
+}
+
+func TestTracingMiddlewareMarksFailedUpstreamCall(t *testing.T) {
+	tracer := &recordingTracer{}
+	server, client := tracedHoverfly(t, tracer, hoverfly.CaptureMode)
+	defer server.Close()
+
+	resp, err := client.Get("http://127.0.0.1:1/unreachable")
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusServiceUnavailable)
+
+	proxySpan := tracer.span(SpanNameProxy)
+	testutil.Refute(t, proxySpan, nil)
+	testutil.Expect(t, proxySpan.parent.IsValid(), false)
+	testutil.Expect(t, proxySpan.status, codes.Error)
+
+	upstream := tracer.span("hoverfly." + hoverfly.OperationUpstream)
+	testutil.Refute(t, upstream, nil)
+	testutil.Expect(t, upstream.kind, trace.SpanKindClient)
+	testutil.Expect(t, upstream.parent.SpanID(), proxySpan.sc.SpanID())
+	testutil.Expect(t, upstream.status, codes.Error)
+}
+
+func TestTracerProviderCreatesMiddlewareSpans(t *testing.T) {
+	tracer := &recordingTracer{}
+	ctx, parent := tracer.Start(context.Background(), SpanNameProxy)
+
+	span := NewTracerProvider(tracer).StartSpan(ctx, hoverfly.SpanNameMiddleware)
+	span.SetAttribute(hoverfly.SpanAttributeMiddlewarePath, "./middleware.py")
+	span.SetAttribute(hoverfly.SpanAttributeMiddlewareExitCode, 1)
+	span.SetAttribute(hoverfly.SpanAttributeMiddlewareElapsedMs, 2.5)
+	span.End(errors.New("exit status 1"))
+
+	recorded := tracer.span(hoverfly.SpanNameMiddleware)
+	testutil.Refute(t, recorded, nil)
+	testutil.Expect(t, recorded.parent.SpanID(), parent.SpanContext().SpanID())
+	testutil.Expect(t, recorded.kind, trace.SpanKindInternal)
+	testutil.Expect(t, recorded.status, codes.Error)
+	testutil.Expect(t, recorded.ended, true)
+
+	testutil.Expect(t, len(recorded.attrs), 3)
+	testutil.Expect(t, recorded.attrs[0], attribute.String(hoverfly.SpanAttributeMiddlewarePath, "./middleware.py"))
+	testutil.Expect(t, recorded.attrs[1], attribute.Int(hoverfly.SpanAttributeMiddlewareExitCode, 1))
+	testutil.Expect(t, recorded.attrs[2], attribute.Float64(hoverfly.SpanAttributeMiddlewareElapsedMs, 2.5))
+}
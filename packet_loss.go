@@ -0,0 +1,91 @@
+package hoverfly
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// validatePacketLossRate - packet loss rate is share of responses that are cut off, between 0 and 1
+func validatePacketLossRate(rate float64) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("packet loss rate must be between 0 and 1, got %v", rate)
+	}
+	return nil
+}
+
+// packetLoss - random source of packet loss simulation, the same seed cuts off the same responses at the same
+// offsets, random seed is used when seed is 0
+type packetLoss struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newPacketLoss(seed int64) *packetLoss {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &packetLoss{rand: rand.New(rand.NewSource(seed))}
+}
+
+// lost - decides whether response is cut off
+func (p *packetLoss) lost(rate float64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rand.Float64() < rate
+}
+
+// offset - number of body bytes sent before connection is reset, at least one byte of non empty body is lost
+func (p *packetLoss) offset(bodyLength int) int {
+	if bodyLength == 0 {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rand.Intn(bodyLength)
+}
+
+// packetLossHandler - cuts off PacketLossRate of simulated responses after random number of body bytes and resets
+// the connection
+type packetLossHandler struct {
+	handler http.Handler
+	cfg     *Configuration
+	loss    *packetLoss
+}
+
+func (h *packetLossHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok || h.cfg.PacketLossRate <= 0 || r.Method == "CONNECT" || requestMode(r, h.cfg) != SimulateMode ||
+		!h.loss.lost(h.cfg.PacketLossRate) {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	rec := &bufferedResponseWriter{header: make(http.Header), status: http.StatusOK}
+	h.handler.ServeHTTP(rec, r)
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("Failed to hijack connection, full response is sent")
+		rec.writeTo(w)
+		return
+	}
+
+	bodyLength := rec.body.Len()
+	sent := h.loss.offset(bodyLength)
+	cutOffResponse(conn, buf, rec, sent)
+
+	log.WithFields(log.Fields{
+		"packetLossRate": h.cfg.PacketLossRate,
+		"bytesSent":      sent,
+		"bodyLength":     bodyLength,
+		"destination":    r.Host,
+		"path":           r.URL.Path,
+	}).Info("Packet loss simulated, response cut off and connection reset")
+}
@@ -0,0 +1,90 @@
+package hoverfly
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func packetLossServer(cfg *Configuration) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "0123456789abcdefghij")
+	})
+	return httptest.NewServer(&packetLossHandler{handler: handler, cfg: cfg, loss: newPacketLoss(cfg.SimulationSeed)})
+}
+
+func TestPacketLossCutsOffResponse(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetMode(SimulateMode)
+	cfg.PacketLossRate = 1
+
+	server := packetLossServer(cfg)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/download")
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+
+	testutil.Expect(t, resp.ContentLength, int64(20))
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, len(body) < 20, true)
+	testutil.Expect(t, string(body), "0123456789abcdefghij"[:len(body)])
+}
+
+func TestNoPacketLossWhenRateIsZero(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetMode(SimulateMode)
+
+	server := packetLossServer(cfg)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/download")
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "0123456789abcdefghij")
+}
+
+func TestPacketLossOnlyInSimulateMode(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetMode(CaptureMode)
+	cfg.PacketLossRate = 1
+
+	server := packetLossServer(cfg)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/download")
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "0123456789abcdefghij")
+}
+
+func TestPacketLossIsSeeded(t *testing.T) {
+	first, second := newPacketLoss(42), newPacketLoss(42)
+	for i := 0; i < 20; i++ {
+		testutil.Expect(t, first.lost(0.5), second.lost(0.5))
+		testutil.Expect(t, first.offset(100), second.offset(100))
+	}
+
+	testutil.Expect(t, first.offset(0), 0)
+	for i := 0; i < 20; i++ {
+		testutil.Expect(t, first.offset(3) < 3, true)
+	}
+}
+
+func TestValidatePacketLossRate(t *testing.T) {
+	testutil.Expect(t, validatePacketLossRate(0), nil)
+	testutil.Expect(t, validatePacketLossRate(1), nil)
+	testutil.Refute(t, validatePacketLossRate(-0.1), nil)
+	testutil.Refute(t, validatePacketLossRate(1.5), nil)
+}
@@ -0,0 +1,143 @@
+package hoverfly
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// PageQueryParam - query parameter that selects a page of paginated response
+const PageQueryParam = "page"
+
+// SessionHeader - identifies client session, client IP is used when it is not supplied
+const SessionHeader = "X-Hoverfly-Session"
+
+// paginationKeyPrefix - prefix for metadata keys that hold last served page per session
+const paginationKeyPrefix = "pagination_"
+
+// getRequestSession - returns client session identifier
+func getRequestSession(req *http.Request) string {
+	if session := req.Header.Get(SessionHeader); session != "" {
+		return session
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// removePageParam - removes page query parameter, keeping the order of other parameters
+func removePageParam(rawQuery string) (query string, page int, found bool) {
+	var params []string
+
+	for _, param := range strings.Split(rawQuery, "&") {
+		if strings.HasPrefix(param, PageQueryParam+"=") {
+			n, err := strconv.Atoi(strings.TrimPrefix(param, PageQueryParam+"="))
+			if err == nil {
+				page, found = n, true
+				continue
+			}
+		}
+		if param != "" {
+			params = append(params, param)
+		}
+	}
+
+	return strings.Join(params, "&"), page, found
+}
+
+// getPageFingerprint - returns request hash without page query parameter, so requests to page URLs
+// can be matched with the first request
+func (d *Hoverfly) getPageFingerprint(req *http.Request, requestBody []byte) (string, bool) {
+	cfg := d.requestConfig(req)
+	query, _, found := removePageParam(req.URL.RawQuery)
+	if !found {
+		return "", false
+	}
+
+	r := models.RequestDetails{
+		Path:        req.URL.Path,
+		Method:      req.Method,
+		Destination: req.Host,
+		Query:       cfg.normaliseQuery(query),
+		Body:        string(requestBody),
+		Headers:     req.Header,
+	}
+
+	return r.Hash(), true
+}
+
+// pageLink - returns URL of given page for the request
+func pageLink(req *http.Request, page int) string {
+	scheme := req.URL.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+
+	query, _, _ := removePageParam(req.URL.RawQuery)
+	if query != "" {
+		query += "&"
+	}
+
+	return fmt.Sprintf("%s://%s%s?%s%s=%d", scheme, req.Host, req.URL.Path, query, PageQueryParam, page)
+}
+
+// paginate - replaces payload response with requested page. Page is taken from the page query parameter,
+// otherwise next page after the one last served to this client session is returned (first page if nothing was
+// served yet).
+func (d *Hoverfly) paginate(req *http.Request, key string, payload *models.Payload) (*models.Payload, error) {
+	pages := payload.Paginated.Pages
+
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("paginated response has no pages")
+	}
+
+	pointerKey := []byte(fmt.Sprintf("%s%s_%s", paginationKeyPrefix, getRequestSession(req), key))
+
+	_, page, found := removePageParam(req.URL.RawQuery)
+
+	if !found {
+		page = 1
+		last, err := d.MetadataCache.Get(pointerKey)
+		if err == nil {
+			if n, err := strconv.Atoi(string(last)); err == nil {
+				page = n + 1
+			}
+		}
+		if page > len(pages) {
+			page = len(pages)
+		}
+	}
+
+	if page < 1 || page > len(pages) {
+		return nil, fmt.Errorf("page %d not found, paginated response has %d pages", page, len(pages))
+	}
+
+	if err := d.MetadataCache.Set(pointerKey, []byte(strconv.Itoa(page))); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   string(pointerKey),
+		}).Warn("Failed to store page pointer")
+	}
+
+	response := pages[page-1]
+
+	// copying headers, stored page should not be modified
+	headers := make(map[string][]string)
+	for k, v := range response.Headers {
+		headers[k] = v
+	}
+
+	if page < len(pages) {
+		headers["Link"] = []string{fmt.Sprintf(`<%s>; rel="next"`, pageLink(req, page+1))}
+	}
+	response.Headers = headers
+
+	return &models.Payload{Request: payload.Request, Response: response}, nil
+}
@@ -0,0 +1,92 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestRemovePageParam(t *testing.T) {
+	query, page, found := removePageParam("a=1&page=3&b=2")
+	testutil.Expect(t, query, "a=1&b=2")
+	testutil.Expect(t, page, 3)
+	testutil.Expect(t, found, true)
+
+	query, _, found = removePageParam("a=1")
+	testutil.Expect(t, query, "a=1")
+	testutil.Expect(t, found, false)
+}
+
+func importPaginatedPayload(t *testing.T, dbClient *Hoverfly) {
+	payload := models.Payload{
+		Request: models.RequestDetails{
+			Method:      "GET",
+			Destination: "example.com",
+			Path:        "/items",
+		},
+		Paginated: &models.PaginatedResponse{
+			Pages: []models.ResponseDetails{
+				{Status: 200, Body: "page one"},
+				{Status: 200, Body: "page two"},
+			},
+		},
+	}
+	err := dbClient.ImportPayloads([]models.PayloadView{*payload.ConvertToPayloadView()})
+	testutil.Expect(t, err, nil)
+}
+
+func getPage(t *testing.T, dbClient *Hoverfly, url string) (*http.Response, string) {
+	req, err := http.NewRequest("GET", url, nil)
+	testutil.Expect(t, err, nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+
+	resp := dbClient.getResponse(req)
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	return resp, string(body)
+}
+
+func TestPaginatedResponse(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	defer dbClient.MetadataCache.DeleteData()
+
+	importPaginatedPayload(t, dbClient)
+
+	resp, body := getPage(t, dbClient, "http://example.com/items")
+	testutil.Expect(t, resp.StatusCode, 200)
+	testutil.Expect(t, body, "page one")
+	testutil.Expect(t, resp.Header.Get("Link"), `<http://example.com/items?page=2>; rel="next"`)
+
+	resp, body = getPage(t, dbClient, "http://example.com/items?page=2")
+	testutil.Expect(t, resp.StatusCode, 200)
+	testutil.Expect(t, body, "page two")
+	testutil.Expect(t, resp.Header.Get("Link"), "")
+
+	resp, _ = getPage(t, dbClient, "http://example.com/items?page=3")
+	testutil.Expect(t, resp.StatusCode, http.StatusNotFound)
+}
+
+func TestPaginatedResponseSessionPointer(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	defer dbClient.MetadataCache.DeleteData()
+
+	importPaginatedPayload(t, dbClient)
+
+	_, body := getPage(t, dbClient, "http://example.com/items")
+	testutil.Expect(t, body, "page one")
+
+	// same session, next page is served
+	_, body = getPage(t, dbClient, "http://example.com/items")
+	testutil.Expect(t, body, "page two")
+
+	// staying on last page
+	_, body = getPage(t, dbClient, "http://example.com/items")
+	testutil.Expect(t, body, "page two")
+}
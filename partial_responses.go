@@ -0,0 +1,176 @@
+package hoverfly
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// PartialResponseRule - simulated responses of requests which host and path match given regexp pattern are cut
+// off after BytesSent bytes of body and the connection is reset
+type PartialResponseRule struct {
+	Pattern   string `json:"pattern"`
+	BytesSent int    `json:"bytesSent"`
+
+	// compiled Pattern, set by SetPartialResponses
+	re *regexp.Regexp
+}
+
+// LoadPartialResponses - reads partial response rules from JSON file, i.e.:
+//
+//	[{"pattern": "example.com/api/download", "bytesSent": 1024}]
+func (c *Configuration) LoadPartialResponses(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var rules []PartialResponseRule
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return fmt.Errorf("failed to parse partial responses file: %s", err.Error())
+	}
+
+	return c.SetPartialResponses(rules)
+}
+
+// SetPartialResponses - validates rules and compiles their patterns, requests are matched against compiled patterns
+func (c *Configuration) SetPartialResponses(rules []PartialResponseRule) error {
+	compiled := make([]PartialResponseRule, len(rules))
+	for i, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid partial response pattern '%s': %s", rule.Pattern, err.Error())
+		}
+		if rule.BytesSent < 0 {
+			return fmt.Errorf("invalid partial response bytesSent %d for pattern '%s', must not be negative", rule.BytesSent, rule.Pattern)
+		}
+		rule.re = re
+		compiled[i] = rule
+	}
+
+	c.PartialResponses = compiled
+
+	return nil
+}
+
+// GetPartialResponseRule - returns first partial response rule that matches given host and path
+func (c *Configuration) GetPartialResponseRule(host, path string) *PartialResponseRule {
+	for i := range c.PartialResponses {
+		if re := c.PartialResponses[i].re; re != nil && re.MatchString(host+path) {
+			return &c.PartialResponses[i]
+		}
+	}
+	return nil
+}
+
+// partialResponseHandler - writes only the beginning of simulated response body to hijacked client connection and
+// resets the connection
+type partialResponseHandler struct {
+	handler http.Handler
+	cfg     *Configuration
+}
+
+func (h *partialResponseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok || r.Method == "CONNECT" || requestMode(r, h.cfg) != SimulateMode {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	rule := h.cfg.GetPartialResponseRule(r.Host, r.URL.Path)
+	if rule == nil {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	rec := &bufferedResponseWriter{header: make(http.Header), status: http.StatusOK}
+	h.handler.ServeHTTP(rec, r)
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("Failed to hijack connection, full response is sent")
+		rec.writeTo(w)
+		return
+	}
+
+	body := rec.body.Bytes()
+	sent := rule.BytesSent
+	if sent > len(body) {
+		sent = len(body)
+	}
+	cutOffResponse(conn, buf, rec, sent)
+
+	log.WithFields(log.Fields{
+		"pattern":     rule.Pattern,
+		"bytesSent":   sent,
+		"bodyLength":  len(body),
+		"destination": r.Host,
+		"path":        r.URL.Path,
+	}).Info("Response cut off, connection reset")
+}
+
+// cutOffResponse - writes status, headers and first sent bytes of buffered response to hijacked connection and
+// resets it
+func cutOffResponse(conn net.Conn, buf *bufio.ReadWriter, rec *bufferedResponseWriter, sent int) {
+	body := rec.body.Bytes()
+
+	// full length is announced so that clients know response was cut off
+	rec.header.Del("Transfer-Encoding")
+	rec.header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\n", rec.status, http.StatusText(rec.status))
+	rec.header.Write(buf)
+	buf.WriteString("\r\n")
+	buf.Write(body[:sent])
+	buf.Flush()
+
+	// zero linger makes close send RST instead of FIN
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// bufferedResponseWriter - keeps response in memory
+type bufferedResponseWriter struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedResponseWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponseWriter) WriteHeader(code int) {
+	if b.wroteHeader {
+		return
+	}
+	b.wroteHeader = true
+	b.status = code
+}
+
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponseWriter) writeTo(w http.ResponseWriter) {
+	for k, v := range b.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
@@ -0,0 +1,107 @@
+package hoverfly
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func partialResponseServer(cfg *Configuration) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "partial")
+		fmt.Fprint(w, "0123456789abcdefghij")
+	})
+	return httptest.NewServer(&partialResponseHandler{handler: handler, cfg: cfg})
+}
+
+func TestPartialResponseIsCutOff(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetMode(SimulateMode)
+	testutil.Expect(t, cfg.SetPartialResponses([]PartialResponseRule{{Pattern: "/download", BytesSent: 5}}), nil)
+
+	server := partialResponseServer(cfg)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/download")
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+
+	testutil.Expect(t, resp.StatusCode, 200)
+	testutil.Expect(t, resp.Header.Get("X-Test"), "partial")
+	testutil.Expect(t, resp.ContentLength, int64(20))
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, string(body), "01234")
+}
+
+func TestPartialResponseNotMatched(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetMode(SimulateMode)
+	testutil.Expect(t, cfg.SetPartialResponses([]PartialResponseRule{{Pattern: "/download", BytesSent: 5}}), nil)
+
+	server := partialResponseServer(cfg)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/other")
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "0123456789abcdefghij")
+}
+
+func TestPartialResponseOnlyInSimulateMode(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetMode(CaptureMode)
+	testutil.Expect(t, cfg.SetPartialResponses([]PartialResponseRule{{Pattern: "/download", BytesSent: 5}}), nil)
+
+	server := partialResponseServer(cfg)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/download")
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "0123456789abcdefghij")
+}
+
+func TestLoadPartialResponses(t *testing.T) {
+	f, err := ioutil.TempFile("", "partial_responses")
+	testutil.Expect(t, err, nil)
+	defer os.Remove(f.Name())
+
+	fmt.Fprint(f, `[{"pattern": "example.com/download", "bytesSent": 100}]`)
+	f.Close()
+
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.LoadPartialResponses(f.Name()), nil)
+	testutil.Expect(t, len(cfg.PartialResponses), 1)
+
+	rule := cfg.GetPartialResponseRule("example.com", "/download")
+	testutil.Refute(t, rule, nil)
+	testutil.Expect(t, rule.BytesSent, 100)
+
+	testutil.Expect(t, cfg.GetPartialResponseRule("example.com", "/upload") == nil, true)
+}
+
+func TestLoadPartialResponsesInvalidPattern(t *testing.T) {
+	f, err := ioutil.TempFile("", "partial_responses")
+	testutil.Expect(t, err, nil)
+	defer os.Remove(f.Name())
+
+	fmt.Fprint(f, `[{"pattern": "(", "bytesSent": 100}]`)
+	f.Close()
+
+	cfg := InitSettings()
+	testutil.Refute(t, cfg.LoadPartialResponses(f.Name()), nil)
+	testutil.Expect(t, len(cfg.PartialResponses), 0)
+}
@@ -0,0 +1,288 @@
+package hoverfly
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/google/gopacket/tcpassembly"
+)
+
+// pcapngSectionHeader - first 4 bytes of pcapng file, the same in either byte order
+const pcapngSectionHeader = 0x0a0d0d0a
+
+// tlsRecordTypeHandshake - first byte of TLS ClientHello, streams starting with it are encrypted
+const tlsRecordTypeHandshake = 0x16
+
+// errNotPCAP - file is neither pcap nor pcapng capture
+var errNotPCAP = errors.New("file is not a pcap or pcapng capture")
+
+// tcpStream - data sent by one side of TCP connection, reassembled by tcpassembly. Stream ends at the first gap.
+type tcpStream struct {
+	endpoint string
+	data     []byte
+	gap      bool
+}
+
+// Reassembled - appends data in sequence order, retransmitted and overlapping segments were merged already
+func (s *tcpStream) Reassembled(reassemblies []tcpassembly.Reassembly) {
+	for _, r := range reassemblies {
+		if s.gap {
+			return
+		}
+		// connection started before capture when the first data is preceded by unknown number of bytes
+		if r.Skip > 0 || (r.Skip < 0 && len(s.data) > 0) {
+			log.WithFields(log.Fields{
+				"side":    s.endpoint,
+				"missing": r.Skip,
+			}).Warn("TCP stream has a gap, data after it is not imported")
+			s.gap = true
+			return
+		}
+		s.data = append(s.data, r.Bytes...)
+	}
+}
+
+func (s *tcpStream) ReassemblyComplete() {}
+
+// tcpConn - both directions of TCP connection, client is the side that was seen first, it sends SYN
+type tcpConn struct {
+	client, server *tcpStream
+}
+
+// response - data sent by server, nil when server side wasn't captured
+func (c *tcpConn) response() []byte {
+	if c.server == nil {
+		return nil
+	}
+	return c.server.data
+}
+
+// tcpStreamFactory - pairs streams of both directions into connections in the order they were seen
+type tcpStreamFactory struct {
+	conns []*tcpConn
+	open  map[string]*tcpConn
+}
+
+func (f *tcpStreamFactory) New(netFlow, tcpFlow gopacket.Flow) tcpassembly.Stream {
+	src := net.JoinHostPort(netFlow.Src().String(), tcpFlow.Src().String())
+	dst := net.JoinHostPort(netFlow.Dst().String(), tcpFlow.Dst().String())
+	key := src + "-" + dst
+	if src > dst {
+		key = dst + "-" + src
+	}
+
+	s := &tcpStream{endpoint: src}
+	if conn := f.open[key]; conn != nil && conn.server == nil && conn.client.endpoint == dst {
+		conn.server = s
+		return s
+	}
+	// first stream of connection, or a new connection on the same ports
+	conn := &tcpConn{client: s}
+	f.open[key] = conn
+	f.conns = append(f.conns, conn)
+	return s
+}
+
+// ImportPCAP - reassembles TCP streams of pcap or pcapng capture (i.e. saved by Wireshark or tcpdump), parses
+// cleartext HTTP/1.x requests and responses and imports them, returns number of imported pairs. Encrypted (TLS)
+// streams and streams that aren't HTTP are skipped with a warning.
+func (d *Hoverfly) ImportPCAP(r io.Reader) (int, error) {
+	conns, err := readPCAPConnections(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read PCAP: %s", err.Error())
+	}
+
+	var payloads []models.PayloadView
+	for _, conn := range conns {
+		request, response := conn.client.data, conn.response()
+		if len(request) == 0 {
+			continue
+		}
+
+		server := ""
+		if conn.server != nil {
+			server = conn.server.endpoint
+		}
+		fields := log.Fields{
+			"client": conn.client.endpoint,
+			"server": server,
+		}
+		if request[0] == tlsRecordTypeHandshake {
+			log.WithFields(fields).Warn("TCP stream is encrypted (TLS), skipping it")
+			continue
+		}
+
+		pairs, err := parseHTTPStream(request, response, server)
+		if err != nil {
+			fields["error"] = err.Error()
+			log.WithFields(fields).Warn("TCP stream is not cleartext HTTP/1.x, skipping it")
+		}
+		payloads = append(payloads, pairs...)
+	}
+
+	if len(payloads) == 0 {
+		return 0, errors.New("no cleartext HTTP requests with responses found in PCAP")
+	}
+	if err := d.ImportPayloads(payloads); err != nil {
+		return 0, err
+	}
+	return len(payloads), nil
+}
+
+// parseHTTPStream - pairs requests of client stream with responses of server stream, pairs parsed before an
+// error are returned together with it
+func parseHTTPStream(request, response []byte, server string) ([]models.PayloadView, error) {
+	requests := bufio.NewReader(bytes.NewReader(request))
+	responses := bufio.NewReader(bytes.NewReader(response))
+
+	var payloads []models.PayloadView
+	for {
+		if _, err := requests.Peek(1); err == io.EOF {
+			return payloads, nil
+		}
+
+		req, err := http.ReadRequest(requests)
+		if err != nil {
+			return payloads, err
+		}
+		reqBody, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return payloads, fmt.Errorf("incomplete request body: %s", err.Error())
+		}
+
+		resp, err := readFinalResponse(responses, req)
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				log.WithFields(log.Fields{
+					"method": req.Method,
+					"path":   req.URL.Path,
+				}).Warn("Request in PCAP has no response, skipping it")
+				return payloads, nil
+			}
+			return payloads, err
+		}
+		respBody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return payloads, fmt.Errorf("incomplete response body: %s", err.Error())
+		}
+
+		destination := req.Host
+		if destination == "" {
+			destination = server
+		}
+
+		payload := models.PayloadView{
+			Request: models.RequestDetailsView{
+				Method:      req.Method,
+				Scheme:      "http",
+				Destination: destination,
+				Path:        req.URL.Path,
+				Query:       req.URL.RawQuery,
+				Body:        string(reqBody),
+				Headers:     req.Header,
+			},
+			Response: models.ResponseDetailsView{
+				Status:  resp.StatusCode,
+				Body:    string(respBody),
+				Headers: resp.Header,
+			},
+		}
+		// chunked body was decoded
+		if len(resp.TransferEncoding) > 0 {
+			http.Header(payload.Response.Headers).Set("Content-Length", strconv.Itoa(len(respBody)))
+		}
+		if !utf8.Valid(respBody) {
+			payload.Response.Body = base64.StdEncoding.EncodeToString(respBody)
+			payload.Response.EncodedBody = true
+		}
+		payloads = append(payloads, payload)
+	}
+}
+
+// readFinalResponse - reads response to given request, informational (1xx) responses are skipped
+func readFinalResponse(r *bufio.Reader, req *http.Request) (*http.Response, error) {
+	for {
+		resp, err := http.ReadResponse(r, req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 200 || resp.StatusCode == http.StatusSwitchingProtocols {
+			return resp, nil
+		}
+	}
+}
+
+// readPCAPConnections - reads pcap or pcapng capture and reassembles its TCP connections. Fragmented IPv4 packets
+// aren't reassembled, gopacket doesn't decode TCP layer of fragments.
+func readPCAPConnections(r io.Reader) ([]*tcpConn, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(4)
+	if err != nil {
+		return nil, errNotPCAP
+	}
+
+	var source gopacket.PacketDataSource
+	var linkType func(ci gopacket.CaptureInfo) (layers.LinkType, error)
+	if binary.BigEndian.Uint32(magic) == pcapngSectionHeader {
+		ng, err := pcapgo.NewNgReader(buffered, pcapgo.DefaultNgReaderOptions)
+		if err != nil {
+			return nil, err
+		}
+		source = ng
+		// every interface has its own link type
+		linkType = func(ci gopacket.CaptureInfo) (layers.LinkType, error) {
+			iface, err := ng.Interface(ci.InterfaceIndex)
+			return iface.LinkType, err
+		}
+	} else {
+		reader, err := pcapgo.NewReader(buffered)
+		if err != nil {
+			return nil, errNotPCAP
+		}
+		source = reader
+		linkType = func(gopacket.CaptureInfo) (layers.LinkType, error) {
+			return reader.LinkType(), nil
+		}
+	}
+
+	factory := &tcpStreamFactory{open: make(map[string]*tcpConn)}
+	assembler := tcpassembly.NewAssembler(tcpassembly.NewStreamPool(factory))
+	for {
+		data, ci, err := source.ReadPacketData()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		lt, err := linkType(ci)
+		if err != nil {
+			return nil, err
+		}
+
+		packet := gopacket.NewPacket(data, lt, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+		tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP)
+		if !ok || packet.NetworkLayer() == nil {
+			continue
+		}
+		assembler.AssembleWithTimestamp(packet.NetworkLayer().NetworkFlow(), tcp, ci.Timestamp)
+	}
+	assembler.FlushAll()
+
+	return factory.conns, nil
+}
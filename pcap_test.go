@@ -0,0 +1,300 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// testSegment - TCP segment sent from client (toServer) or server
+type testSegment struct {
+	toServer bool
+	seq      uint32
+	syn      bool
+	payload  string
+}
+
+// tcpFrame - Ethernet frame with IPv4 TCP segment, checksums are not set as they aren't verified
+func tcpFrame(src, dst net.IP, srcPort, dstPort uint16, seq uint32, syn bool, payload []byte) []byte {
+	mac := net.HardwareAddr{0, 0, 0, 0, 0, 1}
+	eth := &layers.Ethernet{SrcMAC: mac, DstMAC: mac, EthernetType: layers.EthernetTypeIPv4}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: src.To4(), DstIP: dst.To4()}
+	tcp := &layers.TCP{SrcPort: layers.TCPPort(srcPort), DstPort: layers.TCPPort(dstPort), Seq: seq, ACK: true, SYN: syn}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, tcp, gopacket.Payload(payload)); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+// testFrames - frames of connection between 10.0.0.1:50000 and 10.0.0.2:80
+func testFrames(segments []testSegment) [][]byte {
+	client, server := net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")
+	var frames [][]byte
+	for _, s := range segments {
+		if s.toServer {
+			frames = append(frames, tcpFrame(client, server, 50000, 80, s.seq, s.syn, []byte(s.payload)))
+		} else {
+			frames = append(frames, tcpFrame(server, client, 80, 50000, s.seq, s.syn, []byte(s.payload)))
+		}
+	}
+	return frames
+}
+
+// testCaptureInfo - capture info of i-th frame, frames are a millisecond apart
+func testCaptureInfo(i int, frame []byte) gopacket.CaptureInfo {
+	return gopacket.CaptureInfo{
+		Timestamp:     time.Unix(1500000000, 0).Add(time.Duration(i) * time.Millisecond),
+		CaptureLength: len(frame),
+		Length:        len(frame),
+	}
+}
+
+// classicPCAP - libpcap file with Ethernet frames
+func classicPCAP(frames [][]byte) []byte {
+	var buf bytes.Buffer
+	w := pcapgo.NewWriter(&buf)
+	if err := w.WriteFileHeader(65535, layers.LinkTypeEthernet); err != nil {
+		panic(err)
+	}
+	for i, frame := range frames {
+		if err := w.WritePacket(testCaptureInfo(i, frame), frame); err != nil {
+			panic(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// pcapngFile - pcapng file with a single Ethernet interface
+func pcapngFile(frames [][]byte) []byte {
+	var buf bytes.Buffer
+	w, err := pcapgo.NewNgWriter(&buf, layers.LinkTypeEthernet)
+	if err != nil {
+		panic(err)
+	}
+	for i, frame := range frames {
+		if err := w.WritePacket(testCaptureInfo(i, frame), frame); err != nil {
+			panic(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+const (
+	pcapTestRequest  = "GET /users?id=1 HTTP/1.1\r\nHost: api.example.com\r\nAccept: application/json\r\n\r\n"
+	pcapTestResponse = "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 13\r\n\r\n{\"name\":\"jo\"}"
+)
+
+// exchangeSegments - handshake, request split into two segments and response
+func exchangeSegments() []testSegment {
+	return []testSegment{
+		{toServer: true, seq: 1000, syn: true},
+		{toServer: false, seq: 5000, syn: true},
+		{toServer: true, seq: 1001, payload: pcapTestRequest[:10]},
+		{toServer: true, seq: 1011, payload: pcapTestRequest[10:]},
+		{toServer: false, seq: 5001, payload: pcapTestResponse},
+	}
+}
+
+func simulatedBody(t *testing.T, d *Hoverfly, url string) (int, string) {
+	d.Cfg.SetMode(SimulateMode)
+	req, err := http.NewRequest("GET", url, nil)
+	testutil.Expect(t, err, nil)
+	_, resp := d.processRequest(req)
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	return resp.StatusCode, string(body)
+}
+
+func TestImportPCAP(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	imported, err := dbClient.ImportPCAP(bytes.NewReader(classicPCAP(testFrames(exchangeSegments()))))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, imported, 1)
+
+	status, body := simulatedBody(t, dbClient, "http://api.example.com/users?id=1")
+	testutil.Expect(t, status, http.StatusOK)
+	testutil.Expect(t, body, `{"name":"jo"}`)
+}
+
+func TestImportPCAPNG(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	imported, err := dbClient.ImportPCAP(bytes.NewReader(pcapngFile(testFrames(exchangeSegments()))))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, imported, 1)
+
+	_, body := simulatedBody(t, dbClient, "http://api.example.com/users?id=1")
+	testutil.Expect(t, body, `{"name":"jo"}`)
+}
+
+func TestImportPCAPReassemblesOutOfOrderSegments(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	response := "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n6\r\n world\r\n0\r\n\r\n"
+	segments := []testSegment{
+		{toServer: true, seq: 1000, syn: true},
+		{toServer: false, seq: 5000, syn: true},
+		// second part arrives first, first part is retransmitted
+		{toServer: true, seq: 1011, payload: pcapTestRequest[10:]},
+		{toServer: true, seq: 1001, payload: pcapTestRequest[:10]},
+		{toServer: true, seq: 1001, payload: pcapTestRequest[:10]},
+		{toServer: false, seq: 5001, payload: response[:30]},
+		// overlaps with the previous segment
+		{toServer: false, seq: 5021, payload: response[20:]},
+	}
+
+	imported, err := dbClient.ImportPCAP(bytes.NewReader(classicPCAP(testFrames(segments))))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, imported, 1)
+
+	_, body := simulatedBody(t, dbClient, "http://api.example.com/users?id=1")
+	testutil.Expect(t, body, "hello world")
+}
+
+func TestImportPCAPPipelinedRequests(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	requests := "GET /a HTTP/1.1\r\nHost: api.example.com\r\n\r\n" +
+		"POST /b HTTP/1.1\r\nHost: api.example.com\r\nContent-Length: 4\r\n\r\ndata"
+	responses := "HTTP/1.1 200 OK\r\nContent-Length: 1\r\n\r\na" +
+		"HTTP/1.1 100 Continue\r\n\r\n" +
+		"HTTP/1.1 201 Created\r\nContent-Length: 1\r\n\r\nb"
+	segments := []testSegment{
+		{toServer: true, seq: 1000, syn: true},
+		{toServer: false, seq: 5000, syn: true},
+		{toServer: true, seq: 1001, payload: requests},
+		{toServer: false, seq: 5001, payload: responses},
+	}
+
+	imported, err := dbClient.ImportPCAP(bytes.NewReader(classicPCAP(testFrames(segments))))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, imported, 2)
+
+	_, body := simulatedBody(t, dbClient, "http://api.example.com/a")
+	testutil.Expect(t, body, "a")
+
+	req, err := http.NewRequest("POST", "http://api.example.com/b", strings.NewReader("data"))
+	testutil.Expect(t, err, nil)
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusCreated)
+}
+
+func TestImportPCAPSkipsTLSStreams(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	tls := testFrames([]testSegment{
+		{toServer: true, seq: 1000, syn: true},
+		{toServer: false, seq: 5000, syn: true},
+		{toServer: true, seq: 1001, payload: "\x16\x03\x01\x00\x05hello"},
+		{toServer: false, seq: 5001, payload: "\x16\x03\x03\x00\x05world"},
+	})
+
+	_, err := dbClient.ImportPCAP(bytes.NewReader(classicPCAP(tls)))
+	testutil.Refute(t, err, nil)
+
+	// cleartext stream of the same capture is still imported
+	client, other := net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.3")
+	frames := append(tls,
+		tcpFrame(client, other, 50001, 80, 1, false, []byte(pcapTestRequest)),
+		tcpFrame(other, client, 80, 50001, 7, false, []byte(pcapTestResponse)),
+	)
+	imported, err := dbClient.ImportPCAP(bytes.NewReader(classicPCAP(frames)))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, imported, 1)
+
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+}
+
+func TestImportPCAPInvalid(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	_, err := dbClient.ImportPCAP(bytes.NewBufferString("not a capture file"))
+	testutil.Refute(t, err, nil)
+
+	truncated := classicPCAP(testFrames(exchangeSegments()))
+	_, err = dbClient.ImportPCAP(bytes.NewReader(truncated[:len(truncated)-5]))
+	testutil.Refute(t, err, nil)
+
+	truncated = pcapngFile(testFrames(exchangeSegments()))
+	_, err = dbClient.ImportPCAP(bytes.NewReader(truncated[:len(truncated)-5]))
+	testutil.Refute(t, err, nil)
+
+	// only the header of pcapng section
+	_, err = dbClient.ImportPCAP(bytes.NewReader(truncated[:8]))
+	testutil.Refute(t, err, nil)
+}
+
+func TestImportPCAPSkipsMalformedPackets(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	frames := testFrames(exchangeSegments())
+	// frames with different request data for the same sequence numbers, import fails if any of them is used
+	corrupted := testFrames([]testSegment{{toServer: true, seq: 1001, payload: "XXXXXXXXXX"}})[0]
+	badHeaderLength := append([]byte(nil), corrupted...)
+	// IPv4 header length longer than the packet
+	badHeaderLength[14] = 0x4f
+	cutTCPHeader := corrupted[:14+20+10]
+	fragment := append([]byte(nil), corrupted...)
+	// more fragments flag
+	fragment[14+6] = 0x20
+
+	malformed := [][]byte{{0x01, 0x02}, badHeaderLength, cutTCPHeader, fragment}
+	imported, err := dbClient.ImportPCAP(bytes.NewReader(classicPCAP(append(malformed, frames...))))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, imported, 1)
+
+	_, body := simulatedBody(t, dbClient, "http://api.example.com/users?id=1")
+	testutil.Expect(t, body, `{"name":"jo"}`)
+}
+
+func TestImportPCAPHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("POST", "/api/simulation/pcap", bytes.NewReader(classicPCAP(testFrames(exchangeSegments()))))
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+	testutil.Expect(t, strings.Contains(rec.Body.String(), "1 requests imported"), true)
+
+	req, err = http.NewRequest("POST", "/api/simulation/pcap", bytes.NewBufferString("not a capture file"))
+	testutil.Expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusBadRequest)
+}
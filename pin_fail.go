@@ -0,0 +1,119 @@
+package hoverfly
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rusenask/goproxy"
+)
+
+var (
+	pinFailCA     tls.Certificate
+	pinFailCAErr  error
+	pinFailCAOnce sync.Once
+)
+
+// SetPinFailHosts - sets regexp patterns of hosts which certificate pinning failure is simulated in PinFailMode
+func (c *Configuration) SetPinFailHosts(patterns []string) error {
+	compiled, err := compilePatterns("pin fail host", patterns)
+	if err != nil {
+		return err
+	}
+	c.PinFailHosts = patterns
+	c.pinFailHostPatterns = compiled
+	return nil
+}
+
+// IsPinFailHost - checks whether certificate pinning failure should be simulated for given host, host patterns
+// are regexps, every host matches when no patterns are set
+func (c *Configuration) IsPinFailHost(host string) bool {
+	if !c.PinFailMode {
+		return false
+	}
+	if len(c.PinFailHosts) == 0 {
+		return true
+	}
+	for _, re := range c.pinFailHostPatterns {
+		if re.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// pinFailConnect - MITM action for CONNECT requests to pin fail hosts, host certificate is signed by throwaway CA
+// instead of Hoverfly CA so that it doesn't match pinned certificate or key
+func (d *Hoverfly) pinFailConnect(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+	hostname, _, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname = host
+	}
+	if !d.Cfg.IsPinFailHost(hostname) {
+		return nil, ""
+	}
+
+	ca, err := getPinFailCA()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"host":  host,
+		}).Error("Failed to create certificate for pinning failure")
+		return nil, ""
+	}
+
+	log.WithFields(log.Fields{
+		"host": host,
+	}).Info("Presenting unpinned certificate")
+
+	return &goproxy.ConnectAction{Action: goproxy.ConnectMitm, TLSConfig: goproxy.TLSConfigFromCA(ca)}, host
+}
+
+// getPinFailCA - returns self-signed CA generated once per process
+func getPinFailCA() (*tls.Certificate, error) {
+	pinFailCAOnce.Do(func() {
+		pinFailCA, pinFailCAErr = generatePinFailCA()
+	})
+	return &pinFailCA, pinFailCAErr
+}
+
+func generatePinFailCA() (tls.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Hoverfly pinning failure"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
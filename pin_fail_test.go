@@ -0,0 +1,68 @@
+package hoverfly
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+	"github.com/rusenask/goproxy"
+)
+
+// hoverflyCAClient - HTTP client that uses given proxy and trusts only Hoverfly CA
+func hoverflyCAClient(t *testing.T, proxyURL string) *http.Client {
+	ca, err := x509.ParseCertificate(goproxy.GoproxyCa.Certificate[0])
+	testutil.Expect(t, err, nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	u, err := url.Parse(proxyURL)
+	testutil.Expect(t, err, nil)
+	return &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(u),
+		TLSClientConfig: &tls.Config{RootCAs: pool},
+	}}
+}
+
+func TestPinFailHostGetsUnpinnedCertificate(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.PinFailMode = true
+	testutil.Expect(t, dbClient.Cfg.SetPinFailHosts([]string{"^pinned\\.example\\.com$"}), nil)
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.UpdateProxy()
+
+	proxy := httptest.NewServer(dbClient.Proxy)
+	defer proxy.Close()
+	client := hoverflyCAClient(t, proxy.URL)
+
+	_, err := client.Get("https://pinned.example.com/")
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, strings.Contains(err.Error(), "certificate"), true)
+
+	// other hosts are served with certificate signed by Hoverfly CA, there is nothing to simulate for them
+	resp, err := client.Get("https://other.example.com/")
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+}
+
+func TestIsPinFailHost(t *testing.T) {
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.SetPinFailHosts([]string{"api\\.example\\.com"}), nil)
+	testutil.Expect(t, cfg.IsPinFailHost("api.example.com"), false)
+
+	cfg.PinFailMode = true
+	testutil.Expect(t, cfg.IsPinFailHost("api.example.com"), true)
+	testutil.Expect(t, cfg.IsPinFailHost("example.com"), false)
+
+	testutil.Expect(t, cfg.SetPinFailHosts(nil), nil)
+	testutil.Expect(t, cfg.IsPinFailHost("example.com"), true)
+
+	testutil.Refute(t, cfg.SetPinFailHosts([]string{"api\\.(example"}), nil)
+}
@@ -0,0 +1,124 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// pipelineMaxInFlight - how many pipelined requests of one connection are processed at once, further requests
+// aren't read until the oldest response is written
+const pipelineMaxInFlight = 16
+
+// pipelinedResponse - response of pipelined request, ready is closed once it is buffered
+type pipelinedResponse struct {
+	req   *http.Request
+	rec   *bufferedResponseWriter
+	ready chan struct{}
+}
+
+// pipelineHandler - takes over client connections in simulate mode when PipelineSimulation is enabled, requests
+// client sends without waiting for responses are processed concurrently and responses are written in the order
+// the requests arrived in
+type pipelineHandler struct {
+	handler http.Handler
+	cfg     *Configuration
+}
+
+func (h *pipelineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok || !h.cfg.PipelineSimulation || r.ProtoMajor != 1 || r.Method == "CONNECT" || r.Header.Get("Upgrade") != "" ||
+		requestMode(r, h.cfg) != SimulateMode {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	// body of the first request can't be read once connection is taken over
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("Failed to hijack connection, pipelined requests are processed one by one")
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	defer conn.Close()
+
+	responses := make(chan *pipelinedResponse, pipelineMaxInFlight)
+	written := make(chan struct{})
+	go writePipelinedResponses(conn, responses, written)
+
+	req := r
+	for {
+		resp := &pipelinedResponse{
+			req:   req,
+			rec:   &bufferedResponseWriter{header: make(http.Header), status: http.StatusOK},
+			ready: make(chan struct{}),
+		}
+		responses <- resp
+		go func() {
+			defer close(resp.ready)
+			h.handler.ServeHTTP(resp.rec, resp.req)
+		}()
+
+		if req.Close {
+			break
+		}
+		next, err := http.ReadRequest(buf.Reader)
+		if err != nil {
+			break
+		}
+		// next request can only be read once body of this one is consumed
+		body, err := ioutil.ReadAll(next.Body)
+		if err != nil {
+			break
+		}
+		next.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.RemoteAddr = r.RemoteAddr
+		req = next.WithContext(r.Context())
+	}
+
+	close(responses)
+	<-written
+}
+
+// writePipelinedResponses - writes responses in order of their requests, the rest are only waited for once
+// writing fails
+func writePipelinedResponses(conn net.Conn, responses <-chan *pipelinedResponse, written chan<- struct{}) {
+	defer close(written)
+
+	var err error
+	for resp := range responses {
+		<-resp.ready
+		if err != nil {
+			continue
+		}
+		err = (&http.Response{
+			StatusCode:    resp.rec.status,
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        resp.rec.header,
+			Body:          ioutil.NopCloser(&resp.rec.body),
+			ContentLength: int64(resp.rec.body.Len()),
+			Close:         resp.req.Close,
+			Request:       resp.req,
+		}).Write(conn)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":       err.Error(),
+				"destination": resp.req.Host,
+				"path":        resp.req.URL.Path,
+			}).Warn("Failed to write pipelined response")
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package hoverfly
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// pipelineServer - responds with request number, earlier requests take longer so that responses are ready out of
+// order
+func pipelineServer(cfg *Configuration, inFlight *int32) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(inFlight, 1)
+		defer atomic.AddInt32(inFlight, -1)
+
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		time.Sleep(time.Duration(5-n) * 20 * time.Millisecond)
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "response %d%s", n, body)
+	})
+	return httptest.NewServer(&pipelineHandler{handler: handler, cfg: cfg})
+}
+
+// sendPipelined - writes all requests at once and reads their responses
+func sendPipelined(t *testing.T, server *httptest.Server, count int) []string {
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	testutil.Expect(t, err, nil)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	var requests bytes.Buffer
+	var sent []*http.Request
+	for i := 0; i < count; i++ {
+		req, err := http.NewRequest("POST", server.URL+"/"+strconv.Itoa(i), strings.NewReader(fmt.Sprintf(" body %d", i)))
+		testutil.Expect(t, err, nil)
+		req.Close = i == count-1
+		testutil.Expect(t, req.Write(&requests), nil)
+		sent = append(sent, req)
+	}
+	_, err = conn.Write(requests.Bytes())
+	testutil.Expect(t, err, nil)
+
+	reader := bufio.NewReader(conn)
+	var bodies []string
+	for _, req := range sent {
+		resp, err := http.ReadResponse(reader, req)
+		testutil.Expect(t, err, nil)
+		body, err := ioutil.ReadAll(resp.Body)
+		testutil.Expect(t, err, nil)
+		bodies = append(bodies, string(body))
+	}
+	return bodies
+}
+
+func TestPipelinedResponsesAreWrittenInOrder(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetMode(SimulateMode)
+	cfg.PipelineSimulation = true
+
+	var inFlight int32
+	server := pipelineServer(cfg, &inFlight)
+	defer server.Close()
+
+	start := time.Now()
+	bodies := sendPipelined(t, server, 5)
+	testutil.Expect(t, len(bodies), 5)
+	for i, body := range bodies {
+		testutil.Expect(t, body, fmt.Sprintf("response %d body %d", i, i))
+	}
+
+	// requests were processed concurrently, one by one they take 300ms
+	testutil.Expect(t, time.Since(start) < 250*time.Millisecond, true)
+}
+
+func TestPipelinedRequestsProcessedOneByOneWhenDisabled(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetMode(SimulateMode)
+
+	var inFlight, maxInFlight int32
+	server := pipelineServer(cfg, &inFlight)
+	defer server.Close()
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if n := atomic.LoadInt32(&inFlight); n > atomic.LoadInt32(&maxInFlight) {
+				atomic.StoreInt32(&maxInFlight, n)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	bodies := sendPipelined(t, server, 3)
+	close(done)
+	testutil.Expect(t, len(bodies), 3)
+	testutil.Expect(t, bodies[2], "response 2 body 2")
+	testutil.Expect(t, atomic.LoadInt32(&maxInFlight), int32(1))
+}
+
+func TestPipelineSimulationOnlyInSimulateMode(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetMode(CaptureMode)
+	cfg.PipelineSimulation = true
+
+	var inFlight int32
+	server := pipelineServer(cfg, &inFlight)
+	defer server.Close()
+
+	bodies := sendPipelined(t, server, 2)
+	testutil.Expect(t, len(bodies), 2)
+	testutil.Expect(t, bodies[0], "response 0 body 0")
+	testutil.Expect(t, bodies[1], "response 1 body 1")
+}
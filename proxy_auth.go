@@ -0,0 +1,85 @@
+package hoverfly
+
+import (
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ProxyAuthRealm - realm of simulated proxy authentication challenge
+const ProxyAuthRealm = "hoverfly"
+
+// proxyAuthHandler - simulates proxy that requires authentication. First request from a client without
+// Proxy-Authorization header gets '407 Proxy Authentication Required' challenge, any Basic credential is
+// accepted and remembered for the client, so that its following requests are served normally.
+type proxyAuthHandler struct {
+	handler http.Handler
+	cfg     *Configuration
+
+	mu          sync.Mutex
+	credentials map[string]string
+}
+
+func newProxyAuthHandler(handler http.Handler, cfg *Configuration) *proxyAuthHandler {
+	return &proxyAuthHandler{handler: handler, cfg: cfg, credentials: make(map[string]string)}
+}
+
+func (h *proxyAuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.cfg.SimulateProxyAuth {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	client := clientHost(r)
+	credential, ok := basicProxyCredential(r.Header.Get("Proxy-Authorization"))
+	// header is meant for the proxy only
+	r.Header.Del("Proxy-Authorization")
+
+	h.mu.Lock()
+	if ok {
+		h.credentials[client] = credential
+	} else {
+		_, ok = h.credentials[client]
+	}
+	h.mu.Unlock()
+
+	if !ok {
+		log.WithFields(log.Fields{
+			"client":      client,
+			"method":      r.Method,
+			"destination": r.Host,
+		}).Info("Proxy authentication required, sending challenge")
+
+		w.Header().Set("Proxy-Authenticate", `Basic realm="`+ProxyAuthRealm+`"`)
+		http.Error(w, http.StatusText(http.StatusProxyAuthRequired), http.StatusProxyAuthRequired)
+		return
+	}
+
+	h.handler.ServeHTTP(w, r)
+}
+
+// basicProxyCredential - returns user name and password from Basic Proxy-Authorization header value
+func basicProxyCredential(header string) (string, bool) {
+	const prefix = "Basic "
+	if !strings.HasPrefix(header, prefix) {
+		return "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header[len(prefix):]))
+	if err != nil || !strings.Contains(string(decoded), ":") {
+		return "", false
+	}
+	return string(decoded), true
+}
+
+// clientHost - client address without port
+func clientHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,73 @@
+package hoverfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestProxyAuthChallengeUntilCredentialSent(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SimulateProxyAuth = true
+
+	var forwardedAuth string
+	proxy := httptest.NewServer(newProxyAuthHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedAuth = r.Header.Get("Proxy-Authorization")
+		w.WriteHeader(http.StatusOK)
+	}), cfg))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	testutil.Expect(t, err, nil)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get("http://example.com/")
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusProxyAuthRequired)
+	testutil.Expect(t, resp.Header.Get("Proxy-Authenticate"), `Basic realm="hoverfly"`)
+
+	authURL := *proxyURL
+	authURL.User = url.UserPassword("user", "pass")
+	authClient := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(&authURL)}}
+
+	resp, err = authClient.Get("http://example.com/")
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, forwardedAuth, "")
+
+	// credential is remembered for the client
+	resp, err = client.Get("http://example.com/")
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+}
+
+func TestProxyAuthDisabled(t *testing.T) {
+	cfg := InitSettings()
+
+	rec := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+
+	newProxyAuthHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), cfg).ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+}
+
+func TestBasicProxyCredential(t *testing.T) {
+	credential, ok := basicProxyCredential("Basic dXNlcjpwYXNz")
+	testutil.Expect(t, ok, true)
+	testutil.Expect(t, credential, "user:pass")
+
+	_, ok = basicProxyCredential("Bearer token")
+	testutil.Expect(t, ok, false)
+
+	_, ok = basicProxyCredential("Basic not-base64")
+	testutil.Expect(t, ok, false)
+}
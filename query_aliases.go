@@ -0,0 +1,79 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// LoadQueryParamAliases - reads query parameter aliases from JSON file, keys are canonical parameter names, i.e.:
+//
+//	{"userId": ["user_id", "userid"]}
+func (c *Configuration) LoadQueryParamAliases(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var aliases map[string][]string
+	if err := json.NewDecoder(f).Decode(&aliases); err != nil {
+		return fmt.Errorf("failed to parse query parameter aliases file: %s", err.Error())
+	}
+	if err := validateQueryParamAliases(aliases); err != nil {
+		return err
+	}
+
+	c.QueryParamAliases = aliases
+	return nil
+}
+
+// validateQueryParamAliases - every alias can stand for one canonical name only
+func validateQueryParamAliases(aliases map[string][]string) error {
+	seen := make(map[string]string)
+	for canonical, names := range aliases {
+		for _, alias := range names {
+			if other, ok := seen[alias]; ok && other != canonical {
+				return fmt.Errorf("query parameter alias '%s' is used for both '%s' and '%s'", alias, other, canonical)
+			}
+			if _, ok := aliases[alias]; ok && alias != canonical {
+				return fmt.Errorf("query parameter alias '%s' is also a canonical name", alias)
+			}
+			seen[alias] = canonical
+		}
+	}
+	return nil
+}
+
+// normaliseQuery - renames aliased query parameters to their canonical names, parameter order and values are
+// kept as they are
+func (c *Configuration) normaliseQuery(rawQuery string) string {
+	if c == nil || len(c.QueryParamAliases) == 0 || rawQuery == "" {
+		return rawQuery
+	}
+
+	canonicalNames := make(map[string]string)
+	for canonical, aliases := range c.QueryParamAliases {
+		for _, alias := range aliases {
+			canonicalNames[alias] = canonical
+		}
+	}
+
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		name, value := param, ""
+		if eq := strings.Index(param, "="); eq >= 0 {
+			name, value = param[:eq], param[eq:]
+		}
+		unescaped, err := url.QueryUnescape(name)
+		if err != nil {
+			continue
+		}
+		if canonical, ok := canonicalNames[unescaped]; ok {
+			params[i] = url.QueryEscape(canonical) + value
+		}
+	}
+	return strings.Join(params, "&")
+}
@@ -0,0 +1,94 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestNormaliseQuery(t *testing.T) {
+	cfg := InitSettings()
+	cfg.QueryParamAliases = map[string][]string{"userId": {"user_id", "userid"}}
+
+	testutil.Expect(t, cfg.normaliseQuery("user_id=42&page=1"), "userId=42&page=1")
+	testutil.Expect(t, cfg.normaliseQuery("page=1&userid=42"), "page=1&userId=42")
+	testutil.Expect(t, cfg.normaliseQuery("user%5Fid=42"), "userId=42")
+	testutil.Expect(t, cfg.normaliseQuery("userId=42&user_id_extra=1"), "userId=42&user_id_extra=1")
+	testutil.Expect(t, cfg.normaliseQuery("user_id"), "userId")
+	testutil.Expect(t, cfg.normaliseQuery(""), "")
+}
+
+func TestNormaliseQueryWithoutAliases(t *testing.T) {
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.normaliseQuery("user_id=42"), "user_id=42")
+}
+
+func TestSimulateMatchesAliasedQueryParam(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.QueryParamAliases = map[string][]string{"userId": {"user_id"}}
+
+	captured, err := http.NewRequest("GET", "http://example.com/users?userId=42", nil)
+	testutil.Expect(t, err, nil)
+	dbClient.save(captured, []byte(""), &http.Response{StatusCode: 201, Header: http.Header{}}, []byte("user 42"))
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	req, err := http.NewRequest("GET", "http://example.com/users?user_id=42", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, 201)
+
+	// values still have to match
+	req, err = http.NewRequest("GET", "http://example.com/users?user_id=43", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp = dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+}
+
+func TestImportedPayloadsMatchAliasedQueryParam(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.QueryParamAliases = map[string][]string{"userId": {"user_id"}}
+
+	err := dbClient.ImportPayloads([]models.PayloadView{{
+		Request:  models.RequestDetailsView{Method: "GET", Destination: "example.com", Path: "/users", Query: "user_id=42"},
+		Response: models.ResponseDetailsView{Status: 201, Body: "user 42"},
+	}})
+	testutil.Expect(t, err, nil)
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	req, err := http.NewRequest("GET", "http://example.com/users?userId=42", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, 201)
+}
+
+func TestLoadQueryParamAliases(t *testing.T) {
+	f, err := ioutil.TempFile("", "aliases")
+	testutil.Expect(t, err, nil)
+	defer os.Remove(f.Name())
+	f.WriteString(`{"userId": ["user_id", "userid"]}`)
+	f.Close()
+
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.LoadQueryParamAliases(f.Name()), nil)
+	testutil.Expect(t, len(cfg.QueryParamAliases["userId"]), 2)
+	testutil.Expect(t, cfg.QueryParamAliases["userId"][0], "user_id")
+}
+
+func TestValidateQueryParamAliases(t *testing.T) {
+	testutil.Expect(t, validateQueryParamAliases(map[string][]string{"userId": {"user_id"}, "orderId": {"order_id"}}), nil)
+	testutil.Refute(t, validateQueryParamAliases(map[string][]string{"userId": {"id"}, "orderId": {"id"}}), nil)
+	testutil.Refute(t, validateQueryParamAliases(map[string][]string{"userId": {"orderId"}, "orderId": {"order_id"}}), nil)
+}
@@ -0,0 +1,143 @@
+package hoverfly
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	errMultipleRanges     = errors.New("multiple byte ranges are not supported")
+	errInvalidRange       = errors.New("invalid byte range")
+	errUnsatisfiableRange = errors.New("byte range not satisfiable")
+)
+
+// byteRange - inclusive range of bytes as described in RFC 7233
+type byteRange struct {
+	start int64
+	end   int64
+}
+
+func (r byteRange) length() int64 {
+	return r.end - r.start + 1
+}
+
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.end, size)
+}
+
+// parseByteRange - parses single range 'Range' header value (i.e. 'bytes=0-1023', 'bytes=500-' or 'bytes=-500')
+// against body of given size
+func parseByteRange(header string, size int64) (r byteRange, err error) {
+	const prefix = "bytes="
+
+	if !strings.HasPrefix(header, prefix) {
+		return r, errInvalidRange
+	}
+
+	spec := strings.TrimSpace(header[len(prefix):])
+
+	if strings.Contains(spec, ",") {
+		return r, errMultipleRanges
+	}
+
+	i := strings.Index(spec, "-")
+	if i < 0 {
+		return r, errInvalidRange
+	}
+
+	first, last := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+
+	if first == "" {
+		// suffix range, last N bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return r, errInvalidRange
+		}
+		if n == 0 || size == 0 {
+			return r, errUnsatisfiableRange
+		}
+		if n > size {
+			n = size
+		}
+		return byteRange{start: size - n, end: size - 1}, nil
+	}
+
+	r.start, err = strconv.ParseInt(first, 10, 64)
+	if err != nil || r.start < 0 {
+		return r, errInvalidRange
+	}
+
+	if last == "" {
+		r.end = size - 1
+	} else {
+		r.end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || r.end < r.start {
+			return r, errInvalidRange
+		}
+		if r.end >= size {
+			r.end = size - 1
+		}
+	}
+
+	if r.start >= size {
+		return r, errUnsatisfiableRange
+	}
+
+	return r, nil
+}
+
+// applyRange - returns 206 Partial Content response with requested slice of the body when request has
+// a 'Range' header and stored response was successful. Invalid and multi range headers are ignored and
+// full response is returned.
+func applyRange(req *http.Request, resp *http.Response) *http.Response {
+	header := req.Header.Get("Range")
+
+	if header == "" || resp.StatusCode != http.StatusOK {
+		return resp
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to read response body for range request")
+		return hoverflyError(req, err, "Failed to read response body for range request", http.StatusInternalServerError, ErrorCodeInternal, errorFormat(req))
+	}
+	resp.Body.Close()
+
+	size := int64(len(body))
+
+	r, err := parseByteRange(header, size)
+
+	switch err {
+	case nil:
+		resp.StatusCode = http.StatusPartialContent
+		resp.Header.Set("Content-Range", r.contentRange(size))
+		body = body[r.start : r.end+1]
+
+	case errUnsatisfiableRange:
+		resp.StatusCode = http.StatusRequestedRangeNotSatisfiable
+		resp.Header.Set(ErrorCodeHeader, string(ErrorCodeRangeNotSatisfiable))
+		resp.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		body = []byte("")
+
+	default:
+		log.WithFields(log.Fields{
+			"range": header,
+			"error": err.Error(),
+		}).Debug("Ignoring range header")
+	}
+
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.ContentLength = int64(len(body))
+	resp.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+
+	return resp
+}
@@ -0,0 +1,81 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestParseByteRange(t *testing.T) {
+	r, err := parseByteRange("bytes=0-9", 100)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, r, byteRange{start: 0, end: 9})
+
+	r, err = parseByteRange("bytes=90-", 100)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, r, byteRange{start: 90, end: 99})
+
+	r, err = parseByteRange("bytes=-10", 100)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, r, byteRange{start: 90, end: 99})
+
+	r, err = parseByteRange("bytes=50-500", 100)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, r, byteRange{start: 50, end: 99})
+}
+
+func TestParseByteRangeErrors(t *testing.T) {
+	_, err := parseByteRange("bytes=0-9,20-29", 100)
+	testutil.Expect(t, err, errMultipleRanges)
+
+	_, err = parseByteRange("items=0-9", 100)
+	testutil.Expect(t, err, errInvalidRange)
+
+	_, err = parseByteRange("bytes=9-0", 100)
+	testutil.Expect(t, err, errInvalidRange)
+
+	_, err = parseByteRange("bytes=100-", 100)
+	testutil.Expect(t, err, errUnsatisfiableRange)
+}
+
+func getRangeResponse(t *testing.T, rangeHeader string) *http.Response {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Range", rangeHeader)
+
+	payload := models.Payload{Response: models.ResponseDetails{Status: 200, Body: "0123456789"}}
+
+	return applyRange(req, NewConstructor(req, payload).ReconstructResponse())
+}
+
+func TestApplyRange(t *testing.T) {
+	resp := getRangeResponse(t, "bytes=2-4")
+
+	testutil.Expect(t, resp.StatusCode, http.StatusPartialContent)
+	testutil.Expect(t, resp.Header.Get("Content-Range"), "bytes 2-4/10")
+	testutil.Expect(t, resp.Header.Get("Content-Length"), "3")
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "234")
+}
+
+func TestApplyRangeNotSatisfiable(t *testing.T) {
+	resp := getRangeResponse(t, "bytes=20-")
+
+	testutil.Expect(t, resp.StatusCode, http.StatusRequestedRangeNotSatisfiable)
+	testutil.Expect(t, resp.Header.Get("Content-Range"), "bytes */10")
+}
+
+func TestApplyRangeMultipleRangesIgnored(t *testing.T) {
+	resp := getRangeResponse(t, "bytes=0-1,4-5")
+
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "0123456789")
+}
@@ -0,0 +1,260 @@
+package hoverfly
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// recentAccessKeyPrefix - prefix of metadata entries with the last time a record was served, value is RFC 3339 time
+const recentAccessKeyPrefix = "recent_access_"
+
+// defaultRecentRecords - number of records returned by RecentRecordsHandler when 'n' is not given
+const defaultRecentRecords = 50
+
+// recentAccessFlushInterval - minimum time between writes of access times into metadata cache
+const recentAccessFlushInterval = 10 * time.Second
+
+type recentAccess struct {
+	key string
+	at  time.Time
+}
+
+// accessIndex - request cache keys ordered by the last time their records were served, newest first. Access times
+// are kept in memory and written into MetadataCache in batches, at most once per flushInterval in the background
+// and on flush, so that serving a record doesn't write into the cache. The index is built from MetadataCache on
+// first use so that it survives restarts with persistent cache.
+type accessIndex struct {
+	mu       sync.Mutex
+	loaded   bool
+	order    *list.List
+	elements map[string]*list.Element
+
+	// dirty - keys which access times weren't written into metadata cache yet
+	dirty         map[string]time.Time
+	flushInterval time.Duration
+	flushedAt     time.Time
+	flushing      bool
+	// flushMu - serializes batches so that an older batch doesn't overwrite a newer one
+	flushMu sync.Mutex
+}
+
+func newAccessIndex() *accessIndex {
+	return &accessIndex{
+		order:         list.New(),
+		elements:      make(map[string]*list.Element),
+		dirty:         make(map[string]time.Time),
+		flushInterval: recentAccessFlushInterval,
+		flushedAt:     time.Now(),
+	}
+}
+
+// load - adds access times stored in metadata cache, keys that were accessed since start keep their position
+func (i *accessIndex) load(d *Hoverfly) {
+	if i.loaded {
+		return
+	}
+	keys, err := d.MetadataCache.Keys()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("Failed to read record access times")
+		return
+	}
+	i.loaded = true
+
+	var stored []recentAccess
+	for _, k := range keys {
+		if !strings.HasPrefix(k, recentAccessKeyPrefix) {
+			continue
+		}
+		key := strings.TrimPrefix(k, recentAccessKeyPrefix)
+		if _, ok := i.elements[key]; ok {
+			continue
+		}
+		v, err := d.MetadataCache.Get([]byte(k))
+		if err != nil {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339Nano, string(v))
+		if err != nil {
+			continue
+		}
+		stored = append(stored, recentAccess{key: key, at: at})
+	}
+	sort.Slice(stored, func(a, b int) bool { return stored[a].at.After(stored[b].at) })
+
+	// stored accesses are older than the ones made since start
+	for _, access := range stored {
+		i.elements[access.key] = i.order.PushBack(access)
+	}
+}
+
+// touch - moves key to the front, its access time is stored with the next batch
+func (i *accessIndex) touch(d *Hoverfly, key string, at time.Time) {
+	if i == nil {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.load(d)
+	if e, ok := i.elements[key]; ok {
+		e.Value = recentAccess{key: key, at: at}
+		i.order.MoveToFront(e)
+	} else {
+		i.elements[key] = i.order.PushFront(recentAccess{key: key, at: at})
+	}
+	i.dirty[key] = at
+
+	if !i.flushing && time.Since(i.flushedAt) >= i.flushInterval {
+		i.flushing = true
+		go func() {
+			i.flush(d)
+			i.mu.Lock()
+			i.flushing = false
+			i.mu.Unlock()
+		}()
+	}
+}
+
+// flush - stores access times of records served since the last batch
+func (i *accessIndex) flush(d *Hoverfly) {
+	if i == nil {
+		return
+	}
+	i.flushMu.Lock()
+	defer i.flushMu.Unlock()
+
+	i.mu.Lock()
+	dirty := i.dirty
+	i.dirty = make(map[string]time.Time)
+	i.flushedAt = time.Now()
+	i.mu.Unlock()
+
+	for key, at := range dirty {
+		if err := d.MetadataCache.Set([]byte(recentAccessKeyPrefix+key), []byte(at.Format(time.RFC3339Nano))); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"key":   key,
+			}).Warn("Failed to store record access time")
+		}
+	}
+}
+
+// remove - forgets key of record that is not in the cache any more
+func (i *accessIndex) remove(d *Hoverfly, key string) {
+	if i == nil {
+		return
+	}
+	i.mu.Lock()
+	if e, ok := i.elements[key]; ok {
+		i.order.Remove(e)
+		delete(i.elements, key)
+	}
+	delete(i.dirty, key)
+	i.mu.Unlock()
+
+	d.MetadataCache.Delete([]byte(recentAccessKeyPrefix + key))
+}
+
+// recent - up to n of the most recently accessed keys, newest first
+func (i *accessIndex) recent(d *Hoverfly, n int) []recentAccess {
+	if i == nil {
+		return nil
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.load(d)
+
+	var accesses []recentAccess
+	for e := i.order.Front(); e != nil && len(accesses) < n; e = e.Next() {
+		accesses = append(accesses, e.Value.(recentAccess))
+	}
+	return accesses
+}
+
+// recentRecord - record with the last time it was served
+type recentRecord struct {
+	*models.PayloadView
+	ID             string    `json:"id"`
+	LastAccessedAt time.Time `json:"lastAccessedAt"`
+}
+
+type recentRecords struct {
+	Data []recentRecord `json:"data"`
+}
+
+// RecentRecords - up to n of the most recently served records, newest first. Only the returned records are read
+// from the request cache, records that were removed since they were served are skipped.
+func (d *Hoverfly) RecentRecords(n int) ([]recentRecord, error) {
+	for {
+		accesses := d.recentAccess.recent(d, n)
+		records := []recentRecord{}
+		removed := false
+
+		for _, access := range accesses {
+			bts, err := d.RequestCache.Get([]byte(access.key))
+			if err != nil {
+				d.recentAccess.remove(d, access.key)
+				removed = true
+				continue
+			}
+			payload, err := models.NewPayloadFromBytes(bts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode record '%s': %s", access.key, err.Error())
+			}
+			records = append(records, recentRecord{
+				PayloadView:    payload.ConvertToPayloadView(),
+				ID:             access.key,
+				LastAccessedAt: access.at,
+			})
+		}
+
+		// removed records leave room for older ones
+		if !removed || len(accesses) < n {
+			return records, nil
+		}
+	}
+}
+
+// RecentRecordsHandler - returns the 'n' (50 by default) most recently served records with their last access time,
+// newest first
+func (d *Hoverfly) RecentRecordsHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	n := defaultRecentRecords
+	if v := req.URL.Query().Get("n"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			http.Error(w, fmt.Sprintf("invalid number of records '%s', expected positive integer", v), http.StatusBadRequest)
+			return
+		}
+		n = parsed
+	}
+
+	records, err := d.RecentRecords(n)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get recent records")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b, err := json.Marshal(recentRecords{Data: records})
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
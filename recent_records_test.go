@@ -0,0 +1,201 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// serveRecorded - captures requests to given paths and serves them in given order in simulate mode
+func serveRecorded(t *testing.T, dbClient *Hoverfly, captured []string, served []string) {
+	dbClient.Cfg.SetMode(CaptureMode)
+	for _, path := range captured {
+		req, err := http.NewRequest("GET", "http://example.com"+path, nil)
+		testutil.Expect(t, err, nil)
+		dbClient.captureRequest(req)
+	}
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	for _, path := range served {
+		req, err := http.NewRequest("GET", "http://example.com"+path, nil)
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, dbClient.getResponse(req).StatusCode, http.StatusOK)
+	}
+}
+
+func recentPaths(t *testing.T, dbClient *Hoverfly, n int) []string {
+	records, err := dbClient.RecentRecords(n)
+	testutil.Expect(t, err, nil)
+	paths := []string{}
+	for _, record := range records {
+		paths = append(paths, record.Request.Path)
+	}
+	return paths
+}
+
+func TestRecentRecords(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	serveRecorded(t, dbClient, []string{"/a", "/b", "/c", "/never"}, []string{"/a", "/b", "/c", "/a"})
+
+	paths := recentPaths(t, dbClient, 2)
+	testutil.Expect(t, len(paths), 2)
+	testutil.Expect(t, paths[0], "/a")
+	testutil.Expect(t, paths[1], "/c")
+
+	// records that weren't served are not returned
+	paths = recentPaths(t, dbClient, 10)
+	testutil.Expect(t, len(paths), 3)
+	testutil.Expect(t, paths[2], "/b")
+
+	records, err := dbClient.RecentRecords(3)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, records[0].ID, records[0].ConvertToPayload().Id())
+	testutil.Expect(t, strings.TrimSpace(records[0].Response.Body), `{'message': 'here'}`)
+	testutil.Expect(t, records[0].LastAccessedAt.After(records[1].LastAccessedAt), true)
+}
+
+func TestRecentRecordsSkipsRemovedRecords(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	serveRecorded(t, dbClient, []string{"/a", "/b", "/c"}, []string{"/a", "/b", "/c"})
+
+	records, err := dbClient.RecentRecords(1)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, dbClient.RequestCache.Delete([]byte(records[0].ID)), nil)
+
+	paths := recentPaths(t, dbClient, 2)
+	testutil.Expect(t, len(paths), 2)
+	testutil.Expect(t, paths[0], "/b")
+	testutil.Expect(t, paths[1], "/a")
+}
+
+func TestRecentRecordsAreLoadedFromMetadata(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	serveRecorded(t, dbClient, []string{"/a", "/b", "/c"}, []string{"/a", "/b"})
+
+	// restart with the same caches, records served since then come first
+	dbClient.recentAccess.flush(dbClient)
+	dbClient.recentAccess = newAccessIndex()
+	serveRecorded(t, dbClient, nil, []string{"/c"})
+
+	paths := recentPaths(t, dbClient, 10)
+	testutil.Expect(t, len(paths), 3)
+	testutil.Expect(t, paths[0], "/c")
+	testutil.Expect(t, paths[1], "/b")
+	testutil.Expect(t, paths[2], "/a")
+}
+
+// recentAccessEntries - number of access times stored in metadata cache
+func recentAccessEntries(t *testing.T, dbClient *Hoverfly) int {
+	keys, err := dbClient.MetadataCache.Keys()
+	testutil.Expect(t, err, nil)
+	count := 0
+	for _, k := range keys {
+		if strings.HasPrefix(k, recentAccessKeyPrefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestRecentAccessIsStoredInBatches(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	defer dbClient.MetadataCache.DeleteData()
+
+	serveRecorded(t, dbClient, []string{"/a", "/b"}, []string{"/a", "/b", "/a"})
+
+	// serving records doesn't write into metadata cache
+	testutil.Expect(t, recentAccessEntries(t, dbClient), 0)
+	testutil.Expect(t, len(recentPaths(t, dbClient, 10)), 2)
+
+	dbClient.recentAccess.flush(dbClient)
+	testutil.Expect(t, recentAccessEntries(t, dbClient), 2)
+
+	// batch is written in the background once the interval passes
+	dbClient.recentAccess.mu.Lock()
+	dbClient.recentAccess.flushInterval = 0
+	dbClient.recentAccess.mu.Unlock()
+	serveRecorded(t, dbClient, []string{"/c"}, []string{"/c"})
+	for i := 0; i < 100 && recentAccessEntries(t, dbClient) < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	testutil.Expect(t, recentAccessEntries(t, dbClient), 3)
+}
+
+func TestAllMetadataHandlerIncludesRecentAccess(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	defer dbClient.MetadataCache.DeleteData()
+
+	serveRecorded(t, dbClient, []string{"/a"}, []string{"/a"})
+	records, err := dbClient.RecentRecords(1)
+	testutil.Expect(t, err, nil)
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "/api/metadata", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var response storedMetadata
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &response), nil)
+	testutil.Expect(t, response.Data[recentAccessKeyPrefix+records[0].ID], records[0].LastAccessedAt.Format(time.RFC3339Nano))
+}
+
+func TestRecentRecordsHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	serveRecorded(t, dbClient, []string{"/a", "/b"}, []string{"/a", "/b"})
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "/api/records/recent?n=1", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var response struct {
+		Data []struct {
+			ID             string `json:"id"`
+			LastAccessedAt string `json:"lastAccessedAt"`
+			Request        struct {
+				Path string `json:"path"`
+			} `json:"request"`
+			Response struct {
+				Status int `json:"status"`
+			} `json:"response"`
+		} `json:"data"`
+	}
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &response), nil)
+	testutil.Expect(t, len(response.Data), 1)
+	testutil.Expect(t, response.Data[0].Request.Path, "/b")
+	testutil.Expect(t, response.Data[0].Response.Status, 200)
+	testutil.Refute(t, response.Data[0].LastAccessedAt, "")
+
+	for _, n := range []string{"0", "-1", "many"} {
+		req, err := http.NewRequest("GET", "/api/records/recent?n="+n, nil)
+		testutil.Expect(t, err, nil)
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		testutil.Expect(t, rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,96 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// recordOnceLocks - per request key locks, requests with different keys are recorded concurrently. Zero value is
+// ready to use.
+type recordOnceLocks struct {
+	mu sync.Mutex
+	m  map[string]*recordOnceLock
+}
+
+type recordOnceLock struct {
+	sync.Mutex
+	// users - requests holding or waiting for the lock, lock is removed once there are none
+	users int
+}
+
+// lock - locks given key, returned function unlocks it
+func (l *recordOnceLocks) lock(key string) func() {
+	l.mu.Lock()
+	if l.m == nil {
+		l.m = make(map[string]*recordOnceLock)
+	}
+	kl, ok := l.m[key]
+	if !ok {
+		kl = &recordOnceLock{}
+		l.m[key] = kl
+	}
+	kl.users++
+	l.mu.Unlock()
+
+	kl.Lock()
+	return func() {
+		kl.Unlock()
+		l.mu.Lock()
+		kl.users--
+		if kl.users == 0 {
+			delete(l.m, key)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// recordOnceResponse - simulates request, requests that aren't simulated yet are forwarded upstream and recorded
+// (record-or-playback). Concurrent requests that are not simulated yet wait for the first one to be recorded and are
+// then served from the record.
+func (d *Hoverfly) recordOnceResponse(req *http.Request) *http.Response {
+	cfg := d.requestConfig(req)
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return hoverflyError(req, err, "Failed to read request body", http.StatusInternalServerError, ErrorCodeInternal, errorFormat(req))
+		}
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	key := d.getRequestFingerprint(req, body)
+	unlock := d.recordOnceLocks.lock(key)
+	defer unlock()
+
+	// request that was recorded in the background can be served once it is stored
+	d.cacheWrites.wait(key)
+
+	response := d.getResponse(req)
+	if response.Header.Get(ErrorCodeHeader) != string(ErrorCodeCacheMiss) {
+		return response
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if cfg.RecordOnceAsync {
+		req = withAsyncCacheWrite(req)
+	}
+	response, err := d.captureRequest(req)
+	if err != nil {
+		return hoverflyError(req, err, "Could not record request", upstreamErrorStatus(err), errorCodeOf(err, ErrorCodeUpstreamUnreachable), errorFormat(req))
+	}
+
+	log.WithFields(log.Fields{
+		"mode":        SimulateMode,
+		"path":        req.URL.Path,
+		"rawQuery":    req.URL.RawQuery,
+		"method":      req.Method,
+		"destination": req.Host,
+	}).Info("request was not simulated yet, recorded")
+
+	return response
+}
@@ -0,0 +1,115 @@
+package hoverfly
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// DefaultRecordOnceQueueSize - how many recorded requests wait for background cache write when
+// RecordOnceQueueSize is not set
+const DefaultRecordOnceQueueSize = 1000
+
+type asyncCacheWriteKey struct{}
+
+// withAsyncCacheWrite - requests that are recorded with RecordOnceAsync are stored by background writer
+func withAsyncCacheWrite(req *http.Request) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), asyncCacheWriteKey{}, true))
+}
+
+func isAsyncCacheWrite(req *http.Request) bool {
+	async, _ := req.Context().Value(asyncCacheWriteKey{}).(bool)
+	return async
+}
+
+type cacheWrite struct {
+	key   string
+	write func()
+}
+
+// pendingCacheWrite - writes of a key that are queued or being written, done is closed once there are none
+type pendingCacheWrite struct {
+	count int
+	done  chan struct{}
+}
+
+// cacheWriter - stores recorded requests in the background, writes are queued in bounded queue. Writer is started
+// with the first write, zero value is ready to use.
+type cacheWriter struct {
+	mu      sync.Mutex
+	queue   chan cacheWrite
+	pending map[string]*pendingCacheWrite
+	// idle - signalled when there are no pending writes
+	idle *sync.Cond
+}
+
+// enqueue - queues write of given key, returns false without queueing when the queue is full
+func (w *cacheWriter) enqueue(size int, key string, write func()) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.queue == nil {
+		if size <= 0 {
+			size = DefaultRecordOnceQueueSize
+		}
+		w.queue = make(chan cacheWrite, size)
+		w.pending = make(map[string]*pendingCacheWrite)
+		w.idle = sync.NewCond(&w.mu)
+		go w.run(w.queue)
+	}
+
+	select {
+	case w.queue <- cacheWrite{key: key, write: write}:
+	default:
+		return false
+	}
+
+	p, ok := w.pending[key]
+	if !ok {
+		p = &pendingCacheWrite{done: make(chan struct{})}
+		w.pending[key] = p
+	}
+	p.count++
+	return true
+}
+
+func (w *cacheWriter) run(queue chan cacheWrite) {
+	for cw := range queue {
+		cw.write()
+
+		w.mu.Lock()
+		p := w.pending[cw.key]
+		p.count--
+		if p.count == 0 {
+			close(p.done)
+			delete(w.pending, cw.key)
+		}
+		if len(w.pending) == 0 {
+			w.idle.Broadcast()
+		}
+		w.mu.Unlock()
+	}
+}
+
+// wait - waits until queued writes of given key are stored
+func (w *cacheWriter) wait(key string) {
+	w.mu.Lock()
+	p, ok := w.pending[key]
+	w.mu.Unlock()
+	if ok {
+		<-p.done
+	}
+}
+
+// flush - waits until all queued writes are stored
+func (w *cacheWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.pending) > 0 {
+		w.idle.Wait()
+	}
+}
+
+// FlushCacheWrites - waits until requests that were recorded with RecordOnceAsync are stored in the cache
+func (d *Hoverfly) FlushCacheWrites() {
+	d.cacheWrites.flush()
+}
@@ -0,0 +1,155 @@
+package hoverfly
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/cache"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// blockingCache - request cache which writes wait until release is closed
+type blockingCache struct {
+	cache.Cache
+	release chan struct{}
+}
+
+func (c *blockingCache) Set(key, value []byte) error {
+	<-c.release
+	return c.Cache.Set(key, value)
+}
+
+func TestRecordOnceAsyncReturnsBeforeRecordIsStored(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	var hits int32
+	upstream := countingUpstream(&hits)
+	defer upstream.Close()
+
+	blocking := &blockingCache{Cache: dbClient.RequestCache, release: make(chan struct{})}
+	dbClient.RequestCache = blocking
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.SetRecordOnce(true)
+	dbClient.Cfg.RecordOnceAsync = true
+
+	status, body := recordOnceGet(t, dbClient, upstream.URL+"/items")
+	testutil.Expect(t, status, http.StatusOK)
+	testutil.Expect(t, body, "hit 1")
+
+	count, err := blocking.Count()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 0)
+
+	close(blocking.release)
+	dbClient.FlushCacheWrites()
+
+	count, err = blocking.Count()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+
+	// served from the record
+	status, body = recordOnceGet(t, dbClient, upstream.URL+"/items")
+	testutil.Expect(t, status, http.StatusOK)
+	testutil.Expect(t, body, "hit 1")
+	testutil.Expect(t, atomic.LoadInt32(&hits), int32(1))
+}
+
+func TestRecordOnceAsyncConcurrentRequestsRecordedOnce(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	var hits int32
+	upstream := countingUpstream(&hits)
+	defer upstream.Close()
+
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.SetRecordOnce(true)
+	dbClient.Cfg.RecordOnceAsync = true
+
+	bodies := make([]string, 10)
+	var wg sync.WaitGroup
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, bodies[i] = recordOnceGet(t, dbClient, upstream.URL+"/items")
+		}(i)
+	}
+	wg.Wait()
+	dbClient.FlushCacheWrites()
+
+	for _, body := range bodies {
+		testutil.Expect(t, body, "hit 1")
+	}
+	testutil.Expect(t, atomic.LoadInt32(&hits), int32(1))
+}
+
+func TestCacheWriterQueueFull(t *testing.T) {
+	var w cacheWriter
+	release := make(chan struct{})
+	var written int32
+	write := func() {
+		<-release
+		atomic.AddInt32(&written, 1)
+	}
+
+	// the first write is taken by the writer, the second fills the queue
+	testutil.Expect(t, w.enqueue(1, "a", write), true)
+	for i := 0; i < 100; i++ {
+		if w.enqueue(1, "b", write) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	testutil.Expect(t, w.enqueue(1, "c", write), false)
+
+	close(release)
+	w.wait("b")
+	w.flush()
+	testutil.Expect(t, atomic.LoadInt32(&written), int32(2))
+
+	// pending write of the key that wasn't queued isn't waited for
+	w.wait("c")
+}
+
+func TestRecordOnceAsyncStoresSynchronouslyWhenQueueIsFull(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	var hits int32
+	upstream := countingUpstream(&hits)
+	defer upstream.Close()
+
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.SetRecordOnce(true)
+	dbClient.Cfg.RecordOnceAsync = true
+	dbClient.Cfg.RecordOnceQueueSize = 1
+
+	// writer is busy and the queue is full
+	release := make(chan struct{})
+	testutil.Expect(t, dbClient.cacheWrites.enqueue(1, "a", func() { <-release }), true)
+	for i := 0; i < 100; i++ {
+		if dbClient.cacheWrites.enqueue(1, "b", func() {}) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	defer close(release)
+
+	_, body := recordOnceGet(t, dbClient, upstream.URL+"/items")
+	testutil.Expect(t, body, "hit 1")
+
+	count, err := dbClient.RequestCache.Count()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+}
@@ -0,0 +1,112 @@
+package hoverfly
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// countingUpstream - responds with number of requests it received so far
+func countingUpstream(hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(hits, 1)
+		// gives concurrent requests a chance to arrive while the first one is recorded
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprintf(w, "hit %d", n)
+	}))
+}
+
+func recordOnceGet(t *testing.T, d *Hoverfly, target string) (int, string) {
+	req, err := http.NewRequest("GET", target, nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := d.processRequest(req)
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	return resp.StatusCode, string(body)
+}
+
+func TestRecordOnceRecordsMissingRequest(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	var hits int32
+	upstream := countingUpstream(&hits)
+	defer upstream.Close()
+
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.SetRecordOnce(true)
+
+	status, body := recordOnceGet(t, dbClient, upstream.URL+"/items")
+	testutil.Expect(t, status, http.StatusOK)
+	testutil.Expect(t, body, "hit 1")
+
+	count, err := dbClient.RequestCache.Count()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+
+	// served from the record
+	status, body = recordOnceGet(t, dbClient, upstream.URL+"/items")
+	testutil.Expect(t, status, http.StatusOK)
+	testutil.Expect(t, body, "hit 1")
+	testutil.Expect(t, atomic.LoadInt32(&hits), int32(1))
+}
+
+func TestRecordOnceConcurrentRequestsRecordedOnce(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	var hits int32
+	upstream := countingUpstream(&hits)
+	defer upstream.Close()
+
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.SetRecordOnce(true)
+
+	bodies := make([]string, 10)
+	var wg sync.WaitGroup
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, bodies[i] = recordOnceGet(t, dbClient, upstream.URL+"/concurrent")
+		}(i)
+	}
+	wg.Wait()
+
+	testutil.Expect(t, atomic.LoadInt32(&hits), int32(1))
+	for _, body := range bodies {
+		testutil.Expect(t, body, "hit 1")
+	}
+
+	count, err := dbClient.RequestCache.Count()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+
+	testutil.Expect(t, len(dbClient.recordOnceLocks.m), 0)
+}
+
+func TestRecordOnceDisabledReturnsCacheMiss(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	var hits int32
+	upstream := countingUpstream(&hits)
+	defer upstream.Close()
+
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.SetMode(SimulateMode)
+
+	status, _ := recordOnceGet(t, dbClient, upstream.URL+"/items")
+	testutil.Expect(t, status, http.StatusPreconditionFailed)
+	testutil.Expect(t, atomic.LoadInt32(&hits), int32(0))
+}
@@ -0,0 +1,150 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// RecordsSearch - selects records by request body, either by substring or by value at JSONPath. Value is compared
+// as JSON, records that have anything at BodyJSONPath match when it is not given.
+type RecordsSearch struct {
+	BodyContains string          `json:"bodyContains"`
+	BodyJSONPath string          `json:"bodyJSONPath"`
+	Value        json.RawMessage `json:"value"`
+
+	path  []interface{}
+	value interface{}
+}
+
+// compile - validates search and parses JSONPath and value
+func (s *RecordsSearch) compile() error {
+	if s.BodyContains == "" && s.BodyJSONPath == "" {
+		return errors.New("either 'bodyContains' or 'bodyJSONPath' should be set")
+	}
+	if s.BodyJSONPath == "" {
+		if len(s.Value) > 0 {
+			return errors.New("'value' can only be used with 'bodyJSONPath'")
+		}
+		return nil
+	}
+
+	path, err := parseJSONPath(s.BodyJSONPath)
+	if err != nil {
+		return err
+	}
+	s.path = path
+
+	if len(s.Value) > 0 {
+		if err := json.Unmarshal(s.Value, &s.value); err != nil {
+			return fmt.Errorf("invalid 'value': %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// matches - checks request body of the record
+func (s *RecordsSearch) matches(payload *models.Payload) bool {
+	body := payload.Request.Body
+	if s.BodyContains != "" && !strings.Contains(body, s.BodyContains) {
+		return false
+	}
+	if s.path == nil {
+		return true
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		return false
+	}
+	found, ok := evalJSONPath(doc, s.path)
+	if !ok {
+		return false
+	}
+	return len(s.Value) == 0 || reflect.DeepEqual(found, s.value)
+}
+
+// SearchRecords - writes records that match the search as newline delimited JSON. Cache entries are read one by
+// one, so neither the cache nor matching records are kept in memory.
+func (d *Hoverfly) SearchRecords(w io.Writer, search RecordsSearch) (int, error) {
+	if err := search.compile(); err != nil {
+		return 0, err
+	}
+
+	keys, err := d.RequestCache.Keys()
+	if err != nil {
+		return 0, err
+	}
+
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+
+	count := 0
+	for _, key := range keys {
+		v, err := d.RequestCache.Get([]byte(key))
+		// removed since keys were listed
+		if err != nil {
+			continue
+		}
+
+		payload, err := models.NewPayloadFromBytes(v)
+		if err != nil {
+			return count, fmt.Errorf("failed to decode record '%s': %s", key, err.Error())
+		}
+		if !search.matches(payload) {
+			continue
+		}
+
+		if err := encoder.Encode(payload.ConvertToPayloadView()); err != nil {
+			return count, err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// SearchRecordsHandler - streams records with matching request body as newline delimited JSON, search is given in
+// request body, i.e. {"bodyJSONPath": "$.user.email", "value": "alice@example.com"}
+func (d *Hoverfly) SearchRecordsHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	var search RecordsSearch
+	if err := json.NewDecoder(req.Body).Decode(&search); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode search: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if err := search.compile(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	count, err := d.SearchRecords(w, search)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":   err.Error(),
+			"records": count,
+		}).Error("Failed to search records")
+		// status was already sent with the first record
+		if count == 0 {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"bodyContains": search.BodyContains,
+		"bodyJSONPath": search.BodyJSONPath,
+		"records":      count,
+	}).Debug("records searched")
+}
@@ -0,0 +1,97 @@
+package hoverfly
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func storeSearchablePayload(t *testing.T, d *Hoverfly, path, body string) {
+	payload := models.Payload{
+		Request:  models.RequestDetails{Method: "POST", Destination: "search.com", Path: path, Body: body},
+		Response: models.ResponseDetails{Status: 200, Body: "ok"},
+	}
+	bts, err := payload.Encode()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, d.RequestCache.Set([]byte(payload.Id()), bts), nil)
+}
+
+func searchRecords(t *testing.T, d *Hoverfly, search string) (*httptest.ResponseRecorder, []string) {
+	m := getBoneRouter(*d)
+
+	req, err := http.NewRequest("POST", "/api/records/search", strings.NewReader(search))
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+
+	var paths []string
+	if rec.Code != http.StatusOK {
+		return rec, paths
+	}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var view models.PayloadView
+		testutil.Expect(t, json.Unmarshal(scanner.Bytes(), &view), nil)
+		paths = append(paths, view.Request.Path)
+	}
+	return rec, paths
+}
+
+func TestSearchRecordsByJSONPath(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	storeSearchablePayload(t, dbClient, "/alice", `{"user": {"email": "alice@example.com", "age": 30}}`)
+	storeSearchablePayload(t, dbClient, "/bob", `{"user": {"email": "bob@example.com"}}`)
+	storeSearchablePayload(t, dbClient, "/text", `user=alice@example.com`)
+
+	rec, paths := searchRecords(t, dbClient, `{"bodyJSONPath": "$.user.email", "value": "alice@example.com"}`)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+	testutil.Expect(t, rec.Header().Get("Content-Type"), "application/x-ndjson")
+	testutil.Expect(t, len(paths), 1)
+	testutil.Expect(t, paths[0], "/alice")
+
+	_, paths = searchRecords(t, dbClient, `{"bodyJSONPath": "$.user.age", "value": 30}`)
+	testutil.Expect(t, len(paths), 1)
+	testutil.Expect(t, paths[0], "/alice")
+
+	// without value every record with the field matches
+	_, paths = searchRecords(t, dbClient, `{"bodyJSONPath": "$.user.email"}`)
+	testutil.Expect(t, len(paths), 2)
+}
+
+func TestSearchRecordsByBodySubstring(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	storeSearchablePayload(t, dbClient, "/alice", `{"user": {"email": "alice@example.com"}}`)
+	storeSearchablePayload(t, dbClient, "/bob", `{"user": {"email": "bob@example.com"}}`)
+	storeSearchablePayload(t, dbClient, "/text", `user=alice@example.com`)
+
+	rec, paths := searchRecords(t, dbClient, `{"bodyContains": "alice@"}`)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+	testutil.Expect(t, len(paths), 2)
+
+	rec, paths = searchRecords(t, dbClient, `{"bodyContains": "nobody"}`)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+	testutil.Expect(t, len(paths), 0)
+}
+
+func TestSearchRecordsInvalidSearch(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	for _, search := range []string{`{}`, `{"bodyJSONPath": "user.email"}`, `{"bodyContains": "a", "value": 1}`, `not json`} {
+		rec, _ := searchRecords(t, dbClient, search)
+		testutil.Expect(t, rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,121 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// RedactedValue - value that redacted response body fields are replaced with
+const RedactedValue = "REDACTED"
+
+// ExportRedacted - writes all records in the same format as 'GET /api/records', values matched by given JSONPath
+// expressions are replaced with RedactedValue in JSON response bodies. Records in the cache are not modified.
+func (d *Hoverfly) ExportRedacted(w io.Writer, redactPaths []string) error {
+	paths := make([][]interface{}, 0, len(redactPaths))
+	for _, p := range redactPaths {
+		steps, err := parseJSONPath(p)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, steps)
+	}
+
+	_, err := d.writeRecords(w, func(payload *models.Payload) (*models.PayloadView, error) {
+		redactResponseBody(&payload.Response, paths)
+		return payload.ConvertToPayloadView(), nil
+	})
+	return err
+}
+
+// redactResponseBody - replaces matched values in JSON body, bodies that aren't JSON (or are encoded) are left as
+// they are
+func redactResponseBody(response *models.ResponseDetails, paths [][]interface{}) {
+	if len(paths) == 0 || !isJSONContentType(response.Headers) || len(response.Headers["Content-Encoding"]) > 0 {
+		return
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(response.Body))
+	// numbers are written back as they were captured
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("response body is not valid JSON, it won't be redacted")
+		return
+	}
+
+	redacted := false
+	for _, steps := range paths {
+		var ok bool
+		if doc, ok = redactJSONPath(doc, steps); ok {
+			redacted = true
+		}
+	}
+	if !redacted {
+		return
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return
+	}
+	response.Body = strings.TrimSuffix(buf.String(), "\n")
+
+	// body length changed
+	delete(response.Headers, "Content-Length")
+}
+
+// redactJSONPath - replaces value selected by given steps with RedactedValue, returns updated document and whether
+// the value was found
+func redactJSONPath(doc interface{}, steps []interface{}) (interface{}, bool) {
+	if len(steps) == 0 {
+		return RedactedValue, true
+	}
+
+	switch s := steps[0].(type) {
+	case string:
+		object, ok := doc.(map[string]interface{})
+		if !ok {
+			return doc, false
+		}
+		value, ok := object[s]
+		if !ok {
+			return doc, false
+		}
+		object[s], ok = redactJSONPath(value, steps[1:])
+		return object, ok
+	case int:
+		array, ok := doc.([]interface{})
+		if !ok || s >= len(array) {
+			return doc, false
+		}
+		array[s], ok = redactJSONPath(array[s], steps[1:])
+		return array, ok
+	}
+	return doc, false
+}
+
+// isJSONContentType - whether Content-Type header is application/json or +json media type
+func isJSONContentType(headers map[string][]string) bool {
+	for name, values := range headers {
+		if !strings.EqualFold(name, "Content-Type") || len(values) == 0 {
+			continue
+		}
+		mediaType, _, err := mime.ParseMediaType(values[0])
+		if err != nil {
+			return false
+		}
+		return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// payloadWithBody - encoded payload with given response body and content type
+func payloadWithBody(t *testing.T, path, contentType, body string) []byte {
+	payload := models.Payload{
+		Request: models.RequestDetails{Method: "GET", Scheme: "http", Destination: "example.com", Path: path},
+		Response: models.ResponseDetails{
+			Status:  200,
+			Body:    body,
+			Headers: map[string][]string{"Content-Type": {contentType}},
+		},
+	}
+	bts, err := payload.Encode()
+	testutil.Expect(t, err, nil)
+	return bts
+}
+
+func TestExportRedacted(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	original := `{"user":{"name":"john","token":"secret"},"cards":[{"number":"4111"}],"count":12345678901234567890}`
+	dbClient.RequestCache.Set([]byte("one"), payloadWithBody(t, "/one", "application/json; charset=utf-8", original))
+	dbClient.RequestCache.Set([]byte("two"), payloadWithBody(t, "/two", "text/plain", `{"user":{"token":"secret"}}`))
+
+	var buf bytes.Buffer
+	err := dbClient.ExportRedacted(&buf, []string{"$.user.token", "$.cards[0].number", "$.missing"})
+	testutil.Expect(t, err, nil)
+
+	var data models.PayloadViewData
+	testutil.Expect(t, json.Unmarshal(buf.Bytes(), &data), nil)
+	testutil.Expect(t, len(data.Data), 2)
+	testutil.Expect(t, data.Data[0].Response.Body, `{"cards":[{"number":"REDACTED"}],"count":12345678901234567890,"user":{"name":"john","token":"REDACTED"}}`)
+	// only JSON bodies are redacted
+	testutil.Expect(t, data.Data[1].Response.Body, `{"user":{"token":"secret"}}`)
+
+	// cache is not modified
+	bts, err := dbClient.RequestCache.Get([]byte("one"))
+	testutil.Expect(t, err, nil)
+	payload, err := models.NewPayloadFromBytes(bts)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, payload.Response.Body, original)
+}
+
+func TestExportRedactedInvalidPath(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	var buf bytes.Buffer
+	err := dbClient.ExportRedacted(&buf, []string{"user.token"})
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, buf.Len(), 0)
+}
+
+func TestRedactResponseBodyLeavesInvalidJSON(t *testing.T) {
+	response := models.ResponseDetails{
+		Body:    "not json",
+		Headers: map[string][]string{"Content-Type": {"application/json"}},
+	}
+	steps, err := parseJSONPath("$.token")
+	testutil.Expect(t, err, nil)
+
+	redactResponseBody(&response, [][]interface{}{steps})
+	testutil.Expect(t, response.Body, "not json")
+}
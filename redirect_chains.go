@@ -0,0 +1,138 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rusenask/goproxy"
+)
+
+// RedirectHop - redirect response of a redirect chain
+type RedirectHop struct {
+	StatusCode int    `json:"statusCode"`
+	Location   string `json:"location"`
+}
+
+// RedirectChainRule - simulate mode responds to request with given host and path with the first hop, requests to
+// Location of every hop get the next hop and request to Location of the last hop gets the response recorded for
+// the initial request
+type RedirectChainRule struct {
+	Host string        `json:"host"`
+	Path string        `json:"path"`
+	Hops []RedirectHop `json:"hops"`
+}
+
+// Validate - checks whether rule can be used
+func (r RedirectChainRule) Validate() error {
+	if r.Host == "" || r.Path == "" {
+		return fmt.Errorf("redirect chain should have host and path")
+	}
+	if len(r.Hops) == 0 {
+		return fmt.Errorf("redirect chain for '%s%s' has no hops", r.Host, r.Path)
+	}
+	for _, hop := range r.Hops {
+		switch hop.StatusCode {
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		default:
+			return fmt.Errorf("invalid redirect status code %d in chain for '%s%s'", hop.StatusCode, r.Host, r.Path)
+		}
+		if _, err := r.location(hop); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// location - parsed Location of the hop, locations without host are on the host of the rule
+func (r RedirectChainRule) location(hop RedirectHop) (*url.URL, error) {
+	u, err := url.Parse(hop.Location)
+	if err != nil || u.Path == "" {
+		return nil, fmt.Errorf("invalid redirect location '%s' in chain for '%s%s'", hop.Location, r.Host, r.Path)
+	}
+	if u.Host == "" {
+		u.Host = r.Host
+	}
+	return u, nil
+}
+
+// step - position of request in the chain, -1 is the initial request and i is Location of hop i
+func (r RedirectChainRule) step(req *http.Request) (int, bool) {
+	if req.Host == r.Host && req.URL.Path == r.Path {
+		return -1, true
+	}
+	for i, hop := range r.Hops {
+		u, err := r.location(hop)
+		if err != nil {
+			continue
+		}
+		if req.Host == u.Host && req.URL.Path == u.Path && (u.RawQuery == "" || req.URL.RawQuery == u.RawQuery) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// LoadRedirectChains - reads redirect chains from JSON file, i.e.:
+//
+//	[{"host": "example.com", "path": "/old", "hops": [{"statusCode": 301, "location": "/older"},
+//	  {"statusCode": 302, "location": "http://new.example.com/new"}]}]
+func (c *Configuration) LoadRedirectChains(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var rules []RedirectChainRule
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return fmt.Errorf("failed to parse redirect chains file: %s", err.Error())
+	}
+
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+
+	c.RedirectChains = rules
+
+	return nil
+}
+
+// redirectChainResponse - returns redirect response when request is part of redirect chain. Request to the end of
+// the chain is pointed to the initial request so that recorded response is simulated for it.
+func (d *Hoverfly) redirectChainResponse(req *http.Request) *http.Response {
+	cfg := d.requestConfig(req)
+	for _, rule := range cfg.RedirectChains {
+		step, ok := rule.step(req)
+		if !ok {
+			continue
+		}
+
+		if step == len(rule.Hops)-1 {
+			req.Host = rule.Host
+			req.URL.Host = rule.Host
+			req.URL.Path = rule.Path
+			return nil
+		}
+
+		hop := rule.Hops[step+1]
+		log.WithFields(log.Fields{
+			"host":     req.Host,
+			"path":     req.URL.Path,
+			"status":   hop.StatusCode,
+			"location": hop.Location,
+			"hop":      step + 2,
+			"hops":     len(rule.Hops),
+		}).Debug("simulating redirect chain")
+
+		resp := goproxy.NewResponse(req, goproxy.ContentTypeText, hop.StatusCode, "")
+		resp.Header.Set("Location", hop.Location)
+		return resp
+	}
+	return nil
+}
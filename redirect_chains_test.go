@@ -0,0 +1,88 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestRedirectChainSimulated(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	req, err := http.NewRequest("GET", "http://example.com/old", nil)
+	testutil.Expect(t, err, nil)
+	dbClient.captureRequest(req)
+
+	dbClient.Cfg.RedirectChains = []RedirectChainRule{{
+		Host: "example.com",
+		Path: "/old",
+		Hops: []RedirectHop{
+			{StatusCode: http.StatusMovedPermanently, Location: "/step1"},
+			{StatusCode: http.StatusFound, Location: "http://other.com/step2"},
+		},
+	}}
+
+	steps := []struct {
+		url      string
+		status   int
+		location string
+	}{
+		{"http://example.com/old", http.StatusMovedPermanently, "/step1"},
+		{"http://example.com/step1", http.StatusFound, "http://other.com/step2"},
+		{"http://other.com/step2", http.StatusOK, ""},
+	}
+
+	for _, step := range steps {
+		req, err := http.NewRequest("GET", step.url, nil)
+		testutil.Expect(t, err, nil)
+
+		resp := dbClient.getResponse(req)
+		testutil.Expect(t, resp.StatusCode, step.status)
+		testutil.Expect(t, resp.Header.Get("Location"), step.location)
+	}
+
+	req, err = http.NewRequest("GET", "http://other.com/step2", nil)
+	testutil.Expect(t, err, nil)
+	body, err := ioutil.ReadAll(dbClient.getResponse(req).Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, strings.TrimSpace(string(body)), `{'message': 'here'}`)
+}
+
+func TestRedirectChainRuleValidate(t *testing.T) {
+	valid := RedirectChainRule{Host: "example.com", Path: "/old", Hops: []RedirectHop{{StatusCode: 307, Location: "/new"}}}
+	testutil.Expect(t, valid.Validate(), nil)
+
+	invalid := []RedirectChainRule{
+		{Path: "/old", Hops: valid.Hops},
+		{Host: "example.com", Path: "/old"},
+		{Host: "example.com", Path: "/old", Hops: []RedirectHop{{StatusCode: 200, Location: "/new"}}},
+		{Host: "example.com", Path: "/old", Hops: []RedirectHop{{StatusCode: 302, Location: ""}}},
+	}
+	for _, rule := range invalid {
+		testutil.Refute(t, rule.Validate(), nil)
+	}
+}
+
+func TestLoadRedirectChains(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hoverfly_redirect_chains")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "chains.json")
+	testutil.Expect(t, ioutil.WriteFile(path, []byte(`[{"host": "example.com", "path": "/old", "hops": [{"statusCode": 301, "location": "/new"}]}]`), 0644), nil)
+
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.LoadRedirectChains(path), nil)
+	testutil.Expect(t, len(cfg.RedirectChains), 1)
+	testutil.Expect(t, cfg.RedirectChains[0].Hops[0].Location, "/new")
+
+	testutil.Expect(t, ioutil.WriteFile(path, []byte(`[{"host": "example.com", "path": "/old", "hops": [{"statusCode": 200, "location": "/new"}]}]`), 0644), nil)
+	testutil.Refute(t, cfg.LoadRedirectChains(path), nil)
+}
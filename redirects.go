@@ -0,0 +1,57 @@
+package hoverfly
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// defaultMaxRedirects - same limit as Go HTTP client applies by default
+const defaultMaxRedirects = 10
+
+// ErrTooManyRedirects - upstream kept redirecting after MaxRedirects hops, URL is the last one reached
+type ErrTooManyRedirects struct {
+	MaxRedirects int
+	URL          string
+}
+
+func (e *ErrTooManyRedirects) Error() string {
+	return fmt.Sprintf("stopped after %d redirects, last URL reached: %s", e.MaxRedirects, e.URL)
+}
+
+// CheckRedirect - redirect policy for upstream requests, up to MaxRedirects hops are followed. Go HTTP client
+// default policy applies when MaxRedirects is not set.
+func (c *Configuration) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if c.MaxRedirects <= 0 {
+		if len(via) >= defaultMaxRedirects {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+
+	if len(via) > c.MaxRedirects {
+		return &ErrTooManyRedirects{MaxRedirects: c.MaxRedirects, URL: via[len(via)-1].URL.String()}
+	}
+	return nil
+}
+
+// tooManyRedirects - returns redirect error that HTTP client wrapped
+func tooManyRedirects(err error) (*ErrTooManyRedirects, bool) {
+	if ue, ok := err.(*url.Error); ok {
+		err = ue.Err
+	}
+	tooMany, ok := err.(*ErrTooManyRedirects)
+	return tooMany, ok
+}
+
+// upstreamErrorStatus - status of Hoverfly error response for failed upstream request
+func upstreamErrorStatus(err error) int {
+	switch errorCodeOf(err, ErrorCodeUnknown) {
+	case ErrorCodeTooManyRedirects:
+		return http.StatusBadGateway
+	case ErrorCodeMiddlewareTimeout:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusServiceUnavailable
+}
@@ -0,0 +1,77 @@
+package hoverfly
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// redirectChain - redirects /hop/N to /hop/N+1 until 'hops' redirects were made
+func redirectChain(hops int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+		if n < hops {
+			http.Redirect(w, r, fmt.Sprintf("/hop/%d", n+1), http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestCaptureFollowsMaxRedirects(t *testing.T) {
+	upstream := redirectChain(15)
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.MaxRedirects = 15
+	dbClient.HTTP = &http.Client{CheckRedirect: dbClient.Cfg.CheckRedirect}
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	req, err := http.NewRequest("GET", upstream.URL+"/hop/0", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+}
+
+func TestCaptureTooManyRedirects(t *testing.T) {
+	upstream := redirectChain(15)
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.MaxRedirects = 3
+	dbClient.HTTP = &http.Client{CheckRedirect: dbClient.Cfg.CheckRedirect}
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	req, err := http.NewRequest("GET", upstream.URL+"/hop/0", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusBadGateway)
+	testutil.Expect(t, resp.Header.Get(ErrorCodeHeader), string(ErrorCodeTooManyRedirects))
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, strings.Contains(string(body), "last URL reached: "+upstream.URL+"/hop/3"), true)
+}
+
+func TestCheckRedirectDefaultLimit(t *testing.T) {
+	cfg := InitSettings()
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	testutil.Expect(t, err, nil)
+
+	testutil.Expect(t, cfg.CheckRedirect(req, make([]*http.Request, 9)), nil)
+	testutil.Refute(t, cfg.CheckRedirect(req, make([]*http.Request, 10)), nil)
+}
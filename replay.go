@@ -0,0 +1,226 @@
+package hoverfly
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// DefaultReplayWorkers - how many recorded requests are replayed at the same time
+const DefaultReplayWorkers = 10
+
+// DefaultReplayMethods - methods of recorded requests that are replayed when ReplayOptions don't list any, other
+// methods aren't replayed by default as they can change state of the target
+var DefaultReplayMethods = []string{http.MethodGet}
+
+// ReplayOptions - which recorded requests are replayed
+type ReplayOptions struct {
+	// Methods - methods of replayed requests, DefaultReplayMethods when empty
+	Methods []string
+}
+
+// ReplayResult - recorded request together with recorded and live response, Diff holds mismatches between the two.
+// Live is empty and Error is set when live request failed. Match is set when live response has the same status and
+// body as the recorded one, header differences are listed in Diff but don't affect it.
+type ReplayResult struct {
+	Request  models.RequestDetailsView   `json:"request"`
+	Recorded models.ResponseDetailsView  `json:"recorded"`
+	Live     *models.ResponseDetailsView `json:"live,omitempty"`
+	Error    string                      `json:"error,omitempty"`
+	Diff     []Difference                `json:"diff"`
+	Match    bool                        `json:"match"`
+}
+
+// ReplayRequests - sends every recorded GET request to target (i.e. 'https://newhost') and compares live responses
+// with recorded ones, results are in capture order
+func (d *Hoverfly) ReplayRequests(target string) ([]ReplayResult, error) {
+	return d.ReplayRequestsContext(context.Background(), target)
+}
+
+// ReplayRequestsContext - same as ReplayRequests, replay stops when context is cancelled
+func (d *Hoverfly) ReplayRequestsContext(ctx context.Context, target string) ([]ReplayResult, error) {
+	return d.ReplayRequestsWithOptions(ctx, target, ReplayOptions{})
+}
+
+// ReplayRequestsWithOptions - same as ReplayRequestsContext, only requests with methods given in options are
+// replayed. Records are replayed in the order they were captured, imported records without capture time go last.
+func (d *Hoverfly) ReplayRequestsWithOptions(ctx context.Context, target string, options ReplayOptions) ([]ReplayResult, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil || targetURL.Host == "" || (targetURL.Scheme != "http" && targetURL.Scheme != "https") {
+		return nil, fmt.Errorf("invalid replay target '%s', expected i.e. 'https://newhost'", target)
+	}
+
+	methods := options.Methods
+	if len(methods) == 0 {
+		methods = DefaultReplayMethods
+	}
+	replayed := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		replayed[strings.ToUpper(method)] = true
+	}
+
+	keys, err := d.RequestCache.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	var payloads []*models.Payload
+	for _, key := range keys {
+		bts, err := d.RequestCache.Get([]byte(key))
+		// removed since keys were listed
+		if err != nil {
+			continue
+		}
+		payload, err := models.NewPayloadFromBytes(bts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode record '%s': %s", key, err.Error())
+		}
+		if !replayed[strings.ToUpper(payload.Request.Method)] {
+			continue
+		}
+		payloads = append(payloads, payload)
+	}
+	sort.SliceStable(payloads, func(i, j int) bool {
+		first, second := payloads[i].CreatedAt, payloads[j].CreatedAt
+		if first.IsZero() || second.IsZero() {
+			return !first.IsZero() && second.IsZero()
+		}
+		return first.Before(second)
+	})
+
+	workers := d.Cfg.ReplayWorkers
+	if workers <= 0 {
+		workers = DefaultReplayWorkers
+	}
+
+	results := make([]ReplayResult, len(payloads))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				results[index] = d.replay(ctx, targetURL, payloads[index])
+			}
+		}()
+	}
+
+	func() {
+		defer close(jobs)
+		for index := range payloads {
+			select {
+			case jobs <- index:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"target":   target,
+		"requests": len(results),
+	}).Info("recorded requests replayed")
+
+	return results, nil
+}
+
+// replay - sends recorded request to target, keeping its method, path, query, headers and body
+func (d *Hoverfly) replay(ctx context.Context, target *url.URL, payload *models.Payload) ReplayResult {
+	result := ReplayResult{
+		Request:  payload.Request.ConvertToRequestDetailsView(),
+		Recorded: payload.Response.ConvertToResponseDetailsView(),
+		Diff:     []Difference{},
+	}
+
+	recorded := payload.Response
+	recordedBody := []byte(recorded.Body)
+	recordedHeader := http.Header(cloneHeader(recorded.Headers))
+	if strings.EqualFold(recordedHeader.Get("Content-Encoding"), "gzip") {
+		if body, err := gunzip(recordedBody); err == nil {
+			recordedBody = body
+		}
+	}
+
+	live, liveBody, err := d.sendReplayRequest(ctx, target, payload.Request)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":  err.Error(),
+			"method": payload.Request.Method,
+			"path":   payload.Request.Path,
+		}).Warn("replayed request failed")
+		result.Error = err.Error()
+		return result
+	}
+
+	liveView := models.ResponseDetails{Status: live.StatusCode, Body: string(liveBody), Headers: live.Header}
+	view := liveView.ConvertToResponseDetailsView()
+	result.Live = &view
+
+	// only recorded headers are compared, body encoding is compared after decoding
+	liveHeader := http.Header{}
+	for name := range recordedHeader {
+		if values, ok := live.Header[http.CanonicalHeaderKey(name)]; ok {
+			liveHeader[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	for _, h := range []http.Header{recordedHeader, liveHeader} {
+		h.Del("Content-Encoding")
+		h.Del("Content-Length")
+	}
+
+	result.Diff = d.Cfg.compareResponses(recorded.Status, live.StatusCode, recordedHeader, liveHeader, recordedBody, liveBody)
+	result.Match = true
+	for _, diff := range result.Diff {
+		if diff.Field == "status" || diff.Field == "body" {
+			result.Match = false
+		}
+	}
+	return result
+}
+
+// sendReplayRequest - returns live response and its decoded body. Accept-Encoding isn't copied so that
+// transport decompresses gzip responses itself.
+func (d *Hoverfly) sendReplayRequest(ctx context.Context, target *url.URL, request models.RequestDetails) (*http.Response, []byte, error) {
+	u := url.URL{
+		Scheme:   target.Scheme,
+		Host:     target.Host,
+		Path:     request.Path,
+		RawQuery: request.Query,
+	}
+
+	req, err := http.NewRequest(request.Method, u.String(), bytes.NewReader([]byte(request.Body)))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header = cloneHeader(request.Headers)
+	req.Header.Del("Accept-Encoding")
+	req.Header.Del("Content-Length")
+
+	resp, err := d.HTTP.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
@@ -0,0 +1,227 @@
+package hoverfly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// replayUpstream - new version of service, '/changed' returns different body than the recorded one
+func replayUpstream() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Not-Recorded", "yes")
+		if r.URL.Path == "/changed" && r.URL.Query().Get("v") == "2" {
+			w.Write([]byte(`{"name":"new"}`))
+			return
+		}
+		w.Write([]byte(`{"name":"same"}`))
+	}))
+}
+
+func replayPayload(t *testing.T, path, query, body string) []byte {
+	return replayPayloadWith(t, "GET", path, query, body, time.Time{})
+}
+
+func replayPayloadWith(t *testing.T, method, path, query, body string, createdAt time.Time) []byte {
+	payload := models.Payload{
+		CreatedAt: createdAt,
+		Request:   models.RequestDetails{Method: method, Scheme: "http", Destination: "oldhost", Path: path, Query: query},
+		Response: models.ResponseDetails{
+			Status:  200,
+			Body:    body,
+			Headers: map[string][]string{"Content-Type": {"application/json"}},
+		},
+	}
+	bts, err := payload.Encode()
+	testutil.Expect(t, err, nil)
+	return bts
+}
+
+func TestReplayRequests(t *testing.T) {
+	upstream := replayUpstream()
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = &http.Client{}
+
+	dbClient.RequestCache.Set([]byte("a"), replayPayload(t, "/changed", "v=2", `{"name":"old"}`))
+	dbClient.RequestCache.Set([]byte("b"), replayPayload(t, "/unchanged", "", `{"name":"same"}`))
+
+	results, err := dbClient.ReplayRequests(upstream.URL)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(results), 2)
+
+	testutil.Expect(t, results[0].Request.Path, "/changed")
+	testutil.Expect(t, results[0].Live.Body, `{"name":"new"}`)
+	testutil.Expect(t, len(results[0].Diff), 1)
+	testutil.Expect(t, results[0].Diff[0].Field, "body")
+	testutil.Expect(t, results[0].Diff[0].Simulated, `{"name":"old"}`)
+
+	// headers that weren't recorded are not compared
+	testutil.Expect(t, results[1].Request.Path, "/unchanged")
+	testutil.Expect(t, len(results[1].Diff), 0)
+}
+
+func TestReplayRequestsUnreachableTarget(t *testing.T) {
+	upstream := replayUpstream()
+	upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = &http.Client{}
+
+	dbClient.RequestCache.Set([]byte("a"), replayPayload(t, "/changed", "", `{"name":"old"}`))
+
+	results, err := dbClient.ReplayRequests(upstream.URL)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(results), 1)
+	testutil.Refute(t, results[0].Error, "")
+	testutil.Expect(t, results[0].Live == nil, true)
+}
+
+func TestReplayRequestsCancelled(t *testing.T) {
+	upstream := replayUpstream()
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.ReplayWorkers = 1
+
+	dbClient.RequestCache.Set([]byte("a"), replayPayload(t, "/changed", "", `{"name":"old"}`))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := dbClient.ReplayRequestsContext(ctx, upstream.URL)
+	testutil.Expect(t, err, context.Canceled)
+}
+
+func TestReplayRequestsInvalidTarget(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	for _, target := range []string{"", "newhost", "ftp://newhost"} {
+		_, err := dbClient.ReplayRequests(target)
+		testutil.Refute(t, err, nil)
+	}
+}
+
+func TestReplayHandler(t *testing.T) {
+	upstream := replayUpstream()
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = &http.Client{}
+	m := getBoneRouter(*dbClient)
+
+	dbClient.RequestCache.Set([]byte("a"), replayPayload(t, "/changed", "v=2", `{"name":"old"}`))
+
+	body, _ := json.Marshal(replayRequest{Target: upstream.URL})
+	req, err := http.NewRequest("POST", "/api/replay", bytes.NewReader(body))
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var response replayResponse
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &response), nil)
+	testutil.Expect(t, len(response.Data), 1)
+	testutil.Expect(t, len(response.Data[0].Diff), 1)
+
+	req, err = http.NewRequest("POST", "/api/replay", bytes.NewBufferString(`{}`))
+	testutil.Expect(t, err, nil)
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusBadRequest)
+}
+
+func TestReplayRequestsSkipsNonIdempotentMethods(t *testing.T) {
+	upstream := replayUpstream()
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = &http.Client{}
+
+	dbClient.RequestCache.Set([]byte("a"), replayPayloadWith(t, "POST", "/orders", "", `{"name":"same"}`, time.Time{}))
+	dbClient.RequestCache.Set([]byte("b"), replayPayloadWith(t, "GET", "/orders", "", `{"name":"same"}`, time.Time{}))
+	dbClient.RequestCache.Set([]byte("c"), replayPayloadWith(t, "DELETE", "/orders", "", `{"name":"same"}`, time.Time{}))
+
+	results, err := dbClient.ReplayRequests(upstream.URL)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(results), 1)
+	testutil.Expect(t, results[0].Request.Method, "GET")
+	testutil.Expect(t, results[0].Match, true)
+
+	results, err = dbClient.ReplayRequestsWithOptions(context.Background(), upstream.URL, ReplayOptions{Methods: []string{"get", "post"}})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(results), 2)
+}
+
+func TestReplayRequestsInCaptureOrder(t *testing.T) {
+	upstream := replayUpstream()
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = &http.Client{}
+
+	captured := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	dbClient.RequestCache.Set([]byte("a"), replayPayloadWith(t, "GET", "/imported", "", `{"name":"same"}`, time.Time{}))
+	dbClient.RequestCache.Set([]byte("b"), replayPayloadWith(t, "GET", "/second", "", `{"name":"same"}`, captured.Add(time.Minute)))
+	dbClient.RequestCache.Set([]byte("c"), replayPayloadWith(t, "GET", "/first", "", `{"name":"same"}`, captured))
+
+	results, err := dbClient.ReplayRequests(upstream.URL)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(results), 3)
+	testutil.Expect(t, results[0].Request.Path, "/first")
+	testutil.Expect(t, results[1].Request.Path, "/second")
+	testutil.Expect(t, results[2].Request.Path, "/imported")
+}
+
+func TestReplayHandlerReport(t *testing.T) {
+	upstream := replayUpstream()
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = &http.Client{}
+	m := getBoneRouter(*dbClient)
+
+	dbClient.RequestCache.Set([]byte("a"), replayPayload(t, "/changed", "v=2", `{"name":"old"}`))
+	// JSON bodies are compared by value
+	dbClient.RequestCache.Set([]byte("b"), replayPayload(t, "/unchanged", "", `{ "name": "same" }`))
+	dbClient.RequestCache.Set([]byte("c"), replayPayloadWith(t, "POST", "/orders", "", `{"name":"same"}`, time.Time{}))
+
+	req, err := http.NewRequest("POST", "/api/replay", bytes.NewBufferString(`{"targetBaseURL": "`+upstream.URL+`"}`))
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var response replayResponse
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &response), nil)
+	testutil.Expect(t, len(response.Data), 2)
+	testutil.Expect(t, response.Matches, 1)
+	testutil.Expect(t, response.Mismatches, 1)
+	testutil.Expect(t, response.Errors, 0)
+}
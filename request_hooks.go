@@ -0,0 +1,76 @@
+package hoverfly
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// HookPoint - point of request processing where hooks registered with RegisterHook run
+type HookPoint int
+
+// Hook points, response is nil before capture and before simulate lookup
+const (
+	HookBeforeCapture HookPoint = iota
+	HookAfterCapture
+	HookBeforeSimulate
+	HookAfterSimulate
+)
+
+func (p HookPoint) String() string {
+	switch p {
+	case HookBeforeCapture:
+		return "beforeCapture"
+	case HookAfterCapture:
+		return "afterCapture"
+	case HookBeforeSimulate:
+		return "beforeSimulate"
+	case HookAfterSimulate:
+		return "afterSimulate"
+	}
+	return fmt.Sprintf("HookPoint(%d)", int(p))
+}
+
+// HookFunc - callback that can change request or response in place, error aborts request with 500
+type HookFunc func(req *http.Request, resp *http.Response) error
+
+// requestHooks - hooks by hook point in registration order
+type requestHooks struct {
+	mu    sync.RWMutex
+	hooks map[HookPoint][]HookFunc
+}
+
+func newRequestHooks() *requestHooks {
+	return &requestHooks{hooks: make(map[HookPoint][]HookFunc)}
+}
+
+// RegisterHook - adds hook that runs at given point of every proxied request, hooks of the same point run in the
+// order they were registered and the first one that fails stops the rest
+func (d *Hoverfly) RegisterHook(point HookPoint, fn HookFunc) {
+	d.requestHooks.mu.Lock()
+	d.requestHooks.hooks[point] = append(d.requestHooks.hooks[point], fn)
+	d.requestHooks.mu.Unlock()
+}
+
+// runHooks - runs hooks of given point, error names the point that failed
+func (d *Hoverfly) runHooks(point HookPoint, req *http.Request, resp *http.Response) error {
+	if d.requestHooks == nil {
+		return nil
+	}
+
+	d.requestHooks.mu.RLock()
+	hooks := d.requestHooks.hooks[point]
+	d.requestHooks.mu.RUnlock()
+
+	for _, fn := range hooks {
+		if err := fn(req, resp); err != nil {
+			return fmt.Errorf("%s hook failed: %s", point, err.Error())
+		}
+	}
+	return nil
+}
+
+// hookError - response for request that was aborted by a failed hook
+func hookError(req *http.Request, err error) *http.Response {
+	return hoverflyError(req, err, "Request hook failed", http.StatusInternalServerError, ErrorCodeInternal, errorFormat(req))
+}
@@ -0,0 +1,81 @@
+package hoverfly
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestAfterCaptureHookModifiesResponseHeaders(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	dbClient.RegisterHook(HookAfterCapture, func(req *http.Request, resp *http.Response) error {
+		resp.Header.Set("X-Hooked", req.URL.Path)
+		return nil
+	})
+
+	req, err := http.NewRequest("GET", "http://example.com/hooked", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, resp.Header.Get("X-Hooked"), "/hooked")
+}
+
+func TestFailingHookAbortsRequest(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	lookedUp := false
+	dbClient.RegisterHook(HookBeforeSimulate, func(req *http.Request, resp *http.Response) error {
+		return errors.New("not allowed")
+	})
+	dbClient.RegisterHook(HookAfterSimulate, func(req *http.Request, resp *http.Response) error {
+		lookedUp = true
+		return nil
+	})
+
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusInternalServerError)
+	testutil.Expect(t, resp.Header.Get(ErrorCodeHeader), string(ErrorCodeInternal))
+	testutil.Expect(t, lookedUp, false)
+}
+
+func TestHooksRunInRegistrationOrder(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	var calls []string
+	hook := func(name string) HookFunc {
+		return func(req *http.Request, resp *http.Response) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	dbClient.RegisterHook(HookAfterSimulate, hook("after"))
+	dbClient.RegisterHook(HookBeforeSimulate, hook("first"))
+	dbClient.RegisterHook(HookBeforeSimulate, hook("second"))
+	dbClient.RegisterHook(HookBeforeCapture, hook("capture"))
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	req, err := http.NewRequest("GET", "http://example.com/", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+	testutil.Expect(t, len(calls), 3)
+	testutil.Expect(t, calls[0], "first")
+	testutil.Expect(t, calls[1], "second")
+	testutil.Expect(t, calls[2], "after")
+}
@@ -0,0 +1,40 @@
+package hoverfly
+
+import (
+	"net/http"
+
+	"github.com/pborman/uuid"
+)
+
+// RequestIDHeader - header that identifies request across services
+const RequestIDHeader = "X-Request-ID"
+
+// assignRequestID - generates request ID for requests that don't have one when GenerateRequestID is set, generated
+// ID is added to the request so that it is forwarded upstream. Returned ID should be added to the response, it is
+// empty when neither propagation nor generation applies to the request.
+func (c *Configuration) assignRequestID(req *http.Request) string {
+	if id := req.Header.Get(RequestIDHeader); id != "" {
+		if c.PropagateRequestID {
+			return id
+		}
+		return ""
+	}
+	if !c.GenerateRequestID {
+		return ""
+	}
+
+	id := uuid.New()
+	req.Header.Set(RequestIDHeader, id)
+	return id
+}
+
+// setResponseRequestID - adds request ID to response, ID that response already carries is replaced
+func setResponseRequestID(resp *http.Response, id string) {
+	if id == "" || resp == nil {
+		return
+	}
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+	resp.Header.Set(RequestIDHeader, id)
+}
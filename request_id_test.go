@@ -0,0 +1,122 @@
+package hoverfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func requestIDUpstream() (*httptest.Server, *string) {
+	received := new(string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*received = r.Header.Get(RequestIDHeader)
+		w.Write([]byte("ok"))
+	}))
+	return server, received
+}
+
+func requestIDProxyGet(t *testing.T, d *Hoverfly, target, id string) *http.Response {
+	d.UpdateProxy()
+	proxy := httptest.NewServer(d.Proxy)
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	testutil.Expect(t, err, nil)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	req, err := http.NewRequest("GET", target, nil)
+	testutil.Expect(t, err, nil)
+	if id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	resp, err := client.Do(req)
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	return resp
+}
+
+func TestPropagateRequestIDInCaptureMode(t *testing.T) {
+	upstream, received := requestIDUpstream()
+	defer upstream.Close()
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = http.DefaultClient
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	dbClient.Cfg.PropagateRequestID = true
+
+	resp := requestIDProxyGet(t, dbClient, upstream.URL, "abc-123")
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, *received, "abc-123")
+	testutil.Expect(t, resp.Header.Get(RequestIDHeader), "abc-123")
+}
+
+func TestPropagateRequestIDInSimulateMode(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.PropagateRequestID = true
+
+	// request isn't recorded, error response carries the ID too
+	resp := requestIDProxyGet(t, dbClient, "http://missing.com/", "abc-123")
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+	testutil.Expect(t, resp.Header.Get(RequestIDHeader), "abc-123")
+}
+
+func TestGenerateRequestID(t *testing.T) {
+	upstream, received := requestIDUpstream()
+	defer upstream.Close()
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = http.DefaultClient
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	dbClient.Cfg.GenerateRequestID = true
+
+	resp := requestIDProxyGet(t, dbClient, upstream.URL, "")
+	id := resp.Header.Get(RequestIDHeader)
+	testutil.Expect(t, len(id), 36)
+	testutil.Expect(t, *received, id)
+
+	events, err := dbClient.GetTimeline(time.Time{}, time.Time{})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(events) > 0, true)
+	testutil.Expect(t, events[len(events)-1].RequestID, id)
+}
+
+func TestGenerateRequestIDWithoutPropagationKeepsIncomingID(t *testing.T) {
+	upstream, received := requestIDUpstream()
+	defer upstream.Close()
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.HTTP = http.DefaultClient
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	dbClient.Cfg.GenerateRequestID = true
+
+	resp := requestIDProxyGet(t, dbClient, upstream.URL, "abc-123")
+	testutil.Expect(t, *received, "abc-123")
+	testutil.Expect(t, resp.Header.Get(RequestIDHeader), "")
+}
+
+func TestRequestIDDisabled(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.SetMode(SimulateMode)
+
+	resp := requestIDProxyGet(t, dbClient, "http://missing.com/", "")
+	testutil.Expect(t, resp.Header.Get(RequestIDHeader), "")
+	resp = requestIDProxyGet(t, dbClient, "http://missing.com/", "abc-123")
+	testutil.Expect(t, resp.Header.Get(RequestIDHeader), "")
+}
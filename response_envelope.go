@@ -0,0 +1,82 @@
+package hoverfly
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// EnvelopeConfig - envelope that simulated JSON response bodies are wrapped in, Template is text/template where
+// {{.Body}} is the original body and {{.RequestID}} is X-Request-ID of the request, i.e.
+// '{"data": {{.Body}}, "requestId": "{{.RequestID}}"}'
+type EnvelopeConfig struct {
+	Template string
+}
+
+// envelopeData - values available to envelope template
+type envelopeData struct {
+	Body      string
+	RequestID string
+}
+
+// Validate - checks whether envelope template can be parsed
+func (e EnvelopeConfig) Validate() error {
+	if _, err := template.New("envelope").Parse(e.Template); err != nil {
+		return fmt.Errorf("invalid response envelope template: %s", err.Error())
+	}
+	return nil
+}
+
+// wrapInEnvelope - wraps body of simulated response with ResponseEnvelope when it has 'application/json' Content-Type,
+// Content-Length header is updated when it is set. Encoded (i.e. gzipped) bodies are left as they are, empty body is
+// wrapped as 'null'.
+func (c *Configuration) wrapInEnvelope(response *models.ResponseDetails, req *http.Request) {
+	if c == nil || c.ResponseEnvelope == nil {
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(responseHeader(response.Headers, "Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return
+	}
+	if encoding := responseHeader(response.Headers, "Content-Encoding"); encoding != "" && encoding != "identity" {
+		return
+	}
+
+	tmpl := responseTemplates.get(c.ResponseEnvelope.Template)
+	if tmpl == nil {
+		return
+	}
+
+	body := response.Body
+	if strings.TrimSpace(body) == "" {
+		body = "null"
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, envelopeData{Body: body, RequestID: req.Header.Get(RequestIDHeader)}); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"path":  req.URL.Path,
+		}).Warn("Failed to wrap response body in envelope, returning it unchanged")
+		return
+	}
+	response.Body = buf.String()
+
+	// headers can be shared with the stored payload
+	headers := make(map[string][]string, len(response.Headers))
+	for name, values := range response.Headers {
+		if strings.EqualFold(name, "Content-Length") {
+			values = []string{strconv.Itoa(buf.Len())}
+		}
+		headers[name] = values
+	}
+	response.Headers = headers
+}
@@ -0,0 +1,89 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+const testEnvelope = `{"data": {{.Body}}, "requestId": "{{.RequestID}}"}`
+
+func TestResponseEnvelopeWrapsSimulatedJSON(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	err := dbClient.ImportPayloads([]models.PayloadView{
+		{
+			Request: models.RequestDetailsView{Method: "GET", Destination: "api.example.com", Path: "/users"},
+			Response: models.ResponseDetailsView{Status: 200, Body: `[{"id": 1}]`, Headers: map[string][]string{
+				"Content-Type":   {"application/json; charset=utf-8"},
+				"Content-Length": {"11"},
+			}},
+		},
+		{
+			Request:  models.RequestDetailsView{Method: "GET", Destination: "api.example.com", Path: "/page"},
+			Response: models.ResponseDetailsView{Status: 200, Body: `<html></html>`, Headers: map[string][]string{"Content-Type": {"text/html"}}},
+		},
+		{
+			Request:  models.RequestDetailsView{Method: "GET", Destination: "api.example.com", Path: "/empty"},
+			Response: models.ResponseDetailsView{Status: 200, Headers: map[string][]string{"Content-Type": {"application/json"}}},
+		},
+	})
+	testutil.Expect(t, err, nil)
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.ResponseEnvelope = &EnvelopeConfig{Template: testEnvelope}
+
+	get := func(path string) (*http.Response, string) {
+		req, err := http.NewRequest("GET", "http://api.example.com"+path, nil)
+		testutil.Expect(t, err, nil)
+		req.Header.Set(RequestIDHeader, "req-1")
+		resp := dbClient.getResponse(req)
+		body, err := ioutil.ReadAll(resp.Body)
+		testutil.Expect(t, err, nil)
+		return resp, string(body)
+	}
+
+	resp, body := get("/users")
+	expected := `{"data": [{"id": 1}], "requestId": "req-1"}`
+	testutil.Expect(t, body, expected)
+	testutil.Expect(t, resp.ContentLength, int64(len(expected)))
+
+	_, body = get("/page")
+	testutil.Expect(t, body, `<html></html>`)
+
+	_, body = get("/empty")
+	testutil.Expect(t, body, `{"data": null, "requestId": "req-1"}`)
+
+	// stored records are not changed
+	dbClient.Cfg.ResponseEnvelope = nil
+	_, body = get("/users")
+	testutil.Expect(t, body, `[{"id": 1}]`)
+}
+
+func TestWrapInEnvelopeSkipsEncodedBodies(t *testing.T) {
+	cfg := &Configuration{ResponseEnvelope: &EnvelopeConfig{Template: testEnvelope}}
+	req, err := http.NewRequest("GET", "http://api.example.com/users", nil)
+	testutil.Expect(t, err, nil)
+
+	response := models.ResponseDetails{Body: "\x1f\x8b", Headers: map[string][]string{
+		"Content-Type":     {"application/json"},
+		"Content-Encoding": {"gzip"},
+	}}
+	cfg.wrapInEnvelope(&response, req)
+	testutil.Expect(t, response.Body, "\x1f\x8b")
+
+	// nil configuration doesn't wrap anything
+	var none *Configuration
+	response = models.ResponseDetails{Body: "{}", Headers: map[string][]string{"Content-Type": {"application/json"}}}
+	none.wrapInEnvelope(&response, req)
+	testutil.Expect(t, response.Body, "{}")
+}
+
+func TestEnvelopeConfigValidate(t *testing.T) {
+	testutil.Expect(t, EnvelopeConfig{Template: testEnvelope}.Validate(), nil)
+	testutil.Refute(t, EnvelopeConfig{Template: `{"data": {{.Body}`}.Validate(), nil)
+}
@@ -0,0 +1,123 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// PaddingRule - simulated responses to requests with given host and path (any path when it is empty) are padded
+// to TargetBytes with Fill character (null byte when it is empty). Number of added bytes is sent in PaddingHeader
+// when it is set, responses that are already TargetBytes or larger are left as they are.
+type PaddingRule struct {
+	Host          string `json:"host"`
+	Path          string `json:"path"`
+	TargetBytes   int    `json:"targetBytes"`
+	PaddingHeader string `json:"paddingHeader"`
+	Fill          string `json:"fill"`
+}
+
+// Validate - checks whether rule can be used
+func (r PaddingRule) Validate() error {
+	if r.Host == "" {
+		return fmt.Errorf("response padding should have host")
+	}
+	if r.TargetBytes <= 0 {
+		return fmt.Errorf("invalid target size %d of response padding for '%s%s'", r.TargetBytes, r.Host, r.Path)
+	}
+	if r.PaddingHeader != "" && !isHeaderName(r.PaddingHeader) {
+		return fmt.Errorf("invalid padding header '%s' of response padding for '%s%s'", r.PaddingHeader, r.Host, r.Path)
+	}
+	if len(r.Fill) > 1 {
+		return fmt.Errorf("fill of response padding for '%s%s' should be a single character", r.Host, r.Path)
+	}
+	return nil
+}
+
+func (r PaddingRule) matches(req *http.Request) bool {
+	return strings.EqualFold(req.Host, r.Host) && (r.Path == "" || req.URL.Path == r.Path)
+}
+
+// LoadResponsePadding - reads response padding rules from JSON file, i.e.:
+//
+//	[{"host": "cdn.example.com", "path": "/video", "targetBytes": 1048576, "paddingHeader": "X-Padding"}]
+func (c *Configuration) LoadResponsePadding(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var rules []PaddingRule
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return fmt.Errorf("failed to parse response padding file: %s", err.Error())
+	}
+
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+
+	c.ResponsePadding = rules
+
+	return nil
+}
+
+// padResponse - pads body of simulated response with the first matching padding rule, Content-Length header is
+// updated when it is set. Encoded (i.e. gzipped) bodies are left as they are.
+func (c *Configuration) padResponse(response *models.ResponseDetails, req *http.Request) {
+	if c == nil || len(c.ResponsePadding) == 0 {
+		return
+	}
+	if encoding := responseHeader(response.Headers, "Content-Encoding"); encoding != "" && encoding != "identity" {
+		return
+	}
+
+	for _, rule := range c.ResponsePadding {
+		if !rule.matches(req) {
+			continue
+		}
+
+		padding := rule.TargetBytes - len(response.Body)
+		if padding <= 0 {
+			return
+		}
+		fill := byte(0)
+		if rule.Fill != "" {
+			fill = rule.Fill[0]
+		}
+		response.Body += string(bytes.Repeat([]byte{fill}, padding))
+
+		// headers can be shared with the stored payload
+		headers := make(map[string][]string, len(response.Headers)+1)
+		for name, values := range response.Headers {
+			if strings.EqualFold(name, "Content-Length") {
+				values = []string{strconv.Itoa(len(response.Body))}
+			}
+			if rule.PaddingHeader != "" && strings.EqualFold(name, rule.PaddingHeader) {
+				continue
+			}
+			headers[name] = values
+		}
+		if rule.PaddingHeader != "" {
+			headers[rule.PaddingHeader] = []string{strconv.Itoa(padding)}
+		}
+		response.Headers = headers
+
+		log.WithFields(log.Fields{
+			"destination": req.Host,
+			"path":        req.URL.Path,
+			"padding":     padding,
+			"targetBytes": rule.TargetBytes,
+		}).Debug("response body padded")
+		return
+	}
+}
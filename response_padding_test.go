@@ -0,0 +1,109 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestResponsePaddingSimulated(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	payload := func(path, body string) models.PayloadView {
+		return models.PayloadView{
+			Request:  models.RequestDetailsView{Method: "GET", Destination: "cdn.example.com", Path: path},
+			Response: models.ResponseDetailsView{Status: 200, Body: body, Headers: map[string][]string{"Content-Length": {"5"}}},
+		}
+	}
+	err := dbClient.ImportPayloads([]models.PayloadView{payload("/small", "hello"), payload("/large", "hello world"), payload("/dots", "hello")})
+	testutil.Expect(t, err, nil)
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.ResponsePadding = []PaddingRule{
+		{Host: "cdn.example.com", Path: "/dots", TargetBytes: 8, Fill: "."},
+		{Host: "cdn.example.com", TargetBytes: 8, PaddingHeader: "X-Padding"},
+	}
+
+	get := func(path string) (*http.Response, string) {
+		req, err := http.NewRequest("GET", "http://cdn.example.com"+path, nil)
+		testutil.Expect(t, err, nil)
+		resp := dbClient.getResponse(req)
+		body, err := ioutil.ReadAll(resp.Body)
+		testutil.Expect(t, err, nil)
+		return resp, string(body)
+	}
+
+	resp, body := get("/small")
+	testutil.Expect(t, body, "hello\x00\x00\x00")
+	testutil.Expect(t, resp.ContentLength, int64(8))
+	testutil.Expect(t, resp.Header.Get("Content-Length"), "8")
+	testutil.Expect(t, resp.Header.Get("X-Padding"), "3")
+
+	resp, body = get("/dots")
+	testutil.Expect(t, body, "hello...")
+	testutil.Expect(t, resp.Header.Get("X-Padding"), "")
+
+	// larger responses are passed through
+	resp, body = get("/large")
+	testutil.Expect(t, body, "hello world")
+	testutil.Expect(t, resp.Header.Get("X-Padding"), "")
+}
+
+func TestPadResponseOnlyMatchingHost(t *testing.T) {
+	cfg := &Configuration{ResponsePadding: []PaddingRule{{Host: "cdn.example.com", Path: "/video", TargetBytes: 4}}}
+
+	for _, u := range []string{"http://api.example.com/video", "http://cdn.example.com/audio"} {
+		req, err := http.NewRequest("GET", u, nil)
+		testutil.Expect(t, err, nil)
+		response := models.ResponseDetails{Body: "a"}
+		cfg.padResponse(&response, req)
+		testutil.Expect(t, response.Body, "a")
+	}
+
+	// encoded bodies are not padded
+	req, err := http.NewRequest("GET", "http://cdn.example.com/video", nil)
+	testutil.Expect(t, err, nil)
+	response := models.ResponseDetails{Body: "a", Headers: map[string][]string{"Content-Encoding": {"gzip"}}}
+	cfg.padResponse(&response, req)
+	testutil.Expect(t, response.Body, "a")
+}
+
+func TestPaddingRuleValidate(t *testing.T) {
+	testutil.Expect(t, PaddingRule{Host: "cdn.example.com", TargetBytes: 1024, PaddingHeader: "X-Padding", Fill: " "}.Validate(), nil)
+
+	invalid := []PaddingRule{
+		{TargetBytes: 1024},
+		{Host: "cdn.example.com"},
+		{Host: "cdn.example.com", TargetBytes: 1024, PaddingHeader: "X Padding"},
+		{Host: "cdn.example.com", TargetBytes: 1024, Fill: "ab"},
+	}
+	for _, rule := range invalid {
+		testutil.Refute(t, rule.Validate(), nil)
+	}
+}
+
+func TestLoadResponsePadding(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hoverfly_response_padding")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "padding.json")
+	testutil.Expect(t, ioutil.WriteFile(path, []byte(`[{"host": "cdn.example.com", "targetBytes": 1024, "paddingHeader": "X-Padding"}]`), 0644), nil)
+
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.LoadResponsePadding(path), nil)
+	testutil.Expect(t, len(cfg.ResponsePadding), 1)
+	testutil.Expect(t, cfg.ResponsePadding[0], PaddingRule{Host: "cdn.example.com", TargetBytes: 1024, PaddingHeader: "X-Padding"})
+
+	testutil.Expect(t, ioutil.WriteFile(path, []byte(`[{"host": "cdn.example.com"}]`), 0644), nil)
+	err = cfg.LoadResponsePadding(path)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, strings.Contains(err.Error(), "target size"), true)
+}
@@ -0,0 +1,110 @@
+package hoverfly
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// maxCachedTemplates - parsed templates kept in memory, cache is cleared when it grows over this size
+const maxCachedTemplates = 1000
+
+// templateRequest - request data available to templated response bodies, i.e. '{{ index .Request.PathSegments 1 }}'
+// or '{{ .Request.Query.id }}'. Only first values of query parameters and headers are available by name.
+type templateRequest struct {
+	Method       string
+	Scheme       string
+	Host         string
+	Path         string
+	PathSegments []string
+	RawQuery     string
+	Query        map[string]string
+	Headers      map[string]string
+	Body         string
+}
+
+// templateData - data templated response bodies are executed with
+type templateData struct {
+	Request  templateRequest
+	Metadata map[string]string
+}
+
+// templateCache - parsed response body templates keyed by body, bodies that failed to parse are cached as nil
+type templateCache struct {
+	mu        sync.Mutex
+	templates map[string]*template.Template
+}
+
+var responseTemplates = &templateCache{templates: make(map[string]*template.Template)}
+
+// get - returns parsed template for body, nil when body is not a valid template
+func (c *templateCache) get(body string) *template.Template {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if tmpl, ok := c.templates[body]; ok {
+		return tmpl
+	}
+
+	tmpl, err := template.New("response").Option("missingkey=zero").Parse(body)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("Response body is not a valid template, returning it unchanged")
+		tmpl = nil
+	}
+
+	if len(c.templates) >= maxCachedTemplates {
+		c.templates = make(map[string]*template.Template)
+	}
+	c.templates[body] = tmpl
+	return tmpl
+}
+
+func newTemplateRequest(req *http.Request, body []byte) templateRequest {
+	query := make(map[string]string)
+	for k, v := range req.URL.Query() {
+		query[k] = v[0]
+	}
+	headers := make(map[string]string)
+	for k, v := range req.Header {
+		if len(v) > 0 {
+			headers[k] = v[0]
+		}
+	}
+
+	return templateRequest{
+		Method:       req.Method,
+		Scheme:       req.URL.Scheme,
+		Host:         req.Host,
+		Path:         req.URL.Path,
+		PathSegments: strings.Split(strings.Trim(req.URL.Path, "/"), "/"),
+		RawQuery:     req.URL.RawQuery,
+		Query:        query,
+		Headers:      headers,
+		Body:         string(body),
+	}
+}
+
+// renderTemplatedBody - executes response body as text/template with request data and middleware metadata, raw
+// body is returned when it can't be rendered
+func renderTemplatedBody(body string, req *http.Request, reqBody []byte, metadata map[string]string) string {
+	tmpl := responseTemplates.get(body)
+	if tmpl == nil {
+		return body
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, templateData{Request: newTemplateRequest(req, reqBody), Metadata: metadata}); err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"path":  req.URL.Path,
+		}).Warn("Failed to render templated response body, returning it unchanged")
+		return body
+	}
+	return buf.String()
+}
@@ -0,0 +1,65 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"text/template"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// simulateTemplated - imports templated response for given path and returns body simulated for request URL
+func simulateTemplated(t *testing.T, path, body, requestURL string) string {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+	testutil.Expect(t, err, nil)
+
+	err = dbClient.ImportPayloads([]models.PayloadView{{
+		Request: models.RequestDetailsView{
+			Method: "GET", Destination: req.URL.Host, Path: path, Query: req.URL.RawQuery,
+		},
+		Response: models.ResponseDetailsView{Status: 200, Body: body, TemplatedBody: true},
+	}})
+	testutil.Expect(t, err, nil)
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, 200)
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	return string(respBody)
+}
+
+func TestTemplatedBodyPathSubstitution(t *testing.T) {
+	body := simulateTemplated(t, "/users/42", `{"id": "{{ index .Request.PathSegments 1 }}", "method": "{{ .Request.Method }}"}`,
+		"http://example.com/users/42")
+	testutil.Expect(t, body, `{"id": "42", "method": "GET"}`)
+}
+
+func TestTemplatedBodyMissingVariable(t *testing.T) {
+	body := simulateTemplated(t, "/users", `name={{ .Request.Query.name }};sort={{ .Request.Query.sort }}`,
+		"http://example.com/users?name=alice")
+	testutil.Expect(t, body, "name=alice;sort=")
+}
+
+func TestTemplatedBodyInvalidTemplate(t *testing.T) {
+	body := simulateTemplated(t, "/users", `{"id": "{{ .Request.Path"}`, "http://example.com/users")
+	testutil.Expect(t, body, `{"id": "{{ .Request.Path"}`)
+}
+
+func TestTemplateCacheParsesOnce(t *testing.T) {
+	cache := &templateCache{templates: make(map[string]*template.Template)}
+
+	first := cache.get("{{ .Request.Method }}")
+	testutil.Refute(t, first, nil)
+	testutil.Expect(t, cache.get("{{ .Request.Method }}"), first)
+
+	testutil.Expect(t, cache.get("{{ broken") == nil, true)
+	testutil.Expect(t, len(cache.templates), 2)
+}
@@ -0,0 +1,204 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// CodeRewriteRule - replaces response status code for requests which host and path match given regexp pattern
+// when JSON response body value selected by the condition is truthy, i.e. '$.error' or '$.errors[0].code'
+type CodeRewriteRule struct {
+	Pattern   string `json:"pattern"`
+	Condition string `json:"condition"`
+	Status    int    `json:"status"`
+
+	// compiled Pattern, set by SetResponseCodeRewrites
+	re *regexp.Regexp
+}
+
+// Validate - checks whether rule can be used
+func (r CodeRewriteRule) Validate() error {
+	if _, err := regexp.Compile(r.Pattern); err != nil {
+		return fmt.Errorf("invalid pattern '%s': %s", r.Pattern, err.Error())
+	}
+	if _, err := parseJSONPath(r.Condition); err != nil {
+		return err
+	}
+	if http.StatusText(r.Status) == "" {
+		return fmt.Errorf("invalid replacement status code %d", r.Status)
+	}
+	return nil
+}
+
+// LoadResponseCodeRewrites - reads response code rewrite rules from JSON file, i.e.:
+//
+//	[{"pattern": "api.example.com", "condition": "$.error", "status": 400}]
+func (c *Configuration) LoadResponseCodeRewrites(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var rules []CodeRewriteRule
+	if err := json.NewDecoder(f).Decode(&rules); err != nil {
+		return fmt.Errorf("failed to parse response code rewrites file: %s", err.Error())
+	}
+
+	return c.SetResponseCodeRewrites(rules)
+}
+
+// SetResponseCodeRewrites - validates rules and compiles their patterns, responses are rewritten by the first rule
+// which pattern and condition match
+func (c *Configuration) SetResponseCodeRewrites(rules []CodeRewriteRule) error {
+	compiled := make([]CodeRewriteRule, len(rules))
+	for i, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		rule.re = regexp.MustCompile(rule.Pattern)
+		compiled[i] = rule
+	}
+
+	c.ResponseCodeRewrites = compiled
+
+	return nil
+}
+
+// rewriteStatus - returns status code replaced by the first matching rewrite rule, original status is returned
+// when no rule applies
+func (d *Hoverfly) rewriteStatus(req *http.Request, status int, body []byte) int {
+	cfg := d.requestConfig(req)
+	if len(cfg.ResponseCodeRewrites) == 0 {
+		return status
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		// only JSON bodies can be checked
+		return status
+	}
+
+	for _, rule := range cfg.ResponseCodeRewrites {
+		if rule.re == nil || !rule.re.MatchString(req.Host+req.URL.Path) {
+			continue
+		}
+
+		steps, err := parseJSONPath(rule.Condition)
+		if err != nil {
+			continue
+		}
+
+		if value, found := evalJSONPath(doc, steps); found && isTruthy(value) {
+			log.WithFields(log.Fields{
+				"destination":    req.Host,
+				"path":           req.URL.Path,
+				"condition":      rule.Condition,
+				"originalStatus": status,
+				"status":         rule.Status,
+			}).Info("response status code rewritten")
+			return rule.Status
+		}
+	}
+
+	return status
+}
+
+// parseJSONPath - parses simple JSONPath expression with dot and bracket notation ('$.a.b', "$['a'][0]") into
+// steps, string steps select object keys and int steps select array elements
+func parseJSONPath(path string) ([]interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid JSONPath '%s', it should start with '$'", path)
+	}
+
+	var steps []interface{}
+	rest := path[1:]
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSONPath '%s', empty key", path)
+			}
+			steps = append(steps, rest[:end])
+			rest = rest[end:]
+
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath '%s', missing ']'", path)
+			}
+			selector := rest[1:end]
+			rest = rest[end+1:]
+
+			if len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+				steps = append(steps, selector[1:len(selector)-1])
+				continue
+			}
+
+			index, err := strconv.Atoi(selector)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid JSONPath '%s', bad selector '%s'", path, selector)
+			}
+			steps = append(steps, index)
+
+		default:
+			return nil, fmt.Errorf("invalid JSONPath '%s', unexpected '%c'", path, rest[0])
+		}
+	}
+
+	return steps, nil
+}
+
+// evalJSONPath - returns value selected by given steps from decoded JSON document
+func evalJSONPath(doc interface{}, steps []interface{}) (interface{}, bool) {
+	current := doc
+
+	for _, step := range steps {
+		switch s := step.(type) {
+		case string:
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = object[s]; !ok {
+				return nil, false
+			}
+		case int:
+			array, ok := current.([]interface{})
+			if !ok || s >= len(array) {
+				return nil, false
+			}
+			current = array[s]
+		}
+	}
+
+	return current, true
+}
+
+// isTruthy - JavaScript like truthiness of decoded JSON value
+func isTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+package hoverfly
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	steps, err := parseJSONPath("$.errors[0]['code']")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(steps), 3)
+	testutil.Expect(t, steps[0], "errors")
+	testutil.Expect(t, steps[1], 0)
+	testutil.Expect(t, steps[2], "code")
+
+	steps, err = parseJSONPath("$")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(steps), 0)
+
+	for _, path := range []string{"error", "$.", "$[0", "$[x]", "$..a"} {
+		_, err = parseJSONPath(path)
+		testutil.Refute(t, err, nil)
+	}
+}
+
+func TestIsTruthy(t *testing.T) {
+	testutil.Expect(t, isTruthy(nil), false)
+	testutil.Expect(t, isTruthy(false), false)
+	testutil.Expect(t, isTruthy(float64(0)), false)
+	testutil.Expect(t, isTruthy(""), false)
+	testutil.Expect(t, isTruthy(true), true)
+	testutil.Expect(t, isTruthy("not found"), true)
+	testutil.Expect(t, isTruthy(map[string]interface{}{}), true)
+}
+
+func TestRewriteStatus(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	err := dbClient.Cfg.SetResponseCodeRewrites([]CodeRewriteRule{
+		{Pattern: "example.com/api", Condition: "$.error", Status: 400},
+		{Pattern: "example.com/api", Condition: "$.errors[0].code", Status: 503},
+	})
+	testutil.Expect(t, err, nil)
+
+	req, err := http.NewRequest("GET", "http://example.com/api/users", nil)
+	testutil.Expect(t, err, nil)
+
+	testutil.Expect(t, dbClient.rewriteStatus(req, 200, []byte(`{"error": "bad user"}`)), 400)
+	testutil.Expect(t, dbClient.rewriteStatus(req, 200, []byte(`{"error": false, "errors": [{"code": 1}]}`)), 503)
+	testutil.Expect(t, dbClient.rewriteStatus(req, 200, []byte(`{"error": null}`)), 200)
+	testutil.Expect(t, dbClient.rewriteStatus(req, 200, []byte(`not json`)), 200)
+
+	other, err := http.NewRequest("GET", "http://example.com/health", nil)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, dbClient.rewriteStatus(other, 200, []byte(`{"error": "bad user"}`)), 200)
+}
+
+func TestCaptureRewritesStatus(t *testing.T) {
+	server, dbClient := testTools(200, `{"error": "not allowed"}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	err := dbClient.Cfg.SetResponseCodeRewrites([]CodeRewriteRule{
+		{Pattern: ".", Condition: "$.error", Status: 403},
+	})
+	testutil.Expect(t, err, nil)
+
+	req, err := http.NewRequest("GET", "http://example.com/api", nil)
+	testutil.Expect(t, err, nil)
+
+	resp, err := dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, 403)
+
+	// rewritten status code is stored
+	req, err = http.NewRequest("GET", "http://example.com/api", nil)
+	testutil.Expect(t, err, nil)
+
+	resp = dbClient.getResponse(req)
+	testutil.Expect(t, resp.StatusCode, 403)
+}
+
+func TestCodeRewriteRuleValidate(t *testing.T) {
+	testutil.Expect(t, CodeRewriteRule{Pattern: ".", Condition: "$.error", Status: 400}.Validate(), nil)
+	testutil.Refute(t, CodeRewriteRule{Pattern: "(", Condition: "$.error", Status: 400}.Validate(), nil)
+	testutil.Refute(t, CodeRewriteRule{Pattern: ".", Condition: "error", Status: 400}.Validate(), nil)
+	testutil.Refute(t, CodeRewriteRule{Pattern: ".", Condition: "$.error", Status: 999}.Validate(), nil)
+}
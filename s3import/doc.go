@@ -0,0 +1,8 @@
+// Package s3import imports simulation files stored in S3 into Hoverfly. Objects are streamed from S3 into
+// Hoverfly.ImportSimulation without being written to disk.
+//
+// Importing needs AWS SDK for Go, which Hoverfly doesn't depend on otherwise, so it is only built with the 's3'
+// build tag:
+//
+//	go build -tags s3 ./...
+package s3import
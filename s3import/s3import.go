@@ -0,0 +1,145 @@
+//go:build s3
+// +build s3
+
+package s3import
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// ErrNotModified - object has the same ETag as when it was imported last time, nothing was imported
+var ErrNotModified = errors.New("simulation in S3 was not modified since last import")
+
+// Importer - imports simulations from S3 into Hoverfly, ETags of imported objects are kept so that unchanged
+// objects are neither downloaded nor imported again
+type Importer struct {
+	hoverfly *hoverfly.Hoverfly
+	session  *session.Session
+
+	mu    sync.Mutex
+	etags map[string]string
+}
+
+// NewImporter - returns importer that downloads objects with given AWS session
+func NewImporter(d *hoverfly.Hoverfly, sess *session.Session) *Importer {
+	return &Importer{hoverfly: d, session: sess, etags: make(map[string]string)}
+}
+
+// importers - importers used by ImportFromS3, one for each Hoverfly instance
+var importers = struct {
+	sync.Mutex
+	byHoverfly map[*hoverfly.Hoverfly]*Importer
+}{byHoverfly: make(map[*hoverfly.Hoverfly]*Importer)}
+
+// ImportFromS3 - streams simulation object from S3 into Hoverfly.ImportSimulation, returns number of imported
+// payloads. ErrNotModified is returned when object didn't change since it was imported into d last time.
+func ImportFromS3(d *hoverfly.Hoverfly, bucket, key string, sess *session.Session) (int, error) {
+	importers.Lock()
+	importer, ok := importers.byHoverfly[d]
+	if !ok {
+		importer = NewImporter(d, sess)
+		importers.byHoverfly[d] = importer
+	}
+	importers.Unlock()
+
+	importer.mu.Lock()
+	importer.session = sess
+	importer.mu.Unlock()
+
+	return importer.Import(bucket, key)
+}
+
+// Import - streams simulation object from S3 into Hoverfly, see ImportFromS3
+func (i *Importer) Import(bucket, key string) (int, error) {
+	object := bucket + "/" + key
+
+	i.mu.Lock()
+	sess := i.session
+	etag := i.etags[object]
+	i.mu.Unlock()
+
+	input := &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+	if etag != "" {
+		input.IfNoneMatch = aws.String(etag)
+	}
+
+	// single part is downloaded at a time, so that parts are written to the pipe in order
+	downloader := s3manager.NewDownloader(sess, func(d *s3manager.Downloader) {
+		d.Concurrency = 1
+	})
+
+	pr, pw := io.Pipe()
+	type result struct {
+		count int
+		err   error
+	}
+	imported := make(chan result, 1)
+	go func() {
+		count, err := i.hoverfly.ImportSimulation(pr)
+		// unblocks downloader when import stopped reading
+		pr.CloseWithError(err)
+		imported <- result{count, err}
+	}()
+
+	var newETag string
+	_, err := downloader.Download(&sequentialWriterAt{w: pw}, input, func(d *s3manager.Downloader) {
+		d.RequestOptions = append(d.RequestOptions, func(r *request.Request) {
+			r.Handlers.Complete.PushBack(func(r *request.Request) {
+				if out, ok := r.Data.(*s3.GetObjectOutput); ok && out.ETag != nil {
+					newETag = *out.ETag
+				}
+			})
+		})
+	})
+	pw.CloseWithError(err)
+	res := <-imported
+
+	if notModified(err) {
+		log.WithFields(log.Fields{
+			"bucket": bucket,
+			"key":    key,
+			"etag":   etag,
+		}).Info("simulation in S3 not modified, skipping import")
+		return 0, ErrNotModified
+	}
+	// import error says more than downloader failing to write into closed pipe
+	if res.err != nil {
+		return 0, res.err
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if newETag != "" {
+		i.mu.Lock()
+		i.etags[object] = newETag
+		i.mu.Unlock()
+	}
+
+	log.WithFields(log.Fields{
+		"bucket":   bucket,
+		"key":      key,
+		"etag":     newETag,
+		"payloads": res.count,
+	}).Info("simulation imported from S3")
+
+	return res.count, nil
+}
+
+// notModified - S3 rejected conditional request because ETag matches
+func notModified(err error) bool {
+	var failure awserr.RequestFailure
+	return errors.As(err, &failure) && failure.StatusCode() == http.StatusNotModified
+}
@@ -0,0 +1,156 @@
+//go:build s3
+// +build s3
+
+package s3import
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly"
+	"github.com/SpectoLabs/hoverfly/testutil"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// fakeS3 - serves objects from path style URLs ('/bucket/key'), ranges and conditional requests are handled by
+// http.ServeContent
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	etags    map[string]string
+	requests []*http.Request
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: make(map[string][]byte), etags: make(map[string]string)}
+}
+
+func (s *fakeS3) put(path string, body []byte, etag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[path] = body
+	s.etags[path] = etag
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r)
+	body, ok := s.objects[r.URL.Path]
+	etag := s.etags[r.URL.Path]
+	s.mu.Unlock()
+
+	if !ok {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+		return
+	}
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, r.URL.Path, time.Time{}, bytes.NewReader(body))
+}
+
+func (s *fakeS3) lastRequest() *http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+func testImporter(t *testing.T) (*Importer, *hoverfly.Hoverfly, *fakeS3, func()) {
+	s3 := newFakeS3()
+	server := httptest.NewServer(s3)
+
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:         aws.String(server.URL),
+		Region:           aws.String("us-east-1"),
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+		S3ForcePathStyle: aws.Bool(true),
+		DisableSSL:       aws.Bool(true),
+	})
+	testutil.Expect(t, err, nil)
+
+	cfg := hoverfly.InitSettings()
+	cfg.AuthEnabled = false
+	d, err := hoverfly.NewHoverfly(hoverfly.WithConfiguration(cfg))
+	testutil.Expect(t, err, nil)
+
+	return NewImporter(d, sess), d, s3, server.Close
+}
+
+func testSimulation(t *testing.T) []byte {
+	bts, err := ioutil.ReadFile("../examples/exports/readthedocs.json")
+	testutil.Expect(t, err, nil)
+	return bts
+}
+
+func TestImport(t *testing.T) {
+	importer, d, s3, closeServer := testImporter(t)
+	defer closeServer()
+	s3.put("/simulations/readthedocs.json", testSimulation(t), `"v1"`)
+
+	count, err := importer.Import("simulations", "readthedocs.json")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 5)
+
+	records, err := d.RequestCache.Count()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, records, 5)
+	testutil.Expect(t, s3.lastRequest().Header.Get("If-None-Match"), "")
+}
+
+func TestImportNotModified(t *testing.T) {
+	importer, _, s3, closeServer := testImporter(t)
+	defer closeServer()
+	s3.put("/simulations/readthedocs.json", testSimulation(t), `"v1"`)
+
+	_, err := importer.Import("simulations", "readthedocs.json")
+	testutil.Expect(t, err, nil)
+
+	count, err := importer.Import("simulations", "readthedocs.json")
+	testutil.Expect(t, err, ErrNotModified)
+	testutil.Expect(t, count, 0)
+	testutil.Expect(t, s3.lastRequest().Header.Get("If-None-Match"), `"v1"`)
+
+	// changed object is imported again
+	s3.put("/simulations/readthedocs.json", testSimulation(t), `"v2"`)
+	count, err = importer.Import("simulations", "readthedocs.json")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 5)
+}
+
+func TestImportMissingObject(t *testing.T) {
+	importer, d, _, closeServer := testImporter(t)
+	defer closeServer()
+
+	_, err := importer.Import("simulations", "missing.json")
+	testutil.Refute(t, err, nil)
+
+	records, err := d.RequestCache.Count()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, records, 0)
+}
+
+func TestImportInvalidSimulation(t *testing.T) {
+	importer, d, s3, closeServer := testImporter(t)
+	defer closeServer()
+	s3.put("/simulations/invalid.json", []byte(`{"data": [{"request": {}}]}`), `"v1"`)
+
+	_, err := importer.Import("simulations", "invalid.json")
+	testutil.Refute(t, err, nil)
+
+	records, err := d.RequestCache.Count()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, records, 0)
+
+	// failed import doesn't keep ETag
+	s3.put("/simulations/invalid.json", testSimulation(t), `"v1"`)
+	count, err := importer.Import("simulations", "invalid.json")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 5)
+}
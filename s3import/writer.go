@@ -0,0 +1,22 @@
+package s3import
+
+import (
+	"fmt"
+	"io"
+)
+
+// sequentialWriterAt - io.WriterAt that forwards writes to a stream. S3 downloader writes parts in order when it
+// downloads one part at a time, writes at any other offset fail.
+type sequentialWriterAt struct {
+	w      io.Writer
+	offset int64
+}
+
+func (s *sequentialWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	if off != s.offset {
+		return 0, fmt.Errorf("out of order write at offset %d, expected %d", off, s.offset)
+	}
+	n, err := s.w.Write(p)
+	s.offset += int64(n)
+	return n, err
+}
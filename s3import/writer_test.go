@@ -0,0 +1,29 @@
+package s3import
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestSequentialWriterAt(t *testing.T) {
+	var buf bytes.Buffer
+	w := &sequentialWriterAt{w: &buf}
+
+	n, err := w.WriteAt([]byte("abc"), 0)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, n, 3)
+	_, err = w.WriteAt([]byte("def"), 3)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, buf.String(), "abcdef")
+}
+
+func TestSequentialWriterAtOutOfOrder(t *testing.T) {
+	var buf bytes.Buffer
+	w := &sequentialWriterAt{w: &buf}
+
+	_, err := w.WriteAt([]byte("def"), 3)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, buf.Len(), 0)
+}
@@ -0,0 +1,239 @@
+package hoverfly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// JSONSchemaDraft07 - $schema of inferred schemas
+const JSONSchemaDraft07 = "http://json-schema.org/draft-07/schema#"
+
+// schemaNode - union of JSON values seen at one place in response bodies
+type schemaNode struct {
+	types      map[string]bool
+	objects    int
+	properties map[string]*schemaNode
+	seen       map[string]int
+	items      *schemaNode
+}
+
+func newSchemaNode() *schemaNode {
+	return &schemaNode{types: make(map[string]bool)}
+}
+
+// merge - adds structure of decoded JSON value to node, numbers have to be decoded as json.Number
+func (n *schemaNode) merge(v interface{}) {
+	switch value := v.(type) {
+	case nil:
+		n.types["null"] = true
+	case bool:
+		n.types["boolean"] = true
+	case string:
+		n.types["string"] = true
+	case json.Number:
+		if strings.ContainsAny(value.String(), ".eE") {
+			n.types["number"] = true
+		} else {
+			n.types["integer"] = true
+		}
+	case []interface{}:
+		n.types["array"] = true
+		if n.items == nil {
+			n.items = newSchemaNode()
+		}
+		for _, item := range value {
+			n.items.merge(item)
+		}
+	case map[string]interface{}:
+		n.types["object"] = true
+		if n.properties == nil {
+			n.properties = make(map[string]*schemaNode)
+			n.seen = make(map[string]int)
+		}
+		n.objects++
+		for k, item := range value {
+			if n.properties[k] == nil {
+				n.properties[k] = newSchemaNode()
+			}
+			n.properties[k].merge(item)
+			n.seen[k]++
+		}
+	}
+}
+
+// schema - JSON schema of node, field is required when it was present in every object and nullable fields
+// have 'null' among their types
+func (n *schemaNode) schema() map[string]interface{} {
+	s := make(map[string]interface{})
+
+	// integers are numbers as well
+	if n.types["number"] {
+		delete(n.types, "integer")
+	}
+	types := make([]string, 0, len(n.types))
+	for t := range n.types {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	switch len(types) {
+	case 0:
+		// only empty arrays were seen, anything is allowed
+	case 1:
+		s["type"] = types[0]
+	default:
+		s["type"] = types
+	}
+
+	if n.types["object"] {
+		properties := make(map[string]interface{}, len(n.properties))
+		required := []string{}
+		for k, p := range n.properties {
+			properties[k] = p.schema()
+			if n.seen[k] == n.objects {
+				required = append(required, k)
+			}
+		}
+		sort.Strings(required)
+		s["properties"] = properties
+		if len(required) > 0 {
+			s["required"] = required
+		}
+	}
+
+	if n.items != nil && len(n.items.types) > 0 {
+		s["items"] = n.items.schema()
+	}
+
+	return s
+}
+
+// schemaGroup - key of endpoint which responses share schema
+func schemaGroup(r models.RequestDetails) string {
+	return r.Method + " " + r.Destination + r.Path
+}
+
+// InferSchemas - infers JSON schema (draft-07) of response bodies for every endpoint in the cache, endpoints are
+// keyed by method, host and path (i.e. 'GET api.example.com/users'). Structures of all JSON bodies captured for
+// the same endpoint are merged, bodies that are not JSON are skipped. Result can be registered with
+// SetRequestSchemas.
+func (d *Hoverfly) InferSchemas() map[string]json.RawMessage {
+	schemas := make(map[string]json.RawMessage)
+
+	values, err := d.RequestCache.GetAllValues()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to get cache entries for schema inference")
+		return schemas
+	}
+
+	nodes := make(map[string]*schemaNode)
+	for _, v := range values {
+		payload, err := models.NewPayloadFromBytes(v)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Warn("Failed to decode cache entry for schema inference")
+			continue
+		}
+
+		bodies := []string{payload.Response.Body}
+		if payload.Paginated != nil {
+			bodies = bodies[:0]
+			for _, page := range payload.Paginated.Pages {
+				bodies = append(bodies, page.Body)
+			}
+		}
+
+		group := schemaGroup(payload.Request)
+		for _, body := range bodies {
+			dec := json.NewDecoder(bytes.NewReader([]byte(body)))
+			dec.UseNumber()
+			var value interface{}
+			if err := dec.Decode(&value); err != nil {
+				continue
+			}
+			if nodes[group] == nil {
+				nodes[group] = newSchemaNode()
+			}
+			nodes[group].merge(value)
+		}
+	}
+
+	for group, node := range nodes {
+		s := node.schema()
+		s["$schema"] = JSONSchemaDraft07
+		bts, err := json.Marshal(s)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error":    err.Error(),
+				"endpoint": group,
+			}).Error("Failed to encode inferred schema")
+			continue
+		}
+		schemas[group] = bts
+	}
+
+	return schemas
+}
+
+// SetRequestSchemas - registers JSON schemas of endpoint responses keyed the same way as InferSchemas keys them,
+// responses captured for these endpoints are validated against their schema and mismatches are logged
+func (c *Configuration) SetRequestSchemas(schemas map[string]json.RawMessage) error {
+	parsed := make(map[string]*jsonSchema, len(schemas))
+	for endpoint, raw := range schemas {
+		var schema jsonSchema
+		if err := json.Unmarshal(raw, &schema); err != nil {
+			return fmt.Errorf("invalid schema of '%s': %s", endpoint, err.Error())
+		}
+		parsed[endpoint] = &schema
+	}
+	c.RequestSchemas = schemas
+	c.requestSchemas = parsed
+	return nil
+}
+
+// validateResponseSchema - checks response body captured for given request against schema registered for its
+// endpoint, bodies of endpoints without schema and bodies that aren't JSON are not checked
+func (c *Configuration) validateResponseSchema(request models.RequestDetails, body string) []ValidationError {
+	schema, ok := c.requestSchemas[schemaGroup(request)]
+	if !ok {
+		return nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil
+	}
+
+	v := &schemaValidator{root: schema}
+	v.validate(schema, value, "")
+	return v.errors
+}
+
+// logSchemaMismatch - warns when captured response doesn't match schema of its endpoint, i.e. after upstream API
+// changed
+func logSchemaMismatch(cfg *Configuration, payload models.Payload) {
+	errs := cfg.validateResponseSchema(payload.Request, payload.Response.Body)
+	if len(errs) == 0 {
+		return
+	}
+
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	log.WithFields(log.Fields{
+		"endpoint": schemaGroup(payload.Request),
+		"errors":   strings.Join(messages, "; "),
+	}).Warn("Captured response doesn't match schema of its endpoint")
+}
@@ -0,0 +1,110 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func usersPayload(query, body string) models.PayloadView {
+	return models.PayloadView{
+		Request:  models.RequestDetailsView{Method: "GET", Destination: "api.example.com", Path: "/users", Query: query},
+		Response: models.ResponseDetailsView{Status: 200, Body: body},
+	}
+}
+
+func TestInferSchemasMergesBodies(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	err := dbClient.ImportPayloads([]models.PayloadView{
+		usersPayload("page=1", `{"id": 1, "name": "a", "email": null, "tags": ["x"], "score": 1}`),
+		usersPayload("page=2", `{"id": 2, "name": "b", "email": "b@example.com", "tags": [], "score": 1.5}`),
+		usersPayload("page=3", `{"id": 3, "email": "c@example.com"}`),
+		usersPayload("page=4", `not json`),
+	})
+	testutil.Expect(t, err, nil)
+
+	schemas := dbClient.InferSchemas()
+	testutil.Expect(t, len(schemas), 1)
+
+	var schema struct {
+		Schema     string   `json:"$schema"`
+		Type       string   `json:"type"`
+		Required   []string `json:"required"`
+		Properties map[string]struct {
+			Type  interface{}       `json:"type"`
+			Items map[string]string `json:"items"`
+		} `json:"properties"`
+	}
+	testutil.Expect(t, json.Unmarshal(schemas["GET api.example.com/users"], &schema), nil)
+
+	testutil.Expect(t, schema.Schema, JSONSchemaDraft07)
+	testutil.Expect(t, schema.Type, "object")
+	testutil.Expect(t, len(schema.Required), 2)
+	testutil.Expect(t, schema.Required[0], "email")
+	testutil.Expect(t, schema.Required[1], "id")
+
+	testutil.Expect(t, schema.Properties["id"].Type, "integer")
+	testutil.Expect(t, schema.Properties["score"].Type, "number")
+	testutil.Expect(t, schema.Properties["tags"].Items["type"], "string")
+
+	emailTypes, _ := json.Marshal(schema.Properties["email"].Type)
+	testutil.Expect(t, string(emailTypes), `["null","string"]`)
+}
+
+func TestInferSchemasArrays(t *testing.T) {
+	node := newSchemaNode()
+	node.merge([]interface{}{
+		map[string]interface{}{"id": json.Number("1")},
+		map[string]interface{}{"id": json.Number("2"), "extra": true},
+	})
+
+	s := node.schema()
+	testutil.Expect(t, s["type"], "array")
+
+	items := s["items"].(map[string]interface{})
+	required := items["required"].([]string)
+	testutil.Expect(t, len(required), 1)
+	testutil.Expect(t, required[0], "id")
+}
+
+func TestRegisteredSchemasValidateResponses(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	err := dbClient.ImportPayloads([]models.PayloadView{
+		usersPayload("page=1", `{"id": 1, "name": "a"}`),
+		usersPayload("page=2", `{"id": 2, "name": "b"}`),
+	})
+	testutil.Expect(t, err, nil)
+
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.SetRequestSchemas(dbClient.InferSchemas()), nil)
+	cfg = cfg.Clone()
+
+	request := models.RequestDetails{Method: "GET", Destination: "api.example.com", Path: "/users"}
+	testutil.Expect(t, len(cfg.validateResponseSchema(request, `{"id": 3, "name": "c"}`)), 0)
+
+	errs := cfg.validateResponseSchema(request, `{"id": "3"}`)
+	testutil.Expect(t, len(errs), 2)
+	testutil.Expect(t, errs[0].Field, "name")
+	testutil.Expect(t, errs[1].Field, "id")
+
+	// other endpoints and bodies that aren't JSON aren't checked
+	request.Path = "/orders"
+	testutil.Expect(t, len(cfg.validateResponseSchema(request, `{"id": "3"}`)), 0)
+	request.Path = "/users"
+	testutil.Expect(t, len(cfg.validateResponseSchema(request, `not json`)), 0)
+}
+
+func TestSetRequestSchemasInvalidSchema(t *testing.T) {
+	cfg := InitSettings()
+	err := cfg.SetRequestSchemas(map[string]json.RawMessage{"GET api.example.com/users": json.RawMessage(`{"type": 1}`)})
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, len(cfg.RequestSchemas), 0)
+}
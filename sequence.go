@@ -0,0 +1,35 @@
+package hoverfly
+
+import "sync"
+
+// SequenceCounter - counts how many times responses of each request key were served from their response sequence
+type SequenceCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewSequenceCounter - returns empty sequence counter
+func NewSequenceCounter() *SequenceCounter {
+	return &SequenceCounter{counts: make(map[string]int)}
+}
+
+// Next - returns index of response that should be served for given key from sequence of given length and
+// advances the counter, the last index is returned once the sequence is exhausted
+func (s *SequenceCounter) Next(key string, length int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.counts[key]
+	if i < length-1 {
+		s.counts[key] = i + 1
+		return i
+	}
+	return length - 1
+}
+
+// Reset - starts all sequences from the first response again
+func (s *SequenceCounter) Reset() {
+	s.mu.Lock()
+	s.counts = make(map[string]int)
+	s.mu.Unlock()
+}
@@ -0,0 +1,133 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func importSequencePayload(t *testing.T, dbClient *Hoverfly, sequence ...models.ResponseDetails) {
+	payload := models.Payload{
+		Request: models.RequestDetails{
+			Method:      "GET",
+			Destination: "example.com",
+			Path:        "/resource",
+		},
+		Response:         models.ResponseDetails{Status: 200, Body: "not in sequence"},
+		ResponseSequence: sequence,
+	}
+	err := dbClient.ImportPayloads([]models.PayloadView{*payload.ConvertToPayloadView()})
+	testutil.Expect(t, err, nil)
+}
+
+func getSequenceResponse(t *testing.T, dbClient *Hoverfly) (int, string) {
+	req, err := http.NewRequest("GET", "http://example.com/resource", nil)
+	testutil.Expect(t, err, nil)
+
+	resp := dbClient.getResponse(req)
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	return resp.StatusCode, string(body)
+}
+
+func TestResponseSequence(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	importSequencePayload(t, dbClient,
+		models.ResponseDetails{Status: 404},
+		models.ResponseDetails{Status: 200, Body: "created"},
+		models.ResponseDetails{Status: 304},
+	)
+
+	status, _ := getSequenceResponse(t, dbClient)
+	testutil.Expect(t, status, 404)
+
+	status, body := getSequenceResponse(t, dbClient)
+	testutil.Expect(t, status, 200)
+	testutil.Expect(t, body, "created")
+
+	// last response repeats after the sequence is exhausted
+	for i := 0; i < 3; i++ {
+		status, _ = getSequenceResponse(t, dbClient)
+		testutil.Expect(t, status, 304)
+	}
+}
+
+func TestResponseSequenceConcurrent(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	importSequencePayload(t, dbClient,
+		models.ResponseDetails{Status: 404},
+		models.ResponseDetails{Status: 200},
+		models.ResponseDetails{Status: 304},
+	)
+
+	var mu sync.Mutex
+	statuses := make(map[int]int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, _ := getSequenceResponse(t, dbClient)
+			mu.Lock()
+			statuses[status]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	testutil.Expect(t, statuses[404], 1)
+	testutil.Expect(t, statuses[200], 1)
+	testutil.Expect(t, statuses[304], 18)
+}
+
+func TestResponseSequenceSingleResponse(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	importSequencePayload(t, dbClient, models.ResponseDetails{Status: 201, Body: "only"})
+
+	for i := 0; i < 3; i++ {
+		status, body := getSequenceResponse(t, dbClient)
+		testutil.Expect(t, status, 201)
+		testutil.Expect(t, body, "only")
+	}
+}
+
+func TestDeleteSequencesHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	m := getBoneRouter(*dbClient)
+
+	importSequencePayload(t, dbClient,
+		models.ResponseDetails{Status: 404},
+		models.ResponseDetails{Status: 200},
+	)
+
+	status, _ := getSequenceResponse(t, dbClient)
+	testutil.Expect(t, status, 404)
+
+	req, err := http.NewRequest("DELETE", "/api/sequences", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	status, _ = getSequenceResponse(t, dbClient)
+	testutil.Expect(t, status, 404)
+	status, _ = getSequenceResponse(t, dbClient)
+	testutil.Expect(t, status, 200)
+}
@@ -3,6 +3,8 @@ package hoverfly
 import (
 	"github.com/SpectoLabs/hoverfly/testutil"
 	"os"
+	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -61,3 +63,92 @@ func TestGetMode(t *testing.T) {
 
 	testutil.Expect(t, cfg.GetMode(), "capture")
 }
+
+func TestViaHeader(t *testing.T) {
+	cfg := Configuration{}
+	testutil.Expect(t, cfg.ViaHeader(), "1.1 hoverfly (Hoverfly/"+Version+")")
+
+	cfg.ViaAlias = "gateway"
+	testutil.Expect(t, cfg.ViaHeader(), "1.1 gateway")
+}
+
+func TestIsLiveEndpoint(t *testing.T) {
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.SetLiveEndpoints([]string{"payments.example.com/webhook", "^auth\\."}), nil)
+
+	testutil.Expect(t, cfg.IsLiveEndpoint("payments.example.com", "/webhook/1"), true)
+	testutil.Expect(t, cfg.IsLiveEndpoint("auth.example.com", "/"), true)
+	testutil.Expect(t, cfg.IsLiveEndpoint("payments.example.com", "/orders"), false)
+	testutil.Expect(t, cfg.IsLiveEndpoint("example.com", "/auth."), false)
+
+	testutil.Refute(t, cfg.SetLiveEndpoints([]string{"payments.(example"}), nil)
+	testutil.Expect(t, cfg.IsLiveEndpoint("payments.example.com", "/webhook/1"), true)
+}
+
+// fillConfiguration - sets every exported field to non zero value so that fields missing in Clone are noticed
+func fillConfiguration(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field, info := v.Field(i), v.Type().Field(i)
+		if info.PkgPath != "" {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(info.Name)
+		case reflect.Bool:
+			field.SetBool(true)
+		case reflect.Int, reflect.Int64:
+			field.SetInt(int64(i + 1))
+		case reflect.Float64:
+			field.SetFloat(0.5)
+		case reflect.Slice:
+			field.Set(reflect.MakeSlice(field.Type(), 1, 1))
+		case reflect.Map:
+			field.Set(reflect.MakeMap(field.Type()))
+			field.SetMapIndex(reflect.Zero(field.Type().Key()), reflect.Zero(field.Type().Elem()))
+		case reflect.Ptr:
+			field.Set(reflect.New(field.Type().Elem()))
+		case reflect.Struct:
+			if field.Type() != reflect.TypeOf(sync.WaitGroup{}) {
+				fillConfiguration(field)
+			}
+		}
+	}
+}
+
+func TestConfigurationCloneCopiesAllFields(t *testing.T) {
+	cfg := &Configuration{}
+	fillConfiguration(reflect.ValueOf(cfg).Elem())
+
+	testutil.Expect(t, reflect.DeepEqual(cfg, cfg.Clone()), true)
+}
+
+func TestConfigurationCloneIsDeep(t *testing.T) {
+	cfg := &Configuration{
+		Mode:              SimulateMode,
+		Destinations:      []string{"example.com"},
+		QueryParamAliases: map[string][]string{"page": {"p"}},
+		DelayProfiles:     map[string]DelayProfile{"slow": {MinMs: 100}},
+		RedirectChains:    []RedirectChainRule{{Host: "example.com", Hops: []RedirectHop{{}}}},
+		MutualTLS:         &MutualTLSConfig{CertFile: "cert.pem"},
+		ChaosProfile:      &ChaosProfile{ErrorRate: 0.5},
+	}
+	clone := cfg.Clone()
+
+	clone.Mode = CaptureMode
+	clone.Destinations[0] = "changed.com"
+	clone.QueryParamAliases["page"][0] = "changed"
+	clone.DelayProfiles["fast"] = DelayProfile{}
+	clone.RedirectChains[0].Hops = nil
+	clone.MutualTLS.CertFile = "changed.pem"
+
+	testutil.Expect(t, cfg.Mode, SimulateMode)
+	testutil.Expect(t, cfg.Destinations[0], "example.com")
+	testutil.Expect(t, cfg.QueryParamAliases["page"][0], "p")
+	testutil.Expect(t, len(cfg.DelayProfiles), 1)
+	testutil.Expect(t, len(cfg.RedirectChains[0].Hops), 1)
+	testutil.Expect(t, cfg.MutualTLS.CertFile, "cert.pem")
+
+	// chaos profile keeps random source between requests and is shared
+	testutil.Expect(t, clone.ChaosProfile, cfg.ChaosProfile)
+}
@@ -0,0 +1,159 @@
+// Package simdiff compares simulations exported by Hoverfly, records are matched by the request fingerprint that
+// simulate mode uses to look them up
+package simdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// Record - request and response present in only one of the simulations
+type Record struct {
+	Key      string                     `json:"key"`
+	Request  models.RequestDetailsView  `json:"request"`
+	Response models.ResponseDetailsView `json:"response"`
+}
+
+// Response - status and headers of response in one of the simulations
+type Response struct {
+	Status  int                 `json:"status"`
+	Headers map[string][]string `json:"headers"`
+}
+
+// Modification - request present in both simulations with different response, BodyDiff is unified diff of decoded
+// bodies and is empty when bodies are the same
+type Modification struct {
+	Key      string                    `json:"key"`
+	Request  models.RequestDetailsView `json:"request"`
+	Old      Response                  `json:"old"`
+	New      Response                  `json:"new"`
+	BodyDiff string                    `json:"bodyDiff,omitempty"`
+}
+
+// SimDiff - records added, removed and modified in the new simulation, each list is sorted by key
+type SimDiff struct {
+	Added    []Record       `json:"added"`
+	Removed  []Record       `json:"removed"`
+	Modified []Modification `json:"modified"`
+}
+
+// Empty - simulations have the same records and responses
+func (d SimDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// simulation - default format Hoverfly exports records in
+type simulation struct {
+	Data []models.PayloadView `json:"data"`
+}
+
+// CompareSim - compares old simulation a with new simulation b
+func CompareSim(a, b io.Reader) (SimDiff, error) {
+	old, err := readSimulation(a)
+	if err != nil {
+		return SimDiff{}, fmt.Errorf("old simulation: %s", err.Error())
+	}
+	current, err := readSimulation(b)
+	if err != nil {
+		return SimDiff{}, fmt.Errorf("new simulation: %s", err.Error())
+	}
+
+	diff := SimDiff{Added: []Record{}, Removed: []Record{}, Modified: []Modification{}}
+
+	for _, key := range sortedKeys(current) {
+		if _, ok := old[key]; !ok {
+			diff.Added = append(diff.Added, newRecord(key, current[key]))
+		}
+	}
+
+	for _, key := range sortedKeys(old) {
+		oldView := old[key]
+		newView, ok := current[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, newRecord(key, oldView))
+			continue
+		}
+
+		// decoded responses, so that encoded and plain bodies are compared by content
+		o := oldView.Response.ConvertToResponseDetails()
+		n := newView.Response.ConvertToResponseDetails()
+
+		bodyDiff := UnifiedDiff(o.Body, n.Body, "old", "new")
+		if o.Status == n.Status && headersEqual(o.Headers, n.Headers) && bodyDiff == "" {
+			continue
+		}
+
+		diff.Modified = append(diff.Modified, Modification{
+			Key:      key,
+			Request:  oldView.Request,
+			Old:      Response{Status: o.Status, Headers: o.Headers},
+			New:      Response{Status: n.Status, Headers: n.Headers},
+			BodyDiff: bodyDiff,
+		})
+	}
+
+	return diff, nil
+}
+
+// readSimulation - returns payloads keyed by request fingerprint, later records replace earlier ones with the same
+// fingerprint like they do on import
+func readSimulation(r io.Reader) (map[string]models.PayloadView, error) {
+	var sim simulation
+	if err := json.NewDecoder(r).Decode(&sim); err != nil {
+		return nil, fmt.Errorf("failed to parse simulation: %s", err.Error())
+	}
+
+	payloads := make(map[string]models.PayloadView, len(sim.Data))
+	for _, view := range sim.Data {
+		payload := view.ConvertToPayload()
+		payloads[payload.Id()] = view
+	}
+	return payloads, nil
+}
+
+func newRecord(key string, view models.PayloadView) Record {
+	return Record{Key: key, Request: view.Request, Response: view.Response}
+}
+
+// headersEqual - compares headers regardless of the case of their names
+func headersEqual(a, b map[string][]string) bool {
+	canonical := func(h map[string][]string) map[string][]string {
+		c := make(map[string][]string, len(h))
+		for name, values := range h {
+			key := http.CanonicalHeaderKey(name)
+			c[key] = append(c[key], values...)
+		}
+		return c
+	}
+
+	ca, cb := canonical(a), canonical(b)
+	if len(ca) != len(cb) {
+		return false
+	}
+	for name, values := range ca {
+		other, ok := cb[name]
+		if !ok || len(other) != len(values) {
+			return false
+		}
+		for i := range values {
+			if values[i] != other[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func sortedKeys(payloads map[string]models.PayloadView) []string {
+	keys := make([]string, 0, len(payloads))
+	for k := range payloads {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,95 @@
+package simdiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+const oldSimulation = `{"data": [
+	{"request": {"method": "GET", "destination": "example.com", "path": "/users"},
+	 "response": {"status": 200, "body": "alice\nbob\ncarol\n", "headers": {"Content-Type": ["text/plain"]}}},
+	{"request": {"method": "GET", "destination": "example.com", "path": "/health"},
+	 "response": {"status": 200, "body": "ok"}}
+]}`
+
+func TestCompareSimIdentical(t *testing.T) {
+	diff, err := CompareSim(strings.NewReader(oldSimulation), strings.NewReader(oldSimulation))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, diff.Empty(), true)
+}
+
+func TestCompareSimAddedRecord(t *testing.T) {
+	added := strings.Replace(oldSimulation, "\n]}", `,
+	{"request": {"method": "POST", "destination": "example.com", "path": "/users"},
+	 "response": {"status": 201, "body": "created"}}
+]}`, 1)
+
+	diff, err := CompareSim(strings.NewReader(oldSimulation), strings.NewReader(added))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(diff.Added), 1)
+	testutil.Expect(t, len(diff.Removed), 0)
+	testutil.Expect(t, len(diff.Modified), 0)
+	testutil.Expect(t, diff.Added[0].Request.Method, "POST")
+	testutil.Expect(t, diff.Added[0].Response.Status, 201)
+	testutil.Expect(t, diff.Added[0].Key != "", true)
+
+	// the other way round the record is removed
+	diff, err = CompareSim(strings.NewReader(added), strings.NewReader(oldSimulation))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(diff.Added), 0)
+	testutil.Expect(t, len(diff.Removed), 1)
+}
+
+func TestCompareSimChangedBody(t *testing.T) {
+	changed := strings.Replace(oldSimulation, `alice\nbob\ncarol\n`, `alice\nbobby\ncarol\n`, 1)
+
+	diff, err := CompareSim(strings.NewReader(oldSimulation), strings.NewReader(changed))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(diff.Added), 0)
+	testutil.Expect(t, len(diff.Removed), 0)
+	testutil.Expect(t, len(diff.Modified), 1)
+
+	modified := diff.Modified[0]
+	testutil.Expect(t, modified.Request.Path, "/users")
+	testutil.Expect(t, modified.Old.Status, 200)
+	testutil.Expect(t, modified.New.Status, 200)
+	testutil.Expect(t, modified.New.Headers["Content-Type"][0], "text/plain")
+	testutil.Expect(t, modified.BodyDiff, "--- old\n+++ new\n@@ -1,3 +1,3 @@\n alice\n-bob\n+bobby\n carol\n")
+}
+
+func TestCompareSimChangedStatusOnly(t *testing.T) {
+	changed := strings.Replace(oldSimulation, `"status": 200, "body": "ok"`, `"status": 503, "body": "ok"`, 1)
+
+	diff, err := CompareSim(strings.NewReader(oldSimulation), strings.NewReader(changed))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(diff.Modified), 1)
+	testutil.Expect(t, diff.Modified[0].Old.Status, 200)
+	testutil.Expect(t, diff.Modified[0].New.Status, 503)
+	testutil.Expect(t, diff.Modified[0].BodyDiff, "")
+}
+
+func TestCompareSimMalformed(t *testing.T) {
+	_, err := CompareSim(strings.NewReader(oldSimulation), strings.NewReader("not json"))
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, strings.HasPrefix(err.Error(), "new simulation:"), true)
+}
+
+func TestUnifiedDiffHunks(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 20; i++ {
+		a = append(a, string(rune('a'+i)))
+	}
+	b = append(b, a...)
+	b[1] = "B"
+	b[18] = "S"
+
+	diff := UnifiedDiff(strings.Join(a, "\n"), strings.Join(b, "\n"), "old", "new")
+	testutil.Expect(t, strings.Count(diff, "@@ "), 2)
+	testutil.Expect(t, strings.Contains(diff, "@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n"), true)
+	testutil.Expect(t, strings.Contains(diff, "@@ -16,5 +16,5 @@\n p\n q\n r\n-s\n+S\n t\n"), true)
+
+	testutil.Expect(t, UnifiedDiff("same", "same", "old", "new"), "")
+	testutil.Expect(t, UnifiedDiff("", "line\n", "old", "new"), "--- old\n+++ new\n@@ -0,0 +1,1 @@\n+line\n")
+}
@@ -0,0 +1,79 @@
+package hoverfly
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rusenask/goproxy"
+)
+
+// Default CORS headers of simulated responses
+const (
+	DefaultCORSAllowOrigin  = "*"
+	DefaultCORSAllowMethods = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	DefaultCORSAllowHeaders = "Authorization, Content-Type"
+)
+
+// CORSConfig - CORS headers added to simulated responses so that browsers can call simulated services, headers
+// that are empty are set to defaults. MaxAge is only sent in responses to preflight requests when it is set.
+type CORSConfig struct {
+	Enabled      bool
+	AllowOrigin  string
+	AllowMethods string
+	AllowHeaders string
+	MaxAge       int
+}
+
+// headers - CORS headers in the order they are added
+func (c CORSConfig) headers(preflight bool) [][2]string {
+	value := func(v, fallback string) string {
+		if v == "" {
+			return fallback
+		}
+		return v
+	}
+
+	headers := [][2]string{
+		{"Access-Control-Allow-Origin", value(c.AllowOrigin, DefaultCORSAllowOrigin)},
+		{"Access-Control-Allow-Methods", value(c.AllowMethods, DefaultCORSAllowMethods)},
+		{"Access-Control-Allow-Headers", value(c.AllowHeaders, DefaultCORSAllowHeaders)},
+	}
+	if preflight && c.MaxAge > 0 {
+		headers = append(headers, [2]string{"Access-Control-Max-Age", strconv.Itoa(c.MaxAge)})
+	}
+	return headers
+}
+
+// applySimulatedCORSHeaders - adds CORS headers that simulated response doesn't set itself, stored headers are kept
+// even when their names differ in case
+func (c CORSConfig) applySimulatedCORSHeaders(resp *http.Response, preflight bool) {
+	if resp.Header == nil {
+		resp.Header = make(http.Header)
+	}
+
+	for _, header := range c.headers(preflight) {
+		present := false
+		for name := range resp.Header {
+			if strings.EqualFold(name, header[0]) {
+				present = true
+				break
+			}
+		}
+		if !present {
+			resp.Header.Set(header[0], header[1])
+		}
+	}
+}
+
+// isCORSPreflight - OPTIONS request sent by browser before the actual cross-origin request
+func isCORSPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// corsPreflightResponse - empty response with CORS headers to preflight request
+func (c CORSConfig) corsPreflightResponse(req *http.Request) *http.Response {
+	resp := goproxy.NewResponse(req, goproxy.ContentTypeText, http.StatusNoContent, "")
+	c.applySimulatedCORSHeaders(resp, true)
+	return resp
+}
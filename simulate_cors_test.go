@@ -0,0 +1,115 @@
+package hoverfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func corsProxyClient(t *testing.T, d *Hoverfly) (*http.Client, func()) {
+	d.UpdateProxy()
+	proxy := httptest.NewServer(d.Proxy)
+
+	proxyURL, err := url.Parse(proxy.URL)
+	testutil.Expect(t, err, nil)
+	return &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}, proxy.Close
+}
+
+func storeCORSPayload(t *testing.T, d *Hoverfly, headers map[string][]string) {
+	payload := models.Payload{
+		Request:  models.RequestDetails{Method: "GET", Destination: "cors.com", Path: "/api"},
+		Response: models.ResponseDetails{Status: 200, Body: "ok", Headers: headers},
+	}
+	bts, err := payload.Encode()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, d.RequestCache.Set([]byte(payload.Id()), bts), nil)
+}
+
+func TestSimulateCORSPreflight(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.CORS = CORSConfig{Enabled: true, AllowOrigin: "http://localhost:3000", MaxAge: 600}
+	client, closeProxy := corsProxyClient(t, dbClient)
+	defer closeProxy()
+
+	req, err := http.NewRequest("OPTIONS", "http://cors.com/api", nil)
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+
+	resp, err := client.Do(req)
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+
+	testutil.Expect(t, resp.StatusCode, http.StatusNoContent)
+	testutil.Expect(t, resp.Header.Get("Access-Control-Allow-Origin"), "http://localhost:3000")
+	testutil.Expect(t, resp.Header.Get("Access-Control-Allow-Methods"), DefaultCORSAllowMethods)
+	testutil.Expect(t, resp.Header.Get("Access-Control-Allow-Headers"), DefaultCORSAllowHeaders)
+	testutil.Expect(t, resp.Header.Get("Access-Control-Max-Age"), "600")
+}
+
+func TestSimulateCORSHeadersKeepStoredOnes(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	storeCORSPayload(t, dbClient, map[string][]string{
+		"access-control-allow-origin": {"https://stored.com"},
+	})
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.CORS = CORSConfig{Enabled: true, MaxAge: 600}
+	client, closeProxy := corsProxyClient(t, dbClient)
+	defer closeProxy()
+
+	resp, err := client.Get("http://cors.com/api")
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, len(resp.Header["Access-Control-Allow-Origin"]), 1)
+	testutil.Expect(t, resp.Header.Get("Access-Control-Allow-Origin"), "https://stored.com")
+	testutil.Expect(t, resp.Header.Get("Access-Control-Allow-Methods"), DefaultCORSAllowMethods)
+	// max age is only sent to preflight requests
+	testutil.Expect(t, resp.Header.Get("Access-Control-Max-Age"), "")
+
+	// stored simulation doesn't get injected headers
+	bts, err := dbClient.RequestCache.GetAllValues()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(bts), 1)
+	payload, err := models.NewPayloadFromBytes(bts[0])
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(payload.Response.Headers), 1)
+}
+
+func TestSimulateCORSDisabledInCaptureMode(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	dbClient.Cfg.CORS = CORSConfig{Enabled: true}
+	client, closeProxy := corsProxyClient(t, dbClient)
+	defer closeProxy()
+
+	resp, err := client.Get("http://cors.com/api")
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, resp.Header.Get("Access-Control-Allow-Origin"), "")
+
+	bts, err := dbClient.RequestCache.GetAllValues()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(bts), 1)
+	payload, err := models.NewPayloadFromBytes(bts[0])
+	testutil.Expect(t, err, nil)
+	_, injected := payload.Response.Headers["Access-Control-Allow-Origin"]
+	testutil.Expect(t, injected, false)
+}
@@ -0,0 +1,53 @@
+package hoverfly
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// simulationChecksum - response of 'GET /api/simulation/checksum'
+type simulationChecksum struct {
+	SHA256     string `json:"sha256"`
+	EntryCount int    `json:"entryCount"`
+}
+
+// SimulationChecksum - SHA-256 of all records exported with ExportNormalised, so instances with the same captures
+// get the same checksum regardless of when they were captured or in what order
+func (d *Hoverfly) SimulationChecksum() (string, int, error) {
+	views, err := d.normalisedPayloadViews()
+	if err != nil {
+		return "", 0, err
+	}
+
+	h := sha256.New()
+	if err := encodeNormalised(h, views); err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), len(views), nil
+}
+
+// SimulationChecksumHandler - returns SHA-256 checksum and number of records in the cache
+func (d *Hoverfly) SimulationChecksumHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	sum, count, err := d.SimulationChecksum()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to compute simulation checksum")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	b, err := json.Marshal(simulationChecksum{SHA256: sum, EntryCount: count})
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
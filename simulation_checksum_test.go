@@ -0,0 +1,85 @@
+package hoverfly
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestSimulationChecksumSameCaptures(t *testing.T) {
+	var sums []string
+	for i, date := range []string{"Mon, 12 Oct 2026 10:00:00 GMT", "Tue, 13 Oct 2026 11:30:00 GMT"} {
+		server, dbClient := testTools(200, `{'message': 'here'}`)
+		defer server.Close()
+		defer dbClient.RequestCache.DeleteData()
+
+		paths := []string{"/a", "/b"}
+		if i == 1 {
+			paths = []string{"/b", "/a"}
+		}
+		for _, path := range paths {
+			storeNormalisedPayload(t, dbClient, path, map[string][]string{"content-type": {"text/plain"}, "Date": {date}})
+		}
+
+		sum, count, err := dbClient.SimulationChecksum()
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, count, 2)
+		sums = append(sums, sum)
+	}
+	testutil.Expect(t, sums[0], sums[1])
+	testutil.Expect(t, len(sums[0]), 64)
+}
+
+func TestSimulationChecksumChangesWithCache(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	empty, count, err := dbClient.SimulationChecksum()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 0)
+
+	storeNormalisedPayload(t, dbClient, "/a", nil)
+	sum, count, err := dbClient.SimulationChecksum()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+	testutil.Refute(t, sum, empty)
+
+	storeNormalisedPayload(t, dbClient, "/a", map[string][]string{"X-Version": {"2"}})
+	changed, _, err := dbClient.SimulationChecksum()
+	testutil.Expect(t, err, nil)
+	testutil.Refute(t, changed, sum)
+}
+
+func TestSimulationChecksumHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	storeNormalisedPayload(t, dbClient, "/a", nil)
+	storeNormalisedPayload(t, dbClient, "/b", nil)
+
+	m := getBoneRouter(*dbClient)
+	req, err := http.NewRequest("GET", "/api/simulation/checksum", nil)
+	testutil.Expect(t, err, nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var response simulationChecksum
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &response), nil)
+	testutil.Expect(t, response.EntryCount, 2)
+
+	// checksum of the normalised export
+	var buf bytes.Buffer
+	testutil.Expect(t, dbClient.ExportNormalised(&buf), nil)
+	sum := sha256.Sum256(buf.Bytes())
+	testutil.Expect(t, response.SHA256, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,114 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/simdiff"
+)
+
+// EntryChange - difference in response of entry present in both simulations
+type EntryChange struct {
+	Field    string `json:"field"`
+	Baseline string `json:"baseline"`
+	Current  string `json:"current"`
+}
+
+// ModifiedEntry - request which response differs between simulations, BodyDiff is unified diff of the bodies when
+// they differ
+type ModifiedEntry struct {
+	Request  models.RequestDetailsView `json:"request"`
+	Changes  []EntryChange             `json:"changes"`
+	BodyDiff string                    `json:"bodyDiff,omitempty"`
+}
+
+// SimulationDiff - entry level diff between baseline and current simulation, entries are matched by request
+type SimulationDiff struct {
+	Added    []models.RequestDetailsView `json:"added"`
+	Removed  []models.RequestDetailsView `json:"removed"`
+	Modified []ModifiedEntry             `json:"modified"`
+}
+
+// readSimulation - parses simulation in the default export format, payloads are keyed by request hash
+func readSimulation(r io.Reader) (map[string]models.PayloadView, error) {
+	var requests recordedRequests
+	if err := json.NewDecoder(r).Decode(&requests); err != nil {
+		return nil, fmt.Errorf("Got error while parsing payloads, error %s", err.Error())
+	}
+	if err := requests.resolveBodyRefs(); err != nil {
+		return nil, err
+	}
+
+	payloads := make(map[string]models.PayloadView, len(requests.Data))
+	for _, view := range requests.Data {
+		pl := view.ConvertToPayload()
+		payloads[pl.Id()] = view
+	}
+	return payloads, nil
+}
+
+// DiffSimulations - compares two simulations without importing them, response bodies are compared by content when
+// they are JSON. Headers listed in DiffIgnoreHeaders are not compared.
+func (c *Configuration) DiffSimulations(baseline, current io.Reader) (*SimulationDiff, error) {
+	base, err := readSimulation(baseline)
+	if err != nil {
+		return nil, fmt.Errorf("baseline: %s", err.Error())
+	}
+	curr, err := readSimulation(current)
+	if err != nil {
+		return nil, fmt.Errorf("current: %s", err.Error())
+	}
+
+	diff := &SimulationDiff{
+		Added:    []models.RequestDetailsView{},
+		Removed:  []models.RequestDetailsView{},
+		Modified: []ModifiedEntry{},
+	}
+
+	for _, key := range sortedPayloadKeys(curr) {
+		if _, ok := base[key]; !ok {
+			diff.Added = append(diff.Added, curr[key].Request)
+		}
+	}
+
+	for _, key := range sortedPayloadKeys(base) {
+		baseView := base[key]
+		currView, ok := curr[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, baseView.Request)
+			continue
+		}
+
+		// decoded responses, so that encoded and plain bodies can be compared
+		b := baseView.Response.ConvertToResponseDetails()
+		n := currView.Response.ConvertToResponseDetails()
+
+		differences := c.compareResponses(b.Status, n.Status, b.Headers, n.Headers, []byte(b.Body), []byte(n.Body))
+		if len(differences) == 0 {
+			continue
+		}
+
+		entry := ModifiedEntry{Request: baseView.Request, Changes: make([]EntryChange, len(differences))}
+		for i, d := range differences {
+			entry.Changes[i] = EntryChange{Field: d.Field, Baseline: d.Simulated, Current: d.Live}
+			if d.Field == "body" {
+				entry.BodyDiff = simdiff.UnifiedDiff(b.Body, n.Body, "baseline", "current")
+			}
+		}
+		diff.Modified = append(diff.Modified, entry)
+	}
+
+	return diff, nil
+}
+
+func sortedPayloadKeys(payloads map[string]models.PayloadView) []string {
+	keys := make([]string, 0, len(payloads))
+	for k := range payloads {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,64 @@
+package hoverfly
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+const baselineSimulation = `{"data": [
+	{"request": {"method": "GET", "destination": "example.com", "path": "/users"},
+	 "response": {"status": 200, "body": "{\"id\": 1, \"name\": \"a\"}"}},
+	{"request": {"method": "GET", "destination": "example.com", "path": "/orders"},
+	 "response": {"status": 200, "body": "[]"}},
+	{"request": {"method": "GET", "destination": "example.com", "path": "/health"},
+	 "response": {"status": 200, "body": "ok"}}
+]}`
+
+const currentSimulation = `{"data": [
+	{"request": {"method": "GET", "destination": "example.com", "path": "/users"},
+	 "response": {"status": 200, "body": "{\"name\": \"a\",\n \"id\": 1}"}},
+	{"request": {"method": "GET", "destination": "example.com", "path": "/health"},
+	 "response": {"status": 503, "body": "ok"}},
+	{"request": {"method": "GET", "destination": "example.com", "path": "/payments"},
+	 "response": {"status": 200, "body": "[]"}}
+]}`
+
+func TestDiffSimulations(t *testing.T) {
+	cfg := InitSettings()
+
+	diff, err := cfg.DiffSimulations(strings.NewReader(baselineSimulation), strings.NewReader(currentSimulation))
+	testutil.Expect(t, err, nil)
+
+	testutil.Expect(t, len(diff.Added), 1)
+	testutil.Expect(t, diff.Added[0].Path, "/payments")
+
+	testutil.Expect(t, len(diff.Removed), 1)
+	testutil.Expect(t, diff.Removed[0].Path, "/orders")
+
+	// reformatted JSON body is not a modification
+	testutil.Expect(t, len(diff.Modified), 1)
+	testutil.Expect(t, diff.Modified[0].Request.Path, "/health")
+	testutil.Expect(t, diff.Modified[0].Changes[0].Field, "status")
+	testutil.Expect(t, diff.Modified[0].Changes[0].Baseline, "200")
+	testutil.Expect(t, diff.Modified[0].Changes[0].Current, "503")
+}
+
+func TestDiffSimulationsMalformed(t *testing.T) {
+	cfg := InitSettings()
+
+	_, err := cfg.DiffSimulations(strings.NewReader(baselineSimulation), strings.NewReader("not json"))
+	testutil.Refute(t, err, nil)
+}
+
+func TestDiffSimulationsBodyDiff(t *testing.T) {
+	cfg := InitSettings()
+
+	changed := strings.Replace(baselineSimulation, `"body": "ok"`, `"body": "degraded"`, 1)
+	diff, err := cfg.DiffSimulations(strings.NewReader(baselineSimulation), strings.NewReader(changed))
+	testutil.Expect(t, err, nil)
+
+	testutil.Expect(t, len(diff.Modified), 1)
+	testutil.Expect(t, diff.Modified[0].BodyDiff, "--- baseline\n+++ current\n@@ -1,1 +1,1 @@\n-ok\n+degraded\n")
+}
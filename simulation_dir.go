@@ -0,0 +1,68 @@
+package hoverfly
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// MultiError - errors collected while processing several items, processing isn't aborted by any of them
+type MultiError []error
+
+func (m MultiError) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d error(s): %s", len(m), strings.Join(msgs, "; "))
+}
+
+// LoadSimulationDirectory - imports every '*.json' file in given directory and its subdirectories. Files are
+// imported in lexicographic order of their paths, so records of later files replace the same requests from earlier
+// ones. Files that fail to import don't stop the load, their errors are returned together as MultiError.
+func (d *Hoverfly) LoadSimulationDirectory(dir string) error {
+	var errs MultiError
+	loaded := 0
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// root directory has to be readable, unreadable subdirectories are reported
+			if path == dir {
+				return err
+			}
+			errs = append(errs, fmt.Errorf("%s: %s", path, err.Error()))
+			return nil
+		}
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(path), ".json") {
+			return nil
+		}
+
+		if err := d.ImportFromDisk(path); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"file":  path,
+			}).Error("Failed to load simulation file")
+			errs = append(errs, fmt.Errorf("%s: %s", path, err.Error()))
+			return nil
+		}
+		loaded++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to read simulation directory, error %s", err.Error())
+	}
+
+	log.WithFields(log.Fields{
+		"directory": dir,
+		"loaded":    loaded,
+		"failed":    len(errs),
+	}).Info("simulation directory loaded")
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
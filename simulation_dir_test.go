@@ -0,0 +1,139 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func fixtureSimulation(path, body string) string {
+	return `{"data": [{
+		"request": {"path": "` + path + `", "method": "GET", "destination": "example.com", "scheme": "http", "query": "", "body": "", "headers": {}},
+		"response": {"status": 200, "body": "` + body + `", "encodedBody": false, "headers": {}}
+	}]}`
+}
+
+// loadedBodies - response bodies of records in cache by request path
+func loadedBodies(t *testing.T, d *Hoverfly) map[string]string {
+	values, err := d.RequestCache.GetAllValues()
+	testutil.Expect(t, err, nil)
+
+	bodies := map[string]string{}
+	for _, v := range values {
+		payload, err := models.NewPayloadFromBytes(v)
+		testutil.Expect(t, err, nil)
+		bodies[payload.Request.Path] = payload.Response.Body
+	}
+	return bodies
+}
+
+func TestLoadSimulationDirectory(t *testing.T) {
+	dir := writeSimulationFiles(t, map[string]string{
+		"users.json":        fixtureSimulation("/users", "users"),
+		"nested/books.json": fixtureSimulation("/books", "books"),
+		"README.md":         "not a simulation",
+	})
+	defer os.RemoveAll(dir)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	testutil.Expect(t, dbClient.LoadSimulationDirectory(dir), nil)
+
+	bodies := loadedBodies(t, dbClient)
+	testutil.Expect(t, len(bodies), 2)
+	testutil.Expect(t, bodies["/users"], "users")
+	testutil.Expect(t, bodies["/books"], "books")
+}
+
+func TestLoadSimulationDirectoryLaterFilesTakePrecedence(t *testing.T) {
+	dir := writeSimulationFiles(t, map[string]string{
+		"01-base.json":     fixtureSimulation("/users", "base"),
+		"02-override.json": fixtureSimulation("/users", "override"),
+	})
+	defer os.RemoveAll(dir)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	testutil.Expect(t, dbClient.LoadSimulationDirectory(dir), nil)
+
+	bodies := loadedBodies(t, dbClient)
+	testutil.Expect(t, len(bodies), 1)
+	testutil.Expect(t, bodies["/users"], "override")
+}
+
+func TestLoadSimulationDirectoryInvalidFile(t *testing.T) {
+	dir := writeSimulationFiles(t, map[string]string{
+		"a.json":      fixtureSimulation("/users", "users"),
+		"broken.json": `{"data": [`,
+		"c.json":      fixtureSimulation("/books", "books"),
+	})
+	defer os.RemoveAll(dir)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	err := dbClient.LoadSimulationDirectory(dir)
+	errs, ok := err.(MultiError)
+	testutil.Expect(t, ok, true)
+	testutil.Expect(t, len(errs), 1)
+	testutil.Expect(t, strings.Contains(err.Error(), "broken.json"), true)
+
+	// files after the broken one are still loaded
+	bodies := loadedBodies(t, dbClient)
+	testutil.Expect(t, len(bodies), 2)
+	testutil.Expect(t, bodies["/books"], "books")
+}
+
+func TestLoadSimulationDirectoryEmpty(t *testing.T) {
+	dir, err := ioutil.TempDir("", "simulation-dir")
+	testutil.Expect(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	testutil.Expect(t, dbClient.LoadSimulationDirectory(dir), nil)
+	testutil.Expect(t, len(loadedBodies(t, dbClient)), 0)
+}
+
+func TestLoadSimulationDirectoryMissing(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	err := dbClient.LoadSimulationDirectory(filepath.Join(os.TempDir(), "missing-simulation-dir"))
+	testutil.Refute(t, err, nil)
+	_, ok := err.(MultiError)
+	testutil.Expect(t, ok, false)
+}
+
+func TestNewHoverflyLoadsSimulationDir(t *testing.T) {
+	dir := writeSimulationFiles(t, map[string]string{
+		"users.json":  fixtureSimulation("/users", "users"),
+		"broken.json": `not json`,
+	})
+	defer os.RemoveAll(dir)
+
+	cfg := InitSettings()
+	cfg.SimulationDir = dir
+	h, err := NewHoverfly(WithConfiguration(cfg))
+	testutil.Expect(t, err, nil)
+
+	bodies := loadedBodies(t, h)
+	testutil.Expect(t, len(bodies), 1)
+	testutil.Expect(t, bodies["/users"], "users")
+
+	cfg = InitSettings()
+	cfg.SimulationDir = filepath.Join(os.TempDir(), "missing-simulation-dir")
+	_, err = NewHoverfly(WithConfiguration(cfg))
+	testutil.Refute(t, err, nil)
+}
@@ -0,0 +1,130 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// includeDirectives - '$include' and 'responseHeaders' keys of simulation file or included file. Included files can
+// include other files, their paths are relative to the file that includes them.
+type includeDirectives struct {
+	Include         json.RawMessage     `json:"$include"`
+	ResponseHeaders map[string][]string `json:"responseHeaders"`
+}
+
+// includedSimulation - simulation file that can reference shared response headers
+type includedSimulation struct {
+	includeDirectives
+	Data []models.PayloadView `json:"data"`
+}
+
+// paths - '$include' can be a single path or a list of paths
+func (i includeDirectives) paths() ([]string, error) {
+	if len(i.Include) == 0 {
+		return nil, nil
+	}
+
+	var single string
+	if err := json.Unmarshal(i.Include, &single); err == nil {
+		return []string{single}, nil
+	}
+	var paths []string
+	if err := json.Unmarshal(i.Include, &paths); err != nil {
+		return nil, fmt.Errorf("'$include' should be a path or a list of paths")
+	}
+	return paths, nil
+}
+
+// defaultResponseHeaders - response headers of all included files merged with the ones of given file, headers of
+// the including file override included ones and later includes override earlier ones
+func (i includeDirectives) defaultResponseHeaders(path string, chain []string) (map[string][]string, error) {
+	paths, err := i.paths()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+
+	headers := make(map[string][]string)
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(path), p)
+		}
+		included, err := loadIncludedHeaders(p, chain)
+		if err != nil {
+			return nil, err
+		}
+		mergeHeaders(headers, included)
+	}
+	mergeHeaders(headers, i.ResponseHeaders)
+
+	return headers, nil
+}
+
+// loadIncludedHeaders - reads default response headers from included file, chain holds files that are including it
+// and is used to reject circular includes
+func loadIncludedHeaders(path string, chain []string) (map[string][]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range chain {
+		if p == abs {
+			return nil, fmt.Errorf("circular include: %s -> %s", strings.Join(chain, " -> "), abs)
+		}
+	}
+
+	f, err := os.Open(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open included file, error %s", err.Error())
+	}
+	defer f.Close()
+
+	var directives includeDirectives
+	if err := json.NewDecoder(f).Decode(&directives); err != nil {
+		return nil, fmt.Errorf("failed to parse included file '%s', error %s", abs, err.Error())
+	}
+
+	return directives.defaultResponseHeaders(abs, append(append([]string(nil), chain...), abs))
+}
+
+// mergeHeaders - adds headers to dst, headers that are already there (case insensitive) are replaced
+func mergeHeaders(dst, src map[string][]string) {
+	for name, values := range src {
+		for existing := range dst {
+			if strings.EqualFold(existing, name) {
+				delete(dst, existing)
+			}
+		}
+		dst[name] = values
+	}
+}
+
+// applyDefaultResponseHeaders - sets default headers on responses that don't set them explicitly
+func applyDefaultResponseHeaders(payloads []models.PayloadView, defaults map[string][]string) {
+	if len(defaults) == 0 {
+		return
+	}
+
+	for i := range payloads {
+		headers := payloads[i].Response.Headers
+		if headers == nil {
+			headers = make(map[string][]string)
+			payloads[i].Response.Headers = headers
+		}
+
+		set := make(map[string]bool, len(headers))
+		for name := range headers {
+			set[http.CanonicalHeaderKey(name)] = true
+		}
+		for name, values := range defaults {
+			if !set[http.CanonicalHeaderKey(name)] {
+				headers[name] = append([]string(nil), values...)
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// writeSimulationFiles - writes given files into temporary directory, returns the directory
+func writeSimulationFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "simulation-includes")
+	testutil.Expect(t, err, nil)
+	for name, content := range files {
+		testutil.Expect(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755), nil)
+		testutil.Expect(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644), nil)
+	}
+	return dir
+}
+
+const includingSimulation = `{
+	"$include": "shared/headers.json",
+	"data": [{
+		"request": {"path": "/default", "method": "GET", "destination": "example.com", "scheme": "http", "query": "", "body": "", "headers": {}},
+		"response": {"status": 200, "body": "default", "encodedBody": false, "headers": {"Content-Type": ["text/plain"]}}
+	}, {
+		"request": {"path": "/override", "method": "GET", "destination": "example.com", "scheme": "http", "query": "", "body": "", "headers": {}},
+		"response": {"status": 200, "body": "override", "encodedBody": false, "headers": {"cache-control": ["no-cache"]}}
+	}]
+}`
+
+func TestImportFromDiskAppliesIncludedHeaders(t *testing.T) {
+	dir := writeSimulationFiles(t, map[string]string{
+		"simulation.json": includingSimulation,
+		"shared/headers.json": `{
+			"$include": ["cors.json"],
+			"responseHeaders": {"Cache-Control": ["max-age=60"], "Access-Control-Allow-Origin": ["https://example.com"]}
+		}`,
+		"shared/cors.json": `{"responseHeaders": {"Access-Control-Allow-Origin": ["*"], "Access-Control-Allow-Methods": ["GET"]}}`,
+	})
+	defer os.RemoveAll(dir)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	testutil.Expect(t, dbClient.Import(filepath.Join(dir, "simulation.json")), nil)
+
+	values, err := dbClient.RequestCache.GetAllValues()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(values), 2)
+
+	responses := map[string]models.ResponseDetails{}
+	for _, v := range values {
+		payload, err := models.NewPayloadFromBytes(v)
+		testutil.Expect(t, err, nil)
+		responses[payload.Response.Body] = payload.Response
+	}
+
+	headers := responses["default"].Headers
+	testutil.Expect(t, headers["Content-Type"][0], "text/plain")
+	testutil.Expect(t, headers["Cache-Control"][0], "max-age=60")
+	// including file overrides included one
+	testutil.Expect(t, headers["Access-Control-Allow-Origin"][0], "https://example.com")
+	testutil.Expect(t, headers["Access-Control-Allow-Methods"][0], "GET")
+
+	// entry headers override defaults regardless of case
+	headers = responses["override"].Headers
+	testutil.Expect(t, headers["cache-control"][0], "no-cache")
+	testutil.Expect(t, len(headers["Cache-Control"]), 0)
+	testutil.Expect(t, headers["Access-Control-Allow-Methods"][0], "GET")
+}
+
+func TestImportFromDiskRejectsCircularIncludes(t *testing.T) {
+	dir := writeSimulationFiles(t, map[string]string{
+		"simulation.json":     includingSimulation,
+		"shared/headers.json": `{"$include": "other.json"}`,
+		"shared/other.json":   `{"$include": "headers.json"}`,
+	})
+	defer os.RemoveAll(dir)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	err := dbClient.Import(filepath.Join(dir, "simulation.json"))
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, strings.Contains(err.Error(), "circular include"), true)
+
+	count, _ := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, count, 0)
+}
+
+func TestImportFromDiskRejectsSelfInclude(t *testing.T) {
+	dir := writeSimulationFiles(t, map[string]string{
+		"simulation.json": `{"$include": "./simulation.json", "data": []}`,
+	})
+	defer os.RemoveAll(dir)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	err := dbClient.Import(filepath.Join(dir, "simulation.json"))
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, strings.Contains(err.Error(), "circular include"), true)
+}
+
+func TestImportFromDiskMissingInclude(t *testing.T) {
+	dir := writeSimulationFiles(t, map[string]string{
+		"simulation.json": `{"$include": ["missing.json"], "data": []}`,
+	})
+	defer os.RemoveAll(dir)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	testutil.Refute(t, dbClient.Import(filepath.Join(dir, "simulation.json")), nil)
+}
+
+func TestIncludeDirectivesPaths(t *testing.T) {
+	paths, err := includeDirectives{Include: []byte(`"a.json"`)}.paths()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(paths), 1)
+	testutil.Expect(t, paths[0], "a.json")
+
+	paths, err = includeDirectives{Include: []byte(`["a.json", "b.json"]`)}.paths()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(paths), 2)
+
+	_, err = includeDirectives{Include: []byte(`42`)}.paths()
+	testutil.Refute(t, err, nil)
+}
@@ -0,0 +1,135 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// LintWarning - likely mistake in simulation entry that is still valid according to simulation schema. Entry and
+// Field are set the same way as in ValidationError.
+type LintWarning struct {
+	Entry   int    `json:"entry"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (w LintWarning) String() string {
+	return fmt.Sprintf("%s: %s", w.Field, w.Message)
+}
+
+// LintSimulation - checks responses of simulation read from given reader for common mistakes: status codes outside
+// of 100-599, malformed header names, invalid Content-Type, invalid Location of 3xx responses, bodies that aren't
+// JSON although Content-Type says so and bodies that don't match Content-Length. Templated bodies are only rendered
+// when they are served so their contents aren't checked. Error is returned only when simulation can't be parsed.
+func LintSimulation(r io.Reader) ([]LintWarning, error) {
+	var requests recordedRequests
+	if err := json.NewDecoder(r).Decode(&requests); err != nil {
+		return nil, fmt.Errorf("Got error while parsing simulation, error %s", err.Error())
+	}
+	if err := requests.resolveBodyRefs(); err != nil {
+		return nil, err
+	}
+
+	l := &simulationLinter{}
+	for i, payload := range requests.Data {
+		l.lintResponse(i, fmt.Sprintf("data[%d].response", i), payload.Response)
+		for j, response := range payload.ResponseSequence {
+			l.lintResponse(i, fmt.Sprintf("data[%d].responseSequence[%d]", i, j), response)
+		}
+	}
+	return l.warnings, nil
+}
+
+type simulationLinter struct {
+	warnings []LintWarning
+}
+
+func (l *simulationLinter) warn(entry int, field, message string) {
+	l.warnings = append(l.warnings, LintWarning{Entry: entry, Field: field, Message: message})
+}
+
+func (l *simulationLinter) lintResponse(entry int, path string, response models.ResponseDetailsView) {
+	if response.Status < 100 || response.Status > 599 {
+		l.warn(entry, path+".status", fmt.Sprintf("status code %d is not between 100 and 599", response.Status))
+	}
+
+	names := make([]string, 0, len(response.Headers))
+	for name := range response.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !isHeaderName(name) {
+			l.warn(entry, path+".headers", fmt.Sprintf("'%s' is not a valid header name", name))
+		}
+	}
+
+	headers := http.Header{}
+	for _, name := range names {
+		headers[http.CanonicalHeaderKey(name)] = append(headers[http.CanonicalHeaderKey(name)], response.Headers[name]...)
+	}
+	body := response.ConvertToResponseDetails().Body
+
+	mediaType := ""
+	if contentType := headers.Get("Content-Type"); contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			l.warn(entry, path+".headers.Content-Type", fmt.Sprintf("'%s' is not a valid MIME type - %s", contentType, err.Error()))
+		}
+	}
+
+	if response.Status >= 300 && response.Status < 400 {
+		if location := headers.Get("Location"); location != "" {
+			if _, err := url.Parse(location); err != nil {
+				l.warn(entry, path+".headers.Location", fmt.Sprintf("'%s' is not a valid URL", location))
+			}
+		}
+	}
+
+	if response.TemplatedBody {
+		return
+	}
+
+	if isJSONMediaType(mediaType) && body != "" && !json.Valid([]byte(body)) {
+		l.warn(entry, path+".body", fmt.Sprintf("body is not valid JSON although Content-Type is %s", mediaType))
+	}
+
+	if contentLength := headers.Get("Content-Length"); contentLength != "" {
+		length, err := strconv.Atoi(contentLength)
+		if err != nil || length < 0 {
+			l.warn(entry, path+".headers.Content-Length", fmt.Sprintf("'%s' is not a valid length", contentLength))
+		} else if length != len(body) {
+			l.warn(entry, path+".headers.Content-Length", fmt.Sprintf("is %d but body is %d bytes long", length, len(body)))
+		}
+	}
+}
+
+// isJSONMediaType - application/json and structured syntax suffix types such as application/problem+json
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// isHeaderName - header names are RFC 7230 tokens
+func isHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			continue
+		}
+		if !strings.ContainsRune("!#$%&'*+-.^_`|~", c) {
+			return false
+		}
+	}
+	return true
+}
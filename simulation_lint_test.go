@@ -0,0 +1,74 @@
+package hoverfly
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+const lintedSimulation = `{
+	"data": [
+		{
+			"request": {"method": "GET", "destination": "example.com", "path": "/"},
+			"response": {"status": 200, "body": "{\"ok\": true}", "headers": {"content-type": ["application/json"], "Content-Length": ["12"]}}
+		},
+		{
+			"request": {"method": "GET", "destination": "example.com", "path": "/broken"},
+			"response": {"status": 700, "body": "{not json", "headers": {"Content-Type": ["application/problem+json"], "Content-Length": ["3"], "Bad Header": ["x"]}}
+		},
+		{
+			"request": {"method": "GET", "destination": "example.com", "path": "/redirect"},
+			"response": {"status": 302, "headers": {"Location": ["http://exa mple.com"], "Content-Type": ["text/html; charset"]}},
+			"responseSequence": [
+				{"status": 42, "body": "{{ .Request.Path }}", "templatedBody": true, "headers": {"Content-Type": ["application/json"]}}
+			]
+		}
+	]
+}`
+
+func TestLintSimulation(t *testing.T) {
+	warnings, err := LintSimulation(strings.NewReader(lintedSimulation))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(warnings), 7)
+
+	testutil.Expect(t, warnings[0], LintWarning{Entry: 1, Field: "data[1].response.status", Message: "status code 700 is not between 100 and 599"})
+	testutil.Expect(t, warnings[1], LintWarning{Entry: 1, Field: "data[1].response.headers", Message: "'Bad Header' is not a valid header name"})
+	testutil.Expect(t, warnings[2], LintWarning{Entry: 1, Field: "data[1].response.body", Message: "body is not valid JSON although Content-Type is application/problem+json"})
+	testutil.Expect(t, warnings[3], LintWarning{Entry: 1, Field: "data[1].response.headers.Content-Length", Message: "is 3 but body is 9 bytes long"})
+	testutil.Expect(t, warnings[4].Field, "data[2].response.headers.Content-Type")
+	testutil.Expect(t, warnings[5], LintWarning{Entry: 2, Field: "data[2].response.headers.Location", Message: "'http://exa mple.com' is not a valid URL"})
+	// templated body isn't checked
+	testutil.Expect(t, warnings[6], LintWarning{Entry: 2, Field: "data[2].responseSequence[0].status", Message: "status code 42 is not between 100 and 599"})
+}
+
+func TestLintSimulationEncodedBody(t *testing.T) {
+	warnings, err := LintSimulation(strings.NewReader(`{"data": [{
+		"request": {"method": "GET", "destination": "example.com"},
+		"response": {"status": 200, "body": "e30=", "encodedBody": true, "headers": {"Content-Type": ["application/json"], "Content-Length": ["2"]}}
+	}]}`))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(warnings), 0)
+}
+
+func TestLintSimulationExport(t *testing.T) {
+	payloadsFile, err := os.Open("examples/exports/readthedocs.json")
+	testutil.Expect(t, err, nil)
+	defer payloadsFile.Close()
+
+	_, err = LintSimulation(payloadsFile)
+	testutil.Expect(t, err, nil)
+}
+
+func TestLintSimulationNotJSON(t *testing.T) {
+	_, err := LintSimulation(strings.NewReader(`not json`))
+	testutil.Refute(t, err, nil)
+}
+
+func TestIsHeaderName(t *testing.T) {
+	testutil.Expect(t, isHeaderName("X-Custom_Header.1"), true)
+	testutil.Expect(t, isHeaderName(""), false)
+	testutil.Expect(t, isHeaderName("Bad Header"), false)
+	testutil.Expect(t, isHeaderName("Bad:Header"), false)
+}
@@ -0,0 +1,230 @@
+package hoverfly
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// simulationSchema - JSON Schema of simulation files, see simulation_schema.json
+//
+//go:embed simulation_schema.json
+var simulationSchema []byte
+
+// ValidationError - simulation entry that doesn't conform to simulation schema. Entry is index of the entry in
+// 'data' array, -1 when error isn't related to a single entry. Field is path to invalid value, i.e.
+// 'data[2].response.status'.
+type ValidationError struct {
+	Entry   int    `json:"entry"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// jsonSchema - subset of JSON Schema draft 07 keywords used by simulation schema
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Type                 jsonSchemaTypes        `json:"type"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinLength            *int                   `json:"minLength"`
+	Definitions          map[string]*jsonSchema `json:"definitions"`
+}
+
+// jsonSchemaTypes - 'type' keyword, either a single type or list of types
+type jsonSchemaTypes []string
+
+func (t *jsonSchemaTypes) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*t = jsonSchemaTypes{single}
+		return nil
+	}
+	var types []string
+	if err := json.Unmarshal(b, &types); err != nil {
+		return err
+	}
+	*t = types
+	return nil
+}
+
+// ValidateSimulation - checks simulation read from given reader against simulation schema and returns errors
+// of every invalid entry. Error is returned only when simulation isn't valid JSON.
+func ValidateSimulation(r io.Reader) ([]ValidationError, error) {
+	var schema jsonSchema
+	if err := json.Unmarshal(simulationSchema, &schema); err != nil {
+		return nil, fmt.Errorf("Failed to load simulation schema, error %s", err.Error())
+	}
+
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	var simulation interface{}
+	if err := decoder.Decode(&simulation); err != nil {
+		return nil, fmt.Errorf("Got error while parsing simulation, error %s", err.Error())
+	}
+
+	v := &schemaValidator{root: &schema}
+	v.validate(&schema, simulation, "")
+	return v.errors, nil
+}
+
+type schemaValidator struct {
+	root   *jsonSchema
+	errors []ValidationError
+}
+
+func (v *schemaValidator) fail(path, message string) {
+	v.errors = append(v.errors, ValidationError{Entry: entryIndex(path), Field: path, Message: message})
+}
+
+func (v *schemaValidator) resolve(schema *jsonSchema) *jsonSchema {
+	for schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/definitions/")
+		schema = v.root.Definitions[name]
+	}
+	return schema
+}
+
+func (v *schemaValidator) validate(schema *jsonSchema, value interface{}, path string) {
+	schema = v.resolve(schema)
+
+	if len(schema.Type) > 0 && !matchesType(schema.Type, value) {
+		v.fail(fieldPath(path), fmt.Sprintf("expected %s, got %s", strings.Join(schema.Type, " or "), jsonType(value)))
+		return
+	}
+
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		v.fail(fieldPath(path), fmt.Sprintf("must be one of %v", schema.Enum))
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := val[name]; !ok {
+				v.fail(fieldPath(joinPath(path, name)), "is required")
+			}
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if property, ok := schema.Properties[k]; ok {
+				v.validate(property, val[k], joinPath(path, k))
+			} else if schema.AdditionalProperties != nil {
+				v.validate(schema.AdditionalProperties, val[k], joinPath(path, k))
+			}
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range val {
+				v.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case json.Number:
+		n, _ := val.Float64()
+		if schema.Minimum != nil && n < *schema.Minimum {
+			v.fail(fieldPath(path), fmt.Sprintf("must be at least %v", *schema.Minimum))
+		}
+		if schema.Maximum != nil && n > *schema.Maximum {
+			v.fail(fieldPath(path), fmt.Sprintf("must be at most %v", *schema.Maximum))
+		}
+	case string:
+		if schema.MinLength != nil && len(val) < *schema.MinLength {
+			v.fail(fieldPath(path), fmt.Sprintf("must be at least %d characters long", *schema.MinLength))
+		}
+	}
+}
+
+func matchesType(types []string, value interface{}) bool {
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(value interface{}) string {
+	switch val := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := val.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func fieldPath(path string) string {
+	if path == "" {
+		return "(root)"
+	}
+	return path
+}
+
+// entryIndex - index of simulation entry given path points into, -1 for paths outside of 'data' entries
+func entryIndex(path string) int {
+	if !strings.HasPrefix(path, "data[") {
+		return -1
+	}
+	end := strings.Index(path, "]")
+	i, err := strconv.Atoi(path[len("data["):end])
+	if err != nil {
+		return -1
+	}
+	return i
+}
+
+// validationFailure - error describing validation errors of imported simulation
+func validationFailure(errs []ValidationError) error {
+	var buf bytes.Buffer
+	for i, err := range errs {
+		if i > 0 {
+			buf.WriteString("; ")
+		}
+		buf.WriteString(err.Error())
+	}
+	return fmt.Errorf("Simulation is not valid, %d error(s): %s", len(errs), buf.String())
+}
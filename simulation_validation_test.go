@@ -0,0 +1,76 @@
+package hoverfly
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+const invalidSimulation = `{
+	"data": [
+		{
+			"request": {"method": "GET", "destination": "example.com", "path": "/"},
+			"response": {"status": 200, "body": "ok"}
+		},
+		{
+			"request": {"method": "GET", "path": "/missing"},
+			"response": {"status": "200", "headers": {"Content-Type": "text/plain"}}
+		},
+		{
+			"request": {"method": "POST", "destination": "example.com"},
+			"response": {"status": 1200},
+			"maxUseCount": -1
+		}
+	]
+}`
+
+func TestValidateSimulation(t *testing.T) {
+	errs, err := ValidateSimulation(strings.NewReader(invalidSimulation))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(errs), 5)
+
+	testutil.Expect(t, errs[0], ValidationError{Entry: 1, Field: "data[1].request.destination", Message: "is required"})
+	testutil.Expect(t, errs[1].Field, "data[1].response.headers.Content-Type")
+	testutil.Expect(t, errs[2], ValidationError{Entry: 1, Field: "data[1].response.status", Message: "expected integer, got string"})
+	testutil.Expect(t, errs[3], ValidationError{Entry: 2, Field: "data[2].maxUseCount", Message: "must be at least 0"})
+	testutil.Expect(t, errs[4], ValidationError{Entry: 2, Field: "data[2].response.status", Message: "must be at most 599"})
+}
+
+func TestValidateSimulationExport(t *testing.T) {
+	payloadsFile, err := os.Open("examples/exports/readthedocs.json")
+	testutil.Expect(t, err, nil)
+	defer payloadsFile.Close()
+
+	errs, err := ValidateSimulation(payloadsFile)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(errs), 0)
+}
+
+func TestValidateSimulationMissingData(t *testing.T) {
+	errs, err := ValidateSimulation(strings.NewReader(`{"payloads": []}`))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(errs), 1)
+	testutil.Expect(t, errs[0], ValidationError{Entry: -1, Field: "data", Message: "is required"})
+}
+
+func TestValidateSimulationNotJSON(t *testing.T) {
+	_, err := ValidateSimulation(strings.NewReader(`not json`))
+	testutil.Refute(t, err, nil)
+}
+
+func TestImportSimulationInvalid(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	count, err := dbClient.ImportSimulation(strings.NewReader(invalidSimulation))
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, count, 0)
+
+	// valid entries aren't imported either
+	records, err := dbClient.RequestCache.GetAllValues()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(records), 0)
+}
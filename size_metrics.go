@@ -0,0 +1,54 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// countingBody - request body that counts bytes read from it
+type countingBody struct {
+	io.ReadCloser
+	contentLength int64
+	read          int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+// size - bytes read from the body, declared Content-Length when body wasn't read
+func (b *countingBody) size() int64 {
+	if b.read == 0 && b.contentLength > 0 {
+		return b.contentLength
+	}
+	return b.read
+}
+
+// countRequestBody - replaces request body with one that counts bytes read while request is processed, requests
+// without body are left as they are so that transport still knows they don't have any
+func countRequestBody(req *http.Request) *countingBody {
+	body := &countingBody{contentLength: req.ContentLength}
+	if req.Body != nil && req.Body != http.NoBody {
+		body.ReadCloser = req.Body
+		req.Body = body
+	}
+	return body
+}
+
+// SizeMetricsHandler - returns min, max and percentiles of request and response body sizes by mode
+func (d *Hoverfly) SizeMetricsHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	b, err := json.Marshal(d.Sizes.Summary())
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
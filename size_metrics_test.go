@@ -0,0 +1,54 @@
+package hoverfly
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/metrics"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestProcessRequestRecordsSizes(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.SetMode(CaptureMode)
+
+	req, err := http.NewRequest("POST", "http://example.com/sizes", strings.NewReader("twelve bytes"))
+	testutil.Expect(t, err, nil)
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+
+	summary := dbClient.Sizes.Summary()
+	testutil.Expect(t, summary.Requests[CaptureMode].Count, int64(1))
+	testutil.Expect(t, summary.Requests[CaptureMode].Max, int64(len("twelve bytes")))
+	testutil.Expect(t, resp.ContentLength > 0, true)
+	testutil.Expect(t, summary.Responses[CaptureMode].Max, resp.ContentLength)
+}
+
+func TestSizeMetricsHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	dbClient.Sizes.RecordResponseSize(SimulateMode, 2048)
+
+	req, err := http.NewRequest("GET", "/api/metrics/sizes", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+	testutil.Expect(t, rec.Header().Get("Content-Type"), "application/json")
+
+	var summary metrics.SizeSummary
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &summary), nil)
+	stats := summary.Responses[SimulateMode]
+	testutil.Expect(t, stats.Count, int64(1))
+	testutil.Expect(t, stats.Min, int64(2048))
+	testutil.Expect(t, stats.P50, int64(2048))
+	testutil.Expect(t, len(summary.Requests), 0)
+}
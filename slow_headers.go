@@ -0,0 +1,101 @@
+package hoverfly
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// slowHeadersHandler - delays headers of simulated responses, status line is sent to the client straight away
+type slowHeadersHandler struct {
+	handler http.Handler
+	cfg     *Configuration
+}
+
+func (h *slowHeadersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !h.cfg.SlowHeaders || !ok || r.Method == "CONNECT" || requestMode(r, h.cfg) != SimulateMode {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+
+	sw := &slowHeadersWriter{
+		w:        w,
+		hijacker: hijacker,
+		header:   make(http.Header),
+		delay:    time.Duration(h.cfg.SlowHeadersDelayMs) * time.Millisecond,
+	}
+	defer sw.close()
+
+	h.handler.ServeHTTP(sw, r)
+}
+
+// slowHeadersWriter - writes response directly to hijacked client connection, sleeping between status line
+// and headers. Connection is closed once response is written since body length might be unknown.
+type slowHeadersWriter struct {
+	w        http.ResponseWriter
+	hijacker http.Hijacker
+	header   http.Header
+	delay    time.Duration
+
+	conn        net.Conn
+	buf         *bufio.ReadWriter
+	wroteHeader bool
+}
+
+func (sw *slowHeadersWriter) Header() http.Header {
+	return sw.header
+}
+
+func (sw *slowHeadersWriter) WriteHeader(code int) {
+	if sw.wroteHeader {
+		return
+	}
+	sw.wroteHeader = true
+
+	conn, buf, err := sw.hijacker.Hijack()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("Failed to hijack connection, headers will not be delayed")
+
+		for k, v := range sw.header {
+			sw.w.Header()[k] = v
+		}
+		sw.w.WriteHeader(code)
+		return
+	}
+	sw.conn, sw.buf = conn, buf
+
+	fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\n", code, http.StatusText(code))
+	buf.Flush()
+
+	time.Sleep(sw.delay)
+
+	sw.header.Set("Connection", "close")
+	sw.header.Write(buf)
+	buf.WriteString("\r\n")
+	buf.Flush()
+}
+
+func (sw *slowHeadersWriter) Write(b []byte) (int, error) {
+	if !sw.wroteHeader {
+		sw.WriteHeader(http.StatusOK)
+	}
+	if sw.buf == nil {
+		return sw.w.Write(b)
+	}
+	return sw.buf.Write(b)
+}
+
+func (sw *slowHeadersWriter) close() {
+	if sw.conn == nil {
+		return
+	}
+	sw.buf.Flush()
+	sw.conn.Close()
+}
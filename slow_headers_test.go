@@ -0,0 +1,92 @@
+package hoverfly
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func slowHeadersServer(cfg *Configuration) *httptest.Server {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "slow")
+		w.WriteHeader(201)
+		fmt.Fprint(w, "slow body")
+	})
+	return httptest.NewServer(&slowHeadersHandler{handler: handler, cfg: cfg})
+}
+
+func TestSlowHeaders(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetMode(SimulateMode)
+	cfg.SlowHeaders = true
+	cfg.SlowHeadersDelayMs = 100
+
+	server := slowHeadersServer(cfg)
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	testutil.Expect(t, err, nil)
+	defer conn.Close()
+
+	fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+
+	reader := bufio.NewReader(conn)
+	statusLine, err := reader.ReadString('\n')
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, statusLine, "HTTP/1.1 201 Created\r\n")
+
+	start := time.Now()
+	resp, err := http.ReadResponse(bufio.NewReader(&prefixReader{prefix: statusLine, reader: reader}), nil)
+	testutil.Expect(t, err, nil)
+
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("headers were not delayed, got them after %s", elapsed)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, 201)
+	testutil.Expect(t, resp.Header.Get("X-Test"), "slow")
+	testutil.Expect(t, string(body), "slow body")
+}
+
+func TestSlowHeadersOnlyInSimulateMode(t *testing.T) {
+	cfg := InitSettings()
+	cfg.SetMode(CaptureMode)
+	cfg.SlowHeaders = true
+	cfg.SlowHeadersDelayMs = 1000
+
+	server := slowHeadersServer(cfg)
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := http.Get(server.URL)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, 201)
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("response was delayed in capture mode by %s", elapsed)
+	}
+}
+
+// prefixReader - returns already consumed prefix before reading the rest
+type prefixReader struct {
+	prefix string
+	reader *bufio.Reader
+}
+
+func (r *prefixReader) Read(p []byte) (int, error) {
+	if r.prefix != "" {
+		n := copy(p, r.prefix)
+		r.prefix = r.prefix[n:]
+		return n, nil
+	}
+	return r.reader.Read(p)
+}
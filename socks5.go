@@ -0,0 +1,283 @@
+package hoverfly
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rusenask/goproxy"
+)
+
+// SOCKS5 protocol values (RFC 1928, RFC 1929)
+const (
+	socks5Version          = 0x05
+	socks5MethodNoAuth     = 0x00
+	socks5MethodUserPass   = 0x02
+	socks5MethodNoneUsable = 0xff
+	socks5CmdConnect       = 0x01
+	socks5AddrIPv4         = 0x01
+	socks5AddrDomain       = 0x03
+	socks5AddrIPv6         = 0x04
+	socks5UserPassVersion  = 0x01
+)
+
+// socks5Dialer - opens upstream connections through SOCKS5 proxy, destination host names are resolved by the proxy
+type socks5Dialer struct {
+	address  string
+	username string
+	password string
+	forward  *net.Dialer
+}
+
+// newSOCKS5Dialer - proxy is 'host:port' or 'socks5://[user:password@]host:port'
+func newSOCKS5Dialer(proxy string) (*socks5Dialer, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = "socks5://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOCKS5 proxy '%s': %s", proxy, err.Error())
+	}
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+		return nil, fmt.Errorf("invalid SOCKS5 proxy '%s': unsupported scheme '%s'", proxy, u.Scheme)
+	}
+	if _, port, err := net.SplitHostPort(u.Host); err != nil || port == "" {
+		return nil, fmt.Errorf("invalid SOCKS5 proxy '%s': address should be 'host:port'", proxy)
+	}
+
+	d := &socks5Dialer{
+		address: u.Host,
+		forward: &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+	}
+	if u.User != nil {
+		d.username = u.User.Username()
+		d.password, _ = u.User.Password()
+	}
+	return d, nil
+}
+
+// Dial - connects to address through proxy
+func (d *socks5Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialContext - connects to address through proxy, handshake is aborted when context is done
+func (d *socks5Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil, fmt.Errorf("SOCKS5 proxy doesn't support network '%s'", network)
+	}
+
+	conn, err := d.forward.DialContext(ctx, "tcp", d.address)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			// unblocks handshake
+			conn.SetDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	err = d.handshake(conn, address)
+	close(done)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SOCKS5 proxy %s: %s", d.address, err.Error())
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// handshake - negotiates authentication and sends CONNECT command
+func (d *socks5Dialer) handshake(conn net.Conn, address string) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 0xffff {
+		return fmt.Errorf("invalid port in '%s'", address)
+	}
+
+	methods := []byte{socks5MethodNoAuth}
+	if d.username != "" {
+		methods = append(methods, socks5MethodUserPass)
+	}
+	if _, err := conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("unexpected protocol version %d", reply[0])
+	}
+
+	switch reply[1] {
+	case socks5MethodNoAuth:
+	case socks5MethodUserPass:
+		if err := d.authenticate(conn); err != nil {
+			return err
+		}
+	case socks5MethodNoneUsable:
+		return errors.New("no acceptable authentication methods")
+	default:
+		return fmt.Errorf("unsupported authentication method %d", reply[1])
+	}
+
+	req := []byte{socks5Version, socks5CmdConnect, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(append(req, socks5AddrIPv4), ip4...)
+		} else {
+			req = append(append(req, socks5AddrIPv6), ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("host name '%s' is too long", host)
+		}
+		req = append(append(req, socks5AddrDomain, byte(len(host))), host...)
+	}
+	req = append(req, 0, 0)
+	binary.BigEndian.PutUint16(req[len(req)-2:], uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// version, reply, reserved, address type
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[1] != 0x00 {
+		return fmt.Errorf("connect to %s failed with reply code %d", address, header[1])
+	}
+
+	// bound address isn't used
+	var skip int
+	switch header[3] {
+	case socks5AddrIPv4:
+		skip = net.IPv4len
+	case socks5AddrIPv6:
+		skip = net.IPv6len
+	case socks5AddrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		skip = int(length[0])
+	default:
+		return fmt.Errorf("unexpected address type %d", header[3])
+	}
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
+
+// authenticate - username/password authentication (RFC 1929)
+func (d *socks5Dialer) authenticate(conn net.Conn) error {
+	if len(d.username) > 255 || len(d.password) > 255 {
+		return errors.New("username and password can't be longer than 255 bytes")
+	}
+	req := []byte{socks5UserPassVersion, byte(len(d.username))}
+	req = append(req, d.username...)
+	req = append(req, byte(len(d.password)))
+	req = append(req, d.password...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[1] != 0x00 {
+		return errors.New("username/password authentication failed")
+	}
+	return nil
+}
+
+// validateUpstreamSOCKS5Proxy - checks SOCKS5 proxy address, empty address is valid
+func validateUpstreamSOCKS5Proxy(proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+	_, err := newSOCKS5Dialer(proxy)
+	return err
+}
+
+// applyUpstreamSOCKS5Proxy - routes upstream connections of HTTP client and of CONNECT tunnels that aren't
+// intercepted through configured SOCKS5 proxy, connections are direct again once proxy is removed from configuration
+func (d *Hoverfly) applyUpstreamSOCKS5Proxy(proxy *goproxy.ProxyHttpServer) {
+	if d.Cfg.UpstreamSOCKS5Proxy == "" && d.socks5Proxy == "" {
+		return
+	}
+
+	transport, ok := d.httpTransport()
+	if !ok {
+		log.Warn("HTTP client doesn't use http.Transport, upstream SOCKS5 proxy can't be applied")
+		return
+	}
+
+	if d.Cfg.UpstreamSOCKS5Proxy == "" {
+		transport.DialContext = nil
+		d.socks5Proxy = ""
+		d.dnsDialer = nil
+		return
+	}
+
+	dialer, err := newSOCKS5Dialer(d.Cfg.UpstreamSOCKS5Proxy)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to apply upstream SOCKS5 proxy")
+		return
+	}
+
+	// idle connections were opened without proxy
+	transport.CloseIdleConnections()
+	transport.DialContext = dialer.DialContext
+	proxy.ConnectDial = dialer.Dial
+	d.socks5Proxy = d.Cfg.UpstreamSOCKS5Proxy
+	d.dnsDialer = nil
+
+	log.WithFields(log.Fields{
+		"proxy": dialer.address,
+	}).Info("upstream connections go through SOCKS5 proxy")
+}
+
+// dialUpstream - opens upstream connection, through SOCKS5 proxy when it is configured. Simulated DNS failures
+// apply to it as well.
+func (d *Hoverfly) dialUpstream(network, address string) (net.Conn, error) {
+	if err := d.Cfg.dnsFailure(address); err != nil {
+		return nil, err
+	}
+	if d.Cfg.UpstreamSOCKS5Proxy == "" {
+		return net.Dial(network, address)
+	}
+	dialer, err := newSOCKS5Dialer(d.Cfg.UpstreamSOCKS5Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return dialer.Dial(network, address)
+}
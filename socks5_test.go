@@ -0,0 +1,214 @@
+package hoverfly
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// socks5Server - minimal SOCKS5 server for tests, remembers destinations of CONNECT commands. Username/password
+// authentication is required when username is set.
+type socks5Server struct {
+	listener net.Listener
+	username string
+	password string
+
+	mu           sync.Mutex
+	destinations []string
+}
+
+func newSOCKS5Server(t *testing.T, username, password string) *socks5Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	testutil.Expect(t, err, nil)
+
+	s := &socks5Server{listener: l, username: username, password: password}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *socks5Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *socks5Server) Close() {
+	s.listener.Close()
+}
+
+func (s *socks5Server) Destinations() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.destinations...)
+}
+
+func (s *socks5Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return
+	}
+	methods := make([]byte, header[1])
+	io.ReadFull(conn, methods)
+
+	if s.username == "" {
+		conn.Write([]byte{5, 0})
+	} else {
+		conn.Write([]byte{5, 2})
+		auth := make([]byte, 2)
+		io.ReadFull(conn, auth)
+		user := make([]byte, auth[1])
+		io.ReadFull(conn, user)
+		passLen := make([]byte, 1)
+		io.ReadFull(conn, passLen)
+		pass := make([]byte, passLen[0])
+		io.ReadFull(conn, pass)
+		if string(user) != s.username || string(pass) != s.password {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+	}
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		length := make([]byte, 1)
+		io.ReadFull(conn, length)
+		name := make([]byte, length[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	default:
+		return
+	}
+	portBytes := make([]byte, 2)
+	io.ReadFull(conn, portBytes)
+	destination := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(portBytes))))
+
+	remote, err := net.Dial("tcp", destination)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer remote.Close()
+
+	s.mu.Lock()
+	s.destinations = append(s.destinations, destination)
+	s.mu.Unlock()
+
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	go io.Copy(remote, conn)
+	io.Copy(conn, remote)
+}
+
+func socks5Hoverfly(t *testing.T, proxy string) *Hoverfly {
+	cfg := InitSettings()
+	cfg.UpstreamSOCKS5Proxy = proxy
+	dbClient, err := NewHoverfly(WithConfiguration(cfg))
+	testutil.Expect(t, err, nil)
+	return dbClient
+}
+
+func TestUpstreamSOCKS5ProxyHTTP(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("through socks"))
+	}))
+	defer upstream.Close()
+
+	socks := newSOCKS5Server(t, "", "")
+	defer socks.Close()
+
+	dbClient := socks5Hoverfly(t, socks.Addr())
+
+	resp, err := dbClient.HTTP.Get(upstream.URL)
+	testutil.Expect(t, err, nil)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	testutil.Expect(t, string(body), "through socks")
+
+	u, _ := url.Parse(upstream.URL)
+	destinations := socks.Destinations()
+	testutil.Expect(t, len(destinations), 1)
+	testutil.Expect(t, destinations[0], u.Host)
+}
+
+func TestUpstreamSOCKS5ProxyHTTPS(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tls through socks"))
+	}))
+	defer upstream.Close()
+
+	socks := newSOCKS5Server(t, "user", "secret")
+	defer socks.Close()
+
+	dbClient := socks5Hoverfly(t, fmt.Sprintf("socks5://user:secret@%s", socks.Addr()))
+
+	resp, err := dbClient.HTTP.Get(upstream.URL)
+	testutil.Expect(t, err, nil)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	testutil.Expect(t, string(body), "tls through socks")
+	testutil.Expect(t, len(socks.Destinations()), 1)
+}
+
+func TestUpstreamSOCKS5ProxyWrongCredentials(t *testing.T) {
+	socks := newSOCKS5Server(t, "user", "secret")
+	defer socks.Close()
+
+	dbClient := socks5Hoverfly(t, fmt.Sprintf("socks5://user:wrong@%s", socks.Addr()))
+
+	_, err := dbClient.HTTP.Get("http://example.com")
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, len(socks.Destinations()), 0)
+}
+
+func TestUpdateProxyRemovesUpstreamSOCKS5Proxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	socks := newSOCKS5Server(t, "", "")
+	defer socks.Close()
+
+	dbClient := socks5Hoverfly(t, socks.Addr())
+	dbClient.Cfg.UpstreamSOCKS5Proxy = ""
+	dbClient.UpdateProxy()
+
+	resp, err := dbClient.HTTP.Get(upstream.URL)
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+	testutil.Expect(t, len(socks.Destinations()), 0)
+}
+
+func TestNewHoverflyInvalidUpstreamSOCKS5Proxy(t *testing.T) {
+	for _, proxy := range []string{"localhost", "http://localhost:1080", "socks5://"} {
+		cfg := InitSettings()
+		cfg.UpstreamSOCKS5Proxy = proxy
+		_, err := NewHoverfly(WithConfiguration(cfg))
+		testutil.Refute(t, err, nil)
+	}
+}
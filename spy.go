@@ -0,0 +1,69 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// spyRequest - forwards request to original destination and returns upstream response unchanged, request and
+// response are saved to cache as in capture mode. Middleware is not applied and failing to save the pair doesn't
+// affect the response.
+func (d *Hoverfly) spyRequest(req *http.Request) (*http.Response, error) {
+	cfg := d.requestConfig(req)
+	if req.Body == nil {
+		req.Body = ioutil.NopCloser(bytes.NewBuffer([]byte("")))
+	}
+
+	reqBody, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"mode":  SpyMode,
+		}).Error("Got error when reading request body")
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+
+	// We can't have this set. And it only contains "/pkg/net/http/" anyway
+	req.RequestURI = ""
+
+	end := startOperation(req, OperationUpstream)
+	resp, err := d.HTTP.Do(req)
+	end(err)
+
+	if err != nil {
+		log.WithFields(log.Fields{
+			"mode":   SpyMode,
+			"error":  err.Error(),
+			"host":   req.Host,
+			"method": req.Method,
+			"path":   req.URL.Path,
+		}).Error("could not forward request, failed to do an HTTP request.")
+		if tooMany, ok := tooManyRedirects(err); ok {
+			return nil, withErrorCode(ErrorCodeTooManyRedirects, tooMany)
+		}
+		return nil, withErrorCode(ErrorCodeUpstreamUnreachable, err)
+	}
+
+	respBody, err := extractBody(resp)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"mode":  SpyMode,
+		}).Error("Failed to copy response body, request was not captured")
+		return resp, nil
+	}
+
+	// stored copy doesn't keep credentials, forwarded request and returned response are left untouched
+	stored := *req
+	stored.Header = cloneHeader(req.Header)
+	if cfg.StripAuthHeaders && stripAuthHeaders(stored.Header) {
+		stored.Header.Set("Authorization", RedactedAuthorization)
+	}
+
+	d.save(&stored, reqBody, resp, respBody)
+
+	return resp, nil
+}
@@ -0,0 +1,68 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestSpyModeReturnsUpstreamResponseAndCaptures(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream", "yes")
+		w.WriteHeader(201)
+		w.Write([]byte("live body"))
+	}))
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.HTTP = &http.Client{}
+	// middleware is not applied in spy mode
+	dbClient.Cfg.Middleware = "./examples/middleware/modify_request/modify_request.py"
+	dbClient.Cfg.SetMode(SpyMode)
+
+	req, err := http.NewRequest("GET", upstream.URL+"/items", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, 201)
+	testutil.Expect(t, resp.Header.Get("X-Upstream"), "yes")
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "live body")
+
+	count, err := dbClient.RequestCache.RecordsCount()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, count, 1)
+
+	// captured pair can be simulated afterwards
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.Middleware = ""
+	req, err = http.NewRequest("GET", upstream.URL+"/items", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp = dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, 201)
+}
+
+func TestSpyModeUpstreamUnreachable(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.SetMode(SpyMode)
+
+	req, err := http.NewRequest("GET", "http://127.0.0.1:1/", nil)
+	testutil.Expect(t, err, nil)
+
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusServiceUnavailable)
+	testutil.Expect(t, resp.Header.Get(ErrorCodeHeader), string(ErrorCodeUpstreamUnreachable))
+}
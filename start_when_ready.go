@@ -0,0 +1,72 @@
+package hoverfly
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// errStartSignalClosed - start signal was closed instead of sending a value
+var errStartSignalClosed = errors.New("start signal was closed, proxy and admin interface were not started")
+
+// StartWhenReady - prepares proxy and admin interface, their ports are bound only once a value is received on
+// signal so that i.e. tests can populate cache before any client can connect. Blocks until signal is received,
+// returns error without binding anything when signal is closed. Both are stopped with StopProxy.
+func (d *Hoverfly) StartWhenReady(signal <-chan struct{}) error {
+	if d.Cfg.ProxyPort == "" {
+		return fmt.Errorf("Proxy port is not set!")
+	}
+	if d.Cfg.AdminPort == "" {
+		return fmt.Errorf("Admin port is not set!")
+	}
+	if d.Cfg.AdminTLS {
+		if err := d.Cfg.EnsureAdminTLSCertificate(); err != nil {
+			return fmt.Errorf("failed to prepare admin TLS certificate: %s", err.Error())
+		}
+	}
+
+	d.UpdateProxy()
+	admin := &http.Server{Handler: d.adminHandler()}
+
+	log.WithFields(log.Fields{
+		"port":      d.Cfg.ProxyPort,
+		"AdminPort": d.Cfg.AdminPort,
+	}).Info("proxy and admin interface are waiting for start signal")
+
+	if _, ok := <-signal; !ok {
+		return errStartSignalClosed
+	}
+
+	if err := d.StartProxy(); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", d.Cfg.AdminPort))
+	if err == nil {
+		d.adminListener, err = NewStoppableListener(listener)
+	}
+	if err != nil {
+		d.StopProxy()
+		return fmt.Errorf("failed to start admin interface: %s", err.Error())
+	}
+	sl := d.adminListener
+
+	d.Cfg.ProxyControlWG.Add(1)
+	go func() {
+		defer d.Cfg.ProxyControlWG.Done()
+		log.WithFields(log.Fields{
+			"AdminPort": d.Cfg.AdminPort,
+			"tls":       d.Cfg.AdminTLS,
+		}).Info("Admin interface is starting...")
+
+		if d.Cfg.AdminTLS {
+			log.Warn(admin.ServeTLS(sl, d.Cfg.AdminTLSCertFile, d.Cfg.AdminTLSKeyFile))
+		} else {
+			log.Warn(admin.Serve(sl))
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,104 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// portIsOpen - something accepts connections on local port
+func portIsOpen(port string) bool {
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:"+port, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func TestStartWhenReadyBindsPortsOnSignal(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.Cfg.ProxyPort = "9791"
+	dbClient.Cfg.AdminPort = "9792"
+	dbClient.Cfg.SetMode(SimulateMode)
+
+	signal := make(chan struct{})
+	started := make(chan error, 1)
+	go func() {
+		started <- dbClient.StartWhenReady(signal)
+	}()
+
+	// nobody can connect while cache is populated
+	time.Sleep(100 * time.Millisecond)
+	testutil.Expect(t, portIsOpen("9791"), false)
+	testutil.Expect(t, portIsOpen("9792"), false)
+
+	err := dbClient.ImportPayloads([]models.PayloadView{{
+		Request:  models.RequestDetailsView{Method: "GET", Scheme: "http", Destination: "ready.example.com", Path: "/"},
+		Response: models.ResponseDetailsView{Status: 201, Body: "populated"},
+	}})
+	testutil.Expect(t, err, nil)
+
+	signal <- struct{}{}
+	testutil.Expect(t, <-started, nil)
+	defer dbClient.StopProxy()
+
+	testutil.Expect(t, proxiedStatus(t, "9791", "http://ready.example.com/"), http.StatusCreated)
+
+	resp, err := http.Get("http://127.0.0.1:9792/api/health")
+	testutil.Expect(t, err, nil)
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+}
+
+func TestStartWhenReadyClosedSignal(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.ProxyPort = "9793"
+	dbClient.Cfg.AdminPort = "9794"
+
+	signal := make(chan struct{})
+	close(signal)
+	testutil.Expect(t, dbClient.StartWhenReady(signal), errStartSignalClosed)
+
+	testutil.Expect(t, portIsOpen("9793"), false)
+	testutil.Expect(t, portIsOpen("9794"), false)
+}
+
+func TestStartWhenReadyNeedsPorts(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.ProxyPort = ""
+
+	testutil.Refute(t, dbClient.StartWhenReady(make(chan struct{})), nil)
+}
+
+func TestStartWhenReadyStopsProxyWhenAdminPortIsTaken(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.ProxyPort = "9795"
+	dbClient.Cfg.AdminPort = "9796"
+
+	taken, err := net.Listen("tcp", ":9796")
+	testutil.Expect(t, err, nil)
+	defer taken.Close()
+
+	signal := make(chan struct{}, 1)
+	signal <- struct{}{}
+	testutil.Refute(t, dbClient.StartWhenReady(signal), nil)
+
+	// proxy listener was stopped
+	deadline := time.Now().Add(3 * time.Second)
+	for portIsOpen("9795") && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	testutil.Expect(t, portIsOpen("9795"), false)
+}
@@ -0,0 +1,297 @@
+package hoverfly
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// spooledBodyPrefix - name prefix of request body files created by spoolRequestBody
+const spooledBodyPrefix = "hoverfly_body_"
+
+// spooledBody - request body written to a file while its SHA-256 hash is calculated
+type spooledBody struct {
+	path string
+	size int64
+	hash string
+}
+
+// spoolRequestBody - copies body to a new file in given directory chunk by chunk, system temp directory is used
+// when dir is empty
+func spoolRequestBody(body io.Reader, dir string) (*spooledBody, error) {
+	f, err := ioutil.TempFile(dir, spooledBodyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(f, h), body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	return &spooledBody{path: f.Name(), size: n, hash: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+func (s *spooledBody) remove() {
+	os.Remove(s.path)
+}
+
+// ownsRequestBodyFile - checks whether body file was spooled by Hoverfly, files referenced by imported records
+// belong to the user and are never removed
+func ownsRequestBodyFile(path string) bool {
+	return path != "" && strings.HasPrefix(filepath.Base(path), spooledBodyPrefix)
+}
+
+// removeRequestBodyFile - removes spooled body file of a record that is deleted or overwritten
+func removeRequestBodyFile(path string) {
+	if !ownsRequestBodyFile(path) {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"file":  path,
+		}).Warn("Failed to remove request body file")
+	}
+}
+
+// copyRequestBodyFile - copies spooled body file next to the original so that every record owns its file
+func copyRequestBodyFile(path string) (string, error) {
+	if !ownsRequestBodyFile(path) {
+		return path, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	spooled, err := spoolRequestBody(f, filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	return spooled.path, nil
+}
+
+// removeReplacedBodyFile - removes body file of the record stored under key before the record is deleted or
+// overwritten by a record with different body file
+func (d *Hoverfly) removeReplacedBodyFile(key, replacement string) {
+	bts, err := d.RequestCache.Get([]byte(key))
+	if err != nil {
+		return
+	}
+	payload, err := models.NewPayloadFromBytes(bts)
+	if err != nil || payload.RequestBodyFile == replacement {
+		return
+	}
+	removeRequestBodyFile(payload.RequestBodyFile)
+}
+
+// deleteAllRecords - wipes request cache together with spooled body files of its records
+func (d *Hoverfly) deleteAllRecords() error {
+	if values, err := d.RequestCache.GetAllValues(); err == nil {
+		for _, bts := range values {
+			if payload, err := models.NewPayloadFromBytes(bts); err == nil {
+				removeRequestBodyFile(payload.RequestBodyFile)
+			}
+		}
+	}
+	return d.RequestCache.DeleteData()
+}
+
+// sameContents - compares two files chunk by chunk
+func sameContents(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	sa, err := fa.Stat()
+	if err != nil {
+		return false, err
+	}
+	sb, err := fb.Stat()
+	if err != nil {
+		return false, err
+	}
+	if sa.Size() != sb.Size() {
+		return false, nil
+	}
+
+	ra, rb := bufio.NewReader(fa), bufio.NewReader(fb)
+	chunkA, chunkB := make([]byte, 32*1024), make([]byte, 32*1024)
+
+	for {
+		na, errA := io.ReadFull(ra, chunkA)
+		nb, errB := io.ReadFull(rb, chunkB)
+
+		if !bytes.Equal(chunkA[:na], chunkB[:nb]) {
+			return false, nil
+		}
+
+		doneA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		doneB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if doneA && doneB {
+			return true, nil
+		}
+		if errA != nil && !doneA {
+			return false, errA
+		}
+		if errB != nil && !doneB {
+			return false, errB
+		}
+		if doneA != doneB {
+			return false, nil
+		}
+	}
+}
+
+// validateStreamedBody - checks that incoming body matches captured body file
+func validateStreamedBody(captured, incoming string) error {
+	same, err := sameContents(captured, incoming)
+	if err != nil {
+		return err
+	}
+	if !same {
+		return fmt.Errorf("request body differs from captured body")
+	}
+	return nil
+}
+
+// getStreamedRequestFingerprint - request hash for streamed bodies, body hash is used instead of the body
+func (d *Hoverfly) getStreamedRequestFingerprint(req *http.Request, bodyHash string) string {
+	cfg := d.requestConfig(req)
+	r := models.RequestDetails{
+		Path:        req.URL.Path,
+		Method:      req.Method,
+		Destination: req.Host,
+		Query:       cfg.normaliseQuery(req.URL.RawQuery),
+		Body:        "sha256:" + bodyHash,
+	}
+
+	return r.Hash()
+}
+
+// captureStreamedRequest - forwards request which body is streamed from a file, the file is referenced by cache
+// entry instead of storing the body. Middleware is not applied since it would need the whole body in memory.
+func (d *Hoverfly) captureStreamedRequest(req *http.Request) (*http.Response, error) {
+	cfg := d.requestConfig(req)
+	if req.Body == nil {
+		req.Body = ioutil.NopCloser(bytes.NewBuffer([]byte("")))
+	}
+
+	spooled, err := spoolRequestBody(req.Body, cfg.RequestBodyDir)
+	req.Body.Close()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"mode":  "capture",
+		}).Error("Failed to write request body to file")
+		return nil, err
+	}
+
+	body, err := os.Open(spooled.path)
+	if err != nil {
+		spooled.remove()
+		return nil, err
+	}
+
+	stripped := false
+	if cfg.StripAuthHeaders {
+		stripped = stripAuthHeaders(req.Header)
+	}
+
+	req.RequestURI = ""
+	req.Body = body
+
+	end := startOperation(req, OperationUpstream)
+	resp, err := d.HTTP.Do(req)
+	end(err)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"mode":   cfg.Mode,
+			"error":  err.Error(),
+			"host":   req.Host,
+			"method": req.Method,
+			"path":   req.URL.Path,
+		}).Error("could not forward streamed request, failed to do an HTTP request.")
+		spooled.remove()
+		return nil, withErrorCode(ErrorCodeUpstreamUnreachable, err)
+	}
+	resp.Header.Set("hoverfly", "Was-Here")
+
+	respBody, err := extractBody(resp)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"mode":  "capture",
+		}).Error("Failed to copy response body.")
+		spooled.remove()
+		return resp, err
+	}
+
+	if status := d.rewriteStatus(req, resp.StatusCode, respBody); status != resp.StatusCode {
+		resp.StatusCode = status
+		resp.Status = fmt.Sprintf("%d %s", status, http.StatusText(status))
+	}
+
+	if stripped {
+		req.Header.Set("Authorization", RedactedAuthorization)
+	}
+
+	requestObj := models.RequestDetails{
+		Path:        req.URL.Path,
+		Method:      req.Method,
+		Destination: req.Host,
+		Scheme:      req.URL.Scheme,
+		Query:       req.URL.RawQuery,
+		Headers:     req.Header,
+	}
+
+	storedResp, storedBody := d.decompressedForStorage(resp, respBody)
+	payload := models.Payload{
+		Response: models.ResponseDetails{
+			Status:  storedResp.StatusCode,
+			Body:    string(storedBody),
+			Headers: storedResp.Header,
+		},
+		Request:           requestObj,
+		RequestBodySHA256: spooled.hash,
+		RequestBodyFile:   spooled.path,
+		CreatedAt:         time.Now(),
+	}
+
+	key := d.getStreamedRequestFingerprint(req, spooled.hash)
+	if d.isDuplicateCapture(cfg, key) {
+		spooled.remove()
+		return resp, nil
+	}
+	d.storePayload(cfg, key, payload)
+	d.storeClientIP(key, req)
+
+	return resp, nil
+}
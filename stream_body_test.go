@@ -0,0 +1,152 @@
+package hoverfly
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestSameContents(t *testing.T) {
+	a, err := spoolRequestBody(strings.NewReader(strings.Repeat("a", 100000)), "")
+	testutil.Expect(t, err, nil)
+	defer a.remove()
+
+	b, err := spoolRequestBody(strings.NewReader(strings.Repeat("a", 100000)), "")
+	testutil.Expect(t, err, nil)
+	defer b.remove()
+
+	c, err := spoolRequestBody(strings.NewReader(strings.Repeat("a", 99999)+"b"), "")
+	testutil.Expect(t, err, nil)
+	defer c.remove()
+
+	testutil.Expect(t, a.size, int64(100000))
+	testutil.Expect(t, a.hash, b.hash)
+
+	same, err := sameContents(a.path, b.path)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, same, true)
+
+	same, err = sameContents(a.path, c.path)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, same, false)
+}
+
+func TestStreamedRequestBodyCaptureAndSimulate(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.StreamRequestBody = true
+	body := strings.Repeat("upload chunk ", 10000)
+
+	req, err := http.NewRequest("POST", "http://example.com/upload", ioutil.NopCloser(strings.NewReader(body)))
+	testutil.Expect(t, err, nil)
+
+	resp, err := dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, 201)
+
+	values, err := dbClient.RequestCache.GetAllValues()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(values), 1)
+
+	payload, err := models.NewPayloadFromBytes(values[0])
+	testutil.Expect(t, err, nil)
+	testutil.Refute(t, payload.RequestBodyFile, "")
+	testutil.Expect(t, payload.Request.Body, "")
+	defer os.Remove(payload.RequestBodyFile)
+
+	stored, err := ioutil.ReadFile(payload.RequestBodyFile)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(stored), body)
+
+	// same body is matched
+	req, err = http.NewRequest("POST", "http://example.com/upload", ioutil.NopCloser(strings.NewReader(body)))
+	testutil.Expect(t, err, nil)
+
+	resp = dbClient.getResponse(req)
+	testutil.Expect(t, resp.StatusCode, 201)
+
+	// different body is not
+	req, err = http.NewRequest("POST", "http://example.com/upload", ioutil.NopCloser(strings.NewReader(body+"x")))
+	testutil.Expect(t, err, nil)
+
+	resp = dbClient.getResponse(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+}
+
+func TestStreamedRequestBodyValidatedAgainstFile(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.StreamRequestBody = true
+
+	req, err := http.NewRequest("POST", "http://example.com/upload", bytes.NewBufferString("original"))
+	testutil.Expect(t, err, nil)
+
+	_, err = dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+
+	values, err := dbClient.RequestCache.GetAllValues()
+	testutil.Expect(t, err, nil)
+	payload, err := models.NewPayloadFromBytes(values[0])
+	testutil.Expect(t, err, nil)
+	defer os.Remove(payload.RequestBodyFile)
+
+	// captured file was changed after capture
+	testutil.Expect(t, ioutil.WriteFile(payload.RequestBodyFile, []byte("tampered"), 0644), nil)
+
+	req, err = http.NewRequest("POST", "http://example.com/upload", bytes.NewBufferString("original"))
+	testutil.Expect(t, err, nil)
+
+	resp := dbClient.getResponse(req)
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+	testutil.Expect(t, resp.Header.Get(ErrorCodeHeader), string(ErrorCodeBodyMismatch))
+}
+
+func TestStreamedRequestBodyFileRemovedWithRecord(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.StreamRequestBody = true
+	bodyFile := func() string {
+		values, err := dbClient.RequestCache.GetAllValues()
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, len(values), 1)
+		payload, err := models.NewPayloadFromBytes(values[0])
+		testutil.Expect(t, err, nil)
+		return payload.RequestBodyFile
+	}
+	capture := func() {
+		req, err := http.NewRequest("POST", "http://example.com/upload", bytes.NewBufferString("original"))
+		testutil.Expect(t, err, nil)
+		_, err = dbClient.captureRequest(req)
+		testutil.Expect(t, err, nil)
+	}
+
+	capture()
+	first := bodyFile()
+
+	// recaptured record gets new file, file of the overwritten record is removed
+	capture()
+	second := bodyFile()
+	testutil.Refute(t, second, first)
+	_, err := os.Stat(first)
+	testutil.Expect(t, os.IsNotExist(err), true)
+
+	req, err := http.NewRequest("DELETE", "/api/records", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	getBoneRouter(*dbClient).ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	_, err = os.Stat(second)
+	testutil.Expect(t, os.IsNotExist(err), true)
+}
@@ -0,0 +1,225 @@
+package hoverfly
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// streamsMiddleware - only middleware commands started for the request can stream their output, plugin, Lua,
+// socket and pooled middleware produce payloads
+func streamsMiddleware(middleware string) bool {
+	if middleware == "" ||
+		strings.HasPrefix(middleware, middlewarePluginPrefix) ||
+		strings.HasPrefix(middleware, luaMiddlewarePrefix) ||
+		strings.HasPrefix(middleware, middlewareSocketPrefix) {
+		return false
+	}
+	_, pooled := pooledMiddleware(middleware)
+	return !pooled
+}
+
+// synthesizeStreaming - starts middleware chain with request payload JSON on stdin and returns response with status
+// 200 that has stdout of the last command as its body. Body length isn't known so Content-Length isn't set and
+// body is sent chunked. Payload isn't compressed and output isn't limited, MiddlewareTimeout is the longest time
+// body can be streamed for. Middleware errors after the response was started are only logged.
+func synthesizeStreaming(req *http.Request, middleware string, limits middlewareLimits, chunkSize int) (*http.Response, error) {
+	payload, err := synthesizePayload(req)
+	if err != nil {
+		return nil, err
+	}
+
+	bts, err := json.Marshal(payload.ConvertToPayloadView())
+	if err != nil {
+		return nil, err
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if limits.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), limits.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	mws := strings.Split(middleware, "|")
+	stream := &synthesisStream{chunkSize: chunkSize, cancel: cancel, middleware: mws, stderr: make([]bytes.Buffer, len(mws))}
+	for i, v := range mws {
+		commands := strings.Split(strings.TrimSpace(v), " ")
+		cmd := middlewareCommand(ctx, commands[0], commands[1:]...)
+		cmd.Stderr = &stream.stderr[i]
+		stream.cmds = append(stream.cmds, cmd)
+	}
+	stream.cmds[0].Stdin = bytes.NewReader(bts)
+
+	last := len(stream.cmds) - 1
+	for i, cmd := range stream.cmds[:last] {
+		if stream.cmds[i+1].Stdin, err = cmd.StdoutPipe(); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	stdout, err := stream.cmds[last].StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stream.reader = bufio.NewReader(stdout)
+
+	for i, cmd := range stream.cmds {
+		if err := cmd.Start(); err != nil {
+			stream.started = i
+			stream.Close()
+			return nil, withErrorCode(ErrorCodeMiddlewareFailed, err)
+		}
+	}
+	stream.started = len(stream.cmds)
+
+	log.WithFields(log.Fields{
+		"middleware":  middleware,
+		"destination": payload.Request.Destination,
+		"chunkSize":   chunkSize,
+	}).Debug("Streaming synthesized response from middleware")
+
+	return &http.Response{
+		Status:           "200 OK",
+		StatusCode:       http.StatusOK,
+		Proto:            "HTTP/1.1",
+		ProtoMajor:       1,
+		ProtoMinor:       1,
+		Header:           http.Header{},
+		Body:             stream,
+		ContentLength:    -1,
+		TransferEncoding: []string{"chunked"},
+		Request:          req,
+	}, nil
+}
+
+// synthesisStream - body of streamed synthesized response, every read returns at most one line of middleware
+// output (or chunkSize bytes) so that each of them is written and flushed separately
+type synthesisStream struct {
+	reader     *bufio.Reader
+	chunkSize  int
+	pending    []byte
+	err        error
+	cmds       []*exec.Cmd
+	started    int
+	middleware []string
+	cancel     context.CancelFunc
+	// stderr - stderr of every command, exec copies it from a separate goroutine for each of them
+	stderr    []bytes.Buffer
+	closeOnce sync.Once
+}
+
+func (s *synthesisStream) Read(p []byte) (int, error) {
+	if len(s.pending) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		s.pending, s.err = s.next()
+		if len(s.pending) == 0 {
+			return 0, s.err
+		}
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+// next - next line or chunk of output, it is only valid until next is called again
+func (s *synthesisStream) next() ([]byte, error) {
+	if s.chunkSize > 0 {
+		chunk := make([]byte, s.chunkSize)
+		n, err := io.ReadFull(s.reader, chunk)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return chunk[:n], err
+	}
+
+	line, err := s.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		err = nil
+	}
+	return line, err
+}
+
+// Close - waits for middleware to exit, it is killed when output wasn't read to the end (client went away)
+func (s *synthesisStream) Close() error {
+	s.closeOnce.Do(func() {
+		if s.err != io.EOF {
+			s.cancel()
+		}
+		var err error
+		for _, cmd := range s.cmds[:s.started] {
+			if waitErr := cmd.Wait(); waitErr != nil && err == nil {
+				err = waitErr
+			}
+		}
+		s.cancel()
+
+		stderr := joinStderr(s.stderr)
+		if err != nil && s.err == io.EOF {
+			log.WithFields(log.Fields{
+				"middlewares": s.middleware,
+				"sdtderr":     string(stderr),
+				"error":       err.Error(),
+			}).Error("Middleware error after synthesized response was streamed")
+		} else if len(stderr) > 0 {
+			log.WithFields(log.Fields{
+				"sdtderr": string(stderr),
+			}).Info("Information from middleware")
+		}
+	})
+	return nil
+}
+
+// streamingSynthesisHandler - flushes every write of response body with StreamingSynthesis, proxy copies body to
+// buffered response writer so streamed output would otherwise reach the client in large blocks
+type streamingSynthesisHandler struct {
+	handler http.Handler
+	cfg     *Configuration
+}
+
+func (h *streamingSynthesisHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !h.cfg.StreamingSynthesis || !ok {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	h.handler.ServeHTTP(&flushingWriter{ResponseWriter: w, flusher: flusher}, r)
+}
+
+type flushingWriter struct {
+	http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (w *flushingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.flusher.Flush()
+	return n, err
+}
+
+func (w *flushingWriter) Flush() {
+	w.flusher.Flush()
+}
+
+// Hijack - CONNECT and websocket handlers need access to underlying connection
+func (w *flushingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
@@ -0,0 +1,162 @@
+package hoverfly
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// mockStreamingMiddleware - replaces middleware processes with shell script
+func mockStreamingMiddleware(t *testing.T, script string) {
+	original := middlewareCommand
+	middlewareCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", script)
+	}
+	t.Cleanup(func() { middlewareCommand = original })
+}
+
+func TestSynthesizeStreamingFlushesEveryLine(t *testing.T) {
+	mockStreamingMiddleware(t, `cat > /dev/null; echo one; sleep 2; echo two`)
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.Cfg.SetMiddleware("./middleware.py")
+	dbClient.Cfg.StreamingSynthesis = true
+
+	req, err := http.NewRequest("GET", "http://example.com/stream", nil)
+	testutil.Expect(t, err, nil)
+
+	start := time.Now()
+	resp, err := dbClient.synthesizeResponse(req)
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, resp.ContentLength, int64(-1))
+	testutil.Expect(t, resp.Header.Get("Content-Length"), "")
+
+	// first line is available while middleware is still running
+	buf := make([]byte, 1024)
+	n, err := resp.Body.Read(buf)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(buf[:n]), "one\n")
+	testutil.Expect(t, time.Since(start) < 1500*time.Millisecond, true)
+
+	rest, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(rest), "two\n")
+}
+
+func TestSynthesizeStreamingChunkSize(t *testing.T) {
+	mockStreamingMiddleware(t, `cat > /dev/null; printf abcdefg`)
+
+	req, err := http.NewRequest("GET", "http://example.com/stream", nil)
+	testutil.Expect(t, err, nil)
+
+	resp, err := synthesizeStreaming(req, "./middleware.py", middlewareLimits{}, 3)
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+
+	var chunks []string
+	buf := make([]byte, 1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			chunks = append(chunks, string(buf[:n]))
+		}
+		if err == io.EOF {
+			break
+		}
+		testutil.Expect(t, err, nil)
+	}
+	testutil.Expect(t, len(chunks), 3)
+	testutil.Expect(t, chunks[0], "abc")
+	testutil.Expect(t, chunks[1], "def")
+	testutil.Expect(t, chunks[2], "g")
+}
+
+func TestSynthesizeStreamingKillsMiddlewareOnClose(t *testing.T) {
+	mockStreamingMiddleware(t, `cat > /dev/null; echo one; exec sleep 60`)
+
+	req, err := http.NewRequest("GET", "http://example.com/stream", nil)
+	testutil.Expect(t, err, nil)
+
+	resp, err := synthesizeStreaming(req, "./middleware.py", middlewareLimits{}, 0)
+	testutil.Expect(t, err, nil)
+
+	start := time.Now()
+	resp.Body.Close()
+	testutil.Expect(t, time.Since(start) < 10*time.Second, true)
+}
+
+func TestSynthesizeStreamingChainCollectsStderrOfEveryCommand(t *testing.T) {
+	original := middlewareCommand
+	middlewareCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", "echo "+name+" >&2; cat")
+	}
+	defer func() { middlewareCommand = original }()
+
+	req, err := http.NewRequest("GET", "http://example.com/stream", nil)
+	testutil.Expect(t, err, nil)
+
+	resp, err := synthesizeStreaming(req, "first | second", middlewareLimits{}, 0)
+	testutil.Expect(t, err, nil)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Refute(t, len(body), 0)
+	resp.Body.Close()
+
+	testutil.Expect(t, string(joinStderr(resp.Body.(*synthesisStream).stderr)), "first\nsecond\n")
+}
+
+func TestStreamingSynthesisHandler(t *testing.T) {
+	mockStreamingMiddleware(t, `cat > /dev/null; echo one; sleep 2; echo two`)
+
+	cfg := InitSettings()
+	cfg.StreamingSynthesis = true
+	// copies response to the client the way proxy does
+	proxy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := synthesizeStreaming(r, "./middleware.py", middlewareLimits{}, 0)
+		testutil.Expect(t, err, nil)
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		resp.Body.Close()
+	})
+	server := httptest.NewServer(&streamingSynthesisHandler{handler: proxy, cfg: cfg})
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := http.Get(server.URL)
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+	testutil.Expect(t, resp.ContentLength, int64(-1))
+	testutil.Expect(t, len(resp.TransferEncoding), 1)
+	testutil.Expect(t, resp.TransferEncoding[0], "chunked")
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, line, "one\n")
+	testutil.Expect(t, time.Since(start) < 1500*time.Millisecond, true)
+
+	line, err = reader.ReadString('\n')
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, line, "two\n")
+}
+
+func TestStreamsMiddleware(t *testing.T) {
+	testutil.Expect(t, streamsMiddleware("./middleware.py"), true)
+	testutil.Expect(t, streamsMiddleware("./one.py|./two.py"), true)
+	testutil.Expect(t, streamsMiddleware(""), false)
+	testutil.Expect(t, streamsMiddleware("plugin:./middleware.so"), false)
+	testutil.Expect(t, streamsMiddleware("lua:response.body = 'x'"), false)
+	testutil.Expect(t, streamsMiddleware("unix:/tmp/middleware.sock"), false)
+}
@@ -2,6 +2,7 @@ package hoverfly
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -12,7 +13,48 @@ import (
 
 // SynthesizeResponse calls middleware to populate response data, nothing gets pass proxy
 func SynthesizeResponse(req *http.Request, middleware string) (*http.Response, error) {
+	return synthesizeWithMiddleware(req, middleware, middlewareLimits{}, nil)
+}
+
+// synthesizeWithMiddleware - SynthesizeResponse with middleware timeout and retries, invocation is traced with
+// given tracer provider when it is set
+func synthesizeWithMiddleware(req *http.Request, middleware string, limits middlewareLimits, tp TracerProvider) (*http.Response, error) {
+
+	payload, err := synthesizePayload(req)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"middleware": middleware,
+			"error":      err.Error(),
+		}).Error("Failed to read request body when synthesizing response")
+
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"middleware":  middleware,
+		"body":        payload.Request.Body,
+		"destination": payload.Request.Destination,
+	}).Debug("Synthesizing new response")
+
+	c := NewConstructor(req, payload).withMiddlewareLimits(limits).withTracerProvider(tp)
 
+	if middleware != "" {
+		err := c.ApplyMiddleware(middleware)
+		if err != nil {
+			return nil, withErrorCode(errorCodeOf(err, ErrorCodeMiddlewareFailed), fmt.Errorf("Synthesize failed, middleware error - %s", err.Error()))
+		}
+	} else {
+		return nil, fmt.Errorf("Synthesize failed, middleware not provided")
+
+	}
+
+	response := c.ReconstructResponse()
+	return response, nil
+
+}
+
+// synthesizePayload - payload with request details only, it is what middleware and synthesize service get
+func synthesizePayload(req *http.Request) (models.Payload, error) {
 	// this is mainly for testing, since when you create a request during tests
 	// its body will be nil, that results in bad things during read
 	if req.Body == nil {
@@ -21,48 +63,71 @@ func SynthesizeResponse(req *http.Request, middleware string) (*http.Response, e
 	defer req.Body.Close()
 	requestBody, err := ioutil.ReadAll(req.Body)
 
-	var bodyStr string
 	if err != nil {
-		log.WithFields(log.Fields{
-			"middleware": middleware,
-			"error":      err.Error(),
-		}).Error("Failed to read request body when synthesizing response")
-
 		// creating new error with more info
-		return nil, fmt.Errorf("Synthesize failed, could not read request body - %s", err.Error())
+		return models.Payload{}, fmt.Errorf("Synthesize failed, could not read request body - %s", err.Error())
 	}
 
-	bodyStr = string(requestBody)
-
 	request := models.RequestDetails{
 		Path:        req.URL.Path,
 		Method:      req.Method,
 		Destination: req.Host,
 		Query:       req.URL.RawQuery,
-		Body:        bodyStr,
+		Body:        string(requestBody),
 		Headers:     req.Header,
 	}
-	payload := models.Payload{Request: request}
+	return models.Payload{Request: request}, nil
+}
+
+// synthesizeResponse - synthesizes response with synthesize service when SynthesizeURL is set, middleware is
+// used otherwise. With StreamingSynthesis, output of middleware command is streamed as response body.
+func (d *Hoverfly) synthesizeResponse(req *http.Request) (*http.Response, error) {
+	cfg := d.requestConfig(req)
+	if cfg.SynthesizeURL == "" {
+		if cfg.StreamingSynthesis && streamsMiddleware(cfg.GetMiddleware()) {
+			return synthesizeStreaming(req, cfg.GetMiddleware(), cfg.middlewareLimits(), cfg.StreamingSynthesisChunkSize)
+		}
+		return synthesizeWithMiddleware(req, cfg.GetMiddleware(), cfg.middlewareLimits(), cfg.TracerProvider)
+	}
+
+	payload, err := synthesizePayload(req)
+	if err != nil {
+		return nil, err
+	}
+
+	bts, err := json.Marshal(payload.ConvertToPayloadView())
+	if err != nil {
+		return nil, err
+	}
 
 	log.WithFields(log.Fields{
-		"middleware":  middleware,
-		"body":        bodyStr,
-		"destination": request.Destination,
-	}).Debug("Synthesizing new response")
+		"synthesizeURL": cfg.SynthesizeURL,
+		"destination":   payload.Request.Destination,
+	}).Debug("Synthesizing new response with synthesize service")
 
-	c := NewConstructor(req, payload)
+	// synthesize service takes place of middleware
+	end := startOperation(req, OperationMiddleware)
+	resp, err := d.HTTP.Post(cfg.SynthesizeURL, "application/json", bytes.NewReader(bts))
+	end(err)
+	if err != nil {
+		return nil, withErrorCode(ErrorCodeUpstreamUnreachable, fmt.Errorf("Synthesize failed, synthesize service error - %s", err.Error()))
+	}
+	defer resp.Body.Close()
 
-	if middleware != "" {
-		err := c.ApplyMiddleware(middleware)
-		if err != nil {
-			return nil, fmt.Errorf("Synthesize failed, middleware error - %s", err.Error())
-		}
-	} else {
-		return nil, fmt.Errorf("Synthesize failed, middleware not provided")
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Synthesize failed, could not read synthesize service response - %s", err.Error())
+	}
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Synthesize failed, synthesize service returned %d - %s", resp.StatusCode, string(body))
 	}
 
-	response := c.ReconstructResponse()
-	return response, nil
+	var view models.PayloadView
+	if err := json.Unmarshal(body, &view); err != nil {
+		return nil, fmt.Errorf("Synthesize failed, could not parse synthesize service response - %s", err.Error())
+	}
 
+	c := NewConstructor(req, view.ConvertToPayload())
+	return c.ReconstructResponse(), nil
 }
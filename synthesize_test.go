@@ -1,9 +1,14 @@
 package hoverfly
 
 import (
-	"github.com/SpectoLabs/hoverfly/testutil"
+	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
 )
 
 func TestSynthesizeResponse(t *testing.T) {
@@ -36,3 +41,70 @@ func TestSynthesizeMiddlewareFailure(t *testing.T) {
 	_, err = SynthesizeResponse(req, "./examples/middleware/this_is_not_there.py")
 	testutil.Refute(t, err, nil)
 }
+
+func TestSynthesizeResponseWithSynthesizeService(t *testing.T) {
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var view models.PayloadView
+		if err := json.NewDecoder(r.Body).Decode(&view); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		view.Response = models.ResponseDetailsView{
+			Status:  201,
+			Body:    "synthesized " + view.Request.Method + " " + view.Request.Path,
+			Headers: map[string][]string{"Content-Type": {"text/plain"}},
+		}
+		json.NewEncoder(w).Encode(view)
+	}))
+	defer service.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.SynthesizeURL = service.URL
+
+	req, err := http.NewRequest("POST", "http://example.com/users", nil)
+	testutil.Expect(t, err, nil)
+
+	resp, err := dbClient.synthesizeResponse(req)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, 201)
+	testutil.Expect(t, resp.Header.Get("Content-Type"), "text/plain")
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "synthesized POST /users")
+}
+
+func TestSynthesizeResponseWithFailingSynthesizeService(t *testing.T) {
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer service.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.SynthesizeURL = service.URL
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	testutil.Expect(t, err, nil)
+
+	_, err = dbClient.synthesizeResponse(req)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, errorCodeOf(err, ErrorCodeMiddlewareFailed), ErrorCodeMiddlewareFailed)
+}
+
+func TestSynthesizeResponseWithUnreachableSynthesizeService(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.SynthesizeURL = "http://127.0.0.1:1"
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	testutil.Expect(t, err, nil)
+
+	_, err = dbClient.synthesizeResponse(req)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, errorCodeOf(err, ErrorCodeMiddlewareFailed), ErrorCodeUpstreamUnreachable)
+}
@@ -60,8 +60,18 @@ func testTools(code int, body string) (*httptest.Server, *Hoverfly) {
 		HTTP:          &http.Client{Transport: tr},
 		RequestCache:  requestCache,
 		Cfg:           cfg,
-		Counter:       metrics.NewModeCounter([]string{SimulateMode, SynthesizeMode, ModifyMode, CaptureMode}),
+		Counter:       metrics.NewModeCounter([]string{SimulateMode, SynthesizeMode, ModifyMode, CaptureMode, SpyMode, DiffMode, ChaosMode}),
+		Sizes:         metrics.NewSizeHistogram(cfg.SizeBuckets),
 		MetadataCache: metaCache,
+		Diffs:         NewDiffStore(cache.NewInMemoryCache()),
+		Sequences:     NewSequenceCounter(),
+		Weights:       NewWeightedSelector(cfg.SimulationSeed),
+		Circuits:      NewCircuitBreaker(cfg),
+		requestHooks:  newRequestHooks(),
+		modeHooks:     newModeChangeHooks(),
+		captureBloom:  newCaptureBloom(),
+		flows:         newFlowIndex(),
+		recentAccess:  newAccessIndex(),
 	}
 	return server, dbClient
 }
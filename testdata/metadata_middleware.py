@@ -0,0 +1,18 @@
+#!/usr/bin/env python
+import sys
+import json
+
+
+def main():
+    data = sys.stdin.readlines()
+    payload = data[0]
+
+    payload_dict = json.loads(payload)
+
+    payload_dict['metadata'] = {'userId': '42'}
+
+    # returning new payload
+    print(json.dumps(payload_dict))
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,20 @@
+// Middleware plugin used by middleware plugin tests, built with 'go build -buildmode=plugin'
+package main
+
+import (
+	"errors"
+
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// MiddlewareFunc - replaces response body and status, fails for requests to '/fail'
+func MiddlewareFunc(pair *models.MiddlewarePair) error {
+	if pair.Request.Path == "/fail" {
+		return errors.New("plugin failure")
+	}
+	pair.Response.Status = 201
+	pair.Response.Body = "modified by plugin: " + pair.Request.Path
+	return nil
+}
+
+func main() {}
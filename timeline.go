@@ -0,0 +1,265 @@
+package hoverfly
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// TimelineKeyPrefix - prefix for metadata keys that hold timeline events
+const TimelineKeyPrefix = "timeline_"
+
+// TimelineEventAdmin - event type for actions performed through admin API
+const TimelineEventAdmin = "admin"
+
+// TimelineEventProxy - event type for requests/responses that went through the proxy
+const TimelineEventProxy = "proxy"
+
+// ActionTypeRecordsImported - default action type for records imported through admin API
+const ActionTypeRecordsImported = "recordsImported"
+
+// ActionTypeCacheGC - action type for cache garbage collection started through admin API
+const ActionTypeCacheGC = "cacheGC"
+
+// ActionTypeCacheSaved - action type for cache saved to file through admin API
+const ActionTypeCacheSaved = "cacheSaved"
+
+// ActionTypeRequestsReplayed - action type for recorded requests replayed against live target through admin API
+const ActionTypeRequestsReplayed = "requestsReplayed"
+
+// ActionTypeMiddlewareUploaded - action type for middleware uploaded through admin API
+const ActionTypeMiddlewareUploaded = "middlewareUploaded"
+
+// ActionTypeRecordCloned - action type for records cloned through admin API
+const ActionTypeRecordCloned = "recordCloned"
+
+// ActionTypeStatsReset - request counters were reset through admin API
+const ActionTypeStatsReset = "statsReset"
+
+// ActionTypeFlowCreated - flow simulation was created through admin API
+const ActionTypeFlowCreated = "flowCreated"
+
+// TimelineEvent - single admin or proxy event stored in the timeline
+type TimelineEvent struct {
+	Time    time.Time           `json:"time"`
+	Type    string              `json:"type"`
+	Action  string              `json:"action"`
+	Actor   string              `json:"actor,omitempty"`
+	Payload *models.PayloadView `json:"payload,omitempty"`
+
+	// RequestID - X-Request-ID of proxied request
+	RequestID string `json:"requestId,omitempty"`
+
+	// middleware stdin and stdout, only stored when verbose logging is enabled
+	MiddlewareInput  json.RawMessage `json:"middlewareInput,omitempty"`
+	MiddlewareOutput json.RawMessage `json:"middlewareOutput,omitempty"`
+
+	// stored whenever middleware was invoked, stderr is capped at MiddlewareStderrMaxBytes
+	MiddlewareExitCode  *int   `json:"middlewareExitCode,omitempty"`
+	MiddlewareStderr    string `json:"middlewareStderr,omitempty"`
+	MiddlewareElapsedMs int64  `json:"middlewareElapsedMs,omitempty"`
+}
+
+type middlewareExchangeKey struct{}
+
+// middlewareExchange - collects details of last middleware call while request is being processed, stdin and
+// stdout aren't collected with skipPayloads
+type middlewareExchange struct {
+	mu           sync.Mutex
+	invoked      bool
+	skipPayloads bool
+	input        []byte
+	output       []byte
+	stderr       []byte
+	exitCode     *int
+	elapsed      time.Duration
+	// received - when proxy received the request
+	received time.Time
+}
+
+// withMiddlewareExchange - returns request that collects middleware call details into returned exchange
+func withMiddlewareExchange(req *http.Request) (*http.Request, *middlewareExchange) {
+	exchange := &middlewareExchange{received: time.Now()}
+	return req.WithContext(context.WithValue(req.Context(), middlewareExchangeKey{}, exchange)), exchange
+}
+
+// recordMiddlewareExchange - stores middleware call details if request collects them, previous values are
+// replaced so in modify mode the response middleware call is kept
+func recordMiddlewareExchange(req *http.Request, run middlewareRun, elapsed time.Duration) {
+	if req == nil {
+		return
+	}
+	exchange, ok := req.Context().Value(middlewareExchangeKey{}).(*middlewareExchange)
+	if !ok {
+		return
+	}
+	exchange.mu.Lock()
+	exchange.invoked = true
+	if !exchange.skipPayloads {
+		exchange.input, exchange.output = run.Stdin, run.Stdout
+	}
+	exchange.stderr, exchange.exitCode, exchange.elapsed = run.Stderr, run.ExitCode, elapsed
+	exchange.mu.Unlock()
+}
+
+// capStderr - keeps first max bytes of middleware stderr, negative max keeps all of it
+func capStderr(stderr []byte, max int) string {
+	if max >= 0 && len(stderr) > max {
+		return string(stderr[:max])
+	}
+	return string(stderr)
+}
+
+// rawJSON - returns given bytes as raw JSON, middleware output that is not valid JSON is stored as a string
+func rawJSON(bts []byte) json.RawMessage {
+	if len(bts) == 0 {
+		return nil
+	}
+	if json.Valid(bts) {
+		return json.RawMessage(bts)
+	}
+	encoded, _ := json.Marshal(string(bts))
+	return json.RawMessage(encoded)
+}
+
+type timelineEvents []TimelineEvent
+
+func (e timelineEvents) Len() int           { return len(e) }
+func (e timelineEvents) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e timelineEvents) Less(i, j int) bool { return e[i].Time.Before(e[j].Time) }
+
+// recordTimelineEvent - stores given event in metadata cache, errors are only logged since the timeline
+// should never break admin API or proxy
+func (d *Hoverfly) recordTimelineEvent(event TimelineEvent) {
+	bts, err := json.Marshal(event)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":  err.Error(),
+			"action": event.Action,
+		}).Error("Failed to encode timeline event")
+		return
+	}
+
+	key := fmt.Sprintf("%s%s_%s", TimelineKeyPrefix, event.Time.UTC().Format(time.RFC3339Nano), GetRandomName(5))
+
+	err = d.MetadataCache.Set([]byte(key), bts)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":  err.Error(),
+			"action": event.Action,
+		}).Warn("Failed to store timeline event")
+	}
+}
+
+// recordAdminEvent - adds admin API action to the timeline, remote address is used as an actor
+func (d *Hoverfly) recordAdminEvent(req *http.Request, action string) {
+	d.recordTimelineEvent(TimelineEvent{
+		Time:   time.Now(),
+		Type:   TimelineEventAdmin,
+		Action: action,
+		Actor:  req.RemoteAddr,
+	})
+}
+
+// recordProxyEvent - adds request/response pair that went through the proxy to the timeline together with
+// details of middleware call if they were collected
+func (d *Hoverfly) recordProxyEvent(req *http.Request, resp *http.Response, exchange *middlewareExchange) {
+	if req == nil || resp == nil {
+		return
+	}
+
+	rd, err := getRequestDetails(req)
+	if err != nil {
+		return
+	}
+
+	// streamed body can't be read ahead of the client
+	respBody := []byte("")
+	if _, streamed := resp.Body.(*synthesisStream); resp.Body != nil && !streamed {
+		respBody, err = extractBody(resp)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+			}).Error("Failed to read response body for timeline")
+			return
+		}
+	}
+
+	payload := models.Payload{
+		Request: rd,
+		Response: models.ResponseDetails{
+			Status:  resp.StatusCode,
+			Body:    string(respBody),
+			Headers: resp.Header,
+		},
+	}
+
+	event := TimelineEvent{
+		Time:      time.Now(),
+		Type:      TimelineEventProxy,
+		Action:    d.Cfg.GetMode(),
+		Actor:     req.RemoteAddr,
+		Payload:   payload.ConvertToPayloadView(),
+		RequestID: req.Header.Get(RequestIDHeader),
+	}
+
+	if exchange != nil {
+		exchange.mu.Lock()
+		event.MiddlewareInput = rawJSON(exchange.input)
+		event.MiddlewareOutput = rawJSON(exchange.output)
+		if exchange.invoked {
+			event.MiddlewareExitCode = exchange.exitCode
+			event.MiddlewareStderr = capStderr(exchange.stderr, d.Cfg.MiddlewareStderrMaxBytes)
+			event.MiddlewareElapsedMs = exchange.elapsed.Nanoseconds() / int64(time.Millisecond)
+		}
+		exchange.mu.Unlock()
+	}
+
+	d.recordTimelineEvent(event)
+}
+
+// GetTimeline - returns all timeline events between start and end (inclusive), sorted by time. Zero start or end
+// leaves that side of the range open.
+func (d *Hoverfly) GetTimeline(start, end time.Time) ([]TimelineEvent, error) {
+	entries, err := d.MetadataCache.GetAllEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	events := timelineEvents{}
+
+	for k, v := range entries {
+		if !strings.HasPrefix(k, TimelineKeyPrefix) {
+			continue
+		}
+
+		var event TimelineEvent
+		if err := json.Unmarshal(v, &event); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"key":   k,
+			}).Warn("Failed to decode timeline event")
+			continue
+		}
+
+		if !start.IsZero() && event.Time.Before(start) {
+			continue
+		}
+		if !end.IsZero() && event.Time.After(end) {
+			continue
+		}
+		events = append(events, event)
+	}
+
+	sort.Sort(events)
+
+	return events, nil
+}
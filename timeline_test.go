@@ -0,0 +1,175 @@
+package hoverfly
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestRecordProxyEvent(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+
+	req, err := http.NewRequest("GET", "http://example.com/path", nil)
+	testutil.Expect(t, err, nil)
+
+	resp, err := dbClient.captureRequest(req)
+	testutil.Expect(t, err, nil)
+
+	dbClient.recordProxyEvent(req, resp, nil)
+
+	events, err := dbClient.GetTimeline(time.Time{}, time.Time{})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(events), 1)
+	testutil.Expect(t, events[0].Type, TimelineEventProxy)
+	testutil.Expect(t, events[0].Payload.Request.Path, "/path")
+	testutil.Expect(t, events[0].Payload.Response.Status, 201)
+}
+
+func TestGetTimelineRange(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+
+	now := time.Now()
+
+	dbClient.recordTimelineEvent(TimelineEvent{Time: now.Add(-2 * time.Hour), Type: TimelineEventAdmin, Action: "first"})
+	dbClient.recordTimelineEvent(TimelineEvent{Time: now.Add(-1 * time.Hour), Type: TimelineEventAdmin, Action: "second"})
+	dbClient.recordTimelineEvent(TimelineEvent{Time: now, Type: TimelineEventAdmin, Action: "third"})
+
+	events, err := dbClient.GetTimeline(time.Time{}, time.Time{})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(events), 3)
+	testutil.Expect(t, events[0].Action, "first")
+	testutil.Expect(t, events[2].Action, "third")
+
+	events, err = dbClient.GetTimeline(now.Add(-90*time.Minute), now.Add(-30*time.Minute))
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(events), 1)
+	testutil.Expect(t, events[0].Action, "second")
+}
+
+func TestRecordProxyEventWithMiddlewareExchange(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.Middleware = "./examples/middleware/modify_response/modify_response.py"
+
+	req, err := http.NewRequest("GET", "http://example.com/path", nil)
+	testutil.Expect(t, err, nil)
+
+	req, exchange := withMiddlewareExchange(req)
+
+	resp, err := dbClient.modifyRequestResponse(req, dbClient.Cfg.Middleware)
+	testutil.Expect(t, err, nil)
+
+	dbClient.recordProxyEvent(req, resp, exchange)
+
+	events, err := dbClient.GetTimeline(time.Time{}, time.Time{})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(events), 1)
+
+	var input, output models.PayloadView
+	testutil.Expect(t, json.Unmarshal(events[0].MiddlewareInput, &input), nil)
+	testutil.Expect(t, json.Unmarshal(events[0].MiddlewareOutput, &output), nil)
+	testutil.Expect(t, input.Request.Path, "/path")
+	testutil.Expect(t, output.Response.Body, "body was replaced by middleware\n")
+}
+
+// middlewareEventFor - runs given shell script as middleware in modify mode and returns timeline event of the request
+func middlewareEventFor(t *testing.T, script string, configure func(*Hoverfly)) TimelineEvent {
+	original := middlewareCommand
+	middlewareCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", script)
+	}
+	defer func() { middlewareCommand = original }()
+
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.Cfg.Middleware = "./middleware.sh"
+	configure(dbClient)
+
+	req, err := http.NewRequest("GET", "http://example.com/path", nil)
+	testutil.Expect(t, err, nil)
+
+	req, exchange := withMiddlewareExchange(req)
+	exchange.skipPayloads = !dbClient.Cfg.Verbose
+
+	resp, _ := dbClient.modifyRequestResponse(req, dbClient.Cfg.Middleware)
+	if resp == nil {
+		resp = &http.Response{StatusCode: http.StatusServiceUnavailable}
+	}
+	dbClient.recordProxyEvent(req, resp, exchange)
+
+	events, err := dbClient.GetTimeline(time.Time{}, time.Time{})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(events), 1)
+	return events[0]
+}
+
+func TestRecordProxyEventWithFailedMiddleware(t *testing.T) {
+	event := middlewareEventFor(t, "echo 'middleware broke' >&2; sleep 0.05; exit 3", func(d *Hoverfly) {})
+
+	testutil.Expect(t, event.MiddlewareExitCode != nil, true)
+	testutil.Expect(t, *event.MiddlewareExitCode, 3)
+	testutil.Expect(t, event.MiddlewareStderr, "middleware broke\n")
+	testutil.Expect(t, event.MiddlewareElapsedMs >= 50, true)
+	// stdin/stdout are only kept with verbose logging
+	testutil.Expect(t, len(event.MiddlewareInput), 0)
+}
+
+func TestRecordProxyEventWithSuccessfulMiddleware(t *testing.T) {
+	event := middlewareEventFor(t, "echo 'some details' >&2; cat", func(d *Hoverfly) {
+		d.Cfg.Verbose = true
+	})
+
+	testutil.Expect(t, event.MiddlewareExitCode != nil, true)
+	testutil.Expect(t, *event.MiddlewareExitCode, 0)
+	testutil.Expect(t, event.MiddlewareStderr, "some details\n")
+	testutil.Refute(t, len(event.MiddlewareInput), 0)
+}
+
+func TestRecordProxyEventCapsMiddlewareStderr(t *testing.T) {
+	event := middlewareEventFor(t, "printf 'abcdefghij' >&2; exit 1", func(d *Hoverfly) {
+		d.Cfg.MiddlewareStderrMaxBytes = 4
+	})
+
+	testutil.Expect(t, *event.MiddlewareExitCode, 1)
+	testutil.Expect(t, event.MiddlewareStderr, "abcd")
+}
+
+func TestRecordProxyEventWithoutMiddleware(t *testing.T) {
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+
+	req, err := http.NewRequest("GET", "http://example.com/path", nil)
+	testutil.Expect(t, err, nil)
+	req, exchange := withMiddlewareExchange(req)
+
+	dbClient.recordProxyEvent(req, &http.Response{StatusCode: 200}, exchange)
+
+	events, err := dbClient.GetTimeline(time.Time{}, time.Time{})
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(events), 1)
+	testutil.Expect(t, events[0].MiddlewareExitCode == nil, true)
+	testutil.Expect(t, events[0].MiddlewareElapsedMs, int64(0))
+}
+
+func TestRawJSON(t *testing.T) {
+	testutil.Expect(t, len(rawJSON(nil)), 0)
+	testutil.Expect(t, string(rawJSON([]byte(`{"a": 1}`))), `{"a": 1}`)
+	testutil.Expect(t, string(rawJSON([]byte("not json"))), `"not json"`)
+}
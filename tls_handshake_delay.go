@@ -0,0 +1,83 @@
+package hoverfly
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rusenask/goproxy"
+)
+
+// SetTLSHandshakeDelayHosts - sets regexp patterns of hosts which TLS handshakes are delayed by TLSHandshakeDelayMs
+func (c *Configuration) SetTLSHandshakeDelayHosts(patterns []string) error {
+	compiled, err := compilePatterns("TLS handshake delay host", patterns)
+	if err != nil {
+		return err
+	}
+	c.TLSHandshakeDelayHosts = patterns
+	c.tlsHandshakeDelayHostPatterns = compiled
+	return nil
+}
+
+// IsTLSHandshakeDelayHost - checks whether TLS handshake with given host should be delayed, host patterns are
+// regexps, every host matches when no patterns are set. Handshakes are delayed only in simulate mode.
+func (c *Configuration) IsTLSHandshakeDelayHost(host string) bool {
+	if c.TLSHandshakeDelayMs <= 0 || c.GetMode() != SimulateMode {
+		return false
+	}
+	if len(c.TLSHandshakeDelayHosts) == 0 {
+		return true
+	}
+	for _, re := range c.tlsHandshakeDelayHostPatterns {
+		if re.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// handshakeDelayConnect - MITM action for CONNECT requests which TLS handshake is delayed, server waits after
+// receiving client hello before it continues with the handshake
+func (d *Hoverfly) handshakeDelayConnect(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+	hostname, _, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname = host
+	}
+	if !d.Cfg.IsTLSHandshakeDelayHost(hostname) {
+		return nil, ""
+	}
+
+	// pinning failure still applies to delayed handshakes
+	ca := &goproxy.GoproxyCa
+	if d.Cfg.IsPinFailHost(hostname) {
+		if ca, err = getPinFailCA(); err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"host":  host,
+			}).Error("Failed to create certificate for pinning failure")
+			return nil, ""
+		}
+	}
+
+	delay := time.Duration(d.Cfg.TLSHandshakeDelayMs) * time.Millisecond
+	signHost := goproxy.TLSConfigFromCA(ca)
+
+	tlsConfig := func(host string, ctx *goproxy.ProxyCtx) (*tls.Config, error) {
+		config, err := signHost(host, ctx)
+		if err != nil {
+			return nil, err
+		}
+		config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			log.WithFields(log.Fields{
+				"host":  host,
+				"delay": delay.String(),
+			}).Debug("Delaying TLS handshake")
+			time.Sleep(delay)
+			return nil, nil
+		}
+		return config, nil
+	}
+
+	return &goproxy.ConnectAction{Action: goproxy.ConnectMitm, TLSConfig: tlsConfig}, host
+}
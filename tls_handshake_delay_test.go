@@ -0,0 +1,49 @@
+package hoverfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestTLSHandshakeDelayTimesOutClient(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.TLSHandshakeDelayMs = 500
+	testutil.Expect(t, dbClient.Cfg.SetTLSHandshakeDelayHosts([]string{"^slow\\.example\\.com$"}), nil)
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.UpdateProxy()
+
+	proxy := httptest.NewServer(dbClient.Proxy)
+	defer proxy.Close()
+	client := hoverflyCAClient(t, proxy.URL)
+	client.Transport.(*http.Transport).TLSHandshakeTimeout = 100 * time.Millisecond
+
+	_, err := client.Get("https://slow.example.com/")
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, strings.Contains(err.Error(), "TLS handshake timeout"), true)
+
+	resp, err := client.Get("https://fast.example.com/")
+	testutil.Expect(t, err, nil)
+	resp.Body.Close()
+}
+
+func TestIsTLSHandshakeDelayHost(t *testing.T) {
+	cfg := InitSettings()
+	cfg.TLSHandshakeDelayMs = 100
+	testutil.Expect(t, cfg.SetTLSHandshakeDelayHosts([]string{"api\\.example\\.com"}), nil)
+
+	cfg.SetMode(SimulateMode)
+	testutil.Expect(t, cfg.IsTLSHandshakeDelayHost("api.example.com"), true)
+	testutil.Expect(t, cfg.IsTLSHandshakeDelayHost("example.com"), false)
+
+	cfg.SetMode(CaptureMode)
+	testutil.Expect(t, cfg.IsTLSHandshakeDelayHost("api.example.com"), false)
+
+	testutil.Refute(t, cfg.SetTLSHandshakeDelayHosts([]string{"api\\.(example"}), nil)
+}
@@ -0,0 +1,123 @@
+package hoverfly
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/rusenask/goproxy"
+)
+
+// TLSSessionStats - TLS handshakes that resumed earlier session and handshakes that didn't
+type TLSSessionStats struct {
+	Resumptions    uint64 `json:"resumptions"`
+	FullHandshakes uint64 `json:"full_handshakes"`
+}
+
+// TLSStats - handshakes of clients with MITM proxy and of Hoverfly with upstream servers
+type TLSStats struct {
+	Client   TLSSessionStats `json:"client"`
+	Upstream TLSSessionStats `json:"upstream"`
+}
+
+// tlsSessionCounter - counts handshakes of connections its VerifyConnection is set on
+type tlsSessionCounter struct {
+	resumptions    uint64
+	fullHandshakes uint64
+}
+
+// verifyConnection - counts handshake and calls verification that was set before, it is called on resumed
+// connections as well
+func (c *tlsSessionCounter) verifyConnection(next func(tls.ConnectionState) error) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		if state.DidResume {
+			atomic.AddUint64(&c.resumptions, 1)
+		} else {
+			atomic.AddUint64(&c.fullHandshakes, 1)
+		}
+		if next != nil {
+			return next(state)
+		}
+		return nil
+	}
+}
+
+func (c *tlsSessionCounter) stats() TLSSessionStats {
+	return TLSSessionStats{
+		Resumptions:    atomic.LoadUint64(&c.resumptions),
+		FullHandshakes: atomic.LoadUint64(&c.fullHandshakes),
+	}
+}
+
+// tlsSessionTracker - counts TLS session resumptions of MITM and upstream connections. MITM configurations are
+// created for every tunnel, they share session ticket key so that clients can resume sessions across tunnels.
+type tlsSessionTracker struct {
+	client    tlsSessionCounter
+	upstream  tlsSessionCounter
+	ticketKey [32]byte
+}
+
+func newTLSSessionTracker() *tlsSessionTracker {
+	t := &tlsSessionTracker{}
+	if _, err := rand.Read(t.ticketKey[:]); err != nil {
+		// sessions are still resumed within configuration that generates its own key
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Warn("Failed to generate TLS session ticket key")
+	}
+	return t
+}
+
+// mitmTLSConfig - wraps function that creates MITM configuration for host
+func (t *tlsSessionTracker) mitmTLSConfig(sign func(host string, ctx *goproxy.ProxyCtx) (*tls.Config, error)) func(host string, ctx *goproxy.ProxyCtx) (*tls.Config, error) {
+	return func(host string, ctx *goproxy.ProxyCtx) (*tls.Config, error) {
+		config, err := sign(host, ctx)
+		if err != nil {
+			return nil, err
+		}
+		config.SessionTicketKey = t.ticketKey
+		config.VerifyConnection = t.client.verifyConnection(config.VerifyConnection)
+		return config, nil
+	}
+}
+
+// trackUpstream - upstream sessions are kept in LRU cache, without it upstream connections are never resumed
+func (t *tlsSessionTracker) trackUpstream(config *tls.Config) {
+	if config.ClientSessionCache == nil {
+		config.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+	config.VerifyConnection = t.upstream.verifyConnection(config.VerifyConnection)
+}
+
+func (t *tlsSessionTracker) stats() TLSStats {
+	return TLSStats{Client: t.client.stats(), Upstream: t.upstream.stats()}
+}
+
+// mitmConnect - intercepts tunnel with MITM configuration that counts TLS sessions
+func (d *Hoverfly) mitmConnect(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+	return &goproxy.ConnectAction{
+		Action:    goproxy.ConnectMitm,
+		TLSConfig: d.tlsSessions.mitmTLSConfig(goproxy.TLSConfigFromCA(&goproxy.GoproxyCa)),
+	}, host
+}
+
+// TLSStatsHandler - returns counts of resumed and full TLS handshakes of clients and upstream connections
+func (d *Hoverfly) TLSStatsHandler(w http.ResponseWriter, req *http.Request, next http.HandlerFunc) {
+	var stats TLSStats
+	if d.tlsSessions != nil {
+		stats = d.tlsSessions.stats()
+	}
+
+	b, err := json.Marshal(stats)
+	if err != nil {
+		log.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
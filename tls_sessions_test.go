@@ -0,0 +1,101 @@
+package hoverfly
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// getTwice - sends two requests on separate connections so that the second one can resume TLS session
+func getTwice(t *testing.T, client *http.Client, target string) {
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(target)
+		testutil.Expect(t, err, nil)
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+}
+
+func TestTLSSessionTrackerCountsUpstreamResumptions(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	tracker := newTLSSessionTracker()
+	config := &tls.Config{InsecureSkipVerify: true}
+	tracker.trackUpstream(config)
+	testutil.Refute(t, config.ClientSessionCache, nil)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config, DisableKeepAlives: true}}
+	getTwice(t, client, upstream.URL)
+
+	stats := tracker.stats()
+	testutil.Expect(t, stats.Upstream.FullHandshakes, uint64(1))
+	testutil.Expect(t, stats.Upstream.Resumptions, uint64(1))
+	testutil.Expect(t, stats.Client.FullHandshakes, uint64(0))
+}
+
+func TestTLSSessionTrackerKeepsVerification(t *testing.T) {
+	tracker := newTLSSessionTracker()
+	called := false
+	config := &tls.Config{VerifyConnection: func(tls.ConnectionState) error {
+		called = true
+		return nil
+	}}
+	tracker.trackUpstream(config)
+
+	testutil.Expect(t, config.VerifyConnection(tls.ConnectionState{DidResume: true}), nil)
+	testutil.Expect(t, called, true)
+	testutil.Expect(t, tracker.stats().Upstream.Resumptions, uint64(1))
+}
+
+func TestTLSSessionTrackerCountsClientResumptions(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.tlsSessions = newTLSSessionTracker()
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.UpdateProxy()
+
+	proxy := httptest.NewServer(dbClient.Proxy)
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	// separate tunnels share client session cache
+	sessions := tls.NewLRUClientSessionCache(0)
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:             http.ProxyURL(proxyURL),
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true, ClientSessionCache: sessions},
+		DisableKeepAlives: true,
+	}}
+	getTwice(t, client, "https://sessions.example.com/")
+
+	stats := dbClient.tlsSessions.stats()
+	testutil.Expect(t, stats.Client.FullHandshakes, uint64(1))
+	testutil.Expect(t, stats.Client.Resumptions, uint64(1))
+}
+
+func TestTLSStatsHandler(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.tlsSessions = newTLSSessionTracker()
+	dbClient.tlsSessions.client.verifyConnection(nil)(tls.ConnectionState{DidResume: true})
+	dbClient.tlsSessions.upstream.verifyConnection(nil)(tls.ConnectionState{})
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", "/api/stats/tls", nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+
+	var body map[string]map[string]uint64
+	testutil.Expect(t, json.Unmarshal(rec.Body.Bytes(), &body), nil)
+	testutil.Expect(t, body["client"]["resumptions"], uint64(1))
+	testutil.Expect(t, body["client"]["full_handshakes"], uint64(0))
+	testutil.Expect(t, body["upstream"]["full_handshakes"], uint64(1))
+}
@@ -0,0 +1,49 @@
+package hoverfly
+
+import (
+	"context"
+	"net/http"
+)
+
+type trailersKey struct{}
+
+// responseTrailers - trailers of the response that is being served, they are known once proxy has the response
+type responseTrailers struct {
+	header http.Header
+}
+
+// trailersHandler - sends trailers of captured and simulated responses after the body. Proxy copies only response
+// headers and body to the client, trailers are written here once the body is written. Responses to requests
+// that were tunneled through CONNECT are written by the proxy itself and don't get trailers.
+type trailersHandler struct {
+	handler http.Handler
+}
+
+func (h *trailersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt := &responseTrailers{}
+	h.handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), trailersKey{}, rt)))
+
+	for k, values := range rt.header {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+}
+
+// declareTrailers - announces response trailers in 'Trailer' header, it has to be done before headers are
+// written. Trailers are only sent with chunked body so Content-Length is removed.
+func declareTrailers(req *http.Request, resp *http.Response) {
+	if req == nil || resp == nil || len(resp.Trailer) == 0 {
+		return
+	}
+	rt, ok := req.Context().Value(trailersKey{}).(*responseTrailers)
+	if !ok {
+		return
+	}
+
+	rt.header = resp.Trailer
+	resp.Header.Del("Content-Length")
+	for k := range resp.Trailer {
+		resp.Header.Add("Trailer", k)
+	}
+}
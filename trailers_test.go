@@ -0,0 +1,98 @@
+package hoverfly
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func getWithTrailers(t *testing.T, client *http.Client, url string) *http.Response {
+	resp, err := client.Get(url)
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+
+	// trailers are available only once body is read
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "checked body")
+	return resp
+}
+
+func TestTrailersAreCapturedAndSimulated(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Digest")
+		w.WriteHeader(200)
+		w.Write([]byte("checked body"))
+		w.Header().Set("Digest", "sha-256=abc")
+	}))
+	defer upstream.Close()
+
+	server, dbClient := testTools(201, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	dbClient.HTTP = &http.Client{}
+	dbClient.Cfg.Destinations = []string{"."}
+	dbClient.Cfg.SetMode(CaptureMode)
+	dbClient.UpdateProxy()
+
+	proxy := httptest.NewServer(&trailersHandler{handler: dbClient.Proxy})
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	testutil.Expect(t, err, nil)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp := getWithTrailers(t, client, upstream.URL+"/file")
+	testutil.Expect(t, resp.Trailer.Get("Digest"), "sha-256=abc")
+
+	values, err := dbClient.RequestCache.GetAllValues()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(values), 1)
+
+	payload, err := models.NewPayloadFromBytes(values[0])
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, payload.Response.Trailers["Digest"][0], "sha-256=abc")
+
+	// upstream is gone, response has to come from cache
+	upstream.Close()
+	dbClient.Cfg.SetMode(SimulateMode)
+
+	resp = getWithTrailers(t, client, upstream.URL+"/file")
+	testutil.Expect(t, resp.StatusCode, 200)
+	testutil.Expect(t, resp.Header.Get("Content-Length"), "")
+	testutil.Expect(t, resp.Trailer.Get("Digest"), "sha-256=abc")
+}
+
+func TestReconstructResponseSetsTrailers(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	testutil.Expect(t, err, nil)
+
+	payload := models.Payload{
+		Response: models.ResponseDetails{
+			Status:   200,
+			Body:     "body",
+			Trailers: map[string][]string{"Digest": {"sha-256=abc"}},
+		},
+	}
+
+	resp := NewConstructor(req, payload).ReconstructResponse()
+	testutil.Expect(t, resp.Trailer.Get("Digest"), "sha-256=abc")
+	testutil.Expect(t, resp.Header.Get("Digest"), "")
+}
+
+func TestDeclareTrailersWithoutTrailersHandler(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	testutil.Expect(t, err, nil)
+
+	resp := &http.Response{Header: http.Header{"Content-Length": {"4"}}, Trailer: http.Header{"Digest": {"abc"}}}
+	declareTrailers(req, resp)
+
+	testutil.Expect(t, resp.Header.Get("Trailer"), "")
+	testutil.Expect(t, resp.Header.Get("Content-Length"), "4")
+}
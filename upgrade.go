@@ -0,0 +1,315 @@
+package hoverfly
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/gorilla/websocket"
+)
+
+// upgradeKeyPrefix - prefix for metadata keys that hold captured upgraded connections
+const upgradeKeyPrefix = "upgrade_"
+
+// UpgradeFrame - bytes read from one side of upgraded connection at once, Payload is base64 encoded in JSON
+type UpgradeFrame struct {
+	Direction string    `json:"direction"`
+	Payload   []byte    `json:"payload"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// UpgradeRecord - captured connection that switched from HTTP to another protocol (i.e. 'Upgrade: myproto/1.0'),
+// it is matched by the Upgrade request the same way as regular requests are. Headers are headers of the
+// '101 Switching Protocols' response and Started is when it was received, frame timestamps are replayed relative
+// to it.
+type UpgradeRecord struct {
+	Request models.RequestDetails `json:"request"`
+	Headers map[string][]string   `json:"headers"`
+	Started time.Time             `json:"started"`
+	Frames  []UpgradeFrame        `json:"frames"`
+}
+
+// upgradeHopHeaders - headers of the proxy connection that must not be forwarded to upstream
+var upgradeHopHeaders = []string{
+	"Proxy-Connection",
+	"Proxy-Authorization",
+}
+
+// upgradeHandler - captures and simulates connections to intercepted hosts that are upgraded to protocols other
+// than WebSocket when CaptureUpgrades is enabled
+type upgradeHandler struct {
+	handler http.Handler
+	hf      *Hoverfly
+}
+
+func (h *upgradeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.hf.isUpgradeMode(r) || !isCustomUpgrade(r) || !h.hf.Cfg.isDestination(r.Host) {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	if requestMode(r, h.hf.Cfg) == CaptureMode {
+		h.hf.captureUpgrade(w, r)
+	} else {
+		h.hf.simulateUpgrade(w, r)
+	}
+}
+
+// isUpgradeMode - checks whether upgraded connections are captured or simulated in mode of the request
+func (d *Hoverfly) isUpgradeMode(r *http.Request) bool {
+	mode := requestMode(r, d.Cfg)
+	return d.Cfg.CaptureUpgrades && (mode == CaptureMode || mode == SimulateMode)
+}
+
+// isCustomUpgrade - request asks to switch protocol, WebSocket upgrades are handled by webSocketHandler
+func isCustomUpgrade(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" || websocket.IsWebSocketUpgrade(r) {
+		return false
+	}
+	for _, value := range r.Header["Connection"] {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dialUpgradeUpstream - opens connection to upstream of upgrade request, TLS for 'https' requests
+func (d *Hoverfly) dialUpgradeUpstream(r *http.Request) (net.Conn, error) {
+	host, port := r.Host, "80"
+	if r.URL.Scheme == "https" {
+		port = "443"
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, port)
+	}
+
+	conn, err := d.dialUpstream("tcp", host)
+	if err != nil || r.URL.Scheme != "https" {
+		return conn, err
+	}
+	serverName, _, _ := net.SplitHostPort(host)
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: serverName, InsecureSkipVerify: d.Cfg.TLSVerification})
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// captureUpgrade - sends upgrade request to upstream, relays bytes between client and upstream once upstream
+// switched protocols and records them when the connection is closed. Responses that don't switch protocols are
+// passed to the client and aren't recorded.
+func (d *Hoverfly) captureUpgrade(w http.ResponseWriter, r *http.Request) {
+	upstream, err := d.dialUpgradeUpstream(r)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err.Error(),
+			"destination": r.Host,
+			"path":        r.URL.Path,
+		}).Error("Could not connect to upstream of upgrade request")
+		writeErrorResponse(w, hoverflyError(r, err, "Could not connect to upstream of upgrade request", http.StatusBadGateway, ErrorCodeUpstreamUnreachable, errorFormat(r)))
+		return
+	}
+	defer upstream.Close()
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, h := range upgradeHopHeaders {
+		out.Header.Del(h)
+	}
+	upstreamReader := bufio.NewReader(upstream)
+	var resp *http.Response
+	if err = out.Write(upstream); err == nil {
+		resp, err = http.ReadResponse(upstreamReader, out)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err.Error(),
+			"destination": r.Host,
+		}).Error("Upgrade request to upstream failed")
+		writeErrorResponse(w, hoverflyError(r, err, "Upgrade request to upstream failed", http.StatusBadGateway, ErrorCodeUpstreamUnreachable, errorFormat(r)))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		for k, values := range resp.Header {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return
+	}
+
+	client, clientReader, err := hijackForUpgrade(w)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to take over client connection for upgrade")
+		return
+	}
+	defer client.Close()
+
+	record := &UpgradeRecord{Request: webSocketRequestDetails(r), Headers: resp.Header, Started: time.Now()}
+	if err := writeSwitchingProtocols(client, resp.Header); err != nil {
+		return
+	}
+
+	var mu sync.Mutex
+	relay := func(from io.Reader, to net.Conn, direction string, done chan<- struct{}) {
+		defer close(done)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := from.Read(buf)
+			if n > 0 {
+				mu.Lock()
+				record.Frames = append(record.Frames, UpgradeFrame{Direction: direction, Payload: append([]byte(nil), buf[:n]...), Timestamp: time.Now()})
+				mu.Unlock()
+				if _, werr := to.Write(buf[:n]); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	fromClient, fromServer := make(chan struct{}), make(chan struct{})
+	go relay(clientReader, upstream, WebSocketFromClient, fromClient)
+	go relay(upstreamReader, client, WebSocketFromServer, fromServer)
+
+	// connection is over when either side closes it
+	select {
+	case <-fromClient:
+	case <-fromServer:
+	}
+	client.Close()
+	upstream.Close()
+	<-fromClient
+	<-fromServer
+
+	d.saveUpgradeRecord(d.getRequestFingerprint(r, nil), record)
+}
+
+// simulateUpgrade - completes upgrade handshake with recorded headers and replays recorded server bytes at their
+// recorded offsets. Server bytes that followed client bytes are sent once the client sent as many bytes as the
+// captured one did before them.
+func (d *Hoverfly) simulateUpgrade(w http.ResponseWriter, r *http.Request) {
+	key := d.getRequestFingerprint(r, nil)
+	record, err := d.getUpgradeRecord(key)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err.Error(),
+			"key":         key,
+			"destination": r.Host,
+			"path":        r.URL.Path,
+		}).Warn("Failed to retrieve upgraded connection from cache")
+		writeErrorResponse(w, hoverflyError(r, err, "Could not find recorded upgraded connection, please record it first!", http.StatusPreconditionFailed, ErrorCodeCacheMiss, errorFormat(r)))
+		return
+	}
+
+	client, clientReader, err := hijackForUpgrade(w)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+		}).Error("Failed to take over client connection for upgrade")
+		return
+	}
+	defer client.Close()
+
+	if err := writeSwitchingProtocols(client, record.Headers); err != nil {
+		return
+	}
+	start := time.Now()
+
+	var expected, received int
+	buf := make([]byte, 32*1024)
+	for _, frame := range record.Frames {
+		if frame.Direction == WebSocketFromClient {
+			expected += len(frame.Payload)
+			continue
+		}
+		for received < expected {
+			n, err := clientReader.Read(buf)
+			received += n
+			if err != nil {
+				return
+			}
+		}
+		if offset := frame.Timestamp.Sub(record.Started); offset > 0 {
+			time.Sleep(time.Until(start.Add(offset)))
+		}
+		if _, err := client.Write(frame.Payload); err != nil {
+			return
+		}
+	}
+}
+
+// hijackForUpgrade - takes over client connection, returned reader holds bytes client sent after the request
+func hijackForUpgrade(w http.ResponseWriter) (net.Conn, io.Reader, error) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer doesn't support hijacking")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, brw.Reader, nil
+}
+
+// writeSwitchingProtocols - writes '101 Switching Protocols' response with given headers
+func writeSwitchingProtocols(conn net.Conn, header http.Header) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "HTTP/1.1 %d %s\r\n", http.StatusSwitchingProtocols, http.StatusText(http.StatusSwitchingProtocols))
+	header.Write(&buf)
+	buf.WriteString("\r\n")
+	_, err := conn.Write(buf.Bytes())
+	return err
+}
+
+func (d *Hoverfly) saveUpgradeRecord(key string, record *UpgradeRecord) {
+	bts, err := json.Marshal(record)
+	if err == nil {
+		err = d.MetadataCache.Set([]byte(upgradeKeyPrefix+key), bts)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Error("Failed to save upgraded connection")
+		return
+	}
+	log.WithFields(log.Fields{
+		"key":         key,
+		"destination": record.Request.Destination,
+		"path":        record.Request.Path,
+		"upgrade":     http.Header(record.Request.Headers).Get("Upgrade"),
+		"frames":      len(record.Frames),
+	}).Info("Upgraded connection captured")
+}
+
+func (d *Hoverfly) getUpgradeRecord(key string) (*UpgradeRecord, error) {
+	bts, err := d.MetadataCache.Get([]byte(upgradeKeyPrefix + key))
+	if err != nil {
+		return nil, fmt.Errorf("upgraded connection '%s' not found", key)
+	}
+	var record UpgradeRecord
+	err = json.Unmarshal(bts, &record)
+	return &record, err
+}
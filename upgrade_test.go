@@ -0,0 +1,182 @@
+package hoverfly
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+var upgradeGreeting = []byte{0x00, 0x01, 0xfe, 0xff}
+
+// customUpgradeUpstream - switches to 'myproto/1.0', greets client with binary bytes and answers 'ping' with 'pong'
+func customUpgradeUpstream() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "myproto/1.0" {
+			w.WriteHeader(http.StatusUpgradeRequired)
+			return
+		}
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: myproto/1.0\r\n\r\n")
+		conn.Write(upgradeGreeting)
+
+		ping := make([]byte, 4)
+		if _, err := io.ReadFull(brw, ping); err != nil || string(ping) != "ping" {
+			return
+		}
+		io.WriteString(conn, "pong")
+	}))
+}
+
+func upgradeProxy(dbClient *Hoverfly) *httptest.Server {
+	dbClient.Cfg.CaptureUpgrades = true
+	dbClient.UpdateProxy()
+	return httptest.NewServer(&upgradeHandler{handler: dbClient.Proxy, hf: dbClient})
+}
+
+// upgradeThroughProxy - sends upgrade request to proxy and returns its response and the connection
+func upgradeThroughProxy(t *testing.T, proxy *httptest.Server, target string) (*http.Response, net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+	testutil.Expect(t, err, nil)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, err := http.NewRequest("GET", target, nil)
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "myproto/1.0")
+	testutil.Expect(t, req.WriteProxy(conn), nil)
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	testutil.Expect(t, err, nil)
+	return resp, conn, reader
+}
+
+func exchangeCustomProtocol(t *testing.T, conn net.Conn, reader *bufio.Reader) {
+	greeting := make([]byte, len(upgradeGreeting))
+	_, err := io.ReadFull(reader, greeting)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(greeting), string(upgradeGreeting))
+
+	_, err = io.WriteString(conn, "ping")
+	testutil.Expect(t, err, nil)
+	rest, err := ioutil.ReadAll(reader)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(rest), "pong")
+}
+
+func TestCaptureAndSimulateCustomUpgrade(t *testing.T) {
+	upstream := customUpgradeUpstream()
+	target := upstream.URL + "/stream"
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	proxy := upgradeProxy(dbClient)
+	defer proxy.Close()
+
+	resp, conn, reader := upgradeThroughProxy(t, proxy, target)
+	testutil.Expect(t, resp.StatusCode, http.StatusSwitchingProtocols)
+	testutil.Expect(t, resp.Header.Get("Upgrade"), "myproto/1.0")
+	exchangeCustomProtocol(t, conn, reader)
+	conn.Close()
+
+	req, err := http.NewRequest("GET", target, nil)
+	testutil.Expect(t, err, nil)
+	key := dbClient.getRequestFingerprint(req, nil)
+
+	var record *UpgradeRecord
+	for i := 0; i < 100; i++ {
+		if record, err = dbClient.getUpgradeRecord(key); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, record.Request.Path, "/stream")
+	testutil.Expect(t, len(record.Frames) >= 3, true)
+	testutil.Expect(t, record.Frames[0].Direction, WebSocketFromServer)
+	testutil.Expect(t, string(record.Frames[0].Payload), string(upgradeGreeting))
+	testutil.Expect(t, record.Frames[1].Direction, WebSocketFromClient)
+	testutil.Expect(t, string(record.Frames[1].Payload), "ping")
+
+	// upstream is not needed to replay the connection
+	upstream.Close()
+	dbClient.Cfg.SetMode(SimulateMode)
+
+	resp, conn, reader = upgradeThroughProxy(t, proxy, target)
+	defer conn.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusSwitchingProtocols)
+	testutil.Expect(t, resp.Header.Get("Upgrade"), "myproto/1.0")
+	exchangeCustomProtocol(t, conn, reader)
+}
+
+func TestSimulateCustomUpgradeNotCaptured(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	proxy := upgradeProxy(dbClient)
+	defer proxy.Close()
+
+	resp, conn, _ := upgradeThroughProxy(t, proxy, "http://127.0.0.1:1/missing")
+	defer conn.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+	testutil.Expect(t, resp.Header.Get(ErrorCodeHeader), string(ErrorCodeCacheMiss))
+}
+
+func TestCustomUpgradeRejectedByUpstreamIsPassedThrough(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUpgradeRequired)
+		io.WriteString(w, "not supported")
+	}))
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	proxy := upgradeProxy(dbClient)
+	defer proxy.Close()
+
+	resp, conn, _ := upgradeThroughProxy(t, proxy, upstream.URL+"/stream")
+	defer conn.Close()
+	testutil.Expect(t, resp.StatusCode, http.StatusUpgradeRequired)
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(body), "not supported")
+}
+
+func TestIsCustomUpgrade(t *testing.T) {
+	for _, tc := range []struct {
+		connection, upgrade string
+		expected            bool
+	}{
+		{"Upgrade", "myproto/1.0", true},
+		{"keep-alive, Upgrade", "h2c", true},
+		{"Upgrade", "websocket", false},
+		{"keep-alive", "myproto/1.0", false},
+		{"Upgrade", "", false},
+	} {
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set("Connection", tc.connection)
+		if tc.upgrade != "" {
+			req.Header.Set("Upgrade", tc.upgrade)
+		}
+		testutil.Expect(t, isCustomUpgrade(req), tc.expected)
+	}
+}
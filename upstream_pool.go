@@ -0,0 +1,352 @@
+package hoverfly
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// load balancing policies of UpstreamHoverflyPool
+const (
+	LoadBalancingRoundRobin       = "round_robin"
+	LoadBalancingLeastConnections = "least_connections"
+	LoadBalancingRandom           = "random"
+)
+
+// UpstreamHealthPath - path of upstream Hoverfly admin API that is checked to keep instance in rotation
+const UpstreamHealthPath = "/healthz"
+
+// DefaultUpstreamHealthCheckInterval - how often upstream Hoverfly instances are checked when interval isn't set
+const DefaultUpstreamHealthCheckInterval = 10 * time.Second
+
+// upstreamHealthCheckTimeout - instance that doesn't answer health check in time is removed from rotation
+const upstreamHealthCheckTimeout = 2 * time.Second
+
+// upstreamInstance - upstream Hoverfly proxy, active counts requests that are waiting for response or which
+// response body wasn't closed yet
+type upstreamInstance struct {
+	proxy   *url.URL
+	health  string
+	active  int64
+	healthy int32
+}
+
+func (i *upstreamInstance) isHealthy() bool {
+	return atomic.LoadInt32(&i.healthy) == 1
+}
+
+// setHealthy - returns true when health changed
+func (i *upstreamInstance) setHealthy(healthy bool) bool {
+	var v int32
+	if healthy {
+		v = 1
+	}
+	return atomic.SwapInt32(&i.healthy, v) != v
+}
+
+// upstreamPool - upstream Hoverfly instances that outgoing requests are distributed across
+type upstreamPool struct {
+	instances []*upstreamInstance
+	policy    string
+	interval  time.Duration
+	client    *http.Client
+
+	mu   sync.Mutex
+	next int
+	rand *rand.Rand
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// ParseUpstreamHoverfly - parses 'proxy[=admin]' (i.e. 'http://10.0.0.1:8500=http://10.0.0.1:8888'), admin API
+// that is health checked defaults to proxy host with DefaultAdminPort
+func ParseUpstreamHoverfly(value string) (*url.URL, string, error) {
+	parts := strings.SplitN(value, "=", 2)
+	proxy, err := url.Parse(parts[0])
+	if err != nil || proxy.Host == "" || (proxy.Scheme != "http" && proxy.Scheme != "https") {
+		return nil, "", fmt.Errorf("invalid upstream Hoverfly '%s', expected i.e. 'http://10.0.0.1:8500'", parts[0])
+	}
+
+	admin := proxy.Scheme + "://" + net.JoinHostPort(proxy.Hostname(), DefaultAdminPort)
+	if len(parts) == 2 {
+		adminURL, err := url.Parse(parts[1])
+		if err != nil || adminURL.Host == "" || (adminURL.Scheme != "http" && adminURL.Scheme != "https") {
+			return nil, "", fmt.Errorf("invalid admin API of upstream Hoverfly '%s', expected i.e. 'http://10.0.0.1:8888'", parts[1])
+		}
+		admin = strings.TrimSuffix(adminURL.String(), "/")
+	}
+	return proxy, admin + UpstreamHealthPath, nil
+}
+
+// validateUpstreamHoverflyPool - checks upstream instances and load balancing policy, empty pool is valid
+func validateUpstreamHoverflyPool(c *Configuration) error {
+	if len(c.UpstreamHoverflyPool) == 0 {
+		return nil
+	}
+	_, err := newUpstreamPool(c.UpstreamHoverflyPool, c.LoadBalancingPolicy, c.UpstreamHealthCheckInterval)
+	return err
+}
+
+func newUpstreamPool(instances []string, policy string, interval time.Duration) (*upstreamPool, error) {
+	switch policy {
+	case "":
+		policy = LoadBalancingRoundRobin
+	case LoadBalancingRoundRobin, LoadBalancingLeastConnections, LoadBalancingRandom:
+	default:
+		return nil, fmt.Errorf("unknown load balancing policy '%s', expected '%s', '%s' or '%s'",
+			policy, LoadBalancingRoundRobin, LoadBalancingLeastConnections, LoadBalancingRandom)
+	}
+	if interval <= 0 {
+		interval = DefaultUpstreamHealthCheckInterval
+	}
+
+	p := &upstreamPool{
+		policy:   policy,
+		interval: interval,
+		client:   &http.Client{Timeout: upstreamHealthCheckTimeout},
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, value := range instances {
+		proxy, health, err := ParseUpstreamHoverfly(value)
+		if err != nil {
+			return nil, err
+		}
+		// instances are in rotation until the first health check says otherwise
+		p.instances = append(p.instances, &upstreamInstance{proxy: proxy, health: health, healthy: 1})
+	}
+	return p, nil
+}
+
+// pick - chooses healthy instance according to policy, nil when none is healthy
+func (p *upstreamPool) pick() *upstreamInstance {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var healthy []*upstreamInstance
+	for _, instance := range p.instances {
+		if instance.isHealthy() {
+			healthy = append(healthy, instance)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	switch p.policy {
+	case LoadBalancingRandom:
+		return healthy[p.rand.Intn(len(healthy))]
+	case LoadBalancingLeastConnections:
+		least := healthy[0]
+		for _, instance := range healthy[1:] {
+			if atomic.LoadInt64(&instance.active) < atomic.LoadInt64(&least.active) {
+				least = instance
+			}
+		}
+		return least
+	default:
+		instance := healthy[p.next%len(healthy)]
+		p.next++
+		return instance
+	}
+}
+
+// checkHealth - checks every instance, instances that don't respond with 200 are removed from rotation
+func (p *upstreamPool) checkHealth() {
+	var wg sync.WaitGroup
+	for _, instance := range p.instances {
+		wg.Add(1)
+		go func(instance *upstreamInstance) {
+			defer wg.Done()
+
+			healthy := false
+			resp, err := p.client.Get(instance.health)
+			if err == nil {
+				healthy = resp.StatusCode == http.StatusOK
+				resp.Body.Close()
+			}
+			if instance.setHealthy(healthy) {
+				fields := log.Fields{
+					"upstream": instance.proxy.String(),
+					"health":   instance.health,
+				}
+				if err != nil {
+					fields["error"] = err.Error()
+				}
+				if healthy {
+					log.WithFields(fields).Info("upstream Hoverfly is healthy again, added to rotation")
+				} else {
+					log.WithFields(fields).Warn("upstream Hoverfly health check failed, removed from rotation")
+				}
+			}
+		}(instance)
+	}
+	wg.Wait()
+}
+
+// start - checks health every interval until stopped
+func (p *upstreamPool) start() {
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		p.checkHealth()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.checkHealth()
+			}
+		}
+	}()
+}
+
+func (p *upstreamPool) close() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+	p.stop = nil
+}
+
+type upstreamInstanceKey struct{}
+
+// upstreamPoolTransport - sends requests through instance picked from pool, requests that fail to reach
+// instance remove it from rotation until health check passes again
+type upstreamPoolTransport struct {
+	pool *upstreamPool
+	base *http.Transport
+}
+
+func (t *upstreamPoolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	instance := t.pool.pick()
+	if instance == nil {
+		return nil, fmt.Errorf("no healthy upstream Hoverfly instances")
+	}
+
+	atomic.AddInt64(&instance.active, 1)
+	resp, err := t.base.RoundTrip(req.WithContext(context.WithValue(req.Context(), upstreamInstanceKey{}, instance)))
+	if err != nil {
+		atomic.AddInt64(&instance.active, -1)
+		if req.Context().Err() == nil && instance.setHealthy(false) {
+			log.WithFields(log.Fields{
+				"upstream": instance.proxy.String(),
+				"error":    err.Error(),
+			}).Warn("upstream Hoverfly is unreachable, removed from rotation")
+		}
+		return nil, err
+	}
+	resp.Body = &upstreamBody{ReadCloser: resp.Body, instance: instance}
+	return resp, nil
+}
+
+// upstreamBody - releases instance connection once response body is closed
+type upstreamBody struct {
+	io.ReadCloser
+	instance *upstreamInstance
+	once     sync.Once
+}
+
+func (b *upstreamBody) Close() error {
+	b.once.Do(func() { atomic.AddInt64(&b.instance.active, -1) })
+	return b.ReadCloser.Close()
+}
+
+// httpTransport - transport of HTTP client, unwrapped when requests go through upstream Hoverfly pool or Digest
+// authentication
+func (d *Hoverfly) httpTransport() (*http.Transport, bool) {
+	return baseTransport(d.HTTP.Transport)
+}
+
+func baseTransport(rt http.RoundTripper) (*http.Transport, bool) {
+	switch transport := rt.(type) {
+	case *http.Transport:
+		return transport, true
+	case *upstreamPoolTransport:
+		return transport.base, true
+	case *digestAuthTransport:
+		return baseTransport(transport.base)
+	}
+	return nil, false
+}
+
+// StartUpstreamHoverflyPool - distributes outgoing requests across Cfg.UpstreamHoverflyPool according to
+// Cfg.LoadBalancingPolicy and starts health checking instances. HTTP client is replaced, so the pool has to be
+// started before the proxy.
+func (d *Hoverfly) StartUpstreamHoverflyPool() error {
+	pool, err := newUpstreamPool(d.Cfg.UpstreamHoverflyPool, d.Cfg.LoadBalancingPolicy, d.Cfg.UpstreamHealthCheckInterval)
+	if err != nil {
+		return err
+	}
+	if len(pool.instances) == 0 {
+		return fmt.Errorf("upstream Hoverfly pool is empty")
+	}
+
+	transport, ok := d.httpTransport()
+	if !ok {
+		return fmt.Errorf("HTTP client doesn't use http.Transport, upstream Hoverfly pool can't be applied")
+	}
+
+	d.StopUpstreamHoverflyPool()
+
+	// transport and client are replaced instead of changed, requests in flight keep using the old ones
+	pooled := transport.Clone()
+	direct := transport.Proxy
+	pooled.Proxy = func(req *http.Request) (*url.URL, error) {
+		if instance, ok := req.Context().Value(upstreamInstanceKey{}).(*upstreamInstance); ok {
+			return instance.proxy, nil
+		}
+		if direct != nil {
+			return direct(req)
+		}
+		return nil, nil
+	}
+	// idle connections were opened without pool
+	transport.CloseIdleConnections()
+
+	client := *d.HTTP
+	client.Transport = &upstreamPoolTransport{pool: pool, base: pooled}
+	// challenges are answered before requests reach the pool
+	if digest, ok := d.HTTP.Transport.(*digestAuthTransport); ok {
+		client.Transport = newDigestAuthTransport(client.Transport, digest.config)
+	}
+
+	d.mu.Lock()
+	d.upstreamPool = pool
+	d.HTTP = &client
+	d.mu.Unlock()
+
+	pool.start()
+
+	log.WithFields(log.Fields{
+		"upstreams": d.Cfg.UpstreamHoverflyPool,
+		"policy":    pool.policy,
+		"interval":  pool.interval.String(),
+	}).Info("outgoing requests are load balanced across upstream Hoverfly instances")
+	return nil
+}
+
+// StopUpstreamHoverflyPool - stops health checks, requests keep going through the pool
+func (d *Hoverfly) StopUpstreamHoverflyPool() {
+	d.mu.Lock()
+	pool := d.upstreamPool
+	d.mu.Unlock()
+	if pool != nil {
+		pool.close()
+	}
+}
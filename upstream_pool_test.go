@@ -0,0 +1,234 @@
+package hoverfly
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+// fakeUpstreamHoverfly - proxy that answers with its name and admin API which health can be switched
+type fakeUpstreamHoverfly struct {
+	proxy   *httptest.Server
+	admin   *httptest.Server
+	healthy int32
+}
+
+func newFakeUpstreamHoverfly(name string) *fakeUpstreamHoverfly {
+	f := &fakeUpstreamHoverfly{healthy: 1}
+	f.proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", name, r.Host)
+	}))
+	f.admin = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != UpstreamHealthPath || atomic.LoadInt32(&f.healthy) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	return f
+}
+
+func (f *fakeUpstreamHoverfly) address() string {
+	return f.proxy.URL + "=" + f.admin.URL
+}
+
+func (f *fakeUpstreamHoverfly) close() {
+	f.proxy.Close()
+	f.admin.Close()
+}
+
+func upstreamPoolHoverfly(t *testing.T, policy string, upstreams ...*fakeUpstreamHoverfly) (*Hoverfly, func()) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	dbClient.HTTP = &http.Client{Transport: &http.Transport{}}
+	dbClient.Cfg.LoadBalancingPolicy = policy
+	// health is checked explicitly in tests
+	dbClient.Cfg.UpstreamHealthCheckInterval = time.Hour
+	for _, upstream := range upstreams {
+		dbClient.Cfg.UpstreamHoverflyPool = append(dbClient.Cfg.UpstreamHoverflyPool, upstream.address())
+	}
+
+	testutil.Expect(t, dbClient.StartUpstreamHoverflyPool(), nil)
+	// waiting for the first health check
+	dbClient.StopUpstreamHoverflyPool()
+
+	return dbClient, func() {
+		server.Close()
+	}
+}
+
+func upstreamPoolGet(t *testing.T, d *Hoverfly) string {
+	resp, err := d.HTTP.Get("http://api.example.com/items")
+	testutil.Expect(t, err, nil)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+	return string(body)
+}
+
+func TestUpstreamHoverflyPoolRoundRobin(t *testing.T) {
+	first, second := newFakeUpstreamHoverfly("first"), newFakeUpstreamHoverfly("second")
+	defer first.close()
+	defer second.close()
+
+	dbClient, cleanup := upstreamPoolHoverfly(t, LoadBalancingRoundRobin, first, second)
+	defer cleanup()
+
+	testutil.Expect(t, upstreamPoolGet(t, dbClient), "first api.example.com")
+	testutil.Expect(t, upstreamPoolGet(t, dbClient), "second api.example.com")
+	testutil.Expect(t, upstreamPoolGet(t, dbClient), "first api.example.com")
+}
+
+func TestUpstreamHoverflyPoolHealthCheck(t *testing.T) {
+	first, second := newFakeUpstreamHoverfly("first"), newFakeUpstreamHoverfly("second")
+	defer first.close()
+	defer second.close()
+
+	dbClient, cleanup := upstreamPoolHoverfly(t, LoadBalancingRoundRobin, first, second)
+	defer cleanup()
+
+	atomic.StoreInt32(&first.healthy, 0)
+	dbClient.upstreamPool.checkHealth()
+	for i := 0; i < 3; i++ {
+		testutil.Expect(t, upstreamPoolGet(t, dbClient), "second api.example.com")
+	}
+
+	atomic.StoreInt32(&second.healthy, 0)
+	dbClient.upstreamPool.checkHealth()
+	_, err := dbClient.HTTP.Get("http://api.example.com/items")
+	testutil.Refute(t, err, nil)
+
+	// instances are back in rotation once they pass health check
+	atomic.StoreInt32(&first.healthy, 1)
+	dbClient.upstreamPool.checkHealth()
+	testutil.Expect(t, upstreamPoolGet(t, dbClient), "first api.example.com")
+}
+
+func TestUpstreamHoverflyPoolRemovesUnreachableInstance(t *testing.T) {
+	first, second := newFakeUpstreamHoverfly("first"), newFakeUpstreamHoverfly("second")
+	defer second.close()
+
+	dbClient, cleanup := upstreamPoolHoverfly(t, LoadBalancingRoundRobin, first, second)
+	defer cleanup()
+
+	first.close()
+	_, err := dbClient.HTTP.Get("http://api.example.com/items")
+	testutil.Refute(t, err, nil)
+
+	for i := 0; i < 3; i++ {
+		testutil.Expect(t, upstreamPoolGet(t, dbClient), "second api.example.com")
+	}
+}
+
+func TestUpstreamHoverflyPoolLeastConnections(t *testing.T) {
+	pool, err := newUpstreamPool([]string{"http://10.0.0.1:8500", "http://10.0.0.2:8500", "http://10.0.0.3:8500"}, LoadBalancingLeastConnections, 0)
+	testutil.Expect(t, err, nil)
+
+	pool.instances[0].active = 2
+	pool.instances[1].active = 1
+	pool.instances[2].active = 3
+	testutil.Expect(t, pool.pick() == pool.instances[1], true)
+
+	pool.instances[1].setHealthy(false)
+	testutil.Expect(t, pool.pick() == pool.instances[0], true)
+}
+
+func TestUpstreamHoverflyPoolLeastConnectionsReleasesOnBodyClose(t *testing.T) {
+	first := newFakeUpstreamHoverfly("first")
+	defer first.close()
+
+	dbClient, cleanup := upstreamPoolHoverfly(t, LoadBalancingLeastConnections, first)
+	defer cleanup()
+
+	resp, err := dbClient.HTTP.Get("http://api.example.com/items")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, atomic.LoadInt64(&dbClient.upstreamPool.instances[0].active), int64(1))
+
+	resp.Body.Close()
+	resp.Body.Close()
+	testutil.Expect(t, atomic.LoadInt64(&dbClient.upstreamPool.instances[0].active), int64(0))
+}
+
+func TestUpstreamHoverflyPoolRandom(t *testing.T) {
+	pool, err := newUpstreamPool([]string{"http://10.0.0.1:8500", "http://10.0.0.2:8500"}, LoadBalancingRandom, 0)
+	testutil.Expect(t, err, nil)
+
+	picked := map[*upstreamInstance]bool{}
+	for i := 0; i < 100; i++ {
+		picked[pool.pick()] = true
+	}
+	testutil.Expect(t, len(picked), 2)
+}
+
+func TestParseUpstreamHoverfly(t *testing.T) {
+	proxy, health, err := ParseUpstreamHoverfly("http://10.0.0.1:8500")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, proxy.String(), "http://10.0.0.1:8500")
+	testutil.Expect(t, health, "http://10.0.0.1:8888/healthz")
+
+	_, health, err = ParseUpstreamHoverfly("http://10.0.0.1:8500=https://admin.example.com:9000/")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, health, "https://admin.example.com:9000/healthz")
+
+	for _, value := range []string{"10.0.0.1:8500", "ftp://10.0.0.1", "http://10.0.0.1:8500=admin"} {
+		_, _, err := ParseUpstreamHoverfly(value)
+		testutil.Refute(t, err, nil)
+	}
+}
+
+func TestValidateUpstreamHoverflyPool(t *testing.T) {
+	cfg := InitSettings()
+	testutil.Expect(t, validateUpstreamHoverflyPool(cfg), nil)
+
+	cfg.UpstreamHoverflyPool = []string{"http://10.0.0.1:8500"}
+	cfg.LoadBalancingPolicy = "fastest"
+	testutil.Refute(t, validateUpstreamHoverflyPool(cfg), nil)
+}
+
+func TestHealthzAlias(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	m := getBoneRouter(*dbClient)
+
+	req, err := http.NewRequest("GET", UpstreamHealthPath, nil)
+	testutil.Expect(t, err, nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusOK)
+}
+
+func TestUpstreamHoverflyPoolKeepsDigestAuth(t *testing.T) {
+	upstream := newFakeUpstreamHoverfly("first")
+	defer upstream.close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	dbClient.HTTP = &http.Client{Transport: newDigestAuthTransport(&http.Transport{}, DigestAuthConfig{Username: "Mufasa", Password: "Circle Of Life", Hosts: []string{"api.example.com"}})}
+	dbClient.Cfg.UpstreamHealthCheckInterval = time.Hour
+	dbClient.Cfg.UpstreamHoverflyPool = []string{upstream.address()}
+
+	// requests in flight keep using the previous client
+	previous := dbClient.HTTP
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			if resp, err := previous.Get(upstream.proxy.URL); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}()
+
+	testutil.Expect(t, dbClient.StartUpstreamHoverflyPool(), nil)
+	dbClient.StopUpstreamHoverflyPool()
+	<-done
+
+	digest, ok := dbClient.HTTP.Transport.(*digestAuthTransport)
+	testutil.Expect(t, ok, true)
+	_, ok = digest.base.(*upstreamPoolTransport)
+	testutil.Expect(t, ok, true)
+	testutil.Expect(t, upstreamPoolGet(t, dbClient), "first api.example.com")
+}
@@ -0,0 +1,257 @@
+package hoverfly
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func webauthnTestCredential(t *testing.T) (WebAuthnCredential, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testutil.Expect(t, err, nil)
+	der, err := x509.MarshalECPrivateKey(key)
+	testutil.Expect(t, err, nil)
+
+	return WebAuthnCredential{
+		ID:         webauthnEncode([]byte{1, 2, 3, 4}),
+		UserHandle: webauthnEncode([]byte("user-1")),
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})),
+	}, key
+}
+
+func webauthnHoverfly(t *testing.T) (*Hoverfly, *ecdsa.PrivateKey, func()) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.SimulatedWebAuthn = &WebAuthnSimulator{
+		Endpoint: "https://auth.example.com/webauthn",
+		RPID:     "example.com",
+	}
+
+	credential, key := webauthnTestCredential(t)
+	testutil.Expect(t, dbClient.RegisterWebAuthnCredential(credential), nil)
+
+	return dbClient, key, func() {
+		dbClient.MetadataCache.DeleteData()
+		server.Close()
+	}
+}
+
+func webauthnPost(t *testing.T, dbClient *Hoverfly, path, body, accept string) *http.Response {
+	req, err := http.NewRequest("POST", "http://auth.example.com/webauthn"+path, strings.NewReader(body))
+	testutil.Expect(t, err, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	_, resp := dbClient.processRequest(req)
+	return resp
+}
+
+// verifyWebAuthnAssertion - checks authenticator data and ES256 signature the way relying party does
+func verifyWebAuthnAssertion(t *testing.T, key *ecdsa.PrivateKey, clientData, authData, signature []byte, signCount uint32) {
+	rpIDHash := sha256.Sum256([]byte("example.com"))
+	testutil.Expect(t, len(authData), 37)
+	testutil.Expect(t, bytes.Equal(authData[:32], rpIDHash[:]), true)
+	testutil.Expect(t, authData[32]&0x01, byte(0x01))
+	testutil.Expect(t, binary.BigEndian.Uint32(authData[33:]), signCount)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+
+	var sig ecdsaSignature
+	_, err := asn1.Unmarshal(signature, &sig)
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, ecdsa.Verify(&key.PublicKey, digest[:], sig.R, sig.S), true)
+}
+
+func TestSimulatedWebAuthnCeremony(t *testing.T) {
+	dbClient, key, cleanup := webauthnHoverfly(t)
+	defer cleanup()
+
+	resp := webauthnPost(t, dbClient, "/assertion/options", `{"username": "user-1"}`, "")
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+
+	var options webauthnOptionsResponse
+	testutil.Expect(t, json.NewDecoder(resp.Body).Decode(&options), nil)
+	testutil.Expect(t, options.Status, "ok")
+	testutil.Expect(t, options.RPID, "example.com")
+	testutil.Expect(t, options.Timeout, defaultWebAuthnTimeout)
+	testutil.Expect(t, len(options.AllowCredentials), 1)
+	testutil.Expect(t, options.AllowCredentials[0].ID, "AQIDBA")
+	testutil.Refute(t, options.Challenge, "")
+
+	for signCount := uint32(1); signCount <= 2; signCount++ {
+		resp = webauthnPost(t, dbClient, "/assertion/result", "", "")
+		testutil.Expect(t, resp.StatusCode, http.StatusOK)
+
+		var result webauthnResultResponse
+		testutil.Expect(t, json.NewDecoder(resp.Body).Decode(&result), nil)
+		testutil.Expect(t, result.ID, "AQIDBA")
+		testutil.Expect(t, result.Type, "public-key")
+		testutil.Expect(t, result.Response.UserHandle, webauthnEncode([]byte("user-1")))
+
+		clientData, err := webauthnDecode(result.Response.ClientDataJSON)
+		testutil.Expect(t, err, nil)
+		var collected webauthnClientData
+		testutil.Expect(t, json.Unmarshal(clientData, &collected), nil)
+		testutil.Expect(t, collected.Type, "webauthn.get")
+		testutil.Expect(t, collected.Challenge, options.Challenge)
+		testutil.Expect(t, collected.Origin, "https://example.com")
+
+		authData, err := webauthnDecode(result.Response.AuthenticatorData)
+		testutil.Expect(t, err, nil)
+		signature, err := webauthnDecode(result.Response.Signature)
+		testutil.Expect(t, err, nil)
+		verifyWebAuthnAssertion(t, key, clientData, authData, signature, signCount)
+	}
+}
+
+func TestSimulatedWebAuthnConcurrentAssertionsHaveDistinctCounters(t *testing.T) {
+	dbClient, _, cleanup := webauthnHoverfly(t)
+	defer cleanup()
+
+	resp := webauthnPost(t, dbClient, "/assertion/options", `{"username": "user-1"}`, "")
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+
+	const assertions = 10
+	counters := make(chan uint32, assertions)
+	var wg sync.WaitGroup
+	for i := 0; i < assertions; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := webauthnPost(t, dbClient, "/assertion/result", "", "")
+			var result webauthnResultResponse
+			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+				t.Error(err)
+				return
+			}
+			authData, _ := webauthnDecode(result.Response.AuthenticatorData)
+			counters <- binary.BigEndian.Uint32(authData[33:])
+		}()
+	}
+	wg.Wait()
+	close(counters)
+
+	seen := make(map[uint32]bool)
+	for counter := range counters {
+		testutil.Expect(t, seen[counter], false)
+		seen[counter] = true
+	}
+	testutil.Expect(t, len(seen), assertions)
+
+	credential, err := dbClient.webauthnCredential("AQIDBA")
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, credential.SignCount, uint32(assertions))
+}
+
+func TestSimulatedWebAuthnCBORResult(t *testing.T) {
+	dbClient, _, cleanup := webauthnHoverfly(t)
+	defer cleanup()
+
+	resp := webauthnPost(t, dbClient, "/assertion/result", `{"id": "AQIDBA", "challenge": "Y2hhbGxlbmdl"}`, "application/cbor")
+	testutil.Expect(t, resp.StatusCode, http.StatusOK)
+	testutil.Expect(t, resp.Header.Get("Content-Type"), "application/cbor")
+
+	body, err := ioutil.ReadAll(resp.Body)
+	testutil.Expect(t, err, nil)
+
+	// map of 4 entries, 1: {"id": h'01020304', "type": "public-key"}, 2: authenticator data
+	prefix := []byte{0xa4, 0x01, 0xa2, 0x62, 'i', 'd', 0x44, 1, 2, 3, 4, 0x64, 't', 'y', 'p', 'e', 0x6a}
+	testutil.Expect(t, bytes.HasPrefix(body, prefix), true)
+	authDataHeader := []byte("public-key\x02\x58\x25")
+	testutil.Expect(t, bytes.Contains(body, authDataHeader), true)
+}
+
+func TestSimulatedWebAuthnResultErrors(t *testing.T) {
+	dbClient, _, cleanup := webauthnHoverfly(t)
+	defer cleanup()
+
+	// options weren't requested
+	resp := webauthnPost(t, dbClient, "/assertion/result", "", "")
+	testutil.Expect(t, resp.StatusCode, http.StatusBadRequest)
+
+	resp = webauthnPost(t, dbClient, "/assertion/result", `{"id": "unknown", "challenge": "abc"}`, "")
+	testutil.Expect(t, resp.StatusCode, http.StatusBadRequest)
+}
+
+func TestSimulatedWebAuthnOtherRequestsSimulatedAsUsual(t *testing.T) {
+	dbClient, _, cleanup := webauthnHoverfly(t)
+	defer cleanup()
+
+	req, err := http.NewRequest("GET", "http://auth.example.com/webauthn/assertion/options", nil)
+	testutil.Expect(t, err, nil)
+	_, resp := dbClient.processRequest(req)
+	testutil.Expect(t, resp.Header.Get(ErrorCodeHeader), string(ErrorCodeCacheMiss))
+}
+
+func TestLoadSimulatedWebAuthn(t *testing.T) {
+	credential, _ := webauthnTestCredential(t)
+	config, err := json.Marshal(map[string]interface{}{
+		"endpoint":    "auth.example.com/webauthn",
+		"rpId":        "example.com",
+		"credentials": []WebAuthnCredential{credential},
+	})
+	testutil.Expect(t, err, nil)
+
+	f, err := ioutil.TempFile("", "webauthn")
+	testutil.Expect(t, err, nil)
+	defer os.Remove(f.Name())
+	f.Write(config)
+	f.Close()
+
+	cfg := InitSettings()
+	testutil.Expect(t, cfg.LoadSimulatedWebAuthn(f.Name()), nil)
+	testutil.Expect(t, cfg.SimulatedWebAuthn.RPID, "example.com")
+	testutil.Expect(t, len(cfg.SimulatedWebAuthn.Credentials), 1)
+
+	credential.PrivateKey = "not a key"
+	config, _ = json.Marshal(map[string]interface{}{
+		"endpoint":    "auth.example.com/webauthn",
+		"rpId":        "example.com",
+		"credentials": []WebAuthnCredential{credential},
+	})
+	ioutil.WriteFile(f.Name(), config, 0644)
+	testutil.Refute(t, cfg.LoadSimulatedWebAuthn(f.Name()), nil)
+}
+
+func TestCBOREncode(t *testing.T) {
+	// RFC 7049 appendix A examples
+	for _, example := range []struct {
+		value   interface{}
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{23, []byte{0x17}},
+		{24, []byte{0x18, 0x18}},
+		{1000, []byte{0x19, 0x03, 0xe8}},
+		{1000000, []byte{0x1a, 0x00, 0x0f, 0x42, 0x40}},
+		{-1, []byte{0x20}},
+		{-1000, []byte{0x39, 0x03, 0xe7}},
+		{"IETF", []byte{0x64, 0x49, 0x45, 0x54, 0x46}},
+		{[]byte{1, 2, 3, 4}, []byte{0x44, 0x01, 0x02, 0x03, 0x04}},
+		{[]interface{}{1, 2, 3}, []byte{0x83, 0x01, 0x02, 0x03}},
+		{cborMap{{1, 2}, {3, 4}}, []byte{0xa2, 0x01, 0x02, 0x03, 0x04}},
+	} {
+		encoded, err := cborEncode(example.value)
+		testutil.Expect(t, err, nil)
+		testutil.Expect(t, bytes.Equal(encoded, example.encoded), true)
+	}
+
+	_, err := cborEncode(1.5)
+	testutil.Refute(t, err, nil)
+}
@@ -0,0 +1,485 @@
+package hoverfly
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/gorilla/websocket"
+	"github.com/rusenask/goproxy"
+)
+
+// webSocketKeyPrefix - prefix for metadata keys that hold captured WebSocket connections
+const webSocketKeyPrefix = "websocket_"
+
+// WebSocketHandshakeTimeout - maximum time allowed for WebSocket handshake with upstream server
+const WebSocketHandshakeTimeout = 10 * time.Second
+
+// WebSocket frame directions
+const (
+	WebSocketFromClient = "client"
+	WebSocketFromServer = "server"
+)
+
+// WebSocketFrame - message sent over captured WebSocket connection. Opcode is websocket.TextMessage,
+// websocket.BinaryMessage or, for control frames, websocket.PingMessage (0x9) and websocket.PongMessage (0xA).
+type WebSocketFrame struct {
+	Direction string    `json:"direction"`
+	Opcode    int       `json:"opcode"`
+	Payload   []byte    `json:"payload"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// WebSocketRecord - captured WebSocket connection, it is matched by the Upgrade request the same way as
+// regular requests are
+type WebSocketRecord struct {
+	Request models.RequestDetails `json:"request"`
+	// Subprotocol - subprotocol selected by upstream server
+	Subprotocol string `json:"subprotocol,omitempty"`
+	// ConnectedAt - time the connection was established, ping frames are replayed at the same offset from it
+	ConnectedAt time.Time        `json:"connectedAt,omitempty"`
+	Frames      []WebSocketFrame `json:"frames"`
+}
+
+// webSocketHandshakeHeaders - headers that are generated by WebSocket client and must not be forwarded
+var webSocketHandshakeHeaders = []string{
+	"Upgrade",
+	"Connection",
+	"Proxy-Connection",
+	"Sec-Websocket-Key",
+	"Sec-Websocket-Version",
+	"Sec-Websocket-Extensions",
+	"Sec-Websocket-Protocol",
+	"Proxy-Authorization",
+}
+
+// webSocketHandler - captures and simulates WebSocket connections to intercepted hosts that are sent to the proxy
+// as regular requests when CaptureWebSocket is enabled. Connections tunneled through CONNECT are handled by
+// webSocketConnect.
+type webSocketHandler struct {
+	handler http.Handler
+	hf      *Hoverfly
+}
+
+func (h *webSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.hf.isWebSocketMode(r) || !websocket.IsWebSocketUpgrade(r) || !h.hf.Cfg.isDestination(r.Host) {
+		h.handler.ServeHTTP(w, r)
+		return
+	}
+	h.hf.serveWebSocket(w, r)
+}
+
+// isWebSocketMode - checks whether WebSocket connections are captured or simulated in mode of the request
+func (d *Hoverfly) isWebSocketMode(r *http.Request) bool {
+	mode := requestMode(r, d.Cfg)
+	return d.Cfg.CaptureWebSocket && (mode == CaptureMode || mode == SimulateMode)
+}
+
+func (d *Hoverfly) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	if requestMode(r, d.Cfg) == CaptureMode {
+		d.captureWebSocket(w, r)
+	} else {
+		d.simulateWebSocket(w, r)
+	}
+}
+
+// webSocketConnect - takes over CONNECT tunnels to intercepted hosts on ports other than 443 when WebSocket
+// connections are captured or simulated, i.e. 'ws' connections of clients that tunnel them through the proxy.
+// Port 443 tunnels are left to regular MITM.
+func (d *Hoverfly) webSocketConnect(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+	if !d.isWebSocketMode(ctx.Req) {
+		return nil, host
+	}
+	if _, port, err := net.SplitHostPort(host); err == nil && port == "443" {
+		return nil, host
+	}
+	return &goproxy.ConnectAction{Action: goproxy.ConnectHijack, Hijack: d.serveWebSocketTunnel}, host
+}
+
+// serveWebSocketTunnel - reads requests from hijacked CONNECT tunnel, TLS is terminated with Hoverfly CA.
+// WebSocket Upgrade requests are captured or simulated, other requests are processed and their responses
+// written back to the tunnel.
+func (d *Hoverfly) serveWebSocketTunnel(connect *http.Request, client net.Conn, ctx *goproxy.ProxyCtx) {
+	defer client.Close()
+
+	var conn net.Conn = &peekedConn{Conn: client, r: bufio.NewReader(client)}
+	scheme := "http"
+
+	// TLS handshake starts with 0x16 record type
+	if first, err := conn.(*peekedConn).r.Peek(1); err == nil && first[0] == 0x16 {
+		tlsConfig, err := goproxy.TLSConfigFromCA(&goproxy.GoproxyCa)(connect.Host, ctx)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"error": err.Error(),
+				"host":  connect.Host,
+			}).Error("Failed to create certificate for WebSocket tunnel")
+			return
+		}
+		conn = tls.Server(conn, tlsConfig)
+		scheme = "https"
+	}
+
+	reader := bufio.NewReader(conn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		req.RemoteAddr = connect.RemoteAddr
+		req.URL.Scheme = scheme
+		req.URL.Host = connect.Host
+		if mode, ok := fixedMode(connect); ok {
+			req = withFixedMode(req, mode)
+		}
+
+		if websocket.IsWebSocketUpgrade(req) {
+			w := &tunnelResponseWriter{conn: conn, brw: bufio.NewReadWriter(reader, bufio.NewWriter(conn)), header: make(http.Header)}
+			d.serveWebSocket(w, req)
+			return
+		}
+
+		_, resp := d.process(req)
+		if resp == nil {
+			return
+		}
+		err = resp.Write(conn)
+		resp.Body.Close()
+		if err != nil || req.Close || resp.Close {
+			return
+		}
+	}
+}
+
+// peekedConn - connection which first bytes were peeked at
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// tunnelResponseWriter - response writer for requests read from CONNECT tunnel, WebSocket upgrader hijacks it
+type tunnelResponseWriter struct {
+	conn        net.Conn
+	brw         *bufio.ReadWriter
+	header      http.Header
+	wroteHeader bool
+}
+
+func (w *tunnelResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *tunnelResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	// only error responses are written, connection isn't reused afterwards
+	w.header.Set("Connection", "close")
+	fmt.Fprintf(w.conn, "HTTP/1.1 %d %s\r\n", status, http.StatusText(status))
+	w.header.Write(w.conn)
+	io.WriteString(w.conn, "\r\n")
+}
+
+func (w *tunnelResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.conn.Write(b)
+}
+
+func (w *tunnelResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.wroteHeader = true
+	return w.conn, w.brw, nil
+}
+
+func webSocketUpgrader(subprotocol string) *websocket.Upgrader {
+	u := &websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin: func(r *http.Request) bool {
+			return true
+		},
+	}
+	if subprotocol != "" {
+		u.Subprotocols = []string{subprotocol}
+	}
+	return u
+}
+
+// captureWebSocket - connects to upstream server, relays messages between client and upstream and records them
+// once the connection is closed
+func (d *Hoverfly) captureWebSocket(w http.ResponseWriter, r *http.Request) {
+	header := cloneHeader(r.Header)
+	for _, h := range webSocketHandshakeHeaders {
+		header.Del(h)
+	}
+
+	scheme := "ws"
+	if r.URL.Scheme == "https" {
+		scheme = "wss"
+	}
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: WebSocketHandshakeTimeout,
+		Subprotocols:     websocket.Subprotocols(r),
+	}
+	upstream, resp, err := dialer.Dial(fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.RequestURI()), header)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err.Error(),
+			"destination": r.Host,
+			"path":        r.URL.Path,
+		}).Error("Could not connect to upstream WebSocket server")
+		writeErrorResponse(w, hoverflyError(r, err, "Could not connect to upstream WebSocket server", http.StatusBadGateway, ErrorCodeUpstreamUnreachable, errorFormat(r)))
+		return
+	}
+	defer upstream.Close()
+
+	subprotocol := resp.Header.Get("Sec-Websocket-Protocol")
+	client, err := webSocketUpgrader(subprotocol).Upgrade(w, r, nil)
+	if err != nil {
+		// upgrader has already responded to the client
+		log.WithFields(log.Fields{
+			"error":       err.Error(),
+			"destination": r.Host,
+		}).Warn("Failed to upgrade client WebSocket connection")
+		return
+	}
+	defer client.Close()
+
+	record := &WebSocketRecord{Request: webSocketRequestDetails(r), Subprotocol: subprotocol, ConnectedAt: time.Now()}
+	var mu sync.Mutex
+	appendFrame := func(direction string, opcode int, payload []byte) {
+		mu.Lock()
+		record.Frames = append(record.Frames, WebSocketFrame{Direction: direction, Opcode: opcode, Payload: payload, Timestamp: time.Now()})
+		mu.Unlock()
+	}
+	relay := func(from, to *websocket.Conn, direction string, done chan<- struct{}) {
+		defer close(done)
+		// control frames are handled while reading messages, they are recorded and relayed instead of being
+		// answered by the proxy so that the other side sees its own keepalive
+		for _, opcode := range []int{websocket.PingMessage, websocket.PongMessage} {
+			opcode := opcode
+			handler := func(data string) error {
+				appendFrame(direction, opcode, []byte(data))
+				err := to.WriteControl(opcode, []byte(data), time.Now().Add(time.Second))
+				if err == websocket.ErrCloseSent {
+					return nil
+				}
+				return err
+			}
+			if opcode == websocket.PingMessage {
+				from.SetPingHandler(handler)
+			} else {
+				from.SetPongHandler(handler)
+			}
+		}
+		for {
+			opcode, payload, err := from.ReadMessage()
+			if err != nil {
+				code := websocket.CloseNormalClosure
+				if ce, ok := err.(*websocket.CloseError); ok {
+					code = ce.Code
+				}
+				to.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(time.Second))
+				return
+			}
+			appendFrame(direction, opcode, payload)
+			if err := to.WriteMessage(opcode, payload); err != nil {
+				return
+			}
+		}
+	}
+
+	fromClient, fromServer := make(chan struct{}), make(chan struct{})
+	go relay(client, upstream, WebSocketFromClient, fromClient)
+	go relay(upstream, client, WebSocketFromServer, fromServer)
+
+	// connection is over when either side closes it
+	select {
+	case <-fromClient:
+	case <-fromServer:
+	}
+	client.Close()
+	upstream.Close()
+	<-fromClient
+	<-fromServer
+
+	d.saveWebSocketRecord(d.getRequestFingerprint(r, nil), record)
+}
+
+// simulateWebSocket - replays captured messages on client connection. Client messages are awaited before
+// the messages that followed them in captured connection are sent. Pings of upstream server are replayed when
+// WebSocketPingTimeout is set and the connection is failed if the client doesn't answer them in time, other
+// control frames are left out as client pings are answered as they come.
+func (d *Hoverfly) simulateWebSocket(w http.ResponseWriter, r *http.Request) {
+	key := d.getRequestFingerprint(r, nil)
+	record, err := d.getWebSocketRecord(key)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err.Error(),
+			"key":         key,
+			"destination": r.Host,
+			"path":        r.URL.Path,
+		}).Warn("Failed to retrieve WebSocket connection from cache")
+		writeErrorResponse(w, hoverflyError(r, err, "Could not find recorded WebSocket connection, please record it first!", http.StatusPreconditionFailed, ErrorCodeCacheMiss, errorFormat(r)))
+		return
+	}
+
+	client, err := webSocketUpgrader(record.Subprotocol).Upgrade(w, r, nil)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error":       err.Error(),
+			"destination": r.Host,
+		}).Warn("Failed to upgrade client WebSocket connection")
+		return
+	}
+	defer client.Close()
+
+	// control frames are only handled while reading, client is read in the background so that pongs arrive
+	// while messages are replayed. Messages are buffered so that reading doesn't wait for a pending ping.
+	messages, pongs, done := make(chan struct{}, len(record.Frames)), make(chan struct{}, 1), make(chan struct{})
+	defer close(done)
+	client.SetPongHandler(func(string) error {
+		select {
+		case pongs <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	go func() {
+		defer close(messages)
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+			select {
+			case messages <- struct{}{}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	pingTimeout := d.Cfg.WebSocketPingTimeout
+	previous := record.ConnectedAt
+	for _, frame := range record.Frames {
+		gap := frame.Timestamp.Sub(previous)
+		previous = frame.Timestamp
+
+		switch {
+		case frame.Opcode == websocket.PingMessage || frame.Opcode == websocket.PongMessage:
+			if frame.Opcode == websocket.PongMessage || frame.Direction == WebSocketFromClient || pingTimeout <= 0 {
+				continue
+			}
+			if !record.ConnectedAt.IsZero() && gap > 0 {
+				time.Sleep(gap)
+			}
+			if !pingWebSocketClient(client, frame.Payload, pongs, pingTimeout) {
+				log.WithFields(log.Fields{
+					"destination": r.Host,
+					"path":        r.URL.Path,
+					"timeout":     pingTimeout.String(),
+				}).Warn("WebSocket client did not answer ping, closing connection")
+				client.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "pong not received"), time.Now().Add(time.Second))
+				return
+			}
+		case frame.Direction == WebSocketFromClient:
+			if _, ok := <-messages; !ok {
+				return
+			}
+		default:
+			if err := client.WriteMessage(frame.Opcode, frame.Payload); err != nil {
+				return
+			}
+		}
+	}
+
+	client.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	// waiting for the client to acknowledge close
+	for range messages {
+	}
+}
+
+// pingWebSocketClient - sends ping to the client and waits for its pong, pongs received before the ping are
+// disregarded
+func pingWebSocketClient(client *websocket.Conn, payload []byte, pongs <-chan struct{}, timeout time.Duration) bool {
+	select {
+	case <-pongs:
+	default:
+	}
+	if err := client.WriteControl(websocket.PingMessage, payload, time.Now().Add(timeout)); err != nil {
+		return false
+	}
+	select {
+	case <-pongs:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// webSocketRequestDetails - Upgrade request details, handshake headers that differ between connections are left out
+func webSocketRequestDetails(r *http.Request) models.RequestDetails {
+	header := cloneHeader(r.Header)
+	header.Del("Sec-Websocket-Key")
+	return models.RequestDetails{
+		Path:        r.URL.Path,
+		Method:      r.Method,
+		Destination: r.Host,
+		Scheme:      r.URL.Scheme,
+		Query:       r.URL.RawQuery,
+		Headers:     header,
+	}
+}
+
+func (d *Hoverfly) saveWebSocketRecord(key string, record *WebSocketRecord) {
+	bts, err := json.Marshal(record)
+	if err == nil {
+		err = d.MetadataCache.Set([]byte(webSocketKeyPrefix+key), bts)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{
+			"error": err.Error(),
+			"key":   key,
+		}).Error("Failed to save WebSocket connection")
+		return
+	}
+	log.WithFields(log.Fields{
+		"key":         key,
+		"destination": record.Request.Destination,
+		"path":        record.Request.Path,
+		"frames":      len(record.Frames),
+	}).Info("WebSocket connection captured")
+}
+
+func (d *Hoverfly) getWebSocketRecord(key string) (*WebSocketRecord, error) {
+	bts, err := d.MetadataCache.Get([]byte(webSocketKeyPrefix + key))
+	if err != nil {
+		return nil, fmt.Errorf("WebSocket connection '%s' not found", key)
+	}
+	var record WebSocketRecord
+	err = json.Unmarshal(bts, &record)
+	return &record, err
+}
+
+// writeErrorResponse - writes Hoverfly error response to client that is served outside of the proxy
+func writeErrorResponse(w http.ResponseWriter, resp *http.Response) {
+	defer resp.Body.Close()
+	for k, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
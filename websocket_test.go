@@ -0,0 +1,336 @@
+package hoverfly
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/testutil"
+	"github.com/gorilla/websocket"
+)
+
+// webSocketUpstream - greets client and then replies to every message with the message in upper case
+func webSocketUpstream() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.WriteMessage(websocket.TextMessage, []byte("welcome"))
+		for {
+			opcode, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(opcode, []byte(strings.ToUpper(string(msg))))
+		}
+	}))
+}
+
+func webSocketProxy(t *testing.T, dbClient *Hoverfly) (*httptest.Server, *websocket.Dialer) {
+	dbClient.Cfg.CaptureWebSocket = true
+	dbClient.UpdateProxy()
+
+	proxy := httptest.NewServer(&webSocketHandler{handler: dbClient.Proxy, hf: dbClient})
+	proxyURL, err := url.Parse(proxy.URL)
+	testutil.Expect(t, err, nil)
+
+	return proxy, &websocket.Dialer{Proxy: http.ProxyURL(proxyURL), HandshakeTimeout: time.Second}
+}
+
+func chat(t *testing.T, conn *websocket.Conn) {
+	_, msg, err := conn.ReadMessage()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(msg), "welcome")
+
+	testutil.Expect(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")), nil)
+	_, msg, err = conn.ReadMessage()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(msg), "HELLO")
+}
+
+func TestCaptureAndSimulateWebSocket(t *testing.T) {
+	upstream := webSocketUpstream()
+	wsURL := "ws://" + upstream.Listener.Addr().String() + "/chat"
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	proxy, dialer := webSocketProxy(t, dbClient)
+	defer proxy.Close()
+
+	conn, _, err := dialer.Dial(wsURL, nil)
+	testutil.Expect(t, err, nil)
+	chat(t, conn)
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	conn.Close()
+
+	// connection is saved once both sides are closed
+	req, err := http.NewRequest("GET", wsURL, nil)
+	testutil.Expect(t, err, nil)
+	key := dbClient.getRequestFingerprint(req, nil)
+
+	var record *WebSocketRecord
+	for i := 0; i < 100; i++ {
+		if record, err = dbClient.getWebSocketRecord(key); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, record.Request.Path, "/chat")
+	testutil.Expect(t, len(record.Frames), 3)
+	testutil.Expect(t, record.Frames[0].Direction, WebSocketFromServer)
+	testutil.Expect(t, record.Frames[1].Direction, WebSocketFromClient)
+	testutil.Expect(t, record.Frames[1].Opcode, websocket.TextMessage)
+	testutil.Expect(t, string(record.Frames[1].Payload), "hello")
+	testutil.Expect(t, string(record.Frames[2].Payload), "HELLO")
+
+	// upstream is not needed to replay the connection
+	upstream.Close()
+	dbClient.Cfg.SetMode(SimulateMode)
+
+	conn, _, err = dialer.Dial(wsURL, nil)
+	testutil.Expect(t, err, nil)
+	defer conn.Close()
+	chat(t, conn)
+
+	_, _, err = conn.ReadMessage()
+	testutil.Expect(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), true)
+}
+
+func TestSimulateWebSocketNotCaptured(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	proxy, dialer := webSocketProxy(t, dbClient)
+	defer proxy.Close()
+
+	_, resp, err := dialer.Dial("ws://127.0.0.1:1/missing", nil)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+	testutil.Expect(t, resp.Header.Get(ErrorCodeHeader), string(ErrorCodeCacheMiss))
+
+	// connection sent directly to the proxy
+	_, resp, err = websocket.DefaultDialer.Dial(strings.Replace(proxy.URL, "http", "ws", 1)+"/missing", nil)
+	testutil.Refute(t, err, nil)
+	testutil.Expect(t, resp.StatusCode, http.StatusPreconditionFailed)
+}
+
+func TestWebSocketPassedThroughWhenDisabled(t *testing.T) {
+	upstream := webSocketUpstream()
+	defer upstream.Close()
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+
+	dbClient.Cfg.SetMode(SimulateMode)
+	handler := &webSocketHandler{
+		handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}),
+		hf: dbClient,
+	}
+
+	req, err := http.NewRequest("GET", upstream.URL, nil)
+	testutil.Expect(t, err, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	testutil.Expect(t, rec.Code, http.StatusTeapot)
+}
+
+// pingingUpstream - greets client, pings it and then replies to every message with the message in upper case
+func pingingUpstream(pongs chan<- string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.SetPongHandler(func(data string) error {
+			pongs <- data
+			return nil
+		})
+		conn.WriteMessage(websocket.TextMessage, []byte("welcome"))
+		conn.WriteControl(websocket.PingMessage, []byte("are you there"), time.Now().Add(time.Second))
+		for {
+			opcode, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(opcode, []byte(strings.ToUpper(string(msg))))
+		}
+	}))
+}
+
+func TestCaptureWebSocketPingPong(t *testing.T) {
+	pongs := make(chan string, 1)
+	upstream := pingingUpstream(pongs)
+	defer upstream.Close()
+	wsURL := "ws://" + upstream.Listener.Addr().String() + "/chat"
+
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+
+	dbClient.Cfg.SetMode(CaptureMode)
+	proxy, dialer := webSocketProxy(t, dbClient)
+	defer proxy.Close()
+
+	conn, _, err := dialer.Dial(wsURL, nil)
+	testutil.Expect(t, err, nil)
+	chat(t, conn)
+
+	// pong of the client is relayed to upstream
+	select {
+	case data := <-pongs:
+		testutil.Expect(t, data, "are you there")
+	case <-time.After(time.Second):
+		t.Fatal("pong wasn't relayed to upstream")
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	conn.Close()
+
+	req, err := http.NewRequest("GET", wsURL, nil)
+	testutil.Expect(t, err, nil)
+	key := dbClient.getRequestFingerprint(req, nil)
+
+	var record *WebSocketRecord
+	for i := 0; i < 100; i++ {
+		if record, err = dbClient.getWebSocketRecord(key); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, len(record.Frames), 5)
+	testutil.Refute(t, record.ConnectedAt.IsZero(), true)
+
+	var ping, pong *WebSocketFrame
+	for i, frame := range record.Frames {
+		switch frame.Opcode {
+		case websocket.PingMessage:
+			ping = &record.Frames[i]
+		case websocket.PongMessage:
+			pong = &record.Frames[i]
+		}
+	}
+	testutil.Refute(t, ping, (*WebSocketFrame)(nil))
+	testutil.Expect(t, ping.Direction, WebSocketFromServer)
+	testutil.Expect(t, string(ping.Payload), "are you there")
+	testutil.Refute(t, pong, (*WebSocketFrame)(nil))
+	testutil.Expect(t, pong.Direction, WebSocketFromClient)
+	testutil.Expect(t, string(pong.Payload), "are you there")
+}
+
+// savePingRecord - saves connection in which upstream pings the client after greeting it
+func savePingRecord(t *testing.T, dbClient *Hoverfly, wsURL string) {
+	req, err := http.NewRequest("GET", wsURL, nil)
+	testutil.Expect(t, err, nil)
+
+	now := time.Now()
+	dbClient.saveWebSocketRecord(dbClient.getRequestFingerprint(req, nil), &WebSocketRecord{
+		Request:     webSocketRequestDetails(req),
+		ConnectedAt: now,
+		Frames: []WebSocketFrame{
+			{Direction: WebSocketFromServer, Opcode: websocket.TextMessage, Payload: []byte("welcome"), Timestamp: now},
+			{Direction: WebSocketFromServer, Opcode: websocket.PingMessage, Payload: []byte("are you there"), Timestamp: now.Add(10 * time.Millisecond)},
+			{Direction: WebSocketFromClient, Opcode: websocket.PongMessage, Payload: []byte("are you there"), Timestamp: now.Add(11 * time.Millisecond)},
+			{Direction: WebSocketFromClient, Opcode: websocket.TextMessage, Payload: []byte("hello"), Timestamp: now.Add(20 * time.Millisecond)},
+			{Direction: WebSocketFromServer, Opcode: websocket.TextMessage, Payload: []byte("HELLO"), Timestamp: now.Add(21 * time.Millisecond)},
+		},
+	})
+}
+
+func TestSimulateWebSocketPing(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+
+	wsURL := "ws://127.0.0.1:1/chat"
+	savePingRecord(t, dbClient, wsURL)
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.WebSocketPingTimeout = time.Second
+	proxy, dialer := webSocketProxy(t, dbClient)
+	defer proxy.Close()
+
+	conn, _, err := dialer.Dial(wsURL, nil)
+	testutil.Expect(t, err, nil)
+	defer conn.Close()
+
+	pings := make(chan string, 1)
+	conn.SetPingHandler(func(data string) error {
+		pings <- data
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	chat(t, conn)
+	testutil.Expect(t, <-pings, "are you there")
+
+	_, _, err = conn.ReadMessage()
+	testutil.Expect(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), true)
+}
+
+func TestSimulateWebSocketPingNotAnswered(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+
+	wsURL := "ws://127.0.0.1:1/chat"
+	savePingRecord(t, dbClient, wsURL)
+	dbClient.Cfg.SetMode(SimulateMode)
+	dbClient.Cfg.WebSocketPingTimeout = 100 * time.Millisecond
+	proxy, dialer := webSocketProxy(t, dbClient)
+	defer proxy.Close()
+
+	conn, _, err := dialer.Dial(wsURL, nil)
+	testutil.Expect(t, err, nil)
+	defer conn.Close()
+
+	// client doesn't answer pings
+	conn.SetPingHandler(func(string) error { return nil })
+
+	_, msg, err := conn.ReadMessage()
+	testutil.Expect(t, err, nil)
+	testutil.Expect(t, string(msg), "welcome")
+	testutil.Expect(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")), nil)
+
+	_, _, err = conn.ReadMessage()
+	testutil.Expect(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), true)
+}
+
+func TestSimulateWebSocketPingNotReplayedWithoutTimeout(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.MetadataCache.DeleteData()
+
+	wsURL := "ws://127.0.0.1:1/chat"
+	savePingRecord(t, dbClient, wsURL)
+	dbClient.Cfg.SetMode(SimulateMode)
+	proxy, dialer := webSocketProxy(t, dbClient)
+	defer proxy.Close()
+
+	conn, _, err := dialer.Dial(wsURL, nil)
+	testutil.Expect(t, err, nil)
+	defer conn.Close()
+
+	conn.SetPingHandler(func(string) error {
+		t.Error("ping was replayed")
+		return nil
+	})
+	chat(t, conn)
+
+	_, _, err = conn.ReadMessage()
+	testutil.Expect(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), true)
+}
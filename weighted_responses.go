@@ -0,0 +1,61 @@
+package hoverfly
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/SpectoLabs/hoverfly/models"
+)
+
+// WeightedSelector - picks responses of response sequences by their weight, i.e. weights 70 and 30 serve the first
+// response to 70% of requests. Selections are reproducible for the same seed.
+type WeightedSelector struct {
+	mu   sync.Mutex
+	seed int64
+	rand *rand.Rand
+}
+
+// NewWeightedSelector - returns weighted selector, random seed is used when seed is 0
+func NewWeightedSelector(seed int64) *WeightedSelector {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &WeightedSelector{seed: seed, rand: rand.New(rand.NewSource(seed))}
+}
+
+// Pick - returns index of response selected by weight, ok is false when no response has positive weight and
+// responses should be served in sequence instead. Responses without weight are never selected.
+func (s *WeightedSelector) Pick(responses []models.ResponseDetails) (index int, ok bool) {
+	total := 0
+	for _, response := range responses {
+		if response.Weight > 0 {
+			total += response.Weight
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+
+	s.mu.Lock()
+	n := s.rand.Intn(total)
+	s.mu.Unlock()
+
+	for i, response := range responses {
+		if response.Weight <= 0 {
+			continue
+		}
+		if n < response.Weight {
+			return i, true
+		}
+		n -= response.Weight
+	}
+	return len(responses) - 1, true
+}
+
+// Reset - starts selection from its seed again, so that the same responses are selected as after start
+func (s *WeightedSelector) Reset() {
+	s.mu.Lock()
+	s.rand = rand.New(rand.NewSource(s.seed))
+	s.mu.Unlock()
+}
@@ -0,0 +1,89 @@
+package hoverfly
+
+import (
+	"testing"
+
+	"github.com/SpectoLabs/hoverfly/models"
+	"github.com/SpectoLabs/hoverfly/testutil"
+)
+
+func TestWeightedResponsesDistribution(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+	dbClient.Weights = NewWeightedSelector(42)
+
+	importSequencePayload(t, dbClient,
+		models.ResponseDetails{Status: 200, Weight: 70},
+		models.ResponseDetails{Status: 500, Weight: 30},
+	)
+
+	counts := map[int]int{}
+	for i := 0; i < 1000; i++ {
+		status, _ := getSequenceResponse(t, dbClient)
+		counts[status]++
+	}
+	testutil.Expect(t, counts[200]+counts[500], 1000)
+	testutil.Expect(t, counts[200] > 650 && counts[200] < 750, true)
+}
+
+func TestWeightedResponsesReproducibleWithSeed(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	importSequencePayload(t, dbClient,
+		models.ResponseDetails{Status: 200, Weight: 50},
+		models.ResponseDetails{Status: 500, Weight: 50},
+	)
+
+	statuses := func() []int {
+		var s []int
+		for i := 0; i < 50; i++ {
+			status, _ := getSequenceResponse(t, dbClient)
+			s = append(s, status)
+		}
+		return s
+	}
+
+	dbClient.Weights = NewWeightedSelector(7)
+	first := statuses()
+	dbClient.Weights = NewWeightedSelector(7)
+	second := statuses()
+	// reset starts from the seed again
+	dbClient.Weights.Reset()
+	third := statuses()
+
+	testutil.Expect(t, len(first), len(second))
+	for i := range first {
+		testutil.Expect(t, first[i], second[i])
+		testutil.Expect(t, first[i], third[i])
+	}
+}
+
+func TestWeightedResponsesSkipUnweighted(t *testing.T) {
+	selector := NewWeightedSelector(1)
+	responses := []models.ResponseDetails{{Status: 404}, {Status: 200, Weight: 1}, {Status: 500, Weight: -5}}
+
+	for i := 0; i < 20; i++ {
+		index, ok := selector.Pick(responses)
+		testutil.Expect(t, ok, true)
+		testutil.Expect(t, index, 1)
+	}
+}
+
+func TestWeightedResponsesWithoutWeightsAreSequence(t *testing.T) {
+	server, dbClient := testTools(200, `{'message': 'here'}`)
+	defer server.Close()
+	defer dbClient.RequestCache.DeleteData()
+
+	importSequencePayload(t, dbClient,
+		models.ResponseDetails{Status: 404},
+		models.ResponseDetails{Status: 200},
+	)
+
+	status, _ := getSequenceResponse(t, dbClient)
+	testutil.Expect(t, status, 404)
+	status, _ = getSequenceResponse(t, dbClient)
+	testutil.Expect(t, status, 200)
+}